@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineAddressTool.
+const (
+	defaultAddressToolName        = "askAddress"
+	defaultAddressToolDescription = "use this to ask the user for a postal address, such as where to ship the gifts; the response holds the name, street, city, postcode and country as separate fields"
+)
+
+// AddressInput contains a request for a postal address.
+type AddressInput struct {
+	Question string `json:"question" jsonschema:"description=what the address is for, such as 'Where should the gifts be shipped?'"`
+}
+
+// Address is the postal address the address tool responds with.
+type Address struct {
+	Name     string `json:"name"`
+	Street   string `json:"street"`
+	City     string `json:"city"`
+	Postcode string `json:"postcode"`
+	Country  string `json:"country"`
+}
+
+// PostcodeValidator reports postcodes that aren't valid in a country. Its error tells the user the
+// expected format.
+type PostcodeValidator func(postcode string) error
+
+// addressField is a field of the address, asked in the order of addressFields.
+type addressField struct {
+	name  string
+	label string
+	value func(*Address) *string
+}
+
+// addressFields lists the fields in the order they are asked; the country comes before the postcode,
+// whose format depends on it.
+var addressFields = []addressField{
+	{name: "name", label: "Full name", value: func(a *Address) *string { return &a.Name }},
+	{name: "street", label: "Street and number", value: func(a *Address) *string { return &a.Street }},
+	{name: "city", label: "City", value: func(a *Address) *string { return &a.City }},
+	{name: "country", label: "Country", value: func(a *Address) *string { return &a.Country }},
+	{name: "postcode", label: "Postcode", value: func(a *Address) *string { return &a.Postcode }},
+}
+
+// DefineAddressTool defines the address tool, named "askAddress" by default, in the Genkit instance and
+// returns it. Its interrupts are answered by the handler returned by NewAddressHandler, which must be
+// registered for the tool name with RegisterToolHandler.
+func DefineAddressTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultAddressToolName, defaultAddressToolDescription, opts)
+
+	return DefineInterruptTool[AddressInput, Address](g, config.name, config.description, WithInterruptMetadataKey("address"))
+}
+
+// addressHandler configures the handler returned by NewAddressHandler.
+type addressHandler struct {
+	// postcodeValidators check postcodes by lower-case country code or name.
+	postcodeValidators map[string]PostcodeValidator
+}
+
+// AddressOption configures the handler of the address tool.
+type AddressOption func(*addressHandler)
+
+// WithPostcodeValidator checks the postcodes of addresses in the country, given as a code or a name
+// matched ignoring case, with the validator. It replaces the built-in check of the country, if any.
+func WithPostcodeValidator(country string, validator PostcodeValidator) AddressOption {
+	return func(h *addressHandler) {
+		h.postcodeValidators[strings.ToLower(strings.TrimSpace(country))] = validator
+	}
+}
+
+// NewAddressHandler returns the ToolHandler of the address tool. Interactors implementing FormAsker
+// show all the fields at once; the others are asked a question per field, after the question is shown
+// with Notify. Every field is required, and postcodes are checked for the United States, Canada, the
+// United Kingdom, Germany, France and the Netherlands unless other validators are given. A field that
+// fails is asked again on its own. It responds with an Address.
+func NewAddressHandler(opts ...AddressOption) ToolHandler {
+	h := &addressHandler{postcodeValidators: defaultPostcodeValidators()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h.handle
+}
+
+func (h *addressHandler) handle(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var request AddressInput
+	if err := decodeToolInput(input, &request); err != nil {
+		return ToolResult{}, err
+	}
+	if request.Question == "" {
+		return ToolResult{}, errors.New("address tool called without a question")
+	}
+
+	var address Address
+	entered := map[string]string{}
+	if asker, ok := interactor.(FormAsker); ok {
+		var err error
+		if entered, err = asker.AskForm(ctx, h.form(request)); err != nil {
+			return ToolResult{}, err
+		}
+	} else if err := interactor.Notify(ctx, request.Question); err != nil {
+		return ToolResult{}, err
+	}
+
+	for _, field := range addressFields {
+		parse := h.fieldParser(field, &address)
+		if _, ok := entered[field.name]; ok {
+			value, err := parse(entered[field.name])
+			if err == nil {
+				*field.value(&address) = value.(string)
+				continue
+			}
+			// only the failing field is asked again
+			if err := interactor.Notify(ctx, invalidAnswerMessage(fmt.Errorf("%s: %w", field.label, err))); err != nil {
+				return ToolResult{}, err
+			}
+		}
+		value, err := askParsed(ctx, interactor, QuestionInput{Question: field.label}, parse)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		*field.value(&address) = value.(string)
+	}
+	return ToolResult{Output: address}, nil
+}
+
+// form returns the address fields as a form for FormAsker interactors.
+func (h *addressHandler) form(request AddressInput) FormInput {
+	form := FormInput{Title: request.Question}
+	for _, field := range addressFields {
+		form.Fields = append(form.Fields, FormField{Name: field.name, Label: field.label, Type: FormFieldText, Required: true})
+	}
+	return form
+}
+
+// fieldParser returns the parser of the field's answer. The postcode is checked against the country
+// already set in address.
+func (h *addressHandler) fieldParser(field addressField, address *Address) AnswerParser {
+	return func(answer string) (any, error) {
+		value := strings.Join(strings.Fields(answer), " ")
+		if value == "" {
+			return nil, errors.New("a value is required")
+		}
+		if field.name != "postcode" {
+			return value, nil
+		}
+		if validate, ok := h.postcodeValidators[strings.ToLower(address.Country)]; ok {
+			if err := validate(value); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}
+}
+
+// defaultPostcodeValidators returns the built-in postcode checks by lower-case country code and name.
+func defaultPostcodeValidators() map[string]PostcodeValidator {
+	formats := []struct {
+		countries []string
+		pattern   string
+		example   string
+	}{
+		{countries: []string{"us", "usa", "united states"}, pattern: `^\d{5}(-\d{4})?$`, example: "12345 or 12345-6789"},
+		{countries: []string{"ca", "canada"}, pattern: `^[A-Za-z]\d[A-Za-z] ?\d[A-Za-z]\d$`, example: "K1A 0B1"},
+		{countries: []string{"gb", "uk", "united kingdom"}, pattern: `^[A-Za-z]{1,2}\d[A-Za-z\d]? ?\d[A-Za-z]{2}$`, example: "SW1A 1AA"},
+		{countries: []string{"de", "germany"}, pattern: `^\d{5}$`, example: "10115"},
+		{countries: []string{"fr", "france"}, pattern: `^\d{5}$`, example: "75001"},
+		{countries: []string{"nl", "netherlands"}, pattern: `^\d{4} ?[A-Za-z]{2}$`, example: "1012 AB"},
+	}
+
+	validators := make(map[string]PostcodeValidator)
+	for _, format := range formats {
+		pattern := regexp.MustCompile(format.pattern)
+		validate := func(postcode string) error {
+			if !pattern.MatchString(postcode) {
+				return fmt.Errorf("expected a postcode such as %s", format.example)
+			}
+			return nil
+		}
+		for _, country := range format.countries {
+			validators[country] = validate
+		}
+	}
+	return validators
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressHandler(t *testing.T) {
+	shippingAddress := map[string]any{"question": "Where should the gifts be shipped?"}
+
+	tests := []struct {
+		name          string
+		opts          []AddressOption
+		answers       []Answer
+		expected      Address
+		notifications []string
+		err           error
+	}{
+		{
+			name:     "complete address",
+			answers:  []Answer{{Value: "Ann  Lee"}, {Value: "1 Main St"}, {Value: "Springfield"}, {Value: "US"}, {Value: "12345-6789"}},
+			expected: Address{Name: "Ann Lee", Street: "1 Main St", City: "Springfield", Postcode: "12345-6789", Country: "US"},
+			notifications: []string{
+				"Where should the gifts be shipped?",
+			},
+		},
+		{
+			name:     "missing required field",
+			answers:  []Answer{{Value: "Ann Lee"}, {Value: " "}, {Value: "1 Main St"}, {Value: "Paris"}, {Value: "France"}, {Value: "75001"}},
+			expected: Address{Name: "Ann Lee", Street: "1 Main St", City: "Paris", Postcode: "75001", Country: "France"},
+			notifications: []string{
+				"Where should the gifts be shipped?",
+				"Invalid answer: a value is required",
+			},
+		},
+		{
+			name:     "postcode format rejected",
+			answers:  []Answer{{Value: "Ann Lee"}, {Value: "10 Downing St"}, {Value: "London"}, {Value: "united kingdom"}, {Value: "12345"}, {Value: "SW1A 2AA"}},
+			expected: Address{Name: "Ann Lee", Street: "10 Downing St", City: "London", Postcode: "SW1A 2AA", Country: "united kingdom"},
+			notifications: []string{
+				"Where should the gifts be shipped?",
+				"Invalid answer: expected a postcode such as SW1A 1AA",
+			},
+		},
+		{
+			name:     "any postcode in other countries",
+			answers:  []Answer{{Value: "Ann Lee"}, {Value: "Via Roma 1"}, {Value: "Rome"}, {Value: "Italy"}, {Value: "00184"}},
+			expected: Address{Name: "Ann Lee", Street: "Via Roma 1", City: "Rome", Postcode: "00184", Country: "Italy"},
+			notifications: []string{
+				"Where should the gifts be shipped?",
+			},
+		},
+		{
+			name: "custom validator",
+			opts: []AddressOption{WithPostcodeValidator("Italy", func(postcode string) error {
+				if len(postcode) != 5 {
+					return errors.New("expected 5 digits")
+				}
+				return nil
+			})},
+			answers:  []Answer{{Value: "Ann Lee"}, {Value: "Via Roma 1"}, {Value: "Rome"}, {Value: "italy"}, {Value: "184"}, {Value: "00184"}},
+			expected: Address{Name: "Ann Lee", Street: "Via Roma 1", City: "Rome", Postcode: "00184", Country: "italy"},
+			notifications: []string{
+				"Where should the gifts be shipped?",
+				"Invalid answer: expected 5 digits",
+			},
+		},
+		{
+			name:    "skipped",
+			answers: []Answer{{Value: "Ann Lee"}, {Skipped: true}},
+			err:     ErrQuestionSkipped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interactor := &sequenceInteractor{answers: tt.answers}
+
+			result, err := NewAddressHandler(tt.opts...)(context.Background(), interactor, shippingAddress)
+
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Output)
+			assert.Equal(t, tt.notifications, interactor.notifications)
+		})
+	}
+}
+
+func TestAddressHandler_WholeForm(t *testing.T) {
+	asker := &fakeFormAsker{
+		fakeInteractor: fakeInteractor{answers: map[string]Answer{"Postcode": {Value: "1012 AB"}}},
+		forms: []map[string]string{
+			{"name": "Ann Lee", "street": "Damrak 1", "city": "Amsterdam", "country": "NL", "postcode": "ABC"},
+		},
+	}
+
+	result, err := NewAddressHandler()(context.Background(), asker, map[string]any{"question": "Where should the gifts be shipped?"})
+
+	require.NoError(t, err)
+	assert.Equal(t, Address{Name: "Ann Lee", Street: "Damrak 1", City: "Amsterdam", Postcode: "1012 AB", Country: "NL"}, result.Output)
+	assert.Equal(t, []string{"Invalid answer: Postcode: expected a postcode such as 1012 AB"}, asker.notified, "only the postcode is asked again")
+}
+
+func TestAddressHandler_ThroughToolHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askAddress", map[string]any{"question": "Where should the gifts be shipped?"})),
+			createTextResponse("Shipping to Berlin...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askAddress": createMockTool("askAddress")},
+	)
+	var out bytes.Buffer
+	terminal := NewTerminalReader(ctx, strings.NewReader("Ann Lee\nUnter den Linden 1\nBerlin\nDE\n1011\n10117\n"), WithAnswerTimeout(time.Second), WithOutput(&out), WithWrapping(false))
+	handler := &InterruptionHandler{generator: mockGen, Interactor: terminal}
+	handler.RegisterToolHandler("askAddress", NewAddressHandler())
+
+	_, err := RunAgent(ctx, &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, Address{Name: "Ann Lee", Street: "Unter den Linden 1", City: "Berlin", Postcode: "10117", Country: "DE"}, parts[0].ToolResponse.Output)
+	assert.Contains(t, out.String(), "Where should the gifts be shipped?\n")
+	assert.Contains(t, out.String(), "Invalid answer: expected a postcode such as 10115\n")
+}
+
+func TestDefineAddressTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	address := DefineAddressTool(g)
+	_, err := address.RunRaw(context.Background(), map[string]any{"question": "Where should the gifts be shipped?"})
+
+	assert.Equal(t, "askAddress", address.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, AddressInput{Question: "Where should the gifts be shipped?"}, metadata["address"])
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/firebase/genkit/go/core"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// defaultFlowName is the name the clarifying agent flow is registered under unless configured otherwise.
+const defaultFlowName = "clarifyingAgent"
+
+// ClarifyingAgentInput is the input of the clarifying agent flow.
+type ClarifyingAgentInput struct {
+	UserPrompt   string `json:"userPrompt"`
+	SystemPrompt string `json:"systemPrompt,omitempty"`
+	// Answers are the canned answers to the agent's questions, keyed by a substring of the question.
+	Answers map[string]string `json:"answers,omitempty"`
+}
+
+// QuestionAnswer is a question asked by the agent together with the answer it was given.
+type QuestionAnswer struct {
+	Question string `json:"question"`
+	// Reason is why the agent asked the question, when it said so.
+	Reason string `json:"reason,omitempty"`
+	Answer string `json:"answer"`
+}
+
+// AgentResult is the output of the clarifying agent flow.
+type AgentResult struct {
+	Text string `json:"text"`
+	// Transcript lists the questions the agent asked in the order they were answered. Secret answers
+	// are recorded as RedactedAnswer.
+	Transcript []QuestionAnswer `json:"transcript,omitempty"`
+	// DeferredQuestions counts the questions the user put off to answer after the others.
+	DeferredQuestions int `json:"deferredQuestions,omitempty"`
+	// Consents are the records of the consent questions, in the order they were answered.
+	Consents []ConsentRecord `json:"consents,omitempty"`
+	// Cost is the cost of the model calls of the run, set when the generator counts it with a CostTracker.
+	Cost *Cost `json:"cost,omitempty"`
+}
+
+// ErrNoCannedAnswer is returned by the flow when the agent asks a question none of the canned answers match.
+type ErrNoCannedAnswer struct {
+	Question string
+}
+
+func (e *ErrNoCannedAnswer) Error() string {
+	return fmt.Sprintf("no canned answer matches the question %q; add an answer keyed by a part of it", e.Question)
+}
+
+// clarifyingAgentFlow runs the agent for a flow input.
+type clarifyingAgentFlow struct {
+	name         string
+	generator    Generator
+	systemPrompt SystemPrompt
+	toolNames    []string
+	toolHandlers map[string]ToolHandler
+}
+
+// FlowOption configures the clarifying agent flow.
+type FlowOption func(*clarifyingAgentFlow)
+
+// WithFlowName registers the flow under the name instead of "clarifyingAgent".
+func WithFlowName(name string) FlowOption {
+	return func(f *clarifyingAgentFlow) {
+		f.name = name
+	}
+}
+
+// WithFlowSystemPrompt sets the system prompt used when the input doesn't provide one.
+func WithFlowSystemPrompt(systemPrompt SystemPrompt) FlowOption {
+	return func(f *clarifyingAgentFlow) {
+		f.systemPrompt = systemPrompt
+	}
+}
+
+// WithFlowGenerator runs the agent with the generator instead of a bare GenkitGenerator, such as one
+// retrying, caching and counting the cost of the model calls with a CostTracker, which sets
+// AgentResult.Cost.
+func WithFlowGenerator(generator Generator) FlowOption {
+	return func(f *clarifyingAgentFlow) {
+		f.generator = generator
+	}
+}
+
+// WithFlowTools sets the tools offered to the model instead of the askQuestion tool alone.
+func WithFlowTools(toolNames ...string) FlowOption {
+	return func(f *clarifyingAgentFlow) {
+		f.toolNames = toolNames
+	}
+}
+
+// WithFlowToolHandler answers the interrupts of the named tool with the handler, like
+// InterruptionHandler.RegisterToolHandler. The handler's questions are answered from the canned answers too.
+func WithFlowToolHandler(name string, handler ToolHandler) FlowOption {
+	return func(f *clarifyingAgentFlow) {
+		if f.toolHandlers == nil {
+			f.toolHandlers = make(map[string]ToolHandler)
+		}
+		f.toolHandlers[name] = handler
+	}
+}
+
+// DefineClarifyingAgentFlow registers a flow that runs the agent, so that it can be run and traced from the
+// Genkit developer UI. The Dev UI can't answer questions live, so they are answered from the canned answers
+// in the input. The askQuestion tool must be defined on g unless other tools are set with WithFlowTools. The
+// model is called through g unless another generator is set with WithFlowGenerator.
+func DefineClarifyingAgentFlow(g *genkit.Genkit, opts ...FlowOption) *core.Flow[ClarifyingAgentInput, AgentResult, struct{}] {
+	flow := newClarifyingAgentFlow(&GenkitGenerator{AIClient: g}, opts...)
+	return genkit.DefineFlow(g, flow.name, flow.run)
+}
+
+func newClarifyingAgentFlow(generator Generator, opts ...FlowOption) *clarifyingAgentFlow {
+	flow := &clarifyingAgentFlow{
+		name:      defaultFlowName,
+		generator: generator,
+		toolNames: []string{"askQuestion"},
+	}
+	for _, opt := range opts {
+		opt(flow)
+	}
+	return flow
+}
+
+// run runs the agent, answering its questions from the canned answers.
+func (f *clarifyingAgentFlow) run(ctx context.Context, input ClarifyingAgentInput) (AgentResult, error) {
+	if strings.TrimSpace(input.UserPrompt) == "" {
+		return AgentResult{}, errors.New("userPrompt is required")
+	}
+	systemPrompt := f.systemPrompt
+	if input.SystemPrompt != "" {
+		systemPrompt = SystemPrompt(input.SystemPrompt)
+	}
+
+	var transcript []QuestionAnswer
+	interaction := func(ctx context.Context, question QuestionInput) (string, error) {
+		answer, ok := cannedAnswer(input.Answers, question.Question)
+		if !ok {
+			return "", &ErrNoCannedAnswer{Question: question.Question}
+		}
+		recorded := answer
+		if IsSecretInput(ctx) {
+			recorded = RedactedAnswer
+		}
+		transcript = append(transcript, QuestionAnswer{Question: question.Question, Reason: question.Reason, Answer: recorded})
+		return answer, nil
+	}
+
+	deferred := 0
+	var consents []ConsentRecord
+	handler := &InterruptionHandler{
+		generator:       f.generator,
+		UserInteraction: interaction,
+		toolNames:       f.toolNames,
+		OnAnswer: func(_ context.Context, answered AnsweredInterrupt) {
+			if answered.Deferred {
+				deferred++
+			}
+			if record, ok := answered.Metadata["consent"].(ConsentRecord); ok {
+				consents = append(consents, record)
+			}
+		},
+	}
+	for name, toolHandler := range f.toolHandlers {
+		handler.RegisterToolHandler(name, toolHandler)
+	}
+	ctx, costs := WithCostScope(ctx)
+	text, err := RunAgent(ctx, &Options{
+		generator:       f.generator,
+		systemPrompt:    systemPrompt,
+		userPrompt:      UserPrompt(input.UserPrompt),
+		toolNames:       f.toolNames,
+		responseHandler: handler,
+	})
+	if err != nil {
+		return AgentResult{}, err
+	}
+	result := AgentResult{Text: text, Transcript: transcript, DeferredQuestions: deferred, Consents: consents}
+	if cost, ok := costs.Snapshot(); ok {
+		result.Cost = &cost
+	}
+	return result, nil
+}
+
+// cannedAnswer returns the answer whose key is a case-insensitive substring of the question.
+// When several keys match, the longest one wins so that the most specific answer is used.
+func cannedAnswer(answers map[string]string, question string) (string, bool) {
+	question = strings.ToLower(question)
+	var matched string
+	found := false
+	for key := range answers {
+		if !strings.Contains(question, strings.ToLower(key)) {
+			continue
+		}
+		if !found || len(key) > len(matched) || (len(key) == len(matched) && key < matched) {
+			matched, found = key, true
+		}
+	}
+	return answers[matched], found
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClarifyingAgentFlow_Run(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createInterruptPart("askQuestion", map[string]any{"question": "What are their ages?", "reason": "Gifts differ by age"}),
+			),
+			createTextResponse("Based on both genders and ages...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	flow := newClarifyingAgentFlow(mockGen)
+
+	result, err := flow.run(context.Background(), ClarifyingAgentInput{
+		UserPrompt: "Suggest a gift.",
+		Answers: map[string]string{
+			"gender":               "Both",
+			"what gender":          "Boy and girl",
+			"AGES":                 "8 and 11",
+			"unrelated to anybody": "ignored",
+		},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Based on both genders and ages...", result.Text)
+	assert.Equal(t, []QuestionAnswer{
+		{Question: "What gender are the children?", Answer: "Boy and girl"},
+		{Question: "What are their ages?", Reason: "Gifts differ by age", Answer: "8 and 11"},
+	}, result.Transcript)
+}
+
+func TestClarifyingAgentFlow_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input ClarifyingAgentInput
+		check func(t *testing.T, err error)
+	}{
+		{
+			name:  "missing user prompt",
+			input: ClarifyingAgentInput{},
+			check: func(t *testing.T, err error) {
+				assert.ErrorContains(t, err, "userPrompt is required")
+			},
+		},
+		{
+			name:  "unanswered question",
+			input: ClarifyingAgentInput{UserPrompt: "Suggest a gift.", Answers: map[string]string{"budget": "$50"}},
+			check: func(t *testing.T, err error) {
+				var noAnswer *ErrNoCannedAnswer
+				require.True(t, errors.As(err, &noAnswer), "unexpected error: %v", err)
+				assert.Equal(t, "What are their ages?", noAnswer.Question)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGen := NewMockGenerator(
+				[]*ai.ModelResponse{
+					createInterruptedResponse(createToolRequestPart("askQuestion", "What are their ages?", nil)),
+					createTextResponse("unreachable", "stop"),
+				},
+				map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+			)
+
+			_, err := newClarifyingAgentFlow(mockGen).run(context.Background(), tt.input)
+
+			tt.check(t, err)
+		})
+	}
+}
+
+func TestDefineClarifyingAgentFlow(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	flow := DefineClarifyingAgentFlow(g, WithFlowName("giftAdvisor"))
+
+	assert.Equal(t, "giftAdvisor", flow.Name())
+}
+
+func TestDefineClarifyingAgentFlow_Generator(t *testing.T) {
+	g, calls := countingModel(func(*ai.ModelRequest) *ai.ModelResponse {
+		return &ai.ModelResponse{Message: ai.NewModelTextMessage("A LEGO set"), FinishReason: ai.FinishReasonStop}
+	})
+	DefineAskQuestionTool(g)
+	generator := NewCostTracker(&GenkitGenerator{AIClient: g, Model: "test/counting"}, map[string]ModelPrice{"test/counting": {InputPricePer1K: 1, OutputPricePer1K: 2}})
+
+	flow := DefineClarifyingAgentFlow(g, WithFlowGenerator(generator))
+	result, err := flow.Run(context.Background(), ClarifyingAgentInput{UserPrompt: "Suggest a gift."})
+
+	require.NoError(t, err)
+	assert.Equal(t, "A LEGO set", result.Text)
+	assert.Equal(t, int64(1), calls.Load())
+	require.NotNil(t, result.Cost, "the cost is counted by the generator of the flow")
+	assert.InDelta(t, 0.02, result.Cost.Dollars, 1e-9)
+}
+
+func TestClarifyingAgentFlow_DeferredQuestions(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askBudget", map[string]any{"question": "What budget?"})),
+			createInterruptedResponse(createInterruptPart("askBudget", map[string]any{"question": "What budget?"})),
+			createTextResponse("A LEGO set", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askBudget": createMockTool("askBudget")},
+	)
+	asked := 0
+	// the first time the question is put off
+	askBudget := func(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+		asked++
+		if asked == 1 {
+			return ToolResult{}, ErrQuestionDeferred
+		}
+		return ToolResult{Output: "$50"}, nil
+	}
+	flow := newClarifyingAgentFlow(mockGen, WithFlowToolHandler("askBudget", askBudget))
+
+	result, err := flow.run(context.Background(), ClarifyingAgentInput{UserPrompt: "Suggest a gift."})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.DeferredQuestions)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, deferredAnswer, parts[0].ToolResponse.Output)
+}
@@ -0,0 +1,4 @@
+// Package agentpb contains the gRPC API of the clarifying agent, generated from agent.proto.
+package agentpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative agent.proto
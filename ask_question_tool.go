@@ -1,30 +1,225 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
+	"github.com/invopop/jsonschema"
 )
 
 // QuestionInput contains a question to ask the user and optional multiple choice answers.
 type QuestionInput struct {
-	Question string   `json:"question" jsonschema:"description=A clarifying question"`
-	Choices  []string `json:"choices" jsonschema:"description=the choices to display to the user"`
-}
-
-// DefineAskQuestionTool defines the "askQuestion" tool in the Genkit instance.
-// This tool allows the AI to ask clarifying questions to the user.
-func DefineAskQuestionTool(g *genkit.Genkit) {
-	genkit.DefineTool(
-		g,
-		"askQuestion",
-		"use this to ask the user any clarifying question",
-		func(ctx *ai.ToolContext, input QuestionInput) (string, error) {
-			return "", ctx.Interrupt(&ai.InterruptOptions{
-				Metadata: map[string]any{
-					"question": input,
-				},
-			})
-		},
-	)
+	Question  string   `json:"question" jsonschema:"description=A clarifying question"`
+	Reason    string   `json:"reason,omitempty" jsonschema:"description=one short sentence telling the user why the answer matters, such as 'Gifts for toddlers differ a lot from gifts for teenagers'; always fill it in"`
+	Choices   []string `json:"choices" jsonschema:"description=the choices to display to the user; either plain strings or {value, label, description} objects whose label is shown with the optional description and whose value is returned when picked"`
+	Default   string   `json:"default,omitempty" jsonschema:"description=the answer to use when the user submits an empty line"`
+	MultiLine bool     `json:"multiLine,omitempty" jsonschema:"description=set when the answer is expected to span several lines"`
+	// TimeoutSeconds overrides how long the user has to answer; zero keeps the interactor's default.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty" jsonschema:"description=seconds the user needs to answer when the question requires checking something first"`
+	// AllowFreeText and Required default to true when left out, which is why they are pointers.
+	AllowFreeText *bool `json:"allowFreeText,omitempty" jsonschema:"default=true,description=set to false when the answer must be one of the choices; otherwise the choices are only suggestions"`
+	Required      *bool `json:"required,omitempty" jsonschema:"default=true,description=set to false when the user may leave the question unanswered"`
+	// ChoicesSource names the ChoiceProvider that fills in the choices when the question is asked.
+	ChoicesSource string `json:"choicesSource,omitempty" jsonschema:"description=the name of a live source of choices the application offers, such as 'nearby_stores'; its choices replace or extend the given ones when the question is asked"`
+	// Priority orders the questions of a batch when the handler's InterruptOrder is ByPriority.
+	Priority int `json:"priority,omitempty" jsonschema:"description=when asking several questions at once, questions with a higher priority are asked first"`
+	// Media are images the question refers to, which interactors show with the question.
+	Media []Media `json:"media,omitempty" jsonschema:"description=images the question refers to, such as the bikes in 'which of these two bikes looks right?'; leave it out when the question needs none"`
+	// ChoiceValues are the values of the choices given as {value, label} objects, by index: Choices holds
+	// the labels shown to the user and the answer selecting one is replaced by its value. It is empty
+	// when all choices are plain strings.
+	ChoiceValues []string `json:"-"`
+	// ChoiceDescriptions are the one-line descriptions of the choices given as objects, by index, which
+	// interactors show under the choices. It is empty when no choice has a description.
+	ChoiceDescriptions []string `json:"choiceDescriptions,omitempty"`
+}
+
+// Choice is a choice given as an object: Label is shown to the user and Value is the answer the model
+// gets when it is picked. Label defaults to Value. Description is shown under the label.
+type Choice struct {
+	Value       string `json:"value"`
+	Label       string `json:"label,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// UnmarshalJSON accepts choices given as plain strings, as Choice objects, or as a mix of both. A
+// default given as the value of a choice is replaced by its label, which is what interactors show.
+// Descriptions given with the choices replace ChoiceDescriptions. Media that are neither an http(s) URL
+// nor a valid data URI are rejected.
+func (q *QuestionInput) UnmarshalJSON(data []byte) error {
+	type plainQuestionInput QuestionInput
+	var raw struct {
+		plainQuestionInput
+		Choices []json.RawMessage `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*q = QuestionInput(raw.plainQuestionInput)
+	for i, media := range q.Media {
+		if err := media.validate(); err != nil {
+			return fmt.Errorf("media %d: %w", i+1, err)
+		}
+	}
+	if raw.Choices == nil {
+		return nil
+	}
+	q.Choices = make([]string, 0, len(raw.Choices))
+	values := make([]string, 0, len(raw.Choices))
+	descriptions := make([]string, 0, len(raw.Choices))
+	hasValues, hasDescriptions := false, false
+	for i, rawChoice := range raw.Choices {
+		var choice Choice
+		if err := json.Unmarshal(rawChoice, &choice.Value); err != nil {
+			if err := json.Unmarshal(rawChoice, &choice); err != nil {
+				return fmt.Errorf("choice %d is neither a string nor a {value, label} object: %w", i+1, err)
+			}
+			hasValues = true
+		}
+		if choice.Label == "" {
+			choice.Label = choice.Value
+		}
+		q.Choices = append(q.Choices, choice.Label)
+		values = append(values, choice.Value)
+		descriptions = append(descriptions, choice.Description)
+		hasDescriptions = hasDescriptions || choice.Description != ""
+	}
+	if hasDescriptions {
+		q.ChoiceDescriptions = descriptions
+	}
+	if hasValues {
+		q.ChoiceValues = values
+		if i := slices.Index(values, q.Default); i >= 0 {
+			q.Default = q.Choices[i]
+		}
+	}
+	return nil
+}
+
+// unmarshalEmbeddedQuestion decodes data into the QuestionInput embedded in a type and into the other
+// fields of the type, which the promoted QuestionInput.UnmarshalJSON would leave out.
+func unmarshalEmbeddedQuestion(data []byte, question *QuestionInput, fields any) error {
+	if err := json.Unmarshal(data, question); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, fields)
+}
+
+// JSONSchemaExtend lets the model give each choice as a plain string or as a Choice object, which is
+// also the only way to describe the choices.
+func (QuestionInput) JSONSchemaExtend(schema *jsonschema.Schema) {
+	schema.Properties.Delete("choiceDescriptions")
+	choices, ok := schema.Properties.Get("choices")
+	if !ok {
+		return
+	}
+	object := &jsonschema.Schema{Type: "object", Properties: jsonschema.NewProperties(), Required: []string{"value"}}
+	object.Properties.Set("value", &jsonschema.Schema{Type: "string", Description: "the answer the model gets when the choice is picked"})
+	object.Properties.Set("label", &jsonschema.Schema{Type: "string", Description: "the text shown to the user; defaults to the value"})
+	object.Properties.Set("description", &jsonschema.Schema{Type: "string", Description: "one line shown under the label, such as 'best for rainy days'"})
+	choices.Items = &jsonschema.Schema{OneOf: []*jsonschema.Schema{{Type: "string"}, object}}
+}
+
+// FreeTextAllowed reports whether answers other than the choices are accepted. Questions without
+// choices always accept free text.
+func (q QuestionInput) FreeTextAllowed() bool {
+	return len(q.Choices) == 0 || q.AllowFreeText == nil || *q.AllowFreeText
+}
+
+// IsRequired reports whether the question must be answered; an empty answer to an optional question
+// without a default skips it.
+func (q QuestionInput) IsRequired() bool {
+	return q.Required == nil || *q.Required
+}
+
+// matchAnswer maps an answer to a question with strict choices to the choice it selects by text or
+// number, and reports answers selecting none. Answers to other questions are returned unchanged.
+func (q QuestionInput) matchAnswer(answer string) (string, error) {
+	if q.FreeTextAllowed() {
+		return answer, nil
+	}
+	if resolved, ok := resolveChoice(q.Choices, strings.TrimSpace(answer)); ok {
+		if choice, ok := matchChoice(q.Choices, resolved); ok {
+			return choice, nil
+		}
+	}
+	if slices.Contains(q.ChoiceValues, answer) {
+		return answer, nil
+	}
+	return "", fmt.Errorf("expected one of: %s", strings.Join(q.Choices, ", "))
+}
+
+// choiceDescription returns the description of the choice with the index, or "" when it has none.
+func (q QuestionInput) choiceDescription(i int) string {
+	if i < 0 || i >= len(q.ChoiceDescriptions) {
+		return ""
+	}
+	return q.ChoiceDescriptions[i]
+}
+
+// choiceValue returns the value of the choice whose label the answer matches, or the answer unchanged
+// when the choices have no values or it matches none.
+func (q QuestionInput) choiceValue(answer string) string {
+	if len(q.ChoiceValues) != len(q.Choices) {
+		return answer
+	}
+	for i, choice := range q.Choices {
+		if strings.EqualFold(strings.TrimSpace(choice), strings.TrimSpace(answer)) {
+			return q.ChoiceValues[i]
+		}
+	}
+	return answer
+}
+
+// Defaults of the tool defined by DefineAskQuestionTool.
+const (
+	defaultAskQuestionToolName        = "askQuestion"
+	defaultAskQuestionToolDescription = "use this to ask the user any clarifying question"
+)
+
+// toolConfig is the name and description a question tool is defined with.
+type toolConfig struct {
+	name        string
+	description string
+}
+
+// ToolOption configures a question tool.
+type ToolOption func(*toolConfig)
+
+// newToolConfig returns the configuration of a tool with the defaults overridden by the options.
+func newToolConfig(name, description string, opts []ToolOption) toolConfig {
+	config := toolConfig{name: name, description: description}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// WithToolName sets the name the tool is defined and called by.
+func WithToolName(name string) ToolOption {
+	return func(c *toolConfig) {
+		c.name = name
+	}
+}
+
+// WithToolDescription sets the description telling the model when to use the tool.
+func WithToolDescription(description string) ToolOption {
+	return func(c *toolConfig) {
+		c.description = description
+	}
+}
+
+// DefineAskQuestionTool defines the question tool, named "askQuestion" by default, in the Genkit
+// instance and returns it. This tool allows the AI to ask clarifying questions to the user. Options
+// rename the tool or change its description, so that several question tools can be defined side by side.
+// The interrupt metadata carries the question and the tool name.
+func DefineAskQuestionTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultAskQuestionToolName, defaultAskQuestionToolDescription, opts)
 
+	return DefineInterruptTool[QuestionInput, string](g, config.name, config.description, WithInterruptMetadataKey("question"))
 }
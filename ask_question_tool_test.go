@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefineAskQuestionTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	askQuestion := DefineAskQuestionTool(g)
+	askParent := DefineAskQuestionTool(g, WithToolName("askParent"), WithToolDescription("use this to ask the parent about the children"))
+
+	tests := []struct {
+		name        string
+		tool        ai.Tool
+		description string
+	}{
+		{name: "askQuestion", tool: askQuestion, description: defaultAskQuestionToolDescription},
+		{name: "askParent", tool: askParent, description: "use this to ask the parent about the children"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.name, tt.tool.Name())
+			assert.Equal(t, tt.description, tt.tool.Definition().Description)
+			registered := genkit.LookupTool(g, tt.name)
+			require.NotNil(t, registered)
+			assert.Equal(t, tt.description, registered.Definition().Description)
+
+			_, err := tt.tool.RunRaw(context.Background(), map[string]any{"question": "What ages?", "choices": []string{}})
+
+			interrupted, metadata := ai.IsToolInterruptError(err)
+			require.True(t, interrupted, "unexpected error: %v", err)
+			assert.Equal(t, tt.name, metadata["tool"])
+			assert.Equal(t, QuestionInput{Question: "What ages?", Choices: []string{}}, metadata["question"])
+		})
+	}
+}
+
+func TestRunAgent_RenamedQuestionTool(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(mockGen *MockGenerator, ask UserInteractionFunc) ResponseHandler
+	}{
+		{name: "interruption handler", handler: func(mockGen *MockGenerator, ask UserInteractionFunc) ResponseHandler {
+			return &InterruptionHandler{generator: mockGen, UserInteraction: ask, QuestionTool: "askParent"}
+		}},
+		{name: "conversation loop handler", handler: func(mockGen *MockGenerator, ask UserInteractionFunc) ResponseHandler {
+			handler := NewConversationLoopHandler(mockGen, "Is the conversation finished?", ask)
+			handler.SetQuestionTool("askParent")
+			return handler
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGen := NewMockGenerator(
+				[]*ai.ModelResponse{
+					createInterruptedResponse(createToolRequestPart("askParent", "What ages?", nil)),
+					createTextResponse("For ages 8 and 11...", "stop"),
+				},
+				map[string]ai.Tool{"askParent": createMockTool("askParent")},
+			)
+			var asked []string
+			handler := tt.handler(mockGen, func(_ context.Context, input QuestionInput) (string, error) {
+				asked = append(asked, input.Question)
+				return "8 and 11", nil
+			})
+
+			result, err := RunAgent(context.Background(), &Options{
+				generator:       mockGen,
+				tools:           []ai.Tool{mockGen.LookupTool("askParent"), createMockTool("findGifts")},
+				responseHandler: handler,
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, "For ages 8 and 11...", result)
+			assert.Equal(t, []string{"What ages?"}, asked)
+			require.Len(t, mockGen.capturedCalls, 2)
+			assert.Equal(t, []string{"askParent", "findGifts"}, capturedToolNames(mockGen.capturedCalls[0].Options))
+			assert.Equal(t, []string{"askParent", "findGifts"}, capturedToolNames(mockGen.capturedCalls[1].Options), "the continuation offers the tools of the run")
+		})
+	}
+}
+
+func TestDefineAskQuestionTool_Schema(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	schema := DefineAskQuestionTool(g).Definition().InputSchema
+
+	properties, ok := schema["properties"].(map[string]any)
+	require.True(t, ok, "unexpected schema: %v", schema)
+	for _, name := range []string{"allowFreeText", "required"} {
+		property, ok := properties[name].(map[string]any)
+		require.True(t, ok, "missing property %s", name)
+		assert.Equal(t, "boolean", property["type"])
+		assert.Equal(t, true, property["default"])
+	}
+	choices, ok := properties["choices"].(map[string]any)
+	require.True(t, ok, "missing property choices")
+	items, ok := choices["items"].(map[string]any)
+	require.True(t, ok, "unexpected choices: %v", choices)
+	oneOf, ok := items["oneOf"].([]any)
+	require.True(t, ok, "unexpected items: %v", items)
+	require.Len(t, oneOf, 2)
+	assert.Equal(t, "string", oneOf[0].(map[string]any)["type"])
+	assert.Equal(t, []any{"value"}, oneOf[1].(map[string]any)["required"])
+}
+
+func TestDefineAskQuestionTool_ChoiceObjects(t *testing.T) {
+	g := genkit.Init(context.Background())
+	tool := DefineAskQuestionTool(g)
+
+	_, err := tool.RunRaw(context.Background(), map[string]any{
+		"question": "What budget?",
+		"choices":  []any{map[string]any{"value": "budget_lt_25", "label": "Under $25"}, "Flexible"},
+	})
+
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, QuestionInput{
+		Question:     "What budget?",
+		Choices:      []string{"Under $25", "Flexible"},
+		ChoiceValues: []string{"budget_lt_25", "Flexible"},
+	}, metadata["question"])
+}
+
+func TestQuestionInput_Media(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []Media
+		err      string
+	}{
+		{
+			name:     "without media",
+			data:     `{"question": "Which bike?"}`,
+			expected: nil,
+		},
+		{
+			name: "URL and data URI",
+			data: `{"question": "Which bike?", "media": [{"url": "https://example.com/red.png", "alt": "a red bike"}, {"url": "data:image/png;base64,aGVsbG8="}]}`,
+			expected: []Media{
+				{URL: "https://example.com/red.png", Alt: "a red bike"},
+				{URL: "data:image/png;base64,aGVsbG8="},
+			},
+		},
+		{
+			name: "unsupported URL",
+			data: `{"question": "Which bike?", "media": [{"url": "https://example.com/red.png"}, {"url": "file:///tmp/blue.png"}]}`,
+			err:  `media 2: "file:///tmp/blue.png" is neither an http(s) URL nor a data URI`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var question QuestionInput
+			err := json.Unmarshal([]byte(tt.data), &question)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "Which bike?", question.Question)
+			assert.Equal(t, tt.expected, question.Media)
+		})
+	}
+}
+
+func TestRunAgent_ChoiceValues(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askQuestion", map[string]any{
+				"question": "What budget?",
+				"choices": []any{
+					map[string]any{"value": "budget_lt_25", "label": "Under $25"},
+					map[string]any{"value": "budget_gte_25", "label": "$25 or more"},
+				},
+			})),
+			createTextResponse("A LEGO set", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	var out bytes.Buffer
+	terminal := NewTerminalReader(ctx, strings.NewReader("2\n"), WithAnswerTimeout(time.Second), WithOutput(&out), WithWrapping(false))
+
+	_, err := RunAgent(ctx, &Options{generator: mockGen, responseHandler: &InterruptionHandler{generator: mockGen, Interactor: terminal}})
+
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "1) Under $25\n2) $25 or more\n", "the labels are shown")
+	assert.NotContains(t, out.String(), "budget_")
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, "budget_gte_25", parts[0].ToolResponse.Output, "the model gets the value")
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultPollInterval is how often waiting checks the store for an answer unless configured otherwise.
+const defaultPollInterval = time.Second
+
+// ErrAnswerPending is returned by AsyncInteractor.Ask when the answer will be submitted later.
+// InterruptionHandler turns it into ErrRunSuspended so that the run can be resumed.
+type ErrAnswerPending struct {
+	// ID correlates the question with the answer submitted through AsyncInteractor.SubmitAnswer.
+	ID string
+}
+
+func (e *ErrAnswerPending) Error() string {
+	return fmt.Sprintf("answer to question %s is pending", e.ID)
+}
+
+// ErrUnknownQuestion is returned by an AnswerStore for an ID it has no question for.
+var ErrUnknownQuestion = errors.New("unknown question")
+
+// ErrAlreadyAnswered is returned when submitting an answer to a question that already has one.
+var ErrAlreadyAnswered = errors.New("question is already answered")
+
+// AsyncQuestion is a question persisted by an AnswerStore together with its answer, once submitted.
+type AsyncQuestion struct {
+	ID       string
+	Input    QuestionInput
+	AskedAt  time.Time
+	Answered bool
+	Answer   string
+}
+
+// AnswerStore persists questions asked by an AsyncInteractor until they are answered.
+type AnswerStore interface {
+	// SaveQuestion stores a new question.
+	SaveQuestion(ctx context.Context, question AsyncQuestion) error
+	// SaveAnswer records the answer, failing with ErrUnknownQuestion or ErrAlreadyAnswered.
+	SaveAnswer(ctx context.Context, id, answer string) error
+	// Question returns the question with the ID, failing with ErrUnknownQuestion.
+	Question(ctx context.Context, id string) (AsyncQuestion, error)
+	// Unanswered lists the questions waiting for an answer, oldest first.
+	Unanswered(ctx context.Context) ([]AsyncQuestion, error)
+}
+
+// MemoryAnswerStore is an AnswerStore that keeps questions in memory.
+// It suits tests and processes that live until the answers arrive.
+type MemoryAnswerStore struct {
+	mu        sync.Mutex
+	questions map[string]AsyncQuestion
+}
+
+// NewMemoryAnswerStore creates an empty MemoryAnswerStore.
+func NewMemoryAnswerStore() *MemoryAnswerStore {
+	return &MemoryAnswerStore{questions: make(map[string]AsyncQuestion)}
+}
+
+// SaveQuestion stores a new question.
+func (m *MemoryAnswerStore) SaveQuestion(_ context.Context, question AsyncQuestion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.questions[question.ID] = question
+	return nil
+}
+
+// SaveAnswer records the answer to the question.
+func (m *MemoryAnswerStore) SaveAnswer(_ context.Context, id, answer string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question, ok := m.questions[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownQuestion, id)
+	}
+	if question.Answered {
+		return fmt.Errorf("%w: %s", ErrAlreadyAnswered, id)
+	}
+	question.Answered, question.Answer = true, answer
+	m.questions[id] = question
+	return nil
+}
+
+// Question returns the question with the ID.
+func (m *MemoryAnswerStore) Question(_ context.Context, id string) (AsyncQuestion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	question, ok := m.questions[id]
+	if !ok {
+		return AsyncQuestion{}, fmt.Errorf("%w: %s", ErrUnknownQuestion, id)
+	}
+	return question, nil
+}
+
+// Unanswered lists the questions waiting for an answer, oldest first.
+func (m *MemoryAnswerStore) Unanswered(_ context.Context) ([]AsyncQuestion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var unanswered []AsyncQuestion
+	for _, question := range m.questions {
+		if !question.Answered {
+			unanswered = append(unanswered, question)
+		}
+	}
+	slices.SortFunc(unanswered, func(a, b AsyncQuestion) int {
+		return a.AskedAt.Compare(b.AskedAt)
+	})
+	return unanswered, nil
+}
+
+// AsyncInteractor asks questions whose answers arrive much later, for example through a ticketing system.
+// Ask persists the question and fails with ErrAnswerPending, which suspends the run; the answer is
+// submitted with SubmitAnswer and the run is continued with InterruptionHandler.Resume.
+// With WithWaitForAnswers, Ask blocks until the answer is submitted instead.
+type AsyncInteractor struct {
+	store        AnswerStore
+	wait         bool
+	pollInterval time.Duration
+}
+
+// AsyncOption configures an AsyncInteractor.
+type AsyncOption func(*AsyncInteractor)
+
+// WithWaitForAnswers makes Ask wait for the answer instead of suspending the run,
+// checking the store every pollInterval.
+func WithWaitForAnswers(pollInterval time.Duration) AsyncOption {
+	return func(a *AsyncInteractor) {
+		a.wait = true
+		a.pollInterval = pollInterval
+	}
+}
+
+// NewAsyncInteractor creates an AsyncInteractor that keeps questions in the store.
+func NewAsyncInteractor(store AnswerStore, opts ...AsyncOption) *AsyncInteractor {
+	a := &AsyncInteractor{store: store, pollInterval: defaultPollInterval}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Ask persists the question under a new correlation ID and returns ErrAnswerPending carrying it.
+// A question asked again with its ID in the context, as InterruptionHandler.Resume does, returns the
+// submitted answer or ErrAnswerPending when there is none yet. It implements UserInteractionFunc.
+func (a *AsyncInteractor) Ask(ctx context.Context, input QuestionInput) (string, error) {
+	id, ok := CorrelationIDFromContext(ctx)
+	if !ok {
+		var err error
+		if id, err = newCorrelationID(); err != nil {
+			return "", err
+		}
+		question := AsyncQuestion{ID: id, Input: input, AskedAt: time.Now()}
+		if err := a.store.SaveQuestion(ctx, question); err != nil {
+			return "", fmt.Errorf("failed to save the question: %w", err)
+		}
+	}
+
+	if a.wait {
+		return a.Wait(ctx, id)
+	}
+	question, err := a.store.Question(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if !question.Answered {
+		return "", &ErrAnswerPending{ID: id}
+	}
+	return question.Answer, nil
+}
+
+// SubmitAnswer records the answer to the question with the correlation ID.
+// An empty answer selects the question's default.
+func (a *AsyncInteractor) SubmitAnswer(ctx context.Context, id, answer string) error {
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		question, err := a.store.Question(ctx, id)
+		if err != nil {
+			return err
+		}
+		if answer = question.Input.Default; answer == "" {
+			return errors.New("answer is empty")
+		}
+	}
+	return a.store.SaveAnswer(ctx, id, answer)
+}
+
+// Wait blocks until the question with the correlation ID is answered or the context is done.
+func (a *AsyncInteractor) Wait(ctx context.Context, id string) (string, error) {
+	ticker := time.NewTicker(a.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		question, err := a.store.Question(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		if question.Answered {
+			return question.Answer, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// newCorrelationID returns a random ID for a question.
+func newCorrelationID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate a correlation ID: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// askPending asks the question and returns the correlation ID of the pending answer.
+func askPending(t *testing.T, interactor *AsyncInteractor, input QuestionInput) string {
+	t.Helper()
+	_, err := interactor.Ask(context.Background(), input)
+	var pending *ErrAnswerPending
+	require.True(t, errors.As(err, &pending), "unexpected error: %v", err)
+	return pending.ID
+}
+
+func TestAsyncInteractor_SubmitBeforeWait(t *testing.T) {
+	ctx := context.Background()
+	interactor := NewAsyncInteractor(NewMemoryAnswerStore(), WithWaitForAnswers(time.Millisecond))
+	noWait := NewAsyncInteractor(interactor.store)
+
+	id := askPending(t, noWait, QuestionInput{Question: "Approve the budget?"})
+	require.NoError(t, interactor.SubmitAnswer(ctx, id, "yes"))
+
+	answer, err := interactor.Wait(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "yes", answer)
+	assert.ErrorIs(t, interactor.SubmitAnswer(ctx, id, "no"), ErrAlreadyAnswered)
+}
+
+func TestAsyncInteractor_WaitBeforeSubmit(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAnswerStore()
+	interactor := NewAsyncInteractor(store, WithWaitForAnswers(time.Millisecond))
+
+	done := make(chan string, 1)
+	go func() {
+		answer, err := interactor.Ask(ctx, QuestionInput{Question: "Approve the budget?", Default: "no"})
+		assert.NoError(t, err)
+		done <- answer
+	}()
+
+	var unanswered []AsyncQuestion
+	require.Eventually(t, func() bool {
+		var err error
+		unanswered, err = store.Unanswered(ctx)
+		return err == nil && len(unanswered) == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "Approve the budget?", unanswered[0].Input.Question)
+
+	// an empty answer selects the default
+	require.NoError(t, interactor.SubmitAnswer(ctx, unanswered[0].ID, " "))
+	assert.Equal(t, "no", <-done)
+}
+
+func TestAsyncInteractor_Errors(t *testing.T) {
+	ctx := context.Background()
+	interactor := NewAsyncInteractor(NewMemoryAnswerStore(), WithWaitForAnswers(time.Millisecond))
+
+	assert.ErrorIs(t, interactor.SubmitAnswer(ctx, "missing", "yes"), ErrUnknownQuestion)
+	_, err := interactor.Wait(ctx, "missing")
+	assert.ErrorIs(t, err, ErrUnknownQuestion)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = interactor.Ask(timeoutCtx, QuestionInput{Question: "Approve?"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestAsyncInteractor_SuspendAndResume(t *testing.T) {
+	ctx := context.Background()
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createToolRequestPart("askQuestion", "Approve the budget?", nil),
+			),
+			createTextResponse("Based on the approved budget...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	interactor := NewAsyncInteractor(NewMemoryAnswerStore())
+	handler := &InterruptionHandler{generator: mockGen, UserInteraction: interactor.Ask}
+
+	_, err := RunAgent(ctx, &Options{generator: mockGen, responseHandler: handler})
+	var suspended *ErrRunSuspended
+	require.True(t, errors.As(err, &suspended), "unexpected error: %v", err)
+	require.Len(t, suspended.Pending, 2)
+	assert.Equal(t, 1, mockGen.callIndex, "nothing is generated while answers are pending")
+
+	// resuming with a missing answer suspends the run again
+	require.NoError(t, interactor.SubmitAnswer(ctx, suspended.Pending[0], "Both"))
+	_, err = handler.Resume(ctx, suspended)
+	require.True(t, errors.As(err, &suspended))
+	assert.Equal(t, map[int]string{0: "Both"}, suspended.Answers)
+	require.Len(t, suspended.Pending, 1)
+
+	require.NoError(t, interactor.SubmitAnswer(ctx, suspended.Pending[1], "yes"))
+	response, err := handler.Resume(ctx, suspended)
+	require.NoError(t, err)
+	assert.Equal(t, "Based on the approved budget...", response.Text())
+	assert.Equal(t, 2, mockGen.callIndex)
+}
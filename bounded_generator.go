@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/firebase/genkit/go/ai"
+	"golang.org/x/sync/semaphore"
+)
+
+// BoundedGenerator is a Generator capping how many model calls of another one are in flight at once,
+// to stay under the concurrency limit of the provider. A single BoundedGenerator is safe to share
+// between concurrent sessions, which then share its limit.
+type BoundedGenerator struct {
+	inner    Generator
+	slots    *semaphore.Weighted
+	inFlight atomic.Int64
+	queued   atomic.Int64
+}
+
+// NewBoundedGenerator returns a Generator calling inner with at most maxInFlight calls at once; a
+// maxInFlight below 1 is taken as 1. Generate, GenerateStream, GenerateBool and GenerateJSON wait for
+// a call to finish when maxInFlight are in flight, or until ctx is done. A streamed call is in flight
+// until its stream ends.
+func NewBoundedGenerator(inner Generator, maxInFlight int) *BoundedGenerator {
+	return &BoundedGenerator{inner: inner, slots: semaphore.NewWeighted(int64(max(maxInFlight, 1)))}
+}
+
+// InFlight returns how many calls are calling the inner generator.
+func (g *BoundedGenerator) InFlight() int {
+	return int(g.inFlight.Load())
+}
+
+// Queued returns how many calls are waiting for another call to finish.
+func (g *BoundedGenerator) Queued() int {
+	return int(g.queued.Load())
+}
+
+// Generate waits for a free slot and calls the inner Generate.
+func (g *BoundedGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	if err := g.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer g.release()
+	return g.inner.Generate(ctx, opts...)
+}
+
+// GenerateStream waits for a free slot and calls the inner GenerateStream.
+func (g *BoundedGenerator) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	if err := g.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer g.release()
+	return g.inner.GenerateStream(ctx, cb, opts...)
+}
+
+// GenerateBool waits for a free slot and calls the inner GenerateBool.
+func (g *BoundedGenerator) GenerateBool(ctx context.Context, prompt string, history []*ai.Message) (bool, error) {
+	if err := g.acquire(ctx); err != nil {
+		return false, err
+	}
+	defer g.release()
+	return g.inner.GenerateBool(ctx, prompt, history)
+}
+
+// GenerateChecklist waits for a free slot and calls the inner GenerateChecklist.
+func (g *BoundedGenerator) GenerateChecklist(ctx context.Context, questions []string, history []*ai.Message) (map[string]bool, error) {
+	if err := g.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer g.release()
+	return g.inner.GenerateChecklist(ctx, questions, history)
+}
+
+// GenerateJSON waits for a free slot and calls the inner GenerateJSON.
+func (g *BoundedGenerator) GenerateJSON(ctx context.Context, system string, history []*ai.Message, out any) error {
+	if err := g.acquire(ctx); err != nil {
+		return err
+	}
+	defer g.release()
+	return g.inner.GenerateJSON(ctx, system, history, out)
+}
+
+// LookupTool looks up the tool with the inner generator, without waiting for a slot.
+func (g *BoundedGenerator) LookupTool(name string) ai.Tool {
+	return g.inner.LookupTool(name)
+}
+
+// CountTokens counts the tokens with the inner generator, without waiting for a slot.
+func (g *BoundedGenerator) CountTokens(ctx context.Context, messages []*ai.Message) (TokenCount, error) {
+	return g.inner.CountTokens(ctx, messages)
+}
+
+// Embed embeds the text with the inner generator, without waiting for a slot.
+func (g *BoundedGenerator) Embed(ctx context.Context, text string) ([]float32, error) {
+	return g.inner.Embed(ctx, text)
+}
+
+// Ping pings the inner generator, without waiting for a slot.
+func (g *BoundedGenerator) Ping(ctx context.Context) error {
+	return ping(ctx, g.inner)
+}
+
+// acquire waits for a free slot, or until ctx is done.
+func (g *BoundedGenerator) acquire(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !g.slots.TryAcquire(1) {
+		g.queued.Add(1)
+		err := g.slots.Acquire(ctx, 1)
+		g.queued.Add(-1)
+		if err != nil {
+			return err
+		}
+	}
+	g.inFlight.Add(1)
+	return nil
+}
+
+// release frees the slot of a finished call.
+func (g *BoundedGenerator) release() {
+	g.inFlight.Add(-1)
+	g.slots.Release(1)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowGenerator is a Generator whose calls take a while, tracking how many are in flight at once. Its
+// calls wait for unblock when it's set.
+type slowGenerator struct {
+	delay    time.Duration
+	unblock  chan struct{}
+	inFlight atomic.Int64
+	peak     atomic.Int64
+	calls    atomic.Int64
+}
+
+func (s *slowGenerator) call(ctx context.Context) error {
+	s.calls.Add(1)
+	inFlight := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		peak := s.peak.Load()
+		if inFlight <= peak || s.peak.CompareAndSwap(peak, inFlight) {
+			break
+		}
+	}
+	if s.unblock != nil {
+		select {
+		case <-s.unblock:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return sleepContext(ctx, s.delay)
+}
+
+func (s *slowGenerator) Generate(ctx context.Context, _ ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	if err := s.call(ctx); err != nil {
+		return nil, err
+	}
+	return createTextResponse("A LEGO set", "stop"), nil
+}
+
+func (s *slowGenerator) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, _ ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	if err := s.call(ctx); err != nil {
+		return nil, err
+	}
+	if err := cb(&ai.ModelResponseChunk{Role: ai.RoleModel, Content: []*ai.Part{ai.NewTextPart("A LEGO set")}}); err != nil {
+		return nil, err
+	}
+	return createTextResponse("A LEGO set", "stop"), nil
+}
+
+func (s *slowGenerator) GenerateBool(ctx context.Context, _ string, _ []*ai.Message) (bool, error) {
+	return true, s.call(ctx)
+}
+
+func (s *slowGenerator) GenerateChecklist(ctx context.Context, questions []string, _ []*ai.Message) (map[string]bool, error) {
+	return map[string]bool{}, s.call(ctx)
+}
+
+func (s *slowGenerator) GenerateJSON(ctx context.Context, _ string, _ []*ai.Message, _ any) error {
+	return s.call(ctx)
+}
+
+func (s *slowGenerator) CountTokens(_ context.Context, messages []*ai.Message) (TokenCount, error) {
+	return estimateTokens(messages), nil
+}
+
+func (s *slowGenerator) Embed(context.Context, string) ([]float32, error) {
+	return []float32{1, 0}, nil
+}
+
+func (s *slowGenerator) LookupTool(name string) ai.Tool {
+	return createMockTool(name)
+}
+
+func TestBoundedGenerator_Ceiling(t *testing.T) {
+	inner := &slowGenerator{delay: 2 * time.Millisecond}
+	generator := NewBoundedGenerator(inner, 3)
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			switch i % 4 {
+			case 0:
+				_, err = generator.Generate(context.Background())
+			case 1:
+				_, err = generator.GenerateBool(context.Background(), "Is it finished?", nil)
+			case 2:
+				var count int
+				err = generator.GenerateJSON(context.Background(), "How many gifts?", nil, &count)
+			default:
+				_, err = generator.GenerateStream(context.Background(), func(*ai.ModelResponseChunk) error { return nil })
+			}
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(50), inner.calls.Load())
+	assert.LessOrEqual(t, inner.peak.Load(), int64(3))
+	assert.Equal(t, 0, generator.InFlight())
+	assert.Equal(t, 0, generator.Queued())
+}
+
+func TestBoundedGenerator_Counts(t *testing.T) {
+	inner := &slowGenerator{unblock: make(chan struct{})}
+	generator := NewBoundedGenerator(inner, 2)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := generator.Generate(context.Background())
+			assert.NoError(t, err)
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		return generator.InFlight() == 2 && generator.Queued() == 3
+	}, time.Second, time.Millisecond)
+	assert.NotNil(t, generator.LookupTool("askQuestion"), "looking up a tool doesn't wait for a slot")
+	close(inner.unblock)
+	wg.Wait()
+
+	assert.Equal(t, int64(2), inner.peak.Load())
+	assert.Equal(t, 0, generator.InFlight())
+	assert.Equal(t, 0, generator.Queued())
+}
+
+func TestBoundedGenerator_ContextDoneWhileWaiting(t *testing.T) {
+	inner := &slowGenerator{unblock: make(chan struct{})}
+	generator := NewBoundedGenerator(inner, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := generator.Generate(context.Background())
+		assert.NoError(t, err)
+	}()
+	require.Eventually(t, func() bool { return generator.InFlight() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := generator.GenerateBool(ctx, "Is it finished?", nil)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, generator.Queued())
+	close(inner.unblock)
+	<-done
+	assert.Equal(t, int64(1), inner.calls.Load(), "the cancelled call doesn't reach the inner generator")
+
+	_, err = generator.Generate(context.Background())
+	assert.NoError(t, err, "the slot of the finished call is free")
+}
+
+func TestNewBoundedGenerator_AtLeastOne(t *testing.T) {
+	generator := NewBoundedGenerator(&slowGenerator{}, 0)
+
+	_, err := generator.Generate(context.Background())
+
+	assert.NoError(t, err)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// BroadcastInteractor asks every question through several interactors at once, for example a Slack DM,
+// an SMS and the terminal for on-call approvals. The first answer wins and the other asks are cancelled.
+type BroadcastInteractor struct {
+	interactors []Interactor
+}
+
+// NewBroadcastInteractor creates a BroadcastInteractor asking through the interactors.
+func NewBroadcastInteractor(interactors ...Interactor) *BroadcastInteractor {
+	return &BroadcastInteractor{interactors: interactors}
+}
+
+// broadcastResult is the outcome of asking through one interactor.
+type broadcastResult struct {
+	answer Answer
+	err    error
+}
+
+// Ask asks the question through every interactor and returns the first answer, cancelling the other asks
+// through their context. When all of them fail, the errors are joined. Answers arriving after the first
+// one are discarded.
+func (b *BroadcastInteractor) Ask(ctx context.Context, input QuestionInput) (Answer, error) {
+	if len(b.interactors) == 0 {
+		return Answer{}, errors.New("no interactors to broadcast the question to")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffered so that the asks finishing after the first answer never block
+	results := make(chan broadcastResult, len(b.interactors))
+	for _, interactor := range b.interactors {
+		go func() {
+			answer, err := interactor.Ask(ctx, input)
+			results <- broadcastResult{answer: answer, err: err}
+		}()
+	}
+
+	var errs []error
+	for range b.interactors {
+		result := <-results
+		if result.err == nil {
+			return result.answer, nil
+		}
+		errs = append(errs, result.err)
+	}
+	return Answer{}, fmt.Errorf("all interactors failed: %w", errors.Join(errs...))
+}
+
+// Notify sends the message through every interactor.
+func (b *BroadcastInteractor) Notify(ctx context.Context, message string) error {
+	var errs []error
+	for _, interactor := range b.interactors {
+		errs = append(errs, interactor.Notify(ctx, message))
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every interactor.
+func (b *BroadcastInteractor) Close() error {
+	var errs []error
+	for _, interactor := range b.interactors {
+		errs = append(errs, interactor.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// delayedInteractor answers after a delay unless its context is done first.
+type delayedInteractor struct {
+	delay  time.Duration
+	answer string
+	err    error
+	// ignoreCancel makes it answer after the delay even when its context is done.
+	ignoreCancel bool
+	// done receives the error it returned once Ask finishes.
+	done chan error
+}
+
+func newDelayedInteractor(delay time.Duration, answer string, err error) *delayedInteractor {
+	return &delayedInteractor{delay: delay, answer: answer, err: err, done: make(chan error, 1)}
+}
+
+func (d *delayedInteractor) Ask(ctx context.Context, _ QuestionInput) (answer Answer, err error) {
+	defer func() { d.done <- err }()
+
+	timer := time.NewTimer(d.delay)
+	defer timer.Stop()
+	if d.ignoreCancel {
+		<-timer.C
+		return Answer{Value: d.answer}, d.err
+	}
+	select {
+	case <-timer.C:
+		return Answer{Value: d.answer}, d.err
+	case <-ctx.Done():
+		return Answer{}, ctx.Err()
+	}
+}
+
+func (d *delayedInteractor) Notify(context.Context, string) error { return nil }
+
+func (d *delayedInteractor) Close() error { return nil }
+
+func TestBroadcastInteractor_FirstAnswerWins(t *testing.T) {
+	slack := newDelayedInteractor(time.Hour, "from slack", nil)
+	sms := newDelayedInteractor(10*time.Millisecond, "from sms", nil)
+	terminal := newDelayedInteractor(time.Millisecond, "", errors.New("terminal is closed"))
+
+	answer, err := NewBroadcastInteractor(slack, sms, terminal).Ask(context.Background(), QuestionInput{Question: "Approve?"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "from sms", answer.Value)
+	assert.ErrorIs(t, <-slack.done, context.Canceled, "the slower ask is cancelled")
+}
+
+func TestBroadcastInteractor_AllFail(t *testing.T) {
+	closed := errors.New("terminal is closed")
+	slack := newDelayedInteractor(5*time.Millisecond, "", &ErrAnswerPending{ID: "42"})
+	terminal := newDelayedInteractor(time.Millisecond, "", closed)
+
+	_, err := NewBroadcastInteractor(slack, terminal).Ask(context.Background(), QuestionInput{Question: "Approve?"})
+
+	assert.ErrorIs(t, err, closed)
+	var pending *ErrAnswerPending
+	assert.True(t, errors.As(err, &pending))
+}
+
+func TestBroadcastInteractor_LateAnswersDiscarded(t *testing.T) {
+	for range 50 {
+		stubborn := newDelayedInteractor(2*time.Millisecond, "late", nil)
+		stubborn.ignoreCancel = true
+		fast := newDelayedInteractor(0, "fast", nil)
+
+		answer, err := NewBroadcastInteractor(fast, stubborn).Ask(context.Background(), QuestionInput{Question: "Approve?"})
+
+		require.NoError(t, err)
+		assert.Contains(t, []string{"fast", "late"}, answer.Value)
+		// the late ask still finishes instead of blocking on a channel nobody reads
+		select {
+		case <-stubborn.done:
+		case <-time.After(time.Second):
+			t.Fatal("the late ask never finished")
+		}
+	}
+}
+
+func TestBroadcastInteractor_Cancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	slack, sms := newDelayedInteractor(time.Hour, "", nil), newDelayedInteractor(time.Hour, "", nil)
+
+	_, err := NewBroadcastInteractor(slack, sms).Ask(ctx, QuestionInput{Question: "Approve?"})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestBroadcastInteractor_NoInteractors(t *testing.T) {
+	_, err := NewBroadcastInteractor().Ask(context.Background(), QuestionInput{Question: "Approve?"})
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineBudgetTool.
+const (
+	defaultBudgetToolName        = "askBudget"
+	defaultBudgetToolDescription = "use this to ask the user for a budget; the response holds the amount, the currency, whether it is approximate, and the range when the user gave one, which your suggestions must stay within"
+)
+
+// BudgetInput contains a question answered with a budget.
+type BudgetInput struct {
+	Question        string `json:"question" jsonschema:"description=a question such as 'What is your budget per child?'"`
+	DefaultCurrency string `json:"defaultCurrency,omitempty" jsonschema:"description=the ISO code of the currency assumed when the user names none, such as 'USD'"`
+}
+
+// Budget is the budget the budget tool responds with. Amount is the middle of a range, or its only
+// bound when the user gave a maximum or a minimum.
+type Budget struct {
+	Amount      float64  `json:"amount"`
+	Currency    string   `json:"currency,omitempty"`
+	Approximate bool     `json:"approximate"`
+	RangeMin    *float64 `json:"rangeMin,omitempty"`
+	RangeMax    *float64 `json:"rangeMax,omitempty"`
+}
+
+// currencyPatterns map the symbols, codes and names of currencies to their ISO codes. Symbols with a
+// prefix come before the plain ones they contain.
+var currencyPatterns = []struct {
+	pattern *regexp.Regexp
+	code    string
+}{
+	{regexp.MustCompile(`c\$|\bcad\b|canadian dollars?`), "CAD"},
+	{regexp.MustCompile(`a\$|\baud\b|australian dollars?`), "AUD"},
+	{regexp.MustCompile(`\$|\busd\b|\bdollars?\b|\bbucks\b`), "USD"},
+	{regexp.MustCompile(`€|\beur\b|\beuros?\b`), "EUR"},
+	{regexp.MustCompile(`£|\bgbp\b|\bpounds?\b|\bquid\b`), "GBP"},
+	{regexp.MustCompile(`¥|\bjpy\b|\byen\b`), "JPY"},
+	{regexp.MustCompile(`\bchf\b|\bswiss francs?\b`), "CHF"},
+}
+
+var (
+	// budgetAmount matches amounts such as 50, 1,200, 49.99 and 1.5k.
+	budgetAmount = regexp.MustCompile(`(\d[\d,]*(?:\.\d+)?)\s*(k\b)?`)
+	// approximateBudget matches the words making an amount approximate.
+	approximateBudget = regexp.MustCompile(`~|\babout\b|\baround\b|\bapprox(imately)?\b|\broughly\b|\bcirca\b|\d\s*-?ish\b|\bor so\b`)
+	// maxBudget and minBudget match the words making an amount an upper or a lower bound.
+	maxBudget = regexp.MustCompile(`<|\bunder\b|\bbelow\b|\bless than\b|\bup to\b|\bat most\b|\bno more than\b|\bmax(imum)?\b`)
+	minBudget = regexp.MustCompile(`>|\bover\b|\babove\b|\bmore than\b|\bat least\b|\bmin(imum)?\b|\bfrom\b`)
+)
+
+// DefineBudgetTool defines the budget tool, named "askBudget" by default, in the Genkit instance and
+// returns it. Its interrupts are answered by HandleBudget, which must be registered for the tool name
+// with RegisterToolHandler.
+func DefineBudgetTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultBudgetToolName, defaultBudgetToolDescription, opts)
+
+	return DefineInterruptTool[BudgetInput, Budget](g, config.name, config.description, WithInterruptMetadataKey("budget"))
+}
+
+// HandleBudget is the ToolHandler of the budget tool. The answer may hold a currency symbol, code or
+// name, a range such as 40-60, and words such as about or under; it is asked again while it holds no
+// amount. It responds with a Budget, in the default currency when the answer names none.
+func HandleBudget(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var request BudgetInput
+	if err := decodeToolInput(input, &request); err != nil {
+		return ToolResult{}, err
+	}
+	if request.Question == "" {
+		return ToolResult{}, errors.New("budget tool called without a question")
+	}
+
+	question := QuestionInput{Question: request.Question + " (such as $50, 50 euro or 40-60)"}
+	budget, err := askParsed(ctx, interactor, question, request.parse)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	return ToolResult{Output: budget}, nil
+}
+
+// parse converts the answer into a Budget.
+func (b BudgetInput) parse(answer string) (any, error) {
+	budget, err := parseBudget(answer)
+	if err != nil {
+		return nil, err
+	}
+	if budget.Currency == "" {
+		budget.Currency = strings.ToUpper(b.DefaultCurrency)
+	}
+	return budget, nil
+}
+
+// parseBudget reads the amount or range, the currency and the approximation words of an answer.
+func parseBudget(answer string) (Budget, error) {
+	text := strings.ToLower(strings.TrimSpace(answer))
+
+	var budget Budget
+	for _, currency := range currencyPatterns {
+		if !currency.pattern.MatchString(text) {
+			continue
+		}
+		if budget.Currency != "" && budget.Currency != currency.code {
+			return Budget{}, fmt.Errorf("expected a single currency, not both %s and %s", budget.Currency, currency.code)
+		}
+		budget.Currency = currency.code
+		// the symbols are removed so that "c$" isn't read as dollars too
+		text = currency.pattern.ReplaceAllString(text, " ")
+	}
+	budget.Approximate = approximateBudget.MatchString(text)
+
+	var amounts []float64
+	for _, match := range budgetAmount.FindAllStringSubmatch(text, -1) {
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(match[1], ",", ""), 64)
+		if err != nil {
+			return Budget{}, fmt.Errorf("%q is not an amount", match[1])
+		}
+		if match[2] != "" {
+			amount *= 1000
+		}
+		amounts = append(amounts, amount)
+	}
+
+	switch {
+	case len(amounts) == 0:
+		return Budget{}, errors.New("expected an amount, such as $50, 50 euro or 40-60")
+	case len(amounts) > 2:
+		return Budget{}, errors.New("expected a single amount or a range, such as 40-60")
+	case len(amounts) == 2:
+		low, high := min(amounts[0], amounts[1]), max(amounts[0], amounts[1])
+		budget.RangeMin, budget.RangeMax = &low, &high
+		budget.Amount = (low + high) / 2
+	case maxBudget.MatchString(text):
+		budget.Amount = amounts[0]
+		budget.RangeMax = &amounts[0]
+	case minBudget.MatchString(text):
+		budget.Amount = amounts[0]
+		budget.RangeMin = &amounts[0]
+	default:
+		budget.Amount = amounts[0]
+	}
+	return budget, nil
+}
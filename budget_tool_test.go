@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// amount returns a pointer to the range bound.
+func amount(f float64) *float64 {
+	return &f
+}
+
+func TestParseBudget(t *testing.T) {
+	tests := []struct {
+		answer   string
+		expected Budget
+		err      string
+	}{
+		{answer: "$50", expected: Budget{Amount: 50, Currency: "USD"}},
+		{answer: "50 euro", expected: Budget{Amount: 50, Currency: "EUR"}},
+		{answer: "€49.99", expected: Budget{Amount: 49.99, Currency: "EUR"}},
+		{answer: "£1,200", expected: Budget{Amount: 1200, Currency: "GBP"}},
+		{answer: "1.5k USD", expected: Budget{Amount: 1500, Currency: "USD"}},
+		{answer: "C$80", expected: Budget{Amount: 80, Currency: "CAD"}},
+		{answer: "100 bucks", expected: Budget{Amount: 100, Currency: "USD"}},
+		{answer: "50", expected: Budget{Amount: 50}},
+		{answer: "around 40-60", expected: Budget{Amount: 50, Approximate: true, RangeMin: amount(40), RangeMax: amount(60)}},
+		{answer: "between 40 and 60 dollars", expected: Budget{Amount: 50, Currency: "USD", RangeMin: amount(40), RangeMax: amount(60)}},
+		{answer: "€60 to €40", expected: Budget{Amount: 50, Currency: "EUR", RangeMin: amount(40), RangeMax: amount(60)}},
+		{answer: "about 30 pounds", expected: Budget{Amount: 30, Currency: "GBP", Approximate: true}},
+		{answer: "50ish", expected: Budget{Amount: 50, Approximate: true}},
+		{answer: "~25 EUR", expected: Budget{Amount: 25, Currency: "EUR", Approximate: true}},
+		{answer: "under $100", expected: Budget{Amount: 100, Currency: "USD", RangeMax: amount(100)}},
+		{answer: "at least 20 euros", expected: Budget{Amount: 20, Currency: "EUR", RangeMin: amount(20)}},
+		{answer: "no idea", err: "expected an amount, such as $50, 50 euro or 40-60"},
+		{answer: "$50 or €40", err: "expected a single currency, not both USD and EUR"},
+		{answer: "10, 20 or 30", err: "expected a single amount or a range, such as 40-60"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.answer, func(t *testing.T) {
+			budget, err := parseBudget(tt.answer)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, budget)
+		})
+	}
+}
+
+func TestHandleBudget(t *testing.T) {
+	interactor := &sequenceInteractor{answers: []Answer{{Value: "not much"}, {Value: "around 40-60"}}}
+
+	result, err := HandleBudget(context.Background(), interactor, map[string]any{"question": "What is your budget per child?", "defaultCurrency": "usd"})
+
+	require.NoError(t, err)
+	assert.Equal(t, Budget{Amount: 50, Currency: "USD", Approximate: true, RangeMin: amount(40), RangeMax: amount(60)}, result.Output)
+	assert.Equal(t, []string{"Invalid answer: expected an amount, such as $50, 50 euro or 40-60"}, interactor.notifications)
+}
+
+func TestHandleBudget_ThroughToolHandler(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askBudget", map[string]any{"question": "What is your budget per child?"})),
+			createTextResponse("A LEGO set for about $45...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askBudget": createMockTool("askBudget")},
+	)
+	handler := &InterruptionHandler{generator: mockGen, Interactor: &sequenceInteractor{answers: []Answer{{Value: "under $50"}}}}
+	handler.RegisterToolHandler("askBudget", HandleBudget)
+
+	_, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, Budget{Amount: 50, Currency: "USD", RangeMax: amount(50)}, parts[0].ToolResponse.Output)
+}
+
+func TestBudget_JSON(t *testing.T) {
+	budget := Budget{Amount: 50, Currency: "EUR", Approximate: true, RangeMin: amount(40), RangeMax: amount(60)}
+
+	data, err := json.Marshal(budget)
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount": 50, "currency": "EUR", "approximate": true, "rangeMin": 40, "rangeMax": 60}`, string(data))
+}
+
+func TestDefineBudgetTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	budget := DefineBudgetTool(g)
+	_, err := budget.RunRaw(context.Background(), map[string]any{"question": "What is your budget?", "defaultCurrency": "EUR"})
+
+	assert.Equal(t, "askBudget", budget.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, BudgetInput{Question: "What is your budget?", DefaultCurrency: "EUR"}, metadata["budget"])
+}
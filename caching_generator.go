@@ -0,0 +1,300 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// Cache keeps model responses by the fingerprint of their request.
+type Cache interface {
+	// Get returns the response stored under the key, and false when there is none.
+	Get(ctx context.Context, key string) (*ai.ModelResponse, bool, error)
+	Put(ctx context.Context, key string, resp *ai.ModelResponse) error
+}
+
+// CachingGenerator is a Generator answering the model requests it has seen before from a cache instead
+// of calling the model again, for example while iterating on a prompt by re-running the same
+// conversation. The responses are cached as the model returned them, interrupts included, so that a
+// cached conversation goes the same way as the first one.
+type CachingGenerator struct {
+	inner Generator
+	cache Cache
+}
+
+// NewCachingGenerator returns a Generator calling inner and caching the model responses in cache. The
+// responses are cached by GenkitGenerator, through which inner has to call the model, by a fingerprint
+// of the model and of the request it gets: its messages, system prompt, tools, output format and
+// config, safety settings included, so that generators calling different models can share a cache.
+// Cached responses report no usage, since they cost no tokens, and are streamed as a single chunk.
+func NewCachingGenerator(inner Generator, cache Cache) *CachingGenerator {
+	return &CachingGenerator{inner: inner, cache: cache}
+}
+
+// Generate calls the inner Generate, answering from the cache.
+func (c *CachingGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	return c.inner.Generate(withModelMiddleware(ctx, c.middleware), opts...)
+}
+
+// GenerateStream calls the inner GenerateStream, answering from the cache.
+func (c *CachingGenerator) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	return c.inner.GenerateStream(withModelMiddleware(ctx, c.middleware), cb, opts...)
+}
+
+// GenerateBool calls the inner GenerateBool, answering from the cache.
+func (c *CachingGenerator) GenerateBool(ctx context.Context, prompt string, history []*ai.Message) (bool, error) {
+	return c.inner.GenerateBool(withModelMiddleware(ctx, c.middleware), prompt, history)
+}
+
+// GenerateChecklist calls the inner GenerateChecklist, answering from the cache.
+func (c *CachingGenerator) GenerateChecklist(ctx context.Context, questions []string, history []*ai.Message) (map[string]bool, error) {
+	return c.inner.GenerateChecklist(withModelMiddleware(ctx, c.middleware), questions, history)
+}
+
+// GenerateJSON calls the inner GenerateJSON, answering from the cache.
+func (c *CachingGenerator) GenerateJSON(ctx context.Context, system string, history []*ai.Message, out any) error {
+	return c.inner.GenerateJSON(withModelMiddleware(ctx, c.middleware), system, history, out)
+}
+
+// LookupTool looks up the tool with the inner generator.
+func (c *CachingGenerator) LookupTool(name string) ai.Tool {
+	return c.inner.LookupTool(name)
+}
+
+// CountTokens counts the tokens with the inner generator, without caching the count.
+func (c *CachingGenerator) CountTokens(ctx context.Context, messages []*ai.Message) (TokenCount, error) {
+	return c.inner.CountTokens(ctx, messages)
+}
+
+// Embed embeds the text with the inner generator, without caching the embedding.
+func (c *CachingGenerator) Embed(ctx context.Context, text string) ([]float32, error) {
+	return c.inner.Embed(ctx, text)
+}
+
+// Ping pings the inner generator, bypassing the cache.
+func (c *CachingGenerator) Ping(ctx context.Context) error {
+	return ping(ctx, c.inner)
+}
+
+// middleware answers the model requests from the cache, and caches the responses of the others. A cache
+// that fails is logged and bypassed rather than failing the call.
+func (c *CachingGenerator) middleware(next ai.ModelFunc) ai.ModelFunc {
+	return func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+		key, err := fingerprint(calledModel(ctx), req)
+		if err != nil {
+			log.Printf("failed to fingerprint the model request, calling the model: %v", err)
+			return next(ctx, req, cb)
+		}
+		cached, ok, err := c.cache.Get(ctx, key)
+		if err != nil {
+			log.Printf("failed to read the model response cache: %v", err)
+		}
+		if ok {
+			if resp, err := cloneResponse(cached); err != nil {
+				log.Printf("failed to read the cached model response: %v", err)
+			} else {
+				return replayResponse(ctx, req, resp, cb)
+			}
+		}
+
+		resp, err := next(ctx, req, cb)
+		if err != nil {
+			return nil, err
+		}
+		if stored, err := cloneResponse(resp); err != nil {
+			log.Printf("failed to cache the model response: %v", err)
+		} else if err := c.cache.Put(ctx, key, stored); err != nil {
+			log.Printf("failed to cache the model response: %v", err)
+		}
+		return resp, nil
+	}
+}
+
+// replayResponse returns the cached response to the request, streaming its message to cb when set.
+func replayResponse(ctx context.Context, req *ai.ModelRequest, resp *ai.ModelResponse, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+	resp.Request = req
+	resp.Usage = nil
+	if cb != nil && resp.Message != nil {
+		if err := cb(ctx, &ai.ModelResponseChunk{Role: resp.Message.Role, Content: resp.Message.Content}); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// fingerprint returns a key identifying the request to the model: the hash of the JSON encoding of the
+// model and the request, which holds the messages, including the system prompt, the tool definitions,
+// the output format and the config.
+func fingerprint(model string, req *ai.ModelRequest) (string, error) {
+	return digest(struct {
+		Model   string           `json:"model"`
+		Request *ai.ModelRequest `json:"request"`
+	}{model, req})
+}
+
+// digest returns the hex-encoded SHA-256 hash of the JSON encoding of v.
+func digest(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// cloneResponse returns a deep copy of what is cached of the response: its message, finish reason and
+// usage. The request, which is rebuilt on every call, and the provider's raw data are left out.
+func cloneResponse(resp *ai.ModelResponse) (*ai.ModelResponse, error) {
+	data, err := json.Marshal(&ai.ModelResponse{
+		FinishReason:  resp.FinishReason,
+		FinishMessage: resp.FinishMessage,
+		Message:       resp.Message,
+		Usage:         resp.Usage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var clone ai.ModelResponse
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+// defaultMemoryCacheSize is the number of responses a MemoryCache keeps by default.
+const defaultMemoryCacheSize = 256
+
+// MemoryCache is a Cache keeping the responses used last in memory.
+type MemoryCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key  string
+	resp *ai.ModelResponse
+}
+
+// NewMemoryCache returns a Cache keeping up to size responses, dropping the one used least recently
+// when full; zero or less means 256.
+func NewMemoryCache(size int) *MemoryCache {
+	if size <= 0 {
+		size = defaultMemoryCacheSize
+	}
+	return &MemoryCache{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// Get returns the response stored under the key.
+func (m *MemoryCache) Get(_ context.Context, key string) (*ai.ModelResponse, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	element, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	m.order.MoveToFront(element)
+	return element.Value.(*memoryCacheEntry).resp, true, nil
+}
+
+// Put stores the response under the key.
+func (m *MemoryCache) Put(_ context.Context, key string, resp *ai.ModelResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if element, ok := m.entries[key]; ok {
+		element.Value.(*memoryCacheEntry).resp = resp
+		m.order.MoveToFront(element)
+		return nil
+	}
+	m.entries[key] = m.order.PushFront(&memoryCacheEntry{key: key, resp: resp})
+	if m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
+
+// DiskCache is a Cache keeping the responses as JSON files in a directory, so that they outlive the
+// process.
+type DiskCache struct {
+	dir string
+	ttl time.Duration
+	// now is replaced in tests to expire the responses.
+	now func() time.Time
+}
+
+// NewDiskCache returns a Cache keeping the responses in dir, which is created when missing, for ttl;
+// zero or less keeps them until they are deleted.
+func NewDiskCache(dir string, ttl time.Duration) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the cache directory: %w", err)
+	}
+	return &DiskCache{dir: dir, ttl: ttl, now: time.Now}, nil
+}
+
+// Get returns the response stored under the key, and deletes it when it's expired.
+func (d *DiskCache) Get(_ context.Context, key string) (*ai.ModelResponse, bool, error) {
+	path := d.path(key)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if d.ttl > 0 && d.now().Sub(info.ModTime()) > d.ttl {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, false, err
+		}
+		return nil, false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	var resp ai.ModelResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, false, fmt.Errorf("cached response %s: %w", key, err)
+	}
+	return &resp, true, nil
+}
+
+// Put stores the response under the key. The file is written aside and renamed, so that concurrent runs
+// never read a partly written response.
+func (d *DiskCache) Put(_ context.Context, key string, resp *ai.ModelResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(d.dir, key+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), d.path(key))
+}
+
+func (d *DiskCache) path(key string) string {
+	return filepath.Join(d.dir, key+".json")
+}
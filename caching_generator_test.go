@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingModel defines the default model of a new Genkit instance answering with respond, and returns
+// the instance with the number of times the model was called.
+func countingModel(respond func(req *ai.ModelRequest) *ai.ModelResponse) (*genkit.Genkit, *atomic.Int64) {
+	g := genkit.Init(context.Background(), genkit.WithDefaultModel("test/counting"))
+	var calls atomic.Int64
+	genkit.DefineModel(g, "test/counting", &ai.ModelOptions{Supports: &ai.ModelSupports{Multiturn: true, SystemRole: true, Tools: true}},
+		func(_ context.Context, req *ai.ModelRequest, _ ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+			calls.Add(1)
+			resp := respond(req)
+			resp.Usage = &ai.GenerationUsage{InputTokens: 10, OutputTokens: 5}
+			return resp, nil
+		})
+	return g, &calls
+}
+
+func TestCachingGenerator_Generate(t *testing.T) {
+	g, calls := countingModel(func(req *ai.ModelRequest) *ai.ModelResponse {
+		return &ai.ModelResponse{Message: ai.NewModelTextMessage("Gift for: " + req.Messages[len(req.Messages)-1].Text()), FinishReason: ai.FinishReasonStop}
+	})
+	generator := NewCachingGenerator(&GenkitGenerator{AIClient: g}, NewMemoryCache(0))
+
+	first, err := generator.Generate(context.Background(), ai.WithPrompt("a boy"))
+	require.NoError(t, err)
+	second, err := generator.Generate(context.Background(), ai.WithPrompt("a boy"))
+	require.NoError(t, err)
+	third, err := generator.Generate(context.Background(), ai.WithPrompt("a girl"))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(2), calls.Load(), "the same request is answered from the cache")
+	assert.Equal(t, "Gift for: a boy", first.Text())
+	assert.Equal(t, "Gift for: a boy", second.Text())
+	assert.Equal(t, "Gift for: a girl", third.Text())
+	assert.NotNil(t, first.Usage)
+	assert.Nil(t, second.Usage, "a cached response costs no tokens")
+}
+
+func TestCachingGenerator_Interrupted(t *testing.T) {
+	g, calls := countingModel(func(*ai.ModelRequest) *ai.ModelResponse {
+		return &ai.ModelResponse{
+			Message: &ai.Message{Role: ai.RoleModel, Content: []*ai.Part{
+				ai.NewToolRequestPart(&ai.ToolRequest{Name: "askQuestion", Input: map[string]any{"question": "Who is the gift for?", "choices": []any{"A boy", "A girl"}}}),
+			}},
+			FinishReason: ai.FinishReasonStop,
+		}
+	})
+	askQuestion := DefineAskQuestionTool(g)
+	generator := NewCachingGenerator(&GenkitGenerator{AIClient: g}, NewMemoryCache(0))
+
+	for range 2 {
+		resp, err := generator.Generate(context.Background(), ai.WithPrompt("Suggest a gift."), ai.WithTools(askQuestion))
+
+		require.NoError(t, err)
+		assert.Equal(t, ai.FinishReasonInterrupted, resp.FinishReason)
+		require.Len(t, resp.Interrupts(), 1)
+	}
+	assert.Equal(t, int64(1), calls.Load())
+}
+
+func TestCachingGenerator_OtherCalls(t *testing.T) {
+	g, calls := countingModel(func(*ai.ModelRequest) *ai.ModelResponse {
+		return &ai.ModelResponse{Message: ai.NewModelTextMessage(trueAnswer), FinishReason: ai.FinishReasonStop}
+	})
+	generator := NewCachingGenerator(&GenkitGenerator{AIClient: g}, NewMemoryCache(0))
+	history := []*ai.Message{ai.NewUserTextMessage("Suggest a gift."), ai.NewModelTextMessage("A kite.")}
+
+	for range 2 {
+		finished, err := generator.GenerateBool(context.Background(), "Is the conversation finished?", history)
+		require.NoError(t, err)
+		assert.True(t, finished)
+	}
+	assert.Equal(t, int64(1), calls.Load())
+
+	var texts []string
+	_, err := generator.GenerateStream(context.Background(), chunkTexts(&texts), ai.WithPrompt("Suggest a gift."))
+	require.NoError(t, err)
+	_, err = generator.GenerateStream(context.Background(), chunkTexts(&texts), ai.WithPrompt("Suggest a gift."))
+	require.NoError(t, err)
+	assert.Equal(t, []string{trueAnswer}, texts, "a cached response is streamed as a single chunk")
+	assert.Equal(t, int64(2), calls.Load())
+}
+
+func TestCachingGenerator_SharedCache(t *testing.T) {
+	g := genkit.Init(context.Background(), genkit.WithDefaultModel("test/primary"))
+	calls := map[string]int{}
+	for _, name := range []string{"test/primary", "test/fallback"} {
+		genkit.DefineModel(g, name, &ai.ModelOptions{Supports: &ai.ModelSupports{Multiturn: true}},
+			func(context.Context, *ai.ModelRequest, ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+				calls[name]++
+				return &ai.ModelResponse{Message: ai.NewModelTextMessage("Answered by " + name), FinishReason: ai.FinishReasonStop}, nil
+			})
+	}
+	strict, err := safetySettings(map[string]string{"HARM_CATEGORY_HARASSMENT": "BLOCK_LOW_AND_ABOVE"})
+	require.NoError(t, err)
+	cache := NewMemoryCache(0)
+	generators := []struct {
+		name      string
+		generator *GenkitGenerator
+		expected  string
+	}{
+		{name: "primary", generator: &GenkitGenerator{AIClient: g, Model: "test/primary"}, expected: "Answered by test/primary"},
+		{name: "fallback", generator: &GenkitGenerator{AIClient: g, Model: "test/fallback"}, expected: "Answered by test/fallback"},
+		{name: "safety settings", generator: &GenkitGenerator{AIClient: g, Model: "test/primary", SafetySettings: strict}, expected: "Answered by test/primary"},
+	}
+
+	for range 2 {
+		for _, tt := range generators {
+			resp, err := NewCachingGenerator(tt.generator, cache).Generate(context.Background(), ai.WithPrompt("Suggest a gift."))
+
+			require.NoError(t, err, tt.name)
+			assert.Equal(t, tt.expected, resp.Text(), tt.name)
+		}
+	}
+	assert.Equal(t, map[string]int{"test/primary": 2, "test/fallback": 1}, calls, "each generator is answered from its own entries")
+}
+
+func TestFingerprint(t *testing.T) {
+	request := func() *ai.ModelRequest {
+		return &ai.ModelRequest{
+			Messages: []*ai.Message{
+				ai.NewSystemTextMessage("You help with gifts."),
+				ai.NewUserTextMessage("Suggest a gift."),
+				ai.NewModelTextMessage("Who is the gift for?"),
+				ai.NewUserTextMessage("A boy."),
+			},
+			Tools:  []*ai.ToolDefinition{{Name: "askQuestion", Description: "asks the user"}},
+			Config: map[string]any{"temperature": 0.2},
+		}
+	}
+	base, err := fingerprint("googleai/gemini-2.5-flash", request())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		mutate func(req *ai.ModelRequest)
+	}{
+		{name: "message", mutate: func(req *ai.ModelRequest) { req.Messages[3].Content[0].Text = "A girl." }},
+		{name: "system prompt", mutate: func(req *ai.ModelRequest) { req.Messages[0].Content[0].Text = "You help with books." }},
+		{name: "tools", mutate: func(req *ai.ModelRequest) { req.Tools = nil }},
+		{name: "config", mutate: func(req *ai.ModelRequest) { req.Config = map[string]any{"temperature": 0.9} }},
+	}
+
+	same, err := fingerprint("googleai/gemini-2.5-flash", request())
+	require.NoError(t, err)
+	assert.Equal(t, base, same, "the fingerprint is stable")
+	otherModel, err := fingerprint("googleai/gemini-2.5-pro", request())
+	require.NoError(t, err)
+	assert.NotEqual(t, base, otherModel, "the model is part of the fingerprint")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request()
+			tt.mutate(req)
+
+			changed, err := fingerprint("googleai/gemini-2.5-flash", req)
+
+			require.NoError(t, err)
+			assert.NotEqual(t, base, changed)
+		})
+	}
+}
+
+func TestMemoryCache_Evicts(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache(2)
+	require.NoError(t, cache.Put(ctx, "a", createTextResponse("A kite", "stop")))
+	require.NoError(t, cache.Put(ctx, "b", createTextResponse("A LEGO set", "stop")))
+	_, _, _ = cache.Get(ctx, "a")
+	require.NoError(t, cache.Put(ctx, "c", createTextResponse("A puzzle", "stop")))
+
+	_, ok, _ := cache.Get(ctx, "b")
+	assert.False(t, ok, "the response used least recently is dropped")
+	resp, ok, _ := cache.Get(ctx, "a")
+	require.True(t, ok)
+	assert.Equal(t, "A kite", resp.Text())
+}
+
+func TestDiskCache(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache, err := NewDiskCache(dir, time.Hour)
+	require.NoError(t, err)
+
+	_, ok, err := cache.Get(ctx, "gift")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Put(ctx, "gift", createTextResponse("A kite", "stop")))
+	reopened, err := NewDiskCache(dir, time.Hour)
+	require.NoError(t, err)
+	resp, ok, err := reopened.Get(ctx, "gift")
+	require.NoError(t, err)
+	require.True(t, ok, "the responses outlive the cache")
+	assert.Equal(t, "A kite", resp.Text())
+
+	reopened.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, ok, err = reopened.Get(ctx, "gift")
+	require.NoError(t, err)
+	assert.False(t, ok, "an expired response is a miss")
+	_, err = os.Stat(filepath.Join(dir, "gift.json"))
+	assert.True(t, os.IsNotExist(err), "an expired response is deleted")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQuestionNotPending is returned when answering a question that was already answered or cancelled.
+var ErrQuestionNotPending = errors.New("question is no longer pending")
+
+// PendingQuestion is a question waiting for the consumer of a ChanInteractor to answer it.
+// Answer and Fail are safe to call from any goroutine; only the first call resolves the question.
+type PendingQuestion struct {
+	Input QuestionInput
+
+	ctx  context.Context
+	mu   sync.Mutex
+	done chan struct{}
+	// answer and err are set once done is closed.
+	answer string
+	err    error
+}
+
+// Context is done when the question is cancelled by the asking side,
+// after which there is no point in answering it.
+func (q *PendingQuestion) Context() context.Context {
+	return q.ctx
+}
+
+// Answer resolves the question with the answer.
+// It returns ErrQuestionNotPending when the question was already resolved or cancelled.
+func (q *PendingQuestion) Answer(answer string) error {
+	return q.resolve(answer, nil)
+}
+
+// Fail resolves the question with an error, such as ErrQuestionSkipped to let the model continue without an answer.
+// It returns ErrQuestionNotPending when the question was already resolved or cancelled.
+func (q *PendingQuestion) Fail(err error) error {
+	return q.resolve("", err)
+}
+
+func (q *PendingQuestion) resolve(answer string, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return ErrQuestionNotPending
+	default:
+	}
+	if q.ctx.Err() != nil {
+		return ErrQuestionNotPending
+	}
+	q.answer, q.err = answer, err
+	close(q.done)
+	return nil
+}
+
+// ChanInteractor hands questions to Go code over a channel, for embedding the agent in a service
+// that bridges them to its own transport. Several questions can be outstanding at once and each is
+// answered through its PendingQuestion.
+type ChanInteractor struct {
+	questions chan *PendingQuestion
+}
+
+// NewChanInteractor creates a ChanInteractor whose question channel holds up to buffer questions
+// that the consumer hasn't received yet.
+func NewChanInteractor(buffer int) *ChanInteractor {
+	return &ChanInteractor{questions: make(chan *PendingQuestion, buffer)}
+}
+
+// Questions returns the channel delivering the questions to answer.
+func (c *ChanInteractor) Questions() <-chan *PendingQuestion {
+	return c.questions
+}
+
+// Interact sends the question to the consumer and blocks until it is answered or the context is done.
+// It implements UserInteractionFunc.
+func (c *ChanInteractor) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	question := &PendingQuestion{Input: input, ctx: ctx, done: make(chan struct{})}
+	select {
+	case c.questions <- question:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	select {
+	case <-question.done:
+		return question.answer, question.err
+	case <-ctx.Done():
+		// an answer given at the same time as the cancellation wins
+		select {
+		case <-question.done:
+			return question.answer, question.err
+		default:
+			return "", ctx.Err()
+		}
+	}
+}
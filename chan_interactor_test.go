@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChanInteractor_RunAgent(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createToolRequestPart("askQuestion", "What are their ages?", nil),
+			),
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What is the budget?", nil),
+			),
+			createTextResponse("Based on both genders and ages...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	interactor := NewChanInteractor(0)
+
+	answers := map[string]string{
+		"What gender are the children?": "Both",
+		"What are their ages?":          "8 and 11",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for question := range interactor.Questions() {
+			// each question is answered from its own goroutine
+			go func() {
+				if answer, ok := answers[question.Input.Question]; ok {
+					assert.NoError(t, question.Answer(answer))
+				} else {
+					assert.NoError(t, question.Fail(ErrQuestionSkipped))
+				}
+			}()
+		}
+	}()
+
+	result, err := RunAgent(ctx, &Options{
+		generator: mockGen,
+		responseHandler: &InterruptionHandler{
+			generator:       mockGen,
+			UserInteraction: interactor.Interact,
+		},
+	})
+
+	require.NoError(t, err)
+	assert.Contains(t, result, "Based on")
+	assert.Equal(t, 3, mockGen.callIndex)
+}
+
+func TestChanInteractor_OutstandingQuestions(t *testing.T) {
+	interactor := NewChanInteractor(2)
+
+	var wg sync.WaitGroup
+	results := make([]string, 2)
+	for i, question := range []string{"What gender?", "What age?"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			answer, err := interactor.Interact(context.Background(), QuestionInput{Question: question})
+			assert.NoError(t, err)
+			results[i] = answer
+		}()
+	}
+
+	pending := map[string]*PendingQuestion{}
+	for range 2 {
+		question := <-interactor.Questions()
+		pending[question.Input.Question] = question
+	}
+	require.NoError(t, pending["What age?"].Answer("8"))
+	require.NoError(t, pending["What gender?"].Answer("Boy"))
+	wg.Wait()
+
+	assert.Equal(t, []string{"Boy", "8"}, results)
+	assert.ErrorIs(t, pending["What age?"].Answer("11"), ErrQuestionNotPending, "only the first answer counts")
+}
+
+func TestChanInteractor_Cancel(t *testing.T) {
+	t.Run("pending question", func(t *testing.T) {
+		interactor := NewChanInteractor(1)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := interactor.Interact(ctx, QuestionInput{Question: "What gender?"})
+			done <- err
+		}()
+		question := <-interactor.Questions()
+		cancel()
+
+		assert.ErrorIs(t, <-done, context.Canceled)
+		<-question.Context().Done()
+		assert.ErrorIs(t, question.Answer("Boy"), ErrQuestionNotPending)
+	})
+
+	t.Run("question not received", func(t *testing.T) {
+		interactor := NewChanInteractor(0)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := interactor.Interact(ctx, QuestionInput{Question: "What gender?"})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func ExampleChanInteractor() {
+	interactor := NewChanInteractor(0)
+	go func() {
+		for question := range interactor.Questions() {
+			if len(question.Input.Choices) > 0 {
+				_ = question.Answer(question.Input.Choices[0])
+				continue
+			}
+			_ = question.Fail(ErrQuestionSkipped)
+		}
+	}()
+
+	answer, _ := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}})
+	fmt.Println(answer)
+	_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What budget?"})
+	fmt.Println(err)
+	// Output:
+	// Boy
+	// question skipped by the user
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// checklistInstruction is the system instruction of GenerateChecklist; the questions are sent as the
+// last user message, numbered, as with GenerateBoolScored.
+const checklistInstruction = "Answer each of the numbered yes/no questions in the last user message about the conversation " +
+	"before it, strictly with true or false, giving the number of the question with its answer."
+
+// checklistOutput is the structured answer of GenerateChecklist. An answer is a pointer to tell a null
+// answer from false.
+type checklistOutput struct {
+	Answers []checklistAnswer `json:"answers" jsonschema:"description=the answers, one for each question"`
+}
+
+type checklistAnswer struct {
+	Number int   `json:"number" jsonschema:"description=the number of the question"`
+	Answer *bool `json:"answer" jsonschema:"description=the answer to the question"`
+}
+
+// GenerateChecklist asks the model the yes/no questions about the history in a single call, and returns
+// the answers by question. The questions are sent numbered after the history as a user message. A
+// question the model leaves out or answers with null is taken as false with a warning. An answer that
+// isn't a checklist is asked for once more, as with GenerateBoolScored.
+func (g *GenkitGenerator) GenerateChecklist(ctx context.Context, questions []string, history []*ai.Message) (map[string]bool, error) {
+	if len(questions) == 0 {
+		return map[string]bool{}, nil
+	}
+	messages := append(slices.Clone(history), ai.NewUserTextMessage(checklistPrompt(questions)))
+	var err error
+	for range boolAttempts {
+		var output checklistOutput
+		if err = g.GenerateJSON(ctx, checklistInstruction, messages, &output); err == nil {
+			return output.byQuestion(questions), nil
+		}
+		var mismatch *ErrOutputMismatch
+		if !errors.As(err, &mismatch) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// checklistPrompt numbers the questions from 1, one a line.
+func checklistPrompt(questions []string) string {
+	var prompt strings.Builder
+	for i, question := range questions {
+		fmt.Fprintf(&prompt, "%d. %s\n", i+1, question)
+	}
+	return strings.TrimSuffix(prompt.String(), "\n")
+}
+
+// byQuestion returns the answers by question, false for a question without an answer.
+func (o checklistOutput) byQuestion(questions []string) map[string]bool {
+	answers := make(map[string]bool, len(questions))
+	for _, answer := range o.Answers {
+		if answer.Number < 1 || answer.Number > len(questions) || answer.Answer == nil {
+			continue
+		}
+		answers[questions[answer.Number-1]] = *answer.Answer
+	}
+	for _, question := range questions {
+		if _, ok := answers[question]; !ok {
+			log.Printf("the model didn't answer the question %q of the checklist, taken as false", question)
+			answers[question] = false
+		}
+	}
+	return answers
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// giftChecklist are the questions of the checklist tests.
+var giftChecklist = []string{"Is the budget known?", "Are the ages known?", "Is the user frustrated?"}
+
+func TestGenkitGenerator_GenerateChecklist(t *testing.T) {
+	tests := []struct {
+		name     string
+		answers  []string
+		expected map[string]bool
+		warnings []string
+		err      string
+	}{
+		{
+			name:     "full",
+			answers:  []string{`{"answers": [{"number": 1, "answer": true}, {"number": 2, "answer": true}, {"number": 3, "answer": false}]}`},
+			expected: map[string]bool{"Is the budget known?": true, "Are the ages known?": true, "Is the user frustrated?": false},
+		},
+		{
+			name:     "out of order",
+			answers:  []string{`{"answers": [{"number": 3, "answer": true}, {"number": 1, "answer": false}, {"number": 2, "answer": true}]}`},
+			expected: map[string]bool{"Is the budget known?": false, "Are the ages known?": true, "Is the user frustrated?": true},
+		},
+		{
+			name:     "partial",
+			answers:  []string{`{"answers": [{"number": 1, "answer": true}, {"number": 2, "answer": null}, {"number": 7, "answer": true}]}`},
+			expected: map[string]bool{"Is the budget known?": true, "Are the ages known?": false, "Is the user frustrated?": false},
+			warnings: []string{`the model didn't answer the question "Are the ages known?"`, `the model didn't answer the question "Is the user frustrated?"`},
+		},
+		{
+			name:     "malformed once",
+			answers:  []string{"yes, yes and no", `{"answers": [{"number": 1, "answer": true}, {"number": 2, "answer": true}, {"number": 3, "answer": true}]}`},
+			expected: map[string]bool{"Is the budget known?": true, "Are the ages known?": true, "Is the user frustrated?": true},
+		},
+		{
+			name:    "malformed twice",
+			answers: []string{"yes, yes and no", `{"answers": "all yes"}`},
+			err:     "the model output doesn't match the expected type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureLog(t)
+			responses := make([]*ai.ModelResponse, len(tt.answers))
+			for i, answer := range tt.answers {
+				responses[i] = createTextResponse(answer, "stop")
+			}
+			generator, calls := scriptedGenkitGenerator(responses...)
+
+			answers, err := generator.GenerateChecklist(context.Background(), giftChecklist, nil)
+
+			assert.Len(t, *calls, len(tt.answers))
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, answers)
+			for _, warning := range tt.warnings {
+				assert.Contains(t, out.String(), warning)
+			}
+			if len(tt.warnings) == 0 {
+				assert.Empty(t, out.String())
+			}
+		})
+	}
+}
+
+func TestGenkitGenerator_GenerateChecklistPrompt(t *testing.T) {
+	generator, calls := scriptedGenkitGenerator(createTextResponse(`{"answers": []}`, "stop"))
+	history := []*ai.Message{ai.NewUserTextMessage("Suggest a gift."), ai.NewModelTextMessage("How old are the children?")}
+	captureLog(t)
+
+	_, err := generator.GenerateChecklist(context.Background(), giftChecklist, history)
+
+	require.NoError(t, err)
+	require.Len(t, *calls, 1, "all the questions are asked in a single call")
+	system, messages := capturedOptionPrompts(t, (*calls)[0])
+	assert.Equal(t, checklistInstruction, system)
+	require.Len(t, messages, 3)
+	assert.Equal(t, "1. Is the budget known?\n2. Are the ages known?\n3. Is the user frustrated?", messages[2].Text())
+}
+
+func TestGenkitGenerator_GenerateChecklistWithoutQuestions(t *testing.T) {
+	generator, calls := scriptedGenkitGenerator()
+
+	answers, err := generator.GenerateChecklist(context.Background(), nil, nil)
+
+	require.NoError(t, err)
+	assert.Empty(t, answers)
+	assert.Empty(t, *calls, "the model isn't called")
+}
+
+func TestConversationLoopHandler_RequireFields(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{
+		createTextResponse("What is your budget?", "stop"),
+		createTextResponse("A LEGO set", "stop"),
+	}, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")})
+	mockGen.checklistResponses = []map[string]bool{
+		{"Is the conversation finished?": true, "Is the budget known?": false, "Are the ages known?": true},
+		{"Is the conversation finished?": true, "Is the budget known?": true, "Are the ages known?": true},
+	}
+	var questions []string
+	handler := NewConversationLoopHandler(mockGen, "Is the conversation finished?", func(_ context.Context, input QuestionInput) (string, error) {
+		questions = append(questions, input.Question)
+		return "$50", nil
+	})
+	handler.RequireFields("Is the budget known?", "Are the ages known?")
+
+	text, err := RunAgent(context.Background(), &Options{generator: mockGen, userPrompt: "Suggest a gift.", responseHandler: handler})
+
+	require.NoError(t, err)
+	assert.Equal(t, "A LEGO set", text)
+	assert.Equal(t, []string{"What is your budget?"}, questions, "the conversation goes on while a field is missing")
+	assert.Equal(t, [][]string{
+		{"Is the conversation finished?", "Is the budget known?", "Are the ages known?"},
+		{"Is the conversation finished?", "Is the budget known?", "Are the ages known?"},
+	}, mockGen.checklistQuestions)
+	assert.Empty(t, mockGen.boolPrompts, "the validation prompt is asked with the checklist")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+)
+
+// ChoiceProvider returns the choices of a question from live data only the application has, such as the
+// stores near the user. It is called right before the question is asked, with the question as the model
+// wrote it.
+type ChoiceProvider func(ctx context.Context, question QuestionInput) ([]Choice, error)
+
+// choiceProvider is a registered ChoiceProvider and how its choices are combined with the model's.
+type choiceProvider struct {
+	provide ChoiceProvider
+	augment bool
+}
+
+// ChoiceProviderOption configures a registered ChoiceProvider.
+type ChoiceProviderOption func(*choiceProvider)
+
+// WithAugmentedChoices adds the provided choices after the ones the model wrote, leaving out those it
+// already has, instead of replacing them.
+func WithAugmentedChoices() ChoiceProviderOption {
+	return func(p *choiceProvider) {
+		p.augment = true
+	}
+}
+
+// RegisterChoiceProvider fills in the choices of the questions whose choicesSource is source with the
+// provider. A provider that fails or returns no choices leaves the choices the model wrote.
+func (ih *InterruptionHandler) RegisterChoiceProvider(source string, provider ChoiceProvider, opts ...ChoiceProviderOption) {
+	if ih.choiceProviders == nil {
+		ih.choiceProviders = make(map[string]choiceProvider)
+	}
+	p := choiceProvider{provide: provider}
+	for _, opt := range opts {
+		opt(&p)
+	}
+	ih.choiceProviders[source] = p
+}
+
+// RegisterChoiceProvider registers the provider with the inner handler, when it is an InterruptionHandler.
+func (cv *ConversationLoopHandler) RegisterChoiceProvider(source string, provider ChoiceProvider, opts ...ChoiceProviderOption) {
+	if inner, ok := cv.inner.(*InterruptionHandler); ok {
+		inner.RegisterChoiceProvider(source, provider, opts...)
+	}
+}
+
+// provideChoices returns the question with the choices of the provider registered for its source.
+func (ih *InterruptionHandler) provideChoices(ctx context.Context, question QuestionInput) QuestionInput {
+	if question.ChoicesSource == "" {
+		return question
+	}
+	provider, ok := ih.choiceProviders[question.ChoicesSource]
+	if !ok {
+		log.Printf("no choice provider for %q, keeping the model's choices", question.ChoicesSource)
+		return question
+	}
+	choices, err := provider.provide(ctx, question)
+	if err != nil {
+		log.Printf("choice provider %q failed, keeping the model's choices: %v", question.ChoicesSource, err)
+		return question
+	}
+	if len(choices) == 0 {
+		return question
+	}
+
+	if provider.augment {
+		existing := question.choiceList()
+		for _, choice := range choices {
+			if !slices.ContainsFunc(existing, choice.sameAs) {
+				existing = append(existing, choice)
+			}
+		}
+		choices = existing
+	}
+	question.setChoices(choices)
+	return question
+}
+
+// choiceList returns the choices of the question as Choice objects.
+func (q QuestionInput) choiceList() []Choice {
+	choices := make([]Choice, len(q.Choices))
+	for i, label := range q.Choices {
+		choices[i] = Choice{Value: q.choiceValue(label), Label: label, Description: q.choiceDescription(i)}
+	}
+	return choices
+}
+
+// setChoices replaces the choices of the question. A default given as the value of a choice is
+// replaced by its label, like in UnmarshalJSON.
+func (q *QuestionInput) setChoices(choices []Choice) {
+	q.Choices, q.ChoiceValues, q.ChoiceDescriptions = nil, nil, nil
+	values := make([]string, 0, len(choices))
+	descriptions := make([]string, 0, len(choices))
+	hasValues, hasDescriptions := false, false
+	for _, choice := range choices {
+		if choice.Label == "" {
+			choice.Label = choice.Value
+		}
+		q.Choices = append(q.Choices, choice.Label)
+		values = append(values, choice.Value)
+		descriptions = append(descriptions, choice.Description)
+		hasValues = hasValues || choice.Value != choice.Label
+		hasDescriptions = hasDescriptions || choice.Description != ""
+	}
+	if hasDescriptions {
+		q.ChoiceDescriptions = descriptions
+	}
+	if hasValues {
+		q.ChoiceValues = values
+		if i := slices.Index(values, q.Default); i >= 0 {
+			q.Default = q.Choices[i]
+		}
+	}
+}
+
+// sameAs reports whether the choices have the same value or label, ignoring case.
+func (c Choice) sameAs(other Choice) bool {
+	label, otherLabel := c.Label, other.Label
+	if label == "" {
+		label = c.Value
+	}
+	if otherLabel == "" {
+		otherLabel = other.Value
+	}
+	return strings.EqualFold(c.Value, other.Value) || strings.EqualFold(label, otherLabel)
+}
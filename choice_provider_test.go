@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterruptionHandler_ChoiceProvider(t *testing.T) {
+	nearbyStores := func(context.Context, QuestionInput) ([]Choice, error) {
+		return []Choice{
+			{Value: "store_12", Label: "Toy Town", Description: "0.5 km away"},
+			{Value: "store_7", Label: "Game Corner"},
+		}, nil
+	}
+
+	tests := []struct {
+		name         string
+		input        map[string]any
+		provider     ChoiceProvider
+		opts         []ChoiceProviderOption
+		answer       string
+		expected     QuestionInput
+		expectedText string
+	}{
+		{
+			name:     "substitution",
+			input:    map[string]any{"question": "Which store should I check?", "choices": []any{"Any store"}, "choicesSource": "nearby_stores"},
+			provider: nearbyStores,
+			answer:   "Toy Town",
+			expected: QuestionInput{
+				Question:           "Which store should I check?",
+				Choices:            []string{"Toy Town", "Game Corner"},
+				ChoiceValues:       []string{"store_12", "store_7"},
+				ChoiceDescriptions: []string{"0.5 km away", ""},
+				ChoicesSource:      "nearby_stores",
+			},
+			expectedText: "store_12",
+		},
+		{
+			name:     "augmentation",
+			input:    map[string]any{"question": "Which store should I check?", "choices": []any{"Online", "game corner"}, "choicesSource": "nearby_stores"},
+			provider: nearbyStores,
+			opts:     []ChoiceProviderOption{WithAugmentedChoices()},
+			answer:   "Online",
+			expected: QuestionInput{
+				Question:           "Which store should I check?",
+				Choices:            []string{"Online", "game corner", "Toy Town"},
+				ChoiceValues:       []string{"Online", "game corner", "store_12"},
+				ChoiceDescriptions: []string{"", "", "0.5 km away"},
+				ChoicesSource:      "nearby_stores",
+			},
+			expectedText: "Online",
+		},
+		{
+			name:  "fallback on failure",
+			input: map[string]any{"question": "Which store should I check?", "choices": []any{"Any store"}, "choicesSource": "nearby_stores"},
+			provider: func(context.Context, QuestionInput) ([]Choice, error) {
+				return nil, errors.New("location unavailable")
+			},
+			answer:       "Any store",
+			expected:     QuestionInput{Question: "Which store should I check?", Choices: []string{"Any store"}, ChoicesSource: "nearby_stores"},
+			expectedText: "Any store",
+		},
+		{
+			name:  "fallback without choices",
+			input: map[string]any{"question": "Which store should I check?", "choices": []any{"Any store"}, "choicesSource": "nearby_stores"},
+			provider: func(context.Context, QuestionInput) ([]Choice, error) {
+				return nil, nil
+			},
+			answer:       "Any store",
+			expected:     QuestionInput{Question: "Which store should I check?", Choices: []string{"Any store"}, ChoicesSource: "nearby_stores"},
+			expectedText: "Any store",
+		},
+		{
+			name:         "unknown source",
+			input:        map[string]any{"question": "Which store should I check?", "choices": []any{"Any store"}, "choicesSource": "open_stores"},
+			provider:     nearbyStores,
+			answer:       "Any store",
+			expected:     QuestionInput{Question: "Which store should I check?", Choices: []string{"Any store"}, ChoicesSource: "open_stores"},
+			expectedText: "Any store",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGen := NewMockGenerator(
+				[]*ai.ModelResponse{
+					createInterruptedResponse(createInterruptPart("askQuestion", tt.input)),
+					createTextResponse("Toy Town has it", "stop"),
+				},
+				map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+			)
+			var asked QuestionInput
+			handler := &InterruptionHandler{
+				generator: mockGen,
+				UserInteraction: func(_ context.Context, input QuestionInput) (string, error) {
+					asked = input
+					return tt.answer, nil
+				},
+			}
+			handler.RegisterChoiceProvider("nearby_stores", tt.provider, tt.opts...)
+
+			_, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, asked)
+			parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+			require.Len(t, parts, 1)
+			assert.Equal(t, tt.expectedText, parts[0].ToolResponse.Output)
+		})
+	}
+}
+
+func TestChoiceProvider_ReceivesQuestion(t *testing.T) {
+	var received QuestionInput
+	handler := &InterruptionHandler{}
+	handler.RegisterChoiceProvider("nearby_stores", func(_ context.Context, question QuestionInput) ([]Choice, error) {
+		received = question
+		return []Choice{{Value: "Toy Town"}}, nil
+	})
+
+	question := handler.provideChoices(context.Background(), QuestionInput{Question: "Which store?", ChoicesSource: "nearby_stores", Default: "Toy Town"})
+
+	assert.Equal(t, QuestionInput{Question: "Which store?", ChoicesSource: "nearby_stores", Default: "Toy Town"}, received)
+	assert.Equal(t, []string{"Toy Town"}, question.Choices)
+	assert.Nil(t, question.ChoiceValues, "choices whose value is their label have no values")
+	assert.Equal(t, "Toy Town", question.Default)
+}
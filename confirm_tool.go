@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineConfirmTool.
+const (
+	defaultConfirmToolName        = "confirm"
+	defaultConfirmToolDescription = "use this to ask the user a yes/no question; the response is true for yes and false for no"
+)
+
+// ConfirmInput contains a statement for the user to confirm or reject.
+type ConfirmInput struct {
+	Statement string `json:"statement" jsonschema:"description=a yes/no question such as 'Should I stay under $50 total?'"`
+}
+
+// DefineConfirmTool defines the yes/no tool, named "confirm" by default, in the Genkit instance and
+// returns it. Its interrupts are answered by HandleConfirm, which must be registered for the tool name
+// with RegisterToolHandler.
+func DefineConfirmTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultConfirmToolName, defaultConfirmToolDescription, opts)
+
+	return DefineInterruptTool[ConfirmInput, bool](g, config.name, config.description, WithInterruptMetadataKey("confirm"))
+}
+
+// HandleConfirm is the ToolHandler of the confirm tool. It asks the statement as a y/n question,
+// asking again until the answer is y, yes, n or no, and responds with a bool.
+func HandleConfirm(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var confirm ConfirmInput
+	if err := decodeToolInput(input, &confirm); err != nil {
+		return ToolResult{}, err
+	}
+	if confirm.Statement == "" {
+		return ToolResult{}, errors.New("confirm tool called without a statement")
+	}
+
+	confirmed, err := askParsed(ctx, interactor, QuestionInput{Question: confirm.Statement + " (y/n)"}, parseConfirmation)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	return ToolResult{Output: confirmed}, nil
+}
+
+// parseConfirmation maps y, yes, n and no, in any case, to a bool.
+func parseConfirmation(answer string) (any, error) {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	default:
+		return nil, errors.New("expected y or n")
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfirmation(t *testing.T) {
+	tests := []struct {
+		answer   string
+		expected any
+		ok       bool
+	}{
+		{answer: "y", expected: true, ok: true},
+		{answer: " YES ", expected: true, ok: true},
+		{answer: "n", expected: false, ok: true},
+		{answer: "No", expected: false, ok: true},
+		{answer: "maybe"},
+		{answer: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.answer, func(t *testing.T) {
+			value, err := parseConfirmation(tt.answer)
+
+			if !tt.ok {
+				assert.EqualError(t, err, "expected y or n")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestHandleConfirm_ThroughToolHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		{name: "yes", input: "y\n", expected: true},
+		{name: "no after an invalid answer", input: "maybe\nNO\n", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			mockGen := NewMockGenerator(
+				[]*ai.ModelResponse{
+					createInterruptedResponse(createInterruptPart("confirm", map[string]any{"statement": "Should I stay under $50 total?"})),
+					createTextResponse("Here are some gifts...", "stop"),
+				},
+				map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "confirm": createMockTool("confirm")},
+			)
+			var out bytes.Buffer
+			terminal := NewTerminalReader(ctx, strings.NewReader(tt.input), WithAnswerTimeout(time.Second), WithOutput(&out))
+			handler := &InterruptionHandler{generator: mockGen, Interactor: terminal}
+			handler.RegisterToolHandler("confirm", HandleConfirm)
+
+			result, err := RunAgent(ctx, &Options{generator: mockGen, responseHandler: handler})
+
+			require.NoError(t, err)
+			assert.Equal(t, "Here are some gifts...", result)
+			parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+			require.Len(t, parts, 1)
+			assert.Equal(t, tt.expected, parts[0].ToolResponse.Output, "the model gets a real bool")
+			assert.Contains(t, out.String(), "Should I stay under $50 total? (y/n)")
+			if strings.HasPrefix(tt.input, "maybe") {
+				assert.Contains(t, out.String(), "Invalid answer: expected y or n\n")
+			}
+		})
+	}
+}
+
+func TestHandleConfirm_MissingStatement(t *testing.T) {
+	_, err := HandleConfirm(context.Background(), &sequenceInteractor{}, map[string]any{})
+
+	assert.ErrorContains(t, err, "without a statement")
+}
+
+func TestDefineConfirmTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	confirm := DefineConfirmTool(g)
+	_, err := confirm.RunRaw(context.Background(), map[string]any{"statement": "Stay under $50?"})
+
+	assert.Equal(t, "confirm", confirm.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, "confirm", metadata["tool"])
+	assert.Equal(t, ConfirmInput{Statement: "Stay under $50?"}, metadata["confirm"])
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineConsentTool.
+const (
+	defaultConsentToolName        = "askConsent"
+	defaultConsentToolDescription = "use this before doing anything privacy-sensitive, such as storing or sharing personal details, to get the user's explicit consent; the response is true only when the user agreed, and you must not go ahead otherwise"
+)
+
+// consentAffirmative is the exact answer granting consent, and consentRefusal the one suggested for
+// refusing it.
+const (
+	consentAffirmative = "I agree"
+	consentRefusal     = "I do not agree"
+)
+
+// consentField is the name of the checkbox field shown to FormAsker interactors.
+const consentField = "consent"
+
+// consentRefusals are the answers refusing consent, compared in lower case.
+var consentRefusals = []string{"i do not agree", "i don't agree", "i disagree", "no", "n", "decline"}
+
+// ConsentInput contains the statement the user is asked to agree to.
+type ConsentInput struct {
+	Statement    string `json:"statement" jsonschema:"description=what the user agrees to, such as 'I agree that my address is shared with the shop to ship the gifts'"`
+	Consequences string `json:"consequences,omitempty" jsonschema:"description=what happens when the user agrees, such as who gets the data and for how long"`
+}
+
+// ConsentRecord is the auditable record of a consent question: the wording shown to the user, the exact
+// answer, when it was given and through which interactor.
+type ConsentRecord struct {
+	Statement    string `json:"statement"`
+	Consequences string `json:"consequences,omitempty"`
+	// Answer is the answer as given; a checkbox is recorded as "checked" or "unchecked", and a skipped
+	// question as an empty answer.
+	Answer     string    `json:"answer"`
+	Granted    bool      `json:"granted"`
+	AnsweredAt time.Time `json:"answeredAt"`
+	// InteractorSource is the type of the interactor the answer came from, such as "TerminalReader".
+	InteractorSource string `json:"interactorSource"`
+}
+
+// ConsentStore keeps the consent records, for example in a database for audits.
+type ConsentStore interface {
+	SaveConsent(ctx context.Context, record ConsentRecord) error
+}
+
+// DefineConsentTool defines the consent tool, named "askConsent" by default, in the Genkit instance and
+// returns it. Its interrupts are answered by the handler returned by NewConsentHandler, which must be
+// registered for the tool name with RegisterToolHandler.
+func DefineConsentTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultConsentToolName, defaultConsentToolDescription, opts)
+
+	return DefineInterruptTool[ConsentInput, bool](g, config.name, config.description, WithInterruptMetadataKey("consent"))
+}
+
+// consentHandler configures the handler returned by NewConsentHandler.
+type consentHandler struct {
+	store ConsentStore
+	// now is replaced in tests to record a fixed time.
+	now func() time.Time
+}
+
+// ConsentOption configures the handler of the consent tool.
+type ConsentOption func(*consentHandler)
+
+// WithConsentStore saves every consent record to the store. A record that can't be saved stops the run,
+// since consent that isn't recorded can't be relied on.
+func WithConsentStore(store ConsentStore) ConsentOption {
+	return func(h *consentHandler) {
+		h.store = store
+	}
+}
+
+// NewConsentHandler returns the ToolHandler of the consent tool. Interactors implementing FormAsker show
+// the statement with an "I agree" checkbox; the others ask the user to type "I agree" exactly, asking
+// again after answers that neither agree nor refuse. Skipping the question refuses consent. It responds
+// with true only when the user agreed, and with the ConsentRecord under the "consent" response metadata,
+// which transcripts collect.
+func NewConsentHandler(opts ...ConsentOption) ToolHandler {
+	h := &consentHandler{now: time.Now}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h.handle
+}
+
+func (h *consentHandler) handle(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var consent ConsentInput
+	if err := decodeToolInput(input, &consent); err != nil {
+		return ToolResult{}, err
+	}
+	if strings.TrimSpace(consent.Statement) == "" {
+		return ToolResult{}, errors.New("consent tool called without a statement")
+	}
+
+	granted, answer, err := h.ask(ctx, interactor, consent)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	record := ConsentRecord{
+		Statement:        consent.Statement,
+		Consequences:     consent.Consequences,
+		Answer:           answer,
+		Granted:          granted,
+		AnsweredAt:       h.now().UTC(),
+		InteractorSource: interactorSource(interactor),
+	}
+	if h.store != nil {
+		if err := h.store.SaveConsent(ctx, record); err != nil {
+			return ToolResult{}, fmt.Errorf("failed to store the consent: %w", err)
+		}
+	}
+	return ToolResult{Output: granted, Metadata: map[string]any{"consent": record}}, nil
+}
+
+// ask asks for consent and returns whether it was granted with the answer as given.
+func (h *consentHandler) ask(ctx context.Context, interactor Interactor, consent ConsentInput) (bool, string, error) {
+	if asker, ok := interactor.(FormAsker); ok {
+		form := FormInput{
+			Title:  strings.TrimSpace(consent.Statement + "\n" + consent.Consequences),
+			Fields: []FormField{{Name: consentField, Label: consentAffirmative, Type: FormFieldCheckbox}},
+		}
+		values, err := askWholeForm(ctx, interactor, asker, form)
+		if err != nil {
+			return false, "", err
+		}
+		if checked, _ := values[consentField].(bool); checked {
+			return true, "checked", nil
+		}
+		return false, "unchecked", nil
+	}
+
+	question := QuestionInput{
+		Question: fmt.Sprintf("%s (type %q to consent, or %q)", consent.Statement, consentAffirmative, consentRefusal),
+		Reason:   consent.Consequences,
+	}
+	var answer string
+	parse := func(reply string) (any, error) {
+		answer = reply
+		return parseConsent(reply)
+	}
+	granted, err := askParsed(ctx, interactor, question, parse)
+	if errors.Is(err, ErrQuestionSkipped) {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	return granted.(bool), answer, nil
+}
+
+// parseConsent accepts "I agree", in any case, as consent and the usual refusals as a refusal; anything
+// else is ambiguous.
+func parseConsent(answer string) (any, error) {
+	text := strings.TrimRight(strings.TrimSpace(answer), ".!")
+	switch {
+	case strings.EqualFold(text, consentAffirmative):
+		return true, nil
+	case slices.Contains(consentRefusals, strings.ToLower(text)):
+		return false, nil
+	default:
+		return nil, fmt.Errorf("expected %q to consent, or %q", consentAffirmative, consentRefusal)
+	}
+}
+
+// interactorSource names the type of the interactor, without its package and pointer.
+func interactorSource(interactor Interactor) string {
+	t := reflect.TypeOf(interactor)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// consentTime is the time the consent records are made at in the tests.
+var consentTime = time.Date(2025, 12, 1, 9, 30, 0, 0, time.UTC)
+
+// memoryConsentStore keeps the consent records in memory.
+type memoryConsentStore struct {
+	records []ConsentRecord
+	err     error
+}
+
+func (m *memoryConsentStore) SaveConsent(_ context.Context, record ConsentRecord) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.records = append(m.records, record)
+	return nil
+}
+
+// newTestConsentHandler returns the consent handler saving to the store at consentTime.
+func newTestConsentHandler(store ConsentStore) ToolHandler {
+	h := &consentHandler{store: store, now: func() time.Time { return consentTime }}
+	return h.handle
+}
+
+func TestParseConsent(t *testing.T) {
+	tests := []struct {
+		answer   string
+		expected any
+		err      string
+	}{
+		{answer: "I agree", expected: true},
+		{answer: " i AGREE. ", expected: true},
+		{answer: "I do not agree", expected: false},
+		{answer: "I don't agree", expected: false},
+		{answer: "no", expected: false},
+		{answer: "yes", err: `expected "I agree" to consent, or "I do not agree"`},
+		{answer: "I agree, but only for this order", err: `expected "I agree" to consent, or "I do not agree"`},
+		{answer: "ok", err: `expected "I agree" to consent, or "I do not agree"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.answer, func(t *testing.T) {
+			granted, err := parseConsent(tt.answer)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, granted)
+		})
+	}
+}
+
+func TestConsentHandler(t *testing.T) {
+	shareAddress := map[string]any{
+		"statement":    "I agree that my address is shared with the shop",
+		"consequences": "The shop keeps it for 30 days to ship the gifts",
+	}
+
+	tests := []struct {
+		name          string
+		answers       []Answer
+		granted       bool
+		answer        string
+		notifications []string
+	}{
+		{
+			name:    "granted",
+			answers: []Answer{{Value: "I agree"}},
+			granted: true,
+			answer:  "I agree",
+		},
+		{
+			name:    "refused",
+			answers: []Answer{{Value: "I do not agree"}},
+			answer:  "I do not agree",
+		},
+		{
+			name:          "ambiguous answer asked again",
+			answers:       []Answer{{Value: "sure"}, {Value: "I agree"}},
+			granted:       true,
+			answer:        "I agree",
+			notifications: []string{`Invalid answer: expected "I agree" to consent, or "I do not agree"`},
+		},
+		{
+			name:    "skipped",
+			answers: []Answer{{Skipped: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryConsentStore{}
+			interactor := &sequenceInteractor{answers: tt.answers}
+
+			result, err := newTestConsentHandler(store)(context.Background(), interactor, shareAddress)
+
+			require.NoError(t, err)
+			expected := ConsentRecord{
+				Statement:        "I agree that my address is shared with the shop",
+				Consequences:     "The shop keeps it for 30 days to ship the gifts",
+				Answer:           tt.answer,
+				Granted:          tt.granted,
+				AnsweredAt:       consentTime,
+				InteractorSource: "sequenceInteractor",
+			}
+			assert.Equal(t, tt.granted, result.Output)
+			assert.Equal(t, map[string]any{"consent": expected}, result.Metadata)
+			assert.Equal(t, []ConsentRecord{expected}, store.records)
+			assert.Equal(t, tt.notifications, interactor.notifications)
+		})
+	}
+}
+
+func TestConsentHandler_Checkbox(t *testing.T) {
+	tests := []struct {
+		name    string
+		forms   []map[string]string
+		granted bool
+		answer  string
+	}{
+		{name: "ticked", forms: []map[string]string{{"consent": "true"}}, granted: true, answer: "checked"},
+		{name: "left blank", forms: []map[string]string{{}}, answer: "unchecked"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryConsentStore{}
+			asker := &fakeFormAsker{forms: tt.forms}
+
+			result, err := newTestConsentHandler(store)(context.Background(), asker, map[string]any{"statement": "I agree to share my address"})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.granted, result.Output)
+			require.Len(t, store.records, 1)
+			assert.Equal(t, tt.answer, store.records[0].Answer)
+			assert.Equal(t, "fakeFormAsker", store.records[0].InteractorSource)
+		})
+	}
+}
+
+func TestConsentHandler_Errors(t *testing.T) {
+	t.Run("no statement", func(t *testing.T) {
+		_, err := NewConsentHandler()(context.Background(), &sequenceInteractor{}, map[string]any{"statement": " "})
+
+		assert.EqualError(t, err, "consent tool called without a statement")
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		store := &memoryConsentStore{err: errors.New("database is down")}
+		interactor := &sequenceInteractor{answers: []Answer{{Value: "I agree"}}}
+
+		_, err := NewConsentHandler(WithConsentStore(store))(context.Background(), interactor, map[string]any{"statement": "I agree to share my address"})
+
+		assert.EqualError(t, err, "failed to store the consent: database is down")
+	})
+}
+
+func TestConsentHandler_Transcript(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askConsent", map[string]any{"statement": "I agree to share my address"})),
+			createTextResponse("Sharing the address with the shop...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askConsent": createMockTool("askConsent")},
+	)
+	flow := newClarifyingAgentFlow(mockGen, WithFlowToolHandler("askConsent", newTestConsentHandler(nil)))
+
+	result, err := flow.run(context.Background(), ClarifyingAgentInput{
+		UserPrompt: "Ship the gifts to me.",
+		Answers:    map[string]string{"share my address": "I agree"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []ConsentRecord{{
+		Statement:        "I agree to share my address",
+		Answer:           "I agree",
+		Granted:          true,
+		AnsweredAt:       consentTime,
+		InteractorSource: "funcInteractor",
+	}}, result.Consents)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, true, parts[0].ToolResponse.Output)
+}
+
+func TestDefineConsentTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	consent := DefineConsentTool(g)
+	_, err := consent.RunRaw(context.Background(), map[string]any{"statement": "I agree to share my address"})
+
+	assert.Equal(t, "askConsent", consent.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, ConsentInput{Statement: "I agree to share my address"}, metadata["consent"])
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"google.golang.org/genai"
+)
+
+// ErrContentBlocked is returned for a response of the model the provider blocked, such as by its
+// safety filters, instead of the response's empty text.
+type ErrContentBlocked struct {
+	// Categories are the harm categories the response was blocked for, such as
+	// HARM_CATEGORY_HARASSMENT, when the provider reports them with the response.
+	Categories []string
+	// Message is the reason the provider gives, if any.
+	Message string
+}
+
+func (e *ErrContentBlocked) Error() string {
+	text := "the model's response was blocked"
+	if len(e.Categories) > 0 {
+		text += " for " + strings.Join(e.Categories, ", ")
+	}
+	if e.Message != "" {
+		text += ": " + e.Message
+	}
+	return text
+}
+
+// blockedError returns an ErrContentBlocked for a blocked response, and nil for any other.
+func blockedError(resp *ai.ModelResponse) error {
+	if resp == nil || resp.FinishReason != ai.FinishReasonBlocked {
+		return nil
+	}
+	return &ErrContentBlocked{Categories: blockedCategories(resp), Message: resp.FinishMessage}
+}
+
+// blockedCategories returns the categories of the safety ratings in the custom data of the response,
+// in the form of the Gemini API: the ratings marked as blocked, or all of them when none is marked.
+func blockedCategories(resp *ai.ModelResponse) []string {
+	if resp.Custom == nil {
+		return nil
+	}
+	data, err := json.Marshal(resp.Custom)
+	if err != nil {
+		return nil
+	}
+	var custom struct {
+		SafetyRatings []*genai.SafetyRating `json:"safetyRatings"`
+	}
+	if json.Unmarshal(data, &custom) != nil {
+		return nil
+	}
+	var blocked, rated []string
+	for _, rating := range custom.SafetyRatings {
+		if rating == nil || rating.Category == "" {
+			continue
+		}
+		rated = append(rated, string(rating.Category))
+		if rating.Blocked {
+			blocked = append(blocked, string(rating.Category))
+		}
+	}
+	if len(blocked) > 0 {
+		return blocked
+	}
+	return rated
+}
+
+// harmCategories and harmBlockThresholds are the safety settings the Gemini models take.
+var (
+	harmCategories = []genai.HarmCategory{
+		genai.HarmCategoryHarassment,
+		genai.HarmCategoryHateSpeech,
+		genai.HarmCategorySexuallyExplicit,
+		genai.HarmCategoryDangerousContent,
+		genai.HarmCategoryCivicIntegrity,
+	}
+	harmBlockThresholds = []genai.HarmBlockThreshold{
+		genai.HarmBlockThresholdBlockLowAndAbove,
+		genai.HarmBlockThresholdBlockMediumAndAbove,
+		genai.HarmBlockThresholdBlockOnlyHigh,
+		genai.HarmBlockThresholdBlockNone,
+		genai.HarmBlockThresholdOff,
+	}
+)
+
+// safetySettings returns the Gemini safety settings of thresholds by category, ordered by category.
+func safetySettings(thresholds map[string]string) ([]*genai.SafetySetting, error) {
+	var settings []*genai.SafetySetting
+	for _, category := range slices.Sorted(maps.Keys(thresholds)) {
+		threshold := thresholds[category]
+		if !slices.Contains(harmCategories, genai.HarmCategory(category)) {
+			return nil, fmt.Errorf("unknown harm category %q, the categories are %s", category, joinNames(harmCategories))
+		}
+		if !slices.Contains(harmBlockThresholds, genai.HarmBlockThreshold(threshold)) {
+			return nil, fmt.Errorf("unknown threshold %q of %s, the thresholds are %s", threshold, category, joinNames(harmBlockThresholds))
+		}
+		settings = append(settings, &genai.SafetySetting{
+			Category:  genai.HarmCategory(category),
+			Threshold: genai.HarmBlockThreshold(threshold),
+		})
+	}
+	return settings, nil
+}
+
+// ParseSafetySettings parses thresholds by category written as comma-separated CATEGORY=THRESHOLD
+// pairs, such as "HARM_CATEGORY_HARASSMENT=BLOCK_ONLY_HIGH", for ProviderConfig.SafetySettings.
+func ParseSafetySettings(text string) (map[string]string, error) {
+	thresholds := map[string]string{}
+	for pair := range strings.SplitSeq(text, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		category, threshold, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("the safety setting %q isn't a CATEGORY=THRESHOLD pair", pair)
+		}
+		thresholds[strings.TrimSpace(category)] = strings.TrimSpace(threshold)
+	}
+	if _, err := safetySettings(thresholds); err != nil {
+		return nil, err
+	}
+	return thresholds, nil
+}
+
+// joinNames joins the names with commas.
+func joinNames[T ~string](names []T) string {
+	texts := make([]string, len(names))
+	for i, name := range names {
+		texts[i] = string(name)
+	}
+	return strings.Join(texts, ", ")
+}
+
+// safetyMiddleware returns a model middleware adding the safety settings to the config of each
+// request. A config with safety settings of its own keeps them, and a config of another type than the
+// Gemini ones, which belongs to another provider, is left as is.
+func safetyMiddleware(settings []*genai.SafetySetting) ai.ModelMiddleware {
+	return func(next ai.ModelFunc) ai.ModelFunc {
+		return func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+			withSettings := *req
+			withSettings.Config = configWithSafety(req.Config, settings)
+			return next(ctx, &withSettings, cb)
+		}
+	}
+}
+
+// configWithSafety returns a copy of the request config with the safety settings.
+func configWithSafety(config any, settings []*genai.SafetySetting) any {
+	switch config := config.(type) {
+	case nil:
+		return &genai.GenerateContentConfig{SafetySettings: settings}
+	case *genai.GenerateContentConfig:
+		withSettings := *config
+		if withSettings.SafetySettings == nil {
+			withSettings.SafetySettings = settings
+		}
+		return &withSettings
+	case genai.GenerateContentConfig:
+		if config.SafetySettings == nil {
+			config.SafetySettings = settings
+		}
+		return &config
+	case map[string]any:
+		if _, ok := config["safetySettings"]; ok {
+			return config
+		}
+		withSettings := maps.Clone(config)
+		withSettings["safetySettings"] = settings
+		return withSettings
+	default:
+		return config
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// createBlockedResponse returns a response blocked by the safety filters, with the ratings in the
+// custom data as the Gemini API reports them.
+func createBlockedResponse(ratings ...map[string]any) *ai.ModelResponse {
+	resp := createTextResponse("", string(ai.FinishReasonBlocked))
+	resp.Message.Content = nil
+	if len(ratings) > 0 {
+		resp.Custom = map[string]any{"safetyRatings": ratings}
+	}
+	return resp
+}
+
+func TestRunAgent_ContentBlocked(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []*ai.ModelResponse
+		expected  *ErrContentBlocked
+		message   string
+	}{
+		{
+			name: "first response",
+			responses: []*ai.ModelResponse{createBlockedResponse(
+				map[string]any{"category": "HARM_CATEGORY_HARASSMENT", "probability": "MEDIUM", "blocked": true},
+				map[string]any{"category": "HARM_CATEGORY_HATE_SPEECH", "probability": "NEGLIGIBLE"},
+			)},
+			expected: &ErrContentBlocked{Categories: []string{"HARM_CATEGORY_HARASSMENT"}},
+			message:  "the model's response was blocked for HARM_CATEGORY_HARASSMENT",
+		},
+		{
+			name: "after an answer",
+			responses: []*ai.ModelResponse{
+				createInterruptedResponse(createToolRequestPart("askQuestion", "How old are the children?", []string{"8 and 11"})),
+				func() *ai.ModelResponse {
+					resp := createBlockedResponse(map[string]any{"category": "HARM_CATEGORY_DANGEROUS_CONTENT", "probability": "HIGH"})
+					resp.FinishMessage = "the response may be unsafe"
+					return resp
+				}(),
+			},
+			expected: &ErrContentBlocked{Categories: []string{"HARM_CATEGORY_DANGEROUS_CONTENT"}, Message: "the response may be unsafe"},
+			message:  "the model's response was blocked for HARM_CATEGORY_DANGEROUS_CONTENT: the response may be unsafe",
+		},
+		{
+			name:      "without ratings",
+			responses: []*ai.ModelResponse{createBlockedResponse()},
+			expected:  &ErrContentBlocked{},
+			message:   "the model's response was blocked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGen := NewMockGenerator(tt.responses, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")})
+
+			text, err := RunAgent(context.Background(), &Options{
+				generator:  mockGen,
+				userPrompt: "Suggest a gift.",
+				responseHandler: &InterruptionHandler{
+					generator: mockGen,
+					UserInteraction: func(context.Context, QuestionInput) (string, error) {
+						return "8 and 11", nil
+					},
+				},
+			})
+
+			assert.Empty(t, text)
+			var blocked *ErrContentBlocked
+			require.ErrorAs(t, err, &blocked)
+			assert.Equal(t, tt.expected, blocked)
+			assert.EqualError(t, err, tt.message)
+		})
+	}
+}
+
+func TestConfigWithSafety(t *testing.T) {
+	settings := []*genai.SafetySetting{{Category: genai.HarmCategoryHarassment, Threshold: genai.HarmBlockThresholdBlockOnlyHigh}}
+	own := []*genai.SafetySetting{{Category: genai.HarmCategoryHateSpeech, Threshold: genai.HarmBlockThresholdBlockNone}}
+
+	tests := []struct {
+		name     string
+		config   any
+		expected any
+	}{
+		{name: "no config", config: nil, expected: &genai.GenerateContentConfig{SafetySettings: settings}},
+		{name: "map", config: map[string]any{"maxOutputTokens": 80}, expected: map[string]any{"maxOutputTokens": 80, "safetySettings": settings}},
+		{name: "map with safety settings", config: map[string]any{"safetySettings": own}, expected: map[string]any{"safetySettings": own}},
+		{name: "gemini config", config: &genai.GenerateContentConfig{Temperature: genai.Ptr[float32](0)}, expected: &genai.GenerateContentConfig{Temperature: genai.Ptr[float32](0), SafetySettings: settings}},
+		{name: "gemini config with safety settings", config: &genai.GenerateContentConfig{SafetySettings: own}, expected: &genai.GenerateContentConfig{SafetySettings: own}},
+		{name: "gemini config value", config: genai.GenerateContentConfig{}, expected: &genai.GenerateContentConfig{SafetySettings: settings}},
+		{name: "config of another provider", config: &ai.GenerationCommonConfig{Temperature: 0.2}, expected: &ai.GenerationCommonConfig{Temperature: 0.2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, configWithSafety(tt.config, settings))
+		})
+	}
+}
+
+func TestConfigWithSafety_KeepsConfig(t *testing.T) {
+	config := map[string]any{"maxOutputTokens": 80}
+	gemini := &genai.GenerateContentConfig{}
+	settings := []*genai.SafetySetting{{Category: genai.HarmCategoryHarassment, Threshold: genai.HarmBlockThresholdBlockOnlyHigh}}
+
+	configWithSafety(config, settings)
+	configWithSafety(gemini, settings)
+
+	assert.Equal(t, map[string]any{"maxOutputTokens": 80}, config, "the config of the call isn't changed")
+	assert.Nil(t, gemini.SafetySettings)
+}
+
+func TestGenkitGenerator_SafetySettings(t *testing.T) {
+	var configs []any
+	g, _ := countingModel(func(req *ai.ModelRequest) *ai.ModelResponse {
+		configs = append(configs, req.Config)
+		return &ai.ModelResponse{Message: ai.NewModelTextMessage("A kite"), FinishReason: ai.FinishReasonStop}
+	})
+	settings := []*genai.SafetySetting{{Category: genai.HarmCategoryHarassment, Threshold: genai.HarmBlockThresholdBlockOnlyHigh}}
+	generator := &GenkitGenerator{AIClient: g, SafetySettings: settings}
+
+	_, err := generator.Generate(context.Background(), ai.WithPrompt("Suggest a gift."))
+	require.NoError(t, err)
+	_, err = generator.Generate(context.Background(), ai.WithPrompt("Suggest a gift."), ai.WithConfig(map[string]any{"maxOutputTokens": 80}))
+	require.NoError(t, err)
+
+	assert.Equal(t, []any{
+		&genai.GenerateContentConfig{SafetySettings: settings},
+		map[string]any{"maxOutputTokens": 80, "safetySettings": settings},
+	}, configs)
+}
+
+func TestParseSafetySettings(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected map[string]string
+		err      string
+	}{
+		{text: "", expected: map[string]string{}},
+		{
+			text:     "HARM_CATEGORY_HARASSMENT=BLOCK_ONLY_HIGH, HARM_CATEGORY_DANGEROUS_CONTENT=BLOCK_NONE",
+			expected: map[string]string{"HARM_CATEGORY_HARASSMENT": "BLOCK_ONLY_HIGH", "HARM_CATEGORY_DANGEROUS_CONTENT": "BLOCK_NONE"},
+		},
+		{text: "HARM_CATEGORY_HARASSMENT", err: `the safety setting "HARM_CATEGORY_HARASSMENT" isn't a CATEGORY=THRESHOLD pair`},
+		{text: "HARASSMENT=BLOCK_NONE", err: `unknown harm category "HARASSMENT"`},
+		{text: "HARM_CATEGORY_HARASSMENT=NEVER", err: `unknown threshold "NEVER" of HARM_CATEGORY_HARASSMENT`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			thresholds, err := ParseSafetySettings(tt.text)
+
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, thresholds)
+		})
+	}
+}
+
+func TestGenkitGenerator_StructuredOutputBlocked(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(generator *GenkitGenerator) error
+	}{
+		{name: "GenerateJSON", call: func(generator *GenkitGenerator) error {
+			var count int
+			return generator.GenerateJSON(context.Background(), "How many gifts?", nil, &count)
+		}},
+		{name: "GenerateBool", call: func(generator *GenkitGenerator) error {
+			_, err := generator.GenerateBool(context.Background(), "Is the conversation finished?", nil)
+			return err
+		}},
+		{name: "GenerateChecklist", call: func(generator *GenkitGenerator) error {
+			_, err := generator.GenerateChecklist(context.Background(), []string{"Is the budget known?"}, nil)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, calls := countingModel(func(*ai.ModelRequest) *ai.ModelResponse {
+				return createBlockedResponse(map[string]any{"category": "HARM_CATEGORY_HARASSMENT", "probability": "HIGH", "blocked": true})
+			})
+
+			err := tt.call(&GenkitGenerator{AIClient: g})
+
+			var blocked *ErrContentBlocked
+			require.ErrorAs(t, err, &blocked)
+			assert.Equal(t, []string{"HARM_CATEGORY_HARASSMENT"}, blocked.Categories)
+			assert.Equal(t, int64(1), calls.Load(), "a blocked response isn't asked for again")
+		})
+	}
+}
@@ -2,46 +2,157 @@ package main
 
 import (
 	"context"
-	"errors"
 
 	"github.com/firebase/genkit/go/ai"
 )
 
+// ConversationLoopHandler keeps the conversation going until the model provides a final answer.
+// Each model response is first passed to the inner handler; if the conversation is not finished yet,
+// the model's text is shown to the user as a follow-up question and generation continues with the answer.
 type ConversationLoopHandler struct {
-	generator           Generator
-	validationPrompt    string
-	interruptionHandler InterruptionHandler
+	generator        Generator
+	validationPrompt string
+	inner            ResponseHandler
+	interactor       Interactor
+	// validationPromptRef, when set, is the validation prompt loaded from a .prompt file, in place of
+	// validationPrompt.
+	validationPromptRef ai.Prompt
+	// requiredFields are yes/no questions asked along with the validation prompt, all of which must be
+	// answered yes for the conversation to be finished.
+	requiredFields []string
+	// toolNames lists the tools offered on follow-up generations. The question tool is always included.
+	toolNames []string
+	// questionTool is the name of the question tool, askQuestion when empty.
+	questionTool string
+	// historyBudget is how many tokens the history of a follow-up generation may take, with no limit
+	// when 0.
+	historyBudget int
+}
+
+// NewConversationLoopHandler creates a ConversationLoopHandler that wraps the default InterruptionHandler.
+// The same user interaction is used for tool interrupts and for follow-up questions,
+// and the given tools are offered to the model on every generation made by either handler.
+func NewConversationLoopHandler(generator Generator, validationPrompt string, userInteraction UserInteractionFunc, toolNames ...string) *ConversationLoopHandler {
+	return NewInteractorConversationLoopHandler(generator, validationPrompt, InteractorFunc(userInteraction), toolNames...)
+}
+
+// NewInteractorConversationLoopHandler is like NewConversationLoopHandler but asks through an Interactor.
+func NewInteractorConversationLoopHandler(generator Generator, validationPrompt string, interactor Interactor, toolNames ...string) *ConversationLoopHandler {
+	return &ConversationLoopHandler{
+		generator:        generator,
+		validationPrompt: validationPrompt,
+		inner: &InterruptionHandler{
+			generator:  generator,
+			Interactor: interactor,
+			toolNames:  toolNames,
+		},
+		interactor: interactor,
+		toolNames:  toolNames,
+	}
+}
+
+// UseValidationPromptRef asks whether the conversation is finished with a prompt loaded from a .prompt
+// file, such as with LoadPromptFiles, in place of the validation prompt text. It's rendered with
+// the vars of the run as its input.
+func (cv *ConversationLoopHandler) UseValidationPromptRef(prompt ai.Prompt) {
+	cv.validationPromptRef = prompt
+}
+
+// RequireFields keeps the conversation going until the model also answers yes to each of the
+// questions about the details the answer needs, such as "Is the budget known?". The questions are asked
+// along with the validation prompt in a single GenerateChecklist call.
+func (cv *ConversationLoopHandler) RequireFields(questions ...string) {
+	cv.requiredFields = questions
+}
+
+// SetQuestionTool sets the name of the question tool, such as one defined by DefineAskQuestionTool with
+// another name, which is offered on follow-up generations and, when the inner handler is an
+// InterruptionHandler, answered by it.
+func (cv *ConversationLoopHandler) SetQuestionTool(name string) {
+	cv.questionTool = name
+	if inner, ok := cv.inner.(*InterruptionHandler); ok {
+		inner.QuestionTool = name
+	}
+}
+
+// SetHistoryBudget keeps the history of the follow-up generations within the tokens, as counted by the
+// generator, by dropping its oldest messages but the request. A budget of 0 keeps the whole history.
+func (cv *ConversationLoopHandler) SetHistoryBudget(tokens int) {
+	cv.historyBudget = tokens
+}
+
+// SetQuestionCache answers the questions answered before from the cache, when the inner handler is an
+// InterruptionHandler.
+func (cv *ConversationLoopHandler) SetQuestionCache(cache QuestionCache) {
+	if inner, ok := cv.inner.(*InterruptionHandler); ok {
+		inner.QuestionCache = cache
+	}
+}
+
+// RegisterToolHandler answers the interrupts of the named tool with the handler, when the inner handler
+// is an InterruptionHandler.
+func (cv *ConversationLoopHandler) RegisterToolHandler(name string, handler ToolHandler) {
+	if inner, ok := cv.inner.(*InterruptionHandler); ok {
+		inner.RegisterToolHandler(name, handler)
+	}
 }
 
 func (cv *ConversationLoopHandler) handleResponse(ctx context.Context, response *ai.ModelResponse) (*ai.ModelResponse, error) {
-	askQuestion := cv.generator.LookupTool("askQuestion")
-	if askQuestion == nil {
-		return nil, errors.New("askQuestion tool not found")
+	questionTool := cv.questionTool
+	if questionTool == "" {
+		questionTool = defaultAskQuestionToolName
+	}
+	tools, err := continuationTools(ctx, cv.generator, withQuestionTool(questionTool, cv.toolNames))
+	if err != nil {
+		return nil, err
+	}
+	validationPrompt := cv.validationPrompt
+	vars, hasVars := promptVars(ctx)
+	switch {
+	case cv.validationPromptRef != nil:
+		if validationPrompt, err = renderPromptRef(ctx, "validation prompt", cv.validationPromptRef, vars); err != nil {
+			return nil, err
+		}
+	case hasVars:
+		if validationPrompt, err = renderPromptTemplate("validation prompt", validationPrompt, vars); err != nil {
+			return nil, err
+		}
 	}
 
-	var err error
 	var hasMoreQuestions bool = true
 	for hasMoreQuestions {
-		response, err = cv.interruptionHandler.handleResponse(ctx, response)
+		response, err = cv.inner.handleResponse(ctx, response)
 		if err != nil {
 			return nil, err
 		}
 
-		isConversationFinished, err := cv.generator.GenerateBool(ctx,
-			cv.validationPrompt,
-			response.History(),
-		)
-
+		isConversationFinished, err := cv.isFinished(ctx, validationPrompt, response.History())
 		if err != nil {
 			return nil, err
 		}
 
 		hasMoreQuestions = !isConversationFinished
 		if hasMoreQuestions {
-			response, err = cv.generator.Generate(ctx,
-				ai.WithMessages(response.History()...),
-				ai.WithTools(askQuestion),
-				ai.WithPrompt(cv.interruptionHandler.UserInteraction(ctx, QuestionInput{Question: response.Text()})),
+			reply, err := cv.interactor.Ask(ctx, QuestionInput{Question: response.Text()})
+			answer := answerText(reply)
+			if err != nil {
+				steering, ok := steeringAnswer(err)
+				if !ok {
+					return nil, err
+				}
+				answer = steering
+			}
+
+			history := response.History()
+			if cv.historyBudget > 0 {
+				if history, err = trimHistory(ctx, cv.generator, history, ai.NewUserTextMessage(answer), cv.historyBudget); err != nil {
+					return nil, err
+				}
+			}
+			response, err = generateResponse(ctx, cv.generator,
+				ai.WithMessages(history...),
+				ai.WithTools(tools...),
+				ai.WithPrompt(answer),
 			)
 			if err != nil {
 				return nil, err
@@ -51,3 +162,22 @@ func (cv *ConversationLoopHandler) handleResponse(ctx context.Context, response
 
 	return response, nil
 }
+
+// isFinished asks whether the conversation is finished with the validation prompt, along with the
+// required fields when there are any.
+func (cv *ConversationLoopHandler) isFinished(ctx context.Context, validationPrompt string, history []*ai.Message) (bool, error) {
+	if len(cv.requiredFields) == 0 {
+		return cv.generator.GenerateBool(ctx, validationPrompt, history)
+	}
+	questions := append([]string{validationPrompt}, cv.requiredFields...)
+	answers, err := cv.generator.GenerateChecklist(ctx, questions, history)
+	if err != nil {
+		return false, err
+	}
+	for _, question := range questions {
+		if !answers[question] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
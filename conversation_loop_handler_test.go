@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/firebase/genkit/go/ai"
@@ -21,18 +24,9 @@ func TestConversationLoopHandler_HandleResponse(t *testing.T) {
 		// GenerateBool returns true (finished) immediately
 		mockGen.boolResponses = []bool{true}
 
-		handler := &ConversationLoopHandler{
-			generator:           mockGen,
-			validationPrompt:    "Is finished?",
-			interruptionHandler: InterruptionHandler{generator: mockGen},
-		}
+		handler := NewConversationLoopHandler(mockGen, "Is finished?", nil)
 
-		// Mock InterruptionHandler to just return the response
-		// In a real scenario, InterruptionHandler might do more, but here we assume it passes through if no interrupts
-		// We need to make sure InterruptionHandler.handleResponse is called.
-		// Since InterruptionHandler struct is used directly, we can't easily mock it unless we change the struct to use an interface.
-		// However, InterruptionHandler logic is: if interrupted, handle it. If not, return response.
-		// So passing a non-interrupted response should be fine.
+		// The default InterruptionHandler passes a non-interrupted response through unchanged.
 
 		ctx := context.Background()
 		resp, err := handler.handleResponse(ctx, simpleResponse)
@@ -45,9 +39,7 @@ func TestConversationLoopHandler_HandleResponse(t *testing.T) {
 	t.Run("Conversation loops once", func(t *testing.T) {
 		// Initial response -> Loop check (false) -> Generate new response (with prompt from user) -> Loop check (true)
 
-		// We need to mock UserInteraction for the InterruptionHandler used inside ConversationLoopHandler
-		// But wait, ConversationLoopHandler uses `cv.interruptionHandler.UserInteraction`
-		// `InterruptionHandler` struct has `UserInteraction` field.
+		// The same user interaction answers tool interrupts and the loop's follow-up questions.
 
 		mockUserInteraction := func(ctx context.Context, input QuestionInput) (string, error) {
 			return "User Answer", nil
@@ -64,13 +56,61 @@ func TestConversationLoopHandler_HandleResponse(t *testing.T) {
 		// 2. Second check: true (finished)
 		mockGen.boolResponses = []bool{false, true}
 
+		handler := NewConversationLoopHandler(mockGen, "Is finished?", mockUserInteraction)
+
+		ctx := context.Background()
+		resp, err := handler.handleResponse(ctx, simpleResponse)
+
+		require.NoError(t, err)
+		assert.Equal(t, "Final Answer", resp.Text())
+		assert.Equal(t, 2, mockGen.boolCallIndex)
+		assert.Equal(t, 1, mockGen.callIndex) // One generation call inside the loop
+	})
+
+	t.Run("Follow-up generation offers all tools", func(t *testing.T) {
+		mockGen := NewMockGenerator(
+			[]*ai.ModelResponse{
+				createTextResponse("Final Answer", "stop"),
+			},
+			map[string]ai.Tool{
+				"askQuestion": createMockTool("askQuestion"),
+				"lookupPrice": createMockTool("lookupPrice"),
+			},
+		)
+		mockGen.boolResponses = []bool{false, true}
+
+		mockUserInteraction := func(ctx context.Context, input QuestionInput) (string, error) {
+			return "User Answer", nil
+		}
+		handler := NewConversationLoopHandler(mockGen, "Is finished?", mockUserInteraction, "askQuestion", "lookupPrice")
+
+		ctx := context.Background()
+		_, err := handler.handleResponse(ctx, simpleResponse)
+
+		require.NoError(t, err)
+		require.Len(t, mockGen.capturedCalls, 1)
+		assert.Equal(t, []string{"askQuestion", "lookupPrice"}, capturedToolNames(mockGen.capturedCalls[0].Options))
+	})
+
+	t.Run("Wraps a custom inner handler", func(t *testing.T) {
+		mockGen := NewMockGenerator(
+			[]*ai.ModelResponse{
+				createTextResponse("Final Answer", "stop"),
+			},
+			map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+		)
+		mockGen.boolResponses = []bool{false, true}
+
+		inner := &fakeResponseHandler{}
+		var questions []string
 		handler := &ConversationLoopHandler{
 			generator:        mockGen,
 			validationPrompt: "Is finished?",
-			interruptionHandler: InterruptionHandler{
-				generator:       mockGen,
-				UserInteraction: mockUserInteraction,
-			},
+			inner:            inner,
+			interactor: InteractorFunc(func(ctx context.Context, input QuestionInput) (string, error) {
+				questions = append(questions, input.Question)
+				return "User Answer", nil
+			}),
 		}
 
 		ctx := context.Background()
@@ -78,26 +118,121 @@ func TestConversationLoopHandler_HandleResponse(t *testing.T) {
 
 		require.NoError(t, err)
 		assert.Equal(t, "Final Answer", resp.Text())
-		assert.Equal(t, 2, mockGen.boolCallIndex)
-		assert.Equal(t, 1, mockGen.callIndex) // One generation call inside the loop
+		assert.Equal(t, []string{"Hello", "Final Answer"}, inner.seen)
+		assert.Equal(t, []string{"Hello"}, questions)
 	})
 
-	t.Run("Tool not found error", func(t *testing.T) {
+	t.Run("Inner handler error stops the loop", func(t *testing.T) {
 		mockGen := NewMockGenerator(
 			[]*ai.ModelResponse{},
-			map[string]ai.Tool{}, // No tools
+			map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
 		)
 
 		handler := &ConversationLoopHandler{
-			generator:           mockGen,
-			validationPrompt:    "Is finished?",
-			interruptionHandler: InterruptionHandler{generator: mockGen},
+			generator:        mockGen,
+			validationPrompt: "Is finished?",
+			inner:            &fakeResponseHandler{err: errors.New("inner failed")},
 		}
 
 		ctx := context.Background()
 		_, err := handler.handleResponse(ctx, simpleResponse)
 
+		require.EqualError(t, err, "inner failed")
+		assert.Equal(t, 0, mockGen.boolCallIndex)
+	})
+
+	t.Run("Tool not found error", func(t *testing.T) {
+		mockGen := NewMockGenerator(
+			[]*ai.ModelResponse{},
+			map[string]ai.Tool{}, // No tools
+		)
+
+		handler := NewConversationLoopHandler(mockGen, "Is finished?", nil)
+
+		ctx := context.Background()
+		_, err := handler.handleResponse(ctx, simpleResponse)
+
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "askQuestion tool not found")
 	})
 }
+
+// fakeResponseHandler records the text of every response it receives and passes it through.
+type fakeResponseHandler struct {
+	seen []string
+	err  error
+}
+
+func (f *fakeResponseHandler) handleResponse(ctx context.Context, response *ai.ModelResponse) (*ai.ModelResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.seen = append(f.seen, response.Text())
+	return response, nil
+}
+
+// capturedToolNames extracts the names of the tools passed with ai.WithTools.
+func capturedToolNames(opts []ai.GenerateOption) []string {
+	var names []string
+	for _, opt := range opts {
+		v := reflect.ValueOf(opt)
+		if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		field := v.Elem().FieldByName("Tools")
+		if !field.IsValid() {
+			continue
+		}
+		for _, tool := range field.Interface().([]ai.ToolRef) {
+			names = append(names, tool.Name())
+		}
+	}
+	return names
+}
+
+func TestConversationLoopHandler_HistoryBudget(t *testing.T) {
+	// each message is 10 tokens as estimated, and the answer 3
+	request := ai.NewUserTextMessage(strings.Repeat("request ", 5))
+	firstQuestion := ai.NewModelTextMessage(strings.Repeat("question", 5))
+	firstAnswer := ai.NewUserTextMessage(strings.Repeat("answer  ", 5))
+	secondQuestion := ai.NewModelTextMessage(strings.Repeat("again?  ", 5))
+	response := &ai.ModelResponse{
+		Request:      &ai.ModelRequest{Messages: []*ai.Message{request, firstQuestion, firstAnswer}},
+		Message:      secondQuestion,
+		FinishReason: ai.FinishReasonStop,
+	}
+
+	tests := []struct {
+		name     string
+		budget   int
+		expected []*ai.Message
+	}{
+		{name: "no budget", budget: 0, expected: []*ai.Message{request, firstQuestion, firstAnswer, secondQuestion}},
+		{name: "within budget", budget: 43, expected: []*ai.Message{request, firstQuestion, firstAnswer, secondQuestion}},
+		{name: "over budget", budget: 25, expected: []*ai.Message{request, secondQuestion}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGen := NewMockGenerator(
+				[]*ai.ModelResponse{createTextResponse("Final Answer", "stop")},
+				map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+			)
+			mockGen.boolResponses = []bool{false, true}
+			handler := NewConversationLoopHandler(mockGen, "Is finished?", func(context.Context, QuestionInput) (string, error) {
+				return "User Answer", nil
+			})
+			handler.SetHistoryBudget(tt.budget)
+
+			_, err := handler.handleResponse(context.Background(), response)
+
+			require.NoError(t, err)
+			require.Len(t, mockGen.capturedCalls, 1)
+			_, messages := capturedOptionPrompts(t, mockGen.capturedCalls[0].Options)
+			assert.Equal(t, tt.expected, messages)
+			if tt.budget == 0 {
+				assert.Empty(t, mockGen.countedMessages, "the tokens aren't counted without a budget")
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"log"
+	"maps"
+	"sync"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// unknownModel is the model the calls to models without a price are counted under.
+const unknownModel = "unknown"
+
+// ModelPrice is the price of a model in dollars per 1000 tokens.
+type ModelPrice struct {
+	InputPricePer1K  float64 `json:"inputPricePer1k"`
+	OutputPricePer1K float64 `json:"outputPricePer1k"`
+}
+
+// ModelCost is the cost of the calls to a model.
+type ModelCost struct {
+	InputTokens  int64 `json:"inputTokens"`
+	OutputTokens int64 `json:"outputTokens"`
+	// Dollars is zero for the models without a price.
+	Dollars float64 `json:"dollars"`
+}
+
+// Cost is the cost of model calls in dollars, in total and by model. The calls to models without a
+// price are counted under "unknown", with their tokens but no cost.
+type Cost struct {
+	Dollars float64              `json:"dollars"`
+	ByModel map[string]ModelCost `json:"byModel,omitempty"`
+}
+
+// costCounter adds up the cost of concurrent calls.
+type costCounter struct {
+	mu   sync.Mutex
+	cost Cost
+	// tracked is set once a CostTracker counted a call, even one without usage.
+	tracked bool
+}
+
+func (c *costCounter) add(model string, call ModelCost) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tracked = true
+	if c.cost.ByModel == nil {
+		c.cost.ByModel = map[string]ModelCost{}
+	}
+	total := c.cost.ByModel[model]
+	total.InputTokens += call.InputTokens
+	total.OutputTokens += call.OutputTokens
+	total.Dollars += call.Dollars
+	c.cost.ByModel[model] = total
+	c.cost.Dollars += call.Dollars
+}
+
+func (c *costCounter) snapshot() (Cost, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Cost{Dollars: c.cost.Dollars, ByModel: maps.Clone(c.cost.ByModel)}, c.tracked
+}
+
+// CostScope counts the cost of the model calls made with a context returned by WithCostScope, such as
+// the calls of a single run, when they go through a CostTracker.
+type CostScope struct {
+	counter costCounter
+	// parent is the scope of the context the scope was made from, which counts the calls too.
+	parent *CostScope
+}
+
+type costScopeKey struct{}
+
+// WithCostScope returns a context whose model calls are counted by the returned scope, and by the
+// scopes of ctx.
+func WithCostScope(ctx context.Context) (context.Context, *CostScope) {
+	parent, _ := ctx.Value(costScopeKey{}).(*CostScope)
+	scope := &CostScope{parent: parent}
+	return context.WithValue(ctx, costScopeKey{}, scope), scope
+}
+
+// Snapshot returns the cost counted so far. It reports false when no CostTracker counted a call, for
+// example because there is none between the caller and the model.
+func (s *CostScope) Snapshot() (Cost, bool) {
+	return s.counter.snapshot()
+}
+
+// CostTracker is a Generator converting the token usage of the model calls of another one into dollars,
+// with the price of each model. A single CostTracker is safe to share between concurrent sessions.
+type CostTracker struct {
+	inner   Generator
+	prices  map[string]ModelPrice
+	counter costCounter
+	// warned holds the models without a price already logged.
+	warned sync.Map
+}
+
+// NewCostTracker returns a Generator calling inner and counting the cost of the calls, in total and by
+// CostScope, with prices by model name, such as "googleai/gemini-2.5-flash". The model of a call is
+// known when it goes through a GenkitGenerator whose Model is set; the calls to other models are
+// counted under "unknown", and logged once by model.
+func NewCostTracker(inner Generator, prices map[string]ModelPrice) *CostTracker {
+	return &CostTracker{inner: inner, prices: prices}
+}
+
+// Generate calls the inner Generate and counts the cost of the response.
+func (c *CostTracker) Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	resp, err := c.inner.Generate(ctx, opts...)
+	if resp != nil {
+		c.record(ctx, responseModel(resp), resp.Usage)
+	}
+	return resp, err
+}
+
+// GenerateStream calls the inner GenerateStream and counts the cost of the response.
+func (c *CostTracker) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	resp, err := c.inner.GenerateStream(ctx, cb, opts...)
+	if resp != nil {
+		c.record(ctx, responseModel(resp), resp.Usage)
+	}
+	return resp, err
+}
+
+// GenerateBool calls the inner GenerateBool, counting the cost of the usage it reports.
+func (c *CostTracker) GenerateBool(ctx context.Context, prompt string, history []*ai.Message) (bool, error) {
+	return c.inner.GenerateBool(c.recording(ctx), prompt, history)
+}
+
+// GenerateChecklist calls the inner GenerateChecklist, counting the cost of the usage it reports.
+func (c *CostTracker) GenerateChecklist(ctx context.Context, questions []string, history []*ai.Message) (map[string]bool, error) {
+	return c.inner.GenerateChecklist(c.recording(ctx), questions, history)
+}
+
+// GenerateJSON calls the inner GenerateJSON, counting the cost of the usage it reports.
+func (c *CostTracker) GenerateJSON(ctx context.Context, system string, history []*ai.Message, out any) error {
+	return c.inner.GenerateJSON(c.recording(ctx), system, history, out)
+}
+
+// LookupTool looks up the tool with the inner generator.
+func (c *CostTracker) LookupTool(name string) ai.Tool {
+	return c.inner.LookupTool(name)
+}
+
+// CountTokens counts the tokens with the inner generator; counting tokens isn't priced.
+func (c *CostTracker) CountTokens(ctx context.Context, messages []*ai.Message) (TokenCount, error) {
+	return c.inner.CountTokens(ctx, messages)
+}
+
+// Embed embeds the text with the inner generator; embeddings aren't priced.
+func (c *CostTracker) Embed(ctx context.Context, text string) ([]float32, error) {
+	return c.inner.Embed(ctx, text)
+}
+
+// Ping pings the inner generator; a ping isn't priced.
+func (c *CostTracker) Ping(ctx context.Context) error {
+	return ping(ctx, c.inner)
+}
+
+// Snapshot returns the cost of all the calls counted so far.
+func (c *CostTracker) Snapshot() Cost {
+	cost, _ := c.counter.snapshot()
+	return cost
+}
+
+// recording returns ctx with a recorder counting the cost of the usage reported by the inner generator.
+func (c *CostTracker) recording(ctx context.Context) context.Context {
+	return withUsageRecorder(ctx, func(model string, usage *ai.GenerationUsage) {
+		c.record(ctx, model, usage)
+	})
+}
+
+// record counts the cost of the usage in total and in the scopes of ctx.
+func (c *CostTracker) record(ctx context.Context, model string, usage *ai.GenerationUsage) {
+	var call ModelCost
+	if usage != nil {
+		call.InputTokens, call.OutputTokens = int64(usage.InputTokens), int64(usage.OutputTokens)
+	}
+	if price, ok := c.prices[model]; ok {
+		call.Dollars = float64(call.InputTokens)/1000*price.InputPricePer1K + float64(call.OutputTokens)/1000*price.OutputPricePer1K
+	} else {
+		if _, warned := c.warned.LoadOrStore(model, true); !warned {
+			log.Printf("no price for the model %q, its calls are counted as %q without a cost", model, unknownModel)
+		}
+		model = unknownModel
+	}
+
+	c.counter.add(model, call)
+	scope, _ := ctx.Value(costScopeKey{}).(*CostScope)
+	for ; scope != nil; scope = scope.parent {
+		scope.counter.add(model, call)
+	}
+}
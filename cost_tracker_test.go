@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPrices prices test/flash at $0.30 and $2.50 per 1000 input and output tokens.
+var testPrices = map[string]ModelPrice{"test/flash": {InputPricePer1K: 0.3, OutputPricePer1K: 2.5}}
+
+// pricedGenerator returns a GenkitGenerator calling the model, whose responses use 1000 input and 200
+// output tokens.
+func pricedGenerator(model, text string) *GenkitGenerator {
+	return &GenkitGenerator{Model: model, generate: func(context.Context, ...ai.GenerateOption) (*ai.ModelResponse, error) {
+		return withUsage(createTextResponse(text, "stop"), 1000, 200, 1200), nil
+	}}
+}
+
+// captureLog sends the standard logger to a buffer for the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	var out bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&out)
+	t.Cleanup(func() { log.SetOutput(previous) })
+	return &out
+}
+
+func TestCostTracker(t *testing.T) {
+	tracker := NewCostTracker(pricedGenerator("test/flash", trueAnswer), testPrices)
+	ctx, run := WithCostScope(context.Background())
+
+	_, err := tracker.Generate(ctx)
+	require.NoError(t, err)
+	finished, err := tracker.GenerateBool(ctx, "Is the conversation finished?", nil)
+	require.NoError(t, err)
+	assert.True(t, finished)
+	_, err = tracker.Generate(context.Background())
+	require.NoError(t, err)
+
+	cost, ok := run.Snapshot()
+	require.True(t, ok)
+	assert.InDelta(t, 1.6, cost.Dollars, 1e-9, "(1000 × 0.3 + 200 × 2.5) / 1000 per call")
+	assert.Equal(t, int64(2000), cost.ByModel["test/flash"].InputTokens)
+	assert.Equal(t, int64(400), cost.ByModel["test/flash"].OutputTokens)
+	assert.InDelta(t, 2.4, tracker.Snapshot().Dollars, 1e-9, "the totals count the calls of every run")
+}
+
+func TestCostTracker_UnknownModel(t *testing.T) {
+	out := captureLog(t)
+	tracker := NewCostTracker(pricedGenerator("test/pro", "A kite"), testPrices)
+
+	for range 2 {
+		_, err := tracker.Generate(context.Background())
+		require.NoError(t, err)
+	}
+
+	cost := tracker.Snapshot()
+	assert.Zero(t, cost.Dollars)
+	assert.Equal(t, map[string]ModelCost{"unknown": {InputTokens: 2000, OutputTokens: 400}}, cost.ByModel)
+	assert.Equal(t, 1, strings.Count(out.String(), `no price for the model "test/pro"`), "the model is logged once")
+}
+
+func TestCostScope_NoTracker(t *testing.T) {
+	ctx, run := WithCostScope(context.Background())
+
+	_, err := pricedGenerator("test/flash", "A kite").Generate(ctx)
+
+	require.NoError(t, err)
+	_, ok := run.Snapshot()
+	assert.False(t, ok)
+}
+
+func TestClarifyingAgentFlow_Cost(t *testing.T) {
+	for _, tracked := range []bool{false, true} {
+		resp := withUsage(createTextResponse("A LEGO set", "stop"), 1000, 200, 1200)
+		resp.Message.Metadata = map[string]any{modelMetadataKey: "test/flash"}
+		var generator Generator = NewMockGenerator([]*ai.ModelResponse{resp}, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")})
+		if tracked {
+			generator = NewCostTracker(generator, testPrices)
+		}
+
+		result, err := newClarifyingAgentFlow(generator).run(context.Background(), ClarifyingAgentInput{UserPrompt: "Suggest a gift."})
+
+		require.NoError(t, err)
+		if !tracked {
+			assert.Nil(t, result.Cost, "the cost is left out without a CostTracker")
+			continue
+		}
+		require.NotNil(t, result.Cost)
+		assert.InDelta(t, 0.8, result.Cost.Dollars, 1e-9)
+		assert.Equal(t, int64(1000), result.Cost.ByModel["test/flash"].InputTokens)
+	}
+}
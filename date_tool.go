@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineDateTool.
+const (
+	defaultDateToolName        = "askDate"
+	defaultDateToolDescription = "use this to ask the user for a date, such as when the gifts need to arrive; the response is the date in ISO-8601 format"
+)
+
+// dateExamples is shown when an answer isn't understood as a date.
+const dateExamples = "expected a date such as 2025-12-24, 24/12, 24 December, tomorrow, next Friday or in 3 days"
+
+// dateLayouts are the layouts of absolute dates, day before month where it is ambiguous.
+var dateLayouts = []string{"2006-01-02", "02/01/2006", "2/1/2006", "02.01.2006", "2.1.2006", "2 January 2006", "2 Jan 2006", "January 2 2006", "Jan 2 2006"}
+
+// yearlessDateLayouts are the layouts of dates without a year, which are the next such day.
+var yearlessDateLayouts = []string{"02/01", "2/1", "02.01", "2.1", "2 January", "2 Jan", "January 2", "Jan 2"}
+
+// dateTimeLayouts are the layouts of a date with a time of day.
+var dateTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04"}
+
+// relativeDayPattern matches answers such as "in 3 days" or "in 2 weeks".
+var relativeDayPattern = regexp.MustCompile(`^in (\d+) (day|days|week|weeks)$`)
+
+// weekdayPattern matches answers such as "friday", "this friday" or "next friday".
+var weekdayPattern = regexp.MustCompile(`^(?:this |next |on )?(monday|tuesday|wednesday|thursday|friday|saturday|sunday)$`)
+
+// DateInput contains a question answered with a date.
+type DateInput struct {
+	Question string `json:"question" jsonschema:"description=a question such as 'When do the gifts need to arrive?'"`
+	Reason   string `json:"reason,omitempty" jsonschema:"description=a short explanation of why the question is asked"`
+}
+
+// DefineDateTool defines the date tool, named "askDate" by default, in the Genkit instance and returns
+// it. Its interrupts are answered by the handler returned by NewDateHandler, which must be registered
+// for the tool name with RegisterToolHandler.
+func DefineDateTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultDateToolName, defaultDateToolDescription, opts)
+
+	return DefineInterruptTool[DateInput, string](g, config.name, config.description, WithInterruptMetadataKey("date"))
+}
+
+// dateHandler configures the handler returned by NewDateHandler.
+type dateHandler struct {
+	location *time.Location
+	// now is replaced in tests to resolve relative dates against a fixed day.
+	now func() time.Time
+}
+
+// DateOption configures the handler of the date tool.
+type DateOption func(*dateHandler)
+
+// WithDateLocation sets the time zone answers are read in and relative dates are counted from.
+// It defaults to the local time zone.
+func WithDateLocation(location *time.Location) DateOption {
+	return func(h *dateHandler) {
+		h.location = location
+	}
+}
+
+// NewDateHandler returns the ToolHandler of the date tool. Answers are read leniently: ISO dates, day
+// before month dates such as 24/12/2025 or 24.12, month names, dates with a time of day and phrases such
+// as today, tomorrow, next Friday or in 2 weeks. An answer that isn't understood is asked again with
+// examples. It responds with the date as YYYY-MM-DD, or as RFC 3339 when a time of day was given.
+func NewDateHandler(opts ...DateOption) ToolHandler {
+	h := &dateHandler{location: time.Local, now: time.Now}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h.handle
+}
+
+func (h *dateHandler) handle(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var date DateInput
+	if err := decodeToolInput(input, &date); err != nil {
+		return ToolResult{}, err
+	}
+	if date.Question == "" {
+		return ToolResult{}, errors.New("date tool called without a question")
+	}
+
+	parse := func(answer string) (any, error) {
+		return parseDate(answer, h.now().In(h.location))
+	}
+	value, err := askParsed(ctx, interactor, QuestionInput{Question: date.Question, Reason: date.Reason}, parse)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	return ToolResult{Output: value}, nil
+}
+
+// parseDate reads the answer as a date relative to now, in the location of now, and formats it as
+// ISO-8601.
+func parseDate(answer string, now time.Time) (string, error) {
+	text := strings.ToLower(strings.Join(strings.Fields(strings.NewReplacer(",", " ").Replace(answer)), " "))
+	text = strings.TrimSuffix(text, ".")
+	location := now.Location()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	switch text {
+	case "today":
+		return formatDate(today), nil
+	case "tomorrow":
+		return formatDate(today.AddDate(0, 0, 1)), nil
+	case "day after tomorrow", "the day after tomorrow":
+		return formatDate(today.AddDate(0, 0, 2)), nil
+	}
+	if match := relativeDayPattern.FindStringSubmatch(text); match != nil {
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			return "", errors.New(dateExamples)
+		}
+		if strings.HasPrefix(match[2], "week") {
+			n *= 7
+		}
+		return formatDate(today.AddDate(0, 0, n)), nil
+	}
+	if match := weekdayPattern.FindStringSubmatch(text); match != nil {
+		// the first such weekday after today
+		days := (weekdays[match[1]] - int(today.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return formatDate(today.AddDate(0, 0, days)), nil
+	}
+
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, strings.ToUpper(text), location); err == nil {
+			return t.Format(time.RFC3339), nil
+		}
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, text, location); err == nil {
+			return formatDate(t), nil
+		}
+	}
+	for _, layout := range yearlessDateLayouts {
+		t, err := time.ParseInLocation(layout, text, location)
+		if err != nil {
+			continue
+		}
+		t = time.Date(today.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+		if t.Before(today) {
+			t = t.AddDate(1, 0, 0)
+		}
+		return formatDate(t), nil
+	}
+	return "", errors.New(dateExamples)
+}
+
+// weekdays maps the lowercase weekday names to their numbers.
+var weekdays = map[string]int{
+	"sunday": 0, "monday": 1, "tuesday": 2, "wednesday": 3, "thursday": 4, "friday": 5, "saturday": 6,
+}
+
+// formatDate formats the day as ISO-8601 without a time of day.
+func formatDate(t time.Time) string {
+	return t.Format(time.DateOnly)
+}
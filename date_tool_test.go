@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	// a Wednesday
+	now := time.Date(2025, time.December, 10, 15, 30, 0, 0, berlin)
+
+	tests := []struct {
+		answer   string
+		expected string
+	}{
+		{answer: "2025-12-24", expected: "2025-12-24"},
+		{answer: "24/12/2025", expected: "2025-12-24"},
+		{answer: "3/1/2026", expected: "2026-01-03"},
+		{answer: "24.12.2025", expected: "2025-12-24"},
+		{answer: "24 December 2025", expected: "2025-12-24"},
+		{answer: "December 24, 2025", expected: "2025-12-24"},
+		{answer: "24 dec 2025", expected: "2025-12-24"},
+		{answer: "24/12", expected: "2025-12-24"},
+		{answer: "24 December", expected: "2025-12-24"},
+		{answer: "5 Jan", expected: "2026-01-05"},
+		{answer: "2025-12-24 18:00", expected: "2025-12-24T18:00:00+01:00"},
+		{answer: "2025-12-24T18:00:00Z", expected: "2025-12-24T18:00:00Z"},
+		{answer: "today", expected: "2025-12-10"},
+		{answer: "Tomorrow.", expected: "2025-12-11"},
+		{answer: "the day after tomorrow", expected: "2025-12-12"},
+		{answer: "next Friday", expected: "2025-12-12"},
+		{answer: "wednesday", expected: "2025-12-17"},
+		{answer: "in 3 days", expected: "2025-12-13"},
+		{answer: "in 2  weeks", expected: "2025-12-24"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.answer, func(t *testing.T) {
+			date, err := parseDate(tt.answer, now)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, date)
+		})
+	}
+}
+
+func TestParseDate_Failure(t *testing.T) {
+	for _, answer := range []string{"soon", "", "31/02/2025", "in a week", "24-12"} {
+		t.Run(answer, func(t *testing.T) {
+			_, err := parseDate(answer, time.Date(2025, time.December, 10, 0, 0, 0, 0, time.UTC))
+
+			assert.EqualError(t, err, dateExamples)
+		})
+	}
+}
+
+func TestDateHandler_ThroughToolHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askDate", map[string]any{"question": "When do the gifts need to arrive?"})),
+			createTextResponse("Order by the 20th...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askDate": createMockTool("askDate")},
+	)
+	var out bytes.Buffer
+	terminal := NewTerminalReader(ctx, strings.NewReader("before christmas\n24/12\n"), WithAnswerTimeout(time.Second), WithOutput(&out), WithWrapping(false))
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	dates := NewDateHandler(WithDateLocation(tokyo))
+	handler := &InterruptionHandler{generator: mockGen, Interactor: terminal}
+	handler.RegisterToolHandler("askDate", dates)
+
+	_, err = RunAgent(ctx, &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Regexp(t, `^\d{4}-12-24$`, parts[0].ToolResponse.Output)
+	assert.Contains(t, out.String(), "Invalid answer: "+dateExamples+"\n")
+}
+
+func TestDateHandler_Location(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	h := &dateHandler{location: tokyo, now: func() time.Time {
+		// still the 10th in UTC, already the 11th in Tokyo
+		return time.Date(2025, time.December, 10, 20, 0, 0, 0, time.UTC)
+	}}
+
+	result, err := h.handle(context.Background(), &sequenceInteractor{answers: []Answer{{Value: "tomorrow"}}}, map[string]any{"question": "When?"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "2025-12-12", result.Output)
+}
+
+func TestDefineDateTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	tool := DefineDateTool(g)
+	_, err := tool.RunRaw(context.Background(), map[string]any{"question": "When?"})
+
+	assert.Equal(t, "askDate", tool.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, DateInput{Question: "When?"}, metadata["date"])
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// defaultDialogTitle is the title of the dialogs unless configured otherwise.
+const defaultDialogTitle = "Assistant"
+
+// maxDialogButtons is how many buttons an AppleScript dialog can have; more choices are shown as a list.
+const maxDialogButtons = 3
+
+// ErrNoDialogTool is returned by NewDialogInteractor when the platform's dialog tool isn't installed.
+var ErrNoDialogTool = errors.New("no dialog tool is available")
+
+// DialogRunner runs the external dialog tools.
+type DialogRunner interface {
+	// LookPath reports where the tool is installed, as exec.LookPath.
+	LookPath(file string) (string, error)
+	// Run runs the command and returns its trimmed standard output and exit code. The error is only set
+	// when the command couldn't be run at all.
+	Run(ctx context.Context, name string, args ...string) (string, int, error)
+}
+
+// execRunner runs the dialog tools with os/exec.
+type execRunner struct{}
+
+func (execRunner) LookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+func (execRunner) Run(ctx context.Context, name string, args ...string) (string, int, error) {
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return strings.TrimSpace(string(out)), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	return strings.TrimSpace(string(out)), 0, nil
+}
+
+// dialogTools maps the platforms to the tool showing their native dialogs.
+var dialogTools = map[string]string{
+	"linux":   "zenity",
+	"freebsd": "zenity",
+	"darwin":  "osascript",
+	"windows": "powershell",
+}
+
+// DialogInteractor asks questions in native dialogs, for an agent running as a background desktop helper.
+// It uses zenity on Linux, AppleScript on macOS and Windows Forms through PowerShell on Windows.
+// Choices are rendered as buttons, or a list when there are too many for the platform, and free-text
+// questions as an entry field. Dismissing a dialog aborts the conversation.
+type DialogInteractor struct {
+	runner DialogRunner
+	goos   string
+	title  string
+}
+
+// DialogOption configures a DialogInteractor.
+type DialogOption func(*DialogInteractor)
+
+// WithDialogTitle sets the title of the dialogs.
+func WithDialogTitle(title string) DialogOption {
+	return func(d *DialogInteractor) {
+		d.title = title
+	}
+}
+
+// WithDialogRunner runs the dialog tools through the runner instead of os/exec.
+func WithDialogRunner(runner DialogRunner) DialogOption {
+	return func(d *DialogInteractor) {
+		d.runner = runner
+	}
+}
+
+// NewDialogInteractor creates a DialogInteractor for the current platform.
+// It fails with ErrNoDialogTool when the platform's dialog tool isn't installed.
+func NewDialogInteractor(opts ...DialogOption) (*DialogInteractor, error) {
+	d := &DialogInteractor{runner: execRunner{}, goos: runtime.GOOS, title: defaultDialogTitle}
+	for _, opt := range opts {
+		opt(d)
+	}
+	tool, ok := dialogTools[d.goos]
+	if !ok {
+		return nil, fmt.Errorf("%w on %s", ErrNoDialogTool, d.goos)
+	}
+	if _, err := d.runner.LookPath(tool); err != nil {
+		return nil, fmt.Errorf("%w: %s is not installed", ErrNoDialogTool, tool)
+	}
+	return d, nil
+}
+
+// NewDialogOrTerminal returns a DialogInteractor configured with dialogOpts, or a TerminalReader on stdin
+// configured with terminalOpts when no dialog tool is available.
+func NewDialogOrTerminal(ctx context.Context, dialogOpts []DialogOption, terminalOpts ...TerminalOption) Interactor {
+	dialog, err := NewDialogInteractor(dialogOpts...)
+	if err != nil {
+		return NewTerminalReader(ctx, os.Stdin, terminalOpts...)
+	}
+	return dialog
+}
+
+// Ask shows the question and returns the selected choice or the entered text. An empty entry selects the
+// default or shows the dialog again. It fails with ErrConversationAborted when the dialog is dismissed.
+func (d *DialogInteractor) Ask(ctx context.Context, input QuestionInput) (Answer, error) {
+	name, args := d.command(input)
+	for {
+		out, code, err := d.runner.Run(ctx, name, args...)
+		if err != nil {
+			return Answer{}, fmt.Errorf("failed to show the dialog: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return Answer{}, err
+		}
+		if d.dismissed(out, code) {
+			return Answer{}, ErrConversationAborted
+		}
+
+		if len(input.Choices) > 0 {
+			choice, ok := matchChoice(input.Choices, out)
+			if !ok {
+				return Answer{}, fmt.Errorf("dialog returned %q, which isn't one of the choices", out)
+			}
+			return Answer{Value: choice}, nil
+		}
+		if out == "" {
+			out = input.Default
+		}
+		if out != "" {
+			return Answer{Value: out}, nil
+		}
+	}
+}
+
+// dismissed reports whether the dialog was closed without an answer.
+func (d *DialogInteractor) dismissed(out string, code int) bool {
+	if dialogTools[d.goos] == "zenity" {
+		// zenity exits with 1 after a click on one of the extra buttons used for choices,
+		// so only an exit without output is a dismissal
+		return code != 0 && out == ""
+	}
+	return code != 0
+}
+
+// Notify shows the message as a desktop notification where the platform supports it.
+func (d *DialogInteractor) Notify(ctx context.Context, message string) error {
+	var err error
+	switch dialogTools[d.goos] {
+	case "zenity":
+		_, _, err = d.runner.Run(ctx, "zenity", "--notification", "--text", message)
+	case "osascript":
+		_, _, err = d.runner.Run(ctx, "osascript", "-e",
+			fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(d.title)))
+	}
+	return err
+}
+
+// Close does nothing; every dialog is gone once it is answered.
+func (d *DialogInteractor) Close() error {
+	return nil
+}
+
+// command returns the command showing the question on the platform.
+func (d *DialogInteractor) command(input QuestionInput) (string, []string) {
+	text := input.Question
+	if input.Reason != "" {
+		text += "\n\n" + input.Reason
+	}
+
+	switch d.goos {
+	case "darwin":
+		return "osascript", []string{"-e", appleScriptDialog(d.title, text, input)}
+	case "windows":
+		return "powershell", []string{"-NoProfile", "-NonInteractive", "-Command", powerShellDialog(d.title, text, input)}
+	default:
+		return "zenity", zenityArgs(d.title, text, input)
+	}
+}
+
+// zenityArgs renders a question dialog with an extra button per choice, or an entry dialog.
+func zenityArgs(title, text string, input QuestionInput) []string {
+	if len(input.Choices) == 0 {
+		args := []string{"--entry", "--title", title, "--text", text}
+		if input.Default != "" {
+			args = append(args, "--entry-text", input.Default)
+		}
+		return args
+	}
+	// --switch drops the OK and Cancel buttons, leaving only the extra buttons, whose label is printed
+	args := []string{"--question", "--switch", "--title", title, "--text", text}
+	for _, choice := range input.Choices {
+		args = append(args, "--extra-button", choice)
+	}
+	return args
+}
+
+// appleScriptDialog renders a dialog with a button per choice, a list for more choices than fit on
+// buttons, or an entry field. Cancelling fails with error -128, which makes osascript exit with 1.
+func appleScriptDialog(title, text string, input QuestionInput) string {
+	switch {
+	case len(input.Choices) == 0:
+		return fmt.Sprintf("text returned of (display dialog %s with title %s default answer %s)",
+			appleScriptString(text), appleScriptString(title), appleScriptString(input.Default))
+	case len(input.Choices) <= maxDialogButtons:
+		dialog := fmt.Sprintf("display dialog %s with title %s buttons %s",
+			appleScriptString(text), appleScriptString(title), appleScriptList(input.Choices))
+		if slices.Contains(input.Choices, input.Default) {
+			dialog += " default button " + appleScriptString(input.Default)
+		}
+		return "button returned of (" + dialog + ")"
+	default:
+		list := fmt.Sprintf("choose from list %s with prompt %s with title %s",
+			appleScriptList(input.Choices), appleScriptString(text), appleScriptString(title))
+		if slices.Contains(input.Choices, input.Default) {
+			list += " default items {" + appleScriptString(input.Default) + "}"
+		}
+		return "set picked to (" + list + ")\nif picked is false then error number -128\nreturn item 1 of picked"
+	}
+}
+
+// powerShellDialog renders a Windows Forms dialog with a button per choice, or a text box with an OK
+// button. Closing the window exits with 1.
+func powerShellDialog(title, text string, input QuestionInput) string {
+	var b strings.Builder
+	b.WriteString("Add-Type -AssemblyName System.Windows.Forms\n")
+	b.WriteString("$form = New-Object System.Windows.Forms.Form\n")
+	fmt.Fprintf(&b, "$form.Text = %s\n", powerShellString(title))
+	b.WriteString("$form.AutoSize = $true; $form.AutoSizeMode = 'GrowAndShrink'; $form.StartPosition = 'CenterScreen'; $form.TopMost = $true\n")
+	b.WriteString("$panel = New-Object System.Windows.Forms.FlowLayoutPanel\n")
+	b.WriteString("$panel.FlowDirection = 'TopDown'; $panel.AutoSize = $true; $panel.Padding = 10\n")
+	b.WriteString("$form.Controls.Add($panel)\n")
+	b.WriteString("$label = New-Object System.Windows.Forms.Label\n")
+	fmt.Fprintf(&b, "$label.Text = %s; $label.AutoSize = $true; $label.MaximumSize = '400,0'\n", powerShellString(text))
+	b.WriteString("$panel.Controls.Add($label)\n")
+	b.WriteString("$script:answer = $null\n")
+
+	if len(input.Choices) > 0 {
+		for _, choice := range input.Choices {
+			b.WriteString("$button = New-Object System.Windows.Forms.Button\n")
+			fmt.Fprintf(&b, "$button.Text = %s; $button.AutoSize = $true\n", powerShellString(choice))
+			b.WriteString("$button.Add_Click({ $script:answer = $this.Text; $form.Close() })\n")
+			b.WriteString("$panel.Controls.Add($button)\n")
+		}
+	} else {
+		b.WriteString("$box = New-Object System.Windows.Forms.TextBox\n")
+		fmt.Fprintf(&b, "$box.Text = %s; $box.Width = 400\n", powerShellString(input.Default))
+		b.WriteString("$panel.Controls.Add($box)\n")
+		b.WriteString("$ok = New-Object System.Windows.Forms.Button\n")
+		b.WriteString("$ok.Text = 'OK'\n")
+		b.WriteString("$ok.Add_Click({ $script:answer = $box.Text; $form.Close() })\n")
+		b.WriteString("$panel.Controls.Add($ok); $form.AcceptButton = $ok\n")
+	}
+
+	b.WriteString("[void]$form.ShowDialog()\n")
+	b.WriteString("if ($null -eq $script:answer) { exit 1 }\n")
+	b.WriteString("[Console]::Out.Write($script:answer)\n")
+	return b.String()
+}
+
+// appleScriptString quotes the text as an AppleScript string literal.
+func appleScriptString(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
+
+// appleScriptList renders the items as an AppleScript list of strings.
+func appleScriptList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = appleScriptString(item)
+	}
+	return "{" + strings.Join(quoted, ", ") + "}"
+}
+
+// powerShellString quotes the text as a single-quoted PowerShell string, in which nothing is expanded.
+func powerShellString(text string) string {
+	return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialogResult is what a stubbed dialog tool prints and exits with.
+type dialogResult struct {
+	out  string
+	code int
+}
+
+// fakeDialogRunner records the commands and replies with the results in order.
+type fakeDialogRunner struct {
+	installed []string
+	results   []dialogResult
+	calls     [][]string
+}
+
+func (f *fakeDialogRunner) LookPath(file string) (string, error) {
+	for _, tool := range f.installed {
+		if tool == file {
+			return "/usr/bin/" + file, nil
+		}
+	}
+	return "", exec.ErrNotFound
+}
+
+func (f *fakeDialogRunner) Run(_ context.Context, name string, args ...string) (string, int, error) {
+	f.calls = append(f.calls, append([]string{name}, args...))
+	if len(f.results) == 0 {
+		return "", 0, errors.New("unexpected dialog")
+	}
+	result := f.results[0]
+	f.results = f.results[1:]
+	return result.out, result.code, nil
+}
+
+func TestDialogInteractor_Commands(t *testing.T) {
+	choices := []string{"Boy", "Girl", "Both"}
+
+	tests := []struct {
+		name     string
+		goos     string
+		input    QuestionInput
+		expected []string
+	}{
+		{
+			name:  "zenity buttons",
+			goos:  "linux",
+			input: QuestionInput{Question: "What gender?", Reason: "Gifts differ", Choices: choices},
+			expected: []string{"zenity", "--question", "--switch", "--title", "Gifts", "--text", "What gender?\n\nGifts differ",
+				"--extra-button", "Boy", "--extra-button", "Girl", "--extra-button", "Both"},
+		},
+		{
+			name:     "zenity entry",
+			goos:     "freebsd",
+			input:    QuestionInput{Question: "What ages?", Default: "8"},
+			expected: []string{"zenity", "--entry", "--title", "Gifts", "--text", "What ages?", "--entry-text", "8"},
+		},
+		{
+			name:  "applescript buttons",
+			goos:  "darwin",
+			input: QuestionInput{Question: `Pick a "gender"`, Choices: choices, Default: "Both"},
+			expected: []string{"osascript", "-e",
+				`button returned of (display dialog "Pick a \"gender\"" with title "Gifts" buttons {"Boy", "Girl", "Both"} default button "Both")`},
+		},
+		{
+			name:  "applescript list",
+			goos:  "darwin",
+			input: QuestionInput{Question: "Which hobby?", Choices: []string{"Lego", "Art", "Sports", `Back\slash`}},
+			expected: []string{"osascript", "-e",
+				"set picked to (choose from list {\"Lego\", \"Art\", \"Sports\", \"Back\\\\slash\"} with prompt \"Which hobby?\" with title \"Gifts\")\n" +
+					"if picked is false then error number -128\nreturn item 1 of picked"},
+		},
+		{
+			name:     "applescript entry",
+			goos:     "darwin",
+			input:    QuestionInput{Question: "What ages?"},
+			expected: []string{"osascript", "-e", `text returned of (display dialog "What ages?" with title "Gifts" default answer "")`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialog := &DialogInteractor{goos: tt.goos, title: "Gifts"}
+
+			name, args := dialog.command(tt.input)
+
+			assert.Equal(t, tt.expected, append([]string{name}, args...))
+		})
+	}
+}
+
+func TestDialogInteractor_PowerShellCommand(t *testing.T) {
+	dialog := &DialogInteractor{goos: "windows", title: "Gift's helper"}
+
+	name, args := dialog.command(QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}})
+	require.Equal(t, "powershell", name)
+	require.Len(t, args, 4)
+	assert.Equal(t, []string{"-NoProfile", "-NonInteractive", "-Command"}, args[:3])
+	script := args[3]
+	assert.Contains(t, script, "$form.Text = 'Gift''s helper'\n")
+	assert.Contains(t, script, "$button.Text = 'Boy'; $button.AutoSize = $true\n")
+	assert.Contains(t, script, "$button.Text = 'Girl'; $button.AutoSize = $true\n")
+	assert.NotContains(t, script, "TextBox")
+	assert.True(t, strings.HasSuffix(script, "if ($null -eq $script:answer) { exit 1 }\n[Console]::Out.Write($script:answer)\n"))
+
+	_, args = dialog.command(QuestionInput{Question: "What ages?", Default: "8"})
+	assert.Contains(t, args[3], "$box.Text = '8'; $box.Width = 400\n")
+	assert.NotContains(t, args[3], "$button")
+}
+
+func TestDialogInteractor_Ask(t *testing.T) {
+	choices := []string{"Boy", "Girl", "Both"}
+
+	tests := []struct {
+		name     string
+		goos     string
+		input    QuestionInput
+		results  []dialogResult
+		expected Answer
+		err      error
+	}{
+		{name: "zenity extra button", goos: "linux", input: QuestionInput{Question: "What gender?", Choices: choices}, results: []dialogResult{{out: "Girl", code: 1}}, expected: Answer{Value: "Girl"}},
+		{name: "zenity closed", goos: "linux", input: QuestionInput{Question: "What gender?", Choices: choices}, results: []dialogResult{{code: 1}}, err: ErrConversationAborted},
+		{name: "applescript button", goos: "darwin", input: QuestionInput{Question: "What gender?", Choices: choices}, results: []dialogResult{{out: "Both"}}, expected: Answer{Value: "Both"}},
+		{name: "applescript cancel", goos: "darwin", input: QuestionInput{Question: "What ages?"}, results: []dialogResult{{out: "", code: 1}}, err: ErrConversationAborted},
+		{name: "powershell closed", goos: "windows", input: QuestionInput{Question: "What ages?"}, results: []dialogResult{{code: 1}}, err: ErrConversationAborted},
+		{name: "entry", goos: "windows", input: QuestionInput{Question: "What ages?"}, results: []dialogResult{{out: "8 and 11"}}, expected: Answer{Value: "8 and 11"}},
+		{name: "empty entry selects the default", goos: "linux", input: QuestionInput{Question: "What ages?", Default: "10"}, results: []dialogResult{{}}, expected: Answer{Value: "10"}},
+		{name: "empty entry asks again", goos: "linux", input: QuestionInput{Question: "What ages?"}, results: []dialogResult{{}, {out: "9"}}, expected: Answer{Value: "9"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeDialogRunner{results: tt.results}
+			dialog := &DialogInteractor{runner: runner, goos: tt.goos, title: "Gifts"}
+
+			answer, err := dialog.Ask(context.Background(), tt.input)
+
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, answer)
+			assert.Len(t, runner.calls, len(tt.results))
+		})
+	}
+}
+
+func TestNewDialogInteractor(t *testing.T) {
+	_, err := NewDialogInteractor(WithDialogRunner(&fakeDialogRunner{}))
+	assert.ErrorIs(t, err, ErrNoDialogTool)
+
+	dialog, err := NewDialogInteractor(WithDialogRunner(&fakeDialogRunner{installed: []string{"zenity", "osascript", "powershell"}}), WithDialogTitle("Gifts"))
+	require.NoError(t, err)
+	assert.Equal(t, "Gifts", dialog.title)
+
+	fallback := NewDialogOrTerminal(context.Background(), []DialogOption{WithDialogRunner(&fakeDialogRunner{})})
+	_, ok := fallback.(*TerminalReader)
+	assert.True(t, ok, "without a dialog tool the terminal is used")
+	_ = fallback.Close()
+}
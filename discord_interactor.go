@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// discordChoicePrefix prefixes the custom IDs of choice buttons; the rest of the ID is the choice index.
+const discordChoicePrefix = "choice-"
+
+// Discord limits a message to five action rows of five buttons each.
+const (
+	discordButtonsPerRow = 5
+	discordMaxButtons    = 5 * discordButtonsPerRow
+)
+
+// DiscordMessage is a message sent to or edited in a Discord channel.
+type DiscordMessage struct {
+	Content string
+	Buttons []DiscordButton
+}
+
+// DiscordButton is a button component; a click on it is reported with its CustomID.
+type DiscordButton struct {
+	Label    string
+	CustomID string
+}
+
+// DiscordEvent is a user action on a message sent by the bot: a button click or a reply.
+type DiscordEvent struct {
+	// MessageID identifies the message that was clicked or replied to.
+	MessageID string
+	// CustomID is set for button clicks.
+	CustomID string
+	// Content is set for replies.
+	Content string
+}
+
+// DiscordGateway is the part of a Discord bot session used by DiscordInteractor.
+type DiscordGateway interface {
+	// SendMessage sends the message to the channel and returns its ID.
+	SendMessage(ctx context.Context, channelID string, msg DiscordMessage) (string, error)
+	// EditMessage replaces the content and the buttons of the message.
+	EditMessage(ctx context.Context, channelID, messageID string, msg DiscordMessage) error
+	// Subscribe calls handle for every click and reply until the returned function is called.
+	// The subscription must outlive reconnects of the gateway.
+	Subscribe(handle func(DiscordEvent)) (unsubscribe func())
+}
+
+// DiscordInteractor asks questions in a Discord channel or DM. Choices are rendered as buttons and
+// a click answers the question; a reply to the question's message answers it with free text or
+// the number of a choice. Answers are matched to questions by message ID, so several questions can
+// be outstanding and a reconnect of the bot doesn't lose them.
+type DiscordInteractor struct {
+	gateway     DiscordGateway
+	channelID   string
+	timeout     time.Duration
+	unsubscribe func()
+
+	// pending holds the questions by the IDs of their messages.
+	pending pendingAnswers[string]
+}
+
+// DiscordOption configures a DiscordInteractor.
+type DiscordOption func(*DiscordInteractor)
+
+// WithDiscordAnswerTimeout sets how long Interact waits for an answer before marking the question as expired.
+// Zero, the default, waits until the context is done.
+func WithDiscordAnswerTimeout(timeout time.Duration) DiscordOption {
+	return func(d *DiscordInteractor) {
+		d.timeout = timeout
+	}
+}
+
+// NewDiscordInteractor creates a DiscordInteractor that asks questions in the channel and starts listening for answers.
+// Call Close to stop listening.
+func NewDiscordInteractor(gateway DiscordGateway, channelID string, opts ...DiscordOption) *DiscordInteractor {
+	d := &DiscordInteractor{
+		gateway:   gateway,
+		channelID: channelID,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.unsubscribe = gateway.Subscribe(d.handleEvent)
+	return d
+}
+
+// Close stops listening for answers.
+func (d *DiscordInteractor) Close() error {
+	d.unsubscribe()
+	return nil
+}
+
+// Interact sends the question and blocks until it is answered in Discord, the timeout expires
+// or the context is done. It implements UserInteractionFunc.
+func (d *DiscordInteractor) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	messageID, pending, err := d.pending.post(input, func() (string, error) {
+		return d.gateway.SendMessage(ctx, d.channelID, discordQuestionMessage(input))
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to send the question to Discord: %w", err)
+	}
+	defer d.pending.remove(messageID)
+
+	answer, err := pending.wait(ctx, d.timeout)
+	// the message is edited even when the context is done, so that nobody answers a question that is gone
+	editCtx := context.WithoutCancel(ctx)
+	var timeoutErr *ErrAnswerTimeout
+	switch {
+	case err == nil:
+		_ = d.gateway.EditMessage(editCtx, d.channelID, messageID, discordClosedMessage(input, "answered: "+answer))
+	case errors.As(err, &timeoutErr):
+		_ = d.gateway.EditMessage(editCtx, d.channelID, messageID, discordClosedMessage(input, "expired"))
+	default:
+		_ = d.gateway.EditMessage(editCtx, d.channelID, messageID, discordClosedMessage(input, "cancelled"))
+	}
+	return answer, err
+}
+
+// handleEvent answers the question the event refers to. Events for other messages are ignored.
+func (d *DiscordInteractor) handleEvent(event DiscordEvent) {
+	d.pending.answer(event.MessageID, func(input QuestionInput) (string, bool) {
+		if event.CustomID != "" {
+			index, err := strconv.Atoi(strings.TrimPrefix(event.CustomID, discordChoicePrefix))
+			if err != nil || index < 0 || index >= len(input.Choices) {
+				return "", false
+			}
+			return input.Choices[index], true
+		}
+		text := strings.TrimSpace(event.Content)
+		if text == "" {
+			return "", false
+		}
+		return resolveChoice(input.Choices, text)
+	})
+}
+
+// discordQuestionMessage renders the question with its reason and a button for each choice.
+// Choices that don't fit into the buttons are listed with numbers to reply with.
+func discordQuestionMessage(input QuestionInput) DiscordMessage {
+	var content strings.Builder
+	content.WriteString("**" + input.Question + "**")
+	if input.Reason != "" {
+		content.WriteString("\n" + input.Reason)
+	}
+
+	msg := DiscordMessage{}
+	if len(input.Choices) > discordMaxButtons {
+		for i, choice := range input.Choices {
+			fmt.Fprintf(&content, "\n%d) %s", i+1, choice)
+		}
+	} else {
+		for i, choice := range input.Choices {
+			msg.Buttons = append(msg.Buttons, DiscordButton{Label: choice, CustomID: discordChoicePrefix + strconv.Itoa(i)})
+		}
+	}
+	content.WriteString("\n_Reply to this message to answer in your own words._")
+	msg.Content = content.String()
+	return msg
+}
+
+// discordClosedMessage renders a question that can no longer be answered, without its buttons.
+func discordClosedMessage(input QuestionInput, status string) DiscordMessage {
+	return DiscordMessage{Content: "**" + input.Question + "**\n_" + status + "_"}
+}
+
+// discordgoGateway adapts a discordgo session to DiscordGateway.
+type discordgoGateway struct {
+	session *discordgo.Session
+}
+
+// NewDiscordGateway wraps a discordgo session. The session must have the intents to receive
+// message events and should be opened by the caller; discordgo reconnects it automatically
+// and keeps the handlers registered across reconnects.
+func NewDiscordGateway(session *discordgo.Session) DiscordGateway {
+	return &discordgoGateway{session: session}
+}
+
+// DiscordDMChannel returns the ID of the direct message channel with the user, creating it when needed.
+func DiscordDMChannel(session *discordgo.Session, userID string) (string, error) {
+	channel, err := session.UserChannelCreate(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to open a DM channel: %w", err)
+	}
+	return channel.ID, nil
+}
+
+func (g *discordgoGateway) SendMessage(ctx context.Context, channelID string, msg DiscordMessage) (string, error) {
+	sent, err := g.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:    msg.Content,
+		Components: discordComponents(msg.Buttons),
+	}, discordgo.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	return sent.ID, nil
+}
+
+func (g *discordgoGateway) EditMessage(ctx context.Context, channelID, messageID string, msg DiscordMessage) error {
+	components := discordComponents(msg.Buttons)
+	edit := discordgo.NewMessageEdit(channelID, messageID).SetContent(msg.Content)
+	edit.Components = &components
+	_, err := g.session.ChannelMessageEditComplex(edit, discordgo.WithContext(ctx))
+	return err
+}
+
+func (g *discordgoGateway) Subscribe(handle func(DiscordEvent)) func() {
+	removeClicks := g.session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		if i.Type != discordgo.InteractionMessageComponent || i.Message == nil {
+			return
+		}
+		// Discord shows an error to the user unless the click is acknowledged within three seconds
+		_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseDeferredMessageUpdate,
+		})
+		handle(DiscordEvent{MessageID: i.Message.ID, CustomID: i.MessageComponentData().CustomID})
+	})
+	removeReplies := g.session.AddHandler(func(_ *discordgo.Session, m *discordgo.MessageCreate) {
+		if m.Author == nil || m.Author.Bot || m.MessageReference == nil {
+			return
+		}
+		handle(DiscordEvent{MessageID: m.MessageReference.MessageID, Content: m.Content})
+	})
+	return func() {
+		removeClicks()
+		removeReplies()
+	}
+}
+
+// discordComponents lays the buttons out in action rows.
+func discordComponents(buttons []DiscordButton) []discordgo.MessageComponent {
+	components := []discordgo.MessageComponent{}
+	for start := 0; start < len(buttons); start += discordButtonsPerRow {
+		var row discordgo.ActionsRow
+		for _, button := range buttons[start:min(start+discordButtonsPerRow, len(buttons))] {
+			row.Components = append(row.Components, discordgo.Button{
+				Label:    button.Label,
+				Style:    discordgo.PrimaryButton,
+				CustomID: button.CustomID,
+			})
+		}
+		components = append(components, row)
+	}
+	return components
+}
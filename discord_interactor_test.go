@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGateway records sent and edited messages and lets tests deliver events like the Discord gateway.
+type fakeGateway struct {
+	mu        sync.Mutex
+	sent      []DiscordMessage
+	edits     map[string]DiscordMessage
+	handlers  map[int]func(DiscordEvent)
+	nextID    int
+	connected bool
+}
+
+func newFakeGateway() *fakeGateway {
+	return &fakeGateway{edits: make(map[string]DiscordMessage), handlers: make(map[int]func(DiscordEvent)), connected: true}
+}
+
+func (f *fakeGateway) SendMessage(_ context.Context, _ string, msg DiscordMessage) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.connected {
+		return "", errors.New("gateway is disconnected")
+	}
+	f.sent = append(f.sent, msg)
+	return fmt.Sprintf("message-%d", len(f.sent)), nil
+}
+
+func (f *fakeGateway) EditMessage(_ context.Context, _, messageID string, msg DiscordMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.edits[messageID] = msg
+	return nil
+}
+
+func (f *fakeGateway) Subscribe(handle func(DiscordEvent)) func() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := f.nextID
+	f.nextID++
+	f.handlers[id] = handle
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.handlers, id)
+	}
+}
+
+// deliver sends the event to the subscribers, as long as the gateway is connected.
+func (f *fakeGateway) deliver(event DiscordEvent) {
+	f.mu.Lock()
+	var handlers []func(DiscordEvent)
+	if f.connected {
+		for _, handle := range f.handlers {
+			handlers = append(handlers, handle)
+		}
+	}
+	f.mu.Unlock()
+	for _, handle := range handlers {
+		handle(event)
+	}
+}
+
+func (f *fakeGateway) setConnected(connected bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = connected
+}
+
+func (f *fakeGateway) sentCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.sent)
+}
+
+func (f *fakeGateway) edit(messageID string) DiscordMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.edits[messageID]
+}
+
+// discordResult is what Interact returned.
+type discordResult struct {
+	answer string
+	err    error
+}
+
+// askDiscord runs Interact in the background and waits until the question is sent.
+func askDiscord(t *testing.T, gateway *fakeGateway, interactor *DiscordInteractor, input QuestionInput) <-chan discordResult {
+	t.Helper()
+	sent := gateway.sentCount()
+	done := make(chan discordResult, 1)
+	go func() {
+		answer, err := interactor.Interact(context.Background(), input)
+		done <- discordResult{answer, err}
+	}()
+	require.Eventually(t, func() bool { return gateway.sentCount() == sent+1 }, time.Second, time.Millisecond)
+	return done
+}
+
+func TestDiscordInteractor_ButtonClick(t *testing.T) {
+	gateway := newFakeGateway()
+	interactor := NewDiscordInteractor(gateway, "channel", WithDiscordAnswerTimeout(time.Second))
+	defer interactor.Close()
+
+	done := askDiscord(t, gateway, interactor, QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl", "Both"}})
+	assert.Equal(t, []DiscordButton{
+		{Label: "Boy", CustomID: "choice-0"},
+		{Label: "Girl", CustomID: "choice-1"},
+		{Label: "Both", CustomID: "choice-2"},
+	}, gateway.sent[0].Buttons)
+
+	gateway.deliver(DiscordEvent{MessageID: "message-1", CustomID: "choice-1"})
+	result := <-done
+	require.NoError(t, result.err)
+	assert.Equal(t, "Girl", result.answer)
+	assert.Contains(t, gateway.edit("message-1").Content, "answered: Girl")
+	assert.Empty(t, gateway.edit("message-1").Buttons)
+}
+
+func TestDiscordInteractor_OutstandingQuestions(t *testing.T) {
+	gateway := newFakeGateway()
+	interactor := NewDiscordInteractor(gateway, "channel", WithDiscordAnswerTimeout(time.Second))
+	defer interactor.Close()
+
+	first := askDiscord(t, gateway, interactor, QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}})
+	second := askDiscord(t, gateway, interactor, QuestionInput{Question: "What age?"})
+
+	// events for unknown messages and invalid buttons are ignored
+	gateway.deliver(DiscordEvent{MessageID: "message-9", Content: "Boy"})
+	gateway.deliver(DiscordEvent{MessageID: "message-1", CustomID: "choice-7"})
+	gateway.deliver(DiscordEvent{MessageID: "message-2", Content: " 8 and 11 "})
+	gateway.deliver(DiscordEvent{MessageID: "message-1", Content: "2"})
+
+	result := <-first
+	require.NoError(t, result.err)
+	assert.Equal(t, "Girl", result.answer, "a numeric reply selects the choice")
+	result = <-second
+	require.NoError(t, result.err)
+	assert.Equal(t, "8 and 11", result.answer)
+}
+
+func TestDiscordInteractor_Reconnect(t *testing.T) {
+	gateway := newFakeGateway()
+	interactor := NewDiscordInteractor(gateway, "channel", WithDiscordAnswerTimeout(time.Second))
+	defer interactor.Close()
+
+	done := askDiscord(t, gateway, interactor, QuestionInput{Question: "What gender?"})
+
+	// a reply while the bot is disconnected is lost, the question stays pending until the next one
+	gateway.setConnected(false)
+	gateway.deliver(DiscordEvent{MessageID: "message-1", Content: "Boy"})
+	gateway.setConnected(true)
+	gateway.deliver(DiscordEvent{MessageID: "message-1", Content: "Girl"})
+
+	result := <-done
+	require.NoError(t, result.err)
+	assert.Equal(t, "Girl", result.answer)
+}
+
+func TestDiscordInteractor_Timeout(t *testing.T) {
+	gateway := newFakeGateway()
+	interactor := NewDiscordInteractor(gateway, "channel", WithDiscordAnswerTimeout(20*time.Millisecond))
+	defer interactor.Close()
+
+	_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?"})
+
+	var timeoutErr *ErrAnswerTimeout
+	require.True(t, errors.As(err, &timeoutErr))
+	assert.Contains(t, gateway.edit("message-1").Content, "expired")
+}
+
+func TestDiscordInteractor_Close(t *testing.T) {
+	gateway := newFakeGateway()
+	interactor := NewDiscordInteractor(gateway, "channel")
+	require.NoError(t, interactor.Close())
+	assert.Empty(t, gateway.handlers)
+}
+
+func TestDiscordQuestionMessage(t *testing.T) {
+	many := make([]string, discordMaxButtons+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("option %d", i+1)
+	}
+
+	msg := discordQuestionMessage(QuestionInput{Question: "Pick one", Choices: many})
+	assert.Empty(t, msg.Buttons, "too many choices are listed instead")
+	assert.Contains(t, msg.Content, "\n26) option 26")
+
+	msg = discordQuestionMessage(QuestionInput{Question: "Pick one", Choices: many[:7]})
+	components := discordComponents(msg.Buttons)
+	require.Len(t, components, 2)
+	assert.Len(t, components[0].(discordgo.ActionsRow).Components, 5)
+	assert.Len(t, components[1].(discordgo.ActionsRow).Components, 2)
+}
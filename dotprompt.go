@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// templateMessage is a message of a prompt loaded from a .prompt file with the template parts it was
+// loaded with.
+type templateMessage struct {
+	message *ai.Message
+	content []*ai.Part
+}
+
+// promptTemplates keeps the template messages of the prompts rendered by renderPromptRef. Genkit
+// replaces the content of the messages of a loaded prompt with their rendering when the prompt is
+// rendered, so the template is kept from the first render and put back before each one.
+var promptTemplates = struct {
+	sync.Mutex
+	messages map[ai.Prompt][]templateMessage
+}{messages: map[ai.Prompt][]templateMessage{}}
+
+// LoadPromptFiles loads the .prompt files of dir with genkit.LoadPrompt and returns the prompts by
+// name, such as "gift_system" for gift_system.prompt, for the prompt refs of Options and
+// ConversationLoopHandler. Unlike genkit.LookupPrompt for the prompts of genkit.WithPromptDir, the
+// prompts it returns can be rendered again with other vars.
+func LoadPromptFiles(g *genkit.Genkit, dir string) (map[string]ai.Prompt, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.prompt"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .prompt files in %s", dir)
+	}
+	prompts := make(map[string]ai.Prompt, len(paths))
+	for _, path := range paths {
+		prompt := genkit.LoadPrompt(g, path, "")
+		if prompt == nil {
+			return nil, fmt.Errorf("can't load the prompt file %s", path)
+		}
+		prompts[prompt.Name()] = prompt
+	}
+	return prompts, nil
+}
+
+// renderPromptRef renders a prompt loaded from a .prompt file, such as with LoadPromptFiles or
+// genkit.LoadPrompt, and returns the text of its messages. The vars are the input of the prompt,
+// checked against the input schema of the file; without vars, the defaults of the file are used.
+func renderPromptRef(ctx context.Context, name string, prompt ai.Prompt, vars map[string]any) (string, error) {
+	var input any
+	if vars != nil {
+		input = vars
+	}
+
+	promptTemplates.Lock()
+	templates, ok := promptTemplates.messages[prompt]
+	if !ok {
+		var err error
+		if templates, err = loadedTemplateMessages(ctx, prompt); err != nil {
+			promptTemplates.Unlock()
+			return "", fmt.Errorf("can't read the %s %s: %w", name, prompt.Name(), err)
+		}
+		promptTemplates.messages[prompt] = templates
+	}
+	for _, template := range templates {
+		template.message.Content = slices.Clone(template.content)
+	}
+	rendered, err := prompt.Render(ctx, input)
+	promptTemplates.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("can't render the %s %s: %w", name, prompt.Name(), err)
+	}
+
+	texts := make([]string, 0, len(rendered.Messages))
+	for _, message := range rendered.Messages {
+		if text := strings.TrimSpace(message.Text()); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	if len(texts) == 0 {
+		return "", fmt.Errorf("the %s %s rendered no text", name, prompt.Name())
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// loadedTemplateMessages returns the messages of the prompt with their template parts. Genkit doesn't
+// expose the messages of a prompt, so they are read with reflection; a prompt without them, such as
+// one defined in code or looked up with genkit.LookupPrompt, has none.
+func loadedTemplateMessages(ctx context.Context, prompt ai.Prompt) ([]templateMessage, error) {
+	v := reflect.ValueOf(prompt)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	field := v.FieldByName("MessagesFn")
+	if !field.IsValid() || field.IsNil() {
+		return nil, nil
+	}
+	messagesFn, ok := field.Interface().(ai.MessagesFn)
+	if !ok {
+		return nil, nil
+	}
+	messages, err := messagesFn(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]templateMessage, len(messages))
+	for i, message := range messages {
+		templates[i] = templateMessage{message: message, content: slices.Clone(message.Content)}
+	}
+	return templates, nil
+}
+
+// promptSources counts the ways a prompt is set: as text, as a template and as a .prompt file.
+func promptSources[T ~string](text T, template string, ref ai.Prompt) int {
+	count := 0
+	for _, set := range []bool{text != "", template != "", ref != nil} {
+		if set {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadTestPrompts loads the .prompt files of testdata/prompts into a new Genkit instance.
+func loadTestPrompts(t *testing.T) map[string]ai.Prompt {
+	t.Helper()
+	prompts, err := LoadPromptFiles(genkit.Init(context.Background()), "testdata/prompts")
+	require.NoError(t, err)
+	return prompts
+}
+
+func TestRunAgent_PromptRefs(t *testing.T) {
+	prompts := loadTestPrompts(t)
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("A LEGO set", "stop")}, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")})
+	mockGen.boolResponses = []bool{true}
+	handler := NewConversationLoopHandler(mockGen, "", nil)
+	handler.UseValidationPromptRef(prompts["gift_finished"])
+
+	text, err := RunAgent(context.Background(), &Options{
+		generator:       mockGen,
+		systemPromptRef: prompts["gift_system"],
+		userPromptRef:   prompts["gift_request"],
+		vars:            giftVars,
+		responseHandler: handler,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "A LEGO set", text)
+	require.Len(t, mockGen.capturedCalls, 1)
+	fields := capturedPromptFields(t, mockGen.capturedCalls[0].Options)
+	assert.Equal(t, "You help a busy parent find gifts for Christmas.", fields["SystemFn"])
+	assert.Equal(t, "Suggest a Christmas gift that arrives by December 20.", fields["PromptFn"])
+	assert.Equal(t, []string{"Does the answer suit a gift for Christmas?"}, mockGen.boolPrompts)
+}
+
+func TestRenderPromptRef(t *testing.T) {
+	prompts := loadTestPrompts(t)
+
+	tests := []struct {
+		name     string
+		vars     map[string]any
+		expected string
+		err      string
+	}{
+		{name: "vars", vars: map[string]any{"persona": "a busy parent", "occasion": "Christmas"}, expected: "You help a busy parent find gifts for Christmas."},
+		{name: "default of the file", vars: map[string]any{"persona": "a teacher"}, expected: "You help a teacher find gifts for a birthday."},
+		{name: "other vars", vars: map[string]any{"persona": "a student", "occasion": "graduation"}, expected: "You help a student find gifts for graduation."},
+		{name: "missing required var", vars: map[string]any{"occasion": "Christmas"}, err: "can't render the system prompt gift_system"},
+		{name: "var of the wrong type", vars: map[string]any{"persona": 42}, err: "can't render the system prompt gift_system"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := renderPromptRef(context.Background(), "system prompt", prompts["gift_system"], tt.vars)
+
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, text)
+		})
+	}
+}
+
+func TestRunAgent_PromptRefInvalidVars(t *testing.T) {
+	prompts := loadTestPrompts(t)
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("A LEGO set", "stop")}, nil)
+
+	_, err := RunAgent(context.Background(), &Options{
+		generator:     mockGen,
+		userPromptRef: prompts["gift_request"],
+		vars:          map[string]any{"occasion": "Christmas"},
+	})
+
+	assert.ErrorContains(t, err, "can't render the user prompt gift_request")
+	assert.ErrorContains(t, err, "deadline is required")
+	assert.Empty(t, mockGen.capturedCalls, "the model isn't called")
+}
+
+func TestLoadPromptFiles_NoFiles(t *testing.T) {
+	_, err := LoadPromptFiles(genkit.Init(context.Background()), t.TempDir())
+
+	assert.ErrorContains(t, err, "no .prompt files in")
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// emailTokenPattern finds the correlation token in the subject or the quoted text of a reply.
+var emailTokenPattern = regexp.MustCompile(`\[ref:([0-9a-f]{32})\]`)
+
+// emailAttributionPattern matches the line mail clients put above the quoted message, such as
+// "On Mon, 2 Jun 2025 at 10:00, Agent <agent@example.com> wrote:".
+var emailAttributionPattern = regexp.MustCompile(`^On .+ wrote:$`)
+
+// ErrUnknownSender is returned for a reply that doesn't come from the address the question was sent to.
+var ErrUnknownSender = errors.New("reply doesn't come from the recipient of the question")
+
+// ErrNoReplyText is returned for a reply that has nothing above the quoted question.
+var ErrNoReplyText = errors.New("reply has no answer above the quoted text")
+
+// EmailMessage is an email sent by an EmailInteractor.
+type EmailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// InboundEmail is an email received in reply to a question.
+type InboundEmail struct {
+	From    string
+	Subject string
+	// Text is the plain-text body, including any quoted text.
+	Text string
+}
+
+// EmailSender sends emails.
+type EmailSender interface {
+	Send(ctx context.Context, msg EmailMessage) error
+}
+
+// EmailInbox fetches received emails, for example by polling an IMAP mailbox.
+type EmailInbox interface {
+	// Fetch returns the emails received since the previous call.
+	Fetch(ctx context.Context) ([]InboundEmail, error)
+}
+
+// SMTPSender sends plain-text emails through an SMTP server.
+type SMTPSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+	// sendMail is replaced in tests to capture the message instead of connecting.
+	sendMail func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPSender creates an SMTPSender sending from the address through the server at addr, such as
+// "smtp.example.com:587". auth may be nil for servers that don't require authentication.
+func NewSMTPSender(addr, from string, auth smtp.Auth) *SMTPSender {
+	return &SMTPSender{addr: addr, from: from, auth: auth, sendMail: smtp.SendMail}
+}
+
+// Send delivers the message. The SMTP client doesn't support contexts, so the context is only
+// checked before connecting.
+func (s *SMTPSender) Send(ctx context.Context, msg EmailMessage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	if err := s.sendMail(s.addr, s.auth, s.from, []string{msg.To}, b.Bytes()); err != nil {
+		return fmt.Errorf("failed to send the email: %w", err)
+	}
+	return nil
+}
+
+// EmailInteractor asks questions by email for approval loops that take hours. The subject of every
+// question carries a correlation token, and a reply is matched to its question by the token in its
+// subject or quoted text; the first line above the quoted text is the answer. Questions are kept in an
+// AnswerStore and Interact suspends the run, which is continued with InterruptionHandler.Resume once
+// the answers are in. Replies arrive through HandleInbound, fed by InboundHandler or PollInbox.
+type EmailInteractor struct {
+	sender  EmailSender
+	to      string
+	async   *AsyncInteractor
+	store   AnswerStore
+	timeout time.Duration
+	// now is replaced in tests to expire questions without waiting.
+	now func() time.Time
+
+	mu sync.Mutex
+	// expired holds the IDs of questions whose follow-up notice was sent. It lives in memory only,
+	// so after a restart expired questions are noticed again by ExpireStale.
+	expired map[string]bool
+}
+
+// EmailOption configures an EmailInteractor.
+type EmailOption func(*EmailInteractor)
+
+// WithEmailAnswerTimeout sets how long a question waits for a reply before ExpireStale
+// sends a follow-up notice and stops accepting replies to it. Zero, the default, never expires.
+func WithEmailAnswerTimeout(timeout time.Duration) EmailOption {
+	return func(e *EmailInteractor) {
+		e.timeout = timeout
+	}
+}
+
+// NewEmailInteractor creates an EmailInteractor emailing the questions to the address through the
+// sender and keeping them in the store.
+func NewEmailInteractor(sender EmailSender, to string, store AnswerStore, opts ...EmailOption) *EmailInteractor {
+	e := &EmailInteractor{
+		sender:  sender,
+		to:      to,
+		async:   NewAsyncInteractor(store),
+		store:   store,
+		now:     time.Now,
+		expired: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Interact emails a new question and returns ErrAnswerPending, or returns the answer to a question
+// asked before the run was suspended. An expired question fails with ErrAnswerTimeout.
+// It implements UserInteractionFunc.
+func (e *EmailInteractor) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	if id, ok := CorrelationIDFromContext(ctx); ok {
+		if e.isExpired(id) {
+			return "", &ErrAnswerTimeout{Question: input.Question, Timeout: e.timeout}
+		}
+		return e.async.Ask(ctx, input)
+	}
+
+	_, err := e.async.Ask(ctx, input)
+	var pending *ErrAnswerPending
+	if !errors.As(err, &pending) {
+		return "", err
+	}
+	if err := e.sender.Send(ctx, emailQuestion(e.to, pending.ID, input)); err != nil {
+		return "", fmt.Errorf("failed to email the question: %w", err)
+	}
+	return "", pending
+}
+
+// HandleInbound records the answer carried by a reply. It fails with ErrUnknownQuestion for a reply
+// without a token of a pending question, with ErrUnknownSender for a reply from another address and
+// with ErrNoReplyText when there is nothing above the quoted text.
+func (e *EmailInteractor) HandleInbound(ctx context.Context, email InboundEmail) error {
+	match := emailTokenPattern.FindStringSubmatch(email.Subject)
+	if match == nil {
+		match = emailTokenPattern.FindStringSubmatch(email.Text)
+	}
+	if match == nil {
+		return fmt.Errorf("%w: the reply carries no token", ErrUnknownQuestion)
+	}
+	id := match[1]
+	if e.isExpired(id) {
+		return fmt.Errorf("%w: the question has expired", ErrUnknownQuestion)
+	}
+	if !sameAddress(email.From, e.to) {
+		return ErrUnknownSender
+	}
+
+	question, err := e.store.Question(ctx, id)
+	if err != nil {
+		return err
+	}
+	text, ok := extractReply(email.Text)
+	if !ok {
+		return ErrNoReplyText
+	}
+	if choice, ok := matchChoice(question.Input.Choices, text); ok {
+		text = choice
+	}
+	answer, ok := resolveChoice(question.Input.Choices, text)
+	if !ok {
+		return fmt.Errorf("reply %q isn't a number from 1 to %d", text, len(question.Input.Choices))
+	}
+	return e.async.SubmitAnswer(ctx, id, answer)
+}
+
+// InboundHandler returns the handler for an inbound-parse webhook posting received emails as a form
+// with from, subject and text fields. Replies that don't answer a pending question are acknowledged and
+// dropped, so that the email provider doesn't retry them.
+func (e *EmailInteractor) InboundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.ParseMultipartForm(maxRequestBodySize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			http.Error(w, "invalid form: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		_ = e.HandleInbound(r.Context(), InboundEmail{
+			From:    r.FormValue("from"),
+			Subject: r.FormValue("subject"),
+			Text:    r.FormValue("text"),
+		})
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// PollInbox hands the emails fetched from the inbox every interval to HandleInbound until the
+// context is done. Failed fetches are retried at the next tick.
+func (e *EmailInteractor) PollInbox(ctx context.Context, inbox EmailInbox, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if emails, err := inbox.Fetch(ctx); err == nil {
+			for _, email := range emails {
+				_ = e.HandleInbound(ctx, email)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpireStale sends a follow-up notice for every unanswered question older than the answer timeout
+// and stops accepting replies to it. It returns how many questions expired. Call it periodically.
+func (e *EmailInteractor) ExpireStale(ctx context.Context) (int, error) {
+	if e.timeout <= 0 {
+		return 0, nil
+	}
+	unanswered, err := e.store.Unanswered(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, question := range unanswered {
+		if e.isExpired(question.ID) || e.now().Sub(question.AskedAt) < e.timeout {
+			continue
+		}
+		if err := e.sender.Send(ctx, emailExpiredNotice(e.to, question)); err != nil {
+			return expired, fmt.Errorf("failed to email the follow-up notice: %w", err)
+		}
+		e.mu.Lock()
+		e.expired[question.ID] = true
+		e.mu.Unlock()
+		expired++
+	}
+	return expired, nil
+}
+
+// isExpired reports whether the question with the ID has expired.
+func (e *EmailInteractor) isExpired(id string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.expired[id]
+}
+
+// extractReply returns the first line of the reply above the quoted text.
+func extractReply(text string) (string, bool) {
+	for line := range strings.Lines(text) {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, ">"), emailAttributionPattern.MatchString(line),
+			strings.HasPrefix(line, "-----Original Message-----"), line == "--":
+			// the quoted question or the signature starts before any answer
+			return "", false
+		}
+		return line, true
+	}
+	return "", false
+}
+
+// sameAddress reports whether the two addresses, possibly with display names, are the same mailbox.
+func sameAddress(a, b string) bool {
+	parsedA, errA := mail.ParseAddress(a)
+	parsedB, errB := mail.ParseAddress(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return strings.EqualFold(parsedA.Address, parsedB.Address)
+}
+
+// emailToken renders the correlation token put in subjects and bodies.
+func emailToken(id string) string {
+	return "[ref:" + id + "]"
+}
+
+// emailQuestion renders the email asking the question.
+func emailQuestion(to, id string, input QuestionInput) EmailMessage {
+	var b strings.Builder
+	b.WriteString(input.Question + "\n")
+	if input.Reason != "" {
+		b.WriteString("\n" + input.Reason + "\n")
+	}
+	if len(input.Choices) > 0 {
+		b.WriteString("\nReply with the number or the text of one of the choices:\n")
+		for i, choice := range input.Choices {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, choice)
+		}
+	}
+	b.WriteString("\nPut your answer on the first line of your reply.\n")
+	b.WriteString(emailToken(id) + "\n")
+	return EmailMessage{To: to, Subject: emailToken(id) + " " + input.Question, Body: b.String()}
+}
+
+// emailExpiredNotice renders the follow-up notice of a question that expired without an answer.
+func emailExpiredNotice(to string, question AsyncQuestion) EmailMessage {
+	body := fmt.Sprintf("The question %q wasn't answered in time and no longer needs an answer.\n", question.Input.Question)
+	return EmailMessage{To: to, Subject: "Re: " + emailToken(question.ID) + " " + question.Input.Question, Body: body}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMailer records sent emails instead of delivering them.
+type fakeMailer struct {
+	mu      sync.Mutex
+	sent    []EmailMessage
+	sendErr error
+}
+
+func (f *fakeMailer) Send(_ context.Context, msg EmailMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sendErr != nil {
+		return f.sendErr
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+// fakeInbox returns each batch of emails once.
+type fakeInbox struct {
+	mu      sync.Mutex
+	batches [][]InboundEmail
+}
+
+func (f *fakeInbox) Fetch(context.Context) ([]InboundEmail, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.batches) == 0 {
+		return nil, nil
+	}
+	batch := f.batches[0]
+	f.batches = f.batches[1:]
+	return batch, nil
+}
+
+// askByEmail asks the question and returns the correlation ID of the suspended question.
+func askByEmail(t *testing.T, interactor *EmailInteractor, input QuestionInput) string {
+	t.Helper()
+	_, err := interactor.Interact(context.Background(), input)
+	var pending *ErrAnswerPending
+	require.True(t, errors.As(err, &pending), "unexpected error: %v", err)
+	return pending.ID
+}
+
+func TestExtractReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+		ok       bool
+	}{
+		{name: "plain", text: "Both\n", expected: "Both", ok: true},
+		{name: "leading blank lines", text: "\n\n  2  \nThanks!\n", expected: "2", ok: true},
+		{name: "quoted below", text: "Under $80 please\n\nOn Mon, 2 Jun 2025 at 10:00, Agent <agent@example.com> wrote:\n> What is the budget?\n", expected: "Under $80 please", ok: true},
+		{name: "crlf", text: "Yes\r\n> Approve?\r\n", expected: "Yes", ok: true},
+		{name: "only quoted", text: "> What is the budget?\n", ok: false},
+		{name: "attribution first", text: "On Mon, Agent wrote:\n> What is the budget?\n", ok: false},
+		{name: "outlook quote", text: "-----Original Message-----\nFrom: Agent\n", ok: false},
+		{name: "empty", text: "  \n", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, ok := extractReply(tt.text)
+
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, reply)
+		})
+	}
+}
+
+func TestEmailInteractor_HandleInbound(t *testing.T) {
+	input := QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl", "Both"}}
+
+	tests := []struct {
+		name     string
+		email    func(id string) InboundEmail
+		expected string
+		err      error
+	}{
+		{
+			name: "token in the subject",
+			email: func(id string) InboundEmail {
+				return InboundEmail{From: "Pat <pat@example.com>", Subject: "Re: " + emailToken(id) + " What gender?", Text: "3\n> What gender?"}
+			},
+			expected: "Both",
+		},
+		{
+			name: "token in the quoted text",
+			email: func(id string) InboundEmail {
+				return InboundEmail{From: "PAT@example.com", Subject: "Re: your question", Text: "girl\n\nOn Mon, Agent wrote:\n> " + emailToken(id)}
+			},
+			expected: "Girl",
+		},
+		{
+			name: "no token",
+			email: func(string) InboundEmail {
+				return InboundEmail{From: "pat@example.com", Subject: "Re: hi", Text: "Both"}
+			},
+			err: ErrUnknownQuestion,
+		},
+		{
+			name: "unknown token",
+			email: func(string) InboundEmail {
+				return InboundEmail{From: "pat@example.com", Subject: emailToken(strings.Repeat("0", 32)), Text: "Both"}
+			},
+			err: ErrUnknownQuestion,
+		},
+		{
+			name: "other sender",
+			email: func(id string) InboundEmail {
+				return InboundEmail{From: "mallory@example.com", Subject: emailToken(id), Text: "Both"}
+			},
+			err: ErrUnknownSender,
+		},
+		{
+			name: "nothing above the quote",
+			email: func(id string) InboundEmail {
+				return InboundEmail{From: "pat@example.com", Subject: emailToken(id), Text: "> What gender?"}
+			},
+			err: ErrNoReplyText,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryAnswerStore()
+			interactor := NewEmailInteractor(&fakeMailer{}, "pat@example.com", store)
+			id := askByEmail(t, interactor, input)
+
+			err := interactor.HandleInbound(context.Background(), tt.email(id))
+
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			question, err := store.Question(context.Background(), id)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, question.Answer)
+			assert.ErrorIs(t, interactor.HandleInbound(context.Background(), tt.email(id)), ErrAlreadyAnswered)
+		})
+	}
+}
+
+func TestEmailInteractor_SuspendAndResume(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createToolRequestPart("askQuestion", "Approve the $80 budget?", []string{"Yes", "No"})),
+			createTextResponse("Based on the approved budget...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	mailer := &fakeMailer{}
+	interactor := NewEmailInteractor(mailer, "pat@example.com", NewMemoryAnswerStore())
+	handler := &InterruptionHandler{generator: mockGen, UserInteraction: interactor.Interact}
+
+	_, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+	var suspended *ErrRunSuspended
+	require.True(t, errors.As(err, &suspended), "unexpected error: %v", err)
+
+	require.Len(t, mailer.sent, 1)
+	question := mailer.sent[0]
+	assert.Equal(t, "pat@example.com", question.To)
+	assert.Equal(t, emailToken(suspended.Pending[0])+" Approve the $80 budget?", question.Subject)
+	assert.Contains(t, question.Body, "1. Yes\n2. No\n")
+
+	// the reply arrives hours later through the inbound-parse webhook
+	form := url.Values{
+		"from":    {"pat@example.com"},
+		"subject": {"Re: " + question.Subject},
+		"text":    {"1\n\nOn Mon, Agent wrote:\n> " + strings.ReplaceAll(question.Body, "\n", "\n> ")},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/email/inbound", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	interactor.InboundHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	response, err := handler.Resume(context.Background(), suspended)
+	require.NoError(t, err)
+	assert.Equal(t, "Based on the approved budget...", response.Text())
+	assert.Len(t, mailer.sent, 1, "a resumed question isn't emailed again")
+}
+
+func TestEmailInteractor_ExpireStale(t *testing.T) {
+	mailer := &fakeMailer{}
+	interactor := NewEmailInteractor(mailer, "pat@example.com", NewMemoryAnswerStore(), WithEmailAnswerTimeout(time.Hour))
+	id := askByEmail(t, interactor, QuestionInput{Question: "What is the budget?"})
+
+	expired, err := interactor.ExpireStale(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired, "the question is still fresh")
+
+	interactor.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	expired, err = interactor.ExpireStale(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	require.Len(t, mailer.sent, 2)
+	assert.Equal(t, "Re: "+emailToken(id)+" What is the budget?", mailer.sent[1].Subject)
+
+	// the notice is sent once, and late replies are dropped
+	expired, err = interactor.ExpireStale(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired)
+	err = interactor.HandleInbound(context.Background(), InboundEmail{From: "pat@example.com", Subject: emailToken(id), Text: "$80"})
+	assert.ErrorIs(t, err, ErrUnknownQuestion)
+
+	_, err = interactor.Interact(WithCorrelationID(context.Background(), id), QuestionInput{Question: "What is the budget?"})
+	var timeoutErr *ErrAnswerTimeout
+	assert.True(t, errors.As(err, &timeoutErr), "unexpected error: %v", err)
+}
+
+func TestEmailInteractor_PollInbox(t *testing.T) {
+	store := NewMemoryAnswerStore()
+	interactor := NewEmailInteractor(&fakeMailer{}, "pat@example.com", store)
+	id := askByEmail(t, interactor, QuestionInput{Question: "What ages?"})
+	inbox := &fakeInbox{batches: [][]InboundEmail{
+		{{From: "pat@example.com", Subject: "Re: unrelated", Text: "hello"}},
+		{{From: "pat@example.com", Subject: "Re: " + emailToken(id), Text: "8 and 11"}},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- interactor.PollInbox(ctx, inbox, time.Millisecond) }()
+
+	assert.Eventually(t, func() bool {
+		question, err := store.Question(context.Background(), id)
+		return err == nil && question.Answer == "8 and 11"
+	}, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestEmailInteractor_SendFailure(t *testing.T) {
+	interactor := NewEmailInteractor(&fakeMailer{sendErr: errors.New("connection refused")}, "pat@example.com", NewMemoryAnswerStore())
+
+	_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What ages?"})
+
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestSMTPSender(t *testing.T) {
+	sender := NewSMTPSender("smtp.example.com:587", "agent@example.com", nil)
+	var (
+		gotAddr string
+		gotTo   []string
+		gotMsg  string
+	)
+	sender.sendMail = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotTo, gotMsg = addr, to, string(msg)
+		assert.Equal(t, "agent@example.com", from)
+		return nil
+	}
+
+	err := sender.Send(context.Background(), EmailMessage{To: "pat@example.com", Subject: "Budget für Geschenke?", Body: "Line 1\nLine 2\n"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "smtp.example.com:587", gotAddr)
+	assert.Equal(t, []string{"pat@example.com"}, gotTo)
+	assert.Contains(t, gotMsg, "Subject: =?utf-8?q?Budget_f=C3=BCr_Geschenke=3F?=\r\n")
+	assert.True(t, strings.HasSuffix(gotMsg, "\r\n\r\nLine 1\r\nLine 2\r\n"), gotMsg)
+}
+
+func TestEmailQuestion(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    QuestionInput
+		expected string
+	}{
+		{
+			name:     "without reason",
+			input:    QuestionInput{Question: "What ages?"},
+			expected: "What ages?\n\nPut your answer on the first line of your reply.\n[ref:7]\n",
+		},
+		{
+			name:     "with reason",
+			input:    QuestionInput{Question: "What ages?", Reason: "Gifts differ by age"},
+			expected: "What ages?\n\nGifts differ by age\n\nPut your answer on the first line of your reply.\n[ref:7]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := emailQuestion("parent@example.com", "7", tt.input)
+
+			assert.Equal(t, tt.expected, message.Body)
+		})
+	}
+}
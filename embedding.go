@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// ErrNoEmbedder is returned by Embed when the generator has no embedder to call.
+var ErrNoEmbedder = errors.New("no embedder is set up")
+
+// Embed returns the embedding of the text by Embedder, or ErrNoEmbedder when Embedder is empty.
+func (g *GenkitGenerator) Embed(ctx context.Context, text string) ([]float32, error) {
+	if g.Embedder == "" {
+		return nil, ErrNoEmbedder
+	}
+	resp, err := genkit.Embed(ctx, g.AIClient, ai.WithEmbedderName(g.Embedder), ai.WithTextDocs(text))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) == 0 || len(resp.Embeddings[0].Embedding) == 0 {
+		return nil, fmt.Errorf("the embedder %s returned no embedding", g.Embedder)
+	}
+	return resp.Embeddings[0].Embedding, nil
+}
+
+// cosineSimilarity returns the cosine of the angle between the vectors, from -1 to 1, or 0 for vectors
+// of different lengths or without a direction, which an embedder doesn't return.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenkitGenerator_Embed(t *testing.T) {
+	g := genkit.Init(context.Background())
+	var texts []string
+	genkit.DefineEmbedder(g, "test/embedder", nil, func(_ context.Context, req *ai.EmbedRequest) (*ai.EmbedResponse, error) {
+		for _, doc := range req.Input {
+			texts = append(texts, doc.Content[0].Text)
+		}
+		return &ai.EmbedResponse{Embeddings: []*ai.Embedding{{Embedding: []float32{0.6, 0.8}}}}, nil
+	})
+	generator := &GenkitGenerator{AIClient: g, Embedder: "test/embedder"}
+
+	embedding, err := generator.Embed(context.Background(), "What is your budget?")
+
+	require.NoError(t, err)
+	assert.Equal(t, []float32{0.6, 0.8}, embedding)
+	assert.Equal(t, []string{"What is your budget?"}, texts)
+}
+
+func TestGenkitGenerator_EmbedFails(t *testing.T) {
+	g := genkit.Init(context.Background())
+	genkit.DefineEmbedder(g, "test/empty", nil, func(context.Context, *ai.EmbedRequest) (*ai.EmbedResponse, error) {
+		return &ai.EmbedResponse{}, nil
+	})
+	tests := []struct {
+		name     string
+		embedder string
+		err      string
+	}{
+		{name: "no embedder", err: ErrNoEmbedder.Error()},
+		{name: "unknown embedder", embedder: "test/unknown", err: "embedder not found"},
+		{name: "no embedding", embedder: "test/empty", err: "the embedder test/empty returned no embedding"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := &GenkitGenerator{AIClient: g, Embedder: tt.embedder}
+
+			_, err := generator.Embed(context.Background(), "What is your budget?")
+
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     []float32
+		expected float64
+	}{
+		{name: "same direction", a: []float32{1, 2}, b: []float32{2, 4}, expected: 1},
+		{name: "orthogonal", a: []float32{1, 0}, b: []float32{0, 3}, expected: 0},
+		{name: "opposite", a: []float32{1, 1}, b: []float32{-1, -1}, expected: -1},
+		{name: "in between", a: []float32{1, 0}, b: []float32{0.6, 0.8}, expected: 0.6},
+		{name: "different lengths", a: []float32{1, 0}, b: []float32{1, 0, 0}, expected: 0},
+		{name: "zero vector", a: []float32{0, 0}, b: []float32{1, 0}, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.expected, cosineSimilarity(tt.a, tt.b), 1e-6)
+		})
+	}
+}
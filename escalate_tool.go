@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineEscalateTool.
+const (
+	defaultEscalateToolName        = "escalateToHuman"
+	defaultEscalateToolDescription = "use this to hand the whole conversation to a human operator, such as when the user is upset or asks for something you can't help with; the response is the operator's instruction on how to continue"
+)
+
+// EscalateInput contains the reason to hand the conversation to a human operator.
+type EscalateInput struct {
+	Reason  string `json:"reason" jsonschema:"description=why a human operator is needed, such as 'The user wants to return a gift'"`
+	Summary string `json:"summary" jsonschema:"description=a few sentences summing up the conversation so far for the operator"`
+}
+
+// EscalationOutcome is the operator's decision on an escalated conversation: either an instruction the
+// agent continues with, or TakeOver to end the run and carry on with the user themselves.
+type EscalationOutcome struct {
+	Instruction string
+	TakeOver    bool
+}
+
+// EscalationFunc hands an escalated conversation to a human operator and returns their decision.
+type EscalationFunc func(ctx context.Context, escalation EscalateInput) (EscalationOutcome, error)
+
+// ErrEscalated is returned by RunAgent when a human operator took over an escalated conversation.
+type ErrEscalated struct {
+	// Reason and Summary are what the model escalated with.
+	Reason  string
+	Summary string
+	// History is the conversation up to the escalation, for the operator to continue from.
+	History []*ai.Message
+}
+
+func (e *ErrEscalated) Error() string {
+	return "conversation taken over by a human operator: " + e.Reason
+}
+
+// DefineEscalateTool defines the escalation tool, named "escalateToHuman" by default, in the Genkit
+// instance and returns it. Its interrupts are answered by the handler returned by NewEscalateHandler,
+// which must be registered for the tool name with RegisterToolHandler.
+func DefineEscalateTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultEscalateToolName, defaultEscalateToolDescription, opts)
+
+	return DefineInterruptTool[EscalateInput, string](g, config.name, config.description, WithInterruptMetadataKey("escalation"))
+}
+
+// NewEscalateHandler returns the ToolHandler of the escalation tool. It tells the user the conversation
+// is passed on with Notify and hands it to the operator with escalate. An operator instruction is given
+// to the model as the tool response, since the interrupted turn must be answered before the run can
+// continue; a take-over stops the run with ErrEscalated, whose History the InterruptionHandler fills in.
+func NewEscalateHandler(escalate EscalationFunc) ToolHandler {
+	return func(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+		var escalation EscalateInput
+		if err := decodeToolInput(input, &escalation); err != nil {
+			return ToolResult{}, err
+		}
+		if strings.TrimSpace(escalation.Reason) == "" {
+			return ToolResult{}, errors.New("escalate tool called without a reason")
+		}
+
+		if err := interactor.Notify(ctx, "Passing the conversation to a human operator..."); err != nil {
+			return ToolResult{}, err
+		}
+		outcome, err := escalate(ctx, escalation)
+		if err != nil {
+			return ToolResult{}, fmt.Errorf("failed to escalate the conversation: %w", err)
+		}
+		if outcome.TakeOver {
+			return ToolResult{}, &ErrEscalated{Reason: escalation.Reason, Summary: escalation.Summary}
+		}
+		return ToolResult{Output: "The human operator instructs: " + outcome.Instruction}, nil
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscalateHandler_ThroughToolHandler(t *testing.T) {
+	escalation := map[string]any{"reason": "The user wants to return a gift", "summary": "Gift ideas for two children; the user asks about a return."}
+
+	tests := []struct {
+		name        string
+		outcome     EscalationOutcome
+		escalateErr error
+		// output is the tool response the model continues with
+		output      string
+		expectedErr string
+	}{
+		{
+			name:    "continue with instruction",
+			outcome: EscalationOutcome{Instruction: "Returns are free within 30 days; tell the user and carry on."},
+			output:  "The human operator instructs: Returns are free within 30 days; tell the user and carry on.",
+		},
+		{
+			name:        "take over",
+			outcome:     EscalationOutcome{TakeOver: true},
+			expectedErr: "conversation taken over by a human operator: The user wants to return a gift",
+		},
+		{
+			name:        "escalation failure",
+			escalateErr: errors.New("no operator online"),
+			expectedErr: "failed to escalate the conversation: no operator online",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGen := NewMockGenerator(
+				[]*ai.ModelResponse{
+					createInterruptedResponse(createInterruptPart("escalateToHuman", escalation)),
+					createTextResponse("Returns are free within 30 days...", "stop"),
+				},
+				map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "escalateToHuman": createMockTool("escalateToHuman")},
+			)
+			var escalated EscalateInput
+			escalate := func(_ context.Context, input EscalateInput) (EscalationOutcome, error) {
+				escalated = input
+				return tt.outcome, tt.escalateErr
+			}
+			interactor := &sequenceInteractor{}
+			handler := &InterruptionHandler{generator: mockGen, Interactor: interactor}
+			handler.RegisterToolHandler("escalateToHuman", NewEscalateHandler(escalate))
+
+			result, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+
+			assert.Equal(t, EscalateInput{Reason: "The user wants to return a gift", Summary: "Gift ideas for two children; the user asks about a return."}, escalated)
+			assert.Equal(t, []string{"Passing the conversation to a human operator..."}, interactor.notifications)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				require.Len(t, mockGen.capturedCalls, 1, "the run stops at the escalation")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "Returns are free within 30 days...", result)
+			parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+			require.Len(t, parts, 1)
+			assert.Equal(t, tt.output, parts[0].ToolResponse.Output)
+		})
+	}
+}
+
+func TestEscalateHandler_TakeOverCarriesHistory(t *testing.T) {
+	interrupted := createInterruptedResponse(createInterruptPart("escalateToHuman", map[string]any{"reason": "Upset user", "summary": "The user is unhappy with the ideas."}))
+	mockGen := NewMockGenerator(nil, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "escalateToHuman": createMockTool("escalateToHuman")})
+	handler := &InterruptionHandler{generator: mockGen, Interactor: &sequenceInteractor{}}
+	handler.RegisterToolHandler("escalateToHuman", NewEscalateHandler(func(context.Context, EscalateInput) (EscalationOutcome, error) {
+		return EscalationOutcome{TakeOver: true}, nil
+	}))
+
+	_, err := handler.handleResponse(context.Background(), interrupted)
+
+	var escalated *ErrEscalated
+	require.ErrorAs(t, err, &escalated)
+	assert.Equal(t, "Upset user", escalated.Reason)
+	assert.Equal(t, "The user is unhappy with the ideas.", escalated.Summary)
+	assert.Equal(t, interrupted.History(), escalated.History)
+	assert.Empty(t, mockGen.capturedCalls)
+}
+
+func TestEscalateHandler_WithoutReason(t *testing.T) {
+	called := false
+	handler := NewEscalateHandler(func(context.Context, EscalateInput) (EscalationOutcome, error) {
+		called = true
+		return EscalationOutcome{}, nil
+	})
+
+	_, err := handler(context.Background(), &sequenceInteractor{}, map[string]any{"summary": "No reason given"})
+
+	assert.EqualError(t, err, "escalate tool called without a reason")
+	assert.False(t, called)
+}
+
+func TestDefineEscalateTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	escalate := DefineEscalateTool(g)
+	_, err := escalate.RunRaw(context.Background(), map[string]any{"reason": "Upset user", "summary": "The user is unhappy."})
+
+	assert.Equal(t, "escalateToHuman", escalate.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, EscalateInput{Reason: "Upset user", Summary: "The user is unhappy."}, metadata["escalation"])
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// Backend names the generator of a FallbackGenerator that served a call.
+type Backend string
+
+const (
+	BackendPrimary   Backend = "primary"
+	BackendSecondary Backend = "secondary"
+)
+
+// backendMetadataKey is the key of the message metadata holding the Backend that generated a response.
+const backendMetadataKey = "backend"
+
+// ServedBy returns the backend of a FallbackGenerator that generated the response. It reports false for
+// responses that didn't come through a FallbackGenerator.
+func ServedBy(resp *ai.ModelResponse) (Backend, bool) {
+	if resp == nil || resp.Message == nil {
+		return "", false
+	}
+	backend, ok := resp.Message.Metadata[backendMetadataKey].(Backend)
+	return backend, ok
+}
+
+// FallbackGenerator is a Generator calling a secondary generator, such as another model, when the
+// primary one fails, for example because it is overloaded.
+type FallbackGenerator struct {
+	primary        Generator
+	secondary      Generator
+	shouldFailover func(error) bool
+}
+
+// NewFallbackGenerator returns a Generator calling primary, and making the same call to secondary when
+// shouldFailover reports the error of primary; nil means IsTransientError. A call is never failed over
+// once ctx is done. Responses tell which generator served them through ServedBy.
+func NewFallbackGenerator(primary, secondary Generator, shouldFailover func(error) bool) *FallbackGenerator {
+	if shouldFailover == nil {
+		shouldFailover = IsTransientError
+	}
+	return &FallbackGenerator{primary: primary, secondary: secondary, shouldFailover: shouldFailover}
+}
+
+// Generate calls Generate of the primary generator, failing over to the secondary one.
+func (f *FallbackGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	var resp *ai.ModelResponse
+	backend, err := f.call(ctx, nil, func(generator Generator) error {
+		var err error
+		resp, err = generator.Generate(ctx, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return annotateBackend(resp, backend), nil
+}
+
+// GenerateStream calls GenerateStream of the primary generator, failing over to the secondary one
+// unless a chunk was already passed to cb.
+func (f *FallbackGenerator) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	streamed := false
+	stream := func(chunk *ai.ModelResponseChunk) error {
+		streamed = true
+		return cb(chunk)
+	}
+	var resp *ai.ModelResponse
+	backend, err := f.call(ctx, func() bool { return !streamed }, func(generator Generator) error {
+		var err error
+		resp, err = generator.GenerateStream(ctx, stream, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return annotateBackend(resp, backend), nil
+}
+
+// GenerateBool calls GenerateBool of the primary generator, failing over to the secondary one.
+func (f *FallbackGenerator) GenerateBool(ctx context.Context, prompt string, history []*ai.Message) (bool, error) {
+	var result bool
+	_, err := f.call(ctx, nil, func(generator Generator) error {
+		var err error
+		result, err = generator.GenerateBool(ctx, prompt, history)
+		return err
+	})
+	return result, err
+}
+
+// GenerateChecklist calls GenerateChecklist of the primary generator, failing over to the secondary
+// one.
+func (f *FallbackGenerator) GenerateChecklist(ctx context.Context, questions []string, history []*ai.Message) (map[string]bool, error) {
+	var answers map[string]bool
+	_, err := f.call(ctx, nil, func(generator Generator) error {
+		var err error
+		answers, err = generator.GenerateChecklist(ctx, questions, history)
+		return err
+	})
+	return answers, err
+}
+
+// GenerateJSON calls GenerateJSON of the primary generator, failing over to the secondary one.
+func (f *FallbackGenerator) GenerateJSON(ctx context.Context, system string, history []*ai.Message, out any) error {
+	_, err := f.call(ctx, nil, func(generator Generator) error {
+		return generator.GenerateJSON(ctx, system, history, out)
+	})
+	return err
+}
+
+// LookupTool looks up the tool with the primary generator; both generators share the tools.
+func (f *FallbackGenerator) LookupTool(name string) ai.Tool {
+	return f.primary.LookupTool(name)
+}
+
+// CountTokens counts the tokens for the model of the primary generator.
+func (f *FallbackGenerator) CountTokens(ctx context.Context, messages []*ai.Message) (TokenCount, error) {
+	return f.primary.CountTokens(ctx, messages)
+}
+
+// Embed embeds the text with the primary generator only, since the embeddings of two embedders can't be
+// compared.
+func (f *FallbackGenerator) Embed(ctx context.Context, text string) ([]float32, error) {
+	return f.primary.Embed(ctx, text)
+}
+
+// Ping pings the primary generator, without failing over: a failover hides an unready primary.
+func (f *FallbackGenerator) Ping(ctx context.Context) error {
+	return ping(ctx, f.primary)
+}
+
+// call makes the call with the primary generator, and with the secondary one when the primary one fails
+// with an error to fail over on and canFailover, when set, reports true. It returns the generator that
+// served the call; the error of a call failing with both holds both errors.
+func (f *FallbackGenerator) call(ctx context.Context, canFailover func() bool, call func(Generator) error) (Backend, error) {
+	err := call(f.primary)
+	if err == nil {
+		return BackendPrimary, nil
+	}
+	if ctx.Err() != nil || !f.shouldFailover(err) || (canFailover != nil && !canFailover()) {
+		return BackendPrimary, err
+	}
+	log.Printf("model call failed, failing over to the secondary model: %v", err)
+	if secondaryErr := call(f.secondary); secondaryErr != nil {
+		return BackendSecondary, errors.Join(fmt.Errorf("primary model: %w", err), fmt.Errorf("secondary model: %w", secondaryErr))
+	}
+	return BackendSecondary, nil
+}
+
+// annotateBackend records the backend in the metadata of the response message.
+func annotateBackend(resp *ai.ModelResponse, backend Backend) *ai.ModelResponse {
+	if resp == nil || resp.Message == nil {
+		return resp
+	}
+	if resp.Message.Metadata == nil {
+		resp.Message.Metadata = map[string]any{}
+	}
+	resp.Message.Metadata[backendMetadataKey] = backend
+	return resp
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallbackGenerator_Generate(t *testing.T) {
+	unavailable := core.NewError(core.UNAVAILABLE, "model overloaded")
+	badRequest := core.NewError(core.INVALID_ARGUMENT, "bad request")
+
+	tests := []struct {
+		name           string
+		primaryErrs    []error
+		secondaryErrs  []error
+		secondaryCalls int
+		backend        Backend
+		errs           []error
+	}{
+		{name: "primary succeeds", backend: BackendPrimary},
+		{name: "failover succeeds", primaryErrs: []error{unavailable}, secondaryCalls: 1, backend: BackendSecondary},
+		{name: "both fail", primaryErrs: []error{unavailable}, secondaryErrs: []error{badRequest}, secondaryCalls: 1, errs: []error{unavailable, badRequest}},
+		{name: "no failover", primaryErrs: []error{badRequest}, errs: []error{badRequest}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &flakyGenerator{errs: tt.primaryErrs}
+			secondary := &flakyGenerator{errs: tt.secondaryErrs}
+			generator := NewFallbackGenerator(primary, secondary, nil)
+
+			resp, err := generator.Generate(context.Background())
+
+			assert.Equal(t, 1, primary.calls)
+			assert.Equal(t, tt.secondaryCalls, secondary.calls)
+			if tt.errs != nil {
+				for _, expected := range tt.errs {
+					assert.ErrorIs(t, err, expected)
+				}
+				if tt.secondaryCalls == 0 {
+					assert.Equal(t, badRequest, err, "an error not failed over is returned as is")
+				} else {
+					assert.ErrorContains(t, err, "primary model: ")
+					assert.ErrorContains(t, err, "secondary model: ")
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "A LEGO set", resp.Text())
+			backend, ok := ServedBy(resp)
+			assert.True(t, ok)
+			assert.Equal(t, tt.backend, backend)
+		})
+	}
+}
+
+func TestFallbackGenerator_ShouldFailover(t *testing.T) {
+	overloaded := errors.New("model overloaded")
+	primary := &flakyGenerator{errs: []error{overloaded, overloaded}}
+	secondary := &flakyGenerator{}
+	generator := NewFallbackGenerator(primary, secondary, func(err error) bool { return errors.Is(err, overloaded) })
+
+	finished, err := generator.GenerateBool(context.Background(), "Is it finished?", nil)
+	require.NoError(t, err)
+	assert.True(t, finished)
+	var count int
+	require.NoError(t, generator.GenerateJSON(context.Background(), "How many gifts?", nil, &count))
+	assert.Equal(t, 3, count)
+
+	assert.Equal(t, 2, primary.calls)
+	assert.Equal(t, 2, secondary.calls)
+}
+
+func TestFallbackGenerator_GenerateStream(t *testing.T) {
+	tests := []struct {
+		name           string
+		chunkFirst     bool
+		secondaryCalls int
+		err            error
+	}{
+		{name: "fails before streaming", secondaryCalls: 1},
+		{name: "fails after streaming", chunkFirst: true, err: rateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &flakyGenerator{errs: []error{rateLimited}, chunkFirst: tt.chunkFirst}
+			secondary := &flakyGenerator{}
+			generator := NewFallbackGenerator(primary, secondary, nil)
+			var texts []string
+
+			resp, err := generator.GenerateStream(context.Background(), chunkTexts(&texts))
+
+			assert.Equal(t, tt.secondaryCalls, secondary.calls)
+			assert.Equal(t, []string{"A LEGO set"}, texts, "chunks are never streamed twice")
+			if tt.err != nil {
+				assert.Equal(t, tt.err, err)
+				return
+			}
+			require.NoError(t, err)
+			backend, _ := ServedBy(resp)
+			assert.Equal(t, BackendSecondary, backend)
+		})
+	}
+}
+
+func TestFallbackGenerator_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	secondary := &flakyGenerator{}
+	generator := NewFallbackGenerator(&flakyGenerator{errs: []error{rateLimited}}, secondary, nil)
+
+	_, err := generator.Generate(ctx)
+
+	assert.Equal(t, rateLimited, err)
+	assert.Zero(t, secondary.calls)
+}
+
+func TestServedBy(t *testing.T) {
+	_, ok := ServedBy(createTextResponse("A LEGO set", "stop"))
+	assert.False(t, ok)
+	_, ok = ServedBy(&ai.ModelResponse{})
+	assert.False(t, ok)
+}
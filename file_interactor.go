@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileAnswer is the content of an answer file.
+type FileAnswer struct {
+	Answer string `json:"answer"`
+}
+
+// FileInteractor exchanges questions and answers as files in a directory, for batch environments where
+// the user is a process that runs from time to time. Every question is written to question-<id>.json
+// and answered by writing {"answer": "..."} to answer-<id>.json; both files are removed once the answer
+// is read. An answer file that can't be parsed yet, for example because it is still being written, is
+// read again on the next poll.
+type FileInteractor struct {
+	dir          string
+	pollInterval time.Duration
+	deadline     time.Duration
+}
+
+// FileOption configures a FileInteractor.
+type FileOption func(*FileInteractor)
+
+// WithFilePollInterval sets how often the directory is checked for the answer file.
+func WithFilePollInterval(interval time.Duration) FileOption {
+	return func(f *FileInteractor) {
+		f.pollInterval = interval
+	}
+}
+
+// WithFileAnswerDeadline sets how long Interact waits for the answer file.
+// Zero, the default, waits until the context is done.
+func WithFileAnswerDeadline(deadline time.Duration) FileOption {
+	return func(f *FileInteractor) {
+		f.deadline = deadline
+	}
+}
+
+// NewFileInteractor creates a FileInteractor exchanging files in dir, which must exist.
+func NewFileInteractor(dir string, opts ...FileOption) *FileInteractor {
+	f := &FileInteractor{dir: dir, pollInterval: defaultPollInterval}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Interact writes the question file and polls for the answer file until it holds a valid answer,
+// the deadline passes or the context is done. An empty answer selects the question's default.
+// It implements UserInteractionFunc.
+func (f *FileInteractor) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	id, err := newCorrelationID()
+	if err != nil {
+		return "", err
+	}
+	questionPath := filepath.Join(f.dir, "question-"+id+".json")
+	answerPath := filepath.Join(f.dir, "answer-"+id+".json")
+	if err := writeFileAtomic(questionPath, input); err != nil {
+		return "", fmt.Errorf("failed to write the question: %w", err)
+	}
+	// the files are removed whatever the outcome so that a stale question is never answered
+	defer func() {
+		_ = os.Remove(questionPath)
+		_ = os.Remove(answerPath)
+	}()
+
+	deadline := questionTimeout(input, f.deadline, defaultMaxAnswerTimeout)
+	// a nil channel never fires, so a disabled deadline waits for the answer or the context
+	var deadlineCh <-chan time.Time
+	if deadline > 0 {
+		timer := time.NewTimer(deadline)
+		defer timer.Stop()
+		deadlineCh = timer.C
+	}
+	ticker := time.NewTicker(f.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		answer, ok, err := readAnswerFile(answerPath, input.Default)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return answer, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-deadlineCh:
+			return "", &ErrAnswerTimeout{Question: input.Question, Timeout: deadline}
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// readAnswerFile reads the answer from the file. It reports false while the file is missing or doesn't
+// hold a valid answer yet, and fails only when the file can't be read at all.
+func readAnswerFile(path, defaultAnswer string) (string, bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read the answer: %w", err)
+	}
+
+	var answer FileAnswer
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return "", false, nil
+	}
+	value := strings.TrimSpace(answer.Answer)
+	if value == "" {
+		value = defaultAnswer
+	}
+	return value, value != "", nil
+}
+
+// writeFileAtomic writes the value as JSON to a temporary file and renames it into place,
+// so that the other side never sees a partially written file.
+func writeFileAtomic(path string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForQuestionFile waits until a question file appears in dir and returns its ID and content.
+func waitForQuestionFile(t *testing.T, dir string) (string, QuestionInput) {
+	t.Helper()
+	var path string
+	require.Eventually(t, func() bool {
+		matches, err := filepath.Glob(filepath.Join(dir, "question-*.json"))
+		require.NoError(t, err)
+		if len(matches) == 0 {
+			return false
+		}
+		path = matches[0]
+		return true
+	}, time.Second, time.Millisecond)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var input QuestionInput
+	require.NoError(t, json.Unmarshal(data, &input))
+	id := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "question-"), ".json")
+	return id, input
+}
+
+// writeAnswerFile writes the raw content of the answer file for the question.
+func writeAnswerFile(t *testing.T, dir, id, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "answer-"+id+".json"), []byte(content), 0o600))
+}
+
+func TestFileInteractor_RunAgent(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createToolRequestPart("askQuestion", "What are their ages?", nil),
+			),
+			createTextResponse("Based on both genders and ages...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	dir := t.TempDir()
+	interactor := NewFileInteractor(dir, WithFilePollInterval(time.Millisecond), WithFileAnswerDeadline(time.Second))
+
+	answers := map[string]string{
+		"What gender are the children?": "Both",
+		"What are their ages?":          "8 and 11",
+	}
+	go func() {
+		for range answers {
+			id, input := waitForQuestionFile(t, dir)
+			// a partially written answer is retried rather than taken as final
+			writeAnswerFile(t, dir, id, `{"answer": "Bo`)
+			time.Sleep(5 * time.Millisecond)
+			writeAnswerFile(t, dir, id, `{"answer": "`+answers[input.Question]+`"}`)
+			assert.Eventually(t, func() bool {
+				_, err := os.Stat(filepath.Join(dir, "question-"+id+".json"))
+				return errors.Is(err, os.ErrNotExist)
+			}, time.Second, time.Millisecond)
+		}
+	}()
+
+	result, err := RunAgent(context.Background(), &Options{
+		generator: mockGen,
+		responseHandler: &InterruptionHandler{
+			generator:       mockGen,
+			UserInteraction: interactor.Interact,
+		},
+	})
+
+	require.NoError(t, err)
+	assert.Contains(t, result, "Based on")
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the exchanged files are cleaned up")
+}
+
+func TestFileInteractor_Answer(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    QuestionInput
+		content  string
+		expected string
+	}{
+		{name: "answer", input: QuestionInput{Question: "What gender?"}, content: `{"answer": " Boy "}`, expected: "Boy"},
+		{name: "empty answer selects the default", input: QuestionInput{Question: "What gender?", Default: "Both"}, content: `{"answer": ""}`, expected: "Both"},
+		{name: "empty answer without default", input: QuestionInput{Question: "What gender?"}, content: `{"answer": ""}`},
+		{name: "invalid answer", input: QuestionInput{Question: "What gender?"}, content: `Boy`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			interactor := NewFileInteractor(dir, WithFilePollInterval(time.Millisecond), WithFileAnswerDeadline(50*time.Millisecond))
+
+			type result struct {
+				answer string
+				err    error
+			}
+			done := make(chan result, 1)
+			go func() {
+				answer, err := interactor.Interact(context.Background(), tt.input)
+				done <- result{answer, err}
+			}()
+			id, input := waitForQuestionFile(t, dir)
+			assert.Equal(t, tt.input, input)
+			writeAnswerFile(t, dir, id, tt.content)
+
+			res := <-done
+			if tt.expected == "" {
+				var timeoutErr *ErrAnswerTimeout
+				assert.True(t, errors.As(res.err, &timeoutErr), "unexpected error: %v", res.err)
+			} else {
+				require.NoError(t, res.err)
+				assert.Equal(t, tt.expected, res.answer)
+			}
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestFileInteractor_Cancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := NewFileInteractor(t.TempDir(), WithFilePollInterval(time.Millisecond)).Interact(ctx, QuestionInput{Question: "What gender?"})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestFileInteractor_MissingDir(t *testing.T) {
+	_, err := NewFileInteractor(filepath.Join(t.TempDir(), "missing")).Interact(context.Background(), QuestionInput{Question: "What gender?"})
+
+	assert.ErrorContains(t, err, "failed to write the question")
+}
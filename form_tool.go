@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineFormTool.
+const (
+	defaultFormToolName        = "askForm"
+	defaultFormToolDescription = "use this to ask the user several questions at once as a small form; the response maps the field names to the answers"
+)
+
+// Types of form fields.
+const (
+	FormFieldText   = "text"
+	FormFieldChoice = "choice"
+	FormFieldNumber = "number"
+	// FormFieldCheckbox is a box to tick, entered as "true" when ticked; a blank value is unticked.
+	FormFieldCheckbox = "checkbox"
+)
+
+// FormField is one field of a form.
+type FormField struct {
+	Name     string   `json:"name" jsonschema:"description=the key of the answer in the response"`
+	Label    string   `json:"label" jsonschema:"description=the question shown to the user"`
+	Type     string   `json:"type" jsonschema:"enum=text,enum=choice,enum=number,enum=checkbox"`
+	Choices  []string `json:"choices,omitempty" jsonschema:"description=the choices of a choice field"`
+	Required bool     `json:"required,omitempty" jsonschema:"description=set when the field can't be left blank"`
+}
+
+// FormInput contains a form of several fields answered in one interruption.
+type FormInput struct {
+	Title  string      `json:"title" jsonschema:"description=what the form is about"`
+	Fields []FormField `json:"fields" jsonschema:"description=the fields in the order they are asked"`
+}
+
+// FormAsker is implemented by interactors that show a whole form at once instead of asking its fields
+// one by one.
+type FormAsker interface {
+	// AskForm shows the form and returns the entered values by field name.
+	AskForm(ctx context.Context, form FormInput) (map[string]string, error)
+}
+
+// DefineFormTool defines the form tool, named "askForm" by default, in the Genkit instance and returns
+// it. Its interrupts are answered by HandleForm, which must be registered for the tool name with
+// RegisterToolHandler.
+func DefineFormTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultFormToolName, defaultFormToolDescription, opts)
+
+	return DefineInterruptTool[FormInput, map[string]any](g, config.name, config.description, WithInterruptMetadataKey("form"))
+}
+
+// HandleForm is the ToolHandler of the form tool. Interactors implementing FormAsker show the whole
+// form; the others are asked a question per field, after the title is shown with Notify. Required
+// fields must be filled in and number fields hold numbers, otherwise the form or the field is asked
+// again. It responds with the answers by field name: text and choice fields as strings, number fields
+// as numbers, and blank optional fields left out.
+func HandleForm(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var form FormInput
+	if err := decodeToolInput(input, &form); err != nil {
+		return ToolResult{}, err
+	}
+	if err := form.validate(); err != nil {
+		return ToolResult{}, err
+	}
+
+	if asker, ok := interactor.(FormAsker); ok {
+		values, err := askWholeForm(ctx, interactor, asker, form)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		return ToolResult{Output: values}, nil
+	}
+
+	if form.Title != "" {
+		if err := interactor.Notify(ctx, form.Title); err != nil {
+			return ToolResult{}, err
+		}
+	}
+	values := make(map[string]any, len(form.Fields))
+	for _, field := range form.Fields {
+		value, err := askParsed(ctx, interactor, QuestionInput{Question: field.question(), Choices: field.Choices}, field.parse)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		if value != nil {
+			values[field.Name] = value
+		}
+	}
+	return ToolResult{Output: values}, nil
+}
+
+// askWholeForm shows the form until its values are valid, up to maxParseAttempts times.
+func askWholeForm(ctx context.Context, interactor Interactor, asker FormAsker, form FormInput) (map[string]any, error) {
+	var err error
+	for range maxParseAttempts {
+		var entered map[string]string
+		if entered, err = asker.AskForm(ctx, form); err != nil {
+			return nil, err
+		}
+		var values map[string]any
+		if values, err = form.parse(entered); err == nil {
+			return values, nil
+		}
+		if notifyErr := interactor.Notify(ctx, invalidAnswerMessage(err)); notifyErr != nil {
+			return nil, notifyErr
+		}
+	}
+	return nil, fmt.Errorf("no valid answer after %d attempts: %w", maxParseAttempts, err)
+}
+
+// validate reports forms that can't be shown or answered.
+func (f FormInput) validate() error {
+	if len(f.Fields) == 0 {
+		return errors.New("form tool called without fields")
+	}
+	names := make(map[string]bool, len(f.Fields))
+	for _, field := range f.Fields {
+		switch {
+		case field.Name == "":
+			return errors.New("form tool called with a field without a name")
+		case names[field.Name]:
+			return fmt.Errorf("form tool called with the field %q twice", field.Name)
+		case field.Type == FormFieldChoice && len(field.Choices) == 0:
+			return fmt.Errorf("form tool called with the choice field %q without choices", field.Name)
+		case !slices.Contains([]string{FormFieldText, FormFieldChoice, FormFieldNumber, FormFieldCheckbox}, field.Type):
+			return fmt.Errorf("form tool called with the field %q of unknown type %q", field.Name, field.Type)
+		}
+		names[field.Name] = true
+	}
+	return nil
+}
+
+// parse checks and converts the values entered for all fields, reporting the problems of every field.
+func (f FormInput) parse(entered map[string]string) (map[string]any, error) {
+	values := make(map[string]any, len(f.Fields))
+	var errs []error
+	for _, field := range f.Fields {
+		value, err := field.parse(entered[field.Name])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field.label(), err))
+			continue
+		}
+		if value != nil {
+			values[field.Name] = value
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return values, nil
+}
+
+// parse converts the value entered for the field. A blank optional field parses to nil, and a blank
+// checkbox to false.
+func (f FormField) parse(entered string) (any, error) {
+	entered = strings.TrimSpace(entered)
+	if f.Type == FormFieldCheckbox {
+		return parseCheckbox(entered)
+	}
+	if entered == "" {
+		if f.Required {
+			return nil, errors.New("a value is required")
+		}
+		return nil, nil
+	}
+
+	switch f.Type {
+	case FormFieldChoice:
+		if choice, ok := matchChoice(f.Choices, entered); ok {
+			return choice, nil
+		}
+		if n, err := strconv.Atoi(entered); err == nil && n >= 1 && n <= len(f.Choices) {
+			return f.Choices[n-1], nil
+		}
+		return nil, fmt.Errorf("expected one of: %s", strings.Join(f.Choices, ", "))
+	case FormFieldNumber:
+		n, err := strconv.ParseFloat(entered, 64)
+		if err != nil {
+			return nil, errors.New("expected a number")
+		}
+		return n, nil
+	default:
+		return entered, nil
+	}
+}
+
+// label returns the text the field is shown with.
+func (f FormField) label() string {
+	if f.Label != "" {
+		return f.Label
+	}
+	return f.Name
+}
+
+// question returns the question asking for the field on its own.
+func (f FormField) question() string {
+	if f.Type == FormFieldCheckbox {
+		return f.label() + " (y/n)"
+	}
+	if !f.Required {
+		return f.label() + " (optional)"
+	}
+	return f.label()
+}
+
+// parseCheckbox reads the value of a checkbox, entered as true or false by forms and as y or n when the
+// field is asked on its own. A blank value is unticked.
+func parseCheckbox(entered string) (any, error) {
+	switch strings.ToLower(entered) {
+	case "true", "on", "y", "yes":
+		return true, nil
+	case "", "false", "off", "n", "no":
+		return false, nil
+	default:
+		return nil, errors.New("expected y or n")
+	}
+}
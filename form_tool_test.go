@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// giftForm is a three-field form whose last field is optional.
+func giftForm() map[string]any {
+	return map[string]any{
+		"title": "About the children",
+		"fields": []any{
+			map[string]any{"name": "age", "label": "How old is the child?", "type": "number", "required": true},
+			map[string]any{"name": "gender", "label": "Gender", "type": "choice", "choices": []any{"Boy", "Girl"}, "required": true},
+			map[string]any{"name": "interests", "label": "Any interests?", "type": "text"},
+		},
+	}
+}
+
+func TestFormField_Parse(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    FormField
+		entered  string
+		expected any
+		err      string
+	}{
+		{name: "text", field: FormField{Type: FormFieldText}, entered: " Lego ", expected: "Lego"},
+		{name: "number", field: FormField{Type: FormFieldNumber}, entered: "8.5", expected: 8.5},
+		{name: "not a number", field: FormField{Type: FormFieldNumber}, entered: "eight", err: "expected a number"},
+		{name: "choice text", field: FormField{Type: FormFieldChoice, Choices: []string{"Boy", "Girl"}}, entered: "girl", expected: "Girl"},
+		{name: "choice number", field: FormField{Type: FormFieldChoice, Choices: []string{"Boy", "Girl"}}, entered: "1", expected: "Boy"},
+		{name: "unknown choice", field: FormField{Type: FormFieldChoice, Choices: []string{"Boy", "Girl"}}, entered: "3", err: "expected one of: Boy, Girl"},
+		{name: "blank optional", field: FormField{Type: FormFieldNumber}, entered: " ", expected: nil},
+		{name: "blank required", field: FormField{Type: FormFieldText, Required: true}, entered: "", err: "a value is required"},
+		{name: "ticked checkbox", field: FormField{Type: FormFieldCheckbox}, entered: "true", expected: true},
+		{name: "checkbox answered on its own", field: FormField{Type: FormFieldCheckbox}, entered: "Y", expected: true},
+		{name: "blank checkbox", field: FormField{Type: FormFieldCheckbox, Required: true}, entered: "", expected: false},
+		{name: "unclear checkbox", field: FormField{Type: FormFieldCheckbox}, entered: "maybe", err: "expected y or n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := tt.field.parse(tt.entered)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestFormInput_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		form FormInput
+		err  string
+	}{
+		{name: "no fields", form: FormInput{Title: "Empty"}, err: "without fields"},
+		{name: "duplicate name", form: FormInput{Fields: []FormField{{Name: "a", Type: FormFieldText}, {Name: "a", Type: FormFieldText}}}, err: `field "a" twice`},
+		{name: "choice without choices", form: FormInput{Fields: []FormField{{Name: "a", Type: FormFieldChoice}}}, err: "without choices"},
+		{name: "unknown type", form: FormInput{Fields: []FormField{{Name: "a", Type: "date"}}}, err: `unknown type "date"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.form.validate(), tt.err)
+		})
+	}
+}
+
+func TestHandleForm_Terminal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askForm", giftForm())),
+			createTextResponse("For an 8 year old boy...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askForm": createMockTool("askForm")},
+	)
+	var out bytes.Buffer
+	// the age is asked again until it is a number, and the optional interests are left blank
+	terminal := NewTerminalReader(ctx, strings.NewReader("eight\n8\n1\n\n"), WithAnswerTimeout(time.Second), WithOutput(&out), WithWrapping(false))
+	handler := &InterruptionHandler{generator: mockGen, Interactor: terminal}
+	handler.RegisterToolHandler("askForm", HandleForm)
+
+	_, err := RunAgent(ctx, &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, map[string]any{"age": 8.0, "gender": "Boy"}, parts[0].ToolResponse.Output)
+	assert.Contains(t, out.String(), "About the children\nHow old is the child?\n")
+	assert.Contains(t, out.String(), "Invalid answer: expected a number\n")
+	assert.Contains(t, out.String(), "Any interests? (optional)\n")
+}
+
+// fakeFormAsker is an interactor returning the forms in order.
+type fakeFormAsker struct {
+	fakeInteractor
+	forms []map[string]string
+}
+
+func (f *fakeFormAsker) AskForm(context.Context, FormInput) (map[string]string, error) {
+	form := f.forms[0]
+	f.forms = f.forms[1:]
+	return form, nil
+}
+
+func TestHandleForm_WholeForm(t *testing.T) {
+	asker := &fakeFormAsker{forms: []map[string]string{
+		{"age": "8", "interests": "Lego"},
+		{"age": "8", "gender": "2", "interests": " "},
+	}}
+
+	result, err := HandleForm(context.Background(), asker, giftForm())
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"age": 8.0, "gender": "Girl"}, result.Output)
+	assert.Equal(t, []string{"Invalid answer: Gender: a value is required"}, asker.notified)
+}
+
+func TestDefineFormTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	tool := DefineFormTool(g)
+	_, err := tool.RunRaw(context.Background(), giftForm())
+
+	assert.Equal(t, "askForm", tool.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	form, ok := metadata["form"].(FormInput)
+	require.True(t, ok)
+	assert.Len(t, form.Fields, 3)
+}
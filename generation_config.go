@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// deterministicSeed is the seed of the model calls of deterministic runs.
+const deterministicSeed = 42
+
+// The sampling settings a deterministic run pins, greedy sampling with a fixed seed, in the config keys
+// of the Gemini models and of the OpenAI-compatible ones, and of a model whose provider isn't known.
+var (
+	geminiDeterministicSettings = map[string]any{"temperature": 0, "topK": 1, "topP": 1, "seed": deterministicSeed}
+	openAIDeterministicSettings = map[string]any{"temperature": 0, "top_p": 1, "seed": deterministicSeed}
+	commonDeterministicSettings = map[string]any{"temperature": 0, "seed": deterministicSeed}
+)
+
+// deterministicSettings returns the deterministic settings of the provider of the model, by the prefix
+// of its name. A model of another provider than googleai and vertexai is one of an OpenAI-compatible
+// API, and the settings of an unnamed model, the default one of Genkit, are those common to both.
+func deterministicSettings(model string) map[string]any {
+	if model == "" {
+		return commonDeterministicSettings
+	}
+	switch provider, _, _ := strings.Cut(model, "/"); provider {
+	case ProviderGoogleAI, ProviderVertexAI:
+		return geminiDeterministicSettings
+	default:
+		return openAIDeterministicSettings
+	}
+}
+
+type generationConfigKey struct{}
+
+type deterministicSamplingKey struct{}
+
+// withDeterministicSampling returns a context whose model calls made by GenkitGenerator, including the
+// calls of the response handlers, have the deterministic settings of the provider of their model.
+func withDeterministicSampling(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deterministicSamplingKey{}, true)
+}
+
+// deterministicSampling reports whether the model calls of the context are deterministic.
+func deterministicSampling(ctx context.Context) bool {
+	deterministic, _ := ctx.Value(deterministicSamplingKey{}).(bool)
+	return deterministic
+}
+
+// deterministicMiddleware returns a model middleware adding the deterministic settings of the model to
+// the config of each request. A config of another type than a map, such as a typed config of the
+// provider, is left as is.
+func deterministicMiddleware(model string) ai.ModelMiddleware {
+	settings := deterministicSettings(model)
+	return func(next ai.ModelFunc) ai.ModelFunc {
+		return func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+			config, ok := req.Config.(map[string]any)
+			if req.Config != nil && !ok {
+				return next(ctx, req, cb)
+			}
+			withSettings := *req
+			pinned := maps.Clone(config)
+			if pinned == nil {
+				pinned = map[string]any{}
+			}
+			maps.Copy(pinned, settings)
+			withSettings.Config = pinned
+			return next(ctx, &withSettings, cb)
+		}
+	}
+}
+
+// withGenerationConfig returns a context whose model calls made with generateResponse and
+// GenkitGenerator.GenerateJSON, including the calls of the response handlers, use config.
+func withGenerationConfig(ctx context.Context, config map[string]any) context.Context {
+	return context.WithValue(ctx, generationConfigKey{}, config)
+}
+
+// generationConfigOptions returns the option setting the generation config of the run, if it has one.
+func generationConfigOptions(ctx context.Context) []ai.GenerateOption {
+	config, ok := ctx.Value(generationConfigKey{}).(map[string]any)
+	if !ok {
+		return nil
+	}
+	return []ai.GenerateOption{ai.WithConfig(config)}
+}
+
+// generationConfig returns the config of the model calls of the run. The sampling settings of a
+// deterministic run are added by GenkitGenerator for the provider of its model, and a setting of config
+// other than the value a provider pins is an error rather than overridden.
+func (o *Options) generationConfig() (map[string]any, error) {
+	if !o.deterministic {
+		return o.config, nil
+	}
+	for key, value := range o.config {
+		for _, settings := range []map[string]any{geminiDeterministicSettings, openAIDeterministicSettings} {
+			if pinned, ok := settings[key]; ok && fmt.Sprint(value) != fmt.Sprint(pinned) {
+				return nil, fmt.Errorf("the config sets %s to %v, but a deterministic run pins it to %v", key, value, pinned)
+			}
+		}
+	}
+	return o.config, nil
+}
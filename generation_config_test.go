@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunAgent_Deterministic(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{
+		createInterruptedResponse(createToolRequestPart("askQuestion", "How old are the children?", []string{"8 and 11"})),
+		createTextResponse("A LEGO set", "stop"),
+	}, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")})
+
+	text, err := RunAgent(context.Background(), &Options{
+		generator:     mockGen,
+		userPrompt:    "Suggest a gift.",
+		deterministic: true,
+		responseHandler: &InterruptionHandler{
+			generator: mockGen,
+			UserInteraction: func(context.Context, QuestionInput) (string, error) {
+				return "8 and 11", nil
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "A LEGO set", text)
+	require.Len(t, mockGen.capturedCalls, 2)
+	for _, call := range mockGen.capturedCalls {
+		assert.True(t, call.Deterministic, "the handler's continuation is deterministic too")
+		assert.NotContains(t, capturedPromptFields(t, call.Options), "Config", "the settings are added for the provider of the model")
+	}
+}
+
+func TestRunAgent_WithoutConfig(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("A LEGO set", "stop")}, nil)
+
+	_, err := RunAgent(context.Background(), &Options{generator: mockGen, userPrompt: "Suggest a gift."})
+
+	require.NoError(t, err)
+	require.Len(t, mockGen.capturedCalls, 1)
+	assert.NotContains(t, capturedPromptFields(t, mockGen.capturedCalls[0].Options), "Config")
+}
+
+func TestRunAgent_DeterministicConflictingConfig(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("A LEGO set", "stop")}, nil)
+
+	_, err := RunAgent(context.Background(), &Options{
+		generator:     mockGen,
+		userPrompt:    "Suggest a gift.",
+		config:        map[string]any{"temperature": 0.7},
+		deterministic: true,
+	})
+
+	assert.EqualError(t, err, "the config sets temperature to 0.7, but a deterministic run pins it to 0")
+	assert.Empty(t, mockGen.capturedCalls, "the model isn't called")
+}
+
+func TestOptions_GenerationConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  Options
+		expected map[string]any
+		err      string
+	}{
+		{name: "none", options: Options{}, expected: nil},
+		{name: "config", options: Options{config: map[string]any{"temperature": 0.7}}, expected: map[string]any{"temperature": 0.7}},
+		{name: "deterministic", options: Options{deterministic: true}, expected: nil},
+		{
+			name:     "deterministic with other settings",
+			options:  Options{deterministic: true, config: map[string]any{"maxOutputTokens": 800, "temperature": 0.0}},
+			expected: map[string]any{"maxOutputTokens": 800, "temperature": 0.0},
+		},
+		{name: "deterministic with another seed", options: Options{deterministic: true, config: map[string]any{"seed": 7}}, err: "the config sets seed to 7, but a deterministic run pins it to 42"},
+		{name: "deterministic with another Gemini top-k", options: Options{deterministic: true, config: map[string]any{"topK": 40}}, err: "the config sets topK to 40"},
+		{name: "deterministic with another OpenAI top-p", options: Options{deterministic: true, config: map[string]any{"top_p": 0.9}}, err: "the config sets top_p to 0.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.options.generationConfig()
+
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				assert.ErrorContains(t, tt.options.Validate(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, config)
+		})
+	}
+}
+
+func TestGenkitGenerator_Deterministic(t *testing.T) {
+	tests := []struct {
+		model    string
+		expected map[string]any
+	}{
+		{model: "googleai/gemini-2.5-flash", expected: map[string]any{"temperature": 0, "topK": 1, "topP": 1, "seed": deterministicSeed}},
+		{model: "vertexai/gemini-2.5-flash", expected: map[string]any{"temperature": 0, "topK": 1, "topP": 1, "seed": deterministicSeed}},
+		{model: "openai/gpt-4o-mini", expected: map[string]any{"temperature": 0, "top_p": 1, "seed": deterministicSeed}},
+		{model: "groq/llama-3.3-70b", expected: map[string]any{"temperature": 0, "top_p": 1, "seed": deterministicSeed}},
+		{model: "", expected: map[string]any{"temperature": 0, "seed": deterministicSeed}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			var configs []any
+			g := genkit.Init(context.Background(), genkit.WithDefaultModel("test/default"))
+			for _, name := range []string{"test/default", tt.model} {
+				if name == "" {
+					continue
+				}
+				genkit.DefineModel(g, name, &ai.ModelOptions{Supports: &ai.ModelSupports{Multiturn: true, SystemRole: true}},
+					func(_ context.Context, req *ai.ModelRequest, _ ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+						configs = append(configs, req.Config)
+						return &ai.ModelResponse{Message: ai.NewModelTextMessage(trueAnswer), FinishReason: ai.FinishReasonStop}, nil
+					})
+			}
+			generator := &GenkitGenerator{AIClient: g, Model: tt.model}
+			ctx := withDeterministicSampling(withGenerationConfig(context.Background(), map[string]any{"maxOutputTokens": 800}))
+
+			_, err := generator.Generate(ctx, ai.WithPrompt("Suggest a gift."))
+			require.NoError(t, err)
+			answer, err := generator.GenerateBool(ctx, "Is the conversation finished?", nil)
+			require.NoError(t, err)
+
+			assert.True(t, answer)
+			withConfig := maps.Clone(tt.expected)
+			withConfig["maxOutputTokens"] = 800
+			assert.Equal(t, []any{tt.expected, withConfig}, configs, "only the keys of the provider are sent, along with the config of the run")
+		})
+	}
+}
@@ -2,19 +2,168 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
 	"github.com/firebase/genkit/go/genkit"
+	"github.com/xeipuuv/gojsonschema"
+	"google.golang.org/genai"
 )
 
+// boolInstruction is the system instruction of GenerateBoolScored; the question itself is sent as the
+// last user message, so that the model doesn't answer the last message of the history instead.
+const boolInstruction = "Answer the question in the last user message strictly with true or false, " +
+	"tell how sure you are of the answer from 0 to 1, and why in one sentence."
+
+// boolAttempts is how many times GenerateBoolScored asks when the model's answer isn't a boolean.
+const boolAttempts = 2
+
+// scoredBool is the structured answer of GenerateBoolScored. Value is a pointer to tell a null answer
+// from false.
+type scoredBool struct {
+	Value      *bool   `json:"value" jsonschema:"description=the answer to the question"`
+	Confidence float64 `json:"confidence" jsonschema:"description=how sure you are of the answer from 0 for a guess to 1 for certain"`
+	Rationale  string  `json:"rationale" jsonschema:"description=why the answer is true or false in one sentence"`
+}
+
+// ErrOutputMismatch is returned when the output of the model doesn't hold the requested type. Text is
+// the raw output, kept for debugging.
+type ErrOutputMismatch struct {
+	Text string
+	Err  error
+}
+
+func (e *ErrOutputMismatch) Error() string {
+	return fmt.Sprintf("the model output doesn't match the expected type: %v (the model answered %q)", e.Err, e.Text)
+}
+
+func (e *ErrOutputMismatch) Unwrap() error {
+	return e.Err
+}
+
+type modelMiddlewareKey struct{}
+
+type calledModelKey struct{}
+
+// withCalledModel returns a context whose model calls are of the model, for the middleware of the calls.
+func withCalledModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, calledModelKey{}, model)
+}
+
+// calledModel returns the model of the calls of the context, or "" for the default model of Genkit.
+func calledModel(ctx context.Context) string {
+	model, _ := ctx.Value(calledModelKey{}).(string)
+	return model
+}
+
+// withModelMiddleware returns a context whose model calls made by GenkitGenerator go through the
+// middleware, such as the cache of a CachingGenerator. Genkit takes the middleware of a call at once, so
+// it is passed along the context by the generators wrapping GenkitGenerator; the middleware added last
+// runs first.
+func withModelMiddleware(ctx context.Context, middleware ai.ModelMiddleware) context.Context {
+	return context.WithValue(ctx, modelMiddlewareKey{}, append([]ai.ModelMiddleware{middleware}, modelMiddleware(ctx)...))
+}
+
+// modelMiddleware returns the middleware of the model calls made with ctx.
+func modelMiddleware(ctx context.Context) []ai.ModelMiddleware {
+	middleware, _ := ctx.Value(modelMiddlewareKey{}).([]ai.ModelMiddleware)
+	return middleware
+}
+
 // GenkitGenerator is a wrapper around genkit.Genkit that implements the Generator interface.
 type GenkitGenerator struct {
 	AIClient *genkit.Genkit
+	// Model is the name of the model to call, such as "googleai/gemini-2.5-flash-lite". The default
+	// model of AIClient is called when it's empty.
+	Model string
+	// SafetySettings are added to the config of every call to a Gemini model, unless the call sets safety
+	// settings of its own, such as to relax the filters blocking harmless questions about children.
+	SafetySettings []*genai.SafetySetting
+	// Embedder is the name of the embedder Embed calls, with the prefix of its provider, such as
+	// "googleai/gemini-embedding-001". Embed fails with ErrNoEmbedder when it's empty.
+	Embedder string
+	// PingGenerates makes Ping generate a single token rather than look the model up, for backends whose
+	// model lookup doesn't tell whether the model can be called.
+	PingGenerates bool
+	// generate is replaced in tests to stub the model's responses.
+	generate func(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error)
+	// countTokens counts tokens with the provider; the tokens are estimated when it's nil.
+	countTokens tokenCounter
+	// lookupModel looks a model up with the provider for Ping, which generates a token when it's nil.
+	lookupModel func(ctx context.Context, model string) error
 }
 
-// Generate generates a response from the AI model using the provided options.
+// modelMetadataKey is the key of the message metadata holding the name of the model that generated a
+// response.
+const modelMetadataKey = "model"
+
+// Generate generates a response from the AI model using the provided options. The response records
+// the model when Model is set.
 func (g *GenkitGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
-	return genkit.Generate(ctx, g.AIClient, opts...)
+	if g.Model != "" {
+		opts = append([]ai.GenerateOption{ai.WithModelName(g.Model)}, opts...)
+	}
+	// the deterministic and safety settings go first, so that they're part of the fingerprint of a
+	// cached request, as is the model the context carries to the cache
+	var middleware []ai.ModelMiddleware
+	if deterministicSampling(ctx) {
+		middleware = append(middleware, deterministicMiddleware(g.Model))
+	}
+	if len(g.SafetySettings) > 0 {
+		middleware = append(middleware, safetyMiddleware(g.SafetySettings))
+	}
+	middleware = append(middleware, modelMiddleware(ctx)...)
+	ctx = withCalledModel(ctx, g.Model)
+	if len(middleware) > 0 {
+		opts = append(slices.Clone(opts), ai.WithMiddleware(middleware...))
+	}
+	generate := g.generate
+	if generate == nil {
+		generate = func(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+			return genkit.Generate(ctx, g.AIClient, opts...)
+		}
+	}
+	resp, err := generate(ctx, opts...)
+	if err == nil && g.Model != "" && resp != nil && resp.Message != nil {
+		if resp.Message.Metadata == nil {
+			resp.Message.Metadata = map[string]any{}
+		}
+		resp.Message.Metadata[modelMetadataKey] = g.Model
+	}
+	return resp, err
+}
+
+// withModel returns a copy of the generator calling the model, with the same safety settings, embedder
+// and provider clients.
+func (g *GenkitGenerator) withModel(model string) *GenkitGenerator {
+	copied := *g
+	copied.Model = model
+	return &copied
+}
+
+// responseModel returns the name of the model that generated the response, which GenkitGenerator
+// records when its Model is set, and is empty otherwise.
+func responseModel(resp *ai.ModelResponse) string {
+	if resp == nil || resp.Message == nil {
+		return ""
+	}
+	model, _ := resp.Message.Metadata[modelMetadataKey].(string)
+	return model
+}
+
+// GenerateStream generates a response like Generate, passing its chunks to cb as they arrive.
+func (g *GenkitGenerator) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	stream := func(_ context.Context, chunk *ai.ModelResponseChunk) error {
+		return cb(chunk)
+	}
+	return g.Generate(ctx, append(slices.Clone(opts), ai.WithStreaming(stream))...)
 }
 
 // LookupTool looks up a tool by name in the Genkit instance.
@@ -22,16 +171,147 @@ func (g *GenkitGenerator) LookupTool(name string) ai.Tool {
 	return genkit.LookupTool(g.AIClient, name)
 }
 
-// GenerateBool generates a boolean response from the AI model based on the prompt and history.
+// CountTokens counts the tokens of the messages for Model with the provider, or estimates them when the
+// provider can't count tokens, such as the OpenAI-compatible ones, or Model is empty.
+func (g *GenkitGenerator) CountTokens(ctx context.Context, messages []*ai.Message) (TokenCount, error) {
+	_, model, ok := strings.Cut(g.Model, "/")
+	if g.countTokens == nil || !ok {
+		return estimateTokens(messages), nil
+	}
+	tokens, err := g.countTokens(ctx, model, messages)
+	if err != nil {
+		return TokenCount{}, err
+	}
+	return TokenCount{Tokens: tokens}, nil
+}
+
+// GenerateBool generates a boolean response from the AI model based on the prompt and history, like
+// GenerateBoolScored without the confidence and rationale.
 func (g *GenkitGenerator) GenerateBool(ctx context.Context, prompt string, history []*ai.Message) (bool, error) {
-	result, _, err := genkit.GenerateData[bool](ctx, g.AIClient,
-		ai.WithMessages(history...),
-		ai.WithSystem(prompt),
-	)
+	value, _, _, err := g.GenerateBoolScored(ctx, prompt, history)
+	return value, err
+}
+
+// GenerateBoolScored generates a boolean response from the AI model based on the prompt and history,
+// with how sure the model is of it from 0 to 1 and why. The prompt is sent after the history as a user
+// message. An answer that isn't a boolean is asked for once more, and a null answer is an error. A
+// confidence out of range is clamped to it with a warning.
+func (g *GenkitGenerator) GenerateBoolScored(ctx context.Context, prompt string, history []*ai.Message) (value bool, confidence float64, rationale string, err error) {
+	messages := append(slices.Clone(history), ai.NewUserTextMessage(prompt))
+	for range boolAttempts {
+		var result scoredBool
+		if err = g.GenerateJSON(ctx, boolInstruction, messages, &result); err == nil {
+			if result.Value == nil {
+				return false, 0, "", errors.New("the model answered neither true nor false")
+			}
+			return *result.Value, clampConfidence(result.Confidence), result.Rationale, nil
+		}
+		var mismatch *ErrOutputMismatch
+		if !errors.As(err, &mismatch) {
+			return false, 0, "", err
+		}
+	}
+	return false, 0, "", err
+}
+
+// clampConfidence returns the confidence limited to between 0 and 1, warning when it isn't.
+func clampConfidence(confidence float64) float64 {
+	clamped := min(max(confidence, 0), 1)
+	if clamped != confidence {
+		log.Printf("the model answered with a confidence of %v, clamped to %v", confidence, clamped)
+	}
+	return clamped
+}
 
+// GenerateJSON generates a response from the AI model constrained to the JSON schema of out, which must
+// be a non-nil pointer, and decodes it into out. The system instruction is left out when it's empty.
+// Output that doesn't hold the type of out is reported with ErrOutputMismatch, and a response the
+// provider blocked with ErrContentBlocked. The call uses the
+// generation config of the run, if any, and its usage is reported to a UsageTrackingGenerator calling
+// it.
+func (g *GenkitGenerator) GenerateJSON(ctx context.Context, system string, history []*ai.Message, out any) error {
+	target := reflect.ValueOf(out)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("GenerateJSON needs a non-nil pointer, got %T", out)
+	}
+	// the schema is of the type pointed to, so that a *T target gets the schema of T
+	outputType := target.Type().Elem()
+	for outputType.Kind() == reflect.Pointer {
+		outputType = outputType.Elem()
+	}
+
+	// the raw output is kept for the usage of a failed call; a blocked response, and output not
+	// matching the schema, are reported before Genkit parses the output
+	output := reflect.Zero(outputType).Interface()
+	schema := core.InferSchemaMap(output)
+	var rawResp *ai.ModelResponse
+	keepRaw := func(next ai.ModelFunc) ai.ModelFunc {
+		return func(ctx context.Context, req *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+			resp, err := next(ctx, req, cb)
+			if resp != nil {
+				rawResp = &ai.ModelResponse{Message: resp.Message, Usage: resp.Usage}
+			}
+			if err == nil {
+				err = blockedError(resp)
+			}
+			if err == nil {
+				err = checkOutputSchema(schema, resp)
+			}
+			return resp, err
+		}
+	}
+	ctx = withModelMiddleware(ctx, keepRaw)
+	opts := []ai.GenerateOption{
+		ai.WithMessages(history...),
+		ai.WithOutputType(output),
+	}
+	if system != "" {
+		opts = append(opts, ai.WithSystem(system))
+	}
+	opts = append(opts, generationConfigOptions(ctx)...)
+	resp, err := g.Generate(ctx, opts...)
 	if err != nil {
-		return false, err
+		if rawResp != nil {
+			recordUsage(ctx, g.Model, rawResp.Usage)
+		}
+		return err
+	}
+	recordUsage(ctx, g.Model, resp.Usage)
+	if err := resp.Output(out); err != nil {
+		return &ErrOutputMismatch{Text: resp.Text(), Err: err}
 	}
+	return nil
+}
+
+// jsonBlock matches a fenced JSON block, which Genkit takes as the output when the model wraps it in
+// markdown.
+var jsonBlock = regexp.MustCompile("(?s)```json(.*?)```")
 
-	return *result, nil
+// checkOutputSchema returns an ErrOutputMismatch when the JSON output of resp doesn't match schema, the
+// way Genkit would reject it but without depending on the wording of its error. An empty output is left
+// to the decoding of the output.
+func checkOutputSchema(schema map[string]any, resp *ai.ModelResponse) error {
+	if resp == nil || resp.Message == nil {
+		return nil
+	}
+	text := resp.Text()
+	output := strings.TrimSpace(text)
+	if match := jsonBlock.FindStringSubmatch(text); match != nil {
+		output = strings.TrimSpace(match[1])
+	}
+	if output == "" {
+		return nil
+	}
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(schema), gojsonschema.NewStringLoader(output))
+	if err != nil {
+		return &ErrOutputMismatch{Text: text, Err: err}
+	}
+	if !result.Valid() {
+		problems := make([]string, 0, len(result.Errors()))
+		for _, problem := range result.Errors() {
+			problems = append(problems, problem.String())
+		}
+		return &ErrOutputMismatch{Text: text, Err: errors.New(strings.Join(problems, "; "))}
+	}
+	return nil
 }
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// trueAnswer and falseAnswer are answers of the model to the questions of GenerateBool.
+const (
+	trueAnswer  = `{"value": true, "confidence": 0.9, "rationale": "The model gave its final answer."}`
+	falseAnswer = `{"value": false, "confidence": 0.6, "rationale": "The budget is still unknown."}`
+)
+
+// scriptedGenkitGenerator returns a GenkitGenerator answering with the responses in order and recording
+// the options of each call.
+func scriptedGenkitGenerator(responses ...*ai.ModelResponse) (*GenkitGenerator, *[][]ai.GenerateOption) {
+	var calls [][]ai.GenerateOption
+	generator := &GenkitGenerator{generate: func(_ context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+		calls = append(calls, opts)
+		if len(calls) > len(responses) {
+			return nil, errors.New("unexpected call")
+		}
+		return responses[len(calls)-1], nil
+	}}
+	return generator, &calls
+}
+
+// capturedOptionPrompts returns the system prompt and the messages set by the generate options.
+func capturedOptionPrompts(t *testing.T, opts []ai.GenerateOption) (string, []*ai.Message) {
+	t.Helper()
+	var system string
+	var messages []*ai.Message
+	for _, opt := range opts {
+		v := reflect.ValueOf(opt)
+		if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		if field := v.Elem().FieldByName("SystemFn"); field.IsValid() {
+			if fn, ok := field.Interface().(ai.PromptFn); ok && fn != nil {
+				var err error
+				system, err = fn(context.Background(), nil)
+				require.NoError(t, err)
+			}
+		}
+		if field := v.Elem().FieldByName("MessagesFn"); field.IsValid() {
+			if fn, ok := field.Interface().(ai.MessagesFn); ok && fn != nil {
+				var err error
+				messages, err = fn(context.Background(), nil)
+				require.NoError(t, err)
+			}
+		}
+	}
+	return system, messages
+}
+
+func TestGenkitGenerator_GenerateBool(t *testing.T) {
+	history := []*ai.Message{
+		ai.NewUserTextMessage("Suggest a gift."),
+		ai.NewModelTextMessage("Is the gift for a child?"),
+	}
+
+	tests := []struct {
+		name      string
+		responses []*ai.ModelResponse
+		expected  bool
+		calls     int
+		err       string
+	}{
+		{name: "true", responses: []*ai.ModelResponse{createTextResponse(trueAnswer, "stop")}, expected: true, calls: 1},
+		{name: "false in markdown", responses: []*ai.ModelResponse{createTextResponse("```json\n"+falseAnswer+"\n```", "stop")}, calls: 1},
+		{
+			name:      "retried after an unparsable answer",
+			responses: []*ai.ModelResponse{createTextResponse("probably", "stop"), createTextResponse(trueAnswer, "stop")},
+			expected:  true,
+			calls:     2,
+		},
+		{
+			name:      "unparsable twice",
+			responses: []*ai.ModelResponse{createTextResponse("probably", "stop"), createTextResponse(`{"value": "yes"}`, "stop")},
+			calls:     2,
+			err:       `the model output doesn't match the expected type: json: cannot unmarshal string into Go struct field scoredBool.value of type bool (the model answered "{\"value\": \"yes\"}")`,
+		},
+		{name: "null", responses: []*ai.ModelResponse{createTextResponse(`{"value": null}`, "stop")}, calls: 1, err: "the model answered neither true nor false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, calls := scriptedGenkitGenerator(tt.responses...)
+
+			finished, err := generator.GenerateBool(context.Background(), "Has the model given its final answer?", history)
+
+			assert.Len(t, *calls, tt.calls)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, finished)
+		})
+	}
+}
+
+func TestGenkitGenerator_GenerateBoolScored(t *testing.T) {
+	tests := []struct {
+		name       string
+		answer     string
+		confidence float64
+		warning    string
+	}{
+		{name: "in range", answer: trueAnswer, confidence: 0.9},
+		{name: "above one", answer: `{"value": true, "confidence": 1.7, "rationale": "The model gave its final answer."}`, confidence: 1, warning: "confidence of 1.7, clamped to 1"},
+		{name: "below zero", answer: `{"value": true, "confidence": -0.2, "rationale": "The model gave its final answer."}`, confidence: 0, warning: "confidence of -0.2, clamped to 0"},
+		{name: "left out", answer: `{"value": true, "rationale": "The model gave its final answer."}`, confidence: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureLog(t)
+			generator, _ := scriptedGenkitGenerator(createTextResponse(tt.answer, "stop"))
+
+			value, confidence, rationale, err := generator.GenerateBoolScored(context.Background(), "Has the model given its final answer?", nil)
+
+			require.NoError(t, err)
+			assert.True(t, value)
+			assert.Equal(t, tt.confidence, confidence)
+			assert.Equal(t, "The model gave its final answer.", rationale)
+			if tt.warning == "" {
+				assert.Empty(t, out.String())
+				return
+			}
+			assert.Contains(t, out.String(), tt.warning)
+		})
+	}
+}
+
+func TestGenkitGenerator_GenerateBoolScored_RetriesSchemaMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers []string
+		calls   int64
+		err     bool
+	}{
+		{name: "retried", answers: []string{`{"value": "yes"}`, trueAnswer}, calls: 2},
+		{name: "mismatch twice", answers: []string{`{"value": "yes"}`, "probably"}, calls: 2, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var answered atomic.Int64
+			g, calls := countingModel(func(*ai.ModelRequest) *ai.ModelResponse {
+				answer := tt.answers[answered.Add(1)-1]
+				return &ai.ModelResponse{Message: ai.NewModelTextMessage(answer), FinishReason: ai.FinishReasonStop}
+			})
+			generator := &GenkitGenerator{AIClient: g}
+
+			value, _, _, err := generator.GenerateBoolScored(context.Background(), "Has the model given its final answer?", nil)
+
+			assert.Equal(t, tt.calls, calls.Load(), "an answer rejected by the schema is asked for again")
+			if tt.err {
+				var mismatch *ErrOutputMismatch
+				require.ErrorAs(t, err, &mismatch)
+				assert.Equal(t, "probably", mismatch.Text)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, value)
+		})
+	}
+}
+
+func TestGenkitGenerator_GenerateBool_DropsScore(t *testing.T) {
+	responses := func() []*ai.ModelResponse {
+		return []*ai.ModelResponse{createTextResponse("probably", "stop"), createTextResponse(falseAnswer, "stop")}
+	}
+	scored, scoredCalls := scriptedGenkitGenerator(responses()...)
+	plain, plainCalls := scriptedGenkitGenerator(responses()...)
+
+	value, confidence, rationale, scoredErr := scored.GenerateBoolScored(context.Background(), "Is the conversation finished?", nil)
+	finished, err := plain.GenerateBool(context.Background(), "Is the conversation finished?", nil)
+
+	require.NoError(t, scoredErr)
+	require.NoError(t, err)
+	assert.Equal(t, value, finished)
+	require.Len(t, *plainCalls, len(*scoredCalls), "GenerateBool makes the same calls")
+	for i := range *plainCalls {
+		scoredSystem, scoredMessages := capturedOptionPrompts(t, (*scoredCalls)[i])
+		system, messages := capturedOptionPrompts(t, (*plainCalls)[i])
+		assert.Equal(t, scoredSystem, system)
+		assert.Equal(t, scoredMessages, messages)
+	}
+	assert.Equal(t, 0.6, confidence)
+	assert.Equal(t, "The budget is still unknown.", rationale)
+}
+
+func TestGenkitGenerator_GenerateBool_Messages(t *testing.T) {
+	history := []*ai.Message{
+		ai.NewUserTextMessage("Suggest a gift."),
+		ai.NewModelTextMessage("Is the gift for a child?"),
+	}
+	generator, calls := scriptedGenkitGenerator(createTextResponse(falseAnswer, "stop"))
+
+	_, err := generator.GenerateBool(context.Background(), "Has the model given its final answer?", history)
+
+	require.NoError(t, err)
+	require.Len(t, *calls, 1)
+	system, messages := capturedOptionPrompts(t, (*calls)[0])
+	assert.Equal(t, boolInstruction, system)
+	require.Len(t, messages, 3)
+	assert.Equal(t, history, messages[:2])
+	assert.Equal(t, ai.RoleUser, messages[2].Role)
+	assert.Equal(t, "Has the model given its final answer?", messages[2].Text())
+	assert.Len(t, history, 2, "the history isn't changed")
+}
+
+func TestGenkitGenerator_GenerateBool_GenerationError(t *testing.T) {
+	calls := 0
+	generator := &GenkitGenerator{generate: func(context.Context, ...ai.GenerateOption) (*ai.ModelResponse, error) {
+		calls++
+		return nil, errors.New("quota exceeded")
+	}}
+
+	_, err := generator.GenerateBool(context.Background(), "Is it finished?", nil)
+
+	assert.EqualError(t, err, "quota exceeded")
+	assert.Equal(t, 1, calls, "only output that doesn't parse is retried")
+}
+
+func TestGenkitGenerator_GenerateJSON(t *testing.T) {
+	type gift struct {
+		Name  string  `json:"name"`
+		Price float64 `json:"price"`
+	}
+	history := []*ai.Message{ai.NewUserTextMessage("Suggest gifts for an 8 year old.")}
+
+	t.Run("struct", func(t *testing.T) {
+		generator, calls := scriptedGenkitGenerator(createTextResponse(`{"name": "LEGO set", "price": 45}`, "stop"))
+		var out gift
+
+		err := generator.GenerateJSON(context.Background(), "Suggest one gift.", history, &out)
+
+		require.NoError(t, err)
+		assert.Equal(t, gift{Name: "LEGO set", Price: 45}, out)
+		system, messages := capturedOptionPrompts(t, (*calls)[0])
+		assert.Equal(t, "Suggest one gift.", system)
+		assert.Equal(t, history, messages)
+	})
+
+	t.Run("slice", func(t *testing.T) {
+		generator, calls := scriptedGenkitGenerator(createTextResponse("```json\n[\"Kite\", \"Puzzle\"]\n```", "stop"))
+		var out []string
+
+		err := generator.GenerateJSON(context.Background(), "", history, &out)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Kite", "Puzzle"}, out)
+		system, _ := capturedOptionPrompts(t, (*calls)[0])
+		assert.Empty(t, system, "an empty system instruction is left out")
+	})
+
+	t.Run("primitive", func(t *testing.T) {
+		generator, _ := scriptedGenkitGenerator(createTextResponse("3", "stop"))
+		var out int
+
+		err := generator.GenerateJSON(context.Background(), "How many gifts?", history, &out)
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, out)
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		generator, _ := scriptedGenkitGenerator(createTextResponse(`{"name": "Kite", "price": "cheap"}`, "stop"))
+		var out gift
+
+		err := generator.GenerateJSON(context.Background(), "Suggest one gift.", history, &out)
+
+		var mismatch *ErrOutputMismatch
+		require.ErrorAs(t, err, &mismatch)
+		assert.Equal(t, `{"name": "Kite", "price": "cheap"}`, mismatch.Text)
+	})
+
+	t.Run("not a pointer", func(t *testing.T) {
+		generator, calls := scriptedGenkitGenerator()
+
+		err := generator.GenerateJSON(context.Background(), "", history, gift{})
+
+		assert.EqualError(t, err, "GenerateJSON needs a non-nil pointer, got main.gift")
+		assert.Empty(t, *calls)
+	})
+}
+
+func TestGenkitGenerator_GenerateJSON_SchemaMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  string
+		problem string
+	}{
+		{name: "wrong type", answer: `{"count": "three"}`, problem: "count: Invalid type. Expected: integer, given: string"},
+		{name: "fenced", answer: "```json\n{\"count\": 2.5}\n```", problem: "count: Invalid type. Expected: integer, given: number"},
+		{name: "not JSON", answer: "three gifts", problem: "invalid character"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := genkit.Init(context.Background(), genkit.WithDefaultModel("test/fixed"))
+			genkit.DefineModel(g, "test/fixed", &ai.ModelOptions{Supports: &ai.ModelSupports{Multiturn: true, SystemRole: true}},
+				func(context.Context, *ai.ModelRequest, ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+					return &ai.ModelResponse{Message: ai.NewModelTextMessage(tt.answer), FinishReason: ai.FinishReasonStop}, nil
+				})
+			generator := &GenkitGenerator{AIClient: g}
+			var out struct {
+				Count int `json:"count"`
+			}
+
+			err := generator.GenerateJSON(context.Background(), "How many gifts?", []*ai.Message{ai.NewUserTextMessage("Suggest gifts.")}, &out)
+
+			var mismatch *ErrOutputMismatch
+			require.ErrorAs(t, err, &mismatch, "unexpected error: %v", err)
+			assert.Equal(t, tt.answer, mismatch.Text, "the raw output is kept")
+			assert.ErrorContains(t, mismatch.Err, tt.problem, "the output is checked against the schema before Genkit parses it")
+		})
+	}
+}
+
+func TestGenkitGenerator_GenerateStream(t *testing.T) {
+	g := genkit.Init(context.Background(), genkit.WithDefaultModel("test/streaming"))
+	genkit.DefineModel(g, "test/streaming", &ai.ModelOptions{Supports: &ai.ModelSupports{Multiturn: true}},
+		func(ctx context.Context, _ *ai.ModelRequest, cb ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+			if cb != nil {
+				for _, text := range []string{"A LEGO", " set"} {
+					if err := cb(ctx, &ai.ModelResponseChunk{Content: []*ai.Part{ai.NewTextPart(text)}}); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return &ai.ModelResponse{Message: ai.NewModelTextMessage("A LEGO set"), FinishReason: ai.FinishReasonStop}, nil
+		})
+	generator := &GenkitGenerator{AIClient: g}
+	var texts []string
+
+	resp, err := generator.GenerateStream(context.Background(), chunkTexts(&texts), ai.WithPrompt("Suggest a gift."))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A LEGO", " set"}, texts)
+	assert.Equal(t, "A LEGO set", resp.Text())
+}
+
+func TestGenkitGenerator_Model(t *testing.T) {
+	g := genkit.Init(context.Background(), genkit.WithDefaultModel("test/default"))
+	for _, name := range []string{"test/default", "test/lite"} {
+		genkit.DefineModel(g, name, &ai.ModelOptions{Supports: &ai.ModelSupports{Multiturn: true}},
+			func(context.Context, *ai.ModelRequest, ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+				return &ai.ModelResponse{Message: ai.NewModelTextMessage(name), FinishReason: ai.FinishReasonStop}, nil
+			})
+	}
+
+	for model, expected := range map[string]string{"": "test/default", "test/lite": "test/lite"} {
+		generator := &GenkitGenerator{AIClient: g, Model: model}
+
+		resp, err := generator.Generate(context.Background(), ai.WithPrompt("Suggest a gift."))
+
+		require.NoError(t, err)
+		assert.Equal(t, expected, resp.Text())
+		assert.Equal(t, model, responseModel(resp), "the response records the model only when it's set")
+	}
+}
+
+func TestGenkitGenerator_WithModel(t *testing.T) {
+	settings := []*genai.SafetySetting{{Category: genai.HarmCategoryHarassment, Threshold: genai.HarmBlockThresholdBlockOnlyHigh}}
+	base := &GenkitGenerator{AIClient: genkit.Init(context.Background()), Model: "googleai/gemini-2.5-flash", SafetySettings: settings, Embedder: "googleai/gemini-embedding-001"}
+
+	fallback := base.withModel("googleai/gemini-2.5-flash-lite")
+
+	assert.Equal(t, "googleai/gemini-2.5-flash-lite", fallback.Model)
+	assert.Equal(t, "googleai/gemini-2.5-flash", base.Model, "the base generator keeps its model")
+	assert.Same(t, base.AIClient, fallback.AIClient)
+	assert.Equal(t, settings, fallback.SafetySettings)
+	assert.Equal(t, "googleai/gemini-embedding-001", fallback.Embedder)
+}
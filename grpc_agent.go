@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"interrupts/agentpb"
+)
+
+// AgentServer serves the agent over gRPC. Every Run stream is a separate run whose questions
+// are streamed to the client and answered by it.
+type AgentServer struct {
+	agentpb.UnimplementedAgentServiceServer
+	generator Generator
+	toolNames []string
+}
+
+// NewAgentServer creates an AgentServer that runs the agent with the generator and offers it the given tools.
+func NewAgentServer(generator Generator, toolNames ...string) *AgentServer {
+	return &AgentServer{generator: generator, toolNames: toolNames}
+}
+
+// Run starts a run with the client's StartRun message and finishes the stream with the final response.
+// A client that disconnects or stops sending while a question is pending aborts the run.
+func (s *AgentServer) Run(stream grpc.BidiStreamingServer[agentpb.ClientMessage, agentpb.ServerMessage]) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	start := first.GetStartRun()
+	if start == nil {
+		return status.Error(codes.InvalidArgument, "the first message must be StartRun")
+	}
+
+	interaction := newStreamInteraction(stream)
+	go interaction.receive()
+
+	finalResponse, err := RunAgent(stream.Context(), &Options{
+		generator:    s.generator,
+		systemPrompt: SystemPrompt(start.GetSystemPrompt()),
+		userPrompt:   UserPrompt(start.GetUserPrompt()),
+		toolNames:    s.toolNames,
+		responseHandler: &InterruptionHandler{
+			generator:       s.generator,
+			UserInteraction: interaction.Interact,
+			toolNames:       s.toolNames,
+		},
+	})
+	if errors.Is(err, ErrConversationAborted) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return stream.Send(&agentpb.ServerMessage{
+		Message: &agentpb.ServerMessage_FinalResponse{FinalResponse: &agentpb.FinalResponse{Text: finalResponse}},
+	})
+}
+
+// streamInteraction adapts a Run stream into a UserInteractionFunc.
+type streamInteraction struct {
+	stream grpc.BidiStreamingServer[agentpb.ClientMessage, agentpb.ServerMessage]
+	// closed is closed once the client stops sending, after which no answer can arrive.
+	closed chan struct{}
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[string]chan string
+}
+
+func newStreamInteraction(stream grpc.BidiStreamingServer[agentpb.ClientMessage, agentpb.ServerMessage]) *streamInteraction {
+	return &streamInteraction{
+		stream:  stream,
+		closed:  make(chan struct{}),
+		pending: make(map[string]chan string),
+	}
+}
+
+// receive delivers the client's answers to the pending questions until the client stops sending.
+// Answers to unknown questions are ignored.
+func (si *streamInteraction) receive() {
+	defer close(si.closed)
+	for {
+		msg, err := si.stream.Recv()
+		if err != nil {
+			return
+		}
+		answer := msg.GetAnswer()
+		if answer == nil {
+			continue
+		}
+
+		si.mu.Lock()
+		answerCh, ok := si.pending[answer.GetId()]
+		delete(si.pending, answer.GetId())
+		si.mu.Unlock()
+		if ok {
+			answerCh <- answer.GetAnswer()
+		}
+	}
+}
+
+// Interact streams the question to the client and waits for its answer.
+// It fails with ErrConversationAborted when the client goes away first.
+func (si *streamInteraction) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	si.mu.Lock()
+	si.nextID++
+	id := strconv.FormatUint(si.nextID, 10)
+	// buffered so that receive never waits for Interact
+	answerCh := make(chan string, 1)
+	si.pending[id] = answerCh
+	si.mu.Unlock()
+	defer func() {
+		si.mu.Lock()
+		delete(si.pending, id)
+		si.mu.Unlock()
+	}()
+
+	err := si.stream.Send(&agentpb.ServerMessage{
+		Message: &agentpb.ServerMessage_Question{Question: &agentpb.Question{
+			Id:       id,
+			Question: input.Question,
+			Choices:  input.Choices,
+			Reason:   input.Reason,
+			Default:  input.Default,
+		}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrConversationAborted, err)
+	}
+
+	select {
+	case answer := <-answerCh:
+		return answer, nil
+	case <-si.closed:
+		return "", fmt.Errorf("%w: the client stopped sending answers", ErrConversationAborted)
+	case <-ctx.Done():
+		return "", fmt.Errorf("%w: %w", ErrConversationAborted, ctx.Err())
+	}
+}
+
+// RunRemoteAgent runs the agent served by an AgentServer and answers its questions with interaction.
+// Steering errors such as ErrQuestionSkipped are sent to the agent as answers; other errors end the run.
+func RunRemoteAgent(ctx context.Context, client agentpb.AgentServiceClient, systemPrompt SystemPrompt, userPrompt UserPrompt, interaction UserInteractionFunc) (string, error) {
+	// cancelling the context tells the server that the client is gone
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.Run(ctx)
+	if err != nil {
+		return "", err
+	}
+	err = stream.Send(&agentpb.ClientMessage{
+		Message: &agentpb.ClientMessage_StartRun{StartRun: &agentpb.StartRun{
+			SystemPrompt: string(systemPrompt),
+			UserPrompt:   string(userPrompt),
+		}},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return "", err
+		}
+		if final := msg.GetFinalResponse(); final != nil {
+			return final.GetText(), stream.CloseSend()
+		}
+		question := msg.GetQuestion()
+		if question == nil {
+			continue
+		}
+
+		answer, err := interaction(ctx, QuestionInput{
+			Question: question.GetQuestion(),
+			Choices:  question.GetChoices(),
+			Reason:   question.GetReason(),
+			Default:  question.GetDefault(),
+		})
+		if err != nil {
+			steering, ok := steeringAnswer(err)
+			if !ok {
+				return "", err
+			}
+			answer = steering
+		}
+		err = stream.Send(&agentpb.ClientMessage{
+			Message: &agentpb.ClientMessage_Answer{Answer: &agentpb.Answer{Id: question.GetId(), Answer: answer}},
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+}
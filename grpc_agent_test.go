@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"interrupts/agentpb"
+)
+
+// startAgentServer serves an AgentServer over an in-memory listener and returns a client for it.
+func startAgentServer(t *testing.T, server *AgentServer) agentpb.AgentServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	agentpb.RegisterAgentServiceServer(grpcServer, server)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return agentpb.NewAgentServiceClient(conn)
+}
+
+func TestAgentServer_RunRemoteAgent(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createToolRequestPart("askQuestion", "What are their ages?", nil),
+			),
+			createTextResponse("Based on both genders and ages...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	client := startAgentServer(t, NewAgentServer(mockGen))
+
+	var asked []QuestionInput
+	answers := map[string]string{"What gender are the children?": "Both"}
+	interaction := func(_ context.Context, input QuestionInput) (string, error) {
+		asked = append(asked, input)
+		if answer, ok := answers[input.Question]; ok {
+			return answer, nil
+		}
+		return "", ErrQuestionSkipped
+	}
+
+	result, err := RunRemoteAgent(context.Background(), client, "You are a gift advisor.", "Suggest a gift.", interaction)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Based on both genders and ages...", result)
+	require.Len(t, asked, 2)
+	assert.Equal(t, []string{"Boy", "Girl", "Both"}, asked[0].Choices)
+	assert.Equal(t, 2, mockGen.callIndex)
+}
+
+func TestAgentServer_ClientDisconnect(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createToolRequestPart("askQuestion", "What is the budget?", nil)),
+			createTextResponse("unreachable", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	client := startAgentServer(t, NewAgentServer(mockGen))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Run(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&agentpb.ClientMessage{
+		Message: &agentpb.ClientMessage_StartRun{StartRun: &agentpb.StartRun{UserPrompt: "Suggest a gift."}},
+	}))
+
+	msg, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "What is the budget?", msg.GetQuestion().GetQuestion())
+
+	// stopping sending without an answer aborts the run on the server
+	require.NoError(t, stream.CloseSend())
+	_, err = stream.Recv()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ErrConversationAborted.Error())
+	assert.Equal(t, 1, mockGen.callIndex)
+}
+
+func TestAgentServer_RequiresStartRun(t *testing.T) {
+	client := startAgentServer(t, NewAgentServer(NewMockGenerator(nil, nil)))
+
+	stream, err := client.Run(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&agentpb.ClientMessage{
+		Message: &agentpb.ClientMessage_Answer{Answer: &agentpb.Answer{Id: "1", Answer: "yes"}},
+	}))
+
+	_, err = stream.Recv()
+	assert.ErrorContains(t, err, "the first message must be StartRun")
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPQuestion is a pending question as served to HTTP clients.
+type HTTPQuestion struct {
+	ID string `json:"id"`
+	QuestionInput
+	// Form is set for a form, whose fields are answered together; Question holds its title.
+	Form *FormInput `json:"form,omitempty"`
+	// File is set for a file request, which is answered by uploading the file; Question holds the request.
+	File *RequestFileInput `json:"file,omitempty"`
+}
+
+// UnmarshalJSON decodes the question and the fields of the form or file request.
+func (q *HTTPQuestion) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		ID   string            `json:"id"`
+		Form *FormInput        `json:"form"`
+		File *RequestFileInput `json:"file"`
+	}
+	if err := unmarshalEmbeddedQuestion(data, &q.QuestionInput, &fields); err != nil {
+		return err
+	}
+	q.ID, q.Form, q.File = fields.ID, fields.Form, fields.File
+	return nil
+}
+
+// HTTPAnswer is the body of an answer posted by an HTTP client.
+type HTTPAnswer struct {
+	Answer string `json:"answer"`
+	// Fields holds the values of a form by field name.
+	Fields map[string]string `json:"fields,omitempty"`
+	// upload is the file uploaded for a file request.
+	upload FileUpload
+	// skipped is set when an optional question is answered with an empty answer.
+	skipped bool
+}
+
+// httpPending is a question waiting for its answer to be posted.
+type httpPending struct {
+	question HTTPQuestion
+	answerCh chan HTTPAnswer
+}
+
+// HTTPInteractor asks questions over HTTP so that a web frontend can answer them.
+// Clients fetch the oldest pending question from GET /questions/current and answer it
+// with POST /questions/{id}/answer. Every question gets its own ID, so questions asked
+// concurrently are answered independently. Forms are served whole and answered with their fields,
+// and file requests are answered by posting the file as the "file" field of a multipart/form-data body.
+type HTTPInteractor struct {
+	mu     sync.Mutex
+	nextID uint64
+	// pending holds unanswered questions in the order they were asked.
+	pending []*httpPending
+	timeout time.Duration
+	// maxTimeout caps the timeouts questions request through QuestionInput.TimeoutSeconds.
+	maxTimeout time.Duration
+}
+
+// HTTPOption configures an HTTPInteractor.
+type HTTPOption func(*HTTPInteractor)
+
+// WithHTTPAnswerTimeout sets how long Interact waits for an answer to be posted.
+// Zero, the default, waits until the context is done.
+func WithHTTPAnswerTimeout(timeout time.Duration) HTTPOption {
+	return func(h *HTTPInteractor) {
+		h.timeout = timeout
+	}
+}
+
+// WithHTTPMaxAnswerTimeout caps the timeout a question can request through QuestionInput.TimeoutSeconds,
+// 10 minutes by default. Zero lifts the cap.
+func WithHTTPMaxAnswerTimeout(limit time.Duration) HTTPOption {
+	return func(h *HTTPInteractor) {
+		h.maxTimeout = limit
+	}
+}
+
+// NewHTTPInteractor creates an HTTPInteractor. Serve its Handler to let clients answer questions.
+func NewHTTPInteractor(opts ...HTTPOption) *HTTPInteractor {
+	h := &HTTPInteractor{maxTimeout: defaultMaxAnswerTimeout}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Handler returns the HTTP handler serving the question endpoints.
+func (h *HTTPInteractor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /questions/current", h.serveCurrent)
+	mux.HandleFunc("POST /questions/{id}/answer", h.serveAnswer)
+	return mux
+}
+
+// Interact publishes the question and blocks until a client posts the answer,
+// the timeout expires or the context is done. It implements UserInteractionFunc.
+func (h *HTTPInteractor) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	pending := h.add(HTTPQuestion{QuestionInput: input})
+	defer h.remove(pending)
+
+	answer, err := h.wait(ctx, pending)
+	if err == nil && answer.skipped {
+		return "", ErrQuestionSkipped
+	}
+	return answer.Answer, err
+}
+
+// AskForm publishes the form and blocks until a client posts valid values for its fields,
+// the timeout expires or the context is done. It implements FormAsker.
+func (h *HTTPInteractor) AskForm(ctx context.Context, form FormInput) (map[string]string, error) {
+	pending := h.add(HTTPQuestion{QuestionInput: QuestionInput{Question: form.Title}, Form: &form})
+	defer h.remove(pending)
+
+	answer, err := h.wait(ctx, pending)
+	return answer.Fields, err
+}
+
+// AskFile publishes the file request and blocks until a client uploads a file that fits it,
+// the timeout expires or the context is done. It implements FileAsker.
+func (h *HTTPInteractor) AskFile(ctx context.Context, input RequestFileInput) (FileUpload, error) {
+	pending := h.add(HTTPQuestion{QuestionInput: QuestionInput{Question: input.Question}, File: &input})
+	defer h.remove(pending)
+
+	answer, err := h.wait(ctx, pending)
+	return answer.upload, err
+}
+
+// Ask is Interact reporting a skipped question as a skipped Answer.
+// Together with Notify and Close it makes HTTPInteractor an Interactor.
+func (h *HTTPInteractor) Ask(ctx context.Context, input QuestionInput) (Answer, error) {
+	return answerOf(h.Interact(ctx, input))
+}
+
+// Notify does nothing; clients are only served questions.
+func (h *HTTPInteractor) Notify(context.Context, string) error {
+	return nil
+}
+
+// Close does nothing; pending questions end with their contexts.
+func (h *HTTPInteractor) Close() error {
+	return nil
+}
+
+// wait blocks until the answer to the pending question is posted, the timeout expires or the context is done.
+func (h *HTTPInteractor) wait(ctx context.Context, pending *httpPending) (HTTPAnswer, error) {
+	return waitForAnswer(ctx, pending.answerCh, pending.question.QuestionInput, h.timeout, h.maxTimeout)
+}
+
+// add registers a new pending question under a fresh ID.
+func (h *HTTPInteractor) add(question HTTPQuestion) *httpPending {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	question.ID = strconv.FormatUint(h.nextID, 10)
+	pending := &httpPending{
+		question: question,
+		// buffered so that posting an answer never waits for Interact
+		answerCh: make(chan HTTPAnswer, 1),
+	}
+	h.pending = append(h.pending, pending)
+	return pending
+}
+
+// remove forgets the question so that it is no longer served or answerable.
+func (h *HTTPInteractor) remove(pending *httpPending) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.pending = slices.DeleteFunc(h.pending, func(p *httpPending) bool {
+		return p == pending
+	})
+}
+
+// serveCurrent responds with the oldest pending question, or 204 No Content when there is none.
+func (h *HTTPInteractor) serveCurrent(w http.ResponseWriter, _ *http.Request) {
+	h.mu.Lock()
+	var question *HTTPQuestion
+	if len(h.pending) > 0 {
+		question = &h.pending[0].question
+	}
+	h.mu.Unlock()
+
+	if question == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(question)
+}
+
+// serveAnswer delivers the posted answer to the question with the ID from the path.
+// An empty answer selects the question's default, or skips an optional question without one, and
+// strict choices only accept an answer selecting one of them. A form is only answered by fields
+// that are all valid, and a file request only by an upload. A JSON body over maxRequestBodySize is rejected.
+func (h *HTTPInteractor) serveAnswer(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		h.serveUpload(w, r)
+		return
+	}
+
+	var body HTTPAnswer
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid answer: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	id := r.PathValue("id")
+	index := slices.IndexFunc(h.pending, func(p *httpPending) bool {
+		return p.question.ID == id
+	})
+	if index < 0 {
+		http.Error(w, "no pending question with id "+id, http.StatusNotFound)
+		return
+	}
+	pending := h.pending[index]
+
+	if pending.question.File != nil {
+		http.Error(w, "the question expects a file uploaded as multipart/form-data", http.StatusBadRequest)
+		return
+	}
+	if form := pending.question.Form; form != nil {
+		if _, err := form.parse(body.Fields); err != nil {
+			http.Error(w, "invalid form: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.pending = slices.Delete(h.pending, index, index+1)
+		pending.answerCh <- HTTPAnswer{Fields: body.Fields}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	input := pending.question.QuestionInput
+	answer := strings.TrimSpace(body.Answer)
+	if answer == "" {
+		answer = input.Default
+	}
+	if answer == "" && !input.IsRequired() {
+		h.pending = slices.Delete(h.pending, index, index+1)
+		pending.answerCh <- HTTPAnswer{skipped: true}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if answer == "" {
+		http.Error(w, "answer is empty", http.StatusBadRequest)
+		return
+	}
+	answer, err := input.matchAnswer(answer)
+	if err != nil {
+		http.Error(w, "invalid answer: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the question is removed right away so that a second answer to it is rejected
+	h.pending = slices.Delete(h.pending, index, index+1)
+	pending.answerCh <- HTTPAnswer{Answer: answer}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveUpload delivers the file uploaded as the "file" field of a multipart/form-data body to the file
+// request with the ID from the path. Files that are too large or not of an accepted type are rejected.
+func (h *HTTPInteractor) serveUpload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	pending := h.find(id)
+	if pending == nil {
+		http.Error(w, "no pending question with id "+id, http.StatusNotFound)
+		return
+	}
+	request := pending.question.File
+	if request == nil {
+		http.Error(w, "the question doesn't expect a file", http.StatusBadRequest)
+		return
+	}
+	maxBytes := request.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxFileBytes
+	}
+
+	// the lock isn't held while the upload is read so that other questions are answered meanwhile;
+	// the body may exceed the file by the size of the multipart headers
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+maxRequestBodySize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		http.Error(w, "invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	upload := FileUpload{Name: header.Filename, ContentType: header.Header.Get("Content-Type"), Data: data}
+	if upload.ContentType == "" || upload.ContentType == "application/octet-stream" {
+		upload.ContentType = detectContentType(upload.Name, data)
+	}
+	checked := *request
+	checked.MaxBytes = maxBytes
+	if err := checked.check(upload); err != nil {
+		http.Error(w, "invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	index := slices.Index(h.pending, pending)
+	if index < 0 {
+		http.Error(w, "no pending question with id "+id, http.StatusNotFound)
+		return
+	}
+	h.pending = slices.Delete(h.pending, index, index+1)
+	pending.answerCh <- HTTPAnswer{upload: upload}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// find returns the pending question with the ID, or nil when there is none.
+func (h *HTTPInteractor) find(id string) *httpPending {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	index := slices.IndexFunc(h.pending, func(p *httpPending) bool {
+		return p.question.ID == id
+	})
+	if index < 0 {
+		return nil
+	}
+	return h.pending[index]
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fetchQuestion polls the current question until one is pending.
+func fetchQuestion(t *testing.T, server *httptest.Server) HTTPQuestion {
+	t.Helper()
+	for {
+		resp, err := http.Get(server.URL + "/questions/current")
+		require.NoError(t, err)
+		if resp.StatusCode == http.StatusNoContent {
+			_ = resp.Body.Close()
+			time.Sleep(5 * time.Millisecond)
+			continue
+		}
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var question HTTPQuestion
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&question))
+		_ = resp.Body.Close()
+		return question
+	}
+}
+
+// postAnswer posts the answer to the question and returns the response status.
+func postAnswer(t *testing.T, server *httptest.Server, id, answer string) int {
+	t.Helper()
+	body, err := json.Marshal(HTTPAnswer{Answer: answer})
+	require.NoError(t, err)
+	resp, err := http.Post(server.URL+"/questions/"+id+"/answer", "application/json", strings.NewReader(string(body)))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestHTTPInteractor_RunAgent(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createToolRequestPart("askQuestion", "What are their ages?", nil),
+			),
+			createTextResponse("Based on both genders and ages...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	interactor := NewHTTPInteractor(WithHTTPAnswerTimeout(time.Second))
+	server := httptest.NewServer(interactor.Handler())
+	defer server.Close()
+
+	answers := map[string]string{
+		"What gender are the children?": "Both",
+		"What are their ages?":          "8 and 11",
+	}
+	ids := make(chan string, len(answers))
+	go func() {
+		for range answers {
+			question := fetchQuestion(t, server)
+			ids <- question.ID
+			assert.Equal(t, http.StatusNoContent, postAnswer(t, server, question.ID, answers[question.Question]))
+		}
+	}()
+
+	result, err := RunAgent(context.Background(), &Options{
+		generator: mockGen,
+		responseHandler: &InterruptionHandler{
+			generator:       mockGen,
+			UserInteraction: interactor.Interact,
+		},
+	})
+
+	require.NoError(t, err)
+	assert.Contains(t, result, "Based on")
+	assert.NotEqual(t, <-ids, <-ids)
+
+	resp, err := http.Get(server.URL + "/questions/current")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestHTTPInteractor_ConcurrentQuestions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interactor := NewHTTPInteractor()
+	server := httptest.NewServer(interactor.Handler())
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	results := make([]string, 2)
+	for i, question := range []string{"What gender?", "What age?"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			answer, err := interactor.Interact(ctx, QuestionInput{Question: question})
+			assert.NoError(t, err)
+			results[i] = answer
+		}()
+		// wait until the question is pending so that the order is known
+		require.Eventually(t, func() bool {
+			interactor.mu.Lock()
+			defer interactor.mu.Unlock()
+			return len(interactor.pending) == i+1
+		}, time.Second, time.Millisecond)
+	}
+
+	// the second question is answered first by its own ID
+	assert.Equal(t, http.StatusNoContent, postAnswer(t, server, "2", "8"))
+	assert.Equal(t, http.StatusNoContent, postAnswer(t, server, "1", "Boy"))
+	wg.Wait()
+
+	assert.Equal(t, []string{"Boy", "8"}, results)
+}
+
+func TestHTTPInteractor_ChoiceDescriptions(t *testing.T) {
+	interactor := NewHTTPInteractor(WithHTTPAnswerTimeout(time.Second))
+	server := httptest.NewServer(interactor.Handler())
+	defer server.Close()
+	input := QuestionInput{
+		Question:           "What kind of gift?",
+		Choices:            []string{"Board games", "Books"},
+		ChoiceDescriptions: []string{"best for rainy days", ""},
+	}
+
+	done := make(chan string, 1)
+	go func() {
+		answer, _ := interactor.Interact(context.Background(), input)
+		done <- answer
+	}()
+	question := fetchQuestion(t, server)
+	resp, err := http.Get(server.URL + "/questions/current")
+	require.NoError(t, err)
+	payload, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.NoError(t, err)
+
+	assert.Contains(t, string(payload), `"choices":["Board games","Books"],`)
+	assert.Contains(t, string(payload), `"choiceDescriptions":["best for rainy days",""]`)
+	assert.Equal(t, input.ChoiceDescriptions, question.ChoiceDescriptions)
+	assert.Equal(t, http.StatusNoContent, postAnswer(t, server, question.ID, "Board games"))
+	assert.Equal(t, "Board games", <-done)
+}
+
+func TestHTTPInteractor_Answer(t *testing.T) {
+	no := false
+	tests := []struct {
+		name     string
+		input    QuestionInput
+		id       string
+		answer   string
+		status   int
+		expected string
+		err      error
+	}{
+		{name: "answer", input: QuestionInput{Question: "What gender?"}, id: "1", answer: "Boy", status: http.StatusNoContent, expected: "Boy"},
+		{name: "empty answer selects the default", input: QuestionInput{Question: "What gender?", Default: "Both"}, id: "1", answer: " ", status: http.StatusNoContent, expected: "Both"},
+		{name: "empty answer without default", input: QuestionInput{Question: "What gender?"}, id: "1", answer: "", status: http.StatusBadRequest},
+		{name: "unknown question", input: QuestionInput{Question: "What gender?"}, id: "7", answer: "Boy", status: http.StatusNotFound},
+		{name: "strict choice by number", input: QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}, AllowFreeText: &no}, id: "1", answer: "2", status: http.StatusNoContent, expected: "Girl"},
+		{name: "free text to strict choices", input: QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}, AllowFreeText: &no}, id: "1", answer: "Twins", status: http.StatusBadRequest},
+		{name: "empty answer skips an optional question", input: QuestionInput{Question: "Any allergies?", Required: &no}, id: "1", answer: "", status: http.StatusNoContent, err: ErrQuestionSkipped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interactor := NewHTTPInteractor(WithHTTPAnswerTimeout(100 * time.Millisecond))
+			server := httptest.NewServer(interactor.Handler())
+			defer server.Close()
+
+			type result struct {
+				answer string
+				err    error
+			}
+			done := make(chan result, 1)
+			go func() {
+				answer, err := interactor.Interact(context.Background(), tt.input)
+				done <- result{answer, err}
+			}()
+			fetchQuestion(t, server)
+
+			assert.Equal(t, tt.status, postAnswer(t, server, tt.id, tt.answer))
+			res := <-done
+			if tt.err != nil {
+				assert.ErrorIs(t, res.err, tt.err)
+				return
+			}
+			if tt.expected == "" {
+				var timeoutErr *ErrAnswerTimeout
+				assert.True(t, errors.As(res.err, &timeoutErr))
+				return
+			}
+			require.NoError(t, res.err)
+			assert.Equal(t, tt.expected, res.answer)
+		})
+	}
+}
+
+func TestHTTPInteractor_AnswerTooLarge(t *testing.T) {
+	interactor := NewHTTPInteractor(WithHTTPAnswerTimeout(time.Second))
+	server := httptest.NewServer(interactor.Handler())
+	defer server.Close()
+
+	done := make(chan string, 1)
+	go func() {
+		answer, _ := interactor.Interact(context.Background(), QuestionInput{Question: "Any notes?"})
+		done <- answer
+	}()
+	question := fetchQuestion(t, server)
+
+	assert.Equal(t, http.StatusBadRequest, postAnswer(t, server, question.ID, strings.Repeat("a", maxRequestBodySize)))
+	assert.Equal(t, http.StatusNoContent, postAnswer(t, server, question.ID, "None"), "the question is still pending")
+	assert.Equal(t, "None", <-done)
+}
+
+func TestHTTPInteractor_MaxAnswerTimeout(t *testing.T) {
+	interactor := NewHTTPInteractor(WithHTTPMaxAnswerTimeout(20 * time.Millisecond))
+
+	_, err := interactor.Interact(context.Background(), QuestionInput{Question: "Can you check the wishlist?", TimeoutSeconds: 3600})
+
+	var timeoutErr *ErrAnswerTimeout
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, 20*time.Millisecond, timeoutErr.Timeout, "the requested timeout is capped")
+	assert.Equal(t, defaultMaxAnswerTimeout, NewHTTPInteractor().maxTimeout, "the timeouts are capped by default")
+}
+
+func TestHTTPInteractor_Cancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	interactor := NewHTTPInteractor()
+	server := httptest.NewServer(interactor.Handler())
+	defer server.Close()
+
+	_, err := interactor.Interact(ctx, QuestionInput{Question: "What gender?"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, http.StatusNotFound, postAnswer(t, server, "1", "Boy"), "a cancelled question can't be answered")
+}
+
+func TestHTTPInteractor_AskForm(t *testing.T) {
+	interactor := NewHTTPInteractor(WithHTTPAnswerTimeout(time.Second))
+	server := httptest.NewServer(interactor.Handler())
+	defer server.Close()
+
+	results := make(chan ToolResult, 1)
+	go func() {
+		result, err := HandleForm(context.Background(), interactor, giftForm())
+		assert.NoError(t, err)
+		results <- result
+	}()
+
+	question := fetchQuestion(t, server)
+	require.NotNil(t, question.Form)
+	assert.Equal(t, "About the children", question.Question)
+	assert.Len(t, question.Form.Fields, 3)
+
+	post := func(fields map[string]string) (int, string) {
+		body, err := json.Marshal(HTTPAnswer{Fields: fields})
+		require.NoError(t, err)
+		resp, err := http.Post(server.URL+"/questions/"+question.ID+"/answer", "application/json", strings.NewReader(string(body)))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var message strings.Builder
+		_, _ = io.Copy(&message, resp.Body)
+		return resp.StatusCode, message.String()
+	}
+	status, message := post(map[string]string{"age": "eight", "gender": "Boy"})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, message, "How old is the child?: expected a number")
+
+	status, _ = post(map[string]string{"age": "11", "gender": "boy", "interests": "football"})
+	assert.Equal(t, http.StatusNoContent, status)
+	result := <-results
+	assert.Equal(t, map[string]any{"age": 11.0, "gender": "Boy", "interests": "football"}, result.Output)
+}
+
+func TestHTTPInteractor_AskFile(t *testing.T) {
+	interactor := NewHTTPInteractor(WithHTTPAnswerTimeout(time.Second))
+	server := httptest.NewServer(interactor.Handler())
+	defer server.Close()
+
+	results := make(chan ToolResult, 1)
+	go func() {
+		input := map[string]any{"question": "Please share the wishlist", "acceptedTypes": []any{"text/*"}, "maxBytes": 16}
+		result, err := NewRequestFileHandler()(context.Background(), interactor, input)
+		assert.NoError(t, err)
+		results <- result
+	}()
+
+	question := fetchQuestion(t, server)
+	require.NotNil(t, question.File)
+	assert.Equal(t, "Please share the wishlist", question.Question)
+	assert.Equal(t, int64(16), question.File.MaxBytes)
+
+	upload := func(name, content string) (int, string) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("file", name)
+		require.NoError(t, err)
+		_, err = part.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		resp, err := http.Post(server.URL+"/questions/"+question.ID+"/answer", writer.FormDataContentType(), &body)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var message strings.Builder
+		_, _ = io.Copy(&message, resp.Body)
+		return resp.StatusCode, message.String()
+	}
+	status, message := upload("wishlist.txt", "Lego, a bike and a kite")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, message, "larger than the limit of 16 bytes")
+
+	status, message = upload("photo.png", "\x89PNG\r\n\x1a\n")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, message, "photo.png is image/png, expected text/*")
+
+	resp, err := http.Post(server.URL+"/questions/"+question.ID+"/answer", "application/json", strings.NewReader(`{"answer":"wishlist.txt"}`))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	status, _ = upload("wishlist.txt", "Lego, a bike")
+	assert.Equal(t, http.StatusNoContent, status)
+	result := <-results
+	assert.Equal(t, FileReference{
+		Name:        "wishlist.txt",
+		ContentType: "text/plain; charset=utf-8",
+		Size:        12,
+		Data:        "data:text/plain; charset=utf-8;base64,TGVnbywgYSBiaWtl",
+	}, result.Output)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// maxRequestBodySize limits the size of the bodies of the HTTP requests the interactors and the run
+// server read, such as posted answers and answer callbacks.
+const maxRequestBodySize = 1 << 20
+
+// questionTimeout returns how long to wait for the answer to the question: the timeout the question
+// requests through TimeoutSeconds, capped at limit unless limit is 0, or timeout when it requests none.
+func questionTimeout(input QuestionInput, timeout, limit time.Duration) time.Duration {
+	if input.TimeoutSeconds <= 0 {
+		return timeout
+	}
+	requested := time.Duration(input.TimeoutSeconds) * time.Second
+	if limit > 0 && requested > limit {
+		return limit
+	}
+	return requested
+}
+
+// Answer is the user's reply to a question.
+type Answer struct {
+	// Value is the answer text. It is empty when the question was skipped.
+	Value string
+	// Skipped is set when the user chose not to answer; the model continues with its own assumption.
+	Skipped bool
+	// Meta carries details about how the answer was given, such as the channel it came from.
+	Meta map[string]any
+}
+
+// Interactor is the way the agent talks to the user.
+type Interactor interface {
+	// Ask asks the question and returns the user's answer. Errors such as ErrRephraseRequested
+	// steer the conversation, while other errors stop the run.
+	Ask(ctx context.Context, input QuestionInput) (Answer, error)
+	// Notify shows the user a message that needs no answer.
+	Notify(ctx context.Context, message string) error
+	// Close releases the interactor's resources. Ask fails once the interactor is closed.
+	Close() error
+}
+
+// funcInteractor adapts a UserInteractionFunc to the Interactor interface.
+// It has no way to notify the user and nothing to close.
+type funcInteractor struct {
+	fn UserInteractionFunc
+}
+
+// InteractorFunc returns an Interactor asking questions with fn. ErrQuestionSkipped returned by fn
+// becomes a skipped Answer; Notify and Close do nothing.
+func InteractorFunc(fn UserInteractionFunc) Interactor {
+	return &funcInteractor{fn: fn}
+}
+
+func (f *funcInteractor) Ask(ctx context.Context, input QuestionInput) (Answer, error) {
+	value, err := f.fn(ctx, input)
+	return answerOf(value, err)
+}
+
+func (f *funcInteractor) Notify(context.Context, string) error {
+	return nil
+}
+
+func (f *funcInteractor) Close() error {
+	return nil
+}
+
+// answerOf turns the result of a UserInteractionFunc into an Answer.
+func answerOf(value string, err error) (Answer, error) {
+	if errors.Is(err, ErrQuestionSkipped) {
+		return Answer{Skipped: true}, nil
+	}
+	if err != nil {
+		return Answer{}, err
+	}
+	return Answer{Value: value}, nil
+}
+
+// answerText returns what the model is told the user answered.
+func answerText(answer Answer) string {
+	if answer.Skipped {
+		text, _ := steeringAnswer(ErrQuestionSkipped)
+		return text
+	}
+	return answer.Value
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeInteractor answers from a map and records the notifications it is sent.
+type fakeInteractor struct {
+	answers  map[string]Answer
+	notified []string
+	closed   bool
+}
+
+func (f *fakeInteractor) Ask(_ context.Context, input QuestionInput) (Answer, error) {
+	answer, ok := f.answers[input.Question]
+	if !ok {
+		return Answer{}, ErrConversationAborted
+	}
+	return answer, nil
+}
+
+func (f *fakeInteractor) Notify(_ context.Context, message string) error {
+	f.notified = append(f.notified, message)
+	return nil
+}
+
+func (f *fakeInteractor) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestInteractorFunc(t *testing.T) {
+	failure := errors.New("input closed")
+	tests := []struct {
+		name        string
+		value       string
+		err         error
+		expected    Answer
+		expectedErr error
+	}{
+		{name: "answer", value: "Boy", expected: Answer{Value: "Boy"}},
+		{name: "skip", err: ErrQuestionSkipped, expected: Answer{Skipped: true}},
+		{name: "steering error", err: ErrRephraseRequested, expectedErr: ErrRephraseRequested},
+		{name: "error", err: failure, expectedErr: failure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interactor := InteractorFunc(func(context.Context, QuestionInput) (string, error) {
+				return tt.value, tt.err
+			})
+
+			answer, err := interactor.Ask(context.Background(), QuestionInput{Question: "What gender?"})
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, answer)
+			assert.NoError(t, interactor.Notify(context.Background(), "hello"))
+			assert.NoError(t, interactor.Close())
+		})
+	}
+}
+
+func TestInterruptionHandler_Interactor(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createToolRequestPart("askQuestion", "What is the budget?", nil),
+			),
+			createTextResponse("Based on both genders...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	interactor := &fakeInteractor{answers: map[string]Answer{
+		"What gender are the children?": {Value: "Both", Meta: map[string]any{"channel": "fake"}},
+		"What is the budget?":           {Skipped: true},
+	}}
+	handler := &InterruptionHandler{
+		generator:  mockGen,
+		Interactor: interactor,
+		UserInteraction: func(context.Context, QuestionInput) (string, error) {
+			return "", errors.New("the Interactor takes precedence")
+		},
+	}
+
+	result, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Based on both genders...", result)
+	toolResponses := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, toolResponses, 2)
+	assert.Equal(t, "Both", toolResponses[0].ToolResponse.Output)
+	assert.Equal(t, "The user skipped this question. Continue with your best assumption.", toolResponses[1].ToolResponse.Output)
+}
+
+// capturedToolResponses extracts the parts passed with ai.WithToolResponses.
+func capturedToolResponses(opts []ai.GenerateOption) []*ai.Part {
+	return capturedOptionParts(opts, "RespondParts")
+}
+
+// capturedToolRestarts returns the tool requests passed with ai.WithToolRestarts.
+func capturedToolRestarts(opts []ai.GenerateOption) []*ai.Part {
+	return capturedOptionParts(opts, "RestartParts")
+}
+
+// capturedOptionParts returns the parts held by the named field of the generate options.
+func capturedOptionParts(opts []ai.GenerateOption, name string) []*ai.Part {
+	var parts []*ai.Part
+	for _, opt := range opts {
+		v := reflect.ValueOf(opt)
+		if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		field := v.Elem().FieldByName(name)
+		if !field.IsValid() {
+			continue
+		}
+		parts = append(parts, field.Interface().([]*ai.Part)...)
+	}
+	return parts
+}
+
+func TestTerminalReader_Interactor(t *testing.T) {
+	var out bytes.Buffer
+	var interactor Interactor = NewTerminalReader(context.Background(), strings.NewReader("Boy\n/skip\n"),
+		WithOutput(&out), WithWrapping(false))
+
+	answer, err := interactor.Ask(context.Background(), QuestionInput{Question: "What gender?"})
+	require.NoError(t, err)
+	assert.Equal(t, Answer{Value: "Boy"}, answer)
+
+	answer, err = interactor.Ask(context.Background(), QuestionInput{Question: "What budget?"})
+	require.NoError(t, err)
+	assert.Equal(t, Answer{Skipped: true}, answer)
+
+	require.NoError(t, interactor.Notify(context.Background(), "Thinking about gifts..."))
+	assert.Contains(t, out.String(), "Thinking about gifts...\n")
+
+	require.NoError(t, interactor.Close())
+	_, err = interactor.Ask(context.Background(), QuestionInput{Question: "What age?"})
+	assert.ErrorIs(t, err, ErrReaderClosed)
+}
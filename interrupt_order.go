@@ -0,0 +1,84 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// InterruptOrder compares two interrupts of a batch, like the cmp function of slices.SortFunc, to choose
+// which question is asked first: a negative result asks a first. Interrupts comparing equal keep the
+// order the model raised them in.
+type InterruptOrder func(a, b Interrupt) int
+
+// ByPriority asks the questions with a higher priority first. Questions without one have priority 0.
+func ByPriority(a, b Interrupt) int {
+	return cmp.Compare(interruptPriority(b), interruptPriority(a))
+}
+
+// RequiredFirst asks the required questions before the optional ones.
+func RequiredFirst(a, b Interrupt) int {
+	optional := func(interrupt Interrupt) int {
+		if required, ok := interrupt.Input["required"].(bool); ok && !required {
+			return 1
+		}
+		return 0
+	}
+	return cmp.Compare(optional(a), optional(b))
+}
+
+// ShortestFirst asks the questions with the shortest text first. Interrupts without a question, such as
+// those of tools asking a statement, count as empty.
+func ShortestFirst(a, b Interrupt) int {
+	question := func(interrupt Interrupt) string {
+		text, _ := interrupt.Input["question"].(string)
+		return strings.TrimSpace(text)
+	}
+	return cmp.Compare(len(question(a)), len(question(b)))
+}
+
+// OrderBy combines orders: interrupts the first order compares equal are compared with the next one.
+func OrderBy(orders ...InterruptOrder) InterruptOrder {
+	return func(a, b Interrupt) int {
+		for _, order := range orders {
+			if c := order(a, b); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+}
+
+// askingOrder returns the indexes of the interrupts in the order they are asked.
+func (ih *InterruptionHandler) askingOrder(interrupts []*ai.Part) []int {
+	order := make([]int, len(interrupts))
+	for i := range order {
+		order[i] = i
+	}
+	if ih.InterruptOrder == nil {
+		return order
+	}
+	described := make([]Interrupt, len(interrupts))
+	for i, part := range interrupts {
+		input, _ := part.ToolRequest.Input.(map[string]any)
+		described[i] = Interrupt{ToolName: part.ToolRequest.Name, Input: input}
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return ih.InterruptOrder(described[a], described[b])
+	})
+	return order
+}
+
+// interruptPriority returns the priority the model set in the input, decoded from JSON as a float64.
+func interruptPriority(interrupt Interrupt) float64 {
+	switch priority := interrupt.Input["priority"].(type) {
+	case float64:
+		return priority
+	case int:
+		return float64(priority)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterruptionHandler_AskingOrder(t *testing.T) {
+	interrupts := []*ai.Part{
+		createInterruptPart("askQuestion", map[string]any{"question": "Any allergies we should avoid?", "required": false}),
+		createInterruptPart("askQuestion", map[string]any{"question": "What ages are the children?", "priority": 1.0}),
+		createInterruptPart("askQuestion", map[string]any{"question": "Boy or girl?", "priority": 2}),
+	}
+
+	tests := []struct {
+		name     string
+		order    InterruptOrder
+		expected []int
+	}{
+		{name: "original order by default", expected: []int{0, 1, 2}},
+		{name: "by priority", order: ByPriority, expected: []int{2, 1, 0}},
+		{name: "required first", order: RequiredFirst, expected: []int{1, 2, 0}},
+		{name: "shortest first", order: ShortestFirst, expected: []int{2, 1, 0}},
+		{name: "required then shortest", order: OrderBy(RequiredFirst, ShortestFirst), expected: []int{2, 1, 0}},
+		{
+			name: "custom",
+			order: func(a, b Interrupt) int {
+				if a.Input["question"] == "What ages are the children?" {
+					return -1
+				}
+				return 0
+			},
+			expected: []int{1, 0, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &InterruptionHandler{InterruptOrder: tt.order}
+
+			assert.Equal(t, tt.expected, handler.askingOrder(interrupts))
+		})
+	}
+}
+
+func TestInterruptionHandler_InterruptOrder(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createInterruptPart("askQuestion", map[string]any{"question": "Any allergies?", "required": false}),
+				createInterruptPart("askQuestion", map[string]any{"question": "What ages?", "priority": 1}),
+				createInterruptPart("askQuestion", map[string]any{"question": "Boy or girl?", "priority": 2}),
+			),
+			createTextResponse("A LEGO set", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	answers := map[string]string{"Any allergies?": "None", "What ages?": "8 and 11", "Boy or girl?": "Both"}
+	var asked []string
+	var positions []QuestionPosition
+	handler := &InterruptionHandler{
+		generator:      mockGen,
+		InterruptOrder: ByPriority,
+		UserInteraction: func(ctx context.Context, input QuestionInput) (string, error) {
+			asked = append(asked, input.Question)
+			position, _ := QuestionPositionFromContext(ctx)
+			positions = append(positions, position)
+			return answers[input.Question], nil
+		},
+	}
+
+	_, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Boy or girl?", "What ages?", "Any allergies?"}, asked)
+	assert.Equal(t, []QuestionPosition{{Index: 1, Total: 3}, {Index: 2, Total: 3}, {Index: 3, Total: 3}}, positions)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 3)
+	// the responses keep the order of the interrupts, each with the answer to its own question
+	assert.Equal(t, "None", parts[0].ToolResponse.Output)
+	assert.Equal(t, "8 and 11", parts[1].ToolResponse.Output)
+	assert.Equal(t, "Both", parts[2].ToolResponse.Output)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// defaultInterruptMetadataKey is the interrupt metadata key holding the tool input unless configured otherwise.
+const defaultInterruptMetadataKey = "input"
+
+// interruptToolConfig configures a tool defined by DefineInterruptTool.
+type interruptToolConfig struct {
+	metadataKey string
+}
+
+// InterruptToolOption configures a tool defined by DefineInterruptTool.
+type InterruptToolOption func(*interruptToolConfig)
+
+// WithInterruptMetadataKey stores the tool input in the interrupt metadata under the key instead of "input".
+func WithInterruptMetadataKey(key string) InterruptToolOption {
+	return func(c *interruptToolConfig) {
+		c.metadataKey = key
+	}
+}
+
+// DefineInterruptTool defines a tool in the Genkit instance that never runs by itself: every call
+// interrupts the run, with the input and the tool name in the interrupt metadata, so that the user can
+// answer it. In is the input the model fills in and Out the output it is answered with, which together
+// make the tool's schema. Handlers read the input back with ParseInterruptInput. When the tool is
+// restarted for a deferred question, a tool answered with a string responds with a message telling the
+// model that the user put the question off.
+func DefineInterruptTool[In, Out any](g *genkit.Genkit, name, description string, opts ...InterruptToolOption) ai.Tool {
+	config := interruptToolConfig{metadataKey: defaultInterruptMetadataKey}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return genkit.DefineTool(
+		g,
+		name,
+		description,
+		func(ctx *ai.ToolContext, input In) (Out, error) {
+			var output Out
+			// a question the user put off is restarted with the "deferred" metadata, which tools answered
+			// with text tell the model about
+			if deferred, _ := ctx.Resumed["deferred"].(bool); deferred {
+				if text, ok := any(&output).(*string); ok {
+					*text = deferredAnswer
+					return output, nil
+				}
+			}
+			return output, ctx.Interrupt(&ai.InterruptOptions{
+				Metadata: map[string]any{
+					config.metadataKey: input,
+					"tool":             name,
+				},
+			})
+		},
+	)
+}
+
+// ParseInterruptInput converts the input of the interrupted tool request into the tool's input struct.
+func ParseInterruptInput[In any](part *ai.Part) (*In, error) {
+	if part == nil || part.ToolRequest == nil {
+		return nil, errors.New("part is not a tool request")
+	}
+	return parseToolInput[In](part.ToolRequest.Input)
+}
+
+// parseToolInput converts the raw input of a tool request, which the model sends as a JSON object,
+// into the input struct.
+func parseToolInput[In any](input any) (*In, error) {
+	rawInput, ok := input.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unexpected input type: %T", input)
+	}
+	var parsed In
+	if err := decodeToolInput(rawInput, &parsed); err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
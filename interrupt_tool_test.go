@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tripStop and tripInput are a custom tool input with nested fields and slices.
+type tripStop struct {
+	City   string `json:"city"`
+	Nights int    `json:"nights"`
+}
+
+type tripInput struct {
+	Question string     `json:"question"`
+	Stops    []tripStop `json:"stops"`
+	Budget   struct {
+		Amount   float64 `json:"amount"`
+		Currency string  `json:"currency"`
+	} `json:"budget"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+func tripRawInput() map[string]any {
+	return map[string]any{
+		"question": "Which trip do you prefer?",
+		"stops": []any{
+			map[string]any{"city": "Lisbon", "nights": 2},
+			map[string]any{"city": "Porto", "nights": 3},
+		},
+		"budget": map[string]any{"amount": 1200.5, "currency": "EUR"},
+		"tags":   []any{"beach", "food"},
+	}
+}
+
+func TestDefineInterruptTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+	tests := []struct {
+		name        string
+		tool        ai.Tool
+		metadataKey string
+	}{
+		{name: "planTrip", tool: DefineInterruptTool[tripInput, string](g, "planTrip", "use this to plan a trip"), metadataKey: "input"},
+		{name: "pickTrip", tool: DefineInterruptTool[tripInput, []string](g, "pickTrip", "use this to pick a trip", WithInterruptMetadataKey("trip")), metadataKey: "trip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.tool.RunRaw(context.Background(), tripRawInput())
+
+			assert.Equal(t, tt.name, tt.tool.Name())
+			interrupted, metadata := ai.IsToolInterruptError(err)
+			require.True(t, interrupted, "unexpected error: %v", err)
+			assert.Equal(t, tt.name, metadata["tool"])
+			trip, ok := metadata[tt.metadataKey].(tripInput)
+			require.True(t, ok, "unexpected metadata: %v", metadata)
+			assert.Equal(t, []tripStop{{City: "Lisbon", Nights: 2}, {City: "Porto", Nights: 3}}, trip.Stops)
+			assert.Equal(t, "EUR", trip.Budget.Currency)
+		})
+	}
+}
+
+func TestDefineInterruptTool_DeferredRestart(t *testing.T) {
+	g := genkit.Init(context.Background())
+	tool := DefineInterruptTool[QuestionInput, string](g, "askQuestion", "use this to ask a question")
+	// the model answers with the output of the last tool response it gets
+	model := genkit.DefineModel(g, "test/echo", &ai.ModelOptions{Supports: &ai.ModelSupports{Tools: true, Multiturn: true}},
+		func(_ context.Context, req *ai.ModelRequest, _ ai.ModelStreamCallback) (*ai.ModelResponse, error) {
+			last := req.Messages[len(req.Messages)-1]
+			require.NotEmpty(t, last.Content)
+			require.NotNil(t, last.Content[0].ToolResponse)
+			return &ai.ModelResponse{Message: ai.NewModelTextMessage(last.Content[0].ToolResponse.Output.(string)), FinishReason: ai.FinishReasonStop}, nil
+		})
+	request := ai.NewToolRequestPart(&ai.ToolRequest{Name: "askQuestion", Ref: "1", Input: map[string]any{"question": "What budget?", "choices": []any{"Under $25", "Over $25"}}})
+
+	resp, err := genkit.Generate(context.Background(), g,
+		ai.WithModel(model),
+		ai.WithTools(tool),
+		ai.WithMessages(ai.NewUserTextMessage("Find a gift"), ai.NewModelMessage(request)),
+		ai.WithToolRestarts(tool.Restart(request, &ai.RestartOptions{ResumedMetadata: map[string]any{"deferred": true}})),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, deferredAnswer, resp.Text(), "the deferred question is answered instead of interrupting again")
+}
+
+func TestParseInterruptInput(t *testing.T) {
+	t.Run("nested fields and slices", func(t *testing.T) {
+		trip, err := ParseInterruptInput[tripInput](createInterruptPart("planTrip", tripRawInput()))
+
+		require.NoError(t, err)
+		assert.Equal(t, "Which trip do you prefer?", trip.Question)
+		assert.Equal(t, []tripStop{{City: "Lisbon", Nights: 2}, {City: "Porto", Nights: 3}}, trip.Stops)
+		assert.Equal(t, 1200.5, trip.Budget.Amount)
+		assert.Equal(t, "EUR", trip.Budget.Currency)
+		assert.Equal(t, []string{"beach", "food"}, trip.Tags)
+	})
+
+	tests := []struct {
+		name string
+		part *ai.Part
+		err  string
+	}{
+		{name: "nil part", part: nil, err: "part is not a tool request"},
+		{name: "text part", part: ai.NewTextPart("hello"), err: "part is not a tool request"},
+		{name: "input not an object", part: ai.NewToolRequestPart(&ai.ToolRequest{Name: "planTrip", Input: "Lisbon"}), err: "unexpected input type: string"},
+		{name: "mismatched field type", part: createInterruptPart("planTrip", map[string]any{"stops": "Lisbon"}), err: "failed to unmarshal input"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trip, err := ParseInterruptInput[tripInput](tt.part)
+
+			assert.ErrorContains(t, err, tt.err)
+			assert.Nil(t, trip)
+		})
+	}
+}
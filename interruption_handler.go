@@ -2,9 +2,10 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/firebase/genkit/go/ai"
 )
@@ -12,21 +13,82 @@ import (
 // getQuestionInput converts an arbitrary input into a QuestionInput struct.
 // It handles type conversion from map[string]any to the structured QuestionInput type.
 func getQuestionInput(input any) (*QuestionInput, error) {
-	var questionInput QuestionInput
-	rawInput, ok := input.(map[string]any)
-	if !ok {
-		return nil, fmt.Errorf("unexpected input type: %T", input)
-	}
+	return parseToolInput[QuestionInput](input)
+}
 
-	jsonBytes, err := json.Marshal(rawInput)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal input: %w", err)
-	}
-	if err := json.Unmarshal(jsonBytes, &questionInput); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal input: %w", err)
+// Errors returned by a user interaction to steer the conversation instead of answering.
+var (
+	// ErrQuestionSkipped means the user chose not to answer; the model continues with its own assumption.
+	ErrQuestionSkipped = errors.New("question skipped by the user")
+	// ErrConversationAborted means the user wants to stop; the run ends with this error.
+	ErrConversationAborted = errors.New("conversation aborted by the user")
+	// ErrRephraseRequested means the user didn't understand the question and wants it asked differently.
+	ErrRephraseRequested = errors.New("user asked to rephrase the question")
+	// ErrPreviousQuestion means the user wants to change the answer to the previous question.
+	ErrPreviousQuestion = errors.New("user asked to go back to the previous question")
+	// ErrQuestionDeferred means the user wants to answer the question after the others. A question can
+	// be deferred once; deferring it again asks it again at once.
+	ErrQuestionDeferred = errors.New("user asked to answer the question later")
+)
+
+// maxInvalidInputs is how many malformed tool inputs a run reports to the model before failing.
+const maxInvalidInputs = 3
+
+// ErrInvalidInput is returned when the model calls a tool with an input that can't be used, such as a
+// question tool call without a question. Unless InterruptionHandler.StrictInput is set, it is reported
+// to the model so that it can call the tool again with a corrected input.
+type ErrInvalidInput struct {
+	Err error
+}
+
+func (e *ErrInvalidInput) Error() string {
+	return "invalid input: " + e.Err.Error()
+}
+
+func (e *ErrInvalidInput) Unwrap() error {
+	return e.Err
+}
+
+// deferredAnswer tells the model that the user put the question off.
+const deferredAnswer = "The user wants to answer this question later. Continue with the other questions and ask this one again after them."
+
+// steeringAnswer translates an interaction error into a message for the model.
+// It returns false for errors that must stop the run.
+func steeringAnswer(err error) (string, bool) {
+	switch {
+	case errors.Is(err, ErrQuestionSkipped):
+		return "The user skipped this question. Continue with your best assumption.", true
+	case errors.Is(err, ErrRephraseRequested):
+		return "The user did not understand the question. Ask it again in different words.", true
+	case errors.Is(err, ErrPreviousQuestion):
+		return "The user wants to change their answer to the previous question. Ask the previous question again.", true
+	case errors.Is(err, ErrQuestionDeferred):
+		return deferredAnswer, true
+	default:
+		return "", false
 	}
+}
+
+// QuestionPosition locates a question among the interrupts the model raised in a single response.
+type QuestionPosition struct {
+	// Index is the 1-based position of the question in the batch.
+	Index int
+	// Total is the number of questions in the batch.
+	Total int
+}
+
+type questionPositionKey struct{}
+
+// WithQuestionPosition returns a context carrying the position of the question being asked.
+func WithQuestionPosition(ctx context.Context, position QuestionPosition) context.Context {
+	return context.WithValue(ctx, questionPositionKey{}, position)
+}
 
-	return &questionInput, nil
+// QuestionPositionFromContext returns the position of the question being asked.
+// It reports false when the question isn't part of a batch of interrupts.
+func QuestionPositionFromContext(ctx context.Context) (QuestionPosition, bool) {
+	position, ok := ctx.Value(questionPositionKey{}).(QuestionPosition)
+	return position, ok
 }
 
 // UserInteractionFunc sends questions to the user and returns their answer.
@@ -34,19 +96,152 @@ type UserInteractionFunc func(ctx context.Context, input QuestionInput) (string,
 
 // InterruptionHandler handles interruptions during AI generation, specifically for asking clarifying questions.
 type InterruptionHandler struct {
-	generator       Generator
+	generator Generator
+	// Interactor asks the questions. When it is nil, UserInteraction is used instead.
+	Interactor      Interactor
 	UserInteraction UserInteractionFunc
+	// toolNames lists the tools offered when generation resumes. The question tool is always included.
+	toolNames []string
+	// QuestionTool is the name of the question tool, such as one defined by DefineAskQuestionTool with
+	// another name; it's askQuestion when empty.
+	QuestionTool string
+	// toolHandlers answer the interrupts of tools other than the question tools, by tool name.
+	toolHandlers map[string]ToolHandler
+	// choiceProviders fill in the choices of questions, by choicesSource.
+	choiceProviders map[string]choiceProvider
+	// OnAnswer, when set, is called with every interrupt once it is answered, for example to log the
+	// conversation. Secret answers are passed as RedactedAnswer.
+	OnAnswer func(ctx context.Context, answered AnsweredInterrupt)
+	// Locale is the language code of the user, such as "de". When it isn't English and Translator is
+	// set, questions of the question tools are translated to it and their answers back to English.
+	Locale     string
+	Translator Translator
+	// InterruptOrder, when set, orders the questions of a batch of interrupts, such as ByPriority. The
+	// questions are asked in the order the model raised them otherwise.
+	InterruptOrder InterruptOrder
+	// StrictInput makes a malformed tool input fail the run with ErrInvalidInput. By default the tool
+	// request is answered with the error so that the model can correct it, up to 3 times in a run.
+	StrictInput bool
+	// QuestionCache, when set, answers the questions of the question tools that were answered before,
+	// such as with a SemanticQuestionCache, instead of asking the user again.
+	QuestionCache QuestionCache
 }
 
-// handleResponse processes the model response, handling any "askQuestion" tool calls (interrupts).
+// AnsweredInterrupt describes an answered interrupt to OnAnswer.
+type AnsweredInterrupt struct {
+	// ToolName is the name of the interrupting tool.
+	ToolName string
+	// Input is the raw tool input.
+	Input map[string]any
+	// Output is what the model is told, or RedactedAnswer for a secret answer.
+	Output any
+	// Metadata is the response metadata a tool handler returned, such as the "consent" record.
+	Metadata map[string]any
+	// Deferred is set when the user put the question off to answer it later instead of answering.
+	Deferred bool
+}
+
+// interactor returns the Interactor asking the questions.
+func (ih *InterruptionHandler) interactor() Interactor {
+	if ih.Interactor != nil {
+		return ih.Interactor
+	}
+	return InteractorFunc(ih.UserInteraction)
+}
+
+// questionTool returns the name of the question tool.
+func (ih *InterruptionHandler) questionTool() string {
+	if ih.QuestionTool == "" {
+		return defaultAskQuestionToolName
+	}
+	return ih.QuestionTool
+}
+
+// withQuestionTool returns toolNames with the question tool added when it is missing.
+func withQuestionTool(questionTool string, toolNames []string) []string {
+	if slices.Contains(toolNames, questionTool) {
+		return toolNames
+	}
+	return append([]string{questionTool}, toolNames...)
+}
+
+// ErrRunSuspended is returned when some questions will be answered later, for example by an AsyncInteractor.
+// It holds everything needed to continue the run with InterruptionHandler.Resume once the answers are in.
+type ErrRunSuspended struct {
+	// Response is the interrupted model response the run stopped at.
+	Response *ai.ModelResponse
+	// Pending maps the index of each unanswered interrupt to the correlation ID of its question.
+	Pending map[int]string
+	// Answers holds the answers already given to the other interrupts, by index.
+	Answers map[int]string
+	// Results holds the results of the interrupts already answered by tool handlers or reported as
+	// invalid, by index.
+	Results map[int]ToolResult
+}
+
+func (e *ErrRunSuspended) Error() string {
+	return fmt.Sprintf("run suspended: %d question(s) waiting for an answer", len(e.Pending))
+}
+
+// Interrupt describes the tool call that raised the question being asked.
+type Interrupt struct {
+	// ToolName is the name of the interrupting tool.
+	ToolName string
+	// Input is the raw tool input, including fields QuestionInput doesn't know about.
+	Input map[string]any
+}
+
+type interruptKey struct{}
+
+// WithInterrupt returns a context carrying the tool call that raised the question being asked.
+func WithInterrupt(ctx context.Context, interrupt Interrupt) context.Context {
+	return context.WithValue(ctx, interruptKey{}, interrupt)
+}
+
+// InterruptFromContext returns the tool call that raised the question being asked.
+// It reports false for questions that don't come from an interrupt, such as follow-up questions.
+func InterruptFromContext(ctx context.Context) (Interrupt, bool) {
+	interrupt, ok := ctx.Value(interruptKey{}).(Interrupt)
+	return interrupt, ok
+}
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a context telling the interaction that the question was already asked under the ID.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the ID the question was asked under before the run was suspended.
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+	return id, ok
+}
+
+// handleResponse processes the model response, handling any question tool calls (interrupts).
 // It prompts the user for input and continues generation until a final response is reached.
 func (ih *InterruptionHandler) handleResponse(ctx context.Context, response *ai.ModelResponse) (*ai.ModelResponse, error) {
-	askQuestion := ih.generator.LookupTool("askQuestion")
-	if askQuestion == nil {
-		return nil, errors.New("askQuestion tool not found")
+	return ih.resume(ctx, response, nil)
+}
+
+// Resume continues a run that was suspended with ErrRunSuspended. The pending questions are asked
+// again with their correlation IDs, so an interaction that has their answers by now returns them;
+// the run is suspended again when some are still missing.
+func (ih *InterruptionHandler) Resume(ctx context.Context, suspended *ErrRunSuspended) (*ai.ModelResponse, error) {
+	return ih.resume(ctx, suspended.Response, suspended)
+}
+
+// resume runs the interrupt loop. The answers and correlation IDs of suspended apply to the first response only.
+func (ih *InterruptionHandler) resume(ctx context.Context, response *ai.ModelResponse, suspended *ErrRunSuspended) (*ai.ModelResponse, error) {
+	ctx = withSecretValues(ctx)
+	tools, err := continuationTools(ctx, ih.generator, withQuestionTool(ih.questionTool(), ih.toolNames))
+	if err != nil {
+		return nil, err
 	}
 
-	var err error
+	// deferred holds the questions the user put off during the run, which can't be put off again
+	deferred := map[string]bool{}
+	invalidInputs := 0
 	for response.FinishReason == "interrupted" {
 		select {
 		case <-ctx.Done():
@@ -54,33 +249,111 @@ func (ih *InterruptionHandler) handleResponse(ctx context.Context, response *ai.
 		default:
 		}
 
-		var answers []*ai.Part
-		// multiple interrupts can be called at once, so we handle them all
-		for _, part := range response.Interrupts() {
+		var restarts []*ai.Part
+		next := &ErrRunSuspended{Response: response, Pending: map[int]string{}, Answers: map[int]string{}, Results: map[int]ToolResult{}}
+		// multiple interrupts can be called at once, so we handle them all; the responses keep the order
+		// of the interrupts whatever order they are asked in
+		interrupts := response.Interrupts()
+		responses := make([]*ai.Part, len(interrupts))
+		for asked, i := range ih.askingOrder(interrupts) {
+			part := interrupts[i]
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
 			default:
 			}
 
-			// convert map[string]any to QuestionInput
-			questionInput, err := getQuestionInput(part.ToolRequest.Input)
-			if err != nil {
-				return nil, err
+			result, ok := suspended.result(i)
+			if !ok {
+				position := QuestionPosition{Index: asked + 1, Total: len(interrupts)}
+				askCtx := WithQuestionPosition(ctx, position)
+				rawInput, _ := part.ToolRequest.Input.(map[string]any)
+				askCtx = WithInterrupt(askCtx, Interrupt{ToolName: part.ToolRequest.Name, Input: rawInput})
+				if id, ok := suspended.pendingID(i); ok {
+					askCtx = WithCorrelationID(askCtx, id)
+				}
+				result, err = ih.answerDeferrable(askCtx, part, deferred)
+				// a deferred question is reported once, as deferred rather than with the steering answer
+				isDeferred := errors.Is(err, ErrQuestionDeferred)
+				if isDeferred {
+					if ih.OnAnswer != nil {
+						ih.OnAnswer(ctx, AnsweredInterrupt{ToolName: part.ToolRequest.Name, Input: rawInput, Deferred: true})
+					}
+					// question tools are restarted, which tells the model the question was put off, while the
+					// interrupts of tool handlers, whose output isn't text, are answered with the message instead
+					if _, ok := ih.toolHandlers[part.ToolRequest.Name]; !ok {
+						tool := lookupRunTool(ctx, ih.generator, part.ToolRequest.Name)
+						if tool == nil {
+							return nil, fmt.Errorf("%s tool not found", part.ToolRequest.Name)
+						}
+						restarts = append(restarts, tool.Restart(part, &ai.RestartOptions{ResumedMetadata: map[string]any{"deferred": true}}))
+						continue
+					}
+				}
+				// a malformed input is answered with the error, without asking the user
+				var invalid *ErrInvalidInput
+				reported := errors.As(err, &invalid) && !ih.StrictInput && invalidInputs < maxInvalidInputs
+				if reported {
+					invalidInputs++
+					result, err = ToolResult{Output: map[string]any{"error": invalid.Error()}}, nil
+				}
+				if err != nil {
+					// the remaining questions are still asked so that all of them wait for answers together
+					var pending *ErrAnswerPending
+					if errors.As(err, &pending) {
+						next.Pending[i] = pending.ID
+						continue
+					}
+					steering, ok := steeringAnswer(err)
+					if !ok {
+						var escalated *ErrEscalated
+						if errors.As(err, &escalated) && escalated.History == nil {
+							escalated.History = response.History()
+						}
+						return nil, err
+					}
+					result = ToolResult{Output: steering}
+				}
+				if RedactedOutput(result) == RedactedAnswer {
+					if answer, ok := result.Output.(string); ok {
+						MarkSecret(ctx, answer)
+					}
+				}
+				if ih.OnAnswer != nil && !reported && !isDeferred {
+					ih.OnAnswer(ctx, AnsweredInterrupt{ToolName: part.ToolRequest.Name, Input: rawInput, Output: RedactedOutput(result), Metadata: result.Metadata})
+				}
+			}
+			if answer, ok := result.Output.(string); ok && ih.toolHandlers[part.ToolRequest.Name] == nil {
+				next.Answers[i] = answer
+			} else {
+				next.Results[i] = result
+			}
+			// the answer is given through the interrupting tool, which may be a renamed question tool
+			tool := lookupRunTool(ctx, ih.generator, part.ToolRequest.Name)
+			if tool == nil {
+				return nil, fmt.Errorf("%s tool not found", part.ToolRequest.Name)
 			}
-			answer, err := ih.UserInteraction(ctx, *questionInput)
-			if err != nil {
-				return nil, err
+			var respondOptions *ai.RespondOptions
+			if result.Metadata != nil {
+				respondOptions = &ai.RespondOptions{Metadata: result.Metadata}
 			}
-			// use the `Respond` method on our tool to populate answers
-			answers = append(answers, askQuestion.Respond(part, any(answer), nil))
+			responses[i] = tool.Respond(part, result.Output, respondOptions)
+		}
+		suspended = nil
+		if len(next.Pending) > 0 {
+			return nil, next
 		}
 
-		response, err = ih.generator.Generate(ctx,
+		answers := slices.DeleteFunc(responses, func(part *ai.Part) bool { return part == nil })
+		opts := []ai.GenerateOption{
 			ai.WithMessages(response.History()...),
-			ai.WithTools(askQuestion),
+			ai.WithTools(tools...),
 			ai.WithToolResponses(answers...),
-		)
+		}
+		if len(restarts) > 0 {
+			opts = append(opts, ai.WithToolRestarts(restarts...))
+		}
+		response, err = generateResponse(ctx, ih.generator, opts...)
 
 		if err != nil {
 			return nil, err
@@ -89,3 +362,140 @@ func (ih *InterruptionHandler) handleResponse(ctx context.Context, response *ai.
 
 	return response, nil
 }
+
+// answer asks the question of the interrupt, through the handler of its tool when one is registered.
+func (ih *InterruptionHandler) answer(ctx context.Context, part *ai.Part) (ToolResult, error) {
+	if handler, ok := ih.toolHandlers[part.ToolRequest.Name]; ok {
+		rawInput, _ := part.ToolRequest.Input.(map[string]any)
+		return handler(ctx, ih.interactor(), rawInput)
+	}
+
+	questionInput, err := ParseInterruptInput[QuestionInput](part)
+	if err != nil {
+		// decoding errors are wrapped already, unlike an input that isn't an object
+		var invalid *ErrInvalidInput
+		if !errors.As(err, &invalid) {
+			err = &ErrInvalidInput{Err: err}
+		}
+		return ToolResult{}, err
+	}
+	if strings.TrimSpace(questionInput.Question) == "" {
+		return ToolResult{}, &ErrInvalidInput{Err: errors.New("question is required")}
+	}
+	question := ih.provideChoices(ctx, *questionInput)
+	if answer, ok := ih.cachedAnswer(ctx, question); ok {
+		return ToolResult{Output: answer}, nil
+	}
+	var result ToolResult
+	if ih.translates() {
+		result, err = ih.askTranslated(ctx, question)
+	} else {
+		var reply Answer
+		reply, err = askQuestion(ctx, ih.interactor(), question)
+		result = ToolResult{Output: answerText(reply)}
+	}
+	if err != nil {
+		return ToolResult{}, err
+	}
+	ih.storeAnswer(ctx, question, result)
+	return result, nil
+}
+
+// cachedAnswer returns the answer QuestionCache has for the question. An answer that isn't one of the
+// strict choices of the question, such as one given to a question with other choices, isn't used.
+func (ih *InterruptionHandler) cachedAnswer(ctx context.Context, question QuestionInput) (string, bool) {
+	if ih.QuestionCache == nil {
+		return "", false
+	}
+	answer, ok := ih.QuestionCache.Lookup(ctx, question.Question)
+	strict := len(question.Choices) > 0 && !question.FreeTextAllowed()
+	if !ok || (strict && !slices.Contains(question.Choices, answer) && !slices.Contains(question.ChoiceValues, answer)) {
+		return "", false
+	}
+	return answer, true
+}
+
+// storeAnswer stores the answer to the question in QuestionCache. A skipped question isn't stored, so
+// that it's asked again.
+func (ih *InterruptionHandler) storeAnswer(ctx context.Context, question QuestionInput, result ToolResult) {
+	answer, ok := result.Output.(string)
+	if ih.QuestionCache == nil || !ok || answer == answerText(Answer{Skipped: true}) {
+		return
+	}
+	ih.QuestionCache.Store(ctx, question.Question, answer)
+}
+
+// answerDeferrable answers the interrupt like answer, and lets the user put the question off once: a
+// question deferred earlier in the run is asked again at once, up to maxParseAttempts times, after which
+// it is treated as skipped. A question deferred for the first time returns ErrQuestionDeferred.
+func (ih *InterruptionHandler) answerDeferrable(ctx context.Context, part *ai.Part, deferred map[string]bool) (ToolResult, error) {
+	key := part.ToolRequest.Name
+	if input, ok := part.ToolRequest.Input.(map[string]any); ok {
+		question, _ := input["question"].(string)
+		key += "\x00" + strings.TrimSpace(question)
+	}
+
+	result, err := ih.answer(ctx, part)
+	for range maxParseAttempts - 1 {
+		if !errors.Is(err, ErrQuestionDeferred) || !deferred[key] {
+			break
+		}
+		if notifyErr := ih.interactor().Notify(ctx, "This question was already put off once, please answer it now."); notifyErr != nil {
+			return ToolResult{}, notifyErr
+		}
+		result, err = ih.answer(ctx, part)
+	}
+	if errors.Is(err, ErrQuestionDeferred) {
+		if deferred[key] {
+			return ToolResult{}, ErrQuestionSkipped
+		}
+		deferred[key] = true
+	}
+	return result, err
+}
+
+// askQuestion asks the question and holds the answer to the question's settings, for interactors that
+// don't check them themselves: an empty answer skips an optional question, and an answer selecting none
+// of the strict choices is reported with Notify and asked again, up to maxParseAttempts times. An answer
+// selecting a choice with a value is replaced by the value.
+func askQuestion(ctx context.Context, interactor Interactor, input QuestionInput) (Answer, error) {
+	var err error
+	for range maxParseAttempts {
+		var reply Answer
+		if reply, err = interactor.Ask(ctx, input); err != nil || reply.Skipped {
+			return reply, err
+		}
+		if strings.TrimSpace(reply.Value) == "" && !input.IsRequired() {
+			return Answer{Skipped: true, Meta: reply.Meta}, nil
+		}
+		if reply.Value, err = input.matchAnswer(reply.Value); err == nil {
+			reply.Value = input.choiceValue(reply.Value)
+			return reply, nil
+		}
+		if notifyErr := interactor.Notify(ctx, invalidAnswerMessage(err)); notifyErr != nil {
+			return Answer{}, notifyErr
+		}
+	}
+	return Answer{}, fmt.Errorf("no valid answer after %d attempts: %w", maxParseAttempts, err)
+}
+
+// result returns the answer already given to the interrupt with the index before the run was suspended.
+func (e *ErrRunSuspended) result(index int) (ToolResult, bool) {
+	if e == nil {
+		return ToolResult{}, false
+	}
+	if answer, ok := e.Answers[index]; ok {
+		return ToolResult{Output: answer}, true
+	}
+	result, ok := e.Results[index]
+	return result, ok
+}
+
+// pendingID returns the correlation ID of the interrupt with the index, if it was waiting for an answer.
+func (e *ErrRunSuspended) pendingID(index int) (string, bool) {
+	if e == nil {
+		return "", false
+	}
+	id, ok := e.Pending[index]
+	return id, ok
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/firebase/genkit/go/ai"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -13,6 +17,8 @@ func TestGetQuestionInput(t *testing.T) {
 		name        string
 		input       any
 		expected    QuestionInput
+		freeText    bool
+		required    bool
 		expectError bool
 	}{
 		{
@@ -25,6 +31,8 @@ func TestGetQuestionInput(t *testing.T) {
 				Question: "What gender?",
 				Choices:  []string{"Boy", "Girl", "Both"},
 			},
+			freeText:    true,
+			required:    true,
 			expectError: false,
 		},
 		{
@@ -37,8 +45,68 @@ func TestGetQuestionInput(t *testing.T) {
 				Question: "Pick one",
 				Choices:  []string{"A", "B"},
 			},
+			freeText:    true,
+			required:    true,
 			expectError: false,
 		},
+		{
+			name: "reason",
+			input: map[string]any{
+				"question": "What ages?",
+				"reason":   "Gifts differ by age",
+			},
+			expected: QuestionInput{
+				Question: "What ages?",
+				Reason:   "Gifts differ by age",
+			},
+			freeText:    true,
+			required:    true,
+			expectError: false,
+		},
+		{
+			name: "strict optional question",
+			input: map[string]any{
+				"question":      "Pick one",
+				"choices":       []any{"A", "B"},
+				"allowFreeText": false,
+				"required":      false,
+			},
+			expected: QuestionInput{
+				Question: "Pick one",
+				Choices:  []string{"A", "B"},
+			},
+			freeText: false,
+			required: false,
+		},
+		{
+			name: "mixed plain and object choices",
+			input: map[string]any{
+				"question": "What budget?",
+				"choices": []any{
+					map[string]any{"value": "budget_lt_25", "label": "Under $25"},
+					"Flexible",
+					map[string]any{"value": "budget_gte_25", "description": "for a special occasion"},
+				},
+				"default": "budget_lt_25",
+			},
+			expected: QuestionInput{
+				Question:           "What budget?",
+				Choices:            []string{"Under $25", "Flexible", "budget_gte_25"},
+				ChoiceValues:       []string{"budget_lt_25", "Flexible", "budget_gte_25"},
+				ChoiceDescriptions: []string{"", "", "for a special occasion"},
+				Default:            "Under $25",
+			},
+			freeText: true,
+			required: true,
+		},
+		{
+			name: "choice neither a string nor an object",
+			input: map[string]any{
+				"question": "What budget?",
+				"choices":  []any{"Flexible", 25},
+			},
+			expectError: true,
+		},
 		{
 			name:        "invalid input type",
 			input:       "not a map",
@@ -63,7 +131,331 @@ func TestGetQuestionInput(t *testing.T) {
 				require.NotNil(t, result)
 				assert.Equal(t, tt.expected.Question, result.Question)
 				assert.ElementsMatch(t, tt.expected.Choices, result.Choices)
+				assert.Equal(t, tt.expected.ChoiceValues, result.ChoiceValues)
+				assert.Equal(t, tt.expected.ChoiceDescriptions, result.ChoiceDescriptions)
+				assert.Equal(t, tt.expected.Default, result.Default)
+				assert.Equal(t, tt.expected.Reason, result.Reason)
+				assert.Equal(t, tt.freeText, result.FreeTextAllowed())
+				assert.Equal(t, tt.required, result.IsRequired())
+			}
+		})
+	}
+}
+
+func TestInterruptionHandler_SteeringErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		expectedErr error
+	}{
+		{name: "skip", err: ErrQuestionSkipped},
+		{name: "rephrase", err: ErrRephraseRequested},
+		{name: "back", err: ErrPreviousQuestion},
+		{name: "abort", err: ErrConversationAborted, expectedErr: ErrConversationAborted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGen := NewMockGenerator(
+				[]*ai.ModelResponse{
+					createTextResponse("Final Answer", "stop"),
+				},
+				map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+			)
+			handler := &InterruptionHandler{
+				generator: mockGen,
+				UserInteraction: func(ctx context.Context, input QuestionInput) (string, error) {
+					return "", tt.err
+				},
+			}
+
+			resp, err := handler.handleResponse(context.Background(), createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender?", []string{"Boy", "Girl"}),
+			))
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "Final Answer", resp.Text())
+			require.Len(t, mockGen.capturedCalls, 1)
+		})
+	}
+}
+
+func TestInterruptionHandler_InvalidInput(t *testing.T) {
+	corrected := createInterruptedResponse(createToolRequestPart("askQuestion", "What gender?", []string{"Boy", "Girl"}))
+	missingQuestion := createInterruptedResponse(createInterruptPart("askQuestion", map[string]any{"choices": []any{"Boy", "Girl"}}))
+
+	tests := []struct {
+		name      string
+		responses []*ai.ModelResponse
+		strict    bool
+		// reported starts the error the model gets in place of the first answer
+		reported    string
+		expectedErr string
+	}{
+		{
+			name:      "missing question",
+			responses: []*ai.ModelResponse{missingQuestion, corrected, createTextResponse("A LEGO set", "stop")},
+			reported:  "invalid input: question is required",
+		},
+		{
+			name: "choices as a string",
+			responses: []*ai.ModelResponse{
+				createInterruptedResponse(createInterruptPart("askQuestion", map[string]any{"question": "What gender?", "choices": "Boy, Girl"})),
+				corrected,
+				createTextResponse("A LEGO set", "stop"),
+			},
+			reported: "invalid input: failed to unmarshal input: json: cannot unmarshal string into Go struct field .choices",
+		},
+		{
+			name: "tool handler input",
+			responses: []*ai.ModelResponse{
+				createInterruptedResponse(createInterruptPart("rankItems", map[string]any{"question": "Rank these", "items": "Books, Games"})),
+				corrected,
+				createTextResponse("A LEGO set", "stop"),
+			},
+			reported: "invalid input: failed to unmarshal input: json: cannot unmarshal string into Go struct field RankInput.items",
+		},
+		{
+			name:        "strict",
+			responses:   []*ai.ModelResponse{missingQuestion, corrected, createTextResponse("A LEGO set", "stop")},
+			strict:      true,
+			expectedErr: "invalid input: question is required",
+		},
+		{
+			name:        "retries exhausted",
+			responses:   []*ai.ModelResponse{missingQuestion, missingQuestion, missingQuestion, missingQuestion, corrected},
+			expectedErr: "invalid input: question is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGen := NewMockGenerator(tt.responses, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "rankItems": createMockTool("rankItems")})
+			interactor := &sequenceInteractor{answers: []Answer{{Value: "Girl"}}}
+			var answered []AnsweredInterrupt
+			handler := &InterruptionHandler{
+				generator:   mockGen,
+				Interactor:  interactor,
+				StrictInput: tt.strict,
+				OnAnswer: func(_ context.Context, a AnsweredInterrupt) {
+					answered = append(answered, a)
+				},
+			}
+			handler.RegisterToolHandler("rankItems", HandleRank)
+
+			result, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+
+			if tt.expectedErr != "" {
+				var invalid *ErrInvalidInput
+				require.ErrorAs(t, err, &invalid)
+				assert.EqualError(t, invalid, tt.expectedErr)
+				assert.Zero(t, interactor.asked, "the user isn't asked about a malformed input")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "A LEGO set", result)
+			reported := capturedToolResponses(mockGen.capturedCalls[1].Options)
+			require.Len(t, reported, 1)
+			payload, ok := reported[0].ToolResponse.Output.(map[string]any)
+			require.True(t, ok, "unexpected output: %v", reported[0].ToolResponse.Output)
+			assert.True(t, strings.HasPrefix(payload["error"].(string), tt.reported), "unexpected error: %v", payload["error"])
+			answers := capturedToolResponses(mockGen.capturedCalls[2].Options)
+			require.Len(t, answers, 1)
+			assert.Equal(t, "Girl", answers[0].ToolResponse.Output, "the corrected question is asked")
+			assert.Equal(t, 1, interactor.asked)
+			require.Len(t, answered, 1, "only the answered question is passed to OnAnswer")
+		})
+	}
+}
+
+// questionInteractor is an Interactor replying to each question in turn, deferring it for "/later".
+type questionInteractor struct {
+	replies       map[string][]string
+	notifications []string
+}
+
+func (q *questionInteractor) Ask(_ context.Context, input QuestionInput) (Answer, error) {
+	replies := q.replies[input.Question]
+	if len(replies) == 0 {
+		return Answer{}, errors.New("unexpected question")
+	}
+	q.replies[input.Question] = replies[1:]
+	if replies[0] == "/later" {
+		return Answer{}, ErrQuestionDeferred
+	}
+	return Answer{Value: replies[0]}, nil
+}
+
+func (q *questionInteractor) Notify(_ context.Context, message string) error {
+	q.notifications = append(q.notifications, message)
+	return nil
+}
+
+func (q *questionInteractor) Close() error {
+	return nil
+}
+
+func TestInterruptionHandler_DeferredQuestion(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender?", nil),
+				createToolRequestPart("askQuestion", "What budget?", nil),
+				createToolRequestPart("askQuestion", "What ages?", nil),
+			),
+			// the model asks the deferred question again once the others are answered
+			createInterruptedResponse(createToolRequestPart("askQuestion", "What budget?", nil)),
+			createTextResponse("A LEGO set", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	interactor := &questionInteractor{replies: map[string][]string{"What gender?": {"Boy"}, "What budget?": {"/later", "/later", "$50"}, "What ages?": {"8"}}}
+	var answered []AnsweredInterrupt
+	handler := &InterruptionHandler{
+		generator:  mockGen,
+		Interactor: interactor,
+		OnAnswer: func(_ context.Context, a AnsweredInterrupt) {
+			answered = append(answered, a)
+		},
+	}
+
+	_, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	require.Len(t, mockGen.capturedCalls, 3)
+	first := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, first, 2, "the other questions are answered")
+	assert.Equal(t, "Boy", first[0].ToolResponse.Output)
+	assert.Equal(t, "8", first[1].ToolResponse.Output)
+	restarts := capturedToolRestarts(mockGen.capturedCalls[1].Options)
+	require.Len(t, restarts, 1, "the deferred question is restarted")
+	assert.Equal(t, "What budget?", restarts[0].ToolRequest.Input.(map[string]any)["question"])
+	assert.Equal(t, map[string]any{"resumed": map[string]any{"deferred": true}}, restarts[0].Metadata)
+
+	second := capturedToolResponses(mockGen.capturedCalls[2].Options)
+	require.Len(t, second, 1)
+	assert.Equal(t, "$50", second[0].ToolResponse.Output, "a question can't be deferred twice")
+	assert.Empty(t, capturedToolRestarts(mockGen.capturedCalls[2].Options))
+	assert.Equal(t, []string{"This question was already put off once, please answer it now."}, interactor.notifications)
+	require.Len(t, answered, 4)
+	assert.True(t, answered[1].Deferred)
+	assert.Equal(t, "$50", answered[3].Output)
+}
+
+func TestInterruptionHandler_DeferredToolHandler(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askBudget", map[string]any{"question": "What budget?"})),
+			createTextResponse("A LEGO set", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askBudget": createMockTool("askBudget")},
+	)
+	var answered []AnsweredInterrupt
+	handler := &InterruptionHandler{
+		generator: mockGen,
+		OnAnswer: func(_ context.Context, a AnsweredInterrupt) {
+			answered = append(answered, a)
+		},
+	}
+	handler.RegisterToolHandler("askBudget", func(context.Context, Interactor, map[string]any) (ToolResult, error) {
+		return ToolResult{}, ErrQuestionDeferred
+	})
+
+	_, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, deferredAnswer, parts[0].ToolResponse.Output, "the interrupt is answered with the deferral message")
+	require.Len(t, answered, 1, "the deferred interrupt is reported once")
+	assert.True(t, answered[0].Deferred)
+}
+
+func TestAskQuestion(t *testing.T) {
+	no := false
+	choices := []string{"Boy", "Girl"}
+	tests := []struct {
+		name          string
+		question      QuestionInput
+		answers       []Answer
+		expected      Answer
+		err           string
+		notifications []string
+	}{
+		{
+			name:     "free text",
+			question: QuestionInput{Question: "What gender?", Choices: choices},
+			answers:  []Answer{{Value: "Twins"}},
+			expected: Answer{Value: "Twins"},
+		},
+		{
+			name:          "strict choices",
+			question:      QuestionInput{Question: "What gender?", Choices: choices, AllowFreeText: &no},
+			answers:       []Answer{{Value: "Twins"}, {Value: "2"}},
+			expected:      Answer{Value: "Girl"},
+			notifications: []string{"Invalid answer: expected one of: Boy, Girl"},
+		},
+		{
+			name:     "strict choices never matched",
+			question: QuestionInput{Question: "What gender?", Choices: choices, AllowFreeText: &no},
+			answers:  []Answer{{Value: "Twins"}, {Value: "3"}, {Value: "both"}},
+			err:      "no valid answer after 3 attempts: expected one of: Boy, Girl",
+		},
+		{
+			name:     "label of a choice with a value",
+			question: QuestionInput{Question: "What budget?", Choices: []string{"Under $25", "Over $25"}, ChoiceValues: []string{"budget_lt_25", "budget_gte_25"}},
+			answers:  []Answer{{Value: "under $25"}},
+			expected: Answer{Value: "budget_lt_25"},
+		},
+		{
+			name:     "free text with choice values",
+			question: QuestionInput{Question: "What budget?", Choices: []string{"Under $25", "Over $25"}, ChoiceValues: []string{"budget_lt_25", "budget_gte_25"}},
+			answers:  []Answer{{Value: "About $40"}},
+			expected: Answer{Value: "About $40"},
+		},
+		{
+			name:     "strict choices by number and by value",
+			question: QuestionInput{Question: "What budget?", Choices: []string{"Under $25", "Over $25"}, ChoiceValues: []string{"budget_lt_25", "budget_gte_25"}, AllowFreeText: &no},
+			answers:  []Answer{{Value: "2"}},
+			expected: Answer{Value: "budget_gte_25"},
+		},
+		{
+			name:     "strict choices answered with a value",
+			question: QuestionInput{Question: "What budget?", Choices: []string{"Under $25", "Over $25"}, ChoiceValues: []string{"budget_lt_25", "budget_gte_25"}, AllowFreeText: &no},
+			answers:  []Answer{{Value: "budget_gte_25"}},
+			expected: Answer{Value: "budget_gte_25"},
+		},
+		{
+			name:     "empty answer to an optional question",
+			question: QuestionInput{Question: "Any allergies?", Required: &no},
+			answers:  []Answer{{Value: " "}},
+			expected: Answer{Skipped: true},
+		},
+		{
+			name:     "empty answer to a required question",
+			question: QuestionInput{Question: "Any allergies?"},
+			answers:  []Answer{{Value: ""}},
+			expected: Answer{Value: ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interactor := &sequenceInteractor{answers: tt.answers}
+
+			answer, err := askQuestion(context.Background(), interactor, tt.question)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
 			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, answer)
+			assert.Equal(t, tt.notifications, interactor.notifications)
 		})
 	}
 }
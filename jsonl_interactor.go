@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Types of the JSON-lines protocol events.
+const (
+	JSONLQuestion = "question"
+	JSONLAnswer   = "answer"
+	JSONLFinal    = "final"
+	JSONLError    = "error"
+)
+
+// maxJSONLLineSize limits a single line of protocol input.
+const maxJSONLLineSize = 1 << 20
+
+// JSONLEvent is a single line of the JSON-lines protocol. The process emits question, final and
+// error events and consumes answer events.
+type JSONLEvent struct {
+	Type     string   `json:"type"`
+	ID       string   `json:"id,omitempty"`
+	Question string   `json:"question,omitempty"`
+	Choices  []string `json:"choices,omitempty"`
+	// ChoiceDescriptions holds the descriptions of the choices, by index.
+	ChoiceDescriptions []string `json:"choiceDescriptions,omitempty"`
+	Reason             string   `json:"reason,omitempty"`
+	Default            string   `json:"default,omitempty"`
+	Answer             string   `json:"answer,omitempty"`
+	Text               string   `json:"text,omitempty"`
+	Error              string   `json:"error,omitempty"`
+}
+
+// JSONLEncoder writes protocol events, one JSON object per line.
+// It is safe for concurrent use.
+type JSONLEncoder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONLEncoder creates a JSONLEncoder writing to out.
+func NewJSONLEncoder(out io.Writer) *JSONLEncoder {
+	return &JSONLEncoder{enc: json.NewEncoder(out)}
+}
+
+// Encode writes the event as a single line.
+func (e *JSONLEncoder) Encode(event JSONLEvent) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(event)
+}
+
+// Question writes a question event.
+func (e *JSONLEncoder) Question(id string, input QuestionInput) error {
+	return e.Encode(JSONLEvent{
+		Type:               JSONLQuestion,
+		ID:                 id,
+		Question:           input.Question,
+		Choices:            input.Choices,
+		ChoiceDescriptions: input.ChoiceDescriptions,
+		Reason:             input.Reason,
+		Default:            input.Default,
+	})
+}
+
+// Final writes the final event carrying the agent's response.
+func (e *JSONLEncoder) Final(text string) error {
+	return e.Encode(JSONLEvent{Type: JSONLFinal, Text: text})
+}
+
+// Error writes an error event. The ID is empty for errors not related to a question.
+func (e *JSONLEncoder) Error(id string, err error) error {
+	return e.Encode(JSONLEvent{Type: JSONLError, ID: id, Error: err.Error()})
+}
+
+// JSONLInteractor asks questions over a JSON-lines protocol so that a host process can drive the agent
+// through stdin and stdout. Questions are written as question events and answered with answer events
+// carrying the same ID. Malformed input is reported with an error event and otherwise ignored.
+// Input is only read while a question is pending, so nothing is consumed ahead of the questions.
+type JSONLInteractor struct {
+	encoder *JSONLEncoder
+	scanner *bufio.Scanner
+	// demand wakes up the reader after a question is registered.
+	demand chan struct{}
+	// closed is closed once the input is exhausted, after which no answer can arrive.
+	closed chan struct{}
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[string]jsonlPending
+}
+
+// jsonlPending is a question waiting for its answer event.
+type jsonlPending struct {
+	input    QuestionInput
+	answerCh chan string
+}
+
+// NewJSONLInteractor creates a JSONLInteractor reading answers from in and writing events to out.
+func NewJSONLInteractor(in io.Reader, out io.Writer) *JSONLInteractor {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, maxJSONLLineSize)
+	j := &JSONLInteractor{
+		encoder: NewJSONLEncoder(out),
+		scanner: scanner,
+		demand:  make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+		pending: make(map[string]jsonlPending),
+	}
+	go j.read()
+	return j
+}
+
+// Encoder returns the encoder writing the interactor's events, for emitting the final event.
+func (j *JSONLInteractor) Encoder() *JSONLEncoder {
+	return j.encoder
+}
+
+// Interact writes a question event and blocks until the matching answer event arrives.
+// An empty answer selects the question's default. It fails with ErrConversationAborted once
+// the input is exhausted. It implements UserInteractionFunc.
+func (j *JSONLInteractor) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	j.mu.Lock()
+	j.nextID++
+	id := strconv.FormatUint(j.nextID, 10)
+	// buffered so that the reader never waits for Interact
+	answerCh := make(chan string, 1)
+	j.pending[id] = jsonlPending{input: input, answerCh: answerCh}
+	j.mu.Unlock()
+	defer func() {
+		j.mu.Lock()
+		delete(j.pending, id)
+		j.mu.Unlock()
+	}()
+
+	if err := j.encoder.Question(id, input); err != nil {
+		return "", fmt.Errorf("failed to write the question: %w", err)
+	}
+	select {
+	case j.demand <- struct{}{}:
+	default:
+	}
+
+	select {
+	case answer := <-answerCh:
+		return answer, nil
+	case <-j.closed:
+		return "", fmt.Errorf("%w: the input is closed", ErrConversationAborted)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// read reads answer events while questions are pending until the input is exhausted.
+func (j *JSONLInteractor) read() {
+	defer close(j.closed)
+	for range j.demand {
+		for j.hasPending() {
+			if !j.scanner.Scan() {
+				if err := j.scanner.Err(); err != nil {
+					_ = j.encoder.Error("", fmt.Errorf("failed to read the input: %w", err))
+				}
+				return
+			}
+			j.dispatch(j.scanner.Bytes())
+		}
+	}
+}
+
+func (j *JSONLInteractor) hasPending() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.pending) > 0
+}
+
+// dispatch delivers an answer event to its question, reporting malformed input with an error event.
+func (j *JSONLInteractor) dispatch(line []byte) {
+	if strings.TrimSpace(string(line)) == "" {
+		return
+	}
+	var event JSONLEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		_ = j.encoder.Error("", fmt.Errorf("invalid event: %w", err))
+		return
+	}
+	if event.Type != JSONLAnswer {
+		_ = j.encoder.Error(event.ID, fmt.Errorf("unexpected event type %q", event.Type))
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	pending, ok := j.pending[event.ID]
+	if !ok {
+		_ = j.encoder.Error(event.ID, fmt.Errorf("no pending question with id %q", event.ID))
+		return
+	}
+	answer := strings.TrimSpace(event.Answer)
+	if answer == "" {
+		answer = pending.input.Default
+	}
+	if answer == "" {
+		_ = j.encoder.Error(event.ID, errors.New("answer is empty"))
+		return
+	}
+	// the question is removed right away so that a second answer to it is rejected
+	delete(j.pending, event.ID)
+	pending.answerCh <- answer
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeJSONLEvents decodes every line written by a JSONLEncoder.
+func decodeJSONLEvents(t *testing.T, out *bytes.Buffer) []JSONLEvent {
+	t.Helper()
+	var events []JSONLEvent
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event JSONLEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &event), "line %q", line)
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestJSONLInteractor_RoundTrip(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createToolRequestPart("askQuestion", "What are their ages?", nil),
+			),
+			createTextResponse("Based on both genders and ages...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	in := bytes.NewBufferString(strings.Join([]string{
+		`not json`,
+		`{"type":"answer","id":"1","answer":"Both"}`,
+		`{"type":"start"}`,
+		`{"type":"answer","id":"1","answer":"Boy"}`,
+		`{"type":"answer","id":"2","answer":" "}`,
+		``,
+		`{"type":"answer","id":"2","answer":"8 and 11"}`,
+	}, "\n"))
+	var out bytes.Buffer
+	interactor := NewJSONLInteractor(in, &out)
+
+	result, err := RunAgent(context.Background(), &Options{
+		generator: mockGen,
+		responseHandler: &InterruptionHandler{
+			generator:       mockGen,
+			UserInteraction: interactor.Interact,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, interactor.Encoder().Final(result))
+
+	assert.Equal(t, []JSONLEvent{
+		{Type: JSONLQuestion, ID: "1", Question: "What gender are the children?", Choices: []string{"Boy", "Girl", "Both"}},
+		{Type: JSONLError, Error: "invalid event: invalid character 'o' in literal null (expecting 'u')"},
+		{Type: JSONLQuestion, ID: "2", Question: "What are their ages?"},
+		{Type: JSONLError, Error: `unexpected event type "start"`},
+		{Type: JSONLError, ID: "1", Error: `no pending question with id "1"`},
+		{Type: JSONLError, ID: "2", Error: "answer is empty"},
+		{Type: JSONLFinal, Text: "Based on both genders and ages..."},
+	}, decodeJSONLEvents(t, &out))
+	assert.Equal(t, 2, mockGen.callIndex)
+}
+
+func TestJSONLInteractor_Default(t *testing.T) {
+	in := bytes.NewBufferString(`{"type":"answer","id":"1","answer":""}` + "\n")
+	var out bytes.Buffer
+
+	answer, err := NewJSONLInteractor(in, &out).Interact(context.Background(), QuestionInput{Question: "What budget?", Default: "$50"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "$50", answer)
+	assert.Equal(t, []JSONLEvent{
+		{Type: JSONLQuestion, ID: "1", Question: "What budget?", Default: "$50"},
+	}, decodeJSONLEvents(t, &out))
+}
+
+func TestJSONLInteractor_InputClosed(t *testing.T) {
+	in := bytes.NewBufferString(`{"type":"answer","id":"7","answer":"Boy"}` + "\n")
+	var out bytes.Buffer
+
+	_, err := NewJSONLInteractor(in, &out).Interact(context.Background(), QuestionInput{Question: "What gender?"})
+
+	assert.ErrorIs(t, err, ErrConversationAborted)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineListTool.
+const (
+	defaultListToolName        = "askList"
+	defaultListToolDescription = "use this to ask the user for a list of free-text items, such as the interests of each child; the response is the list of items"
+)
+
+// ListInput contains a question answered with a list of items.
+type ListInput struct {
+	Question string `json:"question" jsonschema:"description=a question such as 'List the interests of each child'"`
+	MinItems int    `json:"minItems,omitempty" jsonschema:"description=the fewest items the user must list"`
+	MaxItems int    `json:"maxItems,omitempty" jsonschema:"description=the most items the user may list; 0 means no limit"`
+	ItemHint string `json:"itemHint,omitempty" jsonschema:"description=what each item is, shown to the user, such as 'an interest'"`
+}
+
+// DefineListTool defines the list tool, named "askList" by default, in the Genkit instance and returns
+// it. Its interrupts are answered by HandleList, which must be registered for the tool name with
+// RegisterToolHandler.
+func DefineListTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultListToolName, defaultListToolDescription, opts)
+
+	return DefineInterruptTool[ListInput, []string](g, config.name, config.description, WithInterruptMetadataKey("list"))
+}
+
+// HandleList is the ToolHandler of the list tool. The question is asked as a multi-line question whose
+// answer lists the items separated by commas or on separate lines; it is asked again until the number
+// of items is within the bounds. It responds with the trimmed items, each kept once, in the order they
+// were given.
+func HandleList(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var list ListInput
+	if err := decodeToolInput(input, &list); err != nil {
+		return ToolResult{}, err
+	}
+	if err := list.validate(); err != nil {
+		return ToolResult{}, err
+	}
+
+	question := QuestionInput{Question: list.Question + " " + list.hint(), MultiLine: true}
+	items, err := askParsed(ctx, interactor, question, list.parse)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	return ToolResult{Output: items}, nil
+}
+
+// validate reports inputs that no answer could satisfy.
+func (l ListInput) validate() error {
+	switch {
+	case l.Question == "":
+		return errors.New("list tool called without a question")
+	case l.MinItems < 0 || l.MaxItems < 0:
+		return errors.New("list tool called with negative item bounds")
+	case l.MaxItems > 0 && l.MinItems > l.MaxItems:
+		return fmt.Errorf("list tool called with minItems %d above maxItems %d", l.MinItems, l.MaxItems)
+	}
+	return nil
+}
+
+// hint tells the user how to separate the items and how many to list, such as
+// "(items separated by commas or on separate lines, each an interest, 1 to 3 items)".
+func (l ListInput) hint() string {
+	hint := "(items separated by commas or on separate lines"
+	if l.ItemHint != "" {
+		hint += ", each " + l.ItemHint
+	}
+	switch {
+	case l.MaxItems > 0 && l.MinItems == l.MaxItems:
+		hint += fmt.Sprintf(", %d items", l.MinItems)
+	case l.MaxItems > 0 && l.MinItems > 0:
+		hint += fmt.Sprintf(", %d to %d items", l.MinItems, l.MaxItems)
+	case l.MaxItems > 0:
+		hint += fmt.Sprintf(", at most %d items", l.MaxItems)
+	case l.MinItems > 0:
+		hint += fmt.Sprintf(", at least %d items", l.MinItems)
+	}
+	return hint + ")"
+}
+
+// parse splits the answer into items at commas and line breaks. Blank items are dropped and items
+// repeated with a different case are kept once.
+func (l ListInput) parse(answer string) (any, error) {
+	items := []string{}
+	for line := range strings.Lines(answer) {
+		for item := range strings.SplitSeq(line, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			if !slices.ContainsFunc(items, func(listed string) bool { return strings.EqualFold(listed, item) }) {
+				items = append(items, item)
+			}
+		}
+	}
+
+	switch {
+	case len(items) < l.MinItems:
+		return nil, fmt.Errorf("list at least %d different items", l.MinItems)
+	case l.MaxItems > 0 && len(items) > l.MaxItems:
+		return nil, fmt.Errorf("list at most %d items", l.MaxItems)
+	}
+	return items, nil
+}
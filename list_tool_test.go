@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListInput_Parse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    ListInput
+		answer   string
+		expected []string
+		err      string
+	}{
+		{name: "commas", answer: "Lego, drawing,football", expected: []string{"Lego", "drawing", "football"}},
+		{name: "lines", answer: "Lego\n  drawing  \n\nfootball\n", expected: []string{"Lego", "drawing", "football"}},
+		{name: "commas and lines", answer: "Lego, drawing\nfootball", expected: []string{"Lego", "drawing", "football"}},
+		{name: "trailing commas", answer: "Lego, drawing,,\nfootball,", expected: []string{"Lego", "drawing", "football"}},
+		{name: "duplicates ignoring case", answer: "Lego, lego\nLEGO, drawing", expected: []string{"Lego", "drawing"}},
+		{name: "nothing when allowed", answer: " , ", expected: []string{}},
+		{name: "too few after duplicates", input: ListInput{MinItems: 2}, answer: "Lego, lego", err: "list at least 2 different items"},
+		{name: "too many", input: ListInput{MaxItems: 2}, answer: "Lego, drawing, football", err: "list at most 2 items"},
+		{name: "within bounds", input: ListInput{MinItems: 1, MaxItems: 2}, answer: "Lego,", expected: []string{"Lego"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := tt.input.parse(tt.answer)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, items)
+		})
+	}
+}
+
+func TestListInput_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input ListInput
+		err   string
+	}{
+		{name: "valid", input: ListInput{Question: "Which?", MinItems: 1, MaxItems: 2}},
+		{name: "no question", input: ListInput{}, err: "without a question"},
+		{name: "negative bounds", input: ListInput{Question: "Which?", MinItems: -1}, err: "negative item bounds"},
+		{name: "min above max", input: ListInput{Question: "Which?", MinItems: 3, MaxItems: 2}, err: "minItems 3 above maxItems 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.input.validate()
+
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestListInput_Hint(t *testing.T) {
+	tests := []struct {
+		input    ListInput
+		expected string
+	}{
+		{input: ListInput{}, expected: "(items separated by commas or on separate lines)"},
+		{input: ListInput{ItemHint: "an interest", MinItems: 1, MaxItems: 3}, expected: "(items separated by commas or on separate lines, each an interest, 1 to 3 items)"},
+		{input: ListInput{MinItems: 2, MaxItems: 2}, expected: "(items separated by commas or on separate lines, 2 items)"},
+		{input: ListInput{MinItems: 2}, expected: "(items separated by commas or on separate lines, at least 2 items)"},
+		{input: ListInput{MaxItems: 4}, expected: "(items separated by commas or on separate lines, at most 4 items)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.input.hint())
+		})
+	}
+}
+
+func TestHandleList_ThroughToolHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askList", map[string]any{
+				"question": "List the interests of each child",
+				"minItems": 2,
+				"itemHint": "an interest",
+			})),
+			createTextResponse("A LEGO art set...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askList": createMockTool("askList")},
+	)
+	var out bytes.Buffer
+	// the first answer has a single item once duplicates are dropped, so it is asked again
+	terminal := NewTerminalReader(ctx, strings.NewReader("Lego, lego,\nEOF\nLego,\ndrawing\nEOF\n"), WithAnswerTimeout(time.Second), WithOutput(&out), WithWrapping(false))
+	handler := &InterruptionHandler{generator: mockGen, Interactor: terminal}
+	handler.RegisterToolHandler("askList", HandleList)
+
+	result, err := RunAgent(ctx, &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	assert.Equal(t, "A LEGO art set...", result)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, []string{"Lego", "drawing"}, parts[0].ToolResponse.Output, "the model gets an array, not a joined string")
+	assert.Contains(t, out.String(), "List the interests of each child (items separated by commas or on separate lines, each an interest, at least 2 items)\n")
+	assert.Contains(t, out.String(), "Invalid answer: list at least 2 different items\n")
+}
+
+func TestDefineListTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	list := DefineListTool(g)
+	_, err := list.RunRaw(context.Background(), map[string]any{"question": "Which?", "maxItems": 3})
+
+	assert.Equal(t, "askList", list.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, ListInput{Question: "Which?", MaxItems: 3}, metadata["list"])
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// maxLoggedPromptLength is how many characters of a prompt a LoggingGenerator logs.
+const maxLoggedPromptLength = 200
+
+// LoggingGenerator is a Generator logging the model calls of another one with slog.
+type LoggingGenerator struct {
+	inner  Generator
+	logger *slog.Logger
+	level  slog.Level
+	// now is replaced in tests to fix the durations.
+	now func() time.Time
+}
+
+// NewLoggingGenerator returns a Generator calling inner and logging every call at level: before the
+// call, with the number of messages and the prompt, cut to 200 characters; and after it, with its
+// duration, finish reason and token usage. Failed calls are logged at warn level with their error and
+// the chain of errors it wraps. The values marked with MarkSecret are redacted from the prompts.
+func NewLoggingGenerator(inner Generator, logger *slog.Logger, level slog.Level) *LoggingGenerator {
+	return &LoggingGenerator{inner: inner, logger: logger, level: level, now: time.Now}
+}
+
+// Generate calls the inner Generate and logs the call.
+func (l *LoggingGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	done := l.start(ctx, callGenerate, optionsPrompt(ctx, opts))
+	resp, err := l.inner.Generate(ctx, opts...)
+	done(err, responseAttrs(resp)...)
+	return resp, err
+}
+
+// GenerateStream calls the inner GenerateStream and logs the call.
+func (l *LoggingGenerator) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	done := l.start(ctx, callGenerateStream, optionsPrompt(ctx, opts))
+	resp, err := l.inner.GenerateStream(ctx, cb, opts...)
+	done(err, responseAttrs(resp)...)
+	return resp, err
+}
+
+// GenerateBool calls the inner GenerateBool and logs the call with the usage the inner generator
+// reports, as GenkitGenerator does.
+func (l *LoggingGenerator) GenerateBool(ctx context.Context, prompt string, history []*ai.Message) (bool, error) {
+	done := l.start(ctx, callGenerateBool, loggedPrompt{messages: len(history) + 1, text: prompt})
+	usage := &usageCounter{}
+	result, err := l.inner.GenerateBool(withUsageRecorder(ctx, usage.record), prompt, history)
+	done(err, append(usageAttrs(usage), slog.Bool("result", result))...)
+	return result, err
+}
+
+// GenerateChecklist calls the inner GenerateChecklist and logs the call with the questions and the usage
+// the inner generator reports.
+func (l *LoggingGenerator) GenerateChecklist(ctx context.Context, questions []string, history []*ai.Message) (map[string]bool, error) {
+	done := l.start(ctx, callGenerateChecklist, loggedPrompt{messages: len(history) + 1, text: strings.Join(questions, " ")})
+	usage := &usageCounter{}
+	answers, err := l.inner.GenerateChecklist(withUsageRecorder(ctx, usage.record), questions, history)
+	done(err, append(usageAttrs(usage), slog.Any("result", answers))...)
+	return answers, err
+}
+
+// GenerateJSON calls the inner GenerateJSON and logs the call with the usage the inner generator
+// reports.
+func (l *LoggingGenerator) GenerateJSON(ctx context.Context, system string, history []*ai.Message, out any) error {
+	prompt := loggedPrompt{messages: len(history)}
+	if len(history) > 0 {
+		prompt.text = history[len(history)-1].Text()
+	}
+	done := l.start(ctx, callGenerateJSON, prompt)
+	usage := &usageCounter{}
+	err := l.inner.GenerateJSON(withUsageRecorder(ctx, usage.record), system, history, out)
+	done(err, usageAttrs(usage)...)
+	return err
+}
+
+// LookupTool looks up the tool with the inner generator.
+func (l *LoggingGenerator) LookupTool(name string) ai.Tool {
+	return l.inner.LookupTool(name)
+}
+
+// CountTokens counts the tokens with the inner generator, without logging it as a model call.
+func (l *LoggingGenerator) CountTokens(ctx context.Context, messages []*ai.Message) (TokenCount, error) {
+	return l.inner.CountTokens(ctx, messages)
+}
+
+// Embed embeds the text with the inner generator, without logging it as a model call.
+func (l *LoggingGenerator) Embed(ctx context.Context, text string) ([]float32, error) {
+	return l.inner.Embed(ctx, text)
+}
+
+// Ping pings the inner generator, without logging it as a model call.
+func (l *LoggingGenerator) Ping(ctx context.Context) error {
+	return ping(ctx, l.inner)
+}
+
+// loggedPrompt describes the input of a call.
+type loggedPrompt struct {
+	messages int
+	text     string
+}
+
+// start logs the start of the call and returns the function logging its end with the attributes.
+func (l *LoggingGenerator) start(ctx context.Context, call string, prompt loggedPrompt) func(err error, attrs ...slog.Attr) {
+	l.logger.LogAttrs(ctx, l.level, "model call started",
+		slog.String("call", call),
+		slog.Int("messages", prompt.messages),
+		slog.String("prompt", truncateText(redactSecrets(ctx, prompt.text), maxLoggedPromptLength)),
+	)
+	started := l.now()
+	return func(err error, attrs ...slog.Attr) {
+		attrs = append([]slog.Attr{slog.String("call", call), slog.Duration("duration", l.now().Sub(started))}, attrs...)
+		if err != nil {
+			attrs = append(attrs, slog.String("error", redactSecrets(ctx, err.Error())), slog.Any("errorChain", errorChain(ctx, err)))
+			l.logger.LogAttrs(ctx, slog.LevelWarn, "model call failed", attrs...)
+			return
+		}
+		l.logger.LogAttrs(ctx, l.level, "model call finished", attrs...)
+	}
+}
+
+// optionsPrompt returns the number of messages set by the generate options, the prompt included, and
+// the text of the prompt, or of the last message without a prompt.
+func optionsPrompt(ctx context.Context, opts []ai.GenerateOption) loggedPrompt {
+	var prompt loggedPrompt
+	for _, opt := range opts {
+		set := map[string]any{}
+		if err := optionFields(ctx, reflect.ValueOf(opt), set); err != nil {
+			continue
+		}
+		if messages, ok := set["MessagesFn"].([]*ai.Message); ok && len(messages) > 0 {
+			prompt.messages += len(messages)
+			prompt.text = messages[len(messages)-1].Text()
+		}
+		if text, ok := set["PromptFn"].(string); ok {
+			prompt.messages++
+			prompt.text = text
+		}
+	}
+	return prompt
+}
+
+// responseAttrs returns the finish reason and the token usage of the response.
+func responseAttrs(resp *ai.ModelResponse) []slog.Attr {
+	if resp == nil {
+		return nil
+	}
+	usage := &usageCounter{}
+	usage.add(resp.Usage)
+	return append([]slog.Attr{slog.String("finishReason", string(resp.FinishReason))}, usageAttrs(usage)...)
+}
+
+// usageAttrs returns the token usage counted.
+func usageAttrs(usage *usageCounter) []slog.Attr {
+	snapshot := usage.snapshot()
+	return []slog.Attr{
+		slog.Int64("inputTokens", snapshot.InputTokens),
+		slog.Int64("outputTokens", snapshot.OutputTokens),
+		slog.Int64("totalTokens", snapshot.TotalTokens),
+	}
+}
+
+// errorChain returns the messages of the error and of the errors it wraps, in order.
+func errorChain(ctx context.Context, err error) []string {
+	var chain []string
+	queue := []error{err}
+	for len(queue) > 0 {
+		err, queue = queue[0], queue[1:]
+		chain = append(chain, redactSecrets(ctx, err.Error()))
+		switch wrapper := err.(type) {
+		case interface{ Unwrap() error }:
+			if inner := wrapper.Unwrap(); inner != nil {
+				queue = append(queue, inner)
+			}
+		case interface{ Unwrap() []error }:
+			queue = append(queue, wrapper.Unwrap()...)
+		}
+	}
+	return chain
+}
+
+// truncateText cuts the text to limit characters, marking the cut with an ellipsis.
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturedRecord is a log record captured by captureHandler.
+type capturedRecord struct {
+	level   slog.Level
+	message string
+	attrs   map[string]any
+}
+
+// captureHandler is a slog.Handler keeping the records.
+type captureHandler struct {
+	mu      sync.Mutex
+	records []capturedRecord
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *captureHandler) Handle(_ context.Context, record slog.Record) error {
+	captured := capturedRecord{level: record.Level, message: record.Message, attrs: map[string]any{}}
+	record.Attrs(func(attr slog.Attr) bool {
+		captured.attrs[attr.Key] = attr.Value.Any()
+		return true
+	})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, captured)
+	return nil
+}
+
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *captureHandler) WithGroup(string) slog.Handler { return h }
+
+// newTestLoggingGenerator returns a LoggingGenerator logging to a captureHandler at debug level, whose
+// calls take a second.
+func newTestLoggingGenerator(inner Generator) (*LoggingGenerator, *captureHandler) {
+	handler := &captureHandler{}
+	generator := NewLoggingGenerator(inner, slog.New(handler), slog.LevelDebug)
+	clock := time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC)
+	generator.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	return generator, handler
+}
+
+func TestLoggingGenerator_Generate(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{withUsage(createTextResponse("A LEGO set", "stop"), 100, 20, 120)}, nil)
+	generator, handler := newTestLoggingGenerator(mockGen)
+
+	_, err := generator.Generate(context.Background(),
+		ai.WithMessages(ai.NewUserTextMessage("Suggest a gift."), ai.NewModelTextMessage("Who is it for?")),
+		ai.WithPrompt("A boy who likes "+strings.Repeat("building ", 30)),
+	)
+
+	require.NoError(t, err)
+	require.Len(t, handler.records, 2)
+	started, finished := handler.records[0], handler.records[1]
+	assert.Equal(t, slog.LevelDebug, started.level)
+	assert.Equal(t, "model call started", started.message)
+	assert.Equal(t, "generate", started.attrs["call"])
+	assert.Equal(t, int64(3), started.attrs["messages"])
+	prompt := started.attrs["prompt"].(string)
+	assert.Equal(t, maxLoggedPromptLength+1, len([]rune(prompt)), "the prompt is cut")
+	assert.True(t, strings.HasSuffix(prompt, "…"))
+
+	assert.Equal(t, "model call finished", finished.message)
+	assert.Equal(t, time.Second, finished.attrs["duration"])
+	assert.Equal(t, "stop", finished.attrs["finishReason"])
+	assert.Equal(t, int64(100), finished.attrs["inputTokens"])
+	assert.Equal(t, int64(20), finished.attrs["outputTokens"])
+	assert.Equal(t, int64(120), finished.attrs["totalTokens"])
+}
+
+func TestLoggingGenerator_GenerateBool(t *testing.T) {
+	generator, handler := newTestLoggingGenerator(constantGenerator(trueAnswer, 50, 1))
+	history := []*ai.Message{ai.NewUserTextMessage("Suggest a gift."), ai.NewModelTextMessage("A kite.")}
+
+	finished, err := generator.GenerateBool(context.Background(), "Is the conversation finished?", history)
+
+	require.NoError(t, err)
+	assert.True(t, finished)
+	require.Len(t, handler.records, 2)
+	assert.Equal(t, "generateBool", handler.records[0].attrs["call"])
+	assert.Equal(t, int64(3), handler.records[0].attrs["messages"])
+	assert.Equal(t, "Is the conversation finished?", handler.records[0].attrs["prompt"])
+	assert.Equal(t, true, handler.records[1].attrs["result"])
+	assert.Equal(t, int64(51), handler.records[1].attrs["totalTokens"], "the usage reported by GenkitGenerator is logged")
+}
+
+func TestLoggingGenerator_UsageStillTracked(t *testing.T) {
+	logging, _ := newTestLoggingGenerator(constantGenerator(trueAnswer, 50, 1))
+	tracker := NewUsageTrackingGenerator(logging)
+
+	_, err := tracker.GenerateBool(context.Background(), "Is the conversation finished?", nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(51), tracker.Snapshot().TotalTokens)
+}
+
+func TestLoggingGenerator_Error(t *testing.T) {
+	cause := errors.New("connection reset")
+	inner := &flakyGenerator{errs: []error{fmt.Errorf("generate: %w", cause)}}
+	generator, handler := newTestLoggingGenerator(inner)
+
+	_, err := generator.Generate(context.Background(), ai.WithPrompt("Suggest a gift."))
+
+	require.Error(t, err)
+	require.Len(t, handler.records, 2)
+	failed := handler.records[1]
+	assert.Equal(t, slog.LevelWarn, failed.level)
+	assert.Equal(t, "model call failed", failed.message)
+	assert.Equal(t, "generate: connection reset", failed.attrs["error"])
+	assert.Equal(t, []string{"generate: connection reset", "connection reset"}, failed.attrs["errorChain"])
+}
+
+func TestLoggingGenerator_RedactsSecrets(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("Card saved", "stop")}, nil)
+	generator, handler := newTestLoggingGenerator(mockGen)
+	ctx := withSecretValues(context.Background())
+	MarkSecret(ctx, "4111-2222")
+
+	_, err := generator.Generate(ctx, ai.WithPrompt("My card is 4111-2222."))
+
+	require.NoError(t, err)
+	assert.Equal(t, "My card is [redacted].", handler.records[0].attrs["prompt"])
+}
@@ -2,37 +2,99 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
-	"github.com/firebase/genkit/go/plugins/googlegenai"
 )
 
+// modelPrices are the prices of the Gemini models in dollars per 1000 tokens, as published by Google
+// for text input when written.
+var modelPrices = map[string]ModelPrice{
+	"googleai/gemini-2.5-flash":      {InputPricePer1K: 0.0003, OutputPricePer1K: 0.0025},
+	"googleai/gemini-2.5-flash-lite": {InputPricePer1K: 0.0001, OutputPricePer1K: 0.0004},
+}
+
 // main is the entry point of the application.
 // It initializes the Genkit client, defines tools, and runs the agent loop.
 func main() {
+	webAddr := flag.String("web", "", "serve a page for answering the questions at the address, such as :8080, instead of asking in the terminal")
+	protocol := flag.String("protocol", "terminal", "how questions are asked on stdin and stdout: terminal, or jsonl for driving the agent as a subprocess")
+	useDialog := flag.Bool("dialog", false, "ask in native desktop dialogs, falling back to the terminal when no dialog tool is installed")
+	useTUI := flag.Bool("tui", false, "ask in a full-screen terminal UI; requires a build with the tui tag")
+	scriptPath := flag.String("script", "", "answer the questions from a YAML or JSON script of question patterns and answers")
+	persona := flag.String("persona", "", "let the model answer the questions as the described user, for soak tests without a human")
+	serveAddr := flag.String("serve", "", "serve runs over HTTP at the address, such as :8080, instead of running once")
+	locale := flag.String("locale", "", "the language code of the user, such as de; questions are translated to it and the answers back to English")
+	provider := flag.String("provider", ProviderGoogleAI, "the backend of the model: googleai, vertexai, or openai for OpenAI and compatible APIs")
+	modelName := flag.String("model", "", "the model the agent calls, prefixed with its provider; gemini-2.5-flash for the Gemini providers when empty")
+	baseURL := flag.String("base-url", "", "the address of an OpenAI-compatible API other than OpenAI's, with --provider=openai")
+	safety := flag.String("safety", "", "the thresholds of the Gemini safety filters, such as HARM_CATEGORY_HARASSMENT=BLOCK_ONLY_HIGH, separated by commas")
+	fallbackModel := flag.String("fallback-model", "", "the model called when the model keeps failing, such as googleai/gemini-2.5-flash-lite")
+	cacheDir := flag.String("cache", "", "cache the model responses in the directory for a day, so that re-running the same conversation doesn't call the model again")
+	logCalls := flag.Bool("log-calls", false, "log every model call with its duration and token usage")
+	recordPath := flag.String("record", "", "record the model calls of the run to the JSONL file, to replay them with --replay")
+	replayPath := flag.String("replay", "", "serve the model calls from a recording made with --record instead of calling the model")
+	deterministic := flag.Bool("deterministic", false, "pin the sampling of the model, with temperature 0 and a fixed seed, for runs as reproducible as the provider allows")
+	embedder := flag.String("embedder", "", "the embedder of the provider, such as googleai/gemini-embedding-001, with which --dedup compares questions by meaning")
+	dedup := flag.Bool("dedup", false, "answer a question the user already answered in the run, or one asking the same, without asking again")
+	dedupThreshold := flag.Float64("dedup-threshold", defaultSimilarityThreshold, "how similar, from -1 to 1, the embeddings of two questions must be for --dedup to take them as the same")
+	historyBudget := flag.Int("history-budget", 0, "how many tokens the history of a follow-up question may take, dropping its oldest messages but the request; 0 keeps it all")
+	maxRuns := flag.Int("max-runs", defaultMaxConcurrentRuns, "how many runs --serve runs at once")
+	flag.Parse()
+	if *protocol != "terminal" && *protocol != "jsonl" {
+		log.Fatalf("unknown protocol %q", *protocol)
+	}
+	if *protocol == "jsonl" && *webAddr != "" {
+		log.Fatal("--web can't be combined with --protocol=jsonl")
+	}
+	if *serveAddr != "" && (*webAddr != "" || *protocol == "jsonl" || *useTUI) {
+		log.Fatal("--serve can't be combined with --web, --protocol=jsonl or --tui")
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	apIKey := os.Getenv("API_KEY")
-	if apIKey == "" {
-		log.Fatal("API key is required")
+	safetySettings, err := ParseSafetySettings(*safety)
+	if err != nil {
+		log.Fatal(err.Error())
 	}
-
-	g := genkit.Init(ctx, genkit.WithPlugins(&googlegenai.GoogleAI{
-		APIKey: apIKey,
-	}),
-		genkit.WithDefaultModel("googleai/gemini-2.5-flash"),
-	)
-
-	if g == nil {
-		log.Fatal("can't init genkit")
+	base, err := newGenkitGenerator(ctx, ProviderConfig{
+		Provider:       *provider,
+		Model:          *modelName,
+		APIKey:         os.Getenv("API_KEY"),
+		BaseURL:        *baseURL,
+		Embedder:       *embedder,
+		SafetySettings: safetySettings,
+	})
+	if err != nil {
+		log.Fatal(err.Error())
 	}
+	g := base.AIClient
 
-	DefineAskQuestionTool(g)
+	askQuestion := DefineAskQuestionTool(g)
+	confirm := DefineConfirmTool(g)
+	multiSelect := DefineMultiSelectTool(g)
+	validatedQuestion := DefineValidatedQuestionTool(g)
+	form := DefineFormTool(g)
+	scale := DefineScaleTool(g)
+	date := DefineDateTool(g)
+	number := DefineNumberTool(g)
+	requestFile := DefineRequestFileTool(g)
+	reviewDraft := DefineReviewDraftTool(g)
+	secret := DefineSecretTool(g)
+	list := DefineListTool(g)
+	rank := DefineRankTool(g)
+	provideText := DefineProvideTextTool(g)
+	address := DefineAddressTool(g)
+	budget := DefineBudgetTool(g)
+	consent := DefineConsentTool(g)
 
 	// var systemPrompt SystemPrompt = "Ask clarifying questions until you have a complete solution. Provide a question with options."
 	var systemPrompt SystemPrompt = `You are a helpful assistant that asks clarifying questions to gather information.
@@ -40,41 +102,229 @@ func main() {
 	CRITICAL INSTRUCTIONS:
 	1. You MUST use the askQuestion tool for EVERY question - never ask questions directly in your response
 	2. Continue asking questions until you have ALL necessary information
-	3. If the user's answer is unclear or not in the provided options, ask a follow-up question using the askQuestion tool
-	4. If the user provides an unexpected answer, acknowledge it and ask another clarifying question
-	5. Only provide your final response when you have complete information about:
+	   and fill in the reason of every question with one short sentence telling the user why the answer matters,
+	   for example: question "How old are the children?", reason "Gifts for toddlers differ a lot from gifts for teenagers"
+	3. For questions that can only be answered with yes or no, use the confirm tool instead, and when several
+	   of the choices can apply at once, use the multiSelect tool; when the answer must have a format, such as
+	   an email address, use the askValidatedQuestion tool with a pattern; to ask several short questions at
+	   once, use the askForm tool; for ratings such as how important something is, use the askScale tool, and
+	   for dates, use the askDate tool; for the budget, use the askBudget tool, for other numbers the askNumber
+	   tool, and when the user should share a file, such as a photo of a wishlist, use the requestFile tool;
+	   ask for credentials or personal identifiers, such as a loyalty card number, only with the askSecret
+	   tool; for several free-text items, such as the interests of each child, use the askList tool, and to
+	   learn the order of preference of several options, use the rankItems tool; when the user has a longer
+	   text you should read, such as a previous wishlist or an email, ask them to paste it with the
+	   provideText tool; ask for a shipping address only with the askAddress tool, and before sharing the
+	   address or other personal details with a shop, get the user's consent with the askConsent tool
+	4. If the user's answer is unclear or not in the provided options, ask a follow-up question using the askQuestion tool
+	5. If the user provides an unexpected answer, acknowledge it and ask another clarifying question
+	6. Only provide your final response when you have complete information about:
 	   - The recipients (age, gender, interests)
 	   - Budget constraints
 	   - Any special preferences or restrictions
+	7. Before giving your final response, show it with the reviewDraft tool; if the user asks for changes,
+	   revise it and review it again, and give the final response only once it is approved
 
 	Remember: ALWAYS use the askQuestion tool to interact with the user. Never stop until you have gathered all necessary details.`
 
-	var userPrompt UserPrompt = "Please help with Christmas presents for children 8 and 11 years old children"
-	toolNames := []string{"askQuestion"}
-	generator := GenkitGenerator{AIClient: g}
-	terminalReader := NewTerminalReader(ctx, os.Stdin)
+	var cache Cache
+	if *cacheDir != "" {
+		diskCache, err := NewDiskCache(*cacheDir, 24*time.Hour)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		cache = diskCache
+	}
+
+	// the flow lets the same agent be run and traced from the Genkit developer UI
+	DefineClarifyingAgentFlow(g,
+		WithFlowSystemPrompt(systemPrompt),
+		WithFlowGenerator(NewCostTracker(newModelGenerator(base, *fallbackModel, cache, *logCalls), modelPrices)),
+	)
+
+	// the runs of the CLI and of --serve offer all the tools the system prompt tells about
+	toolNames := []string{askQuestion.Name(), confirm.Name(), multiSelect.Name(), validatedQuestion.Name(), form.Name(), scale.Name(), date.Name(), number.Name(), requestFile.Name(), reviewDraft.Name(), secret.Name(), list.Name(), rank.Name(), provideText.Name(), address.Name(), budget.Name(), consent.Name()}
+	toolHandlers := func(generator Generator) map[string]ToolHandler {
+		return map[string]ToolHandler{
+			confirm.Name():           HandleConfirm,
+			multiSelect.Name():       HandleMultiSelect,
+			validatedQuestion.Name(): NewValidatedQuestionHandler(),
+			form.Name():              HandleForm,
+			scale.Name():             HandleScale,
+			date.Name():              NewDateHandler(),
+			number.Name():            HandleNumber,
+			requestFile.Name():       NewRequestFileHandler(),
+			reviewDraft.Name():       NewReviewDraftHandler(),
+			secret.Name():            HandleSecret,
+			list.Name():              HandleList,
+			rank.Name():              HandleRank,
+			provideText.Name():       NewProvideTextHandler(WithTextSummary(generator, 4000)),
+			address.Name():           NewAddressHandler(),
+			budget.Name():            HandleBudget,
+			consent.Name():           NewConsentHandler(),
+		}
+	}
+
+	if *serveAddr != "" {
+		generator := newModelGenerator(base, *fallbackModel, cache, *logCalls)
+		serverOptions := []RunServerOption{
+			WithRunSystemPrompt(systemPrompt),
+			WithMaxConcurrentRuns(*maxRuns),
+		}
+		for name, handler := range toolHandlers(generator) {
+			serverOptions = append(serverOptions, WithRunToolHandler(name, handler))
+		}
+		runServer := NewRunServer(generator, toolNames, serverOptions...)
+		log.Printf("serving runs at http://%s/runs", *serveAddr)
+		if err := runServer.ListenAndServe(ctx, *serveAddr); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
 
-	interruptionHandler := InterruptionHandler{
-		generator:       &generator,
-		UserInteraction: terminalReader.Interactor,
+	var userPrompt UserPrompt = "Please help with Christmas presents for children 8 and 11 years old children"
+	model := newModelGenerator(base, *fallbackModel, cache, *logCalls)
+	if *replayPath != "" {
+		recording, err := os.Open(*replayPath)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		replay, err := NewReplayGenerator(recording, WithReplayTools(func(name string) ai.Tool { return genkit.LookupTool(g, name) }))
+		_ = recording.Close()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		model = replay
+	}
+	if *recordPath != "" {
+		recording, err := os.Create(*recordPath)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		defer recording.Close()
+		model = NewRecordingGenerator(model, recording)
+	}
+	generator := NewCostTracker(NewUsageTrackingGenerator(model), modelPrices)
+	var (
+		interactor Interactor
+		webUI      *WebUI
+		jsonl      *JSONLInteractor
+	)
+	switch {
+	case *scriptPath != "":
+		scripted, err := LoadScriptedInteractor(*scriptPath, WithUnmatchedQuestionsFile(*scriptPath+".unmatched"))
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		interactor = InteractorFunc(scripted.Interact)
+	case *persona != "":
+		interactor = InteractorFunc(NewSimulatedUserInteractor(generator, *persona).Interact)
+	case *protocol == "jsonl":
+		jsonl = NewJSONLInteractor(os.Stdin, os.Stdout)
+		interactor = InteractorFunc(jsonl.Interact)
+	case *webAddr != "":
+		webUI = NewWebUI()
+		go func() {
+			if err := webUI.ListenAndServe(ctx, *webAddr); err != nil {
+				log.Fatal(err.Error())
+			}
+		}()
+		log.Printf("answer the questions at http://%s", *webAddr)
+		interactor = InteractorFunc(webUI.Interact)
+	default:
+		terminalOptions := []TerminalOption{
+			WithChoiceSelector(),
+			WithWaitingStatus(15 * time.Second),
+		}
+		if *useDialog {
+			interactor = NewDialogOrTerminal(ctx, nil, terminalOptions...)
+			break
+		}
+		if !*useTUI {
+			interactor = NewTerminalReader(ctx, os.Stdin, terminalOptions...)
+			break
+		}
+		tui, ok := newTUI(ctx, terminalOptions...)
+		if !ok {
+			log.Fatal("--tui is not available in this build; rebuild with -tags tui")
+		}
+		interactor = tui
 	}
 
-	conversationLoopHandler := &ConversationLoopHandler{
-		generator:           &generator,
-		validationPrompt:    "Analyze if a conversation can be assumed as finished. If the model is asking a question or requesting more information, the conversation is NOT finished. Only return true if the model has provided a final answer or solution.",
-		interruptionHandler: interruptionHandler,
+	conversationLoopHandler := NewInteractorConversationLoopHandler(
+		generator,
+		"Analyze if a conversation can be assumed as finished. If the model is asking a question or requesting more information, the conversation is NOT finished. Only return true if the model has provided a final answer or solution.",
+		interactor,
+		toolNames...,
+	)
+	for name, handler := range toolHandlers(generator) {
+		conversationLoopHandler.RegisterToolHandler(name, handler)
+	}
+	conversationLoopHandler.SetHistoryBudget(*historyBudget)
+	if *dedup {
+		conversationLoopHandler.SetQuestionCache(NewSemanticQuestionCache(generator, WithSimilarityThreshold(*dedupThreshold)))
+	}
+	if *locale != "" {
+		conversationLoopHandler.SetTranslation(*locale, NewGeneratorTranslator(generator))
 	}
 
-	finalResponse, err := RunAgent(ctx, &Options{
-		generator:       &generator,
+	var usage TokenUsage
+	runCtx, costs := WithCostScope(ctx)
+	finalResponse, err := RunAgent(runCtx, &Options{
+		generator:       generator,
 		systemPrompt:    systemPrompt,
 		userPrompt:      userPrompt,
 		toolNames:       toolNames,
 		responseHandler: conversationLoopHandler,
+		deterministic:   *deterministic,
+		usage:           func(run TokenUsage) { usage = run },
 	})
+	if webUI != nil {
+		webUI.Finish(finalResponse, err)
+	}
+	if jsonl != nil {
+		if err != nil {
+			_ = jsonl.Encoder().Error("", err)
+			os.Exit(1)
+		}
+		_ = jsonl.Encoder().Final(finalResponse)
+		return
+	}
+	// close the interactor before logging so that the terminal is restored from raw mode
+	_ = interactor.Close()
+	if webUI == nil {
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		log.Println(finalResponse)
+		cost, _ := costs.Snapshot()
+		log.Printf("%d model calls used %d tokens (%d input, %d output) costing $%.4f", usage.Calls, usage.TotalTokens, usage.InputTokens, usage.OutputTokens, cost.Dollars)
+		return
+	}
+
+	// keep serving so that the page can show how the run ended
 	if err != nil {
-		log.Fatal(err.Error())
+		log.Println(err.Error())
+	} else {
+		log.Println(finalResponse)
 	}
+	log.Println("press Ctrl+C to stop serving the page")
+	<-ctx.Done()
+}
 
-	log.Println(finalResponse)
+// newModelGenerator returns the generator of the runs: rate limits and server errors of the model are
+// retried instead of ending the run, calls failing anyway go to the fallback model of the same provider
+// when one is set, and the responses are cached when cache isn't nil. With logCalls, every call is logged.
+func newModelGenerator(base *GenkitGenerator, fallbackModel string, cache Cache, logCalls bool) Generator {
+	var generator Generator = NewRetryingGenerator(base, DefaultRetryPolicy())
+	if fallbackModel != "" {
+		generator = NewFallbackGenerator(generator, base.withModel(fallbackModel), nil)
+	}
+	if cache != nil {
+		generator = NewCachingGenerator(generator, cache)
+	}
+	if logCalls {
+		generator = NewLoggingGenerator(generator, slog.Default(), slog.LevelInfo)
+	}
+	return generator
 }
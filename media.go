@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Media is an image attached to a question, given as an http(s) URL or as a data URI such as
+// "data:image/png;base64,iVBOR...". Alt describes the image for interactors that can't show it.
+type Media struct {
+	URL string `json:"url" jsonschema:"description=an http(s) URL or a data URI of an image the question refers to"`
+	Alt string `json:"alt,omitempty" jsonschema:"description=a short description of the image, such as 'a red city bike'"`
+}
+
+// mediaExtensions are the file extensions of media types whose first extension known to the mime
+// package is an unusual one.
+var mediaExtensions = map[string]string{"image/jpeg": ".jpg"}
+
+// validate reports media that are neither an http(s) URL nor a data URI.
+func (m Media) validate() error {
+	if m.IsDataURI() {
+		_, _, err := m.decodeData()
+		return err
+	}
+	u, err := url.Parse(m.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is neither an http(s) URL nor a data URI", m.URL)
+	}
+	return nil
+}
+
+// IsDataURI reports whether the media are embedded in the URL rather than linked.
+func (m Media) IsDataURI() bool {
+	return strings.HasPrefix(strings.ToLower(m.URL), "data:")
+}
+
+// decodeData returns the media type and the content of a data URI. The media type defaults to
+// text/plain, as in RFC 2397.
+func (m Media) decodeData() (string, []byte, error) {
+	header, payload, ok := strings.Cut(m.URL[len("data:"):], ",")
+	if !ok {
+		return "", nil, errors.New("the data URI has no comma before its data")
+	}
+	mediaType, isBase64 := strings.CutSuffix(header, ";base64")
+	if mediaType == "" {
+		mediaType = "text/plain"
+	}
+	if isBase64 {
+		data, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return "", nil, fmt.Errorf("the data URI isn't valid base64: %w", err)
+		}
+		return mediaType, data, nil
+	}
+	data, err := url.PathUnescape(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("the data URI isn't valid: %w", err)
+	}
+	return mediaType, []byte(data), nil
+}
+
+// saveTemp writes the content of a data URI to a new file in the temporary directory, with the
+// extension of its media type, and returns the path of the file.
+func (m Media) saveTemp() (string, error) {
+	mediaType, data, err := m.decodeData()
+	if err != nil {
+		return "", err
+	}
+	extension := ""
+	if base, _, err := mime.ParseMediaType(mediaType); err == nil {
+		extension = mediaExtensions[base]
+		if extensions, _ := mime.ExtensionsByType(base); extension == "" && len(extensions) > 0 {
+			extension = extensions[0]
+		}
+	}
+	file, err := os.CreateTemp("", "question-media-*"+extension)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
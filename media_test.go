@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMedia_Validate(t *testing.T) {
+	tests := []struct {
+		url string
+		err string
+	}{
+		{url: "https://example.com/bike.png"},
+		{url: "http://example.com/bike.png"},
+		{url: "data:image/png;base64,iVBORw0KGgo="},
+		{url: "data:,a%20note"},
+		{url: "ftp://example.com/bike.png", err: `"ftp://example.com/bike.png" is neither an http(s) URL nor a data URI`},
+		{url: "bike.png", err: `"bike.png" is neither an http(s) URL nor a data URI`},
+		{url: "data:image/png;base64", err: "the data URI has no comma before its data"},
+		{url: "data:image/png;base64,not base64!", err: "the data URI isn't valid base64: illegal base64 data at input byte 3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := Media{URL: tt.url}.validate()
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMedia_DecodeData(t *testing.T) {
+	tests := []struct {
+		url       string
+		mediaType string
+		data      string
+	}{
+		{url: "data:image/png;base64,aGVsbG8=", mediaType: "image/png", data: "hello"},
+		{url: "DATA:text/plain;charset=utf-8,two%20words", mediaType: "text/plain;charset=utf-8", data: "two words"},
+		{url: "data:,plain", mediaType: "text/plain", data: "plain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			mediaType, data, err := Media{URL: tt.url}.decodeData()
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.mediaType, mediaType)
+			assert.Equal(t, tt.data, string(data))
+		})
+	}
+}
+
+func TestMedia_SaveTemp(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+
+	path, err := Media{URL: "data:image/jpeg;base64,aGVsbG8="}.saveTemp()
+
+	require.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(path))
+	assert.Equal(t, ".jpg", filepath.Ext(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
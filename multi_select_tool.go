@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineMultiSelectTool.
+const (
+	defaultMultiSelectToolName        = "multiSelect"
+	defaultMultiSelectToolDescription = "use this to ask the user to pick several of the choices at once; the response is the list of selected choices"
+)
+
+// MultiSelectInput contains a question whose answer is any number of the choices.
+type MultiSelectInput struct {
+	Question      string   `json:"question" jsonschema:"description=a question such as 'Which interests apply?'"`
+	Choices       []string `json:"choices" jsonschema:"description=the choices the user picks from"`
+	MinSelections int      `json:"minSelections,omitempty" jsonschema:"description=the fewest choices the user must pick"`
+	MaxSelections int      `json:"maxSelections,omitempty" jsonschema:"description=the most choices the user may pick; 0 means no limit"`
+}
+
+// DefineMultiSelectTool defines the multi-select tool, named "multiSelect" by default, in the Genkit
+// instance and returns it. Its interrupts are answered by HandleMultiSelect, which must be registered
+// for the tool name with RegisterToolHandler.
+func DefineMultiSelectTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultMultiSelectToolName, defaultMultiSelectToolDescription, opts)
+
+	return DefineInterruptTool[MultiSelectInput, []string](g, config.name, config.description, WithInterruptMetadataKey("multiSelect"))
+}
+
+// HandleMultiSelect is the ToolHandler of the multi-select tool. The answer lists the numbers or the
+// texts of the selected choices separated by commas; it is asked again until the number of selections
+// is within the bounds. It responds with the selected choices in the order they were given.
+func HandleMultiSelect(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var multiSelect MultiSelectInput
+	if err := decodeToolInput(input, &multiSelect); err != nil {
+		return ToolResult{}, err
+	}
+	if err := multiSelect.validate(); err != nil {
+		return ToolResult{}, err
+	}
+
+	question := QuestionInput{
+		Question: multiSelect.Question + " " + multiSelect.hint(),
+		Choices:  multiSelect.Choices,
+	}
+	selected, err := askParsed(ctx, interactor, question, multiSelect.parse)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	return ToolResult{Output: selected}, nil
+}
+
+// validate reports inputs that no answer could satisfy.
+func (m MultiSelectInput) validate() error {
+	switch {
+	case m.Question == "":
+		return errors.New("multi-select tool called without a question")
+	case len(m.Choices) == 0:
+		return errors.New("multi-select tool called without choices")
+	case m.MinSelections < 0 || m.MaxSelections < 0:
+		return errors.New("multi-select tool called with negative selection bounds")
+	case m.MaxSelections > 0 && m.MinSelections > m.MaxSelections:
+		return fmt.Errorf("multi-select tool called with minSelections %d above maxSelections %d", m.MinSelections, m.MaxSelections)
+	case m.MinSelections > len(m.Choices):
+		return fmt.Errorf("multi-select tool called with minSelections %d above the %d choices", m.MinSelections, len(m.Choices))
+	}
+	return nil
+}
+
+// hint tells the user how to answer and how many choices to pick.
+func (m MultiSelectInput) hint() string {
+	switch {
+	case m.MaxSelections > 0 && m.MinSelections == m.MaxSelections:
+		return fmt.Sprintf("(pick %d, separated by commas)", m.MinSelections)
+	case m.MaxSelections > 0:
+		return fmt.Sprintf("(pick %d to %d, separated by commas)", m.MinSelections, m.MaxSelections)
+	case m.MinSelections > 0:
+		return fmt.Sprintf("(pick at least %d, separated by commas)", m.MinSelections)
+	default:
+		return "(pick any, separated by commas)"
+	}
+}
+
+// parse turns a comma-separated list of choice numbers and texts into the selected choices.
+// Choices selected twice are kept once.
+func (m MultiSelectInput) parse(answer string) (any, error) {
+	selected := []string{}
+	for item := range strings.SplitSeq(answer, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		choice, ok := matchChoice(m.Choices, item)
+		if !ok {
+			n, err := strconv.Atoi(item)
+			if err != nil || n < 1 || n > len(m.Choices) {
+				return nil, fmt.Errorf("%q is not one of the choices", item)
+			}
+			choice = m.Choices[n-1]
+		}
+		if !slices.Contains(selected, choice) {
+			selected = append(selected, choice)
+		}
+	}
+
+	switch {
+	case len(selected) < m.MinSelections:
+		return nil, fmt.Errorf("pick at least %d", m.MinSelections)
+	case m.MaxSelections > 0 && len(selected) > m.MaxSelections:
+		return nil, fmt.Errorf("pick at most %d", m.MaxSelections)
+	}
+	return selected, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiSelectInput_Parse(t *testing.T) {
+	choices := []string{"Sports", "Lego", "Art"}
+
+	tests := []struct {
+		name     string
+		input    MultiSelectInput
+		answer   string
+		expected []string
+		err      string
+	}{
+		{name: "numbers", input: MultiSelectInput{Choices: choices}, answer: "3, 1", expected: []string{"Art", "Sports"}},
+		{name: "labels ignoring case", input: MultiSelectInput{Choices: choices}, answer: "lego,ART", expected: []string{"Lego", "Art"}},
+		{name: "duplicates and blanks", input: MultiSelectInput{Choices: choices}, answer: "2, lego,, ", expected: []string{"Lego"}},
+		{name: "nothing when allowed", input: MultiSelectInput{Choices: choices}, answer: "", expected: []string{}},
+		{name: "unknown choice", input: MultiSelectInput{Choices: choices}, answer: "1, Music", err: `"Music" is not one of the choices`},
+		{name: "number out of range", input: MultiSelectInput{Choices: choices}, answer: "4", err: `"4" is not one of the choices`},
+		{name: "too few", input: MultiSelectInput{Choices: choices, MinSelections: 1}, answer: " , ", err: "pick at least 1"},
+		{name: "too many", input: MultiSelectInput{Choices: choices, MaxSelections: 2}, answer: "1,2,3", err: "pick at most 2"},
+		{name: "within bounds", input: MultiSelectInput{Choices: choices, MinSelections: 2, MaxSelections: 2}, answer: "1,2", expected: []string{"Sports", "Lego"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := tt.input.parse(tt.answer)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, selected)
+		})
+	}
+}
+
+func TestMultiSelectInput_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input MultiSelectInput
+		err   string
+	}{
+		{name: "valid", input: MultiSelectInput{Question: "Which?", Choices: []string{"A", "B"}, MinSelections: 1, MaxSelections: 2}},
+		{name: "no choices", input: MultiSelectInput{Question: "Which?"}, err: "without choices"},
+		{name: "min above max", input: MultiSelectInput{Question: "Which?", Choices: []string{"A", "B"}, MinSelections: 2, MaxSelections: 1}, err: "above maxSelections"},
+		{name: "min above choices", input: MultiSelectInput{Question: "Which?", Choices: []string{"A"}, MinSelections: 2}, err: "above the 1 choices"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.input.validate()
+
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestHandleMultiSelect_ThroughToolHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("multiSelect", map[string]any{
+				"question":      "Which interests apply?",
+				"choices":       []any{"Sports", "Lego", "Art"},
+				"minSelections": 1,
+				"maxSelections": 2,
+			})),
+			createTextResponse("A LEGO art set...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "multiSelect": createMockTool("multiSelect")},
+	)
+	var out bytes.Buffer
+	// selecting nothing and selecting too many are both asked again
+	terminal := NewTerminalReader(ctx, strings.NewReader(",\n1,2,3\n2, art\n"), WithAnswerTimeout(time.Second), WithOutput(&out), WithWrapping(false))
+	handler := &InterruptionHandler{generator: mockGen, Interactor: terminal}
+	handler.RegisterToolHandler("multiSelect", HandleMultiSelect)
+
+	result, err := RunAgent(ctx, &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	assert.Equal(t, "A LEGO art set...", result)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, []string{"Lego", "Art"}, parts[0].ToolResponse.Output, "the model gets an array, not a joined string")
+	assert.Contains(t, out.String(), "Which interests apply? (pick 1 to 2, separated by commas)\n1) Sports\n2) Lego\n3) Art\n")
+	assert.Contains(t, out.String(), "Invalid answer: pick at least 1\n")
+	assert.Contains(t, out.String(), "Invalid answer: pick at most 2\n")
+}
+
+func TestDefineMultiSelectTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	multiSelect := DefineMultiSelectTool(g, WithToolName("pickInterests"))
+	_, err := multiSelect.RunRaw(context.Background(), map[string]any{"question": "Which?", "choices": []string{"A", "B"}})
+
+	assert.Equal(t, "pickInterests", multiSelect.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, "pickInterests", metadata["tool"])
+	assert.Equal(t, MultiSelectInput{Question: "Which?", Choices: []string{"A", "B"}}, metadata["multiSelect"])
+}
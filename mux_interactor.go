@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrNoRoute is returned by MuxInteractor for a question no route matches when there is no default route.
+var ErrNoRoute = errors.New("no interactor routes the question")
+
+// MuxInteractor sends each question to one of several interactors, for example end-user questions to the
+// terminal and reviewer questions to Slack. The route is picked from the metadata field of the tool input
+// set with WithRouteMetadataKey and otherwise from the name of the interrupting tool. Being an Interactor
+// itself, it can be used wherever a single interactor is expected.
+type MuxInteractor struct {
+	routes      map[string]Interactor
+	fallback    Interactor
+	metadataKey string
+}
+
+// MuxOption configures a MuxInteractor.
+type MuxOption func(*MuxInteractor)
+
+// WithRoute sends the questions whose route key is key to the interactor.
+// The key is either a value of the metadata field or a tool name.
+func WithRoute(key string, interactor Interactor) MuxOption {
+	return func(m *MuxInteractor) {
+		m.routes[key] = interactor
+	}
+}
+
+// WithDefaultRoute sends the questions no route matches to the interactor.
+func WithDefaultRoute(interactor Interactor) MuxOption {
+	return func(m *MuxInteractor) {
+		m.fallback = interactor
+	}
+}
+
+// WithRouteMetadataKey routes questions by the value of the field of the tool input, such as "audience".
+// The tool name is used when the field is missing or has no route.
+func WithRouteMetadataKey(key string) MuxOption {
+	return func(m *MuxInteractor) {
+		m.metadataKey = key
+	}
+}
+
+// NewMuxInteractor creates a MuxInteractor with the given routes.
+func NewMuxInteractor(opts ...MuxOption) *MuxInteractor {
+	m := &MuxInteractor{routes: make(map[string]Interactor)}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Ask asks the question through the interactor routed to by the interrupt in the context.
+// It fails with ErrNoRoute when nothing matches and there is no default route.
+func (m *MuxInteractor) Ask(ctx context.Context, input QuestionInput) (Answer, error) {
+	interactor, err := m.route(ctx)
+	if err != nil {
+		return Answer{}, err
+	}
+	return interactor.Ask(ctx, input)
+}
+
+// route picks the interactor for the interrupt in the context.
+func (m *MuxInteractor) route(ctx context.Context) (Interactor, error) {
+	interrupt, _ := InterruptFromContext(ctx)
+	if m.metadataKey != "" {
+		if key, ok := interrupt.Input[m.metadataKey].(string); ok {
+			if interactor, ok := m.routes[key]; ok {
+				return interactor, nil
+			}
+		}
+	}
+	if interactor, ok := m.routes[interrupt.ToolName]; ok {
+		return interactor, nil
+	}
+	if m.fallback != nil {
+		return m.fallback, nil
+	}
+	return nil, fmt.Errorf("%w: tool %q", ErrNoRoute, interrupt.ToolName)
+}
+
+// Notify sends the message through every interactor, since it isn't tied to a question.
+func (m *MuxInteractor) Notify(ctx context.Context, message string) error {
+	var errs []error
+	for _, interactor := range m.interactors() {
+		errs = append(errs, interactor.Notify(ctx, message))
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every interactor.
+func (m *MuxInteractor) Close() error {
+	var errs []error
+	for _, interactor := range m.interactors() {
+		errs = append(errs, interactor.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// interactors lists the routed interactors once each, even when several routes share one.
+func (m *MuxInteractor) interactors() []Interactor {
+	seen := make(map[Interactor]bool)
+	var interactors []Interactor
+	add := func(interactor Interactor) {
+		if interactor == nil {
+			return
+		}
+		// interactors that can't be map keys are kept as they are rather than panicking
+		if reflect.TypeOf(interactor).Comparable() {
+			if seen[interactor] {
+				return
+			}
+			seen[interactor] = true
+		}
+		interactors = append(interactors, interactor)
+	}
+	for _, interactor := range m.routes {
+		add(interactor)
+	}
+	add(m.fallback)
+	return interactors
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTaggedToolRequestPart creates a question interrupt whose input carries an extra metadata field.
+func createTaggedToolRequestPart(name, question, key, value string) *ai.Part {
+	part := createToolRequestPart(name, question, nil)
+	part.ToolRequest.Input.(map[string]any)[key] = value
+	return part
+}
+
+func TestMuxInteractor_RunAgent(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createTaggedToolRequestPart("askQuestion", "Approve the budget?", "audience", "reviewer"),
+			),
+			createTextResponse("Based on the approved budget...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	user := &fakeInteractor{answers: map[string]Answer{"What gender are the children?": {Value: "Both"}}}
+	reviewer := &fakeInteractor{answers: map[string]Answer{"Approve the budget?": {Value: "yes"}}}
+	mux := NewMuxInteractor(
+		WithRouteMetadataKey("audience"),
+		WithRoute("askQuestion", user),
+		WithRoute("reviewer", reviewer),
+	)
+
+	result, err := RunAgent(context.Background(), &Options{
+		generator:       mockGen,
+		responseHandler: &InterruptionHandler{generator: mockGen, Interactor: mux},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Based on the approved budget...", result)
+	toolResponses := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, toolResponses, 2)
+	assert.Equal(t, "Both", toolResponses[0].ToolResponse.Output)
+	assert.Equal(t, "yes", toolResponses[1].ToolResponse.Output)
+}
+
+func TestMuxInteractor_Route(t *testing.T) {
+	user := &fakeInteractor{answers: map[string]Answer{"Q": {Value: "user"}}}
+	reviewer := &fakeInteractor{answers: map[string]Answer{"Q": {Value: "reviewer"}}}
+	fallback := &fakeInteractor{answers: map[string]Answer{"Q": {Value: "default"}}}
+
+	tests := []struct {
+		name        string
+		opts        []MuxOption
+		interrupt   *Interrupt
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:      "tool name",
+			opts:      []MuxOption{WithRoute("approve", reviewer), WithRoute("askQuestion", user)},
+			interrupt: &Interrupt{ToolName: "approve"},
+			expected:  "reviewer",
+		},
+		{
+			name:      "metadata wins over the tool name",
+			opts:      []MuxOption{WithRouteMetadataKey("audience"), WithRoute("askQuestion", user), WithRoute("reviewer", reviewer)},
+			interrupt: &Interrupt{ToolName: "askQuestion", Input: map[string]any{"audience": "reviewer"}},
+			expected:  "reviewer",
+		},
+		{
+			name:      "unknown metadata value falls back to the tool name",
+			opts:      []MuxOption{WithRouteMetadataKey("audience"), WithRoute("askQuestion", user)},
+			interrupt: &Interrupt{ToolName: "askQuestion", Input: map[string]any{"audience": "auditor"}},
+			expected:  "user",
+		},
+		{
+			name:      "default route",
+			opts:      []MuxOption{WithRoute("askQuestion", user), WithDefaultRoute(fallback)},
+			interrupt: &Interrupt{ToolName: "approve"},
+			expected:  "default",
+		},
+		{
+			name:     "follow-up question without an interrupt",
+			opts:     []MuxOption{WithRoute("askQuestion", user), WithDefaultRoute(fallback)},
+			expected: "default",
+		},
+		{
+			name:        "no route",
+			opts:        []MuxOption{WithRoute("askQuestion", user)},
+			interrupt:   &Interrupt{ToolName: "approve"},
+			expectedErr: ErrNoRoute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.interrupt != nil {
+				ctx = WithInterrupt(ctx, *tt.interrupt)
+			}
+
+			answer, err := NewMuxInteractor(tt.opts...).Ask(ctx, QuestionInput{Question: "Q"})
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expected, answer.Value)
+		})
+	}
+}
+
+func TestMuxInteractor_NotifyAndClose(t *testing.T) {
+	user, reviewer := &fakeInteractor{}, &fakeInteractor{}
+	failing := InteractorFunc(func(context.Context, QuestionInput) (string, error) {
+		return "", errors.New("unused")
+	})
+	mux := NewMuxInteractor(
+		WithRoute("askQuestion", user),
+		WithRoute("confirm", user),
+		WithRoute("approve", reviewer),
+		WithDefaultRoute(failing),
+	)
+
+	require.NoError(t, mux.Notify(context.Background(), "The run is done"))
+	require.NoError(t, mux.Close())
+
+	assert.Equal(t, []string{"The run is done"}, user.notified, "an interactor behind several routes is notified once")
+	assert.Equal(t, []string{"The run is done"}, reviewer.notified)
+	assert.True(t, user.closed)
+	assert.True(t, reviewer.closed)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineNumberTool.
+const (
+	defaultNumberToolName        = "askNumber"
+	defaultNumberToolDescription = "use this to ask the user for a number, such as a budget; the response is the number and its unit"
+)
+
+// numberNoise is stripped from numeric answers: currency symbols, thousands separators and spaces.
+var numberNoise = strings.NewReplacer("$", "", "€", "", "£", "", "¥", "", ",", "", " ", "")
+
+// NumberInput contains a question answered with a number.
+type NumberInput struct {
+	Question string   `json:"question" jsonschema:"description=a question such as 'What is the budget?'"`
+	Min      *float64 `json:"min,omitempty" jsonschema:"description=the smallest accepted number"`
+	Max      *float64 `json:"max,omitempty" jsonschema:"description=the largest accepted number"`
+	Unit     string   `json:"unit,omitempty" jsonschema:"description=the unit of the number shown to the user, such as 'USD' or 'years'"`
+}
+
+// DefineNumberTool defines the numeric tool, named "askNumber" by default, in the Genkit instance and
+// returns it. Its interrupts are answered by HandleNumber, which must be registered for the tool name
+// with RegisterToolHandler.
+func DefineNumberTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultNumberToolName, defaultNumberToolDescription, opts)
+
+	return DefineInterruptTool[NumberInput, float64](g, config.name, config.description, WithInterruptMetadataKey("number"))
+}
+
+// HandleNumber is the ToolHandler of the numeric tool. The question is shown with the unit and the
+// bounds; currency symbols, thousands separators and the unit are stripped from the answer, which is
+// asked again until it is a number within the bounds. It responds with the number, and the unit in the
+// "unit" metadata.
+func HandleNumber(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var number NumberInput
+	if err := decodeToolInput(input, &number); err != nil {
+		return ToolResult{}, err
+	}
+	if number.Question == "" {
+		return ToolResult{}, errors.New("number tool called without a question")
+	}
+	if number.Min != nil && number.Max != nil && *number.Min > *number.Max {
+		return ToolResult{}, fmt.Errorf("number tool called with min %g above max %g", *number.Min, *number.Max)
+	}
+
+	question := number.Question
+	if hint := number.hint(); hint != "" {
+		question += " (" + hint + ")"
+	}
+	value, err := askParsed(ctx, interactor, QuestionInput{Question: question}, number.parse)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	var metadata map[string]any
+	if number.Unit != "" {
+		metadata = map[string]any{"unit": number.Unit}
+	}
+	return ToolResult{Output: value, Metadata: metadata}, nil
+}
+
+// hint renders the unit and the bounds, such as "USD, 10 to 100".
+func (n NumberInput) hint() string {
+	var parts []string
+	if n.Unit != "" {
+		parts = append(parts, n.Unit)
+	}
+	switch {
+	case n.Min != nil && n.Max != nil:
+		parts = append(parts, fmt.Sprintf("%g to %g", *n.Min, *n.Max))
+	case n.Min != nil:
+		parts = append(parts, fmt.Sprintf("at least %g", *n.Min))
+	case n.Max != nil:
+		parts = append(parts, fmt.Sprintf("at most %g", *n.Max))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parse converts the answer into a number within the bounds.
+func (n NumberInput) parse(answer string) (any, error) {
+	text := strings.TrimSpace(answer)
+	if n.Unit != "" && len(text) >= len(n.Unit) && strings.EqualFold(text[len(text)-len(n.Unit):], n.Unit) {
+		text = text[:len(text)-len(n.Unit)]
+	}
+	value, err := strconv.ParseFloat(numberNoise.Replace(text), 64)
+	if err != nil {
+		return nil, errors.New("expected a number, such as 50 or 49.99")
+	}
+	switch {
+	case n.Min != nil && value < *n.Min:
+		return nil, fmt.Errorf("expected at least %g", *n.Min)
+	case n.Max != nil && value > *n.Max:
+		return nil, fmt.Errorf("expected at most %g", *n.Max)
+	}
+	return value, nil
+}
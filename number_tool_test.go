@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNumberInput_Parse(t *testing.T) {
+	minimum, maximum := 10.0, 1000.0
+	budget := NumberInput{Min: &minimum, Max: &maximum, Unit: "USD"}
+
+	tests := []struct {
+		answer   string
+		expected any
+		err      string
+	}{
+		{answer: "$50", expected: 50.0},
+		{answer: "50.5", expected: 50.5},
+		{answer: " 75 usd ", expected: 75.0},
+		{answer: "$ 1,000", expected: 1000.0},
+		{answer: "fifty", err: "expected a number, such as 50 or 49.99"},
+		{answer: "around 50", err: "expected a number, such as 50 or 49.99"},
+		{answer: "5", err: "expected at least 10"},
+		{answer: "1000.01", err: "expected at most 1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.answer, func(t *testing.T) {
+			value, err := budget.parse(tt.answer)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestNumberInput_Hint(t *testing.T) {
+	minimum, maximum := 0.0, 99.5
+
+	assert.Equal(t, "", NumberInput{}.hint())
+	assert.Equal(t, "EUR, 0 to 99.5", NumberInput{Unit: "EUR", Min: &minimum, Max: &maximum}.hint())
+	assert.Equal(t, "at least 0", NumberInput{Min: &minimum}.hint())
+	assert.Equal(t, "years, at most 99.5", NumberInput{Unit: "years", Max: &maximum}.hint())
+}
+
+func TestHandleNumber_ThroughToolHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askNumber", map[string]any{
+				"question": "What is the budget?",
+				"min":      10,
+				"max":      200,
+				"unit":     "USD",
+			})),
+			createTextResponse("With $50 you can get...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askNumber": createMockTool("askNumber")},
+	)
+	var out bytes.Buffer
+	terminal := NewTerminalReader(ctx, strings.NewReader("about fifty bucks\n$500\n$50\n"), WithAnswerTimeout(time.Second), WithOutput(&out), WithWrapping(false))
+	handler := &InterruptionHandler{generator: mockGen, Interactor: terminal}
+	handler.RegisterToolHandler("askNumber", HandleNumber)
+
+	_, err := RunAgent(ctx, &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, 50.0, parts[0].ToolResponse.Output)
+	assert.Equal(t, map[string]any{"interruptResponse": map[string]any{"unit": "USD"}}, parts[0].Metadata)
+	assert.Contains(t, out.String(), "What is the budget? (USD, 10 to 200)\n")
+	assert.Contains(t, out.String(), "Invalid answer: expected a number, such as 50 or 49.99\n")
+	assert.Contains(t, out.String(), "Invalid answer: expected at most 200\n")
+}
+
+func TestHandleNumber_InvalidBounds(t *testing.T) {
+	_, err := HandleNumber(context.Background(), &sequenceInteractor{}, map[string]any{"question": "How much?", "min": 5, "max": 1})
+
+	assert.ErrorContains(t, err, "min 5 above max 1")
+}
+
+func TestDefineNumberTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	tool := DefineNumberTool(g)
+	_, err := tool.RunRaw(context.Background(), map[string]any{"question": "How much?", "unit": "USD"})
+
+	assert.Equal(t, "askNumber", tool.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, NumberInput{Question: "How much?", Unit: "USD"}, metadata["number"])
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// waitForAnswer blocks until an answer arrives on answerCh, the timeout expires or the context is done.
+// The timeout is the one questionTimeout computes for the question; zero waits until the context is done.
+func waitForAnswer[T any](ctx context.Context, answerCh <-chan T, input QuestionInput, timeout, limit time.Duration) (T, error) {
+	var zero T
+	timeout = questionTimeout(input, timeout, limit)
+	// a nil channel never fires, so a disabled timeout waits for the answer or the context
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	select {
+	case answer := <-answerCh:
+		return answer, nil
+	case <-timeoutCh:
+		return zero, &ErrAnswerTimeout{Question: input.Question, Timeout: timeout}
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// outstandingQuestion is a question sent to the user and waiting for the answer.
+type outstandingQuestion struct {
+	input QuestionInput
+	// answerCh is buffered so that the handler delivering the answer never waits for the asker.
+	answerCh chan string
+}
+
+func newOutstandingQuestion(input QuestionInput) *outstandingQuestion {
+	return &outstandingQuestion{input: input, answerCh: make(chan string, 1)}
+}
+
+// wait blocks until the question is answered, the timeout expires or the context is done.
+// A timeout requested by the question replaces timeout, up to defaultMaxAnswerTimeout.
+func (p *outstandingQuestion) wait(ctx context.Context, timeout time.Duration) (string, error) {
+	return waitForAnswer(ctx, p.answerCh, p.input, timeout, defaultMaxAnswerTimeout)
+}
+
+// pendingAnswers holds the questions an interactor waits answers for, keyed by what identifies the
+// question in the answers it receives, such as a message ID. The zero value is ready to use.
+type pendingAnswers[K comparable] struct {
+	mu        sync.Mutex
+	questions map[K]*outstandingQuestion
+	// order lists the keys in the order the questions were asked.
+	order []K
+}
+
+// add registers the question under the key.
+func (p *pendingAnswers[K]) add(key K, input QuestionInput) *outstandingQuestion {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.addLocked(key, input)
+}
+
+// post registers the question under the key that send returns, such as the ID of the posted message.
+// The lock is held while sending so that an answer can't arrive before the question is registered.
+func (p *pendingAnswers[K]) post(input QuestionInput, send func() (K, error)) (K, *outstandingQuestion, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, err := send()
+	if err != nil {
+		return key, nil, err
+	}
+	return key, p.addLocked(key, input), nil
+}
+
+func (p *pendingAnswers[K]) addLocked(key K, input QuestionInput) *outstandingQuestion {
+	if p.questions == nil {
+		p.questions = make(map[K]*outstandingQuestion)
+	}
+	pending := newOutstandingQuestion(input)
+	p.questions[key] = pending
+	p.order = append(p.order, key)
+	return pending
+}
+
+// remove forgets the question so that later answers are ignored.
+func (p *pendingAnswers[K]) remove(key K) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removeLocked(key)
+}
+
+func (p *pendingAnswers[K]) removeLocked(key K) {
+	delete(p.questions, key)
+	p.order = slices.DeleteFunc(p.order, func(pendingKey K) bool {
+		return pendingKey == key
+	})
+}
+
+// answer resolves a reply into the answer to the question registered under the key and delivers it.
+// It reports false when the question isn't pending anymore or resolve rejects the reply.
+func (p *pendingAnswers[K]) answer(key K, resolve func(QuestionInput) (string, bool)) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pending, ok := p.questions[key]
+	if !ok {
+		return false
+	}
+	answer, ok := resolve(pending.input)
+	if !ok {
+		return false
+	}
+	// the first answer wins, later ones find no pending question
+	p.removeLocked(key)
+	pending.answerCh <- answer
+	return true
+}
+
+// len returns how many questions are pending.
+func (p *pendingAnswers[K]) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.questions)
+}
+
+// each calls fn for the pending questions in the order they were asked until fn returns false.
+// Questions can't be added or answered meanwhile.
+func (p *pendingAnswers[K]) each(fn func(key K, input QuestionInput) bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, key := range p.order {
+		if !fn(key, p.questions[key].input) {
+			return
+		}
+	}
+}
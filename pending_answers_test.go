@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForAnswer(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       QuestionInput
+		timeout     time.Duration
+		limit       time.Duration
+		answer      bool
+		cancel      bool
+		wantTimeout time.Duration
+		wantErr     error
+	}{
+		{name: "answered", timeout: time.Hour, answer: true},
+		{name: "default timeout", timeout: 10 * time.Millisecond, wantTimeout: 10 * time.Millisecond},
+		{name: "requested timeout", input: QuestionInput{TimeoutSeconds: 1}, timeout: time.Hour, wantTimeout: time.Second},
+		{name: "requested timeout capped", input: QuestionInput{TimeoutSeconds: 3600}, limit: 20 * time.Millisecond, wantTimeout: 20 * time.Millisecond},
+		{name: "cancelled", cancel: true, wantErr: context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.input.Question = "Which city?"
+			answerCh := make(chan string, 1)
+			if tt.answer {
+				answerCh <- "Paris"
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			answer, err := waitForAnswer(ctx, answerCh, tt.input, tt.timeout, tt.limit)
+			switch {
+			case tt.wantTimeout > 0:
+				var timeoutErr *ErrAnswerTimeout
+				require.True(t, errors.As(err, &timeoutErr))
+				assert.Equal(t, tt.wantTimeout, timeoutErr.Timeout)
+				assert.Equal(t, "Which city?", timeoutErr.Question)
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, "Paris", answer)
+			}
+		})
+	}
+}
+
+func TestPendingAnswers(t *testing.T) {
+	var pending pendingAnswers[string]
+	first := pending.add("1", QuestionInput{Question: "Which city?", Choices: []string{"Paris", "Rome"}})
+	key, _, err := pending.post(QuestionInput{Question: "Which date?"}, func() (string, error) {
+		// the registry stays locked while sending, so nothing can be answered yet
+		assert.False(t, pending.mu.TryLock())
+		return "2", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "2", key)
+
+	_, _, err = pending.post(QuestionInput{}, func() (string, error) {
+		return "", errors.New("send failed")
+	})
+	assert.EqualError(t, err, "send failed")
+	assert.Equal(t, 2, pending.len())
+
+	var keys []string
+	pending.each(func(key string, input QuestionInput) bool {
+		keys = append(keys, key+": "+input.Question)
+		return true
+	})
+	assert.Equal(t, []string{"1: Which city?", "2: Which date?"}, keys)
+
+	choose := func(input QuestionInput) (string, bool) {
+		return resolveChoice(input.Choices, "2")
+	}
+	assert.False(t, pending.answer("3", choose), "unknown key")
+	assert.False(t, pending.answer("1", func(QuestionInput) (string, bool) { return "", false }), "rejected reply")
+	assert.True(t, pending.answer("1", choose))
+	assert.False(t, pending.answer("1", choose), "the first answer wins")
+
+	answer, err := first.wait(context.Background(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "Rome", answer)
+
+	pending.remove("2")
+	assert.Zero(t, pending.len())
+}
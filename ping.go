@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// pingConfig limits the generation of a ping to a single token, with the keys of both the Gemini and
+// the OpenAI configs, each provider ignoring the key of the other.
+var pingConfig = map[string]any{"maxOutputTokens": 1, "max_tokens": 1}
+
+// ErrBackendAuth is returned by Ping when the backend of the model rejects the credentials.
+type ErrBackendAuth struct {
+	Err error
+}
+
+func (e *ErrBackendAuth) Error() string {
+	return fmt.Sprintf("the model backend rejected the credentials: %v", e.Err)
+}
+
+func (e *ErrBackendAuth) Unwrap() error {
+	return e.Err
+}
+
+// ErrBackendQuota is returned by Ping when the quota or the rate limit of the backend is used up.
+type ErrBackendQuota struct {
+	Err error
+}
+
+func (e *ErrBackendQuota) Error() string {
+	return fmt.Sprintf("the quota of the model backend is used up: %v", e.Err)
+}
+
+func (e *ErrBackendQuota) Unwrap() error {
+	return e.Err
+}
+
+// ErrModelNotFound is returned by Ping when the backend has no model by the name.
+type ErrModelNotFound struct {
+	Model string
+	Err   error
+}
+
+func (e *ErrModelNotFound) Error() string {
+	return fmt.Sprintf("the model %s doesn't exist: %v", e.Model, e.Err)
+}
+
+func (e *ErrModelNotFound) Unwrap() error {
+	return e.Err
+}
+
+// Pinger is a Generator whose backend can be checked, such as GenkitGenerator and the generators
+// wrapping one.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ping pings the generator. A generator without a backend to check, such as a ReplayGenerator, is
+// always ready.
+func ping(ctx context.Context, generator Generator) error {
+	if pinger, ok := generator.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Ping checks that the backend of the model is reachable and has Model, by looking the model up with
+// the provider, or by generating a single token when PingGenerates is set, the provider can't look up
+// models or Model is empty. Rejected credentials, a used up quota and a missing model are reported as
+// ErrBackendAuth, ErrBackendQuota and ErrModelNotFound.
+func (g *GenkitGenerator) Ping(ctx context.Context) error {
+	var err error
+	if _, model, ok := strings.Cut(g.Model, "/"); ok && g.lookupModel != nil && !g.PingGenerates {
+		err = g.lookupModel(ctx, model)
+	} else {
+		_, err = g.Generate(ctx, ai.WithPrompt("ping"), ai.WithConfig(pingConfig))
+	}
+	if err != nil {
+		return pingError(g.Model, err)
+	}
+	return nil
+}
+
+// pingError returns the typed error of a failed ping by the HTTP status the backend answered with, or
+// err itself for other failures, such as an unreachable backend.
+func pingError(model string, err error) error {
+	switch backendStatus(err) {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &ErrBackendAuth{Err: err}
+	case http.StatusTooManyRequests:
+		return &ErrBackendQuota{Err: err}
+	case http.StatusNotFound:
+		return &ErrModelNotFound{Model: model, Err: err}
+	default:
+		return err
+	}
+}
+
+// backendStatus returns the HTTP status of an error of the Gemini API, of an OpenAI-compatible API or of
+// Genkit, or 0 for other errors.
+func backendStatus(err error) int {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		return openaiErr.StatusCode
+	}
+	var genkitErr *core.GenkitError
+	if errors.As(err, &genkitErr) {
+		return core.HTTPStatusCode(genkitErr.Status)
+	}
+	return 0
+}
+
+// geminiModelLookup returns a lookup of the models of the Gemini API.
+func geminiModelLookup(client *genai.Client) func(ctx context.Context, model string) error {
+	return func(ctx context.Context, model string) error {
+		_, err := client.Models.Get(ctx, model, nil)
+		return err
+	}
+}
+
+// openAIModelLookup returns a lookup of the models of an OpenAI-compatible API.
+func openAIModelLookup(client openai.Client) func(ctx context.Context, model string) error {
+	return func(ctx context.Context, model string) error {
+		_, err := client.Models.Get(ctx, model)
+		return err
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+	"google.golang.org/genai"
+)
+
+func TestGenkitGenerator_Ping(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected any
+	}{
+		{name: "ready"},
+		{name: "gemini unauthenticated", err: genai.APIError{Code: http.StatusUnauthorized, Status: "UNAUTHENTICATED"}, expected: &ErrBackendAuth{}},
+		{name: "gemini permission denied", err: genai.APIError{Code: http.StatusForbidden, Status: "PERMISSION_DENIED"}, expected: &ErrBackendAuth{}},
+		{name: "gemini quota", err: genai.APIError{Code: http.StatusTooManyRequests, Status: "RESOURCE_EXHAUSTED"}, expected: &ErrBackendQuota{}},
+		{name: "gemini model not found", err: genai.APIError{Code: http.StatusNotFound, Status: "NOT_FOUND"}, expected: &ErrModelNotFound{}},
+		{name: "openai unauthorized", err: &openai.Error{StatusCode: http.StatusUnauthorized}, expected: &ErrBackendAuth{}},
+		{name: "openai rate limited", err: &openai.Error{StatusCode: http.StatusTooManyRequests}, expected: &ErrBackendQuota{}},
+		{name: "openai model not found", err: &openai.Error{StatusCode: http.StatusNotFound}, expected: &ErrModelNotFound{}},
+		{name: "genkit permission denied", err: core.NewError(core.PERMISSION_DENIED, "no access"), expected: &ErrBackendAuth{}},
+		{name: "genkit resource exhausted", err: core.NewError(core.RESOURCE_EXHAUSTED, "quota"), expected: &ErrBackendQuota{}},
+		{name: "genkit model not found", err: core.NewError(core.NOT_FOUND, "model not found"), expected: &ErrModelNotFound{}},
+		{name: "unreachable", err: assert.AnError},
+	}
+
+	for _, tt := range tests {
+		for _, generates := range []bool{false, true} {
+			t.Run(tt.name, func(t *testing.T) {
+				var looked []string
+				var generated int
+				generator := &GenkitGenerator{
+					Model:         "googleai/gemini-2.5-flash",
+					PingGenerates: generates,
+					lookupModel: func(_ context.Context, model string) error {
+						looked = append(looked, model)
+						return tt.err
+					},
+					generate: func(context.Context, ...ai.GenerateOption) (*ai.ModelResponse, error) {
+						generated++
+						if tt.err != nil {
+							return nil, tt.err
+						}
+						return createTextResponse("pong", "length"), nil
+					},
+				}
+
+				err := generator.Ping(context.Background())
+
+				if generates {
+					assert.Empty(t, looked)
+					assert.Equal(t, 1, generated)
+				} else {
+					assert.Equal(t, []string{"gemini-2.5-flash"}, looked)
+					assert.Zero(t, generated)
+				}
+				switch {
+				case tt.err == nil:
+					assert.NoError(t, err)
+				case tt.expected == nil:
+					assert.Same(t, tt.err, err, "an error without a status isn't classified")
+				default:
+					assert.IsType(t, tt.expected, err)
+					assert.Equal(t, tt.err, errors.Unwrap(err))
+				}
+			})
+		}
+	}
+}
+
+func TestGenkitGenerator_PingGeneratesOneToken(t *testing.T) {
+	var configs []any
+	g, calls := countingModel(func(req *ai.ModelRequest) *ai.ModelResponse {
+		configs = append(configs, req.Config)
+		return &ai.ModelResponse{Message: ai.NewModelTextMessage("pong"), FinishReason: ai.FinishReasonLength}
+	})
+	generator := &GenkitGenerator{AIClient: g}
+
+	require.NoError(t, generator.Ping(context.Background()), "the default model is pinged by generating")
+
+	assert.Equal(t, int64(1), calls.Load())
+	assert.Equal(t, []any{pingConfig}, configs)
+}
+
+func TestGenkitGenerator_PingModelNotFound(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error": {"code": 404, "message": "models/gemini-9 is not found", "status": "NOT_FOUND"}}`))
+	}))
+	defer server.Close()
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		Backend:     genai.BackendGeminiAPI,
+		APIKey:      "key",
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	})
+	require.NoError(t, err)
+	generator := &GenkitGenerator{Model: "googleai/gemini-9", lookupModel: geminiModelLookup(client)}
+
+	err = generator.Ping(context.Background())
+
+	var notFound *ErrModelNotFound
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, "googleai/gemini-9", notFound.Model)
+	assert.Contains(t, path, "models/gemini-9")
+}
+
+func TestGenkitGenerator_PingOpenAIUnauthorized(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error", "code": "invalid_api_key"}}`))
+	}))
+	defer server.Close()
+	client := openai.NewClient(option.WithAPIKey("key"), option.WithBaseURL(server.URL), option.WithMaxRetries(0))
+	generator := &GenkitGenerator{Model: "openai/gpt-4o-mini", lookupModel: openAIModelLookup(client)}
+
+	err := generator.Ping(context.Background())
+
+	assert.IsType(t, &ErrBackendAuth{}, err)
+	assert.Equal(t, "/models/gpt-4o-mini", path)
+}
+
+func TestPing_Decorators(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC)}
+	quota := core.NewError(core.RESOURCE_EXHAUSTED, "quota")
+	tests := []struct {
+		name     string
+		decorate func(inner Generator) Generator
+	}{
+		{name: "retrying", decorate: func(inner Generator) Generator {
+			return newTestRetryingGenerator(inner, RetryPolicy{MaxAttempts: 5}, &fakeSleeper{}, 0.5)
+		}},
+		{name: "rate limited", decorate: func(inner Generator) Generator {
+			return newTestRateLimitedGenerator(inner, rate.Every(time.Hour), 1, clock)
+		}},
+		{name: "bounded", decorate: func(inner Generator) Generator { return NewBoundedGenerator(inner, 1) }},
+		{name: "caching", decorate: func(inner Generator) Generator { return NewCachingGenerator(inner, NewMemoryCache(10)) }},
+		{name: "usage tracking", decorate: func(inner Generator) Generator { return NewUsageTrackingGenerator(inner) }},
+		{name: "fallback", decorate: func(inner Generator) Generator { return NewFallbackGenerator(inner, &flakyGenerator{}, nil) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &flakyGenerator{errs: []error{quota, quota, quota}}
+			generator := tt.decorate(inner)
+
+			for range 3 {
+				assert.ErrorIs(t, ping(context.Background(), generator), quota)
+			}
+			assert.NoError(t, ping(context.Background(), generator))
+
+			assert.Equal(t, 4, inner.calls, "every ping reaches the inner generator once")
+		})
+	}
+}
+
+func TestPing_RateLimitNotSpent(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC)}
+	generator := newTestRateLimitedGenerator(&flakyGenerator{}, rate.Every(time.Hour), 1, clock)
+
+	for range 3 {
+		require.NoError(t, generator.Ping(context.Background()))
+	}
+	_, err := generator.Generate(context.Background())
+
+	require.NoError(t, err)
+	assert.Empty(t, clock.sleeps, "the pings don't take the turn of the call")
+}
+
+func TestPing_WithoutBackend(t *testing.T) {
+	assert.NoError(t, ping(context.Background(), NewMockGenerator(nil, nil)))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// parsePromptTemplate parses the text/template of a prompt. Executing it fails on a variable missing
+// from the vars instead of rendering "<no value>".
+func parsePromptTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse the %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// renderPromptTemplate renders the text/template of a prompt with the vars.
+func renderPromptTemplate(name, text string, vars map[string]any) (string, error) {
+	tmpl, err := parsePromptTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, vars); err != nil {
+		return "", fmt.Errorf("can't render the %s template: %w", name, err)
+	}
+	return rendered.String(), nil
+}
+
+type promptVarsKey struct{}
+
+// withPromptVars returns a context whose prompts other than the system and user prompts of the run,
+// such as the validation prompt of ConversationLoopHandler, are rendered as templates with vars.
+func withPromptVars(ctx context.Context, vars map[string]any) context.Context {
+	return context.WithValue(ctx, promptVarsKey{}, vars)
+}
+
+// promptVars returns the vars of the prompt templates of the run, and whether the run has them.
+func promptVars(ctx context.Context) (map[string]any, bool) {
+	vars, ok := ctx.Value(promptVarsKey{}).(map[string]any)
+	return vars, ok
+}
+
+// Validate reports the options RunAgent can't run with: a prompt set in more than one way, as text, as
+// a template or from a .prompt file, a template that doesn't parse, including the validation prompt
+// of a ConversationLoopHandler rendered with the vars, or a config at odds with a deterministic run.
+func (o *Options) Validate() error {
+	if promptSources(o.systemPrompt, o.systemPromptTemplate, o.systemPromptRef) > 1 {
+		return errors.New("the system prompt is set more than once, as text, as a template or from a .prompt file")
+	}
+	if promptSources(o.userPrompt, o.userPromptTemplate, o.userPromptRef) > 1 {
+		return errors.New("the user prompt is set more than once, as text, as a template or from a .prompt file")
+	}
+	if _, err := parsePromptTemplate("system prompt", o.systemPromptTemplate); err != nil {
+		return err
+	}
+	if _, err := parsePromptTemplate("user prompt", o.userPromptTemplate); err != nil {
+		return err
+	}
+	if loop, ok := o.responseHandler.(*ConversationLoopHandler); ok && o.vars != nil && loop.validationPromptRef == nil {
+		if _, err := parsePromptTemplate("validation prompt", loop.validationPrompt); err != nil {
+			return err
+		}
+	}
+	_, err := o.generationConfig()
+	return err
+}
+
+// renderPrompts returns the system and user prompts of the run, rendering their templates and
+// .prompt files with the vars.
+func (o *Options) renderPrompts(ctx context.Context) (SystemPrompt, UserPrompt, error) {
+	systemPrompt, userPrompt := o.systemPrompt, o.userPrompt
+	if o.systemPromptRef != nil {
+		rendered, err := renderPromptRef(ctx, "system prompt", o.systemPromptRef, o.vars)
+		if err != nil {
+			return "", "", err
+		}
+		systemPrompt = SystemPrompt(rendered)
+	}
+	if o.userPromptRef != nil {
+		rendered, err := renderPromptRef(ctx, "user prompt", o.userPromptRef, o.vars)
+		if err != nil {
+			return "", "", err
+		}
+		userPrompt = UserPrompt(rendered)
+	}
+	if o.systemPromptTemplate != "" {
+		rendered, err := renderPromptTemplate("system prompt", o.systemPromptTemplate, o.vars)
+		if err != nil {
+			return "", "", err
+		}
+		systemPrompt = SystemPrompt(rendered)
+	}
+	if o.userPromptTemplate != "" {
+		rendered, err := renderPromptTemplate("user prompt", o.userPromptTemplate, o.vars)
+		if err != nil {
+			return "", "", err
+		}
+		userPrompt = UserPrompt(rendered)
+	}
+	return systemPrompt, userPrompt, nil
+}
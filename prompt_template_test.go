@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// giftVars are the variables of the prompt templates of the tests.
+var giftVars = map[string]any{"occasion": "Christmas", "deadline": "December 20", "persona": "a busy parent"}
+
+// capturedPromptFields returns the fields the generate options set, with the system and user prompts
+// resolved.
+func capturedPromptFields(t *testing.T, opts []ai.GenerateOption) map[string]any {
+	t.Helper()
+	fields := map[string]any{}
+	for _, opt := range opts {
+		require.NoError(t, optionFields(context.Background(), reflect.ValueOf(opt), fields))
+	}
+	return fields
+}
+
+func TestRunAgent_PromptTemplates(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("A LEGO set", "stop")}, nil)
+
+	text, err := RunAgent(context.Background(), &Options{
+		generator:            mockGen,
+		systemPromptTemplate: "You help {{.persona}} find gifts.",
+		userPromptTemplate:   "Suggest a {{.occasion}} gift that arrives by {{.deadline}}.",
+		vars:                 giftVars,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "A LEGO set", text)
+	require.Len(t, mockGen.capturedCalls, 1)
+	fields := capturedPromptFields(t, mockGen.capturedCalls[0].Options)
+	assert.Equal(t, "You help a busy parent find gifts.", fields["SystemFn"])
+	assert.Equal(t, "Suggest a Christmas gift that arrives by December 20.", fields["PromptFn"])
+}
+
+func TestRunAgent_PromptTemplateMissingVariable(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("A LEGO set", "stop")}, nil)
+
+	_, err := RunAgent(context.Background(), &Options{
+		generator:            mockGen,
+		systemPromptTemplate: "You help {{.persona}} find gifts.",
+		userPromptTemplate:   "Suggest a gift for {{.recipient}}.",
+		vars:                 giftVars,
+	})
+
+	assert.ErrorContains(t, err, "can't render the user prompt template")
+	assert.ErrorContains(t, err, `map has no entry for key "recipient"`)
+	assert.Empty(t, mockGen.capturedCalls, "the model isn't called")
+}
+
+func TestConversationLoopHandler_ValidationPromptTemplate(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("A LEGO set", "stop")}, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")})
+	mockGen.boolResponses = []bool{true}
+	handler := NewConversationLoopHandler(mockGen, "Does the answer suit a gift for {{.occasion}}?", nil)
+
+	_, err := RunAgent(context.Background(), &Options{
+		generator:       mockGen,
+		userPrompt:      "Suggest a gift.",
+		vars:            giftVars,
+		responseHandler: handler,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Does the answer suit a gift for Christmas?"}, mockGen.boolPrompts)
+}
+
+func TestConversationLoopHandler_ValidationPromptWithoutVars(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("A LEGO set", "stop")}, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")})
+	mockGen.boolResponses = []bool{true}
+	handler := NewConversationLoopHandler(mockGen, "Is the answer in {{braces}}?", nil)
+
+	_, err := RunAgent(context.Background(), &Options{generator: mockGen, userPrompt: "Suggest a gift.", responseHandler: handler})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Is the answer in {{braces}}?"}, mockGen.boolPrompts, "the prompt is a template only for runs with vars")
+}
+
+func TestOptions_Validate(t *testing.T) {
+	loop := NewConversationLoopHandler(nil, "Is it suitable for {{.occasion", nil)
+	prompts := loadTestPrompts(t)
+	refLoop := NewConversationLoopHandler(nil, "Is it suitable for {{.occasion", nil)
+	refLoop.UseValidationPromptRef(prompts["gift_finished"])
+
+	tests := []struct {
+		name    string
+		options Options
+		err     string
+	}{
+		{name: "templates", options: Options{systemPromptTemplate: "You help {{.persona}}.", userPromptTemplate: "Suggest a gift.", vars: giftVars}},
+		{name: "plain prompts", options: Options{systemPrompt: "You help {{.persona}}.", userPrompt: "Suggest a gift.", responseHandler: loop}},
+		{name: "system prompt", options: Options{systemPromptTemplate: "You help {{.persona}."}, err: "can't parse the system prompt template"},
+		{name: "user prompt", options: Options{userPromptTemplate: "Suggest {{if .occasion}}a gift."}, err: "can't parse the user prompt template"},
+		{name: "validation prompt", options: Options{userPrompt: "Suggest a gift.", vars: giftVars, responseHandler: loop}, err: "can't parse the validation prompt template"},
+		{name: "system prompt twice", options: Options{systemPrompt: "You help.", systemPromptTemplate: "You help {{.persona}}."}, err: "the system prompt is set more than once"},
+		{name: "user prompt twice", options: Options{userPrompt: "Suggest a gift.", userPromptTemplate: "Suggest a {{.occasion}} gift."}, err: "the user prompt is set more than once"},
+		{name: "prompt files", options: Options{systemPromptRef: prompts["gift_system"], userPromptRef: prompts["gift_request"], vars: giftVars, responseHandler: refLoop}},
+		{name: "system prompt file and text", options: Options{systemPrompt: "You help.", systemPromptRef: prompts["gift_system"]}, err: "the system prompt is set more than once"},
+		{name: "user prompt file and template", options: Options{userPromptTemplate: "Suggest a gift.", userPromptRef: prompts["gift_request"]}, err: "the user prompt is set more than once"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineProvideTextTool and its handler.
+const (
+	defaultProvideTextToolName        = "provideText"
+	defaultProvideTextToolDescription = "use this to ask the user to paste a longer text the answer depends on, such as a previous wishlist or an email; the response is the text, or a summary of it when it is long"
+	// defaultMaxTextLength caps the number of characters kept from a pasted text.
+	defaultMaxTextLength = 20000
+)
+
+// ProvideTextInput contains a request for a text pasted by the user.
+type ProvideTextInput struct {
+	Question string `json:"question" jsonschema:"description=what text is needed, such as 'Please paste the wishlist you mentioned'"`
+	Purpose  string `json:"purpose,omitempty" jsonschema:"description=what the text is used for, such as 'finding gift ideas'; long texts are summarized with it in mind"`
+}
+
+// DefineProvideTextTool defines the text tool, named "provideText" by default, in the Genkit instance
+// and returns it. Its interrupts are answered by the handler returned by NewProvideTextHandler, which
+// must be registered for the tool name with RegisterToolHandler.
+func DefineProvideTextTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultProvideTextToolName, defaultProvideTextToolDescription, opts)
+
+	return DefineInterruptTool[ProvideTextInput, string](g, config.name, config.description, WithInterruptMetadataKey("text"))
+}
+
+// provideTextHandler configures the handler returned by NewProvideTextHandler.
+type provideTextHandler struct {
+	maxLength        int
+	generator        Generator
+	summaryThreshold int
+}
+
+// ProvideTextOption configures the handler of the text tool.
+type ProvideTextOption func(*provideTextHandler)
+
+// WithMaxTextLength caps the number of characters kept from the pasted text; the rest is dropped with a
+// warning to the user. It defaults to 20000.
+func WithMaxTextLength(maxLength int) ProvideTextOption {
+	return func(h *provideTextHandler) {
+		h.maxLength = maxLength
+	}
+}
+
+// WithTextSummary summarizes texts longer than threshold characters with the generator, so that the
+// model gets the summary instead of the whole text.
+func WithTextSummary(generator Generator, threshold int) ProvideTextOption {
+	return func(h *provideTextHandler) {
+		h.generator = generator
+		h.summaryThreshold = threshold
+	}
+}
+
+// NewProvideTextHandler returns the ToolHandler of the text tool. The question is asked as a multi-line
+// question and asked again while the answer is empty. A text above the maximum length is cut to it,
+// which the user is warned about with Notify, and a text above the summary threshold is summarized by an
+// extra Generate call. It responds with the text or its summary; the metadata holds the length of the
+// pasted text, whether it was truncated, and the length of the summary when there is one.
+func NewProvideTextHandler(opts ...ProvideTextOption) ToolHandler {
+	h := &provideTextHandler{maxLength: defaultMaxTextLength}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h.handle
+}
+
+func (h *provideTextHandler) handle(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var request ProvideTextInput
+	if err := decodeToolInput(input, &request); err != nil {
+		return ToolResult{}, err
+	}
+	if request.Question == "" {
+		return ToolResult{}, errors.New("provide text tool called without a question")
+	}
+
+	question := QuestionInput{Question: request.Question + " (paste the text)", MultiLine: true}
+	answer, err := askParsed(ctx, interactor, question, parseText)
+	if err != nil {
+		return ToolResult{}, err
+	}
+
+	text := []rune(answer.(string))
+	metadata := map[string]any{"length": len(text), "truncated": false}
+	if h.maxLength > 0 && len(text) > h.maxLength {
+		notice := fmt.Sprintf("The text is %d characters long; only the first %d are kept.", len(text), h.maxLength)
+		if err := interactor.Notify(ctx, notice); err != nil {
+			return ToolResult{}, err
+		}
+		text = text[:h.maxLength]
+		metadata["truncated"] = true
+	}
+
+	if h.generator == nil || len(text) <= h.summaryThreshold {
+		return ToolResult{Output: string(text), Metadata: metadata}, nil
+	}
+	summary, err := h.summarize(ctx, request, string(text))
+	if err != nil {
+		return ToolResult{}, err
+	}
+	metadata["summaryLength"] = len([]rune(summary))
+	return ToolResult{Output: summary, Metadata: metadata}, nil
+}
+
+// summarize asks the generator for a summary of the text.
+func (h *provideTextHandler) summarize(ctx context.Context, request ProvideTextInput, text string) (string, error) {
+	prompt := "Summarize the following text, keeping every detail that could matter"
+	if request.Purpose != "" {
+		prompt += " for " + request.Purpose
+	}
+	resp, err := h.generator.Generate(ctx, ai.WithPrompt(prompt+":\n\n"+text))
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize the text: %w", err)
+	}
+	summary := strings.TrimSpace(resp.Text())
+	if summary == "" {
+		return "", errors.New("failed to summarize the text: the summary is empty")
+	}
+	return summary, nil
+}
+
+// parseText trims the pasted text, which can't be empty.
+func parseText(answer string) (any, error) {
+	text := strings.TrimSpace(answer)
+	if text == "" {
+		return nil, errors.New("paste the text, or skip the question")
+	}
+	return text, nil
+}
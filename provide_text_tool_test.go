@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvideTextHandler(t *testing.T) {
+	wishlist := "Lego castle\nDrawing tablet\nFootball boots"
+
+	tests := []struct {
+		name          string
+		opts          func(gen Generator) []ProvideTextOption
+		answers       []Answer
+		expected      ToolResult
+		summarized    bool
+		notifications []string
+	}{
+		{
+			name:     "under the limit",
+			answers:  []Answer{{Value: "\n" + wishlist + "\n"}},
+			expected: ToolResult{Output: wishlist, Metadata: map[string]any{"length": 41, "truncated": false}},
+		},
+		{
+			name:          "empty text asked again",
+			answers:       []Answer{{Value: " \n "}, {Value: wishlist}},
+			expected:      ToolResult{Output: wishlist, Metadata: map[string]any{"length": 41, "truncated": false}},
+			notifications: []string{"Invalid answer: paste the text, or skip the question"},
+		},
+		{
+			name:          "over the limit",
+			opts:          func(Generator) []ProvideTextOption { return []ProvideTextOption{WithMaxTextLength(11)} },
+			answers:       []Answer{{Value: wishlist}},
+			expected:      ToolResult{Output: "Lego castle", Metadata: map[string]any{"length": 41, "truncated": true}},
+			notifications: []string{"The text is 41 characters long; only the first 11 are kept."},
+		},
+		{
+			name: "under the summary threshold",
+			opts: func(gen Generator) []ProvideTextOption {
+				return []ProvideTextOption{WithTextSummary(gen, 100)}
+			},
+			answers:  []Answer{{Value: wishlist}},
+			expected: ToolResult{Output: wishlist, Metadata: map[string]any{"length": 41, "truncated": false}},
+		},
+		{
+			name: "summarized over the threshold",
+			opts: func(gen Generator) []ProvideTextOption {
+				return []ProvideTextOption{WithTextSummary(gen, 20)}
+			},
+			answers:    []Answer{{Value: wishlist}},
+			expected:   ToolResult{Output: "Toys and sports gear", Metadata: map[string]any{"length": 41, "truncated": false, "summaryLength": 20}},
+			summarized: true,
+		},
+		{
+			name: "truncated before the summary",
+			opts: func(gen Generator) []ProvideTextOption {
+				return []ProvideTextOption{WithMaxTextLength(26), WithTextSummary(gen, 20)}
+			},
+			answers:       []Answer{{Value: wishlist}},
+			expected:      ToolResult{Output: "Toys and sports gear", Metadata: map[string]any{"length": 41, "truncated": true, "summaryLength": 20}},
+			summarized:    true,
+			notifications: []string{"The text is 41 characters long; only the first 26 are kept."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse(" Toys and sports gear\n", "stop")}, nil)
+			var opts []ProvideTextOption
+			if tt.opts != nil {
+				opts = tt.opts(mockGen)
+			}
+			interactor := &sequenceInteractor{answers: tt.answers}
+
+			result, err := NewProvideTextHandler(opts...)(context.Background(), interactor, map[string]any{"question": "Please paste the wishlist", "purpose": "finding gift ideas"})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.notifications, interactor.notifications)
+			if !tt.summarized {
+				assert.Empty(t, mockGen.capturedCalls)
+				return
+			}
+			require.Len(t, mockGen.capturedCalls, 1)
+		})
+	}
+}
+
+func TestProvideTextHandler_Errors(t *testing.T) {
+	t.Run("no question", func(t *testing.T) {
+		_, err := NewProvideTextHandler()(context.Background(), &sequenceInteractor{}, map[string]any{})
+
+		assert.EqualError(t, err, "provide text tool called without a question")
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		interactor := &sequenceInteractor{answers: []Answer{{Skipped: true}}}
+
+		_, err := NewProvideTextHandler()(context.Background(), interactor, map[string]any{"question": "Please paste the wishlist"})
+
+		assert.ErrorIs(t, err, ErrQuestionSkipped)
+	})
+
+	t.Run("empty summary", func(t *testing.T) {
+		mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse(" ", "stop")}, nil)
+		interactor := &sequenceInteractor{answers: []Answer{{Value: "Lego castle, drawing tablet"}}}
+
+		_, err := NewProvideTextHandler(WithTextSummary(mockGen, 10))(context.Background(), interactor, map[string]any{"question": "Please paste the wishlist"})
+
+		assert.EqualError(t, err, "failed to summarize the text: the summary is empty")
+	})
+}
+
+func TestProvideTextHandler_ThroughToolHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("provideText", map[string]any{"question": "Please paste the wishlist"})),
+			createTextResponse("A Lego castle...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "provideText": createMockTool("provideText")},
+	)
+	var out bytes.Buffer
+	terminal := NewTerminalReader(ctx, strings.NewReader("Lego castle\n\nDrawing tablet\nEOF\n"), WithAnswerTimeout(time.Second), WithOutput(&out), WithWrapping(false))
+	handler := &InterruptionHandler{generator: mockGen, Interactor: terminal}
+	handler.RegisterToolHandler("provideText", NewProvideTextHandler())
+
+	result, err := RunAgent(ctx, &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	assert.Equal(t, "A Lego castle...", result)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, "Lego castle\n\nDrawing tablet", parts[0].ToolResponse.Output, "the lines are kept as pasted")
+	assert.Equal(t, map[string]any{"interruptResponse": map[string]any{"length": 27, "truncated": false}}, parts[0].Metadata)
+	assert.Contains(t, out.String(), "Please paste the wishlist (paste the text)\n")
+}
+
+func TestDefineProvideTextTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	text := DefineProvideTextTool(g)
+	_, err := text.RunRaw(context.Background(), map[string]any{"question": "Please paste the wishlist", "purpose": "finding gift ideas"})
+
+	assert.Equal(t, "provideText", text.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, ProvideTextInput{Question: "Please paste the wishlist", Purpose: "finding gift ideas"}, metadata["text"])
+}
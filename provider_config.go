@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/firebase/genkit/go/core/api"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/firebase/genkit/go/plugins/compat_oai"
+	"github.com/firebase/genkit/go/plugins/googlegenai"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"google.golang.org/genai"
+)
+
+// The providers a ProviderConfig can set up.
+const (
+	// ProviderGoogleAI calls the Gemini models through the Gemini API with an API key.
+	ProviderGoogleAI = "googleai"
+	// ProviderVertexAI calls the Gemini models through Vertex AI with the Google Cloud credentials of
+	// the environment.
+	ProviderVertexAI = "vertexai"
+	// ProviderOpenAI calls an OpenAI-compatible chat completions API, OpenAI's own by default.
+	ProviderOpenAI = "openai"
+)
+
+// supportedProviders lists the providers in the order they're reported in errors.
+var supportedProviders = []string{ProviderGoogleAI, ProviderVertexAI, ProviderOpenAI}
+
+// providerDefaultModels are the models called when a ProviderConfig leaves the model out.
+var providerDefaultModels = map[string]string{
+	ProviderGoogleAI: "googleai/gemini-2.5-flash",
+	ProviderVertexAI: "vertexai/gemini-2.5-flash",
+}
+
+// ProviderConfig selects the backend of the model calls, such as from the configuration of an
+// environment. Settings left empty are taken from the environment variables of the provider's plugin.
+type ProviderConfig struct {
+	// Provider is one of googleai, vertexai and openai.
+	Provider string `json:"provider" yaml:"provider"`
+	// Model is the default model with the prefix of its provider, such as "openai/gpt-4o-mini". The
+	// Gemini providers call gemini-2.5-flash when it's empty.
+	Model string `json:"model,omitempty" yaml:"model,omitempty"`
+	// APIKey authenticates with googleai and openai; GEMINI_API_KEY or GOOGLE_API_KEY, and
+	// OPENAI_API_KEY are used when it's empty.
+	APIKey string `json:"-" yaml:"-"`
+	// ProjectID and Location are the Google Cloud project and region of vertexai; GOOGLE_CLOUD_PROJECT
+	// and GOOGLE_CLOUD_LOCATION or GOOGLE_CLOUD_REGION are used when they're empty.
+	ProjectID string `json:"projectId,omitempty" yaml:"projectId,omitempty"`
+	Location  string `json:"location,omitempty" yaml:"location,omitempty"`
+	// BaseURL is the address of an OpenAI-compatible API other than OpenAI's, such as a local server,
+	// which may not need an API key.
+	BaseURL string `json:"baseUrl,omitempty" yaml:"baseUrl,omitempty"`
+	// Prefix is the prefix of the model names of an OpenAI-compatible API, such as "groq"; it's
+	// "openai" when empty.
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	// Embedder is the embedder of the provider, with its prefix, such as "googleai/gemini-embedding-001",
+	// which embeds questions to compare them by meaning. Nothing is embedded when it's empty.
+	Embedder string `json:"embedder,omitempty" yaml:"embedder,omitempty"`
+	// SafetySettings are the thresholds of the Gemini safety filters by harm category, such as
+	// BLOCK_ONLY_HIGH for HARM_CATEGORY_HARASSMENT, sent with every call; see ParseSafetySettings.
+	SafetySettings map[string]string `json:"safetySettings,omitempty" yaml:"safetySettings,omitempty"`
+}
+
+// withDefaults returns the config with the default model and prefix of its provider filled in.
+func (c ProviderConfig) withDefaults() ProviderConfig {
+	if c.Provider == ProviderOpenAI && c.Prefix == "" {
+		c.Prefix = ProviderOpenAI
+	}
+	if c.Model == "" {
+		c.Model = providerDefaultModels[c.Provider]
+	}
+	return c
+}
+
+// modelPrefix returns the prefix the model names of the provider start with.
+func (c ProviderConfig) modelPrefix() string {
+	if c.Provider == ProviderOpenAI {
+		return c.Prefix
+	}
+	return c.Provider
+}
+
+// Validate reports the first setting keeping the provider from being set up, such as a missing
+// API key, which the plugins of Genkit would panic on instead.
+func (c ProviderConfig) Validate() error {
+	c = c.withDefaults()
+	switch c.Provider {
+	case ProviderGoogleAI:
+		if c.APIKey == "" && os.Getenv("GEMINI_API_KEY") == "" && os.Getenv("GOOGLE_API_KEY") == "" {
+			return errors.New("googleai needs an API key, or GEMINI_API_KEY or GOOGLE_API_KEY in the environment")
+		}
+	case ProviderVertexAI:
+		if c.ProjectID == "" && os.Getenv("GOOGLE_CLOUD_PROJECT") == "" {
+			return errors.New("vertexai needs a project ID, or GOOGLE_CLOUD_PROJECT in the environment")
+		}
+		if c.Location == "" && os.Getenv("GOOGLE_CLOUD_LOCATION") == "" && os.Getenv("GOOGLE_CLOUD_REGION") == "" {
+			return errors.New("vertexai needs a location, or GOOGLE_CLOUD_LOCATION or GOOGLE_CLOUD_REGION in the environment")
+		}
+	case ProviderOpenAI:
+		if c.APIKey == "" && c.BaseURL == "" && os.Getenv("OPENAI_API_KEY") == "" {
+			return errors.New("openai needs an API key, OPENAI_API_KEY in the environment, or the base URL of a compatible API")
+		}
+	default:
+		return fmt.Errorf("unknown provider %q, supported providers are %s", c.Provider, strings.Join(supportedProviders, ", "))
+	}
+	if c.Model == "" {
+		return fmt.Errorf("%s needs a model", c.Provider)
+	}
+	if prefix := c.modelPrefix() + "/"; !strings.HasPrefix(c.Model, prefix) {
+		return fmt.Errorf("the model %q of %s must start with %q", c.Model, c.Provider, prefix)
+	}
+	if prefix := c.modelPrefix() + "/"; c.Embedder != "" && !strings.HasPrefix(c.Embedder, prefix) {
+		return fmt.Errorf("the embedder %q of %s must start with %q", c.Embedder, c.Provider, prefix)
+	}
+	if len(c.SafetySettings) > 0 {
+		if c.Provider == ProviderOpenAI {
+			return errors.New("safety settings are only supported by googleai and vertexai")
+		}
+		if _, err := safetySettings(c.SafetySettings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// plugin returns the Genkit plugin of a valid config.
+func (c ProviderConfig) plugin() api.Plugin {
+	switch c.Provider {
+	case ProviderVertexAI:
+		return &googlegenai.VertexAI{ProjectID: c.ProjectID, Location: c.Location}
+	case ProviderOpenAI:
+		return &compat_oai.OpenAICompatible{Provider: c.Prefix, APIKey: c.APIKey, BaseURL: c.BaseURL}
+	default:
+		return &googlegenai.GoogleAI{APIKey: c.APIKey}
+	}
+}
+
+// NewGeneratorFromConfig initializes Genkit with the plugin and default model of the provider, and
+// returns a GenkitGenerator calling the model.
+func NewGeneratorFromConfig(ctx context.Context, cfg ProviderConfig) (Generator, error) {
+	generator, err := newGenkitGenerator(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return generator, nil
+}
+
+// newGenkitGenerator is NewGeneratorFromConfig returning the GenkitGenerator, whose Genkit instance the
+// tools are defined with.
+func newGenkitGenerator(ctx context.Context, cfg ProviderConfig) (*GenkitGenerator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg = cfg.withDefaults()
+	settings, err := safetySettings(cfg.SafetySettings)
+	if err != nil {
+		return nil, err
+	}
+	g := genkit.Init(ctx, genkit.WithPlugins(cfg.plugin()), genkit.WithDefaultModel(cfg.Model))
+	if g == nil {
+		return nil, fmt.Errorf("can't init genkit with %s", cfg.Provider)
+	}
+	generator := &GenkitGenerator{AIClient: g, Model: cfg.Model, Embedder: cfg.Embedder, SafetySettings: settings}
+	if clientConfig := cfg.geminiClientConfig(); clientConfig != nil {
+		client, err := genai.NewClient(ctx, clientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("can't create the %s client counting tokens: %w", cfg.Provider, err)
+		}
+		generator.countTokens = geminiTokenCounter(client)
+		generator.lookupModel = geminiModelLookup(client)
+	}
+	if cfg.Provider == ProviderOpenAI {
+		generator.lookupModel = openAIModelLookup(openai.NewClient(cfg.openAIOptions()...))
+	}
+	return generator, nil
+}
+
+// openAIOptions returns the options of the OpenAI client, set as the plugin sets them.
+func (c ProviderConfig) openAIOptions() []option.RequestOption {
+	var opts []option.RequestOption
+	if c.APIKey != "" {
+		opts = append(opts, option.WithAPIKey(c.APIKey))
+	}
+	if c.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(c.BaseURL))
+	}
+	return opts
+}
+
+// geminiClientConfig returns the config of the Gemini API client of a valid config, or nil for a
+// provider other than googleai and vertexai. The client reads the settings left out from the same
+// environment variables as the plugin.
+func (c ProviderConfig) geminiClientConfig() *genai.ClientConfig {
+	switch c.Provider {
+	case ProviderGoogleAI:
+		return &genai.ClientConfig{Backend: genai.BackendGeminiAPI, APIKey: c.APIKey}
+	case ProviderVertexAI:
+		location := c.Location
+		if location == "" && os.Getenv("GOOGLE_CLOUD_LOCATION") == "" {
+			location = os.Getenv("GOOGLE_CLOUD_REGION")
+		}
+		return &genai.ClientConfig{Backend: genai.BackendVertexAI, Project: c.ProjectID, Location: location}
+	default:
+		return nil
+	}
+}
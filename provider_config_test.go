@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/plugins/compat_oai"
+	"github.com/firebase/genkit/go/plugins/googlegenai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearProviderEnv unsets the environment variables the provider plugins read for the test.
+func clearProviderEnv(t *testing.T) {
+	for _, name := range []string{"GEMINI_API_KEY", "GOOGLE_API_KEY", "GOOGLE_CLOUD_PROJECT", "GOOGLE_CLOUD_LOCATION", "GOOGLE_CLOUD_REGION", "OPENAI_API_KEY"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestProviderConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ProviderConfig
+		env  map[string]string
+		err  string
+	}{
+		{name: "googleai", cfg: ProviderConfig{Provider: "googleai", APIKey: "key"}},
+		{name: "googleai key from the environment", cfg: ProviderConfig{Provider: "googleai"}, env: map[string]string{"GEMINI_API_KEY": "key"}},
+		{name: "googleai without a key", cfg: ProviderConfig{Provider: "googleai"}, err: "googleai needs an API key"},
+		{name: "vertexai", cfg: ProviderConfig{Provider: "vertexai", ProjectID: "gifts", Location: "us-central1", Model: "vertexai/gemini-2.5-pro"}},
+		{name: "vertexai region from the environment", cfg: ProviderConfig{Provider: "vertexai", ProjectID: "gifts"}, env: map[string]string{"GOOGLE_CLOUD_REGION": "europe-west4"}},
+		{name: "vertexai without a project", cfg: ProviderConfig{Provider: "vertexai", Location: "us-central1"}, err: "vertexai needs a project ID"},
+		{name: "vertexai without a location", cfg: ProviderConfig{Provider: "vertexai", ProjectID: "gifts"}, err: "vertexai needs a location"},
+		{name: "openai", cfg: ProviderConfig{Provider: "openai", APIKey: "key", Model: "openai/gpt-4o-mini"}},
+		{name: "openai-compatible without a key", cfg: ProviderConfig{Provider: "openai", BaseURL: "http://localhost:11434/v1", Prefix: "local", Model: "local/llama3"}},
+		{name: "openai without a key", cfg: ProviderConfig{Provider: "openai", Model: "openai/gpt-4o-mini"}, err: "openai needs an API key"},
+		{name: "openai without a model", cfg: ProviderConfig{Provider: "openai", APIKey: "key"}, err: "openai needs a model"},
+		{name: "model of another provider", cfg: ProviderConfig{Provider: "openai", APIKey: "key", Model: "googleai/gemini-2.5-flash"}, err: `must start with "openai/"`},
+		{name: "model of another prefix", cfg: ProviderConfig{Provider: "openai", BaseURL: "http://localhost:11434/v1", Prefix: "local", Model: "openai/gpt-4o-mini"}, err: `must start with "local/"`},
+		{name: "embedder", cfg: ProviderConfig{Provider: "googleai", APIKey: "key", Embedder: "googleai/gemini-embedding-001"}},
+		{name: "embedder of another provider", cfg: ProviderConfig{Provider: "openai", APIKey: "key", Model: "openai/gpt-4o-mini", Embedder: "googleai/gemini-embedding-001"}, err: `the embedder "googleai/gemini-embedding-001" of openai must start with "openai/"`},
+		{name: "unknown provider", cfg: ProviderConfig{Provider: "anthropic"}, err: `unknown provider "anthropic", supported providers are googleai, vertexai, openai`},
+		{name: "no provider", cfg: ProviderConfig{}, err: `unknown provider ""`},
+		{name: "safety settings", cfg: ProviderConfig{Provider: "googleai", APIKey: "key", SafetySettings: map[string]string{"HARM_CATEGORY_HARASSMENT": "BLOCK_ONLY_HIGH"}}},
+		{name: "unknown safety setting", cfg: ProviderConfig{Provider: "googleai", APIKey: "key", SafetySettings: map[string]string{"HARM_CATEGORY_HARASSMENT": "NEVER"}}, err: `unknown threshold "NEVER"`},
+		{name: "safety settings of openai", cfg: ProviderConfig{Provider: "openai", APIKey: "key", Model: "openai/gpt-4o-mini", SafetySettings: map[string]string{"HARM_CATEGORY_HARASSMENT": "BLOCK_ONLY_HIGH"}}, err: "safety settings are only supported by googleai and vertexai"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearProviderEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			err := tt.cfg.Validate()
+
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestProviderConfig_Plugin(t *testing.T) {
+	tests := []struct {
+		cfg    ProviderConfig
+		plugin any
+		model  string
+	}{
+		{
+			cfg:    ProviderConfig{Provider: "googleai", APIKey: "key"},
+			plugin: &googlegenai.GoogleAI{APIKey: "key"},
+			model:  "googleai/gemini-2.5-flash",
+		},
+		{
+			cfg:    ProviderConfig{Provider: "vertexai", ProjectID: "gifts", Location: "us-central1"},
+			plugin: &googlegenai.VertexAI{ProjectID: "gifts", Location: "us-central1"},
+			model:  "vertexai/gemini-2.5-flash",
+		},
+		{
+			cfg:    ProviderConfig{Provider: "openai", APIKey: "key", Model: "openai/gpt-4o-mini"},
+			plugin: &compat_oai.OpenAICompatible{Provider: "openai", APIKey: "key"},
+			model:  "openai/gpt-4o-mini",
+		},
+		{
+			cfg:    ProviderConfig{Provider: "openai", BaseURL: "http://localhost:11434/v1", Prefix: "local", Model: "local/llama3"},
+			plugin: &compat_oai.OpenAICompatible{Provider: "local", BaseURL: "http://localhost:11434/v1"},
+			model:  "local/llama3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			cfg := tt.cfg.withDefaults()
+
+			assert.Equal(t, tt.plugin, cfg.plugin())
+			assert.Equal(t, tt.model, cfg.Model)
+		})
+	}
+}
+
+func TestNewGeneratorFromConfig_OpenAICompatible(t *testing.T) {
+	clearProviderEnv(t)
+	var request struct {
+		Model string `json:"model"`
+	}
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		authorization = r.Header.Get("Authorization")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","object":"chat.completion","created":1,"model":"llama3","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"A LEGO set"}}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`))
+	}))
+	defer server.Close()
+
+	generator, err := NewGeneratorFromConfig(context.Background(), ProviderConfig{
+		Provider: "openai",
+		APIKey:   "key",
+		BaseURL:  server.URL,
+		Prefix:   "local",
+		Model:    "local/llama3",
+	})
+	require.NoError(t, err)
+	resp, err := generator.Generate(context.Background(), ai.WithPrompt("Suggest a gift."))
+
+	require.NoError(t, err)
+	assert.Equal(t, "A LEGO set", resp.Text())
+	assert.Equal(t, "local/llama3", responseModel(resp))
+	assert.Equal(t, "llama3", request.Model)
+	assert.Equal(t, "Bearer key", authorization)
+}
+
+func TestNewGeneratorFromConfig_Invalid(t *testing.T) {
+	clearProviderEnv(t)
+
+	generator, err := NewGeneratorFromConfig(context.Background(), ProviderConfig{Provider: "googleai"})
+
+	assert.Nil(t, generator)
+	assert.ErrorContains(t, err, "googleai needs an API key")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+)
+
+// defaultSimilarityThreshold is the cosine similarity from which a SemanticQuestionCache takes two
+// questions to ask the same.
+const defaultSimilarityThreshold = 0.9
+
+// QuestionCache remembers the answers the user gave during a run, so that InterruptionHandler doesn't
+// ask a question again that was answered already.
+type QuestionCache interface {
+	// Lookup returns the answer to the question, or to a question asking the same, and false when there
+	// is none.
+	Lookup(ctx context.Context, question string) (string, bool)
+	Store(ctx context.Context, question, answer string)
+}
+
+// SemanticQuestionCache is a QuestionCache matching questions by meaning, such as "What is your
+// budget?" and "How much do you want to spend?", by the cosine similarity of their embeddings. A
+// question whose embedding fails, such as with a generator without an embedder, is matched by its text,
+// ignoring case and spacing.
+type SemanticQuestionCache struct {
+	generator Generator
+	threshold float64
+
+	mu      sync.Mutex
+	entries []questionEntry
+	// embeddings are the embeddings of the questions by key, nil for a question whose embedding failed,
+	// so that a question looked up and then stored is embedded once.
+	embeddings map[string][]float32
+}
+
+// questionEntry is a question answered before.
+type questionEntry struct {
+	key       string
+	embedding []float32
+	answer    string
+}
+
+// SemanticQuestionCacheOption configures a SemanticQuestionCache.
+type SemanticQuestionCacheOption func(*SemanticQuestionCache)
+
+// WithSimilarityThreshold sets the cosine similarity, from -1 to 1, from which two questions are taken
+// to ask the same; it's 0.9 by default.
+func WithSimilarityThreshold(threshold float64) SemanticQuestionCacheOption {
+	return func(c *SemanticQuestionCache) {
+		c.threshold = threshold
+	}
+}
+
+// NewSemanticQuestionCache creates a SemanticQuestionCache embedding the questions with the generator.
+func NewSemanticQuestionCache(generator Generator, opts ...SemanticQuestionCacheOption) *SemanticQuestionCache {
+	c := &SemanticQuestionCache{
+		generator:  generator,
+		threshold:  defaultSimilarityThreshold,
+		embeddings: make(map[string][]float32),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Lookup returns the answer to the same question, or else to the most similar question at least as
+// similar as the threshold.
+func (c *SemanticQuestionCache) Lookup(ctx context.Context, question string) (string, bool) {
+	key := questionKey(question)
+	c.mu.Lock()
+	for _, entry := range c.entries {
+		if entry.key == key {
+			c.mu.Unlock()
+			return entry.answer, true
+		}
+	}
+	c.mu.Unlock()
+
+	embedding := c.embed(ctx, question, key)
+	if embedding == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	best, bestSimilarity := -1, c.threshold
+	for i, entry := range c.entries {
+		if similarity := cosineSimilarity(embedding, entry.embedding); similarity >= bestSimilarity {
+			best, bestSimilarity = i, similarity
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return c.entries[best].answer, true
+}
+
+// Store remembers the answer to the question, replacing an earlier answer to the same question.
+func (c *SemanticQuestionCache) Store(ctx context.Context, question, answer string) {
+	key := questionKey(question)
+	embedding := c.embed(ctx, question, key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, entry := range c.entries {
+		if entry.key == key {
+			c.entries[i].answer = answer
+			return
+		}
+	}
+	c.entries = append(c.entries, questionEntry{key: key, embedding: embedding, answer: answer})
+}
+
+// embed returns the embedding of the question, embedding it the first time, or nil when its embedding
+// failed, which is logged once.
+func (c *SemanticQuestionCache) embed(ctx context.Context, question, key string) []float32 {
+	c.mu.Lock()
+	embedding, ok := c.embeddings[key]
+	c.mu.Unlock()
+	if ok {
+		return embedding
+	}
+	embedding, err := c.generator.Embed(ctx, question)
+	if err != nil && ctx.Err() != nil {
+		// not remembered, so that the question is embedded again in a context that isn't done
+		return nil
+	}
+	if err != nil {
+		log.Printf("failed to embed the question %q, matching it by its text: %v", question, err)
+		embedding = nil
+	}
+	c.mu.Lock()
+	c.embeddings[key] = embedding
+	c.mu.Unlock()
+	return embedding
+}
+
+// questionKey is the question in lower case with its spacing collapsed, matching a question asked again
+// with another case or spacing.
+func questionKey(question string) string {
+	return strings.ToLower(strings.Join(strings.Fields(question), " "))
+}
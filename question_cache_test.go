@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// budgetEmbeddings are hand-made embeddings of questions about the budget and a question close to them:
+// the cosine similarity to "What is your budget?" is 0.95 for the rephrasing and 0.8 for the other one.
+var budgetEmbeddings = map[string][]float32{
+	"What is your budget?":              {1, 0, 0},
+	"How much do you want to spend?":    {0.95, 0.312, 0},
+	"How much did you spend last year?": {0.8, 0.6, 0},
+	"How old are the children?":         {0, 0, 1},
+}
+
+func TestSemanticQuestionCache_Lookup(t *testing.T) {
+	tests := []struct {
+		name       string
+		question   string
+		embeddings map[string][]float32
+		opts       []SemanticQuestionCacheOption
+		answer     string
+		hit        bool
+		warning    string
+	}{
+		{name: "rephrased", question: "How much do you want to spend?", embeddings: budgetEmbeddings, answer: "$50", hit: true},
+		{name: "near miss", question: "How much did you spend last year?", embeddings: budgetEmbeddings},
+		{name: "near miss under a lower threshold", question: "How much did you spend last year?", embeddings: budgetEmbeddings, opts: []SemanticQuestionCacheOption{WithSimilarityThreshold(0.75)}, answer: "$50", hit: true},
+		{name: "unrelated", question: "How old are the children?", embeddings: budgetEmbeddings},
+		{name: "same text", question: " what is your  BUDGET? ", answer: "$50", hit: true},
+		{name: "rephrased without embeddings", question: "How much do you want to spend?", warning: `failed to embed the question "How much do you want to spend?", matching it by its text`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureLog(t)
+			generator := NewMockGenerator(nil, nil)
+			generator.embeddings = tt.embeddings
+			cache := NewSemanticQuestionCache(generator, tt.opts...)
+			cache.Store(context.Background(), "What is your budget?", "$50")
+
+			answer, ok := cache.Lookup(context.Background(), tt.question)
+
+			assert.Equal(t, tt.hit, ok)
+			assert.Equal(t, tt.answer, answer)
+			assert.Contains(t, out.String(), tt.warning)
+		})
+	}
+}
+
+func TestSemanticQuestionCache_MostSimilar(t *testing.T) {
+	generator := NewMockGenerator(nil, nil)
+	generator.embeddings = map[string][]float32{
+		"What is your budget?":           {1, 0},
+		"What is your monthly budget?":   {0.9, 0.436},
+		"How much do you want to spend?": {0.98, 0.199},
+	}
+	cache := NewSemanticQuestionCache(generator, WithSimilarityThreshold(0.8))
+	cache.Store(context.Background(), "What is your monthly budget?", "$20 a month")
+	cache.Store(context.Background(), "What is your budget?", "$50")
+
+	answer, ok := cache.Lookup(context.Background(), "How much do you want to spend?")
+
+	assert.True(t, ok)
+	assert.Equal(t, "$50", answer)
+}
+
+func TestSemanticQuestionCache_EmbedsOnce(t *testing.T) {
+	generator := NewMockGenerator(nil, nil)
+	generator.embeddings = budgetEmbeddings
+	cache := NewSemanticQuestionCache(generator)
+
+	_, ok := cache.Lookup(context.Background(), "What is your budget?")
+	require.False(t, ok)
+	cache.Store(context.Background(), "What is your budget?", "$50")
+	cache.Store(context.Background(), "What is your budget?", "$80")
+	answer, _ := cache.Lookup(context.Background(), "How much do you want to spend?")
+
+	assert.Equal(t, "$80", answer, "the later answer replaces the earlier one")
+	assert.Equal(t, []string{"What is your budget?", "How much do you want to spend?"}, generator.embedded)
+}
+
+func TestInterruptionHandler_QuestionCache(t *testing.T) {
+	tests := []struct {
+		name    string
+		choices []string
+		asked   []string
+	}{
+		{name: "answered from the cache", asked: []string{"What is your budget?"}},
+		{name: "cached answer not among the strict choices", choices: []string{"Under $20", "$20 to $100"}, asked: []string{"What is your budget?", "How much do you want to spend?"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rephrased := createToolRequestPart("askQuestion", "How much do you want to spend?", tt.choices)
+			if len(tt.choices) > 0 {
+				rephrased.ToolRequest.Input.(map[string]any)["allowFreeText"] = false
+			}
+			mockGen := NewMockGenerator([]*ai.ModelResponse{
+				createInterruptedResponse(createToolRequestPart("askQuestion", "What is your budget?", nil)),
+				createInterruptedResponse(rephrased),
+				createTextResponse("A LEGO set", "stop"),
+			}, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")})
+			mockGen.embeddings = budgetEmbeddings
+			var asked []string
+			var outputs []any
+			handler := &InterruptionHandler{
+				generator: mockGen,
+				UserInteraction: func(_ context.Context, input QuestionInput) (string, error) {
+					asked = append(asked, input.Question)
+					if len(input.Choices) > 0 {
+						return "$20 to $100", nil
+					}
+					return "$50", nil
+				},
+				OnAnswer: func(_ context.Context, answered AnsweredInterrupt) {
+					outputs = append(outputs, answered.Output)
+				},
+				QuestionCache: NewSemanticQuestionCache(mockGen),
+			}
+
+			text, err := RunAgent(context.Background(), &Options{generator: mockGen, userPrompt: "Suggest a gift.", responseHandler: handler})
+
+			require.NoError(t, err)
+			assert.Equal(t, "A LEGO set", text)
+			assert.Equal(t, tt.asked, asked)
+			assert.Equal(t, "$50", outputs[0])
+			assert.Len(t, outputs, 2)
+		})
+	}
+}
+
+func TestInterruptionHandler_QuestionCacheSkipped(t *testing.T) {
+	optional := func() *ai.Part {
+		part := createToolRequestPart("askQuestion", "What is your budget?", nil)
+		part.ToolRequest.Input.(map[string]any)["required"] = false
+		return part
+	}
+	mockGen := NewMockGenerator([]*ai.ModelResponse{
+		createInterruptedResponse(optional()),
+		createInterruptedResponse(optional()),
+		createTextResponse("A LEGO set", "stop"),
+	}, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")})
+	answers := []string{"", "$50"}
+	asked := 0
+	handler := &InterruptionHandler{
+		generator: mockGen,
+		UserInteraction: func(context.Context, QuestionInput) (string, error) {
+			asked++
+			return answers[asked-1], nil
+		},
+		QuestionCache: NewSemanticQuestionCache(mockGen),
+	}
+	captureLog(t)
+
+	_, err := RunAgent(context.Background(), &Options{generator: mockGen, userPrompt: "Suggest a gift.", responseHandler: handler})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, asked, "a skipped question is asked again")
+}
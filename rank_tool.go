@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineRankTool.
+const (
+	defaultRankToolName        = "rankItems"
+	defaultRankToolDescription = "use this to ask the user to put items in order of preference, such as gift categories from most to least appealing; the response is the items re-ordered"
+)
+
+// RankInput contains a question answered by putting the items in order.
+type RankInput struct {
+	Question     string   `json:"question" jsonschema:"description=a question such as 'Rank these gift categories from most to least appealing'"`
+	Items        []string `json:"items" jsonschema:"description=the items to put in order"`
+	AllowPartial bool     `json:"allowPartial,omitempty" jsonschema:"description=set when the user may rank only the first few items; the others keep their order after them"`
+}
+
+// DefineRankTool defines the ranking tool, named "rankItems" by default, in the Genkit instance and
+// returns it. Its interrupts are answered by HandleRank, which must be registered for the tool name
+// with RegisterToolHandler.
+func DefineRankTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultRankToolName, defaultRankToolDescription, opts)
+
+	return DefineInterruptTool[RankInput, []string](g, config.name, config.description, WithInterruptMetadataKey("rank"))
+}
+
+// HandleRank is the ToolHandler of the ranking tool. The items are shown numbered and the answer lists
+// their numbers or texts from first to last, separated by commas or spaces; it is asked again until it
+// ranks every item once, or at least one item without repeats when partial rankings are allowed. It
+// responds with all the items in the ranked order, the unranked ones last in their original order.
+func HandleRank(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var rank RankInput
+	if err := decodeToolInput(input, &rank); err != nil {
+		return ToolResult{}, err
+	}
+	if err := rank.validate(); err != nil {
+		return ToolResult{}, err
+	}
+
+	question := QuestionInput{Question: rank.Question + " " + rank.hint(), Choices: rank.Items}
+	ranked, err := askParsed(ctx, interactor, question, rank.parse)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	return ToolResult{Output: ranked}, nil
+}
+
+// validate reports inputs that can't be ranked.
+func (r RankInput) validate() error {
+	switch {
+	case r.Question == "":
+		return errors.New("rank tool called without a question")
+	case len(r.Items) < 2:
+		return errors.New("rank tool called with fewer than 2 items")
+	}
+	for i, item := range r.Items {
+		if slices.Contains(r.Items[:i], item) {
+			return fmt.Errorf("rank tool called with %q twice", item)
+		}
+	}
+	return nil
+}
+
+// hint tells the user how to answer.
+func (r RankInput) hint() string {
+	if r.AllowPartial {
+		return "(list the numbers from first to last, such as 3,1; items left out keep their order after them)"
+	}
+	return "(list all the numbers from first to last, such as 3,1,2)"
+}
+
+// parse turns the ranked numbers and texts of the items, separated by commas or else by spaces, into
+// the re-ordered items.
+func (r RankInput) parse(answer string) (any, error) {
+	// items with spaces in their text can only be ranked by text in a comma-separated answer
+	fields := strings.Fields(answer)
+	if strings.Contains(answer, ",") {
+		fields = slices.DeleteFunc(strings.Split(answer, ","), func(field string) bool { return strings.TrimSpace(field) == "" })
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("expected the numbers of the items from first to last, such as 3,1,2")
+	}
+
+	ranked := make([]string, 0, len(r.Items))
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		item, ok := matchChoice(r.Items, field)
+		if !ok {
+			n, err := strconv.Atoi(field)
+			if err != nil || n < 1 || n > len(r.Items) {
+				return nil, fmt.Errorf("%q is not one of the items", field)
+			}
+			item = r.Items[n-1]
+		}
+		if slices.Contains(ranked, item) {
+			return nil, fmt.Errorf("%s is ranked twice", item)
+		}
+		ranked = append(ranked, item)
+	}
+
+	if len(ranked) < len(r.Items) {
+		if !r.AllowPartial {
+			return nil, fmt.Errorf("rank all %d items", len(r.Items))
+		}
+		for _, item := range r.Items {
+			if !slices.Contains(ranked, item) {
+				ranked = append(ranked, item)
+			}
+		}
+	}
+	return ranked, nil
+}
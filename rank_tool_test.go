@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankInput_Parse(t *testing.T) {
+	items := []string{"Board games", "Books", "Outdoor toys"}
+
+	tests := []struct {
+		name     string
+		input    RankInput
+		answer   string
+		expected []string
+		err      string
+	}{
+		{name: "permutation", input: RankInput{Items: items}, answer: "3,1,2", expected: []string{"Outdoor toys", "Board games", "Books"}},
+		{name: "spaces", input: RankInput{Items: items}, answer: " 2 3  1 ", expected: []string{"Books", "Outdoor toys", "Board games"}},
+		{name: "texts and numbers", input: RankInput{Items: items}, answer: "books, 3, board games", expected: []string{"Books", "Outdoor toys", "Board games"}},
+		{name: "duplicate index", input: RankInput{Items: items}, answer: "3,1,3", err: "Outdoor toys is ranked twice"},
+		{name: "duplicate by text", input: RankInput{Items: items}, answer: "2, books, 1", err: "Books is ranked twice"},
+		{name: "missing item", input: RankInput{Items: items}, answer: "3,1", err: "rank all 3 items"},
+		{name: "number out of range", input: RankInput{Items: items}, answer: "4,1,2", err: `"4" is not one of the items`},
+		{name: "nothing", input: RankInput{Items: items}, answer: " , ", err: "expected the numbers of the items from first to last, such as 3,1,2"},
+		{name: "partial", input: RankInput{Items: items, AllowPartial: true}, answer: "3", expected: []string{"Outdoor toys", "Board games", "Books"}},
+		{name: "partial keeps the original order", input: RankInput{Items: items, AllowPartial: true}, answer: "2,", expected: []string{"Books", "Board games", "Outdoor toys"}},
+		{name: "partial still rejects duplicates", input: RankInput{Items: items, AllowPartial: true}, answer: "2,2", err: "Books is ranked twice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked, err := tt.input.parse(tt.answer)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ranked)
+		})
+	}
+}
+
+func TestRankInput_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input RankInput
+		err   string
+	}{
+		{name: "valid", input: RankInput{Question: "Rank", Items: []string{"A", "B"}}},
+		{name: "no question", input: RankInput{Items: []string{"A", "B"}}, err: "without a question"},
+		{name: "single item", input: RankInput{Question: "Rank", Items: []string{"A"}}, err: "fewer than 2 items"},
+		{name: "repeated item", input: RankInput{Question: "Rank", Items: []string{"A", "B", "A"}}, err: `"A" twice`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.input.validate()
+
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestHandleRank_ThroughToolHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("rankItems", map[string]any{
+				"question": "Rank these gift categories from most to least appealing",
+				"items":    []any{"Board games", "Books", "Outdoor toys"},
+			})),
+			createTextResponse("A garden treasure hunt kit...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "rankItems": createMockTool("rankItems")},
+	)
+	var out bytes.Buffer
+	// a repeated number is asked again
+	terminal := NewTerminalReader(ctx, strings.NewReader("3,3,1\n3,1,2\n"), WithAnswerTimeout(time.Second), WithOutput(&out), WithWrapping(false))
+	handler := &InterruptionHandler{generator: mockGen, Interactor: terminal}
+	handler.RegisterToolHandler("rankItems", HandleRank)
+
+	result, err := RunAgent(ctx, &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	assert.Equal(t, "A garden treasure hunt kit...", result)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, []string{"Outdoor toys", "Board games", "Books"}, parts[0].ToolResponse.Output)
+	assert.Contains(t, out.String(), "Rank these gift categories from most to least appealing (list all the numbers from first to last, such as 3,1,2)\n1) Board games\n2) Books\n3) Outdoor toys\n")
+	assert.Contains(t, out.String(), "Invalid answer: Outdoor toys is ranked twice\n")
+}
+
+func TestDefineRankTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	rank := DefineRankTool(g)
+	_, err := rank.RunRaw(context.Background(), map[string]any{"question": "Rank", "items": []string{"A", "B"}, "allowPartial": true})
+
+	assert.Equal(t, "rankItems", rank.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, RankInput{Question: "Rank", Items: []string{"A", "B"}, AllowPartial: true}, metadata["rank"])
+}
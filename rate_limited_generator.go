@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitWait describes a model call waiting for the rate limit, reported to the callback set with
+// WithRateLimitObserver before the call waits.
+type RateLimitWait struct {
+	// Delay is how long the call waits.
+	Delay time.Duration
+	// Waiting counts the calls waiting, this one included.
+	Waiting int
+}
+
+// RateLimitedGenerator is a Generator capping the rate of the model calls of another one. A single
+// RateLimitedGenerator is safe to share between concurrent sessions, which then share its limit.
+type RateLimitedGenerator struct {
+	inner   Generator
+	limiter *rate.Limiter
+	observe func(RateLimitWait)
+	waiting atomic.Int64
+	// now and sleep are replaced in tests by a fake clock.
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// RateLimitOption configures a RateLimitedGenerator.
+type RateLimitOption func(*RateLimitedGenerator)
+
+// WithRateLimitObserver calls observe before every call that has to wait, for example to export how
+// long calls wait as metrics.
+func WithRateLimitObserver(observe func(RateLimitWait)) RateLimitOption {
+	return func(g *RateLimitedGenerator) {
+		g.observe = observe
+	}
+}
+
+// NewRateLimitedGenerator returns a Generator calling inner at most limit times per second, with bursts
+// of up to burst calls; use rate.Every(time.Minute / n) for n requests per minute. Generate,
+// GenerateStream, GenerateBool and GenerateJSON wait for their turn, or until ctx is done.
+func NewRateLimitedGenerator(inner Generator, limit rate.Limit, burst int, opts ...RateLimitOption) *RateLimitedGenerator {
+	g := &RateLimitedGenerator{
+		inner:   inner,
+		limiter: rate.NewLimiter(limit, burst),
+		now:     time.Now,
+		sleep:   sleepContext,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Generate waits for the rate limit and calls the inner Generate.
+func (g *RateLimitedGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	if err := g.wait(ctx); err != nil {
+		return nil, err
+	}
+	return g.inner.Generate(ctx, opts...)
+}
+
+// GenerateStream waits for the rate limit and calls the inner GenerateStream.
+func (g *RateLimitedGenerator) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	if err := g.wait(ctx); err != nil {
+		return nil, err
+	}
+	return g.inner.GenerateStream(ctx, cb, opts...)
+}
+
+// GenerateBool waits for the rate limit and calls the inner GenerateBool.
+func (g *RateLimitedGenerator) GenerateBool(ctx context.Context, prompt string, history []*ai.Message) (bool, error) {
+	if err := g.wait(ctx); err != nil {
+		return false, err
+	}
+	return g.inner.GenerateBool(ctx, prompt, history)
+}
+
+// GenerateChecklist waits for the rate limit and calls the inner GenerateChecklist.
+func (g *RateLimitedGenerator) GenerateChecklist(ctx context.Context, questions []string, history []*ai.Message) (map[string]bool, error) {
+	if err := g.wait(ctx); err != nil {
+		return nil, err
+	}
+	return g.inner.GenerateChecklist(ctx, questions, history)
+}
+
+// GenerateJSON waits for the rate limit and calls the inner GenerateJSON.
+func (g *RateLimitedGenerator) GenerateJSON(ctx context.Context, system string, history []*ai.Message, out any) error {
+	if err := g.wait(ctx); err != nil {
+		return err
+	}
+	return g.inner.GenerateJSON(ctx, system, history, out)
+}
+
+// LookupTool looks up the tool with the inner generator.
+func (g *RateLimitedGenerator) LookupTool(name string) ai.Tool {
+	return g.inner.LookupTool(name)
+}
+
+// CountTokens counts the tokens with the inner generator, without waiting for the rate limit.
+func (g *RateLimitedGenerator) CountTokens(ctx context.Context, messages []*ai.Message) (TokenCount, error) {
+	return g.inner.CountTokens(ctx, messages)
+}
+
+// Embed embeds the text with the inner generator, without waiting for the rate limit of the model.
+func (g *RateLimitedGenerator) Embed(ctx context.Context, text string) ([]float32, error) {
+	return g.inner.Embed(ctx, text)
+}
+
+// Ping pings the inner generator, without waiting for the rate limit or taking a turn.
+func (g *RateLimitedGenerator) Ping(ctx context.Context) error {
+	return ping(ctx, g.inner)
+}
+
+// wait reserves a call and waits for its turn. A call whose context is done while waiting gives its
+// turn back, so that the calls after it don't wait for it.
+func (g *RateLimitedGenerator) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := g.now()
+	reservation := g.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return fmt.Errorf("the rate limit allows no calls with a burst of %d", g.limiter.Burst())
+	}
+	delay := reservation.DelayFrom(now)
+	if delay <= 0 {
+		return nil
+	}
+
+	waiting := g.waiting.Add(1)
+	defer g.waiting.Add(-1)
+	if g.observe != nil {
+		g.observe(RateLimitWait{Delay: delay, Waiting: int(waiting)})
+	}
+	if err := g.sleep(ctx, delay); err != nil {
+		reservation.CancelAt(g.now())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// fakeClock is a clock whose sleeps move it forward at once.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+	// cancelSleeps makes sleeps fail as if the context were cancelled while waiting.
+	cancelSleeps bool
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) sleep(_ context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	if c.cancelSleeps {
+		return context.Canceled
+	}
+	c.now = c.now.Add(d)
+	return nil
+}
+
+// newTestRateLimitedGenerator returns a RateLimitedGenerator timed by the clock.
+func newTestRateLimitedGenerator(inner Generator, limit rate.Limit, burst int, clock *fakeClock, opts ...RateLimitOption) *RateLimitedGenerator {
+	generator := NewRateLimitedGenerator(inner, limit, burst, opts...)
+	generator.now = clock.Now
+	generator.sleep = clock.sleep
+	return generator
+}
+
+func TestRateLimitedGenerator_Spacing(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC)}
+	inner := &flakyGenerator{}
+	var waits []RateLimitWait
+	// 2 calls per second, so calls after the first are 500ms apart
+	generator := newTestRateLimitedGenerator(inner, rate.Every(500*time.Millisecond), 1, clock,
+		WithRateLimitObserver(func(wait RateLimitWait) { waits = append(waits, wait) }))
+
+	_, err := generator.Generate(context.Background())
+	require.NoError(t, err)
+	_, err = generator.GenerateBool(context.Background(), "Is it finished?", nil)
+	require.NoError(t, err)
+	var count int
+	require.NoError(t, generator.GenerateJSON(context.Background(), "How many gifts?", nil, &count))
+	var texts []string
+	_, err = generator.GenerateStream(context.Background(), chunkTexts(&texts))
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, inner.calls)
+	assert.Equal(t, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond}, clock.sleeps)
+	assert.Len(t, waits, 3)
+	assert.Equal(t, RateLimitWait{Delay: 500 * time.Millisecond, Waiting: 1}, waits[0])
+}
+
+func TestRateLimitedGenerator_Burst(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC)}
+	generator := newTestRateLimitedGenerator(&flakyGenerator{}, rate.Every(time.Minute/60), 3, clock)
+
+	for range 4 {
+		_, err := generator.Generate(context.Background())
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []time.Duration{time.Second}, clock.sleeps, "the burst goes through without waiting")
+}
+
+func TestRateLimitedGenerator_CancelledWhileWaiting(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 12, 1, 9, 0, 0, 0, time.UTC)}
+	inner := &flakyGenerator{}
+	generator := newTestRateLimitedGenerator(inner, rate.Every(time.Second), 1, clock)
+	_, err := generator.Generate(context.Background())
+	require.NoError(t, err)
+
+	clock.cancelSleeps = true
+	_, err = generator.Generate(context.Background())
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, inner.calls, "the cancelled call doesn't reach the model")
+
+	clock.cancelSleeps = false
+	_, err = generator.Generate(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, clock.sleeps, "the cancelled call gave its turn back")
+}
+
+func TestRateLimitedGenerator_RealClock(t *testing.T) {
+	generator := NewRateLimitedGenerator(&flakyGenerator{}, rate.Every(time.Hour), 1)
+	_, err := generator.Generate(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = generator.Generate(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "askQuestion", generator.LookupTool("askQuestion").Name())
+}
+
+func TestRateLimitedGenerator_Concurrent(t *testing.T) {
+	// the mock answers GenerateBool without changing its state once it has no scripted answers
+	generator := NewRateLimitedGenerator(&MockGenerator{}, rate.Every(time.Millisecond), 2)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			_, err := generator.GenerateBool(context.Background(), "Is it finished?", nil)
+			assert.NoError(t, err)
+		})
+	}
+	wg.Wait()
+}
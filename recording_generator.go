@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// Kinds of the recorded generator calls.
+const (
+	callGenerate          = "generate"
+	callGenerateStream    = "generateStream"
+	callGenerateBool      = "generateBool"
+	callGenerateChecklist = "generateChecklist"
+	callGenerateJSON      = "generateJSON"
+)
+
+// recordedCall is a line of a recording: a generator call and its result.
+type recordedCall struct {
+	Kind string `json:"kind"`
+	// Digest identifies the arguments of the call, so that a replay notices a diverging conversation.
+	Digest   string                   `json:"digest"`
+	Response *ai.ModelResponse        `json:"response,omitempty"`
+	Chunks   []*ai.ModelResponseChunk `json:"chunks,omitempty"`
+	// Output is the result of GenerateBool, GenerateChecklist and GenerateJSON.
+	Output json.RawMessage `json:"output,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// RecordingGenerator is a Generator writing every call of another one, with its response, to a JSONL
+// recording that a ReplayGenerator can serve offline.
+type RecordingGenerator struct {
+	inner Generator
+	mu    sync.Mutex
+	w     io.Writer
+}
+
+// NewRecordingGenerator returns a Generator calling inner and writing a line to w for every call: its
+// kind, a digest of its arguments, and the full response, the chunks streamed, the decoded output or the
+// error. A call whose line can't be written fails.
+func NewRecordingGenerator(inner Generator, w io.Writer) *RecordingGenerator {
+	return &RecordingGenerator{inner: inner, w: w}
+}
+
+// Generate calls the inner Generate and records the call.
+func (r *RecordingGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	call, err := newOptionsCall(ctx, callGenerate, opts)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.inner.Generate(ctx, opts...)
+	call.Response = resp
+	return resp, r.record(call, err)
+}
+
+// GenerateStream calls the inner GenerateStream and records the call with its chunks.
+func (r *RecordingGenerator) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	call, err := newOptionsCall(ctx, callGenerateStream, opts)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.inner.GenerateStream(ctx, func(chunk *ai.ModelResponseChunk) error {
+		call.Chunks = append(call.Chunks, chunk)
+		return cb(chunk)
+	}, opts...)
+	call.Response = resp
+	return resp, r.record(call, err)
+}
+
+// GenerateBool calls the inner GenerateBool and records the call.
+func (r *RecordingGenerator) GenerateBool(ctx context.Context, prompt string, history []*ai.Message) (bool, error) {
+	call, err := newCall(callGenerateBool, map[string]any{"prompt": prompt, "history": history})
+	if err != nil {
+		return false, err
+	}
+	result, err := r.inner.GenerateBool(ctx, prompt, history)
+	if err == nil {
+		call.Output, err = json.Marshal(result)
+	}
+	return result, r.record(call, err)
+}
+
+// GenerateChecklist calls the inner GenerateChecklist and records the call.
+func (r *RecordingGenerator) GenerateChecklist(ctx context.Context, questions []string, history []*ai.Message) (map[string]bool, error) {
+	call, err := newCall(callGenerateChecklist, map[string]any{"questions": questions, "history": history})
+	if err != nil {
+		return nil, err
+	}
+	answers, err := r.inner.GenerateChecklist(ctx, questions, history)
+	if err == nil {
+		call.Output, err = json.Marshal(answers)
+	}
+	return answers, r.record(call, err)
+}
+
+// GenerateJSON calls the inner GenerateJSON and records the call.
+func (r *RecordingGenerator) GenerateJSON(ctx context.Context, system string, history []*ai.Message, out any) error {
+	call, err := newCall(callGenerateJSON, map[string]any{"system": system, "history": history, "type": fmt.Sprintf("%T", out)})
+	if err != nil {
+		return err
+	}
+	err = r.inner.GenerateJSON(ctx, system, history, out)
+	if err == nil {
+		call.Output, err = json.Marshal(out)
+	}
+	return r.record(call, err)
+}
+
+// LookupTool looks up the tool with the inner generator.
+func (r *RecordingGenerator) LookupTool(name string) ai.Tool {
+	return r.inner.LookupTool(name)
+}
+
+// CountTokens counts the tokens with the inner generator, without recording it.
+func (r *RecordingGenerator) CountTokens(ctx context.Context, messages []*ai.Message) (TokenCount, error) {
+	return r.inner.CountTokens(ctx, messages)
+}
+
+// Embed embeds the text with the inner generator, without recording it.
+func (r *RecordingGenerator) Embed(ctx context.Context, text string) ([]float32, error) {
+	return r.inner.Embed(ctx, text)
+}
+
+// Ping pings the inner generator, without recording it.
+func (r *RecordingGenerator) Ping(ctx context.Context) error {
+	return ping(ctx, r.inner)
+}
+
+// record writes the call with the error it failed with, and returns the error, or the error of writing.
+func (r *RecordingGenerator) record(call *recordedCall, callErr error) error {
+	if callErr != nil {
+		call.Error = callErr.Error()
+	}
+	line, err := json.Marshal(call)
+	if err != nil {
+		return fmt.Errorf("failed to record the model call: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to record the model call: %w", err)
+	}
+	return callErr
+}
+
+// ErrReplayDiverged is returned by a ReplayGenerator called differently than the recorded session.
+type ErrReplayDiverged struct {
+	// Call counts the calls of the replay, from 1.
+	Call int
+	// Expected describes the recorded call, and is empty when the recording has no more calls.
+	Expected string
+	// Got describes the call made.
+	Got string
+}
+
+func (e *ErrReplayDiverged) Error() string {
+	if e.Expected == "" {
+		return fmt.Sprintf("replay diverged at call %d: the recording has no more calls, got %s", e.Call, e.Got)
+	}
+	return fmt.Sprintf("replay diverged at call %d: the recording has %s, got %s", e.Call, e.Expected, e.Got)
+}
+
+// ReplayGenerator is a Generator serving the calls of a recording made by RecordingGenerator instead of
+// calling a model, to debug a session offline.
+type ReplayGenerator struct {
+	mu    sync.Mutex
+	calls []*recordedCall
+	// served marks the calls already replayed.
+	served   []bool
+	replayed int
+	matching bool
+	lookup   func(name string) ai.Tool
+}
+
+// ReplayOption configures a ReplayGenerator.
+type ReplayOption func(*ReplayGenerator)
+
+// WithReplayMatching serves each call with the first recorded call of the same kind and digest that
+// wasn't replayed yet, instead of the recorded calls in order, for sessions whose calls may come in a
+// different order, such as concurrent ones.
+func WithReplayMatching() ReplayOption {
+	return func(g *ReplayGenerator) {
+		g.matching = true
+	}
+}
+
+// WithReplayTools looks up the tools, which the recording doesn't hold, with lookup, such as
+// genkit.LookupTool of the Genkit instance the tools are defined in.
+func WithReplayTools(lookup func(name string) ai.Tool) ReplayOption {
+	return func(g *ReplayGenerator) {
+		g.lookup = lookup
+	}
+}
+
+// NewReplayGenerator returns a Generator serving the calls recorded in r. A call that differs from the
+// recorded one, by kind or arguments, fails with ErrReplayDiverged, as do calls beyond the recording.
+func NewReplayGenerator(r io.Reader, opts ...ReplayOption) (*ReplayGenerator, error) {
+	g := &ReplayGenerator{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var call recordedCall
+		if err := json.Unmarshal(scanner.Bytes(), &call); err != nil {
+			return nil, fmt.Errorf("recording line %d: %w", line, err)
+		}
+		g.calls = append(g.calls, &call)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the recording: %w", err)
+	}
+	g.served = make([]bool, len(g.calls))
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g, nil
+}
+
+// Generate returns the recorded response of the call.
+func (g *ReplayGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	call, err := newOptionsCall(ctx, callGenerate, opts)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := g.next(call)
+	if err != nil {
+		return nil, err
+	}
+	return recorded.Response, recorded.err()
+}
+
+// GenerateStream passes the recorded chunks of the call to cb and returns its recorded response.
+func (g *ReplayGenerator) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	call, err := newOptionsCall(ctx, callGenerateStream, opts)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := g.next(call)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range recorded.Chunks {
+		if err := cb(chunk); err != nil {
+			return nil, err
+		}
+	}
+	return recorded.Response, recorded.err()
+}
+
+// GenerateBool returns the recorded answer of the call.
+func (g *ReplayGenerator) GenerateBool(_ context.Context, prompt string, history []*ai.Message) (bool, error) {
+	call, err := newCall(callGenerateBool, map[string]any{"prompt": prompt, "history": history})
+	if err != nil {
+		return false, err
+	}
+	recorded, err := g.next(call)
+	if err != nil {
+		return false, err
+	}
+	if err := recorded.err(); err != nil {
+		return false, err
+	}
+	var result bool
+	err = json.Unmarshal(recorded.Output, &result)
+	return result, err
+}
+
+// GenerateChecklist returns the recorded answers of the call.
+func (g *ReplayGenerator) GenerateChecklist(_ context.Context, questions []string, history []*ai.Message) (map[string]bool, error) {
+	call, err := newCall(callGenerateChecklist, map[string]any{"questions": questions, "history": history})
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := g.next(call)
+	if err != nil {
+		return nil, err
+	}
+	if err := recorded.err(); err != nil {
+		return nil, err
+	}
+	var answers map[string]bool
+	err = json.Unmarshal(recorded.Output, &answers)
+	return answers, err
+}
+
+// GenerateJSON decodes the recorded output of the call into out.
+func (g *ReplayGenerator) GenerateJSON(_ context.Context, system string, history []*ai.Message, out any) error {
+	call, err := newCall(callGenerateJSON, map[string]any{"system": system, "history": history, "type": fmt.Sprintf("%T", out)})
+	if err != nil {
+		return err
+	}
+	recorded, err := g.next(call)
+	if err != nil {
+		return err
+	}
+	if err := recorded.err(); err != nil {
+		return err
+	}
+	return json.Unmarshal(recorded.Output, out)
+}
+
+// LookupTool looks up the tool with the lookup set by WithReplayTools, and returns nil without it.
+func (g *ReplayGenerator) LookupTool(name string) ai.Tool {
+	if g.lookup == nil {
+		return nil
+	}
+	return g.lookup(name)
+}
+
+// CountTokens estimates the tokens, since a recording has no model to count them with.
+func (g *ReplayGenerator) CountTokens(_ context.Context, messages []*ai.Message) (TokenCount, error) {
+	return estimateTokens(messages), nil
+}
+
+// Embed fails with ErrNoEmbedder: embeddings aren't recorded, so a replayed run matches questions by
+// their text.
+func (g *ReplayGenerator) Embed(context.Context, string) ([]float32, error) {
+	return nil, ErrNoEmbedder
+}
+
+// next returns the recorded call serving the call.
+func (g *ReplayGenerator) next(call *recordedCall) (*recordedCall, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.replayed++
+	for i, recorded := range g.calls {
+		if g.served[i] {
+			continue
+		}
+		if recorded.Kind == call.Kind && recorded.Digest == call.Digest {
+			g.served[i] = true
+			return recorded, nil
+		}
+		if !g.matching {
+			return nil, &ErrReplayDiverged{Call: g.replayed, Expected: recorded.describe(), Got: call.describe()}
+		}
+	}
+	return nil, &ErrReplayDiverged{Call: g.replayed, Got: call.describe()}
+}
+
+// newCall returns a call of the kind whose digest is that of args.
+func newCall(kind string, args any) (*recordedCall, error) {
+	d, err := digest(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to digest the %s call: %w", kind, err)
+	}
+	return &recordedCall{Kind: kind, Digest: d}, nil
+}
+
+// newOptionsCall returns a call of the kind whose digest is that of the generate options.
+func newOptionsCall(ctx context.Context, kind string, opts []ai.GenerateOption) (*recordedCall, error) {
+	fields := make([]map[string]any, 0, len(opts))
+	for _, opt := range opts {
+		set := map[string]any{}
+		if err := optionFields(ctx, reflect.ValueOf(opt), set); err != nil {
+			return nil, fmt.Errorf("failed to digest the %s call: %w", kind, err)
+		}
+		fields = append(fields, set)
+	}
+	return newCall(kind, fields)
+}
+
+// optionFields adds the fields a generate option sets to set, with the messages and prompts resolved and
+// the model and tools by name; callbacks, such as the streaming callback and middleware, are left out.
+// Genkit doesn't expose the options it's given, so they are read with reflection.
+func optionFields(ctx context.Context, v reflect.Value, set map[string]any) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := range v.NumField() {
+		field, value := v.Type().Field(i), v.Field(i)
+		if field.Anonymous {
+			if err := optionFields(ctx, value, set); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() || value.IsZero() {
+			continue
+		}
+		switch x := value.Interface().(type) {
+		case ai.MessagesFn:
+			messages, err := x(ctx, nil)
+			if err != nil {
+				return err
+			}
+			set[field.Name] = messages
+		case ai.PromptFn:
+			text, err := x(ctx, nil)
+			if err != nil {
+				return err
+			}
+			set[field.Name] = text
+		case []ai.ToolRef:
+			names := make([]string, len(x))
+			for i, tool := range x {
+				names[i] = tool.Name()
+			}
+			set[field.Name] = names
+		default:
+			if field.Type == reflect.TypeFor[ai.ModelArg]() {
+				set[field.Name] = x.(ai.ModelArg).Name()
+				continue
+			}
+			if value.Kind() == reflect.Func || (value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Func) {
+				continue
+			}
+			set[field.Name] = x
+		}
+	}
+	return nil
+}
+
+// describe describes the call in divergence errors.
+func (c *recordedCall) describe() string {
+	return fmt.Sprintf("a %s call with digest %.12s", c.Kind, c.Digest)
+}
+
+// err returns the error the recorded call failed with.
+func (c *recordedCall) err() error {
+	if c.Error == "" {
+		return nil
+	}
+	return errors.New(c.Error)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMultiQuestionGenerator returns a mock generator asking two questions at once, then a third one,
+// and then answering, with the conversation loop finishing at once.
+func newMultiQuestionGenerator() *MockGenerator {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createToolRequestPart("askQuestion", "What are their ages?", nil),
+			),
+			createInterruptedResponse(createToolRequestPart("askQuestion", "What is the budget?", nil)),
+			createTextResponse("A LEGO set and a kite", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	mockGen.boolResponses = []bool{true}
+	return mockGen
+}
+
+// runMultiQuestion runs the multi-question scenario of newMultiQuestionGenerator with the generator,
+// giving the answers.
+func runMultiQuestion(generator Generator, answers ...string) (string, error) {
+	interactor := &sequenceInteractor{}
+	for _, answer := range answers {
+		interactor.answers = append(interactor.answers, Answer{Value: answer})
+	}
+	return RunAgent(context.Background(), &Options{
+		generator:       generator,
+		userPrompt:      "Suggest gifts.",
+		toolNames:       []string{"askQuestion"},
+		responseHandler: NewInteractorConversationLoopHandler(generator, "Is the conversation finished?", interactor, "askQuestion"),
+	})
+}
+
+func TestRecordingGenerator_RoundTrip(t *testing.T) {
+	mockGen := newMultiQuestionGenerator()
+	var recording bytes.Buffer
+	result, err := runMultiQuestion(NewRecordingGenerator(mockGen, &recording), "Both", "8 and 11", "$50")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(recording.String()), "\n")
+	require.Len(t, lines, 4, "3 Generate calls and a GenerateBool call")
+	assert.Contains(t, lines[3], `"kind":"generateBool"`)
+
+	replay, err := NewReplayGenerator(bytes.NewReader(recording.Bytes()), WithReplayTools(mockGen.LookupTool))
+	require.NoError(t, err)
+	var replayed bytes.Buffer
+	replayResult, err := runMultiQuestion(NewRecordingGenerator(replay, &replayed), "Both", "8 and 11", "$50")
+
+	require.NoError(t, err)
+	assert.Equal(t, result, replayResult)
+	assert.Equal(t, recording.String(), replayed.String(), "the replay makes the recorded calls byte for byte")
+}
+
+func TestReplayGenerator_Diverged(t *testing.T) {
+	var recording bytes.Buffer
+	mockGen := newMultiQuestionGenerator()
+	_, err := runMultiQuestion(NewRecordingGenerator(mockGen, &recording), "Both", "8 and 11", "$50")
+	require.NoError(t, err)
+	replay, err := NewReplayGenerator(strings.NewReader(recording.String()), WithReplayTools(mockGen.LookupTool))
+	require.NoError(t, err)
+
+	_, err = runMultiQuestion(replay, "Boy", "8 and 11", "$50")
+
+	var diverged *ErrReplayDiverged
+	require.ErrorAs(t, err, &diverged)
+	assert.Equal(t, 2, diverged.Call, "the answers the model gets differ")
+	assert.Contains(t, err.Error(), "replay diverged at call 2: the recording has a generate call with digest ")
+}
+
+func TestReplayGenerator_Order(t *testing.T) {
+	mockGen := &MockGenerator{boolResponses: []bool{true, false}}
+	var recording bytes.Buffer
+	recorder := NewRecordingGenerator(mockGen, &recording)
+	_, err := recorder.GenerateBool(context.Background(), "Is it finished?", nil)
+	require.NoError(t, err)
+	_, err = recorder.GenerateBool(context.Background(), "Is it a toy?", nil)
+	require.NoError(t, err)
+
+	t.Run("in order", func(t *testing.T) {
+		replay, err := NewReplayGenerator(strings.NewReader(recording.String()))
+		require.NoError(t, err)
+
+		_, err = replay.GenerateBool(context.Background(), "Is it a toy?", nil)
+
+		var diverged *ErrReplayDiverged
+		require.ErrorAs(t, err, &diverged)
+		assert.Equal(t, 1, diverged.Call)
+	})
+
+	t.Run("matching", func(t *testing.T) {
+		replay, err := NewReplayGenerator(strings.NewReader(recording.String()), WithReplayMatching())
+		require.NoError(t, err)
+
+		toy, err := replay.GenerateBool(context.Background(), "Is it a toy?", nil)
+		require.NoError(t, err)
+		finished, err := replay.GenerateBool(context.Background(), "Is it finished?", nil)
+		require.NoError(t, err)
+		_, err = replay.GenerateBool(context.Background(), "Is it finished?", nil)
+
+		assert.False(t, toy)
+		assert.True(t, finished)
+		assert.ErrorContains(t, err, "replay diverged at call 3: the recording has no more calls, got a generateBool call with digest ")
+	})
+}
+
+func TestRecordingGenerator_Errors(t *testing.T) {
+	var recording bytes.Buffer
+	recorder := NewRecordingGenerator(&flakyGenerator{errs: []error{errors.New("model overloaded")}}, &recording)
+	_, err := recorder.Generate(context.Background(), ai.WithPrompt("Suggest a gift."))
+	require.EqualError(t, err, "model overloaded")
+	var count int
+	require.NoError(t, recorder.GenerateJSON(context.Background(), "How many gifts?", nil, &count))
+
+	replay, err := NewReplayGenerator(strings.NewReader(recording.String()))
+	require.NoError(t, err)
+	_, err = replay.Generate(context.Background(), ai.WithPrompt("Suggest a gift."))
+	assert.EqualError(t, err, "model overloaded", "a failed call fails again")
+	var replayed int
+	require.NoError(t, replay.GenerateJSON(context.Background(), "How many gifts?", nil, &replayed))
+	assert.Equal(t, 3, replayed)
+}
+
+func TestNewReplayGenerator_Malformed(t *testing.T) {
+	_, err := NewReplayGenerator(strings.NewReader("{\"kind\":\"generate\"}\nnot json\n"))
+
+	assert.ErrorContains(t, err, "recording line 2: ")
+}
+
+func TestRecordingGenerator_Checklist(t *testing.T) {
+	mockGen := NewMockGenerator(nil, nil)
+	mockGen.checklistResponses = []map[string]bool{{"Is the budget known?": true, "Are the ages known?": false}}
+	questions := []string{"Is the budget known?", "Are the ages known?"}
+	var recording bytes.Buffer
+
+	answers, err := NewRecordingGenerator(mockGen, &recording).GenerateChecklist(context.Background(), questions, nil)
+	require.NoError(t, err)
+	assert.Contains(t, recording.String(), `"kind":"generateChecklist"`)
+	replay, err := NewReplayGenerator(bytes.NewReader(recording.Bytes()))
+	require.NoError(t, err)
+	replayed, err := replay.GenerateChecklist(context.Background(), questions, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, answers, replayed)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineRequestFileTool and its handler.
+const (
+	defaultRequestFileToolName        = "requestFile"
+	defaultRequestFileToolDescription = "use this to ask the user for a file, such as a photo of a wishlist or a spreadsheet; the response holds the file content as a data URI or a reference to it"
+	// defaultMaxFileBytes caps the size of requested files that don't set maxBytes.
+	defaultMaxFileBytes = 10 << 20
+	// defaultInlineFileBytes is the size up to which files are inlined in the tool response.
+	defaultInlineFileBytes = 64 << 10
+)
+
+// RequestFileInput contains a request for a file.
+type RequestFileInput struct {
+	Question      string   `json:"question" jsonschema:"description=what file is needed, such as 'Please share the wishlist photo'"`
+	AcceptedTypes []string `json:"acceptedTypes,omitempty" jsonschema:"description=the accepted MIME types such as image/* or text/csv, or file extensions such as .csv; empty accepts any file"`
+	MaxBytes      int64    `json:"maxBytes,omitempty" jsonschema:"description=the largest accepted file size in bytes"`
+}
+
+// FileUpload is a file provided by the user.
+type FileUpload struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// FileReference is the tool response describing the provided file. Small files are inlined in Data as
+// a data URI; larger ones are kept by the storage hook and referred to by Ref.
+type FileReference struct {
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Size        int    `json:"size"`
+	Data        string `json:"data,omitempty"`
+	Ref         string `json:"ref,omitempty"`
+}
+
+// FileAsker is implemented by interactors that receive files directly, such as uploads, instead of a
+// local path typed as the answer.
+type FileAsker interface {
+	// AskFile asks for the file and returns it once provided.
+	AskFile(ctx context.Context, input RequestFileInput) (FileUpload, error)
+}
+
+// FileStorage keeps a file too large to inline and returns a reference the model can pass on to other
+// tools, such as a URL.
+type FileStorage func(ctx context.Context, file FileUpload) (string, error)
+
+// DefineRequestFileTool defines the file request tool, named "requestFile" by default, in the Genkit
+// instance and returns it. Its interrupts are answered by the handler returned by
+// NewRequestFileHandler, which must be registered for the tool name with RegisterToolHandler.
+func DefineRequestFileTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultRequestFileToolName, defaultRequestFileToolDescription, opts)
+
+	return DefineInterruptTool[RequestFileInput, FileReference](g, config.name, config.description, WithInterruptMetadataKey("file"))
+}
+
+// requestFileHandler configures the handler returned by NewRequestFileHandler.
+type requestFileHandler struct {
+	maxBytes    int64
+	inlineBytes int
+	storage     FileStorage
+}
+
+// RequestFileOption configures the handler of the file request tool.
+type RequestFileOption func(*requestFileHandler)
+
+// WithMaxFileBytes caps the size of requested files, including those whose request sets a larger
+// maxBytes. It defaults to 10 MiB.
+func WithMaxFileBytes(maxBytes int64) RequestFileOption {
+	return func(h *requestFileHandler) {
+		h.maxBytes = maxBytes
+	}
+}
+
+// WithFileStorage keeps files larger than inlineBytes with the storage hook, so that the model gets a
+// reference instead of their content.
+func WithFileStorage(storage FileStorage, inlineBytes int) RequestFileOption {
+	return func(h *requestFileHandler) {
+		h.storage = storage
+		h.inlineBytes = inlineBytes
+	}
+}
+
+// NewRequestFileHandler returns the ToolHandler of the file request tool. Interactors implementing
+// FileAsker receive the file themselves; the others are asked for the path of a local file, which is
+// read and asked again while it doesn't exist, is too large or isn't of an accepted type. It responds
+// with a FileReference inlining the content as a base64 data URI, or referring to the copy kept by the
+// storage hook for files above its inline size.
+func NewRequestFileHandler(opts ...RequestFileOption) ToolHandler {
+	h := &requestFileHandler{maxBytes: defaultMaxFileBytes, inlineBytes: defaultInlineFileBytes}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h.handle
+}
+
+func (h *requestFileHandler) handle(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var request RequestFileInput
+	if err := decodeToolInput(input, &request); err != nil {
+		return ToolResult{}, err
+	}
+	if request.Question == "" {
+		return ToolResult{}, errors.New("request file tool called without a question")
+	}
+	if request.MaxBytes <= 0 || request.MaxBytes > h.maxBytes {
+		request.MaxBytes = h.maxBytes
+	}
+
+	file, err := h.ask(ctx, interactor, request)
+	if err != nil {
+		return ToolResult{}, err
+	}
+
+	reference := FileReference{Name: file.Name, ContentType: file.ContentType, Size: len(file.Data)}
+	if h.storage != nil && len(file.Data) > h.inlineBytes {
+		if reference.Ref, err = h.storage(ctx, file); err != nil {
+			return ToolResult{}, fmt.Errorf("failed to store the file: %w", err)
+		}
+		return ToolResult{Output: reference}, nil
+	}
+	reference.Data = "data:" + file.ContentType + ";base64," + base64.StdEncoding.EncodeToString(file.Data)
+	return ToolResult{Output: reference}, nil
+}
+
+// ask gets the file from the interactor, or from the local path the user answers with.
+func (h *requestFileHandler) ask(ctx context.Context, interactor Interactor, request RequestFileInput) (FileUpload, error) {
+	asker, ok := interactor.(FileAsker)
+	if !ok {
+		question := QuestionInput{Question: request.Question + " (path of a local file)"}
+		file, err := askParsed(ctx, interactor, question, request.readLocalFile)
+		if err != nil {
+			return FileUpload{}, err
+		}
+		return file.(FileUpload), nil
+	}
+
+	var err error
+	for range maxParseAttempts {
+		var file FileUpload
+		if file, err = asker.AskFile(ctx, request); err != nil {
+			return FileUpload{}, err
+		}
+		if file.ContentType == "" {
+			file.ContentType = detectContentType(file.Name, file.Data)
+		}
+		if err = request.check(file); err == nil {
+			return file, nil
+		}
+		if notifyErr := interactor.Notify(ctx, invalidAnswerMessage(err)); notifyErr != nil {
+			return FileUpload{}, notifyErr
+		}
+	}
+	return FileUpload{}, fmt.Errorf("no valid answer after %d attempts: %w", maxParseAttempts, err)
+}
+
+// readLocalFile reads the file at the path the user answered with. Quotes added by dragging the file
+// into a terminal are removed.
+func (r RequestFileInput) readLocalFile(answer string) (any, error) {
+	path := strings.Trim(strings.TrimSpace(answer), `"'`)
+	if path == "" {
+		return nil, errors.New("expected the path of a file")
+	}
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("%s doesn't exist", path)
+	case err != nil:
+		return nil, err
+	case info.IsDir():
+		return nil, fmt.Errorf("%s is a directory", path)
+	case r.MaxBytes > 0 && info.Size() > r.MaxBytes:
+		return nil, fmt.Errorf("%s is %d bytes, larger than the limit of %d bytes", path, info.Size(), r.MaxBytes)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := FileUpload{Name: filepath.Base(path), Data: data}
+	file.ContentType = detectContentType(file.Name, data)
+	if err := r.check(file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// check reports a file that is too large or isn't of an accepted type.
+func (r RequestFileInput) check(file FileUpload) error {
+	if r.MaxBytes > 0 && int64(len(file.Data)) > r.MaxBytes {
+		return fmt.Errorf("%s is %d bytes, larger than the limit of %d bytes", file.Name, len(file.Data), r.MaxBytes)
+	}
+	if len(r.AcceptedTypes) == 0 {
+		return nil
+	}
+	contentType, _, _ := mime.ParseMediaType(file.ContentType)
+	for _, accepted := range r.AcceptedTypes {
+		accepted = strings.ToLower(strings.TrimSpace(accepted))
+		switch {
+		case strings.HasPrefix(accepted, "."):
+			if strings.EqualFold(filepath.Ext(file.Name), accepted) {
+				return nil
+			}
+		case strings.HasSuffix(accepted, "/*"):
+			if strings.HasPrefix(contentType, strings.TrimSuffix(accepted, "*")) {
+				return nil
+			}
+		case contentType == accepted:
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is %s, expected %s", file.Name, contentType, strings.Join(r.AcceptedTypes, ", "))
+}
+
+// detectContentType returns the MIME type of the file from its extension, or from its content when the
+// extension is unknown.
+func detectContentType(name string, data []byte) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(data)
+}
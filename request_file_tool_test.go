@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTempFile writes the content to a file with the name in a temporary directory and returns its path.
+func writeTempFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestRequestFileInput_ReadLocalFile(t *testing.T) {
+	wishlist := writeTempFile(t, "wishlist.txt", "Lego, a bike")
+	tests := []struct {
+		name     string
+		request  RequestFileInput
+		answer   string
+		expected FileUpload
+		err      string
+	}{
+		{
+			name:     "small text file",
+			answer:   wishlist,
+			expected: FileUpload{Name: "wishlist.txt", ContentType: "text/plain; charset=utf-8", Data: []byte("Lego, a bike")},
+		},
+		{
+			name:     "quoted path",
+			request:  RequestFileInput{AcceptedTypes: []string{"text/*"}},
+			answer:   ` '` + wishlist + `' `,
+			expected: FileUpload{Name: "wishlist.txt", ContentType: "text/plain; charset=utf-8", Data: []byte("Lego, a bike")},
+		},
+		{name: "not found", answer: filepath.Join(t.TempDir(), "missing.txt"), err: "missing.txt doesn't exist"},
+		{name: "directory", answer: t.TempDir(), err: "is a directory"},
+		{name: "empty", answer: " ", err: "expected the path of a file"},
+		{name: "too large", request: RequestFileInput{MaxBytes: 4}, answer: wishlist, err: "is 12 bytes, larger than the limit of 4 bytes"},
+		{name: "not accepted", request: RequestFileInput{AcceptedTypes: []string{"image/*", ".csv"}}, answer: wishlist, err: "wishlist.txt is text/plain, expected image/*, .csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := tt.request.readLocalFile(tt.answer)
+
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, file)
+		})
+	}
+}
+
+func TestRequestFileInput_Check(t *testing.T) {
+	tests := []struct {
+		name     string
+		accepted []string
+		file     FileUpload
+		ok       bool
+	}{
+		{name: "any type", file: FileUpload{Name: "a.bin", ContentType: "application/octet-stream"}, ok: true},
+		{name: "exact type with parameters", accepted: []string{"text/csv"}, file: FileUpload{Name: "a", ContentType: "text/csv; charset=utf-8"}, ok: true},
+		{name: "wildcard", accepted: []string{"image/*"}, file: FileUpload{Name: "a.png", ContentType: "image/png"}, ok: true},
+		{name: "extension", accepted: []string{".CSV"}, file: FileUpload{Name: "list.csv", ContentType: "application/octet-stream"}, ok: true},
+		{name: "other type", accepted: []string{"image/*", ".csv"}, file: FileUpload{Name: "a.pdf", ContentType: "application/pdf"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RequestFileInput{AcceptedTypes: tt.accepted}.check(tt.file)
+
+			assert.Equal(t, tt.ok, err == nil, "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestRequestFileHandler(t *testing.T) {
+	wishlist := writeTempFile(t, "wishlist.txt", "Lego, a bike")
+	input := map[string]any{"question": "Please share the wishlist", "acceptedTypes": []any{"text/plain"}}
+
+	t.Run("inlines small files", func(t *testing.T) {
+		interactor := &sequenceInteractor{answers: []Answer{{Value: "missing.txt"}, {Value: wishlist}}}
+
+		result, err := NewRequestFileHandler()(context.Background(), interactor, input)
+
+		require.NoError(t, err)
+		assert.Equal(t, FileReference{
+			Name:        "wishlist.txt",
+			ContentType: "text/plain; charset=utf-8",
+			Size:        12,
+			Data:        "data:text/plain; charset=utf-8;base64,TGVnbywgYSBiaWtl",
+		}, result.Output)
+		assert.Equal(t, []string{"Invalid answer: missing.txt doesn't exist"}, interactor.notifications)
+	})
+
+	t.Run("stores large files", func(t *testing.T) {
+		interactor := &sequenceInteractor{answers: []Answer{{Value: wishlist}}}
+		var stored FileUpload
+		storage := func(_ context.Context, file FileUpload) (string, error) {
+			stored = file
+			return "gs://uploads/wishlist.txt", nil
+		}
+
+		result, err := NewRequestFileHandler(WithFileStorage(storage, 4))(context.Background(), interactor, input)
+
+		require.NoError(t, err)
+		assert.Equal(t, FileReference{
+			Name:        "wishlist.txt",
+			ContentType: "text/plain; charset=utf-8",
+			Size:        12,
+			Ref:         "gs://uploads/wishlist.txt",
+		}, result.Output)
+		assert.Equal(t, "Lego, a bike", string(stored.Data))
+	})
+
+	t.Run("caps the size limit", func(t *testing.T) {
+		interactor := &sequenceInteractor{answers: []Answer{{Value: wishlist}, {Value: wishlist}, {Value: wishlist}}}
+		input := map[string]any{"question": "Please share the wishlist", "maxBytes": 1000}
+
+		_, err := NewRequestFileHandler(WithMaxFileBytes(4))(context.Background(), interactor, input)
+
+		assert.ErrorContains(t, err, "no valid answer after 3 attempts")
+		assert.ErrorContains(t, err, "larger than the limit of 4 bytes")
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		interactor := &sequenceInteractor{answers: []Answer{{Skipped: true}}}
+
+		_, err := NewRequestFileHandler()(context.Background(), interactor, input)
+
+		assert.ErrorIs(t, err, ErrQuestionSkipped)
+	})
+}
+
+// fakeFileAsker is an interactor returning the uploads in order.
+type fakeFileAsker struct {
+	fakeInteractor
+	uploads []FileUpload
+}
+
+func (f *fakeFileAsker) AskFile(context.Context, RequestFileInput) (FileUpload, error) {
+	upload := f.uploads[0]
+	f.uploads = f.uploads[1:]
+	return upload, nil
+}
+
+func TestRequestFileHandler_FileAsker(t *testing.T) {
+	asker := &fakeFileAsker{uploads: []FileUpload{
+		{Name: "photo.png", Data: []byte("\x89PNG\r\n\x1a\n")},
+		{Name: "wishlist.txt", Data: []byte("Lego")},
+	}}
+	input := map[string]any{"question": "Please share the wishlist", "acceptedTypes": []any{".txt"}}
+
+	result, err := NewRequestFileHandler()(context.Background(), asker, input)
+
+	require.NoError(t, err)
+	reference, ok := result.Output.(FileReference)
+	require.True(t, ok)
+	assert.Equal(t, "wishlist.txt", reference.Name)
+	assert.True(t, strings.HasPrefix(reference.Data, "data:text/plain"), reference.Data)
+	assert.Equal(t, []string{"Invalid answer: photo.png is image/png, expected .txt"}, asker.notified)
+}
+
+func TestDefineRequestFileTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	tool := DefineRequestFileTool(g)
+	_, err := tool.RunRaw(context.Background(), map[string]any{"question": "Please share the wishlist", "maxBytes": 1024})
+
+	assert.Equal(t, "requestFile", tool.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, RequestFileInput{Question: "Please share the wishlist", MaxBytes: 1024}, metadata["file"])
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+	"google.golang.org/genai"
+)
+
+// RetryPolicy tells RetryingGenerator which errors to retry and how long to wait between attempts.
+// The delay starts at InitialDelay and is multiplied by Multiplier after each attempt, up to MaxDelay;
+// Jitter spreads it randomly by up to that fraction either way, so that clients failing together don't
+// retry together.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt; zero or less means 3.
+	MaxAttempts int
+	// InitialDelay is the delay before the first retry; zero means 500ms.
+	InitialDelay time.Duration
+	// MaxDelay caps the delay; zero means no cap.
+	MaxDelay time.Duration
+	// Multiplier grows the delay; values below 1 mean 2.
+	Multiplier float64
+	// Jitter is a fraction between 0 and 1; zero waits the exact delays.
+	Jitter float64
+	// IsTransient reports the errors worth retrying; nil means IsTransientError.
+	IsTransient func(error) bool
+}
+
+// DefaultRetryPolicy returns a policy making 3 attempts 0.5s and 1s apart, give or take 20%, on the
+// errors reported by IsTransientError.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: 500 * time.Millisecond,
+		MaxDelay:     10 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+		IsTransient:  IsTransientError,
+	}
+}
+
+// IsTransientError reports errors a later attempt may not get: rate limits and server errors of the
+// Gemini API, the matching Genkit statuses and network timeouts. Output that doesn't match the expected
+// type and cancelled contexts are not transient.
+func IsTransientError(err error) bool {
+	var mismatch *ErrOutputMismatch
+	if errors.As(err, &mismatch) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= http.StatusInternalServerError
+	}
+	var genkitErr *core.GenkitError
+	if errors.As(err, &genkitErr) {
+		return genkitErr.Status == core.RESOURCE_EXHAUSTED || genkitErr.Status == core.UNAVAILABLE
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// RetryingGenerator is a Generator retrying the model calls of another one on transient errors.
+type RetryingGenerator struct {
+	inner  Generator
+	policy RetryPolicy
+	// sleep and random are replaced in tests to skip the delays and fix the jitter.
+	sleep  func(ctx context.Context, d time.Duration) error
+	random func() float64
+}
+
+// NewRetryingGenerator returns a Generator calling inner and retrying Generate, GenerateStream,
+// GenerateBool and GenerateJSON as the policy says. A call is never retried once ctx is done.
+func NewRetryingGenerator(inner Generator, policy RetryPolicy) *RetryingGenerator {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.InitialDelay <= 0 {
+		policy.InitialDelay = 500 * time.Millisecond
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 2
+	}
+	if policy.IsTransient == nil {
+		policy.IsTransient = IsTransientError
+	}
+	return &RetryingGenerator{inner: inner, policy: policy, sleep: sleepContext, random: rand.Float64}
+}
+
+// Generate calls the inner Generate, retrying on transient errors.
+func (r *RetryingGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	var resp *ai.ModelResponse
+	err := r.retry(ctx, nil, func() error {
+		var err error
+		resp, err = r.inner.Generate(ctx, opts...)
+		return err
+	})
+	return resp, err
+}
+
+// GenerateStream calls the inner GenerateStream, retrying on transient errors until a chunk is passed
+// to cb; a call failing after that isn't retried, since the chunks the caller got can't be taken back.
+func (r *RetryingGenerator) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	streamed := false
+	stream := func(chunk *ai.ModelResponseChunk) error {
+		streamed = true
+		return cb(chunk)
+	}
+	var resp *ai.ModelResponse
+	err := r.retry(ctx, func() bool { return !streamed }, func() error {
+		var err error
+		resp, err = r.inner.GenerateStream(ctx, stream, opts...)
+		return err
+	})
+	return resp, err
+}
+
+// GenerateBool calls the inner GenerateBool, retrying on transient errors.
+func (r *RetryingGenerator) GenerateBool(ctx context.Context, prompt string, history []*ai.Message) (bool, error) {
+	var result bool
+	err := r.retry(ctx, nil, func() error {
+		var err error
+		result, err = r.inner.GenerateBool(ctx, prompt, history)
+		return err
+	})
+	return result, err
+}
+
+// GenerateChecklist calls the inner GenerateChecklist, retrying on transient errors.
+func (r *RetryingGenerator) GenerateChecklist(ctx context.Context, questions []string, history []*ai.Message) (map[string]bool, error) {
+	var answers map[string]bool
+	err := r.retry(ctx, nil, func() error {
+		var err error
+		answers, err = r.inner.GenerateChecklist(ctx, questions, history)
+		return err
+	})
+	return answers, err
+}
+
+// GenerateJSON calls the inner GenerateJSON, retrying on transient errors.
+func (r *RetryingGenerator) GenerateJSON(ctx context.Context, system string, history []*ai.Message, out any) error {
+	return r.retry(ctx, nil, func() error {
+		return r.inner.GenerateJSON(ctx, system, history, out)
+	})
+}
+
+// CountTokens counts the tokens with the inner generator, retrying on transient errors.
+func (r *RetryingGenerator) CountTokens(ctx context.Context, messages []*ai.Message) (TokenCount, error) {
+	var count TokenCount
+	err := r.retry(ctx, nil, func() error {
+		var err error
+		count, err = r.inner.CountTokens(ctx, messages)
+		return err
+	})
+	return count, err
+}
+
+// Embed embeds the text with the inner generator, retrying on transient errors.
+func (r *RetryingGenerator) Embed(ctx context.Context, text string) ([]float32, error) {
+	var embedding []float32
+	err := r.retry(ctx, nil, func() error {
+		var err error
+		embedding, err = r.inner.Embed(ctx, text)
+		return err
+	})
+	return embedding, err
+}
+
+// Ping pings the inner generator once, without retrying, so that a ping reports the backend as it is.
+func (r *RetryingGenerator) Ping(ctx context.Context) error {
+	return ping(ctx, r.inner)
+}
+
+// LookupTool looks up the tool with the inner generator.
+func (r *RetryingGenerator) LookupTool(name string) ai.Tool {
+	return r.inner.LookupTool(name)
+}
+
+// retry calls call until it succeeds, fails with an error that isn't transient, or runs out of
+// attempts, and returns its last error. When retryable is set, a failed call is retried only while it
+// reports true.
+func (r *RetryingGenerator) retry(ctx context.Context, retryable func() bool, call func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = call(); err == nil {
+			return nil
+		}
+		if attempt >= r.policy.MaxAttempts || ctx.Err() != nil || !r.policy.IsTransient(err) || (retryable != nil && !retryable()) {
+			return err
+		}
+		delay := r.delay(attempt)
+		log.Printf("model call failed, retrying in %s (attempt %d of %d): %v", delay, attempt+1, r.policy.MaxAttempts, err)
+		if sleepErr := r.sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+// delay returns how long to wait after the failed attempt, counted from 1.
+func (r *RetryingGenerator) delay(attempt int) time.Duration {
+	delay := float64(r.policy.InitialDelay) * math.Pow(r.policy.Multiplier, float64(attempt-1))
+	if r.policy.MaxDelay > 0 {
+		delay = min(delay, float64(r.policy.MaxDelay))
+	}
+	delay *= 1 + r.policy.Jitter*(2*r.random()-1)
+	return time.Duration(delay)
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
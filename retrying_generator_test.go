@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// flakyGenerator fails its first calls with the errors, in order, and then succeeds. With chunkFirst,
+// GenerateStream emits a chunk before failing.
+type flakyGenerator struct {
+	errs       []error
+	calls      int
+	chunkFirst bool
+}
+
+func (f *flakyGenerator) fail() error {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return f.errs[f.calls-1]
+	}
+	return nil
+}
+
+func (f *flakyGenerator) Generate(context.Context, ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return createTextResponse("A LEGO set", "stop"), nil
+}
+
+func (f *flakyGenerator) GenerateStream(_ context.Context, cb func(chunk *ai.ModelResponseChunk) error, _ ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	chunk := &ai.ModelResponseChunk{Role: ai.RoleModel, Content: []*ai.Part{ai.NewTextPart("A LEGO set")}}
+	if f.chunkFirst {
+		if err := cb(chunk); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	if !f.chunkFirst {
+		if err := cb(chunk); err != nil {
+			return nil, err
+		}
+	}
+	return createTextResponse("A LEGO set", "stop"), nil
+}
+
+func (f *flakyGenerator) GenerateBool(context.Context, string, []*ai.Message) (bool, error) {
+	if err := f.fail(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *flakyGenerator) GenerateChecklist(_ context.Context, questions []string, _ []*ai.Message) (map[string]bool, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	answers := make(map[string]bool, len(questions))
+	for _, question := range questions {
+		answers[question] = true
+	}
+	return answers, nil
+}
+
+func (f *flakyGenerator) GenerateJSON(_ context.Context, _ string, _ []*ai.Message, out any) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	*out.(*int) = 3
+	return nil
+}
+
+func (f *flakyGenerator) CountTokens(_ context.Context, messages []*ai.Message) (TokenCount, error) {
+	if err := f.fail(); err != nil {
+		return TokenCount{}, err
+	}
+	return estimateTokens(messages), nil
+}
+
+func (f *flakyGenerator) Embed(context.Context, string) ([]float32, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return []float32{1, 0}, nil
+}
+
+func (f *flakyGenerator) Ping(context.Context) error {
+	return f.fail()
+}
+
+func (f *flakyGenerator) LookupTool(name string) ai.Tool {
+	return createMockTool(name)
+}
+
+// fakeSleeper records the delays instead of waiting.
+type fakeSleeper struct {
+	delays []time.Duration
+}
+
+func (f *fakeSleeper) sleep(_ context.Context, d time.Duration) error {
+	f.delays = append(f.delays, d)
+	return nil
+}
+
+// newTestRetryingGenerator returns a RetryingGenerator sleeping with the sleeper and whose jitter is
+// random fixed at r.
+func newTestRetryingGenerator(inner Generator, policy RetryPolicy, sleeper *fakeSleeper, r float64) *RetryingGenerator {
+	generator := NewRetryingGenerator(inner, policy)
+	generator.sleep = sleeper.sleep
+	generator.random = func() float64 { return r }
+	return generator
+}
+
+// rateLimited is the error of the Gemini API when the quota is exceeded.
+var rateLimited = genai.APIError{Code: http.StatusTooManyRequests, Status: "RESOURCE_EXHAUSTED"}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{name: "rate limited", err: rateLimited, transient: true},
+		{name: "wrapped server error", err: fmt.Errorf("generate: %w", genai.APIError{Code: http.StatusServiceUnavailable}), transient: true},
+		{name: "bad request", err: genai.APIError{Code: http.StatusBadRequest}},
+		{name: "genkit unavailable", err: core.NewError(core.UNAVAILABLE, "model overloaded"), transient: true},
+		{name: "genkit invalid argument", err: core.NewError(core.INVALID_ARGUMENT, "no model")},
+		{name: "output mismatch", err: &ErrOutputMismatch{Text: "maybe", Err: errors.New("invalid character")}},
+		{name: "cancelled", err: fmt.Errorf("generate: %w", context.Canceled)},
+		{name: "other", err: errors.New("tool not found")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.transient, IsTransientError(tt.err))
+		})
+	}
+}
+
+func TestRetryingGenerator_Generate(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 4, InitialDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond, Multiplier: 2}
+
+	tests := []struct {
+		name   string
+		errs   []error
+		calls  int
+		delays []time.Duration
+		err    error
+	}{
+		{name: "first attempt", calls: 1},
+		{
+			name:   "recovers",
+			errs:   []error{rateLimited, rateLimited},
+			calls:  3,
+			delays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+		},
+		{
+			name:   "gives up",
+			errs:   []error{rateLimited, rateLimited, rateLimited, rateLimited},
+			calls:  4,
+			delays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond},
+			err:    rateLimited,
+		},
+		{
+			name:  "not transient",
+			errs:  []error{genai.APIError{Code: http.StatusBadRequest}},
+			calls: 1,
+			err:   genai.APIError{Code: http.StatusBadRequest},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &flakyGenerator{errs: tt.errs}
+			sleeper := &fakeSleeper{}
+			generator := newTestRetryingGenerator(inner, policy, sleeper, 0.5)
+
+			resp, err := generator.Generate(context.Background())
+
+			assert.Equal(t, tt.calls, inner.calls)
+			assert.Equal(t, tt.delays, sleeper.delays)
+			if tt.err != nil {
+				assert.Equal(t, tt.err, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "A LEGO set", resp.Text())
+		})
+	}
+}
+
+func TestRetryingGenerator_Jitter(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 2, InitialDelay: time.Second, Jitter: 0.2}
+
+	for r, expected := range map[float64]time.Duration{0: 800 * time.Millisecond, 1: 1200 * time.Millisecond} {
+		sleeper := &fakeSleeper{}
+		generator := newTestRetryingGenerator(&flakyGenerator{errs: []error{rateLimited}}, policy, sleeper, r)
+
+		_, err := generator.GenerateBool(context.Background(), "Is it finished?", nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, []time.Duration{expected}, sleeper.delays)
+	}
+}
+
+func TestRetryingGenerator_GenerateBoolAndJSON(t *testing.T) {
+	inner := &flakyGenerator{errs: []error{rateLimited, nil, rateLimited}}
+	generator := newTestRetryingGenerator(inner, DefaultRetryPolicy(), &fakeSleeper{}, 0.5)
+
+	finished, err := generator.GenerateBool(context.Background(), "Is it finished?", nil)
+	require.NoError(t, err)
+	assert.True(t, finished)
+
+	var count int
+	require.NoError(t, generator.GenerateJSON(context.Background(), "How many gifts?", nil, &count))
+	assert.Equal(t, 3, count)
+	assert.Equal(t, 4, inner.calls)
+	assert.Equal(t, "askQuestion", generator.LookupTool("askQuestion").Name())
+}
+
+func TestRetryingGenerator_Cancelled(t *testing.T) {
+	t.Run("before a retry", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		inner := &flakyGenerator{errs: []error{rateLimited, rateLimited}}
+		sleeper := &fakeSleeper{}
+
+		_, err := newTestRetryingGenerator(inner, DefaultRetryPolicy(), sleeper, 0.5).Generate(ctx)
+
+		assert.Equal(t, rateLimited, err)
+		assert.Equal(t, 1, inner.calls)
+		assert.Empty(t, sleeper.delays)
+	})
+
+	t.Run("while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		inner := &flakyGenerator{errs: []error{rateLimited, rateLimited}}
+		generator := NewRetryingGenerator(inner, RetryPolicy{InitialDelay: time.Hour})
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		_, err := generator.Generate(ctx)
+
+		assert.Equal(t, rateLimited, err)
+		assert.Equal(t, 1, inner.calls)
+	})
+}
+
+func TestRetryingGenerator_GenerateStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		chunkFirst bool
+		calls      int
+		texts      []string
+		err        error
+	}{
+		{name: "fails before streaming", calls: 2, texts: []string{"A LEGO set"}},
+		{name: "fails after streaming", chunkFirst: true, calls: 1, texts: []string{"A LEGO set"}, err: rateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &flakyGenerator{errs: []error{rateLimited}, chunkFirst: tt.chunkFirst}
+			generator := newTestRetryingGenerator(inner, DefaultRetryPolicy(), &fakeSleeper{}, 0.5)
+			var texts []string
+
+			_, err := generator.GenerateStream(context.Background(), chunkTexts(&texts))
+
+			assert.Equal(t, tt.calls, inner.calls)
+			assert.Equal(t, tt.texts, texts, "chunks are never streamed twice")
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
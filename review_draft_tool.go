@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineReviewDraftTool and its handler.
+const (
+	defaultReviewDraftToolName        = "reviewDraft"
+	defaultReviewDraftToolDescription = "use this to show the user your proposed final answer before giving it; the response tells whether they approved it or which changes they want, in which case revise the draft and review it again"
+	defaultMaxRevisions               = 3
+)
+
+// ReviewDraftInput contains a draft of the final answer for the user to approve.
+type ReviewDraftInput struct {
+	Draft string `json:"draft" jsonschema:"description=the complete proposed final answer"`
+}
+
+// ReviewDraftOutput is the user's verdict on a draft.
+type ReviewDraftOutput struct {
+	Approved bool `json:"approved"`
+	// Feedback holds the changes the user asked for when the draft isn't approved.
+	Feedback string `json:"feedback,omitempty"`
+	// RevisionLimitReached is set when the draft is approved because no more revisions are allowed.
+	RevisionLimitReached bool `json:"revisionLimitReached,omitempty"`
+}
+
+// DefineReviewDraftTool defines the draft review tool, named "reviewDraft" by default, in the Genkit
+// instance and returns it. Its interrupts are answered by the handler returned by
+// NewReviewDraftHandler, which must be registered for the tool name with RegisterToolHandler.
+func DefineReviewDraftTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultReviewDraftToolName, defaultReviewDraftToolDescription, opts)
+
+	return DefineInterruptTool[ReviewDraftInput, ReviewDraftOutput](g, config.name, config.description, WithInterruptMetadataKey("draft"))
+}
+
+// reviewDraftHandler counts the revisions requested through the handler returned by NewReviewDraftHandler.
+type reviewDraftHandler struct {
+	maxRevisions int
+
+	mu        sync.Mutex
+	revisions int
+}
+
+// ReviewDraftOption configures the handler of the draft review tool.
+type ReviewDraftOption func(*reviewDraftHandler)
+
+// WithMaxRevisions sets how many times the user can ask for changes before the next draft is approved
+// without asking. It defaults to 3.
+func WithMaxRevisions(maxRevisions int) ReviewDraftOption {
+	return func(h *reviewDraftHandler) {
+		h.maxRevisions = maxRevisions
+	}
+}
+
+// NewReviewDraftHandler returns the ToolHandler of the draft review tool. It shows the draft with Notify
+// and asks for approval: yes, y, ok or approve approves the draft, and any other answer is passed to the
+// model as the changes to make. Once the user has asked for the maximum number of revisions, later
+// drafts are approved without asking so that the run can't cycle forever. Revisions are counted over
+// the handler's lifetime, so every run needs a handler of its own.
+func NewReviewDraftHandler(opts ...ReviewDraftOption) ToolHandler {
+	h := &reviewDraftHandler{maxRevisions: defaultMaxRevisions}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h.handle
+}
+
+func (h *reviewDraftHandler) handle(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var review ReviewDraftInput
+	if err := decodeToolInput(input, &review); err != nil {
+		return ToolResult{}, err
+	}
+	if strings.TrimSpace(review.Draft) == "" {
+		return ToolResult{}, errors.New("review draft tool called without a draft")
+	}
+
+	if err := interactor.Notify(ctx, review.Draft); err != nil {
+		return ToolResult{}, err
+	}
+	if h.limitReached() {
+		notice := fmt.Sprintf("No more changes can be made after %d revisions; this is the final answer.", h.maxRevisions)
+		if err := interactor.Notify(ctx, notice); err != nil {
+			return ToolResult{}, err
+		}
+		return ToolResult{Output: ReviewDraftOutput{Approved: true, RevisionLimitReached: true}}, nil
+	}
+
+	question := QuestionInput{Question: "Do you approve this answer? Answer yes, or describe the changes you want."}
+	verdict, err := askParsed(ctx, interactor, question, parseReview)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	output := verdict.(ReviewDraftOutput)
+	if !output.Approved {
+		h.mu.Lock()
+		h.revisions++
+		h.mu.Unlock()
+	}
+	return ToolResult{Output: output}, nil
+}
+
+// limitReached reports whether the user has asked for the maximum number of revisions.
+func (h *reviewDraftHandler) limitReached() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.revisions >= h.maxRevisions
+}
+
+// parseReview turns the answer into approval or the requested changes.
+func parseReview(answer string) (any, error) {
+	answer = strings.TrimSpace(answer)
+	switch strings.ToLower(strings.TrimRight(answer, ".!")) {
+	case "":
+		return nil, errors.New("answer yes, or describe the changes you want")
+	case "y", "yes", "ok", "okay", "approve", "approved", "looks good", "lgtm":
+		return ReviewDraftOutput{Approved: true}, nil
+	default:
+		return ReviewDraftOutput{Feedback: answer}, nil
+	}
+}
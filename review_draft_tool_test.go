@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReview(t *testing.T) {
+	tests := []struct {
+		answer   string
+		expected any
+		err      string
+	}{
+		{answer: "yes", expected: ReviewDraftOutput{Approved: true}},
+		{answer: " Looks good! ", expected: ReviewDraftOutput{Approved: true}},
+		{answer: "OK.", expected: ReviewDraftOutput{Approved: true}},
+		{answer: " Cheaper gifts please ", expected: ReviewDraftOutput{Feedback: "Cheaper gifts please"}},
+		{answer: " ", err: "answer yes, or describe the changes you want"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.answer, func(t *testing.T) {
+			value, err := parseReview(tt.answer)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestReviewDraftHandler_ThroughToolHandler(t *testing.T) {
+	draft := func(text string) *ai.Part {
+		return createInterruptPart("reviewDraft", map[string]any{"draft": text})
+	}
+	tests := []struct {
+		name      string
+		responses []*ai.ModelResponse
+		answers   []Answer
+		expected  []ReviewDraftOutput
+		notified  []string
+	}{
+		{
+			name: "approved the first time",
+			responses: []*ai.ModelResponse{
+				createInterruptedResponse(draft("A Lego set and a bike")),
+				createTextResponse("A Lego set and a bike", "stop"),
+			},
+			answers:  []Answer{{Value: "yes"}},
+			expected: []ReviewDraftOutput{{Approved: true}},
+			notified: []string{"A Lego set and a bike"},
+		},
+		{
+			name: "one revision then approved",
+			responses: []*ai.ModelResponse{
+				createInterruptedResponse(draft("A Lego set and a bike")),
+				createInterruptedResponse(draft("A Lego set and a kite")),
+				createTextResponse("A Lego set and a kite", "stop"),
+			},
+			answers:  []Answer{{Value: "Something cheaper than a bike"}, {Value: "y"}},
+			expected: []ReviewDraftOutput{{Feedback: "Something cheaper than a bike"}, {Approved: true}},
+			notified: []string{"A Lego set and a bike", "A Lego set and a kite"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGen := NewMockGenerator(tt.responses, map[string]ai.Tool{
+				"askQuestion": createMockTool("askQuestion"),
+				"reviewDraft": createMockTool("reviewDraft"),
+			})
+			interactor := &sequenceInteractor{answers: tt.answers}
+			handler := &InterruptionHandler{generator: mockGen, Interactor: interactor}
+			handler.RegisterToolHandler("reviewDraft", NewReviewDraftHandler())
+
+			result, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.responses[len(tt.responses)-1].Text(), result)
+			// the first call starts the run and every later one answers a review
+			require.Len(t, mockGen.capturedCalls, len(tt.expected)+1)
+			for i, expected := range tt.expected {
+				parts := capturedToolResponses(mockGen.capturedCalls[i+1].Options)
+				require.Len(t, parts, 1)
+				assert.Equal(t, expected, parts[0].ToolResponse.Output)
+			}
+			assert.Equal(t, tt.notified, interactor.notifications)
+		})
+	}
+}
+
+func TestReviewDraftHandler_MaxRevisions(t *testing.T) {
+	interactor := &sequenceInteractor{answers: []Answer{{Value: "Cheaper"}}}
+	handle := NewReviewDraftHandler(WithMaxRevisions(1))
+	input := map[string]any{"draft": "A bike"}
+
+	first, err := handle(context.Background(), interactor, input)
+	require.NoError(t, err)
+	second, err := handle(context.Background(), interactor, input)
+	require.NoError(t, err)
+
+	assert.Equal(t, ReviewDraftOutput{Feedback: "Cheaper"}, first.Output)
+	assert.Equal(t, ReviewDraftOutput{Approved: true, RevisionLimitReached: true}, second.Output)
+	assert.Equal(t, 1, interactor.asked, "the second draft is approved without asking")
+	assert.Contains(t, interactor.notifications, "No more changes can be made after 1 revisions; this is the final answer.")
+}
+
+func TestDefineReviewDraftTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	tool := DefineReviewDraftTool(g)
+	_, err := tool.RunRaw(context.Background(), map[string]any{"draft": "A bike"})
+
+	assert.Equal(t, "reviewDraft", tool.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, ReviewDraftInput{Draft: "A bike"}, metadata["draft"])
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/firebase/genkit/go/ai"
 )
@@ -23,36 +24,163 @@ type Generator interface {
 	Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error)
 	LookupTool(name string) ai.Tool
 	GenerateBool(ctx context.Context, prompt string, history []*ai.Message) (bool, error)
+	// GenerateChecklist asks the yes/no questions about the history in a single model call and returns
+	// the answers by question. A question the model doesn't answer is false.
+	GenerateChecklist(ctx context.Context, questions []string, history []*ai.Message) (map[string]bool, error)
+	// GenerateJSON generates a response constrained to the JSON schema of out, a non-nil pointer, and
+	// decodes it into out.
+	GenerateJSON(ctx context.Context, system string, history []*ai.Message, out any) error
+	// GenerateStream generates a response like Generate, passing its chunks to cb as the model produces
+	// them. An error returned by cb stops the generation.
+	GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error)
+	// CountTokens counts the tokens the messages take up for the model, such as to keep a history within
+	// the context window. The count is flagged as estimated when the provider can't count tokens.
+	CountTokens(ctx context.Context, messages []*ai.Message) (TokenCount, error)
+	// Embed returns the embedding of the text, such as to compare questions by meaning. It fails with
+	// ErrNoEmbedder when no embedder is set up.
+	Embed(ctx context.Context, text string) ([]float32, error)
 }
 
 // Options contains the configuration for running the agent.
 type Options struct {
-	generator       Generator
-	systemPrompt    SystemPrompt
-	userPrompt      UserPrompt
-	toolNames       []string
+	generator    Generator
+	systemPrompt SystemPrompt
+	userPrompt   UserPrompt
+	// systemPromptTemplate and userPromptTemplate are text/templates rendered with vars in place of
+	// systemPrompt and userPrompt, such as "Find a gift for {{.occasion}} by {{.deadline}}." A
+	// variable missing from vars fails the run before the model is called.
+	systemPromptTemplate string
+	userPromptTemplate   string
+	// systemPromptRef and userPromptRef are prompts loaded from .prompt files, such as with
+	// LoadPromptFiles, rendered with vars as their input in place of systemPrompt and userPrompt.
+	systemPromptRef ai.Prompt
+	userPromptRef   ai.Prompt
+	// vars are the variables of the prompt templates and the input of the .prompt files. When set, the
+	// validation prompt of a ConversationLoopHandler is rendered with them too.
+	vars map[string]any
+	// config is the generation config of the model calls of the run, such as {"maxOutputTokens": 800},
+	// in the format of the provider.
+	config map[string]any
+	// deterministic pins the sampling of the model calls of the run to be as reproducible as the
+	// provider allows: temperature 0 and a fixed seed, with top-k 1 and top-p 1 where the provider takes
+	// them. A config setting them to other values fails the run.
+	deterministic bool
+	toolNames     []string
+	// tools are offered along with the ones named in toolNames, without looking them up.
+	tools           []ai.Tool
 	responseHandler ResponseHandler
+	// usage is called when the run ends with the usage of its model calls, counted when they go through
+	// a UsageTrackingGenerator.
+	usage func(TokenUsage)
+	// onChunk, when set, streams the text of the model responses of the run as it is generated, including
+	// the responses the response handler generates. Tool requests aren't streamed.
+	onChunk func(chunk *ai.ModelResponseChunk) error
+}
+
+// lookupTools resolves tool names into tool references, failing on the first unknown tool.
+func lookupTools(generator Generator, toolNames []string) ([]ai.ToolRef, error) {
+	tools := make([]ai.ToolRef, 0, len(toolNames))
+	for _, toolName := range toolNames {
+		tool := generator.LookupTool(toolName)
+		if tool == nil {
+			return nil, fmt.Errorf("%s tool not found", toolName)
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+type runToolsKey struct{}
+
+// withRunTools returns ctx carrying the tools of the run given as values, which the response handlers
+// offer on the generations continuing the run.
+func withRunTools(ctx context.Context, tools []ai.Tool) context.Context {
+	return context.WithValue(ctx, runToolsKey{}, tools)
+}
+
+// runTools returns the tools of the run made with ctx.
+func runTools(ctx context.Context) []ai.Tool {
+	tools, _ := ctx.Value(runToolsKey{}).([]ai.Tool)
+	return tools
+}
+
+// lookupRunTool returns the tool of the run with the name, or else the tool the generator looks up.
+func lookupRunTool(ctx context.Context, generator Generator, name string) ai.Tool {
+	for _, tool := range runTools(ctx) {
+		if tool.Name() == name {
+			return tool
+		}
+	}
+	return generator.LookupTool(name)
+}
+
+// continuationTools returns the tools offered on a generation continuing the run: the tools of the run
+// and the named tools, which are looked up unless the run has them already.
+func continuationTools(ctx context.Context, generator Generator, toolNames []string) ([]ai.ToolRef, error) {
+	extra := runTools(ctx)
+	names := slices.DeleteFunc(slices.Clone(toolNames), func(name string) bool {
+		return slices.ContainsFunc(extra, func(tool ai.Tool) bool { return tool.Name() == name })
+	})
+	tools, err := lookupTools(generator, names)
+	if err != nil {
+		return nil, err
+	}
+	for _, tool := range extra {
+		tools = append(tools, tool)
+	}
+	return tools, nil
 }
 
 // RunAgent communicates with a user to ask clarifying questions during AI generation.
-// It uses the askQuestion tool to interrupt the generation process, collect user input,
+// It uses the question tool to interrupt the generation process, collect user input,
 // and continue generation with the provided answers until a final response is produced.
 func RunAgent(
 	ctx context.Context,
 	options *Options,
 ) (string, error) {
-	tools := make([]ai.ToolRef, 0, len(options.toolNames))
-	for _, toolName := range options.toolNames {
-		tool := options.generator.LookupTool(toolName)
-		if tool == nil {
-			return "", fmt.Errorf("%s tool not found", toolName)
-		}
+	if err := options.Validate(); err != nil {
+		return "", err
+	}
+	systemPrompt, userPrompt, err := options.renderPrompts(ctx)
+	if err != nil {
+		return "", err
+	}
+	if options.vars != nil {
+		ctx = withPromptVars(ctx, options.vars)
+	}
+	if options.usage != nil {
+		var scope *UsageScope
+		ctx, scope = WithUsageScope(ctx)
+		defer func() { options.usage(scope.Snapshot()) }()
+	}
+	if options.onChunk != nil {
+		ctx = withChunkCallback(ctx, options.onChunk)
+	}
+	config, err := options.generationConfig()
+	if err != nil {
+		return "", err
+	}
+	if config != nil {
+		ctx = withGenerationConfig(ctx, config)
+	}
+	if options.deterministic {
+		ctx = withDeterministicSampling(ctx)
+	}
+	ctx = withSecretValues(ctx)
+	tools, err := lookupTools(options.generator, options.toolNames)
+	if err != nil {
+		return "", err
+	}
+	for _, tool := range options.tools {
 		tools = append(tools, tool)
 	}
+	if len(options.tools) > 0 {
+		ctx = withRunTools(ctx, options.tools)
+	}
 
-	response, err := options.generator.Generate(ctx,
-		ai.WithPrompt(string(options.userPrompt)),
-		ai.WithSystem(string(options.systemPrompt)),
+	response, err := generateResponse(ctx, options.generator,
+		ai.WithPrompt(string(userPrompt)),
+		ai.WithSystem(string(systemPrompt)),
 		ai.WithTools(tools...),
 	)
 	if err != nil {
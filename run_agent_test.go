@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/core/api"
@@ -16,6 +21,8 @@ type MockGenerateCall struct {
 	HasHistory    bool
 	HasTools      bool
 	ToolResponses int
+	// Deterministic is whether the call was made with deterministic sampling.
+	Deterministic bool
 }
 
 // MockGenerator simulates the genkit.Generate function with predefined responses
@@ -27,6 +34,29 @@ type MockGenerator struct {
 	messageHistory []*ai.Message
 	boolResponses  []bool
 	boolCallIndex  int
+	// boolPrompts are the prompts GenerateBool answered from boolResponses, in order.
+	boolPrompts []string
+	// boolScores are the confidence and rationale GenerateBoolScored returns with the answer of the same
+	// call; the confidence is 1 when they're left out.
+	boolScores []scoredBool
+	// checklistResponses are the answers of GenerateChecklist, in order; every question is answered true
+	// after them. checklistQuestions are the questions of each call.
+	checklistResponses []map[string]bool
+	checklistCallIndex int
+	checklistQuestions [][]string
+	// jsonResponses are the payloads GenerateJSON decodes, in order.
+	jsonResponses []string
+	jsonCallIndex int
+	// chunks are the chunks GenerateStream emits before returning the response of the same call.
+	chunks [][]*ai.ModelResponseChunk
+	// tokenCounts are the counts CountTokens returns, in order; the tokens are estimated after them.
+	tokenCounts     []TokenCount
+	tokenCallIndex  int
+	countedMessages [][]*ai.Message
+	// embeddings are the embeddings Embed returns by text; Embed fails for the other texts. embedded are
+	// the texts of the calls.
+	embeddings map[string][]float32
+	embedded   []string
 }
 
 func (m *MockGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
@@ -36,7 +66,8 @@ func (m *MockGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption)
 
 	// Capture call details for assertions
 	call := MockGenerateCall{
-		Options: opts,
+		Options:       opts,
+		Deterministic: deterministicSampling(ctx),
 	}
 	m.capturedCalls = append(m.capturedCalls, call)
 
@@ -61,16 +92,82 @@ func (m *MockGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption)
 	return response, nil
 }
 
+func (m *MockGenerator) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	if m.callIndex < len(m.chunks) {
+		for _, chunk := range m.chunks[m.callIndex] {
+			if err := cb(chunk); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m.Generate(ctx, opts...)
+}
+
 func (m *MockGenerator) GenerateBool(ctx context.Context, prompt string, history []*ai.Message) (bool, error) {
 	if m.boolCallIndex >= len(m.boolResponses) {
 		// Default to true if no more responses are defined, to avoid infinite loops in tests
 		return true, nil
 	}
+	m.boolPrompts = append(m.boolPrompts, prompt)
 	response := m.boolResponses[m.boolCallIndex]
 	m.boolCallIndex++
 	return response, nil
 }
 
+func (m *MockGenerator) GenerateBoolScored(ctx context.Context, prompt string, history []*ai.Message) (bool, float64, string, error) {
+	index := m.boolCallIndex
+	value, err := m.GenerateBool(ctx, prompt, history)
+	if index >= len(m.boolScores) {
+		return value, 1, "", err
+	}
+	return value, m.boolScores[index].Confidence, m.boolScores[index].Rationale, err
+}
+
+func (m *MockGenerator) GenerateChecklist(ctx context.Context, questions []string, history []*ai.Message) (map[string]bool, error) {
+	m.checklistQuestions = append(m.checklistQuestions, questions)
+	if m.checklistCallIndex < len(m.checklistResponses) {
+		answers := m.checklistResponses[m.checklistCallIndex]
+		m.checklistCallIndex++
+		return answers, nil
+	}
+	answers := make(map[string]bool, len(questions))
+	for _, question := range questions {
+		answers[question] = true
+	}
+	return answers, nil
+}
+
+func (m *MockGenerator) GenerateJSON(ctx context.Context, system string, history []*ai.Message, out any) error {
+	if m.jsonCallIndex >= len(m.jsonResponses) {
+		return errors.New("no more mock JSON responses available")
+	}
+	payload := m.jsonResponses[m.jsonCallIndex]
+	m.jsonCallIndex++
+	if err := json.Unmarshal([]byte(payload), out); err != nil {
+		return &ErrOutputMismatch{Text: payload, Err: err}
+	}
+	return nil
+}
+
+func (m *MockGenerator) CountTokens(ctx context.Context, messages []*ai.Message) (TokenCount, error) {
+	m.countedMessages = append(m.countedMessages, messages)
+	if m.tokenCallIndex >= len(m.tokenCounts) {
+		return estimateTokens(messages), nil
+	}
+	count := m.tokenCounts[m.tokenCallIndex]
+	m.tokenCallIndex++
+	return count, nil
+}
+
+func (m *MockGenerator) Embed(ctx context.Context, text string) ([]float32, error) {
+	m.embedded = append(m.embedded, text)
+	embedding, ok := m.embeddings[text]
+	if !ok {
+		return nil, fmt.Errorf("no mock embedding for %q", text)
+	}
+	return embedding, nil
+}
+
 func (m *MockGenerator) LookupTool(name string) ai.Tool {
 	return m.tools[name]
 }
@@ -111,22 +208,34 @@ func (mt *MockTool) RunRaw(ctx context.Context, input any) (any, error) {
 }
 
 func (mt *MockTool) Respond(toolReq *ai.Part, outputData any, opts *ai.RespondOptions) *ai.Part {
-	return &ai.Part{
+	part := &ai.Part{
+		Kind: ai.PartToolResponse,
 		ToolResponse: &ai.ToolResponse{
 			Name:   mt.name,
 			Output: outputData,
 		},
 	}
+	// like the genkit tools, the response metadata is kept under "interruptResponse"
+	if opts != nil && opts.Metadata != nil {
+		part.Metadata = map[string]any{"interruptResponse": opts.Metadata}
+	}
+	return part
 }
 
 func (mt *MockTool) Restart(toolReq *ai.Part, opts *ai.RestartOptions) *ai.Part {
 	// For testing, we can return a simple restart part
-	return &ai.Part{
+	part := &ai.Part{
+		Kind: ai.PartToolRequest,
 		ToolRequest: &ai.ToolRequest{
 			Name:  mt.name,
 			Input: toolReq.ToolRequest.Input,
 		},
 	}
+	// like the genkit tools, the resumed metadata is kept under "resumed"
+	if opts != nil && opts.ResumedMetadata != nil {
+		part.Metadata = map[string]any{"resumed": opts.ResumedMetadata}
+	}
+	return part
 }
 
 func (mt *MockTool) Register(r api.Registry) {
@@ -307,6 +416,47 @@ func TestInterruption_MultipleSimultaneousInterrupts(t *testing.T) {
 	assert.Equal(t, 2, len(questionsAsked), "both questions should be asked")
 }
 
+// TestInterruption_BatchProgress tests that simultaneous interrupts are numbered in the terminal
+func TestInterruption_BatchProgress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createToolRequestPart("askQuestion", "What are their ages?", nil),
+			),
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What is the budget?", nil),
+			),
+			createTextResponse("Based on both genders and ages...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+
+	var out bytes.Buffer
+	terminalReader := NewTerminalReader(ctx, strings.NewReader("3\n8 and 11\n50\n"),
+		WithOutput(&out),
+		WithAnswerTimeout(time.Second),
+	)
+	result, err := RunAgent(ctx, &Options{
+		generator: mockGen,
+		responseHandler: &InterruptionHandler{
+			generator:       mockGen,
+			UserInteraction: terminalReader.Interactor,
+		},
+	})
+
+	require.NoError(t, err)
+	assert.Contains(t, result, "Based on")
+	rendered := out.String()
+	assert.Contains(t, rendered, "Question 1 of 2: What gender are the children?\n")
+	assert.Contains(t, rendered, "Question 2 of 2: What are their ages?\n")
+	assert.Contains(t, rendered, "\nWhat is the budget?\n", "a single question has no prefix")
+	assert.NotContains(t, rendered, "of 1")
+}
+
 // // TestInterruption_ContextCancellation tests handling of context cancellation
 func TestInterruption_ContextCancellation(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -533,16 +683,7 @@ func TestRunAgent_WithConversationLoop(t *testing.T) {
 
 	ctx := context.Background()
 
-	interruptionHandler := InterruptionHandler{
-		generator:       mockGen,
-		UserInteraction: mockUserInteraction,
-	}
-
-	conversationLoopHandler := &ConversationLoopHandler{
-		generator:           mockGen,
-		validationPrompt:    "Is finished?",
-		interruptionHandler: interruptionHandler,
-	}
+	conversationLoopHandler := NewConversationLoopHandler(mockGen, "Is finished?", mockUserInteraction)
 
 	result, err := RunAgent(
 		ctx,
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of a RunServer.
+const (
+	defaultMaxConcurrentRuns = 10
+	defaultRunPollInterval   = 100 * time.Millisecond
+	defaultFinishedRunTTL    = 10 * time.Minute
+)
+
+// errTooManyRuns is returned by start when the cap on concurrent runs is reached.
+var errTooManyRuns = errors.New("too many runs in flight")
+
+// errServerShuttingDown is returned by start once the server is shutting down.
+var errServerShuttingDown = errors.New("server is shutting down")
+
+// CreateRunRequest is the body of POST /runs.
+type CreateRunRequest struct {
+	UserPrompt string `json:"userPrompt"`
+	// SystemPrompt replaces the server's system prompt when set.
+	SystemPrompt string `json:"systemPrompt,omitempty"`
+}
+
+// AnswerRunRequest is the body of POST /runs/{id}/answer.
+type AnswerRunRequest struct {
+	// QuestionID selects the question to answer; it defaults to the pending question.
+	QuestionID string `json:"questionId,omitempty"`
+	// Answer is the answer; an empty answer selects the question's default.
+	Answer string `json:"answer"`
+}
+
+// RunQuestion is a question of a run waiting for an answer.
+type RunQuestion struct {
+	ID string `json:"id"`
+	QuestionInput
+}
+
+// UnmarshalJSON decodes the question and its ID.
+func (q *RunQuestion) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		ID string `json:"id"`
+	}
+	if err := unmarshalEmbeddedQuestion(data, &q.QuestionInput, &fields); err != nil {
+		return err
+	}
+	q.ID = fields.ID
+	return nil
+}
+
+// RunResource is the representation of a run served by GET /runs/{id}.
+type RunResource struct {
+	ID    string   `json:"id"`
+	State RunState `json:"state"`
+	// Question is the oldest question waiting for an answer, if any.
+	Question      *RunQuestion `json:"question,omitempty"`
+	FinalResponse string       `json:"finalResponse,omitempty"`
+	Error         string       `json:"error,omitempty"`
+}
+
+// serverRun is a run started by a RunServer. Its questions are kept in its own AnswerStore.
+type serverRun struct {
+	id         string
+	store      *MemoryAnswerStore
+	interactor *AsyncInteractor
+
+	mu            sync.Mutex
+	done          bool
+	finalResponse string
+	err           error
+}
+
+// resource returns the current representation of the run.
+func (r *serverRun) resource(ctx context.Context) (RunResource, error) {
+	r.mu.Lock()
+	done, finalResponse, err := r.done, r.finalResponse, r.err
+	r.mu.Unlock()
+
+	resource := RunResource{ID: r.id}
+	switch {
+	case done && err != nil:
+		resource.State, resource.Error = RunStateFailed, err.Error()
+		return resource, nil
+	case done:
+		resource.State, resource.FinalResponse = RunStateFinished, finalResponse
+		return resource, nil
+	}
+
+	unanswered, err := r.store.Unanswered(ctx)
+	if err != nil {
+		return RunResource{}, err
+	}
+	resource.State = RunStateWaitingForModel
+	if len(unanswered) > 0 {
+		resource.State = RunStateWaitingForUser
+		resource.Question = &RunQuestion{ID: unanswered[0].ID, QuestionInput: unanswered[0].Input}
+	}
+	return resource, nil
+}
+
+// finish records the outcome of the run.
+func (r *serverRun) finish(finalResponse string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done, r.finalResponse, r.err = true, finalResponse, err
+}
+
+// RunServer serves the agent over HTTP with a session per run. POST /runs starts a run, GET /runs/{id}
+// reports its state together with the question it waits for, and POST /runs/{id}/answer answers the question.
+// GET /ready pings the generator, responding with 503 Service Unavailable when the model backend isn't ready.
+// Every run is a goroutine asking through its own AsyncInteractor, which blocks until the answer is posted.
+// Finished runs are kept for a while, 10 minutes by default, for their outcome to be fetched, and then
+// dropped, so that they're not found anymore.
+type RunServer struct {
+	generator    Generator
+	toolNames    []string
+	toolHandlers map[string]ToolHandler
+	systemPrompt SystemPrompt
+	maxRuns      int
+	pollInterval time.Duration
+	finishedTTL  time.Duration
+	// ctx is the parent of the runs' contexts; cancel cancels the runs in flight.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	runs map[string]*serverRun
+	// active counts the runs that haven't finished yet.
+	active int
+}
+
+// RunServerOption configures a RunServer.
+type RunServerOption func(*RunServer)
+
+// WithRunSystemPrompt sets the system prompt of runs that don't bring their own.
+func WithRunSystemPrompt(prompt SystemPrompt) RunServerOption {
+	return func(s *RunServer) {
+		s.systemPrompt = prompt
+	}
+}
+
+// WithMaxConcurrentRuns caps how many runs are in flight at once; creating another one
+// responds with 429 Too Many Requests.
+func WithMaxConcurrentRuns(n int) RunServerOption {
+	return func(s *RunServer) {
+		s.maxRuns = max(n, 1)
+	}
+}
+
+// WithRunPollInterval sets how often a run waiting for an answer checks whether it was posted.
+func WithRunPollInterval(interval time.Duration) RunServerOption {
+	return func(s *RunServer) {
+		s.pollInterval = interval
+	}
+}
+
+// WithFinishedRunTTL sets how long a finished run is kept before it's dropped.
+func WithFinishedRunTTL(ttl time.Duration) RunServerOption {
+	return func(s *RunServer) {
+		s.finishedTTL = ttl
+	}
+}
+
+// WithRunToolHandler answers the interrupts of the named tool with the handler, like
+// InterruptionHandler.RegisterToolHandler, asking its questions through the run.
+func WithRunToolHandler(name string, handler ToolHandler) RunServerOption {
+	return func(s *RunServer) {
+		if s.toolHandlers == nil {
+			s.toolHandlers = make(map[string]ToolHandler)
+		}
+		s.toolHandlers[name] = handler
+	}
+}
+
+// NewRunServer creates a RunServer that runs the agent with the generator and offers it the tools.
+func NewRunServer(generator Generator, toolNames []string, opts ...RunServerOption) *RunServer {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &RunServer{
+		generator:    generator,
+		toolNames:    toolNames,
+		maxRuns:      defaultMaxConcurrentRuns,
+		pollInterval: defaultRunPollInterval,
+		finishedTTL:  defaultFinishedRunTTL,
+		ctx:          ctx,
+		cancel:       cancel,
+		runs:         make(map[string]*serverRun),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Handler returns the HTTP handler serving the run endpoints.
+func (s *RunServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /runs", s.createRun)
+	mux.HandleFunc("GET /runs/{id}", s.getRun)
+	mux.HandleFunc("POST /runs/{id}/answer", s.answerRun)
+	mux.HandleFunc("GET /ready", s.ready)
+	return mux
+}
+
+// ListenAndServe serves the Handler on the address until the context is done,
+// then cancels the runs in flight and waits for them to stop.
+func (s *RunServer) ListenAndServe(ctx context.Context, addr string) error {
+	server := &http.Server{Addr: addr, Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	err := server.ListenAndServe()
+	s.Shutdown()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown cancels the runs in flight and waits until they have stopped.
+func (s *RunServer) Shutdown() {
+	// cancelled under the lock so that no run starts after it
+	s.mu.Lock()
+	s.cancel()
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// start registers a run and starts it, failing when the server is at capacity or shutting down.
+func (s *RunServer) start(req CreateRunRequest) (*serverRun, error) {
+	id, err := newCorrelationID()
+	if err != nil {
+		return nil, err
+	}
+	store := NewMemoryAnswerStore()
+	run := &serverRun{
+		id:         id,
+		store:      store,
+		interactor: NewAsyncInteractor(store, WithWaitForAnswers(s.pollInterval)),
+	}
+
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return nil, errServerShuttingDown
+	}
+	if s.active >= s.maxRuns {
+		s.mu.Unlock()
+		return nil, errTooManyRuns
+	}
+	s.active++
+	s.runs[id] = run
+	// added under the lock so that Shutdown can't miss a run that is about to start
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	systemPrompt := s.systemPrompt
+	if req.SystemPrompt != "" {
+		systemPrompt = SystemPrompt(req.SystemPrompt)
+	}
+	handler := &InterruptionHandler{
+		generator:       s.generator,
+		UserInteraction: run.interactor.Ask,
+		toolNames:       s.toolNames,
+	}
+	for name, toolHandler := range s.toolHandlers {
+		handler.RegisterToolHandler(name, toolHandler)
+	}
+	go func() {
+		defer s.wg.Done()
+		finalResponse, err := RunAgent(s.ctx, &Options{
+			generator:       s.generator,
+			systemPrompt:    systemPrompt,
+			userPrompt:      UserPrompt(req.UserPrompt),
+			toolNames:       s.toolNames,
+			responseHandler: handler,
+		})
+		run.finish(finalResponse, err)
+
+		s.mu.Lock()
+		s.active--
+		s.mu.Unlock()
+		time.AfterFunc(s.finishedTTL, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.runs, run.id)
+		})
+	}()
+	return run, nil
+}
+
+// run returns the run with the ID from the request path, replying with 404 when there is none.
+func (s *RunServer) run(w http.ResponseWriter, r *http.Request) (*serverRun, bool) {
+	s.mu.Lock()
+	run, ok := s.runs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "run not found", http.StatusNotFound)
+	}
+	return run, ok
+}
+
+func (s *RunServer) createRun(w http.ResponseWriter, r *http.Request) {
+	var req CreateRunRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid run: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.UserPrompt) == "" {
+		http.Error(w, "userPrompt is required", http.StatusBadRequest)
+		return
+	}
+
+	run, err := s.start(req)
+	switch {
+	case errors.Is(err, errTooManyRuns):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case errors.Is(err, errServerShuttingDown):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/runs/"+run.id)
+	s.writeRun(w, r, run, http.StatusCreated)
+}
+
+func (s *RunServer) getRun(w http.ResponseWriter, r *http.Request) {
+	if run, ok := s.run(w, r); ok {
+		s.writeRun(w, r, run, http.StatusOK)
+	}
+}
+
+func (s *RunServer) answerRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.run(w, r)
+	if !ok {
+		return
+	}
+	var req AnswerRunRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid answer: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	questionID := req.QuestionID
+	if questionID == "" {
+		resource, err := run.resource(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if resource.Question == nil {
+			http.Error(w, "the run isn't waiting for an answer", http.StatusConflict)
+			return
+		}
+		questionID = resource.Question.ID
+	}
+
+	err := run.interactor.SubmitAnswer(r.Context(), questionID, req.Answer)
+	switch {
+	case errors.Is(err, ErrUnknownQuestion):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrAlreadyAnswered):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *RunServer) ready(w http.ResponseWriter, r *http.Request) {
+	if err := ping(r.Context(), s.generator); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeRun responds with the representation of the run.
+func (s *RunServer) writeRun(w http.ResponseWriter, r *http.Request, run *serverRun, status int) {
+	resource, err := run.resource(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resource)
+}
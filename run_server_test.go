@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postJSON posts the body as JSON and returns the response status and the decoded run, if any.
+func postJSON(t *testing.T, url string, body any) (int, RunResource) {
+	t.Helper()
+	raw, err := json.Marshal(body)
+	require.NoError(t, err)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(raw))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	var run RunResource
+	if resp.Header.Get("Content-Type") == "application/json" {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&run))
+	}
+	return resp.StatusCode, run
+}
+
+// getRun returns the run served by the server.
+func getRun(t *testing.T, server *httptest.Server, id string) RunResource {
+	t.Helper()
+	resp, err := http.Get(server.URL + "/runs/" + id)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var run RunResource
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&run))
+	return run
+}
+
+// waitForRun polls the run until it reaches the state.
+func waitForRun(t *testing.T, server *httptest.Server, id string, state RunState) RunResource {
+	t.Helper()
+	var run RunResource
+	require.Eventually(t, func() bool {
+		run = getRun(t, server, id)
+		return run.State == state
+	}, time.Second, time.Millisecond)
+	return run
+}
+
+func TestRunServer_CreateAnswerFinish(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+			),
+			createInterruptedResponse(createToolRequestPart("askQuestion", "What is the budget?", nil)),
+			createTextResponse("Based on both genders and $50...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	runServer := NewRunServer(mockGen, []string{"askQuestion"}, WithRunPollInterval(time.Millisecond))
+	defer runServer.Shutdown()
+	server := httptest.NewServer(runServer.Handler())
+	defer server.Close()
+
+	status, created := postJSON(t, server.URL+"/runs", CreateRunRequest{UserPrompt: "Suggest a gift.", SystemPrompt: "You are a gift advisor."})
+	require.Equal(t, http.StatusCreated, status)
+	require.NotEmpty(t, created.ID)
+
+	run := waitForRun(t, server, created.ID, RunStateWaitingForUser)
+	require.NotNil(t, run.Question)
+	assert.Equal(t, []string{"Boy", "Girl", "Both"}, run.Question.Choices)
+	status, _ = postJSON(t, server.URL+"/runs/"+created.ID+"/answer", AnswerRunRequest{Answer: "Both"})
+	assert.Equal(t, http.StatusNoContent, status)
+	// the answered question can't be answered again
+	status, _ = postJSON(t, server.URL+"/runs/"+created.ID+"/answer", AnswerRunRequest{QuestionID: run.Question.ID, Answer: "Boy"})
+	assert.Equal(t, http.StatusConflict, status)
+
+	require.Eventually(t, func() bool {
+		run = getRun(t, server, created.ID)
+		return run.Question != nil && run.Question.Question == "What is the budget?"
+	}, time.Second, time.Millisecond)
+	status, _ = postJSON(t, server.URL+"/runs/"+created.ID+"/answer", AnswerRunRequest{QuestionID: run.Question.ID, Answer: "$50"})
+	assert.Equal(t, http.StatusNoContent, status)
+
+	run = waitForRun(t, server, created.ID, RunStateFinished)
+	assert.Equal(t, "Based on both genders and $50...", run.FinalResponse)
+	assert.Nil(t, run.Question)
+	assert.Equal(t, 3, mockGen.callIndex)
+}
+
+func TestRunServer_Requests(t *testing.T) {
+	runServer := NewRunServer(NewMockGenerator(nil, nil), nil)
+	defer runServer.Shutdown()
+	server := httptest.NewServer(runServer.Handler())
+	defer server.Close()
+
+	status, _ := postJSON(t, server.URL+"/runs", CreateRunRequest{UserPrompt: " "})
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	resp, err := http.Get(server.URL + "/runs/unknown")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	status, _ = postJSON(t, server.URL+"/runs/unknown/answer", AnswerRunRequest{Answer: "yes"})
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = postJSON(t, server.URL+"/runs", CreateRunRequest{UserPrompt: strings.Repeat("a", maxRequestBodySize)})
+	assert.Equal(t, http.StatusBadRequest, status, "a body over the limit is rejected")
+}
+
+func TestRunServer_FinishedRunTTL(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("A LEGO set", "stop")}, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")})
+	runServer := NewRunServer(mockGen, []string{"askQuestion"}, WithFinishedRunTTL(100*time.Millisecond))
+	defer runServer.Shutdown()
+	server := httptest.NewServer(runServer.Handler())
+	defer server.Close()
+
+	status, created := postJSON(t, server.URL+"/runs", CreateRunRequest{UserPrompt: "Suggest a gift."})
+	require.Equal(t, http.StatusCreated, status)
+	waitForRun(t, server, created.ID, RunStateFinished)
+
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(server.URL + "/runs/" + created.ID)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusNotFound
+	}, time.Second, time.Millisecond, "the finished run is dropped")
+}
+
+func TestRunServer_ToolHandler(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askBudget", map[string]any{"question": "What is the budget?"})),
+			createTextResponse("A LEGO set", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askBudget": createMockTool("askBudget")},
+	)
+	askBudget := func(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+		answer, err := interactor.Ask(ctx, QuestionInput{Question: input["question"].(string)})
+		return ToolResult{Output: map[string]any{"amount": answer.Value}}, err
+	}
+	runServer := NewRunServer(mockGen, []string{"askQuestion", "askBudget"}, WithRunPollInterval(time.Millisecond), WithRunToolHandler("askBudget", askBudget))
+	defer runServer.Shutdown()
+	server := httptest.NewServer(runServer.Handler())
+	defer server.Close()
+
+	status, created := postJSON(t, server.URL+"/runs", CreateRunRequest{UserPrompt: "Suggest a gift."})
+	require.Equal(t, http.StatusCreated, status)
+	run := waitForRun(t, server, created.ID, RunStateWaitingForUser)
+	assert.Equal(t, "What is the budget?", run.Question.Question, "the tool handler asks through the run")
+	status, _ = postJSON(t, server.URL+"/runs/"+created.ID+"/answer", AnswerRunRequest{Answer: "$50"})
+	require.Equal(t, http.StatusNoContent, status)
+
+	run = waitForRun(t, server, created.ID, RunStateFinished)
+	assert.Equal(t, "A LEGO set", run.FinalResponse)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, map[string]any{"amount": "$50"}, parts[0].ToolResponse.Output)
+}
+
+func TestRunServer_ConcurrencyCapAndShutdown(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createToolRequestPart("askQuestion", "What is the budget?", nil)),
+			createTextResponse("unreachable", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	runServer := NewRunServer(mockGen, nil, WithMaxConcurrentRuns(1), WithRunPollInterval(time.Millisecond))
+	server := httptest.NewServer(runServer.Handler())
+	defer server.Close()
+
+	status, first := postJSON(t, server.URL+"/runs", CreateRunRequest{UserPrompt: "Suggest a gift."})
+	require.Equal(t, http.StatusCreated, status)
+	waitForRun(t, server, first.ID, RunStateWaitingForUser)
+
+	status, _ = postJSON(t, server.URL+"/runs", CreateRunRequest{UserPrompt: "Suggest another gift."})
+	assert.Equal(t, http.StatusTooManyRequests, status)
+
+	// shutting down cancels the run waiting for its answer
+	runServer.Shutdown()
+	run := getRun(t, server, first.ID)
+	assert.Equal(t, RunStateFailed, run.State)
+	assert.Contains(t, run.Error, "context canceled")
+
+	status, _ = postJSON(t, server.URL+"/runs", CreateRunRequest{UserPrompt: "Suggest a gift."})
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+}
+
+func TestRunServer_Ready(t *testing.T) {
+	inner := &flakyGenerator{errs: []error{&ErrBackendAuth{Err: assert.AnError}}}
+	runServer := NewRunServer(inner, nil)
+	defer runServer.Shutdown()
+	server := httptest.NewServer(runServer.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/ready")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	resp, err = http.Get(server.URL + "/ready")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
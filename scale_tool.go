@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineScaleTool.
+const (
+	defaultScaleToolName        = "askScale"
+	defaultScaleToolDescription = "use this to ask the user for a rating on a numeric scale, such as how important something is from 1 to 5; the response is the number"
+)
+
+// ScaleInput contains a question answered with a whole number on a scale.
+type ScaleInput struct {
+	Question string `json:"question" jsonschema:"description=a question such as 'How important is educational value?'"`
+	Min      int    `json:"min" jsonschema:"description=the lowest rating, usually 1"`
+	Max      int    `json:"max" jsonschema:"description=the highest rating"`
+	MinLabel string `json:"minLabel,omitempty" jsonschema:"description=what the lowest rating means, such as 'not at all'"`
+	MaxLabel string `json:"maxLabel,omitempty" jsonschema:"description=what the highest rating means, such as 'essential'"`
+}
+
+// DefineScaleTool defines the rating-scale tool, named "askScale" by default, in the Genkit instance and
+// returns it. Its interrupts are answered by HandleScale, which must be registered for the tool name
+// with RegisterToolHandler.
+func DefineScaleTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultScaleToolName, defaultScaleToolDescription, opts)
+
+	return DefineInterruptTool[ScaleInput, int](g, config.name, config.description, WithInterruptMetadataKey("scale"))
+}
+
+// HandleScale is the ToolHandler of the rating-scale tool. The question is shown with the scale and its
+// labels and asked again until the answer is a whole number within the scale, which it responds with.
+func HandleScale(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var scale ScaleInput
+	if err := decodeToolInput(input, &scale); err != nil {
+		return ToolResult{}, err
+	}
+	if scale.Question == "" {
+		return ToolResult{}, errors.New("scale tool called without a question")
+	}
+	if scale.Min >= scale.Max {
+		return ToolResult{}, fmt.Errorf("scale tool called with min %d not below max %d", scale.Min, scale.Max)
+	}
+
+	rating, err := askParsed(ctx, interactor, QuestionInput{Question: scale.Question + " " + scale.hint()}, scale.parse)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	return ToolResult{Output: rating}, nil
+}
+
+// hint renders the scale, such as "(1 = not at all … 5 = essential)".
+func (s ScaleInput) hint() string {
+	low, high := strconv.Itoa(s.Min), strconv.Itoa(s.Max)
+	if s.MinLabel != "" {
+		low += " = " + s.MinLabel
+	}
+	if s.MaxLabel != "" {
+		high += " = " + s.MaxLabel
+	}
+	if s.MinLabel == "" && s.MaxLabel == "" {
+		return "(" + low + "–" + high + ")"
+	}
+	return "(" + low + " … " + high + ")"
+}
+
+// parse converts the answer into a rating within the scale.
+func (s ScaleInput) parse(answer string) (any, error) {
+	rating, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil {
+		return nil, fmt.Errorf("expected a whole number from %d to %d", s.Min, s.Max)
+	}
+	if rating < s.Min || rating > s.Max {
+		return nil, fmt.Errorf("%d is outside the scale from %d to %d", rating, s.Min, s.Max)
+	}
+	return rating, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScaleInput_Parse(t *testing.T) {
+	scale := ScaleInput{Min: 1, Max: 5}
+
+	tests := []struct {
+		answer   string
+		expected any
+		err      string
+	}{
+		{answer: "1", expected: 1},
+		{answer: " 5 ", expected: 5},
+		{answer: "0", err: "0 is outside the scale from 1 to 5"},
+		{answer: "6", err: "6 is outside the scale from 1 to 5"},
+		{answer: "3.5", err: "expected a whole number from 1 to 5"},
+		{answer: "very", err: "expected a whole number from 1 to 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.answer, func(t *testing.T) {
+			rating, err := scale.parse(tt.answer)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, rating)
+		})
+	}
+}
+
+func TestScaleInput_Hint(t *testing.T) {
+	assert.Equal(t, "(1–5)", ScaleInput{Min: 1, Max: 5}.hint())
+	assert.Equal(t, "(1 = not at all … 5 = essential)", ScaleInput{Min: 1, Max: 5, MinLabel: "not at all", MaxLabel: "essential"}.hint())
+	assert.Equal(t, "(0 … 10 = best)", ScaleInput{Min: 0, Max: 10, MaxLabel: "best"}.hint())
+}
+
+func TestHandleScale_InvalidScale(t *testing.T) {
+	_, err := HandleScale(context.Background(), &sequenceInteractor{}, map[string]any{"question": "How much?", "min": 5, "max": 5})
+
+	assert.ErrorContains(t, err, "min 5 not below max 5")
+}
+
+func TestHandleScale_ThroughToolHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askScale", map[string]any{
+				"question": "How important is educational value?",
+				"min":      1,
+				"max":      5,
+				"minLabel": "not at all",
+				"maxLabel": "essential",
+			})),
+			createTextResponse("A science kit...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askScale": createMockTool("askScale")},
+	)
+	var out bytes.Buffer
+	terminal := NewTerminalReader(ctx, strings.NewReader("7\nfour\n4\n"), WithAnswerTimeout(time.Second), WithOutput(&out), WithWrapping(false))
+	handler := &InterruptionHandler{generator: mockGen, Interactor: terminal}
+	handler.RegisterToolHandler("askScale", HandleScale)
+
+	_, err := RunAgent(ctx, &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	output, err := json.Marshal(parts[0].ToolResponse.Output)
+	require.NoError(t, err)
+	assert.Equal(t, "4", string(output), "the model gets a JSON number")
+	assert.Contains(t, out.String(), "How important is educational value? (1 = not at all … 5 = essential)\n")
+	assert.Contains(t, out.String(), "Invalid answer: 7 is outside the scale from 1 to 5\n")
+	assert.Contains(t, out.String(), "Invalid answer: expected a whole number from 1 to 5\n")
+}
+
+func TestDefineScaleTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	tool := DefineScaleTool(g)
+	_, err := tool.RunRaw(context.Background(), map[string]any{"question": "How much?", "min": 1, "max": 5})
+
+	assert.Equal(t, "askScale", tool.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, ScaleInput{Question: "How much?", Min: 1, Max: 5}, metadata["scale"])
+}
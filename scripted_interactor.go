@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ScriptedAnswer maps the questions matching a pattern to an answer. Exactly one of Match and Regex is set.
+type ScriptedAnswer struct {
+	// Match matches questions containing it, ignoring case.
+	Match string `yaml:"match,omitempty" json:"match,omitempty"`
+	// Regex matches questions the regular expression matches.
+	Regex  string `yaml:"regex,omitempty" json:"regex,omitempty"`
+	Answer string `yaml:"answer" json:"answer"`
+}
+
+// ErrUnscriptedQuestion is returned by ScriptedInteractor for a question none of the patterns match.
+type ErrUnscriptedQuestion struct {
+	Question string
+}
+
+func (e *ErrUnscriptedQuestion) Error() string {
+	return fmt.Sprintf("no scripted answer matches the question %q; add a pattern matching it to the script", e.Question)
+}
+
+// scriptedRule is a ScriptedAnswer with its pattern prepared for matching.
+type scriptedRule struct {
+	match  string
+	regex  *regexp.Regexp
+	answer string
+}
+
+// matches reports whether the rule applies to the question.
+func (r scriptedRule) matches(question string) bool {
+	if r.regex != nil {
+		return r.regex.MatchString(question)
+	}
+	return strings.Contains(strings.ToLower(question), r.match)
+}
+
+// ScriptedInteractor answers questions from a script, for reproducible demos and regression runs.
+// Each question gets the answer of the first pattern matching it.
+type ScriptedInteractor struct {
+	rules []scriptedRule
+	// unmatchedPath is the file unmatched questions are appended to, if set.
+	unmatchedPath string
+
+	// mu serializes appends to the unmatched file.
+	mu sync.Mutex
+}
+
+// ScriptedOption configures a ScriptedInteractor.
+type ScriptedOption func(*ScriptedInteractor)
+
+// WithUnmatchedQuestionsFile appends every unmatched question to the file as a script entry with an empty
+// answer, so that the script can be completed by filling in the answers and copying the entries over.
+func WithUnmatchedQuestionsFile(path string) ScriptedOption {
+	return func(s *ScriptedInteractor) {
+		s.unmatchedPath = path
+	}
+}
+
+// NewScriptedInteractor creates a ScriptedInteractor trying the answers in order.
+// It fails on an entry without a pattern or with an invalid regular expression.
+func NewScriptedInteractor(answers []ScriptedAnswer, opts ...ScriptedOption) (*ScriptedInteractor, error) {
+	s := &ScriptedInteractor{}
+	for i, answer := range answers {
+		rule := scriptedRule{match: strings.ToLower(answer.Match), answer: answer.Answer}
+		switch {
+		case answer.Regex != "" && answer.Match != "":
+			return nil, fmt.Errorf("scripted answer %d has both match and regex", i+1)
+		case answer.Regex != "":
+			regex, err := regexp.Compile(answer.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("scripted answer %d has an invalid regex: %w", i+1, err)
+			}
+			rule.regex = regex
+		case answer.Match == "":
+			return nil, fmt.Errorf("scripted answer %d has no pattern", i+1)
+		}
+		s.rules = append(s.rules, rule)
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// LoadScriptedInteractor creates a ScriptedInteractor from a YAML or JSON file holding a list of
+// ScriptedAnswer entries.
+func LoadScriptedInteractor(path string, opts ...ScriptedOption) (*ScriptedInteractor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the script: %w", err)
+	}
+	// JSON is valid YAML, so one decoder reads both
+	var answers []ScriptedAnswer
+	if err := yaml.Unmarshal(data, &answers); err != nil {
+		return nil, fmt.Errorf("failed to parse the script %s: %w", path, err)
+	}
+	return NewScriptedInteractor(answers, opts...)
+}
+
+// Interact returns the answer of the first pattern matching the question, or fails with ErrUnscriptedQuestion.
+// It implements UserInteractionFunc.
+func (s *ScriptedInteractor) Interact(_ context.Context, input QuestionInput) (string, error) {
+	for _, rule := range s.rules {
+		if rule.matches(input.Question) {
+			return rule.answer, nil
+		}
+	}
+
+	if s.unmatchedPath != "" {
+		if err := s.recordUnmatched(input.Question); err != nil {
+			return "", err
+		}
+	}
+	return "", &ErrUnscriptedQuestion{Question: input.Question}
+}
+
+// recordUnmatched appends a script entry for the question to the unmatched file.
+func (s *ScriptedInteractor) recordUnmatched(question string) error {
+	entry, err := yaml.Marshal([]ScriptedAnswer{{Match: question}})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the unmatched question: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(s.unmatchedPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to record the unmatched question: %w", err)
+	}
+	if _, err := file.Write(entry); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to record the unmatched question: %w", err)
+	}
+	return file.Close()
+}
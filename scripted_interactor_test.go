@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScriptedInteractor_Interact(t *testing.T) {
+	script := []ScriptedAnswer{
+		{Match: "Gender", Answer: "Both"},
+		{Regex: `(?i)\b(budget|spend)\b`, Answer: "$80"},
+		{Regex: `^How old`, Answer: "8 and 11"},
+		{Match: "budget", Answer: "unreachable"},
+	}
+
+	tests := []struct {
+		name     string
+		question string
+		expected string
+	}{
+		{name: "substring ignores case", question: "What gender are the children?", expected: "Both"},
+		{name: "regex", question: "How much do you want to spend?", expected: "$80"},
+		{name: "first match wins", question: "What is your budget?", expected: "$80"},
+		{name: "anchored regex", question: "How old are they?", expected: "8 and 11"},
+	}
+
+	interactor, err := NewScriptedInteractor(script)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer, err := interactor.Interact(context.Background(), QuestionInput{Question: tt.question})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, answer)
+		})
+	}
+}
+
+func TestScriptedInteractor_Unscripted(t *testing.T) {
+	unmatched := filepath.Join(t.TempDir(), "unmatched.yaml")
+	interactor, err := NewScriptedInteractor([]ScriptedAnswer{{Match: "gender", Answer: "Both"}}, WithUnmatchedQuestionsFile(unmatched))
+	require.NoError(t, err)
+
+	for _, question := range []string{"What is the budget?", "Any hobbies?"} {
+		_, err := interactor.Interact(context.Background(), QuestionInput{Question: question})
+
+		var unscripted *ErrUnscriptedQuestion
+		require.True(t, errors.As(err, &unscripted), "unexpected error: %v", err)
+		assert.Equal(t, question, unscripted.Question)
+	}
+
+	// the recorded questions are a script waiting for its answers
+	recorded, err := LoadScriptedInteractor(unmatched)
+	require.NoError(t, err)
+	require.Len(t, recorded.rules, 2)
+	assert.Equal(t, "any hobbies?", recorded.rules[1].match)
+}
+
+func TestLoadScriptedInteractor(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected string
+	}{
+		{name: "yaml", script: "- match: gender\n  answer: Both\n- regex: budget|spend\n  answer: $80\n"},
+		{name: "json", script: `[{"match": "gender", "answer": "Both"}, {"regex": "budget|spend", "answer": "$80"}]`},
+		{name: "missing pattern", script: "- answer: Both\n", expected: "has no pattern"},
+		{name: "both patterns", script: "- match: gender\n  regex: gender\n  answer: Both\n", expected: "has both match and regex"},
+		{name: "invalid regex", script: "- regex: '('\n  answer: Both\n", expected: "invalid regex"},
+		{name: "not a list", script: "match: gender\n", expected: "failed to parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "script")
+			require.NoError(t, os.WriteFile(path, []byte(tt.script), 0o644))
+
+			interactor, err := LoadScriptedInteractor(path)
+
+			if tt.expected != "" {
+				assert.ErrorContains(t, err, tt.expected)
+				return
+			}
+			require.NoError(t, err)
+			answer, err := interactor.Interact(context.Background(), QuestionInput{Question: "How much will you spend?"})
+			require.NoError(t, err)
+			assert.Equal(t, "$80", answer)
+		})
+	}
+}
+
+func TestScriptedInteractor_RunAgent(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+			),
+			createInterruptedResponse(createToolRequestPart("askQuestion", "What is the budget?", nil)),
+			createTextResponse("Based on both genders and $80...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	interactor, err := NewScriptedInteractor([]ScriptedAnswer{
+		{Match: "gender", Answer: "Both"},
+		{Match: "budget", Answer: "$80"},
+	})
+	require.NoError(t, err)
+
+	result, err := RunAgent(context.Background(), &Options{
+		generator:       mockGen,
+		responseHandler: &InterruptionHandler{generator: mockGen, UserInteraction: interactor.Interact},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Based on both genders and $80...", result)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineSecretTool.
+const (
+	defaultSecretToolName        = "askSecret"
+	defaultSecretToolDescription = "use this to ask the user for a credential or a personal identifier, such as a loyalty card number; the answer is hidden while typed and kept out of logs and transcripts"
+)
+
+// RedactedAnswer stands in for the answer to a secret question wherever answers are recorded.
+const RedactedAnswer = "[redacted]"
+
+// SecretInput contains a question whose answer must not be shown or recorded.
+type SecretInput struct {
+	Question string `json:"question" jsonschema:"description=what is needed, such as 'What is your loyalty card number?'"`
+	Reason   string `json:"reason,omitempty" jsonschema:"description=one short sentence telling the user why it is needed"`
+}
+
+// DefineSecretTool defines the secret question tool, named "askSecret" by default, in the Genkit
+// instance and returns it. Its interrupts are answered by HandleSecret, which must be registered for
+// the tool name with RegisterToolHandler.
+func DefineSecretTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultSecretToolName, defaultSecretToolDescription, opts)
+
+	return DefineInterruptTool[SecretInput, string](g, config.name, config.description, WithInterruptMetadataKey("secret"))
+}
+
+type secretInputKey struct{}
+
+// WithSecretInput returns a context telling the interaction that the answer is secret, so that it is
+// hidden while typed and never echoed, logged or stored.
+func WithSecretInput(ctx context.Context) context.Context {
+	return context.WithValue(ctx, secretInputKey{}, true)
+}
+
+// IsSecretInput reports whether the answer to the question being asked is secret.
+func IsSecretInput(ctx context.Context) bool {
+	secret, _ := ctx.Value(secretInputKey{}).(bool)
+	return secret
+}
+
+// HandleSecret is the ToolHandler of the secret question tool. It asks the question with a context
+// marked by WithSecretInput and responds with the answer, which the model needs, and with the "secret"
+// response metadata, so that OnAnswer hooks and transcripts get RedactedAnswer instead.
+func HandleSecret(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var secret SecretInput
+	if err := decodeToolInput(input, &secret); err != nil {
+		return ToolResult{}, err
+	}
+	if secret.Question == "" {
+		return ToolResult{}, errors.New("secret tool called without a question")
+	}
+
+	answer, err := interactor.Ask(WithSecretInput(ctx), QuestionInput{Question: secret.Question, Reason: secret.Reason})
+	if err != nil {
+		return ToolResult{}, err
+	}
+	if answer.Skipped {
+		return ToolResult{}, ErrQuestionSkipped
+	}
+	return ToolResult{Output: answer.Value, Metadata: map[string]any{"secret": true}}, nil
+}
+
+// RedactedOutput returns the output of the result as it may be logged or stored: RedactedAnswer
+// when the result carries the "secret" metadata, the output itself otherwise.
+func RedactedOutput(result ToolResult) any {
+	if secret, _ := result.Metadata["secret"].(bool); secret {
+		return RedactedAnswer
+	}
+	return result.Output
+}
+
+// secretValues holds the secret answers given during a run, so that the text logged about the run can
+// be redacted.
+type secretValues struct {
+	mu     sync.Mutex
+	values []string
+}
+
+type secretValuesKey struct{}
+
+// withSecretValues returns a context collecting the values marked with MarkSecret, unless ctx already
+// does.
+func withSecretValues(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(secretValuesKey{}).(*secretValues); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, secretValuesKey{}, &secretValues{})
+}
+
+// MarkSecret flags the value as secret for the rest of the run, so that it is replaced with
+// RedactedAnswer in the text logged about the run, such as the prompts logged by a LoggingGenerator.
+// The answers of results carrying the "secret" metadata are marked by InterruptionHandler; tool handlers
+// call it for other secrets.
+func MarkSecret(ctx context.Context, value string) {
+	secrets, ok := ctx.Value(secretValuesKey{}).(*secretValues)
+	if !ok || strings.TrimSpace(value) == "" {
+		return
+	}
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+	secrets.values = append(secrets.values, value)
+}
+
+// redactSecrets returns the text with the values marked with MarkSecret replaced by RedactedAnswer.
+func redactSecrets(ctx context.Context, text string) string {
+	secrets, ok := ctx.Value(secretValuesKey{}).(*secretValues)
+	if !ok {
+		return text
+	}
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+	for _, value := range secrets.values {
+		text = strings.ReplaceAll(text, value, RedactedAnswer)
+	}
+	return text
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleSecret_ThroughToolHandler(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What ages?", nil),
+				createInterruptPart("askSecret", map[string]any{"question": "What is your loyalty card number?"}),
+			),
+			createTextResponse("Your points cover a Lego set", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askSecret": createMockTool("askSecret")},
+	)
+	var secretAsked bool
+	var answered []AnsweredInterrupt
+	handler := &InterruptionHandler{
+		generator: mockGen,
+		UserInteraction: func(ctx context.Context, input QuestionInput) (string, error) {
+			if input.Question == "What ages?" {
+				return "8 and 11", nil
+			}
+			secretAsked = IsSecretInput(ctx)
+			return "4111-2222", nil
+		},
+		OnAnswer: func(_ context.Context, a AnsweredInterrupt) {
+			answered = append(answered, a)
+		},
+	}
+	handler.RegisterToolHandler("askSecret", HandleSecret)
+
+	_, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	assert.True(t, secretAsked, "the interaction is told that the answer is secret")
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 2)
+	assert.Equal(t, "4111-2222", parts[1].ToolResponse.Output, "the model gets the secret")
+	assert.Equal(t, map[string]any{"interruptResponse": map[string]any{"secret": true}}, parts[1].Metadata)
+	require.Len(t, answered, 2)
+	assert.Equal(t, "8 and 11", answered[0].Output)
+	assert.Equal(t, "askSecret", answered[1].ToolName)
+	assert.Equal(t, RedactedAnswer, answered[1].Output)
+}
+
+func TestHandleSecret_MarkedSecret(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askSecret", map[string]any{"question": "What is your loyalty card number?"})),
+			createTextResponse("Your points cover a Lego set", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askSecret": createMockTool("askSecret")},
+	)
+	var logged string
+	handler := &InterruptionHandler{
+		generator:  mockGen,
+		Interactor: &sequenceInteractor{answers: []Answer{{Value: "4111-2222"}}},
+		OnAnswer: func(ctx context.Context, _ AnsweredInterrupt) {
+			logged = redactSecrets(ctx, "card 4111-2222 saved")
+		},
+	}
+	handler.RegisterToolHandler("askSecret", HandleSecret)
+
+	_, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	assert.Equal(t, "card [redacted] saved", logged, "the answer is redacted for the rest of the run")
+}
+
+func TestHandleSecret_Transcript(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askSecret", map[string]any{"question": "What is your loyalty card number?"})),
+			createTextResponse("Your points cover a Lego set", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askSecret": createMockTool("askSecret")},
+	)
+	flow := newClarifyingAgentFlow(mockGen, WithFlowToolHandler("askSecret", HandleSecret))
+
+	result, err := flow.run(context.Background(), ClarifyingAgentInput{
+		UserPrompt: "Suggest a gift.",
+		Answers:    map[string]string{"loyalty card": "4111-2222"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []QuestionAnswer{{Question: "What is your loyalty card number?", Answer: RedactedAnswer}}, result.Transcript)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, "4111-2222", parts[0].ToolResponse.Output)
+}
+
+func TestHandleSecret_Terminal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var out bytes.Buffer
+	terminal := NewTerminalReader(ctx, strings.NewReader("4111-2222\n"),
+		WithOutput(&out),
+		WithTerminalOutput(true),
+		WithColor(ColorNever),
+		WithAnswerTimeout(time.Second),
+		WithConfirmAnswers(ConfirmOptions{}),
+	)
+
+	result, err := HandleSecret(ctx, terminal, map[string]any{"question": "What is your loyalty card number?"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "4111-2222", result.Output)
+	assert.NotContains(t, out.String(), "4111-2222", "the answer isn't echoed by the confirmation step")
+	assert.Contains(t, out.String(), "(your answer is visible while you type on this terminal)")
+}
+
+func TestHandleSecret_Skipped(t *testing.T) {
+	_, err := HandleSecret(context.Background(), &sequenceInteractor{answers: []Answer{{Skipped: true}}}, map[string]any{"question": "Card number?"})
+
+	assert.ErrorIs(t, err, ErrQuestionSkipped)
+}
+
+func TestDefineSecretTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	tool := DefineSecretTool(g)
+	_, err := tool.RunRaw(context.Background(), map[string]any{"question": "Card number?"})
+
+	assert.Equal(t, "askSecret", tool.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, SecretInput{Question: "Card number?"}, metadata["secret"])
+}
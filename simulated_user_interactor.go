@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// Defaults of a SimulatedUserInteractor.
+const (
+	defaultSimulatedMaxTokens       = 64
+	defaultSimulatedMaxAnswerLength = 200
+)
+
+// simulatedUserInstructions follow the persona in the system prompt of the simulated user.
+const simulatedUserInstructions = `An assistant is asking you questions to help you. Answer each question in character,
+briefly and in plain text: a few words or one short sentence, with no preamble and no questions back.`
+
+// SimulatedUserInteractor answers questions by asking the model to play a user described by a persona,
+// so that the agent can be run end to end without a human. The model is called without tools,
+// so answering never interrupts.
+type SimulatedUserInteractor struct {
+	generator       Generator
+	persona         string
+	maxTokens       int
+	maxAnswerLength int
+}
+
+// SimulatedUserOption configures a SimulatedUserInteractor.
+type SimulatedUserOption func(*SimulatedUserInteractor)
+
+// WithSimulatedMaxTokens sets the output token limit of the model call answering a question.
+func WithSimulatedMaxTokens(tokens int) SimulatedUserOption {
+	return func(s *SimulatedUserInteractor) {
+		s.maxTokens = tokens
+	}
+}
+
+// WithSimulatedMaxAnswerLength sets how many characters of the model's reply are kept as the answer.
+func WithSimulatedMaxAnswerLength(length int) SimulatedUserOption {
+	return func(s *SimulatedUserInteractor) {
+		s.maxAnswerLength = max(length, 1)
+	}
+}
+
+// NewSimulatedUserInteractor creates a SimulatedUserInteractor answering as the persona, such as
+// "You are a parent shopping for two boys, budget $80".
+func NewSimulatedUserInteractor(generator Generator, persona string, opts ...SimulatedUserOption) *SimulatedUserInteractor {
+	s := &SimulatedUserInteractor{
+		generator:       generator,
+		persona:         persona,
+		maxTokens:       defaultSimulatedMaxTokens,
+		maxAnswerLength: defaultSimulatedMaxAnswerLength,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Interact asks the model for the persona's answer. The reply is cut to its first line and the
+// maximum answer length; for a question with choices it is mapped to one of them.
+// It implements UserInteractionFunc.
+func (s *SimulatedUserInteractor) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	response, err := s.generator.Generate(ctx,
+		// passed as arguments, since the prompt options treat their text as a format string
+		ai.WithSystem("%s\n\n%s", s.persona, simulatedUserInstructions),
+		ai.WithPrompt("%s", simulatedUserPrompt(input)),
+		// the Google AI plugin takes a map config but not ai.GenerationCommonConfig
+		ai.WithConfig(map[string]any{"maxOutputTokens": s.maxTokens}),
+	)
+	if err != nil {
+		return "", fmt.Errorf("simulated user failed to answer: %w", err)
+	}
+
+	answer, _, _ := strings.Cut(strings.TrimSpace(response.Text()), "\n")
+	answer = strings.TrimSpace(answer)
+	if runes := []rune(answer); len(runes) > s.maxAnswerLength {
+		answer = strings.TrimSpace(string(runes[:s.maxAnswerLength]))
+	}
+	if len(input.Choices) > 0 {
+		return pickSimulatedChoice(input, answer), nil
+	}
+	if answer == "" {
+		if input.Default != "" {
+			return input.Default, nil
+		}
+		return "", errors.New("simulated user gave an empty answer")
+	}
+	return answer, nil
+}
+
+// simulatedUserPrompt renders the question with its reason and numbered choices.
+func simulatedUserPrompt(input QuestionInput) string {
+	var b strings.Builder
+	b.WriteString("Question: " + input.Question + "\n")
+	if input.Reason != "" {
+		b.WriteString("Why it is asked: " + input.Reason + "\n")
+	}
+	if len(input.Choices) > 0 {
+		b.WriteString("Reply with exactly one of these options:\n")
+		for i, choice := range input.Choices {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, choice)
+		}
+	}
+	return b.String()
+}
+
+// pickSimulatedChoice maps the reply to a choice: the choice it names, its number, or the only choice
+// it mentions. A reply that doesn't single out a choice falls back to the default, or the first choice.
+func pickSimulatedChoice(input QuestionInput, reply string) string {
+	reply = strings.TrimRight(reply, ".!")
+	if choice, ok := matchChoice(input.Choices, reply); ok {
+		return choice
+	}
+	if choice, ok := resolveChoice(input.Choices, strings.TrimSpace(reply)); ok && choice != reply {
+		return choice
+	}
+
+	var mentioned []string
+	for _, choice := range input.Choices {
+		// whole words only, so that "boys" doesn't mention "Boy"
+		if regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(choice) + `\b`).MatchString(reply) {
+			mentioned = append(mentioned, choice)
+		}
+	}
+	if len(mentioned) == 1 {
+		return mentioned[0]
+	}
+	if choice, ok := matchChoice(input.Choices, input.Default); ok {
+		return choice
+	}
+	return input.Choices[0]
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturedPrompts renders the system and user prompts set by the options of a generate call.
+func capturedPrompts(t *testing.T, opts []ai.GenerateOption) (system, prompt string) {
+	t.Helper()
+	render := func(field reflect.Value) string {
+		fn, ok := field.Interface().(ai.PromptFn)
+		if !ok || fn == nil {
+			return ""
+		}
+		text, err := fn(context.Background(), nil)
+		require.NoError(t, err)
+		return text
+	}
+	for _, opt := range opts {
+		v := reflect.ValueOf(opt)
+		if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		if field := v.Elem().FieldByName("SystemFn"); field.IsValid() && system == "" {
+			system = render(field)
+		}
+		if field := v.Elem().FieldByName("PromptFn"); field.IsValid() && prompt == "" {
+			prompt = render(field)
+		}
+	}
+	return system, prompt
+}
+
+// capturedConfig returns the model config set by the options of a generate call.
+func capturedConfig(opts []ai.GenerateOption) any {
+	for _, opt := range opts {
+		v := reflect.ValueOf(opt)
+		if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		if field := v.Elem().FieldByName("Config"); field.IsValid() && !field.IsNil() {
+			return field.Interface()
+		}
+	}
+	return nil
+}
+
+func TestSimulatedUserInteractor_Interact(t *testing.T) {
+	choices := []string{"Boy", "Girl", "Both"}
+
+	tests := []struct {
+		name     string
+		input    QuestionInput
+		reply    string
+		expected string
+	}{
+		{name: "free text", input: QuestionInput{Question: "What ages?"}, reply: "8 and 11.", expected: "8 and 11."},
+		{name: "first line only", input: QuestionInput{Question: "What ages?"}, reply: "8 and 11\nAlso, what about books?", expected: "8 and 11"},
+		{name: "capped length", input: QuestionInput{Question: "What hobbies?"}, reply: strings.Repeat("lego ", 20), expected: "lego lego lego lego lego lego"},
+		{name: "empty selects the default", input: QuestionInput{Question: "What ages?", Default: "10"}, reply: " ", expected: "10"},
+		{name: "choice ignoring case", input: QuestionInput{Question: "What gender?", Choices: choices}, reply: "both.", expected: "Both"},
+		{name: "choice number", input: QuestionInput{Question: "What gender?", Choices: choices}, reply: "2", expected: "Girl"},
+		{name: "choice mentioned", input: QuestionInput{Question: "What gender?", Choices: choices}, reply: "They are both boys", expected: "Both"},
+		{name: "unclear reply selects the default", input: QuestionInput{Question: "What gender?", Choices: choices, Default: "Girl"}, reply: "Boy or girl", expected: "Girl"},
+		{name: "unclear reply without a default", input: QuestionInput{Question: "What gender?", Choices: choices}, reply: "not sure", expected: "Boy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			persona := NewMockGenerator([]*ai.ModelResponse{createTextResponse(tt.reply, "stop")}, nil)
+			interactor := NewSimulatedUserInteractor(persona, "You are a parent.", WithSimulatedMaxAnswerLength(30))
+
+			answer, err := interactor.Interact(context.Background(), tt.input)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, answer)
+		})
+	}
+}
+
+func TestSimulatedUserInteractor_Errors(t *testing.T) {
+	interactor := NewSimulatedUserInteractor(NewMockGenerator([]*ai.ModelResponse{createTextResponse("", "stop")}, nil), "You are a parent.")
+	_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What ages?"})
+	assert.ErrorContains(t, err, "empty answer")
+
+	interactor = NewSimulatedUserInteractor(NewMockGenerator(nil, nil), "You are a parent.")
+	_, err = interactor.Interact(context.Background(), QuestionInput{Question: "What ages?"})
+	assert.ErrorContains(t, err, "simulated user failed to answer")
+}
+
+func TestSimulatedUserInteractor_RunAgent(t *testing.T) {
+	agent := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+			),
+			createInterruptedResponse(createToolRequestPart("askQuestion", "What is the budget? (100% honest)", nil)),
+			createTextResponse("Two boys, $80: a LEGO set and a football.", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	persona := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createTextResponse("Boy", "stop"),
+			createTextResponse("About $80 in total", "stop"),
+		},
+		nil,
+	)
+	interactor := NewSimulatedUserInteractor(persona, "You are a parent shopping for two boys, budget $80.", WithSimulatedMaxTokens(32))
+	var answers []string
+	record := func(ctx context.Context, input QuestionInput) (string, error) {
+		answer, err := interactor.Interact(ctx, input)
+		answers = append(answers, answer)
+		return answer, err
+	}
+
+	result, err := RunAgent(context.Background(), &Options{
+		generator:       agent,
+		responseHandler: &InterruptionHandler{generator: agent, UserInteraction: record},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Two boys, $80: a LEGO set and a football.", result)
+	assert.Equal(t, []string{"Boy", "About $80 in total"}, answers)
+	require.Len(t, persona.capturedCalls, 2)
+	for _, call := range persona.capturedCalls {
+		assert.Empty(t, capturedToolNames(call.Options), "the simulated user can't call tools")
+		assert.Equal(t, map[string]any{"maxOutputTokens": 32}, capturedConfig(call.Options))
+	}
+	system, prompt := capturedPrompts(t, persona.capturedCalls[1].Options)
+	assert.True(t, strings.HasPrefix(system, "You are a parent shopping for two boys, budget $80.\n\n"), system)
+	assert.Equal(t, "Question: What is the budget? (100% honest)\n", prompt)
+}
+
+func TestSimulatedUserPrompt(t *testing.T) {
+	prompt := simulatedUserPrompt(QuestionInput{Question: "What gender?", Reason: "Gifts differ", Choices: []string{"Boy", "Girl"}})
+
+	assert.Equal(t, "Question: What gender?\nWhy it is asked: Gifts differ\nReply with exactly one of these options:\n1. Boy\n2. Girl\n", prompt)
+}
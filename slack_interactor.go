@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// slackAPIURL is the base URL of the Slack Web API.
+const slackAPIURL = "https://slack.com/api/"
+
+// slackChoiceAction prefixes the action IDs of choice buttons; the button value is the choice index.
+const slackChoiceAction = "choice-"
+
+// slackMaxSignatureAge is how old a signed request may be before it's rejected as a replay.
+const slackMaxSignatureAge = 5 * time.Minute
+
+// SlackMessage is a message posted to or updated in a Slack channel.
+type SlackMessage struct {
+	// Text is the plain-text fallback shown in notifications.
+	Text   string       `json:"text"`
+	Blocks []SlackBlock `json:"blocks,omitempty"`
+}
+
+// SlackBlock is a Block Kit layout block. Only the fields used by SlackInteractor are modelled.
+type SlackBlock struct {
+	Type     string         `json:"type"`
+	Text     *SlackText     `json:"text,omitempty"`
+	Elements []SlackElement `json:"elements,omitempty"`
+	// ImageURL and AltText are set on image blocks.
+	ImageURL string `json:"image_url,omitempty"`
+	AltText  string `json:"alt_text,omitempty"`
+}
+
+// SlackText is a Block Kit text object.
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// SlackElement is a Block Kit element such as a button.
+type SlackElement struct {
+	Type     string     `json:"type"`
+	Text     *SlackText `json:"text,omitempty"`
+	ActionID string     `json:"action_id,omitempty"`
+	Value    string     `json:"value,omitempty"`
+}
+
+// SlackClient is the part of the Slack Web API used by SlackInteractor.
+type SlackClient interface {
+	// PostMessage posts the message to the channel and returns its timestamp, which identifies it.
+	PostMessage(ctx context.Context, channel string, msg SlackMessage) (string, error)
+	// UpdateMessage replaces the message with the given timestamp.
+	UpdateMessage(ctx context.Context, channel, ts string, msg SlackMessage) error
+}
+
+// SlackAPIClient calls the Slack Web API with a bot token.
+type SlackAPIClient struct {
+	token      string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewSlackAPIClient creates a SlackAPIClient authenticated with the bot token.
+func NewSlackAPIClient(token string) *SlackAPIClient {
+	return &SlackAPIClient{token: token, baseURL: slackAPIURL, httpClient: http.DefaultClient}
+}
+
+// PostMessage calls chat.postMessage.
+func (c *SlackAPIClient) PostMessage(ctx context.Context, channel string, msg SlackMessage) (string, error) {
+	var resp struct {
+		TS string `json:"ts"`
+	}
+	err := c.call(ctx, "chat.postMessage", map[string]any{
+		"channel": channel,
+		"text":    msg.Text,
+		"blocks":  msg.Blocks,
+	}, &resp)
+	return resp.TS, err
+}
+
+// UpdateMessage calls chat.update.
+func (c *SlackAPIClient) UpdateMessage(ctx context.Context, channel, ts string, msg SlackMessage) error {
+	return c.call(ctx, "chat.update", map[string]any{
+		"channel": channel,
+		"ts":      ts,
+		"text":    msg.Text,
+		"blocks":  msg.Blocks,
+	}, nil)
+}
+
+// call invokes a Web API method and decodes the response into out.
+// Slack reports failures with "ok": false and an error code rather than the HTTP status.
+func (c *SlackAPIClient) call(ctx context.Context, method string, params map[string]any, out any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", method, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", method, err)
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return fmt.Errorf("unexpected %s response (HTTP %d): %w", method, resp.StatusCode, err)
+	}
+	if !status.OK {
+		return fmt.Errorf("%s failed: %s", method, status.Error)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("failed to decode %s response: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// SlackInteractor asks questions as Slack messages. Choices are rendered as buttons and a click
+// answers the question; a reply in the message's thread answers it with free text.
+// Serve InteractionHandler as the app's interactivity request URL and EventsHandler
+// as its event subscription URL to receive the answers.
+type SlackInteractor struct {
+	client        SlackClient
+	channel       string
+	signingSecret string
+	timeout       time.Duration
+	// now is replaced in tests to check request signatures at a fixed time.
+	now func() time.Time
+
+	// pending holds the posted questions by the timestamps of their messages.
+	pending pendingAnswers[string]
+}
+
+// SlackOption configures a SlackInteractor.
+type SlackOption func(*SlackInteractor)
+
+// WithSlackSigningSecret makes the handlers reject requests that aren't signed by Slack with the app's signing secret.
+func WithSlackSigningSecret(secret string) SlackOption {
+	return func(s *SlackInteractor) {
+		s.signingSecret = secret
+	}
+}
+
+// WithSlackAnswerTimeout sets how long Interact waits for an answer before marking the question as expired.
+// Zero, the default, waits until the context is done.
+func WithSlackAnswerTimeout(timeout time.Duration) SlackOption {
+	return func(s *SlackInteractor) {
+		s.timeout = timeout
+	}
+}
+
+// NewSlackInteractor creates a SlackInteractor that posts questions to the channel through the client.
+func NewSlackInteractor(client SlackClient, channel string, opts ...SlackOption) *SlackInteractor {
+	s := &SlackInteractor{
+		client:  client,
+		channel: channel,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Interact posts the question and blocks until it is answered in Slack, the timeout expires
+// or the context is done. It implements UserInteractionFunc.
+func (s *SlackInteractor) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	ts, pending, err := s.pending.post(input, func() (string, error) {
+		return s.client.PostMessage(ctx, s.channel, slackQuestionMessage(input))
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to post the question to Slack: %w", err)
+	}
+	defer s.pending.remove(ts)
+
+	answer, err := pending.wait(ctx, s.timeout)
+	// the message is updated even when the context is done, so that nobody answers a question that is gone
+	updateCtx := context.WithoutCancel(ctx)
+	var timeoutErr *ErrAnswerTimeout
+	switch {
+	case err == nil:
+		_ = s.client.UpdateMessage(updateCtx, s.channel, ts, slackClosedMessage(input, "Answered: "+answer))
+	case errors.As(err, &timeoutErr):
+		_ = s.client.UpdateMessage(updateCtx, s.channel, ts, slackClosedMessage(input, "expired"))
+	default:
+		_ = s.client.UpdateMessage(updateCtx, s.channel, ts, slackClosedMessage(input, "cancelled"))
+	}
+	return answer, err
+}
+
+// InteractionHandler returns the handler for the interactivity callback that Slack calls when a button is clicked.
+func (s *SlackInteractor) InteractionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := s.readSigned(w, r)
+		if !ok {
+			return
+		}
+		form, err := parseSlackForm(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var payload struct {
+			Type      string `json:"type"`
+			Container struct {
+				MessageTS string `json:"message_ts"`
+			} `json:"container"`
+			Actions []struct {
+				ActionID string `json:"action_id"`
+				Value    string `json:"value"`
+			} `json:"actions"`
+		}
+		if err := json.Unmarshal([]byte(form), &payload); err != nil {
+			http.Error(w, "invalid payload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Slack expects a quick 200 even for interactions that don't answer anything
+		if payload.Type != "block_actions" {
+			return
+		}
+		for _, action := range payload.Actions {
+			if !strings.HasPrefix(action.ActionID, slackChoiceAction) {
+				continue
+			}
+			s.pending.answer(payload.Container.MessageTS, func(input QuestionInput) (string, bool) {
+				return slackChoice(input, action.Value)
+			})
+		}
+	})
+}
+
+// EventsHandler returns the handler for the Events API. Replies in the thread of a question answer it.
+// The app must be subscribed to message events of the channel.
+func (s *SlackInteractor) EventsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := s.readSigned(w, r)
+		if !ok {
+			return
+		}
+
+		var envelope struct {
+			Type      string `json:"type"`
+			Challenge string `json:"challenge"`
+			Event     struct {
+				Type     string `json:"type"`
+				Subtype  string `json:"subtype"`
+				BotID    string `json:"bot_id"`
+				ThreadTS string `json:"thread_ts"`
+				Text     string `json:"text"`
+			} `json:"event"`
+		}
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			http.Error(w, "invalid event: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch envelope.Type {
+		case "url_verification":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = io.WriteString(w, envelope.Challenge)
+		case "event_callback":
+			event := envelope.Event
+			// the bot's own messages and edits are not answers
+			if event.Type != "message" || event.Subtype != "" || event.BotID != "" || event.ThreadTS == "" {
+				return
+			}
+			if text := strings.TrimSpace(event.Text); text != "" {
+				s.pending.answer(event.ThreadTS, func(QuestionInput) (string, bool) {
+					return text, true
+				})
+			}
+		}
+	})
+}
+
+// slackChoice resolves the value of a clicked button into the text of the choice.
+func slackChoice(input QuestionInput, value string) (string, bool) {
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 0 || index >= len(input.Choices) {
+		return "", false
+	}
+	return input.Choices[index], true
+}
+
+// readSigned reads the request body and checks its signature when a signing secret is configured.
+// It replies with an error and returns false when the request is rejected.
+func (s *SlackInteractor) readSigned(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return nil, false
+	}
+	if s.signingSecret == "" {
+		return body, true
+	}
+	if err := verifySlackSignature(s.signingSecret, r.Header, body, s.now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	return body, true
+}
+
+// verifySlackSignature checks the X-Slack-Signature header of a request signed with the secret.
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("missing request timestamp")
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > slackMaxSignatureAge || age < -slackMaxSignatureAge {
+		return errors.New("request timestamp is too old")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature"))) {
+		return errors.New("invalid request signature")
+	}
+	return nil
+}
+
+// parseSlackForm extracts the JSON payload from an interactivity request, which Slack sends form-encoded.
+func parseSlackForm(body []byte) (string, error) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", fmt.Errorf("invalid form: %w", err)
+	}
+	payload := form.Get("payload")
+	if payload == "" {
+		return "", errors.New("missing payload")
+	}
+	return payload, nil
+}
+
+// slackQuestionMessage renders the question with its reason, its images and a button for each choice.
+// Slack only shows images it can fetch, so data URIs are described instead.
+func slackQuestionMessage(input QuestionInput) SlackMessage {
+	text := "*" + input.Question + "*"
+	if input.Reason != "" {
+		text += "\n" + input.Reason
+	}
+	for _, media := range input.Media {
+		if media.IsDataURI() {
+			text += "\n_An image attached to the question can't be shown in Slack"
+			if media.Alt != "" {
+				text += ": " + media.Alt
+			}
+			text += "_"
+		}
+	}
+	text += "\n_Reply in the thread to answer in your own words._"
+	blocks := []SlackBlock{{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: text}}}
+	for _, media := range input.Media {
+		if !media.IsDataURI() {
+			alt := cmp.Or(media.Alt, "an image attached to the question")
+			blocks = append(blocks, SlackBlock{Type: "image", ImageURL: media.URL, AltText: alt})
+		}
+	}
+	if len(input.Choices) > 0 {
+		buttons := make([]SlackElement, 0, len(input.Choices))
+		for i, choice := range input.Choices {
+			buttons = append(buttons, SlackElement{
+				Type:     "button",
+				Text:     &SlackText{Type: "plain_text", Text: choice},
+				ActionID: slackChoiceAction + strconv.Itoa(i),
+				Value:    strconv.Itoa(i),
+			})
+		}
+		blocks = append(blocks, SlackBlock{Type: "actions", Elements: buttons})
+	}
+	return SlackMessage{Text: input.Question, Blocks: blocks}
+}
+
+// slackClosedMessage renders a question that can no longer be answered, without its buttons.
+func slackClosedMessage(input QuestionInput, status string) SlackMessage {
+	text := "*" + input.Question + "*\n_" + status + "_"
+	return SlackMessage{
+		Text:   input.Question + " (" + status + ")",
+		Blocks: []SlackBlock{{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: text}}},
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSlack records posted and updated messages instead of calling Slack.
+type fakeSlack struct {
+	mu      sync.Mutex
+	posted  []SlackMessage
+	updates map[string]SlackMessage
+	postErr error
+}
+
+func (f *fakeSlack) PostMessage(_ context.Context, _ string, msg SlackMessage) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.postErr != nil {
+		return "", f.postErr
+	}
+	f.posted = append(f.posted, msg)
+	return fmt.Sprintf("1700000000.%06d", len(f.posted)), nil
+}
+
+func (f *fakeSlack) UpdateMessage(_ context.Context, _, ts string, msg SlackMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.updates == nil {
+		f.updates = make(map[string]SlackMessage)
+	}
+	f.updates[ts] = msg
+	return nil
+}
+
+func (f *fakeSlack) postedCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.posted)
+}
+
+func (f *fakeSlack) update(ts string) SlackMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.updates[ts]
+}
+
+// clickButton sends the interactivity callback for a click on the choice button.
+func clickButton(t *testing.T, handler http.Handler, ts string, index int) *httptest.ResponseRecorder {
+	t.Helper()
+	payload, err := json.Marshal(map[string]any{
+		"type":      "block_actions",
+		"container": map[string]any{"message_ts": ts},
+		"actions":   []map[string]any{{"action_id": slackChoiceAction + strconv.Itoa(index), "value": strconv.Itoa(index)}},
+	})
+	require.NoError(t, err)
+	body := url.Values{"payload": {string(payload)}}.Encode()
+	req := httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// replyInThread sends the event for a message posted in the thread of the question.
+func replyInThread(t *testing.T, handler http.Handler, ts, text string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{
+		"type":  "event_callback",
+		"event": map[string]any{"type": "message", "thread_ts": ts, "text": text},
+	})
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/slack/events", strings.NewReader(string(body))))
+	return rec
+}
+
+// slackResult is what Interact returned.
+type slackResult struct {
+	answer string
+	err    error
+}
+
+// askSlack runs Interact in the background and waits until the question is posted.
+func askSlack(t *testing.T, slack *fakeSlack, interactor *SlackInteractor, input QuestionInput) <-chan slackResult {
+	t.Helper()
+	posted := slack.postedCount()
+	done := make(chan slackResult, 1)
+	go func() {
+		answer, err := interactor.Interact(context.Background(), input)
+		done <- slackResult{answer, err}
+	}()
+	require.Eventually(t, func() bool { return slack.postedCount() == posted+1 }, time.Second, time.Millisecond)
+	return done
+}
+
+func TestSlackInteractor_ButtonClick(t *testing.T) {
+	slack := &fakeSlack{}
+	interactor := NewSlackInteractor(slack, "C123", WithSlackAnswerTimeout(time.Second))
+	input := QuestionInput{Question: "What gender?", Reason: "To pick toys", Choices: []string{"Boy", "Girl", "Both"}}
+
+	done := askSlack(t, slack, interactor, input)
+	ts := "1700000000.000001"
+	buttons := slack.posted[0].Blocks[1].Elements
+	require.Len(t, buttons, 3)
+	assert.Equal(t, "Girl", buttons[1].Text.Text)
+	assert.Contains(t, slack.posted[0].Blocks[0].Text.Text, "To pick toys")
+
+	assert.Equal(t, http.StatusOK, clickButton(t, interactor.InteractionHandler(), ts, 2).Code)
+	result := <-done
+	require.NoError(t, result.err)
+	assert.Equal(t, "Both", result.answer)
+	assert.Contains(t, slack.update(ts).Text, "Answered: Both")
+	assert.Empty(t, slack.update(ts).Blocks[0].Elements, "buttons are removed")
+
+	// a second click on the answered question is ignored
+	assert.Equal(t, http.StatusOK, clickButton(t, interactor.InteractionHandler(), ts, 0).Code)
+}
+
+func TestSlackQuestionMessage_Media(t *testing.T) {
+	input := QuestionInput{
+		Question: "Which of these bikes looks right?",
+		Choices:  []string{"Red", "Blue"},
+		Media: []Media{
+			{URL: "https://example.com/red.png", Alt: "a red bike"},
+			{URL: "https://example.com/blue.png"},
+			{URL: "data:image/png;base64,aGVsbG8=", Alt: "a green bike"},
+		},
+	}
+
+	msg := slackQuestionMessage(input)
+
+	require.Len(t, msg.Blocks, 4)
+	assert.Contains(t, msg.Blocks[0].Text.Text, "_An image attached to the question can't be shown in Slack: a green bike_")
+	assert.Equal(t, SlackBlock{Type: "image", ImageURL: "https://example.com/red.png", AltText: "a red bike"}, msg.Blocks[1])
+	assert.Equal(t, SlackBlock{Type: "image", ImageURL: "https://example.com/blue.png", AltText: "an image attached to the question"}, msg.Blocks[2])
+	assert.Equal(t, "actions", msg.Blocks[3].Type)
+}
+
+func TestSlackInteractor_ThreadReply(t *testing.T) {
+	slack := &fakeSlack{}
+	interactor := NewSlackInteractor(slack, "C123", WithSlackAnswerTimeout(time.Second))
+
+	first := askSlack(t, slack, interactor, QuestionInput{Question: "What gender?"})
+	second := askSlack(t, slack, interactor, QuestionInput{Question: "What age?"})
+
+	// answers are mapped back to their questions by the thread timestamp
+	replyInThread(t, interactor.EventsHandler(), "1700000000.000002", " 8 and 11 ")
+	replyInThread(t, interactor.EventsHandler(), "1700000000.000001", "Twins")
+
+	result := <-first
+	require.NoError(t, result.err)
+	assert.Equal(t, "Twins", result.answer)
+	result = <-second
+	require.NoError(t, result.err)
+	assert.Equal(t, "8 and 11", result.answer)
+}
+
+func TestSlackInteractor_Timeout(t *testing.T) {
+	slack := &fakeSlack{}
+	interactor := NewSlackInteractor(slack, "C123", WithSlackAnswerTimeout(20*time.Millisecond))
+
+	_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?", Choices: []string{"Boy"}})
+
+	var timeoutErr *ErrAnswerTimeout
+	require.True(t, errors.As(err, &timeoutErr))
+	assert.Contains(t, slack.update("1700000000.000001").Text, "expired")
+}
+
+func TestSlackInteractor_PostFailure(t *testing.T) {
+	interactor := NewSlackInteractor(&fakeSlack{postErr: errors.New("channel_not_found")}, "C123")
+
+	_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?"})
+	assert.ErrorContains(t, err, "channel_not_found")
+}
+
+func TestSlackInteractor_Events(t *testing.T) {
+	interactor := NewSlackInteractor(&fakeSlack{}, "C123")
+
+	rec := httptest.NewRecorder()
+	interactor.EventsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/slack/events",
+		strings.NewReader(`{"type":"url_verification","challenge":"3eZbrw1aB"}`)))
+	assert.Equal(t, "3eZbrw1aB", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	interactor.InteractionHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/slack/interactions", strings.NewReader("payload=")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	interactor.EventsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/slack/events",
+		strings.NewReader(`{"type":"url_verification","challenge":"`+strings.Repeat("a", maxRequestBodySize)+`"}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "a body over the limit is rejected")
+}
+
+func TestSlackInteractor_Signature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := `{"type":"url_verification","challenge":"ok"}`
+	sign := func(secret, timestamp string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		_, _ = io.WriteString(mac, "v0:"+timestamp+":"+body)
+		return "v0=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		status    int
+	}{
+		{name: "valid", timestamp: "1700000000", signature: sign("secret", "1700000000"), status: http.StatusOK},
+		{name: "wrong secret", timestamp: "1700000000", signature: sign("other", "1700000000"), status: http.StatusUnauthorized},
+		{name: "replayed", timestamp: "1699990000", signature: sign("secret", "1699990000"), status: http.StatusUnauthorized},
+		{name: "unsigned", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interactor := NewSlackInteractor(&fakeSlack{}, "C123", WithSlackSigningSecret("secret"))
+			interactor.now = func() time.Time { return now }
+
+			req := httptest.NewRequest(http.MethodPost, "/slack/events", strings.NewReader(body))
+			req.Header.Set("X-Slack-Request-Timestamp", tt.timestamp)
+			req.Header.Set("X-Slack-Signature", tt.signature)
+			rec := httptest.NewRecorder()
+			interactor.EventsHandler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
+
+func TestSlackAPIClient(t *testing.T) {
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer xoxb-token", r.Header.Get("Authorization"))
+		var params map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		params["method"] = strings.TrimPrefix(r.URL.Path, "/")
+		requests = append(requests, params)
+
+		if params["method"] == "chat.update" {
+			_, _ = io.WriteString(w, `{"ok":false,"error":"message_not_found"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"ok":true,"ts":"1700000000.000001"}`)
+	}))
+	defer server.Close()
+
+	client := NewSlackAPIClient("xoxb-token")
+	client.baseURL = server.URL + "/"
+
+	ts, err := client.PostMessage(context.Background(), "C123", slackQuestionMessage(QuestionInput{Question: "What gender?"}))
+	require.NoError(t, err)
+	assert.Equal(t, "1700000000.000001", ts)
+
+	err = client.UpdateMessage(context.Background(), "C123", ts, slackClosedMessage(QuestionInput{Question: "What gender?"}, "expired"))
+	assert.ErrorContains(t, err, "message_not_found")
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "chat.postMessage", requests[0]["method"])
+	assert.Equal(t, "C123", requests[0]["channel"])
+	assert.Equal(t, ts, requests[1]["ts"])
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// twilioAPIURL is the base URL of the Twilio REST API.
+const twilioAPIURL = "https://api.twilio.com/2010-04-01/"
+
+// InboundSMS is a text message received from a phone.
+type InboundSMS struct {
+	From string
+	Body string
+}
+
+// SMSProvider sends text messages and parses the webhooks reporting the received ones.
+type SMSProvider interface {
+	// SendSMS sends the text to the phone number.
+	SendSMS(ctx context.Context, to, body string) error
+	// ParseInbound extracts the received message from an inbound-message webhook request.
+	ParseInbound(r *http.Request) (InboundSMS, error)
+}
+
+// TwilioProvider is an SMSProvider sending messages through the Twilio REST API.
+type TwilioProvider struct {
+	accountSID string
+	authToken  string
+	from       string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewTwilioProvider creates a TwilioProvider that sends messages from the phone number.
+func NewTwilioProvider(accountSID, authToken, from string) *TwilioProvider {
+	return &TwilioProvider{
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		baseURL:    twilioAPIURL,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// SendSMS creates a message resource.
+func (p *TwilioProvider) SendSMS(ctx context.Context, to, body string) error {
+	form := url.Values{"To": {to}, "From": {p.from}, "Body": {body}}
+	endpoint := p.baseURL + "Accounts/" + url.PathEscape(p.accountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.accountSID, p.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send the message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to send the message (HTTP %d): %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	return nil
+}
+
+// ParseInbound reads the From and Body parameters Twilio posts for a received message.
+func (p *TwilioProvider) ParseInbound(r *http.Request) (InboundSMS, error) {
+	if err := r.ParseForm(); err != nil {
+		return InboundSMS{}, err
+	}
+	msg := InboundSMS{From: r.PostForm.Get("From"), Body: r.PostForm.Get("Body")}
+	if msg.From == "" {
+		return InboundSMS{}, errors.New("the message has no sender")
+	}
+	return msg, nil
+}
+
+// smsPending is a question waiting for its turn to be sent and then for the reply.
+type smsPending struct {
+	*outstandingQuestion
+	// turn is closed once the question is first in its phone's queue and may be sent.
+	turn chan struct{}
+	sent bool
+}
+
+// SMSInteractor asks questions by text message, for people who only have SMS. Choices are numbered and
+// answered by replying with the number. A phone has one question at a time: further questions to the same
+// number wait in a queue until the previous one is answered, so that replies can't be mixed up.
+type SMSInteractor struct {
+	provider SMSProvider
+	to       string
+	timeout  time.Duration
+
+	mu sync.Mutex
+	// queues holds the questions of each phone number, the one that was sent first.
+	queues map[string][]*smsPending
+}
+
+// SMSOption configures an SMSInteractor.
+type SMSOption func(*SMSInteractor)
+
+// WithSMSAnswerTimeout sets how long Interact waits for the reply once the question is sent.
+// Zero, the default, waits until the context is done.
+func WithSMSAnswerTimeout(timeout time.Duration) SMSOption {
+	return func(s *SMSInteractor) {
+		s.timeout = timeout
+	}
+}
+
+// NewSMSInteractor creates an SMSInteractor asking the phone number through the provider.
+// Serve its InboundHandler as the provider's inbound-message webhook to receive the replies.
+func NewSMSInteractor(provider SMSProvider, to string, opts ...SMSOption) *SMSInteractor {
+	s := &SMSInteractor{
+		provider: provider,
+		to:       to,
+		queues:   make(map[string][]*smsPending),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Interact asks the phone number the interactor was created for. It implements UserInteractionFunc.
+func (s *SMSInteractor) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	return s.ask(ctx, s.to, input)
+}
+
+// InteractWith returns a UserInteractionFunc asking another phone number through the same webhook.
+func (s *SMSInteractor) InteractWith(to string) UserInteractionFunc {
+	return func(ctx context.Context, input QuestionInput) (string, error) {
+		return s.ask(ctx, to, input)
+	}
+}
+
+// ask queues the question for the phone number, sends it once it is first in the queue and blocks until
+// the reply arrives, the timeout expires or the context is done.
+func (s *SMSInteractor) ask(ctx context.Context, to string, input QuestionInput) (string, error) {
+	phone := normalizePhone(to)
+	pending := &smsPending{outstandingQuestion: newOutstandingQuestion(input), turn: make(chan struct{})}
+	s.enqueue(phone, pending)
+	defer s.dequeue(phone, pending)
+
+	select {
+	case <-pending.turn:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	// the lock is held while sending so that a reply can't arrive before the question is marked as sent
+	s.mu.Lock()
+	err := s.provider.SendSMS(ctx, to, smsQuestionText(input))
+	pending.sent = err == nil
+	s.mu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("failed to send the question by SMS: %w", err)
+	}
+	return pending.wait(ctx, s.timeout)
+}
+
+// enqueue appends the question to the phone's queue, giving it the turn when the queue was empty.
+func (s *SMSInteractor) enqueue(phone string, pending *smsPending) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queues[phone] = append(s.queues[phone], pending)
+	if len(s.queues[phone]) == 1 {
+		close(pending.turn)
+	}
+}
+
+// dequeue removes the question from the phone's queue and gives the turn to the next one.
+func (s *SMSInteractor) dequeue(phone string, pending *smsPending) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queue := s.queues[phone]
+	for i, p := range queue {
+		if p != pending {
+			continue
+		}
+		queue = append(queue[:i:i], queue[i+1:]...)
+		if i == 0 && len(queue) > 0 {
+			close(queue[0].turn)
+		}
+		break
+	}
+	if len(queue) == 0 {
+		delete(s.queues, phone)
+		return
+	}
+	s.queues[phone] = queue
+}
+
+// InboundHandler returns the HTTP handler for the provider's inbound-message webhook.
+// A reply answers the question sent to its sender; replies from phones without one are ignored.
+func (s *SMSInteractor) InboundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		msg, err := s.provider.ParseInbound(r)
+		if err != nil {
+			http.Error(w, "invalid inbound message: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.receive(r.Context(), msg)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// receive answers the question sent to the sender of the message. A number that doesn't correspond
+// to a choice is answered with a hint and the question stays pending.
+func (s *SMSInteractor) receive(ctx context.Context, msg InboundSMS) {
+	phone := normalizePhone(msg.From)
+
+	s.mu.Lock()
+	queue := s.queues[phone]
+	if len(queue) == 0 || !queue[0].sent {
+		s.mu.Unlock()
+		return
+	}
+	pending := queue[0]
+	text := strings.TrimSpace(msg.Body)
+	answer, ok := resolveChoice(pending.input.Choices, text)
+	if !ok || text == "" {
+		s.mu.Unlock()
+		_ = s.provider.SendSMS(ctx, msg.From, fmt.Sprintf("Please reply with a number from 1 to %d.", len(pending.input.Choices)))
+		return
+	}
+	// the question stops being sent so that a second reply isn't taken as its answer;
+	// the next question in the queue gets its turn once Interact returns
+	pending.sent = false
+	s.mu.Unlock()
+
+	pending.answerCh <- answer
+}
+
+// smsQuestionText renders the question with its reason and numbered choices.
+func smsQuestionText(input QuestionInput) string {
+	var text strings.Builder
+	text.WriteString(input.Question)
+	if input.Reason != "" {
+		text.WriteString("\n" + input.Reason)
+	}
+	if len(input.Choices) == 0 {
+		return text.String()
+	}
+
+	numbers := make([]string, len(input.Choices))
+	for i, choice := range input.Choices {
+		fmt.Fprintf(&text, "\n%d) %s", i+1, choice)
+		numbers[i] = fmt.Sprint(i + 1)
+	}
+	text.WriteString("\nReply " + strings.Join(numbers, "/"))
+	return text.String()
+}
+
+// normalizePhone drops the formatting of a phone number so that "+1 (555) 010-0000" matches "+15550100000".
+func normalizePhone(phone string) string {
+	var normalized strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+			normalized.WriteRune(r)
+		}
+	}
+	return normalized.String()
+}
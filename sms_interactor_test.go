@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sentSMS is a message sent through the fakeSMSProvider.
+type sentSMS struct {
+	to   string
+	body string
+}
+
+// fakeSMSProvider records the sent messages and parses inbound webhooks as plain forms.
+type fakeSMSProvider struct {
+	mu   sync.Mutex
+	sent []sentSMS
+	err  error
+}
+
+func (f *fakeSMSProvider) SendSMS(_ context.Context, to, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, sentSMS{to: to, body: body})
+	return nil
+}
+
+func (f *fakeSMSProvider) ParseInbound(r *http.Request) (InboundSMS, error) {
+	if err := r.ParseForm(); err != nil {
+		return InboundSMS{}, err
+	}
+	if r.PostForm.Get("From") == "" {
+		return InboundSMS{}, errors.New("the message has no sender")
+	}
+	return InboundSMS{From: r.PostForm.Get("From"), Body: r.PostForm.Get("Body")}, nil
+}
+
+func (f *fakeSMSProvider) messages() []sentSMS {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]sentSMS(nil), f.sent...)
+}
+
+// waitForSMS waits until count messages were sent and returns them.
+func waitForSMS(t *testing.T, provider *fakeSMSProvider, count int) []sentSMS {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		return len(provider.messages()) >= count
+	}, time.Second, time.Millisecond)
+	return provider.messages()
+}
+
+// replySMS posts an inbound message to the webhook and returns the response status.
+func replySMS(t *testing.T, server *httptest.Server, from, body string) int {
+	t.Helper()
+	resp, err := http.PostForm(server.URL, url.Values{"From": {from}, "Body": {body}})
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSMSInteractor_RunAgent(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createToolRequestPart("askQuestion", "What are their ages?", nil),
+			),
+			createTextResponse("Based on both genders and ages...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	provider := &fakeSMSProvider{}
+	interactor := NewSMSInteractor(provider, "+1 (555) 010-0000", WithSMSAnswerTimeout(time.Second))
+	server := httptest.NewServer(interactor.InboundHandler())
+	defer server.Close()
+
+	go func() {
+		msgs := waitForSMS(t, provider, 1)
+		assert.Equal(t, sentSMS{to: "+1 (555) 010-0000", body: "What gender are the children?\n1) Boy\n2) Girl\n3) Both\nReply 1/2/3"}, msgs[0])
+		assert.Equal(t, http.StatusNoContent, replySMS(t, server, "+15550100000", "3"))
+
+		msgs = waitForSMS(t, provider, 2)
+		assert.Equal(t, "What are their ages?", msgs[1].body)
+		assert.Equal(t, http.StatusNoContent, replySMS(t, server, "+15550100000", "8 and 11"))
+	}()
+
+	result, err := RunAgent(context.Background(), &Options{
+		generator: mockGen,
+		responseHandler: &InterruptionHandler{
+			generator:       mockGen,
+			UserInteraction: interactor.Interact,
+		},
+	})
+
+	require.NoError(t, err)
+	assert.Contains(t, result, "Based on")
+}
+
+func TestSMSInteractor_QueuesQuestionsPerNumber(t *testing.T) {
+	provider := &fakeSMSProvider{}
+	interactor := NewSMSInteractor(provider, "+15550100000")
+	server := httptest.NewServer(interactor.InboundHandler())
+	defer server.Close()
+
+	type result struct {
+		answer string
+		err    error
+	}
+	gender, budget, other := make(chan result, 1), make(chan result, 1), make(chan result, 1)
+	go func() {
+		answer, err := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}})
+		gender <- result{answer, err}
+	}()
+	waitForSMS(t, provider, 1)
+	go func() {
+		answer, err := interactor.Interact(context.Background(), QuestionInput{Question: "What budget?"})
+		budget <- result{answer, err}
+	}()
+	go func() {
+		answer, err := interactor.InteractWith("+15550199999")(context.Background(), QuestionInput{Question: "Approve?"})
+		other <- result{answer, err}
+	}()
+
+	// the other number gets its question right away while the second question to the first number waits
+	msgs := waitForSMS(t, provider, 2)
+	assert.Equal(t, sentSMS{to: "+15550199999", body: "Approve?"}, msgs[1])
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, provider.messages(), 2, "the queued question isn't sent before the first is answered")
+
+	// an out-of-range number gets a hint and the question stays pending
+	replySMS(t, server, "+15550100000", "7")
+	msgs = waitForSMS(t, provider, 3)
+	assert.Equal(t, sentSMS{to: "+15550100000", body: "Please reply with a number from 1 to 2."}, msgs[2])
+
+	replySMS(t, server, "+15550100000", "2")
+	assert.Equal(t, result{answer: "Girl"}, <-gender)
+
+	msgs = waitForSMS(t, provider, 4)
+	assert.Equal(t, sentSMS{to: "+15550100000", body: "What budget?"}, msgs[3])
+	replySMS(t, server, "+15550100000", "$80")
+	assert.Equal(t, result{answer: "$80"}, <-budget)
+
+	replySMS(t, server, "+15550199999", "yes")
+	assert.Equal(t, result{answer: "yes"}, <-other)
+}
+
+func TestSMSInteractor_Inbound(t *testing.T) {
+	provider := &fakeSMSProvider{}
+	interactor := NewSMSInteractor(provider, "+15550100000")
+	server := httptest.NewServer(interactor.InboundHandler())
+	defer server.Close()
+
+	assert.Equal(t, http.StatusBadRequest, replySMS(t, server, "", "hello"))
+	assert.Equal(t, http.StatusNoContent, replySMS(t, server, "+15550100000", "hello"), "a reply without a question is ignored")
+	assert.Empty(t, provider.messages())
+}
+
+func TestSMSInteractor_TimeoutAndCancel(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		interactor := NewSMSInteractor(&fakeSMSProvider{}, "+15550100000", WithSMSAnswerTimeout(10*time.Millisecond))
+
+		_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?"})
+
+		var timeoutErr *ErrAnswerTimeout
+		assert.True(t, errors.As(err, &timeoutErr))
+	})
+
+	t.Run("queued question cancelled", func(t *testing.T) {
+		provider := &fakeSMSProvider{}
+		interactor := NewSMSInteractor(provider, "+15550100000", WithSMSAnswerTimeout(20*time.Millisecond))
+		first := make(chan error, 1)
+		go func() {
+			_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?"})
+			first <- err
+		}()
+		waitForSMS(t, provider, 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := interactor.Interact(ctx, QuestionInput{Question: "What budget?"})
+		assert.ErrorIs(t, err, context.Canceled)
+
+		var timeoutErr *ErrAnswerTimeout
+		assert.True(t, errors.As(<-first, &timeoutErr))
+		assert.Len(t, provider.messages(), 1, "the cancelled question is never sent")
+		assert.Empty(t, interactor.queues)
+	})
+
+	t.Run("send failure", func(t *testing.T) {
+		interactor := NewSMSInteractor(&fakeSMSProvider{err: errors.New("no credit")}, "+15550100000")
+
+		_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?"})
+
+		assert.ErrorContains(t, err, "no credit")
+	})
+}
+
+func TestTwilioProvider(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if r.URL.Path != "/Accounts/AC123/Messages.json" || user != "AC123" || password != "secret" {
+			http.Error(w, `{"message": "Authenticate"}`, http.StatusUnauthorized)
+			return
+		}
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	provider := NewTwilioProvider("AC123", "secret", "+15550111111")
+	provider.baseURL = server.URL + "/"
+	require.NoError(t, provider.SendSMS(context.Background(), "+15550100000", "What gender?"))
+	assert.Equal(t, url.Values{"To": {"+15550100000"}, "From": {"+15550111111"}, "Body": {"What gender?"}}, form)
+
+	provider.authToken = "wrong"
+	assert.ErrorContains(t, provider.SendSMS(context.Background(), "+15550100000", "What gender?"), "HTTP 401")
+
+	req := httptest.NewRequest(http.MethodPost, "/sms", strings.NewReader("From=%2B15550100000&Body=2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	msg, err := provider.ParseInbound(req)
+	require.NoError(t, err)
+	assert.Equal(t, InboundSMS{From: "+15550100000", Body: "2"}, msg)
+}
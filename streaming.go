@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"slices"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+type chunkCallbackKey struct{}
+
+// withChunkCallback returns a context whose responses generated with generateResponse are streamed to
+// onChunk.
+func withChunkCallback(ctx context.Context, onChunk func(chunk *ai.ModelResponseChunk) error) context.Context {
+	return context.WithValue(ctx, chunkCallbackKey{}, onChunk)
+}
+
+// generateResponse generates a response of the run with the generator. When ctx carries a chunk
+// callback, the response is streamed to it, leaving out the tool requests, which the user isn't meant
+// to see; the interrupts are handled from the response returned either way. A response the provider
+// blocked is returned as ErrContentBlocked. The generation config of the run, if any, is added to opts.
+func generateResponse(ctx context.Context, generator Generator, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	var resp *ai.ModelResponse
+	var err error
+	opts = append(opts, generationConfigOptions(ctx)...)
+	onChunk, ok := ctx.Value(chunkCallbackKey{}).(func(chunk *ai.ModelResponseChunk) error)
+	if !ok {
+		resp, err = generator.Generate(ctx, opts...)
+	} else {
+		resp, err = generator.GenerateStream(ctx, func(chunk *ai.ModelResponseChunk) error {
+			if visible := visibleChunk(chunk); visible != nil {
+				return onChunk(visible)
+			}
+			return nil
+		}, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := blockedError(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// visibleChunk returns the chunk without its tool requests, or nil when nothing else is left.
+func visibleChunk(chunk *ai.ModelResponseChunk) *ai.ModelResponseChunk {
+	if chunk == nil || !slices.ContainsFunc(chunk.Content, (*ai.Part).IsToolRequest) {
+		return chunk
+	}
+	visible := *chunk
+	visible.Content = slices.DeleteFunc(slices.Clone(chunk.Content), (*ai.Part).IsToolRequest)
+	if len(visible.Content) == 0 {
+		return nil
+	}
+	return &visible
+}
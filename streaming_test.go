@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// textChunk returns a chunk of model text.
+func textChunk(text string) *ai.ModelResponseChunk {
+	return &ai.ModelResponseChunk{Role: ai.RoleModel, Content: []*ai.Part{ai.NewTextPart(text)}}
+}
+
+// chunkTexts returns a chunk callback appending the text of every chunk to texts.
+func chunkTexts(texts *[]string) func(chunk *ai.ModelResponseChunk) error {
+	return func(chunk *ai.ModelResponseChunk) error {
+		*texts = append(*texts, chunk.Text())
+		return nil
+	}
+}
+
+func TestVisibleChunk(t *testing.T) {
+	question := createInterruptPart("askQuestion", map[string]any{"question": "Who is the gift for?"})
+
+	tests := []struct {
+		name     string
+		chunk    *ai.ModelResponseChunk
+		expected *ai.ModelResponseChunk
+	}{
+		{name: "text", chunk: textChunk("A LEGO set"), expected: textChunk("A LEGO set")},
+		{name: "tool request", chunk: &ai.ModelResponseChunk{Role: ai.RoleModel, Content: []*ai.Part{question}}},
+		{
+			name:     "text and tool request",
+			chunk:    &ai.ModelResponseChunk{Role: ai.RoleModel, Index: 1, Content: []*ai.Part{ai.NewTextPart("Let me ask. "), question}},
+			expected: &ai.ModelResponseChunk{Role: ai.RoleModel, Index: 1, Content: []*ai.Part{ai.NewTextPart("Let me ask. ")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, visibleChunk(tt.chunk))
+		})
+	}
+}
+
+func TestRunAgent_Streaming(t *testing.T) {
+	question := createInterruptPart("askQuestion", map[string]any{"question": "Who is the gift for?"})
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(question),
+			createTextResponse("A LEGO set", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	mockGen.chunks = [][]*ai.ModelResponseChunk{
+		{textChunk("Let me ask. "), {Role: ai.RoleModel, Content: []*ai.Part{question}}},
+		{textChunk("A LEGO"), textChunk(" set")},
+	}
+	interactor := &sequenceInteractor{answers: []Answer{{Value: "A 7-year-old boy"}}}
+	var texts []string
+
+	result, err := RunAgent(context.Background(), &Options{
+		generator:       mockGen,
+		responseHandler: &InterruptionHandler{generator: mockGen, Interactor: interactor},
+		onChunk:         chunkTexts(&texts),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Let me ask. ", "A LEGO", " set"}, texts, "the tool request isn't streamed")
+	assert.Equal(t, 1, interactor.asked, "the interrupt of the streamed response is handled")
+	assert.Equal(t, "A LEGO set", result)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, "A 7-year-old boy", parts[0].ToolResponse.Output)
+}
+
+func TestRunAgent_StreamingCallbackError(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("A LEGO set", "stop")}, nil)
+	mockGen.chunks = [][]*ai.ModelResponseChunk{{textChunk("A LEGO"), textChunk(" set")}}
+	stopped := errors.New("client disconnected")
+	calls := 0
+
+	_, err := RunAgent(context.Background(), &Options{
+		generator: mockGen,
+		onChunk: func(*ai.ModelResponseChunk) error {
+			calls++
+			return stopped
+		},
+	})
+
+	assert.ErrorIs(t, err, stopped)
+	assert.Equal(t, 1, calls, "the generation stops at the first error")
+}
+
+func TestRunAgent_NotStreaming(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("A LEGO set", "stop")}, nil)
+	mockGen.chunks = [][]*ai.ModelResponseChunk{{textChunk("A LEGO"), textChunk(" set")}}
+
+	result, err := RunAgent(context.Background(), &Options{generator: mockGen})
+
+	require.NoError(t, err)
+	assert.Equal(t, "A LEGO set", result, "Generate is called without a chunk callback")
+}
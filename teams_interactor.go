@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adaptiveCardContentType is the attachment content type of an Adaptive Card.
+const adaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+
+// teamsAnswerInput is the ID of the text input of free-text questions; Teams submits its value under this key.
+const teamsAnswerInput = "answer"
+
+// AdaptiveCard is an Adaptive Card posted to Teams. Only the fields used by TeamsInteractor are modelled.
+type AdaptiveCard struct {
+	Schema  string        `json:"$schema"`
+	Type    string        `json:"type"`
+	Version string        `json:"version"`
+	Body    []CardElement `json:"body"`
+	Actions []CardAction  `json:"actions,omitempty"`
+}
+
+// CardElement is an element of a card body, such as a text block or a text input.
+type CardElement struct {
+	Type        string `json:"type"`
+	ID          string `json:"id,omitempty"`
+	Text        string `json:"text,omitempty"`
+	Weight      string `json:"weight,omitempty"`
+	IsSubtle    bool   `json:"isSubtle,omitempty"`
+	Wrap        bool   `json:"wrap,omitempty"`
+	Placeholder string `json:"placeholder,omitempty"`
+	IsMultiline bool   `json:"isMultiline,omitempty"`
+}
+
+// CardAction is a card action. Action.Submit sends its data, merged with the values of the card's inputs,
+// back to the bot.
+type CardAction struct {
+	Type  string            `json:"type"`
+	Title string            `json:"title"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// TeamsClient is the part of the Bot Connector API used by TeamsInteractor.
+type TeamsClient interface {
+	// SendCard posts the card to the conversation and returns the ID of the activity carrying it.
+	SendCard(ctx context.Context, conversationID string, card AdaptiveCard) (string, error)
+	// UpdateCard replaces the card of the activity.
+	UpdateCard(ctx context.Context, conversationID, activityID string, card AdaptiveCard) error
+}
+
+// TeamsConnectorClient calls the Bot Connector REST API of the service URL Teams sent along with
+// the conversation, authenticated with a bot access token.
+type TeamsConnectorClient struct {
+	serviceURL string
+	token      string
+	httpClient *http.Client
+}
+
+// NewTeamsConnectorClient creates a TeamsConnectorClient for the service URL and the access token.
+func NewTeamsConnectorClient(serviceURL, token string) *TeamsConnectorClient {
+	return &TeamsConnectorClient{
+		serviceURL: strings.TrimSuffix(serviceURL, "/"),
+		token:      token,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// SendCard creates an activity with the card as its attachment.
+func (c *TeamsConnectorClient) SendCard(ctx context.Context, conversationID string, card AdaptiveCard) (string, error) {
+	var resp struct {
+		ID string `json:"id"`
+	}
+	err := c.call(ctx, http.MethodPost, "/v3/conversations/"+url.PathEscape(conversationID)+"/activities", card, &resp)
+	return resp.ID, err
+}
+
+// UpdateCard replaces the activity with one carrying the card.
+func (c *TeamsConnectorClient) UpdateCard(ctx context.Context, conversationID, activityID string, card AdaptiveCard) error {
+	path := "/v3/conversations/" + url.PathEscape(conversationID) + "/activities/" + url.PathEscape(activityID)
+	return c.call(ctx, http.MethodPut, path, card, nil)
+}
+
+// call sends a message activity with the card to the path and decodes the response into out.
+func (c *TeamsConnectorClient) call(ctx context.Context, method, path string, card AdaptiveCard, out any) error {
+	body, err := json.Marshal(map[string]any{
+		"type": "message",
+		"attachments": []map[string]any{
+			{"contentType": adaptiveCardContentType, "content": card},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the activity: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.serviceURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call the Bot Connector: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read the Bot Connector response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("bot connector responded with HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("failed to decode the Bot Connector response: %w", err)
+		}
+	}
+	return nil
+}
+
+// TeamsInteractor asks questions as Adaptive Cards in a Teams conversation. Choices are rendered as
+// buttons; free-text questions get a text input with a submit button. Serve ActionHandler as the bot's
+// messaging endpoint to receive the submitted cards.
+type TeamsInteractor struct {
+	client         TeamsClient
+	conversationID string
+	webhookSecret  string
+	timeout        time.Duration
+
+	// pending holds the questions by the IDs carried in the submit data.
+	pending pendingAnswers[string]
+}
+
+// TeamsOption configures a TeamsInteractor.
+type TeamsOption func(*TeamsInteractor)
+
+// WithTeamsWebhookSecret makes ActionHandler reject requests that aren't signed with the base64-encoded
+// security token of a Teams outgoing webhook.
+func WithTeamsWebhookSecret(secret string) TeamsOption {
+	return func(t *TeamsInteractor) {
+		t.webhookSecret = secret
+	}
+}
+
+// WithTeamsAnswerTimeout sets how long Interact waits for an answer before disabling the card.
+// Zero, the default, waits until the context is done.
+func WithTeamsAnswerTimeout(timeout time.Duration) TeamsOption {
+	return func(t *TeamsInteractor) {
+		t.timeout = timeout
+	}
+}
+
+// NewTeamsInteractor creates a TeamsInteractor that posts questions to the conversation through the client.
+func NewTeamsInteractor(client TeamsClient, conversationID string, opts ...TeamsOption) *TeamsInteractor {
+	t := &TeamsInteractor{
+		client:         client,
+		conversationID: conversationID,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Interact posts the question card and blocks until it is submitted, the timeout expires or the context
+// is done. The card is then replaced with one without inputs, so that it can't be answered twice.
+// It implements UserInteractionFunc.
+func (t *TeamsInteractor) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	id, err := newCorrelationID()
+	if err != nil {
+		return "", err
+	}
+	// the question is registered before posting so that an early submit finds it
+	pending := t.pending.add(id, input)
+	defer t.pending.remove(id)
+
+	activityID, err := t.client.SendCard(ctx, t.conversationID, teamsQuestionCard(id, input))
+	if err != nil {
+		return "", fmt.Errorf("failed to post the question to Teams: %w", err)
+	}
+
+	answer, err := pending.wait(ctx, t.timeout)
+	// the card is updated even when the context is done, so that nobody answers a question that is gone
+	updateCtx := context.WithoutCancel(ctx)
+	var timeoutErr *ErrAnswerTimeout
+	switch {
+	case err == nil:
+		_ = t.client.UpdateCard(updateCtx, t.conversationID, activityID, teamsClosedCard(input, "Answered: "+answer))
+	case errors.As(err, &timeoutErr):
+		_ = t.client.UpdateCard(updateCtx, t.conversationID, activityID, teamsClosedCard(input, "Expired"))
+	default:
+		_ = t.client.UpdateCard(updateCtx, t.conversationID, activityID, teamsClosedCard(input, "Cancelled"))
+	}
+	return answer, err
+}
+
+// answer resolves the submitted values into the answer of the question and delivers it.
+// It reports false when the question isn't pending anymore or the values don't answer it.
+func (t *TeamsInteractor) answer(id, choice, text string) bool {
+	return t.pending.answer(id, func(input QuestionInput) (string, bool) {
+		if choice != "" {
+			index, err := strconv.Atoi(choice)
+			if err != nil || index < 0 || index >= len(input.Choices) {
+				return "", false
+			}
+			return input.Choices[index], true
+		}
+		answer := cmp.Or(strings.TrimSpace(text), input.Default)
+		return answer, answer != ""
+	})
+}
+
+// ActionHandler returns the handler for the activities Teams sends when a card is submitted.
+// Activities that don't answer a pending question, such as submits of an expired card, are acknowledged
+// and ignored.
+func (t *TeamsInteractor) ActionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
+		if err != nil {
+			http.Error(w, "failed to read request", http.StatusBadRequest)
+			return
+		}
+		if t.webhookSecret != "" {
+			if err := verifyTeamsSignature(t.webhookSecret, r.Header.Get("Authorization"), body); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		var activity struct {
+			Type  string `json:"type"`
+			Value struct {
+				QuestionID string `json:"questionId"`
+				Choice     string `json:"choice"`
+				Answer     string `json:"answer"`
+			} `json:"value"`
+		}
+		if err := json.Unmarshal(body, &activity); err != nil {
+			http.Error(w, "invalid activity: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if activity.Type == "message" && activity.Value.QuestionID != "" {
+			t.answer(activity.Value.QuestionID, activity.Value.Choice, activity.Value.Answer)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// verifyTeamsSignature checks the "HMAC <signature>" Authorization header of an outgoing webhook request.
+// The signature is the base64-encoded HMAC-SHA256 of the body keyed with the base64-decoded secret.
+func verifyTeamsSignature(secret, authorization string, body []byte) error {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return errors.New("invalid webhook secret")
+	}
+	signature, ok := strings.CutPrefix(authorization, "HMAC ")
+	if !ok {
+		return errors.New("missing request signature")
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("invalid request signature")
+	}
+	return nil
+}
+
+// newAdaptiveCard returns a card with the body and actions.
+func newAdaptiveCard(body []CardElement, actions []CardAction) AdaptiveCard {
+	return AdaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body:    body,
+		Actions: actions,
+	}
+}
+
+// teamsQuestionCard renders the question with its reason and a submit button for each choice,
+// or a text input when it has no choices.
+func teamsQuestionCard(id string, input QuestionInput) AdaptiveCard {
+	body := []CardElement{{Type: "TextBlock", Text: input.Question, Weight: "Bolder", Wrap: true}}
+	if input.Reason != "" {
+		body = append(body, CardElement{Type: "TextBlock", Text: input.Reason, IsSubtle: true, Wrap: true})
+	}
+
+	var actions []CardAction
+	if len(input.Choices) > 0 {
+		for i, choice := range input.Choices {
+			actions = append(actions, CardAction{
+				Type:  "Action.Submit",
+				Title: choice,
+				Data:  map[string]string{"questionId": id, "choice": strconv.Itoa(i)},
+			})
+		}
+		return newAdaptiveCard(body, actions)
+	}
+
+	placeholder := "Type your answer"
+	if input.Default != "" {
+		placeholder = "Leave empty for " + input.Default
+	}
+	body = append(body, CardElement{Type: "Input.Text", ID: teamsAnswerInput, Placeholder: placeholder, IsMultiline: true})
+	actions = append(actions, CardAction{Type: "Action.Submit", Title: "Send", Data: map[string]string{"questionId": id}})
+	return newAdaptiveCard(body, actions)
+}
+
+// teamsClosedCard renders a question that can no longer be answered, without its inputs and buttons.
+func teamsClosedCard(input QuestionInput, status string) AdaptiveCard {
+	return newAdaptiveCard([]CardElement{
+		{Type: "TextBlock", Text: input.Question, Weight: "Bolder", Wrap: true},
+		{Type: "TextBlock", Text: status, IsSubtle: true, Wrap: true},
+	}, nil)
+}
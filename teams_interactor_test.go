@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTeams records sent and updated cards instead of calling the Bot Connector.
+type fakeTeams struct {
+	mu      sync.Mutex
+	sent    []AdaptiveCard
+	updates map[string]AdaptiveCard
+	sendErr error
+}
+
+func (f *fakeTeams) SendCard(_ context.Context, _ string, card AdaptiveCard) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sendErr != nil {
+		return "", f.sendErr
+	}
+	f.sent = append(f.sent, card)
+	return fmt.Sprintf("activity-%d", len(f.sent)), nil
+}
+
+func (f *fakeTeams) UpdateCard(_ context.Context, _, activityID string, card AdaptiveCard) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.updates == nil {
+		f.updates = make(map[string]AdaptiveCard)
+	}
+	f.updates[activityID] = card
+	return nil
+}
+
+func (f *fakeTeams) card(i int) AdaptiveCard {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sent[i]
+}
+
+func (f *fakeTeams) sentCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.sent)
+}
+
+func (f *fakeTeams) update(activityID string) AdaptiveCard {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.updates[activityID]
+}
+
+// submitCard sends the activity Teams posts when the action is submitted, with the inputs merged into its data.
+func submitCard(t *testing.T, handler http.Handler, action CardAction, inputs map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	value := make(map[string]string)
+	for k, v := range action.Data {
+		value[k] = v
+	}
+	for k, v := range inputs {
+		value[k] = v
+	}
+	body, err := json.Marshal(map[string]any{"type": "message", "replyToId": "activity-1", "value": value})
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/teams/messages", strings.NewReader(string(body))))
+	return rec
+}
+
+// teamsResult is what Interact returned.
+type teamsResult struct {
+	answer string
+	err    error
+}
+
+// askTeams runs Interact in the background and waits until the question card is sent.
+func askTeams(t *testing.T, teams *fakeTeams, interactor *TeamsInteractor, input QuestionInput) <-chan teamsResult {
+	t.Helper()
+	sent := teams.sentCount()
+	done := make(chan teamsResult, 1)
+	go func() {
+		answer, err := interactor.Interact(context.Background(), input)
+		done <- teamsResult{answer, err}
+	}()
+	require.Eventually(t, func() bool { return teams.sentCount() == sent+1 }, time.Second, time.Millisecond)
+	return done
+}
+
+func TestTeamsInteractor_ChoiceAction(t *testing.T) {
+	teams := &fakeTeams{}
+	interactor := NewTeamsInteractor(teams, "19:conversation", WithTeamsAnswerTimeout(time.Second))
+	input := QuestionInput{Question: "What gender?", Reason: "To pick toys", Choices: []string{"Boy", "Girl", "Both"}}
+
+	done := askTeams(t, teams, interactor, input)
+	card := teams.card(0)
+	require.Len(t, card.Actions, 3)
+	assert.Equal(t, "Girl", card.Actions[1].Title)
+	assert.Equal(t, "To pick toys", card.Body[1].Text)
+
+	assert.Equal(t, http.StatusOK, submitCard(t, interactor.ActionHandler(), card.Actions[2], nil).Code)
+	result := <-done
+	require.NoError(t, result.err)
+	assert.Equal(t, "Both", result.answer)
+	assert.Equal(t, "Answered: Both", teams.update("activity-1").Body[1].Text)
+	assert.Empty(t, teams.update("activity-1").Actions, "actions are removed")
+
+	// a second submit of the answered card is ignored
+	assert.Equal(t, http.StatusOK, submitCard(t, interactor.ActionHandler(), card.Actions[0], nil).Code)
+}
+
+func TestTeamsInteractor_FreeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    QuestionInput
+		typed    string
+		expected string
+	}{
+		{name: "typed answer", input: QuestionInput{Question: "What ages?"}, typed: " 8 and 11 ", expected: "8 and 11"},
+		{name: "empty selects the default", input: QuestionInput{Question: "What ages?", Default: "5"}, typed: "", expected: "5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			teams := &fakeTeams{}
+			interactor := NewTeamsInteractor(teams, "19:conversation", WithTeamsAnswerTimeout(time.Second))
+
+			done := askTeams(t, teams, interactor, tt.input)
+			card := teams.card(0)
+			require.Len(t, card.Body, 2)
+			assert.Equal(t, "Input.Text", card.Body[1].Type)
+			require.Len(t, card.Actions, 1)
+
+			submitCard(t, interactor.ActionHandler(), card.Actions[0], map[string]string{teamsAnswerInput: tt.typed})
+			result := <-done
+			require.NoError(t, result.err)
+			assert.Equal(t, tt.expected, result.answer)
+		})
+	}
+}
+
+func TestTeamsInteractor_EmptyAnswerIsIgnored(t *testing.T) {
+	teams := &fakeTeams{}
+	interactor := NewTeamsInteractor(teams, "19:conversation", WithTeamsAnswerTimeout(time.Second))
+
+	done := askTeams(t, teams, interactor, QuestionInput{Question: "What ages?"})
+	action := teams.card(0).Actions[0]
+	submitCard(t, interactor.ActionHandler(), action, map[string]string{teamsAnswerInput: "  "})
+	submitCard(t, interactor.ActionHandler(), action, map[string]string{teamsAnswerInput: "7"})
+
+	result := <-done
+	require.NoError(t, result.err)
+	assert.Equal(t, "7", result.answer)
+}
+
+func TestTeamsInteractor_Timeout(t *testing.T) {
+	teams := &fakeTeams{}
+	interactor := NewTeamsInteractor(teams, "19:conversation", WithTeamsAnswerTimeout(20*time.Millisecond))
+
+	_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?", Choices: []string{"Boy"}})
+
+	var timeoutErr *ErrAnswerTimeout
+	require.True(t, errors.As(err, &timeoutErr))
+	disabled := teams.update("activity-1")
+	assert.Equal(t, "Expired", disabled.Body[1].Text)
+	assert.Empty(t, disabled.Actions)
+	assert.Zero(t, interactor.pending.len())
+}
+
+func TestTeamsInteractor_SendFailure(t *testing.T) {
+	interactor := NewTeamsInteractor(&fakeTeams{sendErr: errors.New("conversation not found")}, "19:conversation")
+
+	_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?"})
+
+	assert.ErrorContains(t, err, "conversation not found")
+	assert.Zero(t, interactor.pending.len())
+}
+
+func TestTeamsInteractor_Signature(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte("outgoing-webhook-token"))
+	body := `{"type":"message","text":"hi"}`
+	sign := func(key string) string {
+		mac := hmac.New(sha256.New, []byte(key))
+		_, _ = io.WriteString(mac, body)
+		return "HMAC " + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{name: "valid", authorization: sign("outgoing-webhook-token"), status: http.StatusOK},
+		{name: "wrong secret", authorization: sign("other"), status: http.StatusUnauthorized},
+		{name: "unsigned", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interactor := NewTeamsInteractor(&fakeTeams{}, "19:conversation", WithTeamsWebhookSecret(secret))
+
+			req := httptest.NewRequest(http.MethodPost, "/teams/messages", strings.NewReader(body))
+			req.Header.Set("Authorization", tt.authorization)
+			rec := httptest.NewRecorder()
+			interactor.ActionHandler().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
+
+func TestTeamsConnectorClient(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		card   AdaptiveCard
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer bot-token", r.Header.Get("Authorization"))
+		var activity struct {
+			Type        string `json:"type"`
+			Attachments []struct {
+				ContentType string       `json:"contentType"`
+				Content     AdaptiveCard `json:"content"`
+			} `json:"attachments"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&activity))
+		assert.Equal(t, "message", activity.Type)
+		require.Len(t, activity.Attachments, 1)
+		assert.Equal(t, adaptiveCardContentType, activity.Attachments[0].ContentType)
+		requests = append(requests, request{method: r.Method, path: r.URL.EscapedPath(), card: activity.Attachments[0].Content})
+
+		if r.Method == http.MethodPut {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"error":{"code":"ActivityNotFound"}}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"id":"1:activity"}`)
+	}))
+	defer server.Close()
+
+	client := NewTeamsConnectorClient(server.URL+"/", "bot-token")
+	input := QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}}
+
+	id, err := client.SendCard(context.Background(), "19:conversation", teamsQuestionCard("q1", input))
+	require.NoError(t, err)
+	assert.Equal(t, "1:activity", id)
+
+	err = client.UpdateCard(context.Background(), "19:conversation", id, teamsClosedCard(input, "Expired"))
+	assert.ErrorContains(t, err, "HTTP 404")
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, http.MethodPost, requests[0].method)
+	assert.Equal(t, "/v3/conversations/19:conversation/activities", requests[0].path)
+	assert.Equal(t, map[string]string{"questionId": "q1", "choice": "1"}, requests[0].card.Actions[1].Data)
+	assert.Equal(t, http.MethodPut, requests[1].method)
+	assert.Equal(t, "/v3/conversations/19:conversation/activities/1:activity", requests[1].path)
+}
@@ -1,38 +1,352 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // Response represents a user's input from the terminal or an error.
 type Response struct {
 	Value string
 	Err   error
+	// question identifies the question that was displayed when the input was read.
+	question uint64
+}
+
+// defaultAnswerTimeout is how long Interactor waits for an answer unless configured otherwise.
+const defaultAnswerTimeout = 30 * time.Second
+
+// lineEditorPrompt is shown in front of the answer when line editing is enabled.
+const lineEditorPrompt = "> "
+
+// multiLineStart starts a multi-line answer and multiLineEnd, alone on a line, finishes it.
+const (
+	multiLineStart = "<<<"
+	multiLineEnd   = "EOF"
+)
+
+// defaultMaxAnswerTimeout is the longest timeout a question can request unless configured otherwise.
+const defaultMaxAnswerTimeout = 10 * time.Minute
+
+// defaultTimeoutWarning is the fraction of the answer timeout after which Interactor warns that time is running out.
+const defaultTimeoutWarning = 2.0 / 3
+
+// ErrAnswerTimeout is returned by Interactor when the user does not answer a question in time.
+type ErrAnswerTimeout struct {
+	Question string
+	Timeout  time.Duration
+}
+
+func (e *ErrAnswerTimeout) Error() string {
+	return fmt.Sprintf("response to %q was not provided within %s", e.Question, e.Timeout)
 }
 
+// ErrInputClosed is returned by Interactor once the input source is exhausted or has failed.
+var ErrInputClosed = errors.New("terminal input is closed")
+
+// ErrInputExhausted is returned in non-interactive mode when there are no more answers to read.
+var ErrInputExhausted = fmt.Errorf("%w: no more answers to read", ErrInputClosed)
+
+// ErrReaderClosed is returned by Interactor after the TerminalReader has been closed.
+var ErrReaderClosed = errors.New("terminal reader is closed")
+
 // TerminalReader reads input from the terminal in a non-blocking way.
 type TerminalReader struct {
 	inputCh chan Response
+	// current identifies the question being displayed and answered the last question that got an answer.
+	// Input read while an unanswered question was displayed is a late answer and is discarded.
+	current  atomic.Uint64
+	answered atomic.Uint64
+	// source is kept so that Close can unblock a pending read.
+	source io.Reader
+	// stopped is closed by Close; cancel stops the reading loop and restore resets the terminal.
+	stopped   chan struct{}
+	closeOnce sync.Once
+	cancel    context.CancelFunc
+	restore   func()
+	// out receives questions, choices and validation messages.
+	out io.Writer
+	// screen is the terminal behind out, used to detect its width; nil when out isn't a terminal.
+	screen *os.File
+	// wrap reports whether questions and choices are wrapped to the width of the output;
+	// nil means wrap only on a terminal. fallbackWidth is used when the width can't be detected.
+	wrap          *bool
+	fallbackWidth int
+	// outIsTerminal reports whether out is an interactive terminal; nil means detect it from out.
+	outIsTerminal *bool
+	colorMode     ColorMode
+	colors        palette
+	timeout       time.Duration
+	// maxTimeout caps per-question timeouts requested through QuestionInput.TimeoutSeconds.
+	maxTimeout time.Duration
+	// statusInterval is how often the time spent waiting for an answer is reported; zero disables it.
+	statusInterval time.Duration
+	// warnAt is the fraction of the timeout after which a single "time remaining" warning is printed.
+	warnAt float64
+	// strictChoices rejects answers that don't match any of the offered choices.
+	strictChoices bool
+	// maxAttempts is how many answers strict mode checks before accepting free text; zero means no limit.
+	maxAttempts int
+	// nonInteractive reads answers strictly in order without timeouts or re-prompts;
+	// nil means detect it from the source.
+	nonInteractive *bool
+	// normalizeNFC composes answers into Unicode normalization form C.
+	normalizeNFC bool
+	// commands are the "/name" commands available while a question is pending.
+	commands map[string]terminalCommand
+	// confirmation enables the confirm-before-submit step when set.
+	confirmation *ConfirmOptions
+	// flushBeforeAsk discards input typed before a question is displayed.
+	flushBeforeAsk bool
+	// choiceSelector shows choices as an arrow-key menu; menu is set when the source is a terminal.
+	choiceSelector bool
+	menu           *choiceMenu
+	// lineEditing enables arrow-key editing and answer history when the source is a terminal.
+	lineEditing bool
+	// canHideInput is set when the line editor is in use, so that answers to secret questions can be hidden.
+	canHideInput bool
+	// inputHidden is set while the answer to a secret question is typed; the line editor echoes
+	// nothing and keeps the line out of its history.
+	inputHidden atomic.Bool
+	// notify rings the bell and calls notifiers when a question is displayed.
+	notify    bool
+	notifiers []Notifier
+	// defaultFirstChoice treats the first choice as the default when the question doesn't set one.
+	defaultFirstChoice bool
+	// mediaFiles are the files the data URIs attached to questions were saved to, removed by Close;
+	// mediaMu guards them.
+	mediaMu    sync.Mutex
+	mediaFiles []string
+}
+
+// TerminalOption configures a TerminalReader.
+type TerminalOption func(*TerminalReader)
+
+// WithAnswerTimeout sets how long Interactor waits for an answer.
+// Zero disables the timeout, so only the context can stop the wait.
+func WithAnswerTimeout(timeout time.Duration) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.timeout = timeout
+	}
+}
+
+// WithMaxAnswerTimeout caps the timeout a question can request through QuestionInput.TimeoutSeconds.
+func WithMaxAnswerTimeout(limit time.Duration) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.maxTimeout = limit
+	}
+}
+
+// WithOutput sets the writer used for questions, choices and validation messages instead of os.Stdout.
+func WithOutput(out io.Writer) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.out = out
+	}
+}
+
+// WithTerminalOutput overrides the detection of whether the output writer is an interactive terminal.
+// Terminal-only features such as the timeout warning are enabled only for terminals.
+func WithTerminalOutput(isTerminal bool) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.outIsTerminal = &isTerminal
+	}
+}
+
+// WithColor sets whether questions and choices are rendered with ANSI colors. The default is ColorAuto.
+func WithColor(mode ColorMode) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.colorMode = mode
+	}
+}
+
+// WithTimeoutWarning sets the fraction of the answer timeout after which a warning with the remaining time is printed.
+// Zero disables the warning.
+func WithTimeoutWarning(fraction float64) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.warnAt = fraction
+	}
+}
+
+// WithWaitingStatus reports how long the question has been waiting for an answer every interval.
+// On a terminal the status line is updated in place, otherwise a line is written each time.
+func WithWaitingStatus(interval time.Duration) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.statusInterval = interval
+	}
+}
+
+// WithStrictChoices makes Interactor re-prompt when a question has choices and the answer matches none of them.
+// After maxAttempts answers the last one is accepted as free text; zero keeps asking until the timeout.
+// Questions that set allowFreeText to false re-prompt without the option and without an attempt limit.
+func WithStrictChoices(maxAttempts int) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.strictChoices = true
+		tr.maxAttempts = maxAttempts
+	}
+}
+
+// WithDefaultFirstChoice makes an empty answer select the first choice when the question has no explicit default.
+func WithDefaultFirstChoice() TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.defaultFirstChoice = true
+	}
+}
+
+// ConfirmOptions controls the confirm-before-submit step enabled by WithConfirmAnswers.
+type ConfirmOptions struct {
+	// IncludeChoices also confirms answers to questions with choices, which are skipped by default.
+	IncludeChoices bool
+	// ResetTimeout restarts the answer timeout when the confirmation prompt is shown.
+	ResetTimeout bool
+}
+
+// WithConfirmAnswers asks the user to confirm each answer before it is returned.
+// Declining the confirmation asks the question again.
+func WithConfirmAnswers(opts ConfirmOptions) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.confirmation = &opts
+	}
+}
+
+// WithFlushBeforeAsk discards input typed ahead before a question is displayed,
+// so a line typed while the model was thinking doesn't answer a question the user hasn't read.
+func WithFlushBeforeAsk() TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.flushBeforeAsk = true
+	}
+}
+
+// WithLineEditing enables readline-style editing and recall of earlier answers with the arrow keys.
+// It only takes effect when the source is a terminal; pipes and files are read as plain lines.
+func WithLineEditing() TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.lineEditing = true
+	}
+}
+
+// WithChoiceSelector shows the choices of a question as a menu navigated with the arrow keys.
+// It implies line editing and, like it, only takes effect when the source is a terminal;
+// otherwise choices are listed with numbers.
+func WithChoiceSelector() TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.choiceSelector = true
+		tr.lineEditing = true
+	}
+}
+
+// WithNonInteractive overrides the detection of non-interactive input. By default a source that is
+// a file or a pipe rather than a terminal is non-interactive: each line is the answer to the next
+// question, there is no timeout and no re-prompting, and running out of lines fails with ErrInputExhausted.
+func WithNonInteractive(nonInteractive bool) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.nonInteractive = &nonInteractive
+	}
+}
+
+// WithNFC normalizes answers to Unicode normalization form C, so that accented letters typed
+// or pasted in decomposed form match the choices.
+func WithNFC() TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.normalizeNFC = true
+	}
 }
 
 // NewTerminalReader creates a new TerminalReader and starts the reading loop.
-func NewTerminalReader(ctx context.Context, source io.Reader) *TerminalReader {
+func NewTerminalReader(ctx context.Context, source io.Reader, opts ...TerminalOption) *TerminalReader {
 	tr := &TerminalReader{
-		inputCh: make(chan Response),
+		inputCh:       make(chan Response),
+		source:        source,
+		stopped:       make(chan struct{}),
+		commands:      defaultCommands(),
+		out:           os.Stdout,
+		timeout:       defaultAnswerTimeout,
+		maxTimeout:    defaultMaxAnswerTimeout,
+		warnAt:        defaultTimeoutWarning,
+		fallbackWidth: defaultTerminalWidth,
+	}
+	for _, opt := range opts {
+		opt(tr)
 	}
-	go tr.readLoop(ctx, source)
+	if tr.outIsTerminal == nil {
+		isTerminal := isTerminal(tr.out)
+		tr.outIsTerminal = &isTerminal
+	}
+	if tr.wrap == nil {
+		tr.wrap = tr.outIsTerminal
+	}
+	if tr.nonInteractive == nil {
+		f, ok := source.(*os.File)
+		nonInteractive := ok && !isTerminal(f)
+		tr.nonInteractive = &nonInteractive
+	}
+	tr.colors = newPalette(tr.colorMode, *tr.outIsTerminal)
+	tr.screen = screenOf(tr.out)
+
+	ctx, tr.cancel = context.WithCancel(ctx)
+	lines, restore := tr.newLineSource(source)
+	tr.restore = sync.OnceFunc(restore)
+	go tr.readLoop(ctx, lines)
 	return tr
 }
 
+// Close stops the reading loop and makes subsequent Interactor calls fail with ErrReaderClosed.
+// A read that is blocked on the source is interrupted when the source supports read deadlines,
+// otherwise the loop exits as soon as the read returns. Close is safe to call more than once.
+func (tr *TerminalReader) Close() error {
+	var err error
+	tr.closeOnce.Do(func() {
+		close(tr.stopped)
+		tr.cancel()
+		if deadliner, ok := tr.source.(interface{ SetReadDeadline(time.Time) error }); ok {
+			if deadlineErr := deadliner.SetReadDeadline(time.Now()); deadlineErr != nil && !errors.Is(deadlineErr, os.ErrNoDeadline) {
+				err = fmt.Errorf("failed to interrupt pending read: %w", deadlineErr)
+			}
+		}
+		tr.restore()
+		tr.mediaMu.Lock()
+		for _, path := range tr.mediaFiles {
+			os.Remove(path)
+		}
+		tr.mediaFiles = nil
+		tr.mediaMu.Unlock()
+	})
+	return err
+}
+
+// closedErr explains why no more input will arrive.
+func (tr *TerminalReader) closedErr(ctx context.Context) error {
+	select {
+	case <-tr.stopped:
+		return ErrReaderClosed
+	default:
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if *tr.nonInteractive {
+		return ErrInputExhausted
+	}
+	return ErrInputClosed
+}
+
 // readLoop continuously reads from the source and sends responses to the input channel.
-func (tr *TerminalReader) readLoop(ctx context.Context, source io.Reader) {
-	reader := bufio.NewReader(source) // os.Stdin
+// When the source fails or is exhausted it reports the error once and closes the input channel.
+func (tr *TerminalReader) readLoop(ctx context.Context, lines lineSource) {
+	defer close(tr.inputCh)
+	defer tr.restore()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -40,54 +354,518 @@ func (tr *TerminalReader) readLoop(ctx context.Context, source io.Reader) {
 		default:
 		}
 
-		stdInput, err := reader.ReadString('\n')
+		// input that is already buffered was typed before now, so it belongs to the question displayed now
+		question := tr.current.Load()
+		buffered, ok := lines.(interface{ Buffered() int })
+		hasBuffered := ok && buffered.Buffered() > 0
+
+		stdInput, err := lines.ReadLine()
+		if !hasBuffered {
+			question = tr.current.Load()
+		}
+
+		// the last line may be returned together with io.EOF when it has no trailing newline;
+		// empty lines are passed on so that Interactor can apply a default answer
+		sentence := tr.normalize(stdInput)
+		if sentence != "" || err == nil {
+			select {
+			case tr.inputCh <- Response{Value: sentence, question: question}:
+			case <-ctx.Done():
+				return
+			}
+		}
+
 		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = fmt.Errorf("%w: %w", ErrInputClosed, err)
+			} else {
+				err = fmt.Errorf("failed to read input: %w", err)
+			}
 			select {
 			case tr.inputCh <- Response{Err: err}:
 			case <-ctx.Done():
 			}
 			return
 		}
+	}
+}
 
-		sentence := strings.TrimSpace(stdInput)
-		if sentence == "" {
-			fmt.Println("Please provide non empty answer")
-			continue
+// normalize cleans a line of input: carriage returns from CRLF line endings are dropped, Unicode
+// spaces such as the non-breaking space become plain spaces, and surrounding whitespace is trimmed
+// together with invisible characters like the zero-width space and the byte order mark.
+func (tr *TerminalReader) normalize(line string) string {
+	line = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\r':
+			return -1
+		case r != '\n' && r != '\t' && unicode.Is(unicode.Zs, r):
+			return ' '
+		default:
+			return r
 		}
+	}, line)
+	line = strings.TrimFunc(line, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '\u200b' || r == '\ufeff'
+	})
+	if tr.normalizeNFC {
+		line = norm.NFC.String(line)
+	}
+	return line
+}
 
-		select {
-		case tr.inputCh <- Response{Value: sentence}:
-		case <-ctx.Done():
-			return
+// Ask displays the question like Interactor and reports a skipped question as a skipped Answer.
+// Together with Notify and Close it makes TerminalReader an Interactor.
+func (tr *TerminalReader) Ask(ctx context.Context, input QuestionInput) (Answer, error) {
+	return answerOf(tr.Interactor(ctx, input))
+}
+
+// Notify writes the message to the terminal.
+func (tr *TerminalReader) Notify(_ context.Context, message string) error {
+	for _, line := range wrapText(message, tr.width()) {
+		if _, err := fmt.Fprintln(tr.out, tr.colors.secondary(line)); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 // Interactor displays a question to the user in the terminal and returns their input.
 func (tr *TerminalReader) Interactor(ctx context.Context, input QuestionInput) (string, error) {
-	fmt.Println(input.Question)
-	if len(input.Choices) > 0 {
-		for i, choice := range input.Choices {
-			var suffix string
-			if i == len(input.Choices)-1 {
-				suffix = ""
+	select {
+	case <-tr.stopped:
+		return "", ErrReaderClosed
+	default:
+	}
+
+	id := tr.current.Add(1)
+	question := &pendingQuestion{
+		input:         input,
+		defaultAnswer: tr.defaultAnswer(input),
+		multiLine:     input.MultiLine,
+	}
+	question.position, _ = QuestionPositionFromContext(ctx)
+	question.parse, _ = AnswerParserFromContext(ctx)
+	question.secret = IsSecretInput(ctx)
+	question.media = tr.mediaLines(input.Media)
+	tr.render(question)
+	if question.secret {
+		tr.inputHidden.Store(tr.canHideInput)
+		defer tr.inputHidden.Store(false)
+	}
+	tr.notifyQuestion(ctx, input)
+	if tr.usesMenu(question) {
+		defer tr.menu.Hide()
+	}
+
+	// a nil channel never fires, so a disabled timeout waits for the answer or the context
+	timeout := tr.questionTimeout(input)
+	if *tr.nonInteractive {
+		timeout = 0
+	}
+	var timer *time.Timer
+	var timeoutCh, warningCh <-chan time.Time
+	if timeout > 0 {
+		timer = time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+
+		if *tr.outIsTerminal && tr.warnAt > 0 && tr.warnAt < 1 {
+			warning := time.NewTimer(time.Duration(float64(timeout) * tr.warnAt))
+			defer warning.Stop()
+			warningCh = warning.C
+		}
+	}
+
+	var statusCh <-chan time.Time
+	if tr.statusInterval > 0 {
+		ticker := time.NewTicker(tr.statusInterval)
+		defer ticker.Stop()
+		statusCh = ticker.C
+	}
+	askedAt := time.Now()
+	statusShown := false
+	defer func() {
+		if statusShown && *tr.outIsTerminal {
+			fmt.Fprint(tr.out, "\r\x1b[2K")
+		}
+	}()
+
+	// the timer covers the whole question, including re-prompts
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-tr.stopped:
+			return "", ErrReaderClosed
+		case <-timeoutCh:
+			return "", &ErrAnswerTimeout{Question: input.Question, Timeout: timeout}
+		case <-statusCh:
+			status := fmt.Sprintf("waiting %s…", formatElapsed(time.Since(askedAt)))
+			if *tr.outIsTerminal {
+				fmt.Fprint(tr.out, "\r\x1b[2K"+tr.colors.secondary(status))
 			} else {
-				suffix = ", "
+				fmt.Fprintln(tr.out, status)
+			}
+			statusShown = true
+		case <-warningCh:
+			remaining := timeout - time.Duration(float64(timeout)*tr.warnAt)
+			fmt.Fprintf(tr.out, "%s remaining…\n", formatRemaining(remaining))
+			warningCh = nil
+		case res, ok := <-tr.inputCh:
+			if !ok {
+				return "", tr.closedErr(ctx)
+			}
+			if res.Err != nil {
+				if *tr.nonInteractive && errors.Is(res.Err, io.EOF) {
+					return "", ErrInputExhausted
+				}
+				return "", res.Err
+			}
+			if *tr.nonInteractive {
+				tr.answered.Store(id)
+				return tr.acceptInOrder(question, res.Value), nil
+			}
+			if tr.isLate(res, id) {
+				continue
+			}
+			// messages about the answer are shown again, and hidden input resumes for a re-prompt
+			tr.inputHidden.Store(false)
+			answer, ok, err := tr.accept(question, res.Value)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				tr.answered.Store(id)
+				return answer, nil
+			}
+			tr.inputHidden.Store(question.secret && tr.canHideInput)
+			if question.restartTimer && timer != nil {
+				timer.Reset(timeout)
+			}
+			question.restartTimer = false
+		}
+	}
+}
+
+// render writes the question, its choices and answer hints to the output.
+// A question asked together with others is prefixed with its position in the batch.
+func (tr *TerminalReader) render(question *pendingQuestion) {
+	input, defaultAnswer := question.input, question.defaultAnswer
+	text := input.Question
+	if question.position.Total > 1 {
+		text = fmt.Sprintf("Question %d of %d: %s", question.position.Index, question.position.Total, text)
+	}
+	width := tr.width()
+	for _, line := range wrapText(text, width) {
+		fmt.Fprintln(tr.out, tr.colors.question(line))
+	}
+	if input.Reason != "" {
+		for _, line := range wrapText(input.Reason, width) {
+			fmt.Fprintln(tr.out, tr.colors.secondary(line))
+		}
+	}
+	for _, line := range question.media {
+		fmt.Fprintln(tr.out, tr.colors.secondary(line))
+	}
+	if tr.usesMenu(question) {
+		tr.menu.Show(input.Choices, input.ChoiceDescriptions, max(slices.Index(input.Choices, defaultAnswer), 0))
+	} else {
+		// continuation lines and descriptions are indented past the number so that the numbers stand out
+		for i, choice := range input.Choices {
+			prefix := fmt.Sprintf("%d) ", i+1)
+			for _, line := range wrapIndented(prefix, choice, width) {
+				fmt.Fprintln(tr.out, tr.colors.secondary(line))
+			}
+			if description := input.choiceDescription(i); description != "" {
+				for _, line := range wrapIndented(strings.Repeat(" ", len(prefix)), description, width) {
+					fmt.Fprintln(tr.out, tr.colors.secondary(line))
+				}
 			}
-			fmt.Printf("%s%s\n", choice, suffix)
 		}
-		fmt.Println("")
 	}
+	if defaultAnswer != "" {
+		fmt.Fprintln(tr.out, tr.colors.secondary(fmt.Sprintf("[default: %s]", defaultAnswer)))
+	} else if !input.IsRequired() {
+		fmt.Fprintln(tr.out, tr.colors.secondary("(optional, press Enter to skip)"))
+	}
+	if input.MultiLine {
+		fmt.Fprintln(tr.out, tr.colors.secondary(fmt.Sprintf("(finish your answer with a line containing only %s)", multiLineEnd)))
+	}
+	switch {
+	case question.secret && tr.canHideInput:
+		fmt.Fprintln(tr.out, tr.colors.secondary("(your answer is hidden while you type)"))
+	case question.secret && *tr.outIsTerminal:
+		fmt.Fprintln(tr.out, tr.colors.secondary("(your answer is visible while you type on this terminal)"))
+	}
+	if len(input.Choices) > 0 {
+		fmt.Fprintln(tr.out, "")
+	}
+}
+
+// mediaLines returns a line for each media attached to the question: the URL, or the path of the
+// temporary file a data URI is saved to, since terminals can't show images.
+func (tr *TerminalReader) mediaLines(media []Media) []string {
+	lines := make([]string, 0, len(media))
+	for _, m := range media {
+		label := "Image"
+		if m.Alt != "" {
+			label += " (" + m.Alt + ")"
+		}
+		if !m.IsDataURI() {
+			lines = append(lines, label+": "+m.URL)
+			continue
+		}
+		path, err := m.saveTemp()
+		if err != nil {
+			lines = append(lines, label+" could not be saved: "+err.Error())
+			continue
+		}
+		tr.mediaMu.Lock()
+		tr.mediaFiles = append(tr.mediaFiles, path)
+		tr.mediaMu.Unlock()
+		lines = append(lines, label+" saved to "+path)
+	}
+	return lines
+}
 
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+// usesMenu reports whether the question's choices are shown as an arrow-key menu. Questions with an
+// answer parser keep the numbered list, since the parser may accept more than a single choice.
+func (tr *TerminalReader) usesMenu(question *pendingQuestion) bool {
+	return tr.menu != nil && len(question.input.Choices) > 0 && !question.input.MultiLine && question.parse == nil
+}
 
-	select {
-	case <-ctx.Done():
-		return "", ctx.Err()
-	case <-ticker.C:
-		return "", errors.New("Response was not provided in time")
-	case res := <-tr.inputCh:
-		return res.Value, res.Err
+// isLate reports whether the response was typed for an earlier question that never got its answer,
+// for example because it timed out. Input typed ahead after an answered question is not late
+// unless stale input is flushed before asking.
+func (tr *TerminalReader) isLate(res Response, id uint64) bool {
+	if res.question == id {
+		return false
+	}
+	return tr.flushBeforeAsk || res.question != tr.answered.Load()
+}
+
+// pendingQuestion tracks the answer being collected for a single question.
+type pendingQuestion struct {
+	input         QuestionInput
+	defaultAnswer string
+	attempts      int
+	// position is the question's place among the interrupts raised together with it.
+	position QuestionPosition
+	// multiLine is set while lines are captured until multiLineEnd.
+	multiLine bool
+	lines     []string
+	// confirming holds an answer waiting for the user's confirmation.
+	confirming string
+	// restartTimer asks Interactor to restart the answer timeout.
+	restartTimer bool
+	// parse rejects answers a tool handler can't use, so that the question is asked again at once.
+	parse AnswerParser
+	// secret is set when the answer must not be echoed, for example by the confirmation step.
+	secret bool
+	// media are the lines showing the media attached to the question, prepared once so that
+	// rendering the question again doesn't save its data URIs again.
+	media []string
+}
+
+// accept processes one line of input for the question.
+// It returns the final answer and true, or false when more input is needed.
+// A command, or an empty line skipping an optional question, can end the question with an error instead.
+func (tr *TerminalReader) accept(question *pendingQuestion, line string) (string, bool, error) {
+	if !question.multiLine && strings.HasPrefix(line, commandPrefix) {
+		isCommand, err := tr.runCommand(question.input, line)
+		if isCommand || err != nil {
+			return "", false, err
+		}
+		line = strings.TrimPrefix(line, commandPrefix)
+	}
+
+	if question.confirming != "" {
+		answer, ok := tr.confirm(question, line)
+		return answer, ok, nil
 	}
+
+	if line == "" && !question.multiLine && question.defaultAnswer == "" && !question.input.IsRequired() {
+		return "", false, ErrQuestionSkipped
+	}
+
+	answer, ok := tr.resolve(question, line)
+	if ok && question.parse != nil {
+		if _, err := question.parse(answer); err != nil {
+			fmt.Fprintln(tr.out, invalidAnswerMessage(err))
+			// the next answer is captured like the first one, over several lines for multi-line questions
+			question.multiLine = question.input.MultiLine
+			return "", false, nil
+		}
+	}
+	if !ok || question.secret || !tr.needsConfirmation(question.input) {
+		return answer, ok, nil
+	}
+
+	question.confirming = answer
+	question.restartTimer = tr.confirmation.ResetTimeout
+	fmt.Fprintf(tr.out, "You answered: %s — send? (y/n)\n", answer)
+	return "", false, nil
+}
+
+// acceptInOrder turns a line into an answer in non-interactive mode, where nothing is asked twice:
+// an empty line selects the default and a choice number its text, anything else is returned as is.
+func (tr *TerminalReader) acceptInOrder(question *pendingQuestion, line string) string {
+	if line == "" {
+		return question.defaultAnswer
+	}
+	if answer, ok := resolveChoice(question.input.Choices, line); ok {
+		return answer
+	}
+	return line
+}
+
+// needsConfirmation reports whether answers to the question must be confirmed before they are returned.
+func (tr *TerminalReader) needsConfirmation(input QuestionInput) bool {
+	if tr.confirmation == nil {
+		return false
+	}
+	return len(input.Choices) == 0 || tr.confirmation.IncludeChoices
+}
+
+// confirm handles the reply to the confirmation prompt. Declining asks the question again.
+func (tr *TerminalReader) confirm(question *pendingQuestion, line string) (string, bool) {
+	switch strings.ToLower(line) {
+	case "y", "yes":
+		return question.confirming, true
+	case "n", "no":
+		question.confirming = ""
+		question.attempts = 0
+		question.multiLine = question.input.MultiLine
+		tr.render(question)
+		return "", false
+	default:
+		fmt.Fprintln(tr.out, "Please answer y or n")
+		return "", false
+	}
+}
+
+// resolve turns one line of input into an answer, handling multi-line capture, defaults and choices.
+// It returns false when the line doesn't complete a valid answer.
+func (tr *TerminalReader) resolve(question *pendingQuestion, line string) (string, bool) {
+	if question.multiLine {
+		if line != multiLineEnd {
+			question.lines = append(question.lines, line)
+			return "", false
+		}
+		question.multiLine = false
+		line = strings.TrimSpace(strings.Join(question.lines, "\n"))
+		question.lines = nil
+		if line != "" {
+			return line, true
+		}
+	} else if rest, ok := strings.CutPrefix(line, multiLineStart); ok {
+		question.multiLine = true
+		if rest = strings.TrimSpace(rest); rest != "" {
+			question.lines = append(question.lines, rest)
+		}
+		return "", false
+	}
+
+	input := question.input
+	if line == "" && question.defaultAnswer != "" {
+		return question.defaultAnswer, true
+	}
+	if question.parse != nil {
+		// the parser interprets empty answers and choice numbers itself and is checked by accept
+		return line, true
+	}
+	if line == "" {
+		fmt.Fprintln(tr.out, "Please provide non empty answer")
+		return "", false
+	}
+
+	question.attempts++
+	answer, ok := resolveChoice(input.Choices, line)
+	if !ok {
+		fmt.Fprintf(tr.out, "Please enter a number between 1 and %d\n", len(input.Choices))
+		return "", false
+	}
+	// questions that don't allow free text are strict however many attempts it takes
+	if len(input.Choices) > 0 && (tr.strictChoices || !input.FreeTextAllowed()) {
+		if choice, ok := matchChoice(input.Choices, answer); ok {
+			return choice, true
+		}
+		if !input.FreeTextAllowed() || tr.maxAttempts == 0 || question.attempts < tr.maxAttempts {
+			fmt.Fprintf(tr.out, "Please choose one of: %s\n", strings.Join(input.Choices, ", "))
+			return "", false
+		}
+	}
+	return answer, true
+}
+
+// questionTimeout returns how long to wait for the answer, preferring the question's own timeout
+// clamped to the configured maximum.
+func (tr *TerminalReader) questionTimeout(input QuestionInput) time.Duration {
+	return questionTimeout(input, tr.timeout, tr.maxTimeout)
+}
+
+// defaultAnswer returns the answer used for an empty line, or "" when the question has no default.
+func (tr *TerminalReader) defaultAnswer(input QuestionInput) string {
+	if input.Default != "" {
+		return input.Default
+	}
+	if tr.defaultFirstChoice && len(input.Choices) > 0 {
+		return input.Choices[0]
+	}
+	return ""
+}
+
+// resolveChoice maps a numeric answer to the text of the corresponding choice.
+// Answers that match a choice literally or are not numbers are returned unchanged.
+// It reports false for a number that doesn't correspond to any choice.
+func resolveChoice(choices []string, answer string) (string, bool) {
+	if len(choices) == 0 || slices.Contains(choices, answer) {
+		return answer, true
+	}
+
+	number, err := strconv.Atoi(answer)
+	if err != nil {
+		return answer, true
+	}
+	if number < 1 || number > len(choices) {
+		return "", false
+	}
+
+	return choices[number-1], true
+}
+
+// matchChoice finds the choice equal to the answer, ignoring case and surrounding whitespace.
+func matchChoice(choices []string, answer string) (string, bool) {
+	answer = strings.TrimSpace(answer)
+	for _, choice := range choices {
+		if strings.EqualFold(strings.TrimSpace(choice), answer) {
+			return choice, true
+		}
+	}
+	return "", false
+}
+
+// formatRemaining renders the time left to answer, in whole seconds when there is at least one.
+func formatRemaining(remaining time.Duration) string {
+	if remaining < time.Second {
+		return remaining.Round(time.Millisecond).String()
+	}
+	seconds := int(remaining.Round(time.Second) / time.Second)
+	if seconds == 1 {
+		return "1 second"
+	}
+	return fmt.Sprintf("%d seconds", seconds)
+}
+
+// formatElapsed renders the time spent waiting, in whole seconds once it reaches one.
+func formatElapsed(elapsed time.Duration) string {
+	if elapsed < time.Second {
+		return elapsed.Round(time.Millisecond).String()
+	}
+	return elapsed.Round(time.Second).String()
+}
+
+// isTerminal reports whether v is a file attached to an interactive terminal.
+func isTerminal(v any) bool {
+	return screenOf(v) != nil
 }
@@ -0,0 +1,987 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminalReader_Timeout(t *testing.T) {
+	t.Run("answer arrives in time", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tr := NewTerminalReader(ctx, strings.NewReader("Boy\n"), WithAnswerTimeout(50*time.Millisecond))
+		answer, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "Boy", answer)
+	})
+
+	t.Run("answer times out", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// the pipe is never written to, so the reader blocks until the timeout
+		source, _ := io.Pipe()
+		tr := NewTerminalReader(ctx, source, WithAnswerTimeout(50*time.Millisecond))
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+		var timeoutErr *ErrAnswerTimeout
+		require.True(t, errors.As(err, &timeoutErr))
+		assert.Equal(t, "What gender?", timeoutErr.Question)
+		assert.Equal(t, 50*time.Millisecond, timeoutErr.Timeout)
+	})
+
+	t.Run("zero timeout waits for the context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		source, _ := io.Pipe()
+		tr := NewTerminalReader(ctx, source, WithAnswerTimeout(0))
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestTerminalReader_NumberedChoices(t *testing.T) {
+	choices := []string{"Boy", "Girl", "Both"}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "number selects choice", input: "2\n", expected: "Girl"},
+		{name: "out of range number re-prompts", input: "5\n2\n", expected: "Girl"},
+		{name: "zero re-prompts", input: "0\n3\n", expected: "Both"},
+		{name: "literal text is accepted", input: "Boy\n", expected: "Boy"},
+		{name: "free text is passed through", input: "Twins\n", expected: "Twins"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			tr := NewTerminalReader(ctx, strings.NewReader(tt.input), WithAnswerTimeout(time.Second))
+			answer, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?", Choices: choices})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, answer)
+		})
+	}
+
+	t.Run("numeric choices match literally", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tr := NewTerminalReader(ctx, strings.NewReader("8\n"), WithAnswerTimeout(time.Second))
+		answer, err := tr.Interactor(ctx, QuestionInput{Question: "Age?", Choices: []string{"8", "11"}})
+
+		require.NoError(t, err)
+		assert.Equal(t, "8", answer)
+	})
+}
+
+func TestTerminalReader_ChoiceDescriptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var out bytes.Buffer
+	input := QuestionInput{
+		Question:           "What kind of gift?",
+		Choices:            []string{"Board games", "Books", "Outdoor toys"},
+		ChoiceDescriptions: []string{"best for rainy days", "", "need a garden or a park nearby"},
+	}
+
+	tr := NewTerminalReader(ctx, strings.NewReader("1\n"), WithAnswerTimeout(time.Second), WithOutput(&out), WithWrapping(true), WithFallbackWidth(24))
+	answer, err := tr.Interactor(ctx, input)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Board games", answer)
+	assert.Contains(t, out.String(), "1) Board games\n   best for rainy days\n2) Books\n3) Outdoor toys\n   need a garden or a\n   park nearby\n",
+		"descriptions are indented under their choices and wrapped")
+}
+
+func TestTerminalReader_Media(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	var out bytes.Buffer
+	input := QuestionInput{
+		Question: "Which of these two bikes looks right?",
+		Media: []Media{
+			{URL: "https://example.com/red.png", Alt: "a red bike"},
+			{URL: "data:image/png;base64,aGVsbG8="},
+		},
+	}
+
+	tr := NewTerminalReader(ctx, strings.NewReader("the red one\n"), WithAnswerTimeout(time.Second), WithOutput(&out), WithWrapping(false))
+	answer, err := tr.Interactor(ctx, input)
+
+	require.NoError(t, err)
+	assert.Equal(t, "the red one", answer)
+	saved, err := filepath.Glob(filepath.Join(dir, "question-media-*.png"))
+	require.NoError(t, err)
+	require.Len(t, saved, 1)
+	assert.Equal(t, "Which of these two bikes looks right?\nImage (a red bike): https://example.com/red.png\nImage saved to "+saved[0]+"\n", out.String())
+	data, err := os.ReadFile(saved[0])
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	require.NoError(t, tr.Close())
+	assert.NoFileExists(t, saved[0], "the saved media are removed on close")
+}
+
+func TestTerminalReader_StrictChoices(t *testing.T) {
+	choices := []string{"Boy", "Girl", "Both"}
+
+	tests := []struct {
+		name        string
+		input       string
+		maxAttempts int
+		expected    string
+	}{
+		{name: "wrong then right", input: "Twins\ngirl\n", maxAttempts: 0, expected: "Girl"},
+		{name: "wrong then number", input: "Twins\n3\n", maxAttempts: 0, expected: "Both"},
+		{name: "free text after max attempts", input: "Twins\nTriplets\n", maxAttempts: 2, expected: "Triplets"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			tr := NewTerminalReader(ctx, strings.NewReader(tt.input),
+				WithAnswerTimeout(time.Second),
+				WithStrictChoices(tt.maxAttempts),
+			)
+			answer, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?", Choices: choices})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, answer)
+		})
+	}
+
+	t.Run("timeout covers all attempts", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		source, writer := io.Pipe()
+		tr := NewTerminalReader(ctx, source,
+			WithAnswerTimeout(50*time.Millisecond),
+			WithStrictChoices(0),
+		)
+		go func() {
+			_, _ = writer.Write([]byte("Twins\n"))
+		}()
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?", Choices: choices})
+
+		var timeoutErr *ErrAnswerTimeout
+		assert.True(t, errors.As(err, &timeoutErr))
+	})
+}
+
+func TestTerminalReader_QuestionSettings(t *testing.T) {
+	no := false
+	choices := []string{"Boy", "Girl"}
+	tests := []struct {
+		name     string
+		input    string
+		question QuestionInput
+		expected string
+		err      error
+		output   string
+	}{
+		{
+			name:     "strict choices re-prompt on free text",
+			input:    "Twins\ngirl\n",
+			question: QuestionInput{Question: "What gender?", Choices: choices, AllowFreeText: &no},
+			expected: "Girl",
+			output:   "Please choose one of: Boy, Girl\n",
+		},
+		{
+			name:     "strict choices accept numbers",
+			input:    "2\n",
+			question: QuestionInput{Question: "What gender?", Choices: choices, AllowFreeText: &no},
+			expected: "Girl",
+		},
+		{
+			name:     "choices are suggestions by default",
+			input:    "Twins\n",
+			question: QuestionInput{Question: "What gender?", Choices: choices},
+			expected: "Twins",
+		},
+		{
+			name:     "optional question skipped by an empty line",
+			input:    "\n",
+			question: QuestionInput{Question: "Any allergies?", Required: &no},
+			err:      ErrQuestionSkipped,
+			output:   "(optional, press Enter to skip)\n",
+		},
+		{
+			name:     "optional question with a default",
+			input:    "\n",
+			question: QuestionInput{Question: "Any allergies?", Default: "none", Required: &no},
+			expected: "none",
+		},
+		{
+			name:     "required question re-prompts on an empty line",
+			input:    "\nNuts\n",
+			question: QuestionInput{Question: "Any allergies?"},
+			expected: "Nuts",
+			output:   "Please provide non empty answer\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var out bytes.Buffer
+			tr := NewTerminalReader(ctx, strings.NewReader(tt.input), WithOutput(&out), WithAnswerTimeout(time.Second))
+			answer, err := tr.Interactor(ctx, tt.question)
+
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, answer)
+			}
+			assert.Contains(t, out.String(), tt.output)
+		})
+	}
+}
+
+func TestTerminalReader_Output(t *testing.T) {
+	t.Run("renders question and numbered choices", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var out bytes.Buffer
+		tr := NewTerminalReader(ctx, strings.NewReader("1\n"), WithOutput(&out), WithAnswerTimeout(time.Second))
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}})
+
+		require.NoError(t, err)
+		assert.Equal(t, "What gender?\n1) Boy\n2) Girl\n\n", out.String())
+	})
+
+	t.Run("renders validation messages", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var out bytes.Buffer
+		tr := NewTerminalReader(ctx, strings.NewReader("7\nTwins\nGirl\n"),
+			WithOutput(&out),
+			WithAnswerTimeout(time.Second),
+			WithStrictChoices(0),
+		)
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}})
+
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "Please enter a number between 1 and 2\n")
+		assert.Contains(t, out.String(), "Please choose one of: Boy, Girl\n")
+	})
+}
+
+func TestTerminalReader_EOF(t *testing.T) {
+	t.Run("closed input fails fast", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tr := NewTerminalReader(ctx, strings.NewReader("Boy\n"), WithAnswerTimeout(time.Minute))
+
+		answer, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+		require.NoError(t, err)
+		assert.Equal(t, "Boy", answer)
+
+		_, err = tr.Interactor(ctx, QuestionInput{Question: "What age?"})
+		assert.ErrorIs(t, err, io.EOF)
+		assert.ErrorIs(t, err, ErrInputClosed)
+
+		start := time.Now()
+		_, err = tr.Interactor(ctx, QuestionInput{Question: "What budget?"})
+		assert.ErrorIs(t, err, ErrInputClosed)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("last line without newline is delivered", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tr := NewTerminalReader(ctx, strings.NewReader("Girl"), WithAnswerTimeout(time.Minute))
+
+		answer, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+		require.NoError(t, err)
+		assert.Equal(t, "Girl", answer)
+	})
+
+	t.Run("read failure is not reported as EOF", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		readErr := errors.New("device gone")
+		tr := NewTerminalReader(ctx, iotest.ErrReader(readErr), WithAnswerTimeout(time.Minute))
+
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+		assert.ErrorIs(t, err, readErr)
+		assert.NotErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestTerminalReader_TimeoutWarning(t *testing.T) {
+	tests := []struct {
+		name       string
+		isTerminal bool
+		timeout    time.Duration
+		warnings   int
+	}{
+		{name: "warns once on a terminal", isTerminal: true, timeout: 100 * time.Millisecond, warnings: 1},
+		{name: "silent when output is not a terminal", isTerminal: false, timeout: 100 * time.Millisecond, warnings: 0},
+		{name: "silent when timeout is disabled", isTerminal: true, timeout: 0, warnings: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			var out bytes.Buffer
+			source, _ := io.Pipe()
+			tr := NewTerminalReader(ctx, source,
+				WithOutput(&out),
+				WithTerminalOutput(tt.isTerminal),
+				WithAnswerTimeout(tt.timeout),
+				WithTimeoutWarning(0.5),
+			)
+			_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+			require.Error(t, err)
+			assert.Equal(t, tt.warnings, strings.Count(out.String(), "remaining…"))
+		})
+	}
+
+	t.Run("warning shows the remaining time", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var out bytes.Buffer
+		source, _ := io.Pipe()
+		tr := NewTerminalReader(ctx, source,
+			WithOutput(&out),
+			WithTerminalOutput(true),
+			WithAnswerTimeout(100*time.Millisecond),
+			WithTimeoutWarning(0.75),
+		)
+		_, _ = tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+		assert.Contains(t, out.String(), "25ms remaining…\n")
+	})
+}
+
+func TestFormatRemaining(t *testing.T) {
+	assert.Equal(t, "10 seconds", formatRemaining(10*time.Second))
+	assert.Equal(t, "1 second", formatRemaining(1200*time.Millisecond))
+	assert.Equal(t, "250ms", formatRemaining(250*time.Millisecond))
+}
+
+func TestTerminalReader_DefaultAnswer(t *testing.T) {
+	choices := []string{"Boy", "Girl"}
+
+	tests := []struct {
+		name     string
+		input    string
+		question QuestionInput
+		opts     []TerminalOption
+		expected string
+		rendered string
+	}{
+		{
+			name:     "empty line selects explicit default",
+			input:    "\n",
+			question: QuestionInput{Question: "What gender?", Choices: choices, Default: "Girl"},
+			expected: "Girl",
+			rendered: "[default: Girl]\n",
+		},
+		{
+			name:     "empty line selects first choice",
+			input:    "\n",
+			question: QuestionInput{Question: "What gender?", Choices: choices},
+			opts:     []TerminalOption{WithDefaultFirstChoice()},
+			expected: "Boy",
+			rendered: "[default: Boy]\n",
+		},
+		{
+			name:     "typed answer wins over default",
+			input:    "2\n",
+			question: QuestionInput{Question: "What gender?", Choices: choices, Default: "Boy"},
+			expected: "Girl",
+			rendered: "[default: Boy]\n",
+		},
+		{
+			name:     "empty line without default re-prompts",
+			input:    "\nBoy\n",
+			question: QuestionInput{Question: "What gender?", Choices: choices},
+			expected: "Boy",
+			rendered: "Please provide non empty answer\n",
+		},
+		{
+			name:     "free text question requires an answer",
+			input:    "\nLEGO\n",
+			question: QuestionInput{Question: "What do they like?"},
+			opts:     []TerminalOption{WithDefaultFirstChoice()},
+			expected: "LEGO",
+			rendered: "Please provide non empty answer\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var out bytes.Buffer
+			opts := append([]TerminalOption{WithOutput(&out), WithAnswerTimeout(time.Second)}, tt.opts...)
+			tr := NewTerminalReader(ctx, strings.NewReader(tt.input), opts...)
+			answer, err := tr.Interactor(ctx, tt.question)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, answer)
+			assert.Contains(t, out.String(), tt.rendered)
+		})
+	}
+}
+
+func TestTerminalReader_MultiLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		question QuestionInput
+		expected string
+	}{
+		{
+			name:     "marker starts capture",
+			input:    "<<<\nLEGO set\n\nBike helmet\nEOF\n",
+			question: QuestionInput{Question: "Paste the wishlist"},
+			expected: "LEGO set\n\nBike helmet",
+		},
+		{
+			name:     "text after marker is the first line",
+			input:    "<<< LEGO set\nBike helmet\nEOF\n",
+			question: QuestionInput{Question: "Paste the wishlist"},
+			expected: "LEGO set\nBike helmet",
+		},
+		{
+			name:     "flag captures without marker",
+			input:    "LEGO set\nBike helmet\nEOF\n",
+			question: QuestionInput{Question: "Paste the wishlist", MultiLine: true},
+			expected: "LEGO set\nBike helmet",
+		},
+		{
+			name:     "empty capture falls back to default",
+			input:    "<<<\nEOF\n",
+			question: QuestionInput{Question: "Paste the wishlist", Default: "nothing"},
+			expected: "nothing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			tr := NewTerminalReader(ctx, strings.NewReader(tt.input), WithOutput(io.Discard), WithAnswerTimeout(time.Second))
+			answer, err := tr.Interactor(ctx, tt.question)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, answer)
+		})
+	}
+
+	t.Run("timeout covers the whole capture", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		source, writer := io.Pipe()
+		tr := NewTerminalReader(ctx, source, WithOutput(io.Discard), WithAnswerTimeout(50*time.Millisecond))
+		go func() {
+			_, _ = writer.Write([]byte("<<<\nLEGO set\n"))
+		}()
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "Paste the wishlist"})
+
+		var timeoutErr *ErrAnswerTimeout
+		assert.True(t, errors.As(err, &timeoutErr))
+	})
+}
+
+func TestTerminalReader_Close(t *testing.T) {
+	t.Run("stops the reading goroutine", func(t *testing.T) {
+		source, writer, err := os.Pipe()
+		require.NoError(t, err)
+		defer writer.Close()
+		defer source.Close()
+
+		before := runtime.NumGoroutine()
+		tr := NewTerminalReader(context.Background(), source, WithOutput(io.Discard))
+		require.NoError(t, tr.Close())
+
+		// polled by hand because assert.Eventually runs its own goroutines
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+	})
+
+	t.Run("interactor fails fast after close", func(t *testing.T) {
+		ctx := context.Background()
+		source, _ := io.Pipe()
+		tr := NewTerminalReader(ctx, source, WithOutput(io.Discard), WithAnswerTimeout(time.Minute))
+		require.NoError(t, tr.Close())
+		require.NoError(t, tr.Close())
+
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+		assert.ErrorIs(t, err, ErrReaderClosed)
+	})
+
+	t.Run("pending interactor is released", func(t *testing.T) {
+		ctx := context.Background()
+		source, _ := io.Pipe()
+		tr := NewTerminalReader(ctx, source, WithOutput(io.Discard), WithAnswerTimeout(time.Minute))
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			_ = tr.Close()
+		}()
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+		assert.ErrorIs(t, err, ErrReaderClosed)
+	})
+
+	t.Run("exhausted input stays distinguishable", func(t *testing.T) {
+		ctx := context.Background()
+		tr := NewTerminalReader(ctx, strings.NewReader(""), WithOutput(io.Discard), WithAnswerTimeout(time.Minute))
+		defer tr.Close()
+
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+		assert.ErrorIs(t, err, ErrInputClosed)
+		_, err = tr.Interactor(ctx, QuestionInput{Question: "What age?"})
+		assert.ErrorIs(t, err, ErrInputClosed)
+	})
+}
+
+func TestTerminalReader_QuestionTimeout(t *testing.T) {
+	t.Run("question overrides the reader default", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		source, writer := io.Pipe()
+		tr := NewTerminalReader(ctx, source, WithOutput(io.Discard), WithAnswerTimeout(50*time.Millisecond))
+		go func() {
+			time.Sleep(150 * time.Millisecond)
+			_, _ = writer.Write([]byte("Boy\n"))
+		}()
+		answer, err := tr.Interactor(ctx, QuestionInput{Question: "Measure the bike frame", TimeoutSeconds: 1})
+
+		require.NoError(t, err)
+		assert.Equal(t, "Boy", answer)
+	})
+
+	t.Run("question timeout is clamped", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		source, _ := io.Pipe()
+		tr := NewTerminalReader(ctx, source,
+			WithOutput(io.Discard),
+			WithAnswerTimeout(time.Minute),
+			WithMaxAnswerTimeout(50*time.Millisecond),
+		)
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "Measure the bike frame", TimeoutSeconds: 3600})
+
+		var timeoutErr *ErrAnswerTimeout
+		require.True(t, errors.As(err, &timeoutErr))
+		assert.Equal(t, 50*time.Millisecond, timeoutErr.Timeout)
+	})
+
+	t.Run("absent question timeout uses the reader default", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		source, _ := io.Pipe()
+		tr := NewTerminalReader(ctx, source, WithOutput(io.Discard), WithAnswerTimeout(50*time.Millisecond))
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+		var timeoutErr *ErrAnswerTimeout
+		require.True(t, errors.As(err, &timeoutErr))
+		assert.Equal(t, 50*time.Millisecond, timeoutErr.Timeout)
+	})
+}
+
+func TestTerminalReader_LateAnswer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	source, writer := io.Pipe()
+	tr := NewTerminalReader(ctx, source, WithOutput(io.Discard), WithAnswerTimeout(50*time.Millisecond))
+
+	_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+	var timeoutErr *ErrAnswerTimeout
+	require.True(t, errors.As(err, &timeoutErr))
+
+	// the user answers the first question after it timed out, before the next one is shown
+	_, err = writer.Write([]byte("Boy\n"))
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = writer.Write([]byte("8\n"))
+	}()
+	answer, err := tr.Interactor(ctx, QuestionInput{Question: "What age?", TimeoutSeconds: 1})
+
+	require.NoError(t, err)
+	assert.Equal(t, "8", answer)
+}
+
+func TestTerminalReader_TypeAheadAfterAnswer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tr := NewTerminalReader(ctx, strings.NewReader("Boy\n8\n"), WithOutput(io.Discard), WithAnswerTimeout(time.Second))
+
+	answer, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+	require.NoError(t, err)
+	assert.Equal(t, "Boy", answer)
+
+	answer, err = tr.Interactor(ctx, QuestionInput{Question: "What age?"})
+	require.NoError(t, err)
+	assert.Equal(t, "8", answer)
+}
+
+func TestTerminalReader_ConfirmAnswers(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		question QuestionInput
+		opts     ConfirmOptions
+		expected string
+		prompts  int
+	}{
+		{
+			name:     "declined answer is asked again",
+			input:    "LEGO tehcnic\nn\nLEGO technic\ny\n",
+			question: QuestionInput{Question: "What do they like?"},
+			expected: "LEGO technic",
+			prompts:  2,
+		},
+		{
+			name:     "unclear reply asks again",
+			input:    "LEGO\nmaybe\nyes\n",
+			question: QuestionInput{Question: "What do they like?"},
+			expected: "LEGO",
+			prompts:  1,
+		},
+		{
+			name:     "choices skip confirmation by default",
+			input:    "2\n",
+			question: QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}},
+			expected: "Girl",
+			prompts:  0,
+		},
+		{
+			name:     "choices can be confirmed",
+			input:    "2\ny\n",
+			question: QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}},
+			opts:     ConfirmOptions{IncludeChoices: true},
+			expected: "Girl",
+			prompts:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var out bytes.Buffer
+			tr := NewTerminalReader(ctx, strings.NewReader(tt.input),
+				WithOutput(&out),
+				WithAnswerTimeout(time.Second),
+				WithConfirmAnswers(tt.opts),
+			)
+			answer, err := tr.Interactor(ctx, tt.question)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, answer)
+			assert.Equal(t, tt.prompts, strings.Count(out.String(), "— send? (y/n)"))
+		})
+	}
+
+	for _, reset := range []bool{false, true} {
+		t.Run(fmt.Sprintf("reset timeout %v", reset), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			source, writer := io.Pipe()
+			tr := NewTerminalReader(ctx, source,
+				WithOutput(io.Discard),
+				WithAnswerTimeout(100*time.Millisecond),
+				WithConfirmAnswers(ConfirmOptions{ResetTimeout: reset}),
+			)
+			go func() {
+				time.Sleep(60 * time.Millisecond)
+				_, _ = writer.Write([]byte("LEGO\n"))
+				time.Sleep(60 * time.Millisecond)
+				_, _ = writer.Write([]byte("y\n"))
+			}()
+			answer, err := tr.Interactor(ctx, QuestionInput{Question: "What do they like?"})
+
+			if reset {
+				require.NoError(t, err)
+				assert.Equal(t, "LEGO", answer)
+			} else {
+				var timeoutErr *ErrAnswerTimeout
+				assert.True(t, errors.As(err, &timeoutErr))
+			}
+		})
+	}
+}
+
+func TestTerminalReader_FlushBeforeAsk(t *testing.T) {
+	for _, flush := range []bool{false, true} {
+		t.Run(fmt.Sprintf("flush %v", flush), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			opts := []TerminalOption{WithOutput(io.Discard), WithAnswerTimeout(time.Second)}
+			if flush {
+				opts = append(opts, WithFlushBeforeAsk())
+			}
+			source, writer := io.Pipe()
+			tr := NewTerminalReader(ctx, source, opts...)
+
+			// the user types ahead while the model is still thinking
+			_, err := writer.Write([]byte("typed ahead\n"))
+			require.NoError(t, err)
+			time.Sleep(20 * time.Millisecond)
+
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				_, _ = writer.Write([]byte("fresh\n"))
+			}()
+			answer, err := tr.Interactor(ctx, QuestionInput{Question: "What do they like?"})
+
+			require.NoError(t, err)
+			if flush {
+				assert.Equal(t, "fresh", answer)
+			} else {
+				assert.Equal(t, "typed ahead", answer)
+			}
+		})
+	}
+}
+
+func TestTerminalReader_NonInteractive(t *testing.T) {
+	t.Run("answers are read in order", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var out bytes.Buffer
+		tr := NewTerminalReader(ctx, strings.NewReader("Boy and Girl\n8 and 11\n"),
+			WithOutput(&out),
+			WithNonInteractive(true),
+			WithAnswerTimeout(time.Nanosecond),
+		)
+
+		answer, err := tr.Interactor(ctx, QuestionInput{Question: "What gender are the children?", Choices: []string{"Boy", "Girl", "Both"}})
+		require.NoError(t, err)
+		assert.Equal(t, "Boy and Girl", answer)
+
+		answer, err = tr.Interactor(ctx, QuestionInput{Question: "What are their ages?"})
+		require.NoError(t, err)
+		assert.Equal(t, "8 and 11", answer)
+
+		_, err = tr.Interactor(ctx, QuestionInput{Question: "What budget?"})
+		assert.ErrorIs(t, err, ErrInputExhausted)
+		assert.ErrorIs(t, err, ErrInputClosed)
+
+		assert.Contains(t, out.String(), "What gender are the children?\n")
+		assert.Contains(t, out.String(), "What are their ages?\n")
+		assert.NotContains(t, out.String(), "Please")
+	})
+
+	t.Run("lines are not validated", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tr := NewTerminalReader(ctx, strings.NewReader("\n7\n2\n"),
+			WithOutput(io.Discard),
+			WithNonInteractive(true),
+			WithStrictChoices(0),
+		)
+		question := QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}, Default: "Boy"}
+
+		var answers []string
+		for range 3 {
+			answer, err := tr.Interactor(ctx, question)
+			require.NoError(t, err)
+			answers = append(answers, answer)
+		}
+		assert.Equal(t, []string{"Boy", "7", "Girl"}, answers)
+	})
+
+	t.Run("piped source is detected", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		source, writer, err := os.Pipe()
+		require.NoError(t, err)
+		defer source.Close()
+		_, err = writer.WriteString("Boy\n")
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		tr := NewTerminalReader(ctx, source, WithOutput(io.Discard))
+		defer tr.Close()
+
+		answer, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+		require.NoError(t, err)
+		assert.Equal(t, "Boy", answer)
+
+		_, err = tr.Interactor(ctx, QuestionInput{Question: "What age?"})
+		assert.ErrorIs(t, err, ErrInputExhausted)
+	})
+}
+
+func TestTerminalReader_WaitingStatus(t *testing.T) {
+	t.Run("status lines until the answer", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var out syncBuffer
+		source, writer := io.Pipe()
+		tr := NewTerminalReader(ctx, source,
+			WithOutput(&out),
+			WithTerminalOutput(false),
+			WithAnswerTimeout(time.Second),
+			WithWaitingStatus(20*time.Millisecond),
+		)
+		go func() {
+			time.Sleep(70 * time.Millisecond)
+			_, _ = writer.Write([]byte("Boy\n"))
+		}()
+		answer, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+		require.NoError(t, err)
+		assert.Equal(t, "Boy", answer)
+
+		rendered := out.String()
+		assert.GreaterOrEqual(t, strings.Count(rendered, "waiting "), 1)
+		assert.Regexp(t, `waiting \d+ms…\n`, rendered)
+
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, rendered, out.String(), "no status after the answer")
+	})
+
+	t.Run("status is updated in place on a terminal", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var out syncBuffer
+		source, _ := io.Pipe()
+		tr := NewTerminalReader(ctx, source,
+			WithOutput(&out),
+			WithTerminalOutput(true),
+			WithColor(ColorNever),
+			WithAnswerTimeout(70*time.Millisecond),
+			WithTimeoutWarning(0),
+			WithWaitingStatus(20*time.Millisecond),
+		)
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+		var timeoutErr *ErrAnswerTimeout
+		require.True(t, errors.As(err, &timeoutErr))
+		assert.Contains(t, out.String(), "\r\x1b[2Kwaiting ")
+		assert.True(t, strings.HasSuffix(out.String(), "\r\x1b[2K"), "status line is cleared")
+		assert.NotContains(t, strings.TrimPrefix(out.String(), "What gender?\n"), "\n")
+	})
+}
+
+func TestTerminalReader_NormalizesInput(t *testing.T) {
+	choices := []string{"Boy", "Girl", "Café"}
+
+	tests := []struct {
+		name     string
+		input    string
+		opts     []TerminalOption
+		expected string
+	}{
+		{name: "CRLF line ending", input: "Boy\r\n", expected: "Boy"},
+		{name: "CRLF number selects choice", input: "2\r\n", expected: "Girl"},
+		{name: "non-breaking spaces are trimmed", input: "\u00a0Girl\u00a0\n", expected: "Girl"},
+		{name: "inner non-breaking space becomes a space", input: "red\u00a0bike\r\n", expected: "red bike"},
+		{name: "zero-width space and byte order mark", input: "\ufeffBoy\u200b\n", expected: "Boy"},
+		{name: "emoji round-trips", input: " 🎁 Lego 🚀\u3000\r\n", expected: "🎁 Lego 🚀"},
+		{name: "decomposed accent kept without NFC", input: "Cafe\u0301\n", expected: "Cafe\u0301"},
+		{name: "decomposed accent composed with NFC", input: "Cafe\u0301\n", opts: []TerminalOption{WithNFC()}, expected: "Café"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			opts := append([]TerminalOption{WithAnswerTimeout(time.Second), WithOutput(io.Discard)}, tt.opts...)
+			tr := NewTerminalReader(ctx, strings.NewReader(tt.input), opts...)
+			answer, err := tr.Interactor(ctx, QuestionInput{Question: "What present?", Choices: choices})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, answer)
+		})
+	}
+
+	t.Run("NFC answer matches a strict choice", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tr := NewTerminalReader(ctx, strings.NewReader("Cafe\u0301\r\n"),
+			WithAnswerTimeout(time.Second), WithOutput(io.Discard), WithStrictChoices(0), WithNFC())
+		answer, err := tr.Interactor(ctx, QuestionInput{Question: "Where?", Choices: choices})
+
+		require.NoError(t, err)
+		assert.Equal(t, "Café", answer)
+	})
+}
+
+// syncBuffer is a bytes.Buffer that can be read while the reader is still writing to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Key sequences understood by the choice menu.
+var (
+	keyUpSequence   = []byte("\x1b[A")
+	keyDownSequence = []byte("\x1b[B")
+)
+
+const keyEsc = 0x1b
+
+// lineCountingWriter counts the lines written through it, so that the choice menu knows
+// how far up the cursor has to move to redraw itself.
+type lineCountingWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	lines int
+}
+
+func (c *lineCountingWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lines += bytes.Count(p, []byte("\n"))
+	return c.w.Write(p)
+}
+
+// Lines returns the number of lines written so far.
+func (c *lineCountingWriter) Lines() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lines
+}
+
+// writeUncounted writes output that rewrites lines already on the screen.
+func (c *lineCountingWriter) writeUncounted(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, _ = c.w.Write(p)
+}
+
+// choiceMenu lets the user pick a choice with the arrow keys.
+// It sits between the raw terminal and the line editor: while the menu is open, up and down move
+// the highlight and Enter types the highlighted choice into the editor as if the user typed it.
+// Esc closes the menu so the user can type a free-text answer, and so does any other key.
+type choiceMenu struct {
+	source io.Reader
+	out    *lineCountingWriter
+	colors palette
+
+	mu           sync.Mutex
+	open         bool
+	choices      []string
+	descriptions []string
+	selected     int
+	// top is the line count of out when the menu was drawn.
+	top     int
+	pending []byte
+	buf     []byte
+}
+
+func newChoiceMenu(source io.Reader, out *lineCountingWriter, colors palette) *choiceMenu {
+	return &choiceMenu{
+		source: source,
+		out:    out,
+		colors: colors,
+		buf:    make([]byte, 256),
+	}
+}
+
+// Show draws the menu with the given choice highlighted and starts intercepting arrow keys.
+// descriptions, by index, are drawn under their choices.
+func (m *choiceMenu) Show(choices, descriptions []string, selected int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.open = true
+	m.choices = choices
+	m.descriptions = descriptions
+	m.selected = selected
+	m.top = m.out.Lines()
+	fmt.Fprint(m.out, m.renderLocked())
+	fmt.Fprintln(m.out, m.colors.secondary("(↑/↓ to move, Enter to select, Esc to type an answer)"))
+}
+
+// Hide stops intercepting keys. The menu stays on the screen.
+func (m *choiceMenu) Hide() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.open = false
+}
+
+// Read passes keys through to the line editor, translating them while the menu is open.
+func (m *choiceMenu) Read(p []byte) (int, error) {
+	for {
+		m.mu.Lock()
+		if len(m.pending) > 0 {
+			n := copy(p, m.pending)
+			m.pending = m.pending[n:]
+			m.mu.Unlock()
+			return n, nil
+		}
+		m.mu.Unlock()
+
+		n, err := m.source.Read(m.buf)
+		if n > 0 {
+			m.mu.Lock()
+			m.pending = m.filterLocked(m.buf[:n])
+			m.mu.Unlock()
+		}
+		if err != nil {
+			m.mu.Lock()
+			pending := len(m.pending)
+			m.mu.Unlock()
+			if pending == 0 {
+				return 0, err
+			}
+		}
+	}
+}
+
+// filterLocked handles the keys meant for the open menu and returns the input left for the line editor.
+func (m *choiceMenu) filterLocked(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		if !m.open {
+			return append(out, data[i:]...)
+		}
+
+		rest := data[i:]
+		switch {
+		case bytes.HasPrefix(rest, keyUpSequence):
+			m.moveLocked(-1)
+			i += len(keyUpSequence)
+		case bytes.HasPrefix(rest, keyDownSequence):
+			m.moveLocked(1)
+			i += len(keyDownSequence)
+		case rest[0] == '\r' || rest[0] == '\n':
+			out = append(out, m.choices[m.selected]...)
+			out = append(out, '\r')
+			m.open = false
+			i++
+		case rest[0] == keyEsc && len(rest) > 1 && rest[1] == '[':
+			// other escape sequences such as left and right are ignored while the menu is open
+			i += escapeSequenceLength(rest)
+		case rest[0] == keyEsc:
+			m.open = false
+			i++
+		default:
+			// typing anything else means the user wants to answer in their own words
+			m.open = false
+		}
+	}
+	return out
+}
+
+// moveLocked moves the highlight, wrapping around at both ends, and redraws the menu in place.
+func (m *choiceMenu) moveLocked(delta int) {
+	m.selected = (m.selected + delta + len(m.choices)) % len(m.choices)
+
+	// the cursor is on the line below everything written since the menu was drawn
+	up := m.out.Lines() - m.top
+	menu := m.renderLocked()
+	var redraw strings.Builder
+	fmt.Fprintf(&redraw, "\x1b[%dA", up)
+	redraw.WriteString(menu)
+	if down := up - strings.Count(menu, "\n"); down > 0 {
+		fmt.Fprintf(&redraw, "\x1b[%dB", down)
+	}
+	m.out.writeUncounted([]byte(redraw.String()))
+}
+
+// renderLocked returns the menu lines with the selected choice marked.
+func (m *choiceMenu) renderLocked() string {
+	var menu strings.Builder
+	for i, choice := range m.choices {
+		menu.WriteString("\r\x1b[2K")
+		if i == m.selected {
+			menu.WriteString(m.colors.question("> " + choice))
+		} else {
+			menu.WriteString(m.colors.secondary("  " + choice))
+		}
+		menu.WriteString("\n")
+		if i < len(m.descriptions) && m.descriptions[i] != "" {
+			menu.WriteString("\r\x1b[2K")
+			menu.WriteString(m.colors.secondary("    " + m.descriptions[i]))
+			menu.WriteString("\n")
+		}
+	}
+	return menu.String()
+}
+
+// escapeSequenceLength returns the length of the CSI sequence at the start of data.
+func escapeSequenceLength(data []byte) int {
+	for i := 2; i < len(data); i++ {
+		if data[i] >= 0x40 && data[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(data)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/term"
+)
+
+func TestChoiceMenu_Read(t *testing.T) {
+	choices := []string{"Boy", "Girl", "Both"}
+
+	tests := []struct {
+		name     string
+		keys     string
+		selected int
+		expected string
+	}{
+		{name: "enter picks the highlighted choice", keys: "\r", selected: 1, expected: "Girl\r"},
+		{name: "down moves the highlight", keys: "\x1b[B\r", expected: "Girl\r"},
+		{name: "up wraps around", keys: "\x1b[A\r", expected: "Both\r"},
+		{name: "other sequences are ignored", keys: "\x1b[C\x1b[B\x1b[B\r", expected: "Both\r"},
+		{name: "esc switches to free text", keys: "\x1bTwins\r", expected: "Twins\r"},
+		{name: "typing switches to free text", keys: "2\r", expected: "2\r"},
+		{name: "keys after the selection pass through", keys: "\rLEGO\r", expected: "Boy\rLEGO\r"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			menu := newChoiceMenu(strings.NewReader(tt.keys), &lineCountingWriter{w: io.Discard}, palette{})
+			menu.Show(choices, nil, tt.selected)
+
+			typed, err := io.ReadAll(menu)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(typed))
+		})
+	}
+
+	t.Run("hidden menu passes keys through", func(t *testing.T) {
+		menu := newChoiceMenu(strings.NewReader("\x1b[B\r"), &lineCountingWriter{w: io.Discard}, palette{})
+		menu.Show(choices, nil, 0)
+		menu.Hide()
+
+		typed, err := io.ReadAll(menu)
+
+		require.NoError(t, err)
+		assert.Equal(t, "\x1b[B\r", string(typed))
+	})
+}
+
+func TestChoiceMenu_Render(t *testing.T) {
+	var screen bytes.Buffer
+	out := &lineCountingWriter{w: &screen}
+	menu := newChoiceMenu(strings.NewReader("\x1b[B"), out, palette{})
+
+	menu.Show([]string{"Boy", "Girl"}, nil, 0)
+	assert.Equal(t, "\r\x1b[2K> Boy\n\r\x1b[2K  Girl\n(↑/↓ to move, Enter to select, Esc to type an answer)\n", screen.String())
+	assert.Equal(t, 3, out.Lines())
+
+	screen.Reset()
+	_, _ = io.ReadAll(menu)
+
+	// the redraw moves up over the menu and its hint, rewrites the choices and returns below the hint
+	assert.Equal(t, "\x1b[3A\r\x1b[2K  Boy\n\r\x1b[2K> Girl\n\x1b[1B", screen.String())
+	assert.Equal(t, 3, out.Lines())
+}
+
+func TestChoiceMenu_RenderDescriptions(t *testing.T) {
+	var screen bytes.Buffer
+	out := &lineCountingWriter{w: &screen}
+	menu := newChoiceMenu(strings.NewReader("\x1b[B"), out, palette{})
+
+	menu.Show([]string{"Board games", "Books"}, []string{"best for rainy days"}, 0)
+	assert.Equal(t, "\r\x1b[2K> Board games\n\r\x1b[2K    best for rainy days\n\r\x1b[2K  Books\n(↑/↓ to move, Enter to select, Esc to type an answer)\n", screen.String())
+	assert.Equal(t, 4, out.Lines())
+
+	screen.Reset()
+	_, _ = io.ReadAll(menu)
+
+	// the redraw counts the description lines
+	assert.Equal(t, "\x1b[4A\r\x1b[2K  Board games\n\r\x1b[2K    best for rainy days\n\r\x1b[2K> Books\n\x1b[1B", screen.String())
+}
+
+func TestChoiceMenu_LineEditor(t *testing.T) {
+	menu := newChoiceMenu(strings.NewReader("\x1b[B\x1b[B\r"), &lineCountingWriter{w: io.Discard}, palette{})
+	menu.Show([]string{"Boy", "Girl", "Both"}, nil, 0)
+
+	lines := &editedLines{terminal: term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{menu, io.Discard}, lineEditorPrompt)}
+	line, err := lines.ReadLine()
+
+	require.NoError(t, err)
+	assert.Equal(t, "Both", line)
+}
@@ -0,0 +1,57 @@
+package main
+
+import "os"
+
+// ColorMode controls whether TerminalReader decorates its output with ANSI colors.
+type ColorMode int
+
+const (
+	// ColorAuto enables colors when the output is a terminal and NO_COLOR is not set.
+	ColorAuto ColorMode = iota
+	// ColorAlways enables colors regardless of the output.
+	ColorAlways
+	// ColorNever disables colors.
+	ColorNever
+)
+
+// ANSI escape sequences used by the terminal interactor. All styling goes through palette.
+const (
+	ansiReset    = "\x1b[0m"
+	ansiBoldCyan = "\x1b[1;36m"
+	ansiDim      = "\x1b[2m"
+)
+
+// palette applies ANSI styles to text, or leaves it untouched when colors are disabled.
+type palette struct {
+	enabled bool
+}
+
+// newPalette resolves the color mode for an output that is or isn't a terminal.
+func newPalette(mode ColorMode, isTerminal bool) palette {
+	switch mode {
+	case ColorAlways:
+		return palette{enabled: true}
+	case ColorNever:
+		return palette{enabled: false}
+	default:
+		_, noColor := os.LookupEnv("NO_COLOR")
+		return palette{enabled: isTerminal && !noColor}
+	}
+}
+
+func (p palette) style(code, text string) string {
+	if !p.enabled || text == "" {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+// question styles the question text.
+func (p palette) question(text string) string {
+	return p.style(ansiBoldCyan, text)
+}
+
+// secondary styles choices, hints and other supporting text.
+func (p palette) secondary(text string) string {
+	return p.style(ansiDim, text)
+}
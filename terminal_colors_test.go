@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPalette(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       ColorMode
+		isTerminal bool
+		noColor    bool
+		enabled    bool
+	}{
+		{name: "auto on terminal", mode: ColorAuto, isTerminal: true, enabled: true},
+		{name: "auto off terminal", mode: ColorAuto, isTerminal: false, enabled: false},
+		{name: "auto with NO_COLOR", mode: ColorAuto, isTerminal: true, noColor: true, enabled: false},
+		{name: "always", mode: ColorAlways, isTerminal: false, noColor: true, enabled: true},
+		{name: "never", mode: ColorNever, isTerminal: true, enabled: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.noColor {
+				t.Setenv("NO_COLOR", "1")
+			}
+			assert.Equal(t, tt.enabled, newPalette(tt.mode, tt.isTerminal).enabled)
+		})
+	}
+}
+
+func TestTerminalReader_ColorRendering(t *testing.T) {
+	question := QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}}
+
+	tests := []struct {
+		name     string
+		mode     ColorMode
+		expected string
+	}{
+		{
+			name:     "colored",
+			mode:     ColorAlways,
+			expected: ansiBoldCyan + "What gender?" + ansiReset + "\n" + ansiDim + "1) Boy" + ansiReset + "\n" + ansiDim + "2) Girl" + ansiReset + "\n\n",
+		},
+		{
+			name:     "plain",
+			mode:     ColorNever,
+			expected: "What gender?\n1) Boy\n2) Girl\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var out bytes.Buffer
+			tr := NewTerminalReader(ctx, strings.NewReader("1\n"),
+				WithOutput(&out),
+				WithColor(tt.mode),
+				WithAnswerTimeout(time.Second),
+			)
+			_, err := tr.Interactor(ctx, question)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// commandPrefix starts a command line. A doubled prefix escapes an answer that starts with it.
+const commandPrefix = "/"
+
+// CommandFunc runs a terminal command for the pending question. Returning an error ends the question
+// with that error, returning nil keeps waiting for an answer. Anything written to out is shown to the user.
+type CommandFunc func(out io.Writer, input QuestionInput, args string) error
+
+// terminalCommand is a registered command with its help text.
+type terminalCommand struct {
+	description string
+	run         CommandFunc
+}
+
+// WithCommand registers a command that the user can type as "/name" while a question is pending.
+// Registering an existing name replaces it.
+func WithCommand(name, description string, run CommandFunc) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.commands[strings.ToLower(name)] = terminalCommand{description: description, run: run}
+	}
+}
+
+// defaultCommands returns the commands available in every TerminalReader.
+func defaultCommands() map[string]terminalCommand {
+	return map[string]terminalCommand{
+		"skip":     {description: "skip this question", run: commandError(ErrQuestionSkipped)},
+		"quit":     {description: "stop the conversation", run: commandError(ErrConversationAborted)},
+		"rephrase": {description: "ask for the question in different words", run: commandError(ErrRephraseRequested)},
+		"back":     {description: "change the answer to the previous question", run: commandError(ErrPreviousQuestion)},
+		"later":    {description: "answer this question after the others", run: commandError(ErrQuestionDeferred)},
+	}
+}
+
+// commandError returns a command that ends the question with err.
+func commandError(err error) CommandFunc {
+	return func(io.Writer, QuestionInput, string) error {
+		return err
+	}
+}
+
+// runCommand interprets a line starting with the command prefix.
+// It returns false when the line is an escaped answer rather than a command.
+func (tr *TerminalReader) runCommand(input QuestionInput, line string) (bool, error) {
+	rest := strings.TrimPrefix(line, commandPrefix)
+	if strings.HasPrefix(rest, commandPrefix) {
+		return false, nil
+	}
+
+	name, args, _ := strings.Cut(rest, " ")
+	name = strings.ToLower(name)
+	if name == "help" {
+		tr.printHelp()
+		return true, nil
+	}
+
+	command, ok := tr.commands[name]
+	if !ok {
+		fmt.Fprintf(tr.out, "Unknown command %s%s, type %shelp for the list of commands\n", commandPrefix, name, commandPrefix)
+		return true, nil
+	}
+	return true, command.run(tr.out, input, strings.TrimSpace(args))
+}
+
+// printHelp lists the available commands.
+func (tr *TerminalReader) printHelp() {
+	names := make([]string, 0, len(tr.commands))
+	for name := range tr.commands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	fmt.Fprintln(tr.out, "Available commands:")
+	fmt.Fprintf(tr.out, "  %shelp - show this list\n", commandPrefix)
+	for _, name := range names {
+		fmt.Fprintf(tr.out, "  %s%s - %s\n", commandPrefix, name, tr.commands[name].description)
+	}
+	fmt.Fprintf(tr.out, "Start an answer with %s%s to send it as text.\n", commandPrefix, commandPrefix)
+}
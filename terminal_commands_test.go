@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminalReader_Commands(t *testing.T) {
+	errCustom := errors.New("custom")
+
+	tests := []struct {
+		name        string
+		input       string
+		opts        []TerminalOption
+		expected    string
+		expectedErr error
+		rendered    string
+	}{
+		{
+			name:     "help keeps the question open",
+			input:    "/help\nBoy\n",
+			expected: "Boy",
+			rendered: "  /skip - skip this question\n",
+		},
+		{
+			name:        "skip ends the question",
+			input:       "/skip\n",
+			expectedErr: ErrQuestionSkipped,
+		},
+		{
+			name:        "later defers the question",
+			input:       "/later\n",
+			expectedErr: ErrQuestionDeferred,
+		},
+		{
+			name:        "commands ignore case",
+			input:       "/Quit\n",
+			expectedErr: ErrConversationAborted,
+		},
+		{
+			name:     "unknown command re-prompts",
+			input:    "/dance\nGirl\n",
+			expected: "Girl",
+			rendered: "Unknown command /dance, type /help for the list of commands\n",
+		},
+		{
+			name:     "doubled prefix sends text",
+			input:    "//usr/bin\n",
+			expected: "/usr/bin",
+		},
+		{
+			name:        "registered command",
+			input:       "/custom\n",
+			opts:        []TerminalOption{WithCommand("custom", "does custom things", commandError(errCustom))},
+			expectedErr: errCustom,
+		},
+		{
+			name:     "registered command appears in help",
+			input:    "/help\nBoy\n",
+			opts:     []TerminalOption{WithCommand("custom", "does custom things", commandError(errCustom))},
+			expected: "Boy",
+			rendered: "  /custom - does custom things\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var out bytes.Buffer
+			opts := append([]TerminalOption{WithOutput(&out), WithAnswerTimeout(time.Second)}, tt.opts...)
+			tr := NewTerminalReader(ctx, strings.NewReader(tt.input), opts...)
+			answer, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, answer)
+			assert.Contains(t, out.String(), tt.rendered)
+		})
+	}
+
+	t.Run("multi-line capture keeps command-like lines", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tr := NewTerminalReader(ctx, strings.NewReader("<<<\n/skip\nEOF\n"), WithOutput(io.Discard), WithAnswerTimeout(time.Second))
+		answer, err := tr.Interactor(ctx, QuestionInput{Question: "Paste the wishlist"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "/skip", answer)
+	})
+}
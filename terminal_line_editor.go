@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"golang.org/x/term"
+)
+
+// lineSource reads answers from the input one line at a time.
+type lineSource interface {
+	// ReadLine returns the next line. The line may still carry its trailing newline,
+	// and the last line may be returned together with io.EOF.
+	ReadLine() (string, error)
+}
+
+// bufferedLines reads lines from a plain reader such as a pipe or a file.
+type bufferedLines struct {
+	reader *bufio.Reader
+}
+
+func (b *bufferedLines) ReadLine() (string, error) {
+	return b.reader.ReadString('\n')
+}
+
+// Buffered returns the number of bytes that can be read without blocking.
+func (b *bufferedLines) Buffered() int {
+	return b.reader.Buffered()
+}
+
+// editedLines reads lines from an interactive terminal with line editing and answer history.
+type editedLines struct {
+	terminal *term.Terminal
+}
+
+func (e *editedLines) ReadLine() (string, error) {
+	line, err := e.terminal.ReadLine()
+	if errors.Is(err, term.ErrPasteIndicator) {
+		// the line was pasted rather than typed, which is still a valid answer
+		err = nil
+	}
+	return line, err
+}
+
+// newLineSource returns the reader used by readLoop. When line editing is enabled and the source
+// is a terminal, the terminal is switched to raw mode and questions are written through the editor,
+// so they don't garble the line being typed. The choice menu, when enabled, filters the keys
+// before they reach the editor. The returned function restores the terminal.
+func (tr *TerminalReader) newLineSource(source io.Reader) (lineSource, func()) {
+	plain := &bufferedLines{reader: bufio.NewReader(source)}
+	if !tr.lineEditing {
+		return plain, func() {}
+	}
+
+	f, ok := source.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return plain, func() {}
+	}
+
+	state, err := term.MakeRaw(int(f.Fd()))
+	if err != nil {
+		return plain, func() {}
+	}
+
+	var keys io.Reader = f
+	var menu *choiceMenu
+	out := &lineCountingWriter{}
+	if tr.choiceSelector {
+		menu = newChoiceMenu(f, out, tr.colors)
+		keys = menu
+	}
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{keys, &hidingWriter{w: tr.out, hidden: &tr.inputHidden}}, lineEditorPrompt)
+	terminal.History = &hidingHistory{History: terminal.History, hidden: &tr.inputHidden}
+	tr.canHideInput = true
+	if width, height, err := term.GetSize(int(f.Fd())); err == nil {
+		_ = terminal.SetSize(width, height)
+	}
+	out.w = terminal
+	tr.out = out
+	tr.menu = menu
+
+	return &editedLines{terminal: terminal}, func() {
+		_ = term.Restore(int(f.Fd()), state)
+	}
+}
+
+// hidingWriter passes on what the line editor writes, except while the answer to a secret question is
+// typed: the echo of the keys is dropped then, keeping only the line breaks so that the cursor still
+// moves past the hidden answer.
+type hidingWriter struct {
+	w      io.Writer
+	hidden *atomic.Bool
+}
+
+func (h *hidingWriter) Write(p []byte) (int, error) {
+	if !h.hidden.Load() {
+		return h.w.Write(p)
+	}
+	var breaks []byte
+	for _, b := range p {
+		if b == '\r' || b == '\n' {
+			breaks = append(breaks, b)
+		}
+	}
+	if len(breaks) > 0 {
+		if _, err := h.w.Write(breaks); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// hidingHistory keeps the answers to secret questions out of the line editor's history.
+type hidingHistory struct {
+	term.History
+	hidden *atomic.Bool
+}
+
+func (h *hidingHistory) Add(entry string) {
+	if !h.hidden.Load() {
+		h.History.Add(entry)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/term"
+)
+
+func TestEditedLines_History(t *testing.T) {
+	// "\x1b[A" is the up arrow, which recalls the previous answer
+	input := strings.NewReader("Boy\r\x1b[A\rGirk\x7fl\r")
+	lines := &editedLines{terminal: term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{input, io.Discard}, lineEditorPrompt)}
+
+	var answers []string
+	for range 3 {
+		line, err := lines.ReadLine()
+		require.NoError(t, err)
+		answers = append(answers, line)
+	}
+
+	assert.Equal(t, []string{"Boy", "Boy", "Girl"}, answers)
+}
+
+func TestEditedLines_HiddenInput(t *testing.T) {
+	var out strings.Builder
+	var hidden atomic.Bool
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader("4111-2222\rBoy\r"), &hidingWriter{w: &out, hidden: &hidden}}, lineEditorPrompt)
+	terminal.History = &hidingHistory{History: terminal.History, hidden: &hidden}
+	lines := &editedLines{terminal: terminal}
+
+	hidden.Store(true)
+	secret, err := lines.ReadLine()
+	require.NoError(t, err)
+	hidden.Store(false)
+	answer, err := lines.ReadLine()
+	require.NoError(t, err)
+
+	assert.Equal(t, "4111-2222", secret)
+	assert.Equal(t, "Boy", answer)
+	assert.NotContains(t, out.String(), "4111", "the secret isn't echoed")
+	assert.Contains(t, out.String(), "Boy")
+	require.Equal(t, 1, terminal.History.Len(), "the secret isn't kept in the history")
+	assert.Equal(t, "Boy", terminal.History.At(0))
+}
+
+func TestTerminalReader_LineEditingFallsBackForPipes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tr := NewTerminalReader(ctx, strings.NewReader("Boy\n"),
+		WithOutput(io.Discard),
+		WithLineEditing(),
+		WithAnswerTimeout(time.Second),
+	)
+	answer, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Boy", answer)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+)
+
+// ansiBell makes the terminal beep or flash its window.
+const ansiBell = "\a"
+
+// Notifier draws the user's attention to a question that was just displayed,
+// for example with a desktop notification. Notifications are best-effort:
+// TerminalReader logs a failing notifier and keeps waiting for the answer.
+type Notifier interface {
+	Notify(ctx context.Context, input QuestionInput) error
+}
+
+// NopNotifier is a Notifier that does nothing.
+type NopNotifier struct{}
+
+// Notify does nothing.
+func (NopNotifier) Notify(context.Context, QuestionInput) error {
+	return nil
+}
+
+// BellNotifier rings the terminal bell by writing the ASCII BEL character to Out.
+type BellNotifier struct {
+	Out io.Writer
+}
+
+// Notify writes the bell character.
+func (b BellNotifier) Notify(context.Context, QuestionInput) error {
+	if _, err := io.WriteString(b.Out, ansiBell); err != nil {
+		return fmt.Errorf("failed to ring the bell: %w", err)
+	}
+	return nil
+}
+
+// WithNotify rings the terminal bell when a new question is displayed, so that a user who switched
+// to another window notices it before the timeout. The given notifiers, such as a desktop notification,
+// are called after the bell. Nothing is rung in non-interactive mode.
+func WithNotify(notifiers ...Notifier) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.notify = true
+		tr.notifiers = append(tr.notifiers, notifiers...)
+	}
+}
+
+// notifyQuestion calls every notifier for the question, logging failures instead of returning them.
+func (tr *TerminalReader) notifyQuestion(ctx context.Context, input QuestionInput) {
+	if !tr.notify || *tr.nonInteractive {
+		return
+	}
+	notifiers := append([]Notifier{BellNotifier{Out: tr.out}}, tr.notifiers...)
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(ctx, input); err != nil {
+			log.Printf("question notification failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingNotifier remembers the questions it was notified about and fails with err.
+type recordingNotifier struct {
+	questions []string
+	err       error
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, input QuestionInput) error {
+	r.questions = append(r.questions, input.Question)
+	return r.err
+}
+
+func TestTerminalReader_Notify(t *testing.T) {
+	t.Run("bell is rung when enabled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var out bytes.Buffer
+		tr := NewTerminalReader(ctx, strings.NewReader("Boy\n"), WithOutput(&out), WithNotify())
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "What gender?\n\a", out.String())
+	})
+
+	t.Run("no bell by default", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var out bytes.Buffer
+		tr := NewTerminalReader(ctx, strings.NewReader("Boy\n"), WithOutput(&out))
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+		require.NoError(t, err)
+		assert.NotContains(t, out.String(), "\a")
+	})
+
+	t.Run("failing notifier doesn't fail the question", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		failing := &recordingNotifier{err: errors.New("no notification daemon")}
+		next := &recordingNotifier{}
+		tr := NewTerminalReader(ctx, strings.NewReader("Boy\n"),
+			WithOutput(io.Discard),
+			WithAnswerTimeout(time.Second),
+			WithNotify(failing, NopNotifier{}, next),
+		)
+		answer, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "Boy", answer)
+		assert.Equal(t, []string{"What gender?"}, failing.questions)
+		assert.Equal(t, []string{"What gender?"}, next.questions)
+	})
+
+	t.Run("nothing is rung in non-interactive mode", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var out bytes.Buffer
+		notifier := &recordingNotifier{}
+		tr := NewTerminalReader(ctx, strings.NewReader("Boy\n"),
+			WithOutput(&out),
+			WithNonInteractive(true),
+			WithNotify(notifier),
+		)
+		_, err := tr.Interactor(ctx, QuestionInput{Question: "What gender?"})
+
+		require.NoError(t, err)
+		assert.NotContains(t, out.String(), "\a")
+		assert.Empty(t, notifier.questions)
+	})
+}
+
+func TestBellNotifier(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, BellNotifier{Out: &out}.Notify(context.Background(), QuestionInput{}))
+	assert.Equal(t, "\a", out.String())
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// defaultTerminalWidth is used when the width of the output can't be detected.
+const defaultTerminalWidth = 80
+
+// WithWrapping overrides whether questions, reasons and choices are wrapped to the output width.
+// By default they are wrapped only when the output is a terminal.
+func WithWrapping(enabled bool) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.wrap = &enabled
+	}
+}
+
+// WithFallbackWidth sets the width used for wrapping when the width of the output can't be detected.
+// The default is 80 columns.
+func WithFallbackWidth(width int) TerminalOption {
+	return func(tr *TerminalReader) {
+		tr.fallbackWidth = width
+	}
+}
+
+// width returns the number of columns available for rendering questions, or zero when wrapping is disabled.
+func (tr *TerminalReader) width() int {
+	if !*tr.wrap {
+		return 0
+	}
+	if tr.screen != nil {
+		if width, _, err := term.GetSize(int(tr.screen.Fd())); err == nil && width > 0 {
+			return width
+		}
+	}
+	return tr.fallbackWidth
+}
+
+// screenOf returns the terminal behind the writer, or nil when it isn't one.
+func screenOf(out any) *os.File {
+	f, ok := out.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return nil
+	}
+	return f
+}
+
+// wrapText splits text into lines of at most width characters, breaking between words where possible.
+// Words longer than the width are split between runes. Line breaks in the text are kept.
+func wrapText(text string, width int) []string {
+	if width <= 0 {
+		return strings.Split(text, "\n")
+	}
+
+	var lines []string
+	for paragraph := range strings.SplitSeq(text, "\n") {
+		var line strings.Builder
+		lineWidth := 0
+		for _, word := range strings.Fields(paragraph) {
+			wordWidth := utf8.RuneCountInString(word)
+			if lineWidth > 0 && lineWidth+1+wordWidth > width {
+				lines = append(lines, line.String())
+				line.Reset()
+				lineWidth = 0
+			}
+			for wordWidth > width {
+				head, tail := splitRunes(word, width)
+				line.WriteString(head)
+				lines = append(lines, line.String())
+				line.Reset()
+				lineWidth = 0
+				word, wordWidth = tail, utf8.RuneCountInString(tail)
+			}
+			if lineWidth > 0 {
+				line.WriteByte(' ')
+				lineWidth++
+			}
+			line.WriteString(word)
+			lineWidth += wordWidth
+		}
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// wrapIndented wraps text after prefix so that continuation lines are indented by the width of the prefix.
+func wrapIndented(prefix, text string, width int) []string {
+	indent := utf8.RuneCountInString(prefix)
+	if width > 0 {
+		// keep at least one column for the text when the prefix is wider than the output
+		width = max(width-indent, 1)
+	}
+	lines := wrapText(text, width)
+	for i := range lines {
+		if i == 0 {
+			lines[i] = prefix + lines[i]
+		} else {
+			lines[i] = strings.Repeat(" ", indent) + lines[i]
+		}
+	}
+	return lines
+}
+
+// splitRunes splits s after n runes.
+func splitRunes(s string, n int) (string, string) {
+	for i := range s {
+		if n == 0 {
+			return s[:i], s[i:]
+		}
+		n--
+	}
+	return s, ""
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		width    int
+		expected []string
+	}{
+		{name: "short text", text: "Budget?", width: 20, expected: []string{"Budget?"}},
+		{name: "breaks between words", text: "budget determines which categories fit", width: 20, expected: []string{"budget determines", "which categories fit"}},
+		{name: "keeps line breaks", text: "first\nsecond", width: 20, expected: []string{"first", "second"}},
+		{name: "splits long words between runes", text: "ääääääääää", width: 4, expected: []string{"ääää", "ääää", "ää"}},
+		{name: "long word after short word", text: "a ääääää", width: 4, expected: []string{"a", "ääää", "ää"}},
+		{name: "zero width disables wrapping", text: "no wrapping here", width: 0, expected: []string{"no wrapping here"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, wrapText(tt.text, tt.width))
+		})
+	}
+}
+
+func TestTerminalReader_RenderReason(t *testing.T) {
+	reason := "I'm asking because the budget determines which categories of presents fit, from books to bikes and game consoles."
+
+	tests := []struct {
+		name     string
+		question QuestionInput
+		expected string
+	}{
+		{
+			name:     "without reason",
+			question: QuestionInput{Question: "Budget?", Choices: []string{"$50", "$100"}},
+			expected: "Budget?\n1) $50\n2) $100\n\n",
+		},
+		{
+			name:     "with reason wrapped to the width",
+			question: QuestionInput{Question: "Budget?", Reason: reason, Choices: []string{"$50", "$100"}},
+			expected: "Budget?\n" +
+				"I'm asking because the budget determines which categories of presents fit, from\n" +
+				"books to bikes and game consoles.\n" +
+				"1) $50\n2) $100\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var out bytes.Buffer
+			tr := NewTerminalReader(ctx, strings.NewReader("1\n"), WithOutput(&out), WithAnswerTimeout(time.Second), WithWrapping(true))
+			_, err := tr.Interactor(ctx, tt.question)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
+}
+
+func TestWrapIndented(t *testing.T) {
+	assert.Equal(t, []string{"1) Lego", "   Technic"}, wrapIndented("1) ", "Lego Technic", 10))
+	assert.Equal(t, []string{"10) a", "    b"}, wrapIndented("10) ", "a b", 2), "keeps a column for the text")
+	assert.Equal(t, []string{"1) Lego Technic"}, wrapIndented("1) ", "Lego Technic", 0))
+}
+
+func TestTerminalReader_RenderWrapped(t *testing.T) {
+	question := QuestionInput{
+		Question: "Which kind of present would the older child, who is eleven years old, enjoy the most this Christmas?",
+		Choices: []string{
+			"A big LEGO Technic set with motors that can be programmed from a tablet",
+			"Книги о путешествиях",
+			"Bike",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		opts     []TerminalOption
+		expected string
+	}{
+		{
+			name: "80 columns",
+			opts: []TerminalOption{WithWrapping(true)},
+			expected: "" +
+				"Which kind of present would the older child, who is eleven years old, enjoy the\n" +
+				"most this Christmas?\n" +
+				"1) A big LEGO Technic set with motors that can be programmed from a tablet\n" +
+				"2) Книги о путешествиях\n" +
+				"3) Bike\n\n",
+		},
+		{
+			name: "40 columns",
+			opts: []TerminalOption{WithWrapping(true), WithFallbackWidth(40)},
+			expected: "" +
+				"Which kind of present would the older\n" +
+				"child, who is eleven years old, enjoy\n" +
+				"the most this Christmas?\n" +
+				"1) A big LEGO Technic set with motors\n" +
+				"   that can be programmed from a tablet\n" +
+				"2) Книги о путешествиях\n" +
+				"3) Bike\n\n",
+		},
+		{
+			name: "not wrapped when the output isn't a terminal",
+			opts: []TerminalOption{WithFallbackWidth(40)},
+			expected: "" +
+				"Which kind of present would the older child, who is eleven years old, enjoy the most this Christmas?\n" +
+				"1) A big LEGO Technic set with motors that can be programmed from a tablet\n" +
+				"2) Книги о путешествиях\n" +
+				"3) Bike\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var out bytes.Buffer
+			opts := append([]TerminalOption{WithOutput(&out), WithAnswerTimeout(time.Second)}, tt.opts...)
+			tr := NewTerminalReader(ctx, strings.NewReader("3\n"), opts...)
+			_, err := tr.Interactor(ctx, question)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/firebase/genkit/go/ai"
+	"google.golang.org/genai"
+)
+
+// charsPerToken is how many characters a token is taken to be when the tokens are estimated.
+const charsPerToken = 4
+
+// TokenCount is the number of tokens of messages for a model, counted with Generator.CountTokens.
+type TokenCount struct {
+	Tokens int
+	// Estimated is set when the provider can't count tokens, and they are estimated from the length of
+	// the messages at charsPerToken characters a token instead.
+	Estimated bool
+}
+
+// tokenCounter counts the tokens of the messages for the model, named without its provider prefix.
+type tokenCounter func(ctx context.Context, model string, messages []*ai.Message) (int, error)
+
+// estimateTokens estimates the tokens of the messages from the characters of their text, tool requests
+// and tool responses, rounding up. Media aren't counted.
+func estimateTokens(messages []*ai.Message) TokenCount {
+	chars := 0
+	for _, message := range messages {
+		if message == nil {
+			continue
+		}
+		for _, part := range message.Content {
+			chars += utf8.RuneCountInString(partText(part))
+		}
+	}
+	return TokenCount{Tokens: (chars + charsPerToken - 1) / charsPerToken, Estimated: true}
+}
+
+// partText returns the text of a part the tokens are estimated from, with tool requests and responses
+// as JSON.
+func partText(part *ai.Part) string {
+	var value any
+	switch {
+	case part == nil || part.IsMedia():
+		return ""
+	case part.IsToolRequest():
+		value = part.ToolRequest
+	case part.IsToolResponse():
+		value = part.ToolResponse
+	default:
+		return part.Text
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// geminiTokenCounter returns a tokenCounter calling the count tokens method of the Gemini API, of
+// either Google AI or Vertex AI.
+func geminiTokenCounter(client *genai.Client) tokenCounter {
+	return func(ctx context.Context, model string, messages []*ai.Message) (int, error) {
+		contents, err := geminiContents(messages)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := client.Models.CountTokens(ctx, model, contents, nil)
+		if err != nil {
+			return 0, fmt.Errorf("can't count the tokens with %s: %w", model, err)
+		}
+		return int(resp.TotalTokens), nil
+	}
+}
+
+// geminiContents converts the messages to Gemini contents. The Gemini API counts the contents of the
+// model and of the user only, so the system and tool messages are counted as the user's.
+func geminiContents(messages []*ai.Message) ([]*genai.Content, error) {
+	contents := make([]*genai.Content, 0, len(messages))
+	for _, message := range messages {
+		if message == nil {
+			continue
+		}
+		parts := make([]*genai.Part, 0, len(message.Content))
+		for _, part := range message.Content {
+			converted, err := geminiPart(part)
+			if err != nil {
+				return nil, err
+			}
+			if converted != nil {
+				parts = append(parts, converted)
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		role := genai.Role(genai.RoleUser)
+		if message.Role == ai.RoleModel {
+			role = genai.RoleModel
+		}
+		contents = append(contents, genai.NewContentFromParts(parts, role))
+	}
+	return contents, nil
+}
+
+// geminiPart converts a part to a Gemini part, or returns nil for a part the tokens aren't counted of.
+func geminiPart(part *ai.Part) (*genai.Part, error) {
+	switch {
+	case part == nil:
+		return nil, nil
+	case part.IsMedia():
+		media := Media{URL: part.Text}
+		if !media.IsDataURI() {
+			return genai.NewPartFromURI(part.Text, part.ContentType), nil
+		}
+		contentType, data, err := media.decodeData()
+		if err != nil {
+			return nil, err
+		}
+		return genai.NewPartFromBytes(data, contentType), nil
+	case part.IsToolRequest():
+		return genai.NewPartFromFunctionCall(part.ToolRequest.Name, asArgs(part.ToolRequest.Input)), nil
+	case part.IsToolResponse():
+		return genai.NewPartFromFunctionResponse(part.ToolResponse.Name, map[string]any{"output": part.ToolResponse.Output}), nil
+	case part.Text == "":
+		return nil, nil
+	default:
+		return genai.NewPartFromText(part.Text), nil
+	}
+}
+
+// asArgs returns the input of a tool request as the arguments of a Gemini function call.
+func asArgs(input any) map[string]any {
+	if args, ok := input.(map[string]any); ok {
+		return args
+	}
+	return map[string]any{"input": input}
+}
+
+// trimHistory drops the oldest messages of the history until the history with the next message fits
+// within budget tokens, as counted by the generator. The system messages and the first user message,
+// which holds the request, are kept, and the tool responses of a dropped tool request are dropped with
+// it. A history that doesn't fit with only its last message left is returned so, with a warning.
+func trimHistory(ctx context.Context, generator Generator, history []*ai.Message, next *ai.Message, budget int) ([]*ai.Message, error) {
+	kept := 0
+	for kept < len(history) && history[kept].Role == ai.RoleSystem {
+		kept++
+	}
+	if kept < len(history) && history[kept].Role == ai.RoleUser {
+		kept++
+	}
+	head, rest := history[:kept], history[kept:]
+	for {
+		trimmed := append(slices.Clone(head), rest...)
+		count, err := generator.CountTokens(ctx, append(slices.Clone(trimmed), next))
+		if err != nil {
+			return nil, err
+		}
+		if count.Tokens <= budget {
+			return trimmed, nil
+		}
+		if len(rest) <= 1 {
+			log.Printf("Warning: the history takes %d tokens even when trimmed, over the budget of %d", count.Tokens, budget)
+			return trimmed, nil
+		}
+		rest = rest[1:]
+		for len(rest) > 1 && rest[0].Role == ai.RoleTool {
+			rest = rest[1:]
+		}
+	}
+}
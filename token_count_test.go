@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// giftHistory is a conversation with a question the user answered.
+var giftHistory = []*ai.Message{
+	ai.NewSystemTextMessage("You help find gifts."),
+	ai.NewUserTextMessage("Suggest a gift."),
+	ai.NewModelMessage(ai.NewToolRequestPart(&ai.ToolRequest{Name: "askQuestion", Input: map[string]any{"question": "How old?"}})),
+	ai.NewMessage(ai.RoleTool, nil, ai.NewToolResponsePart(&ai.ToolResponse{Name: "askQuestion", Output: "8"})),
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []*ai.Message
+		expected int
+	}{
+		{name: "no messages", messages: nil, expected: 0},
+		{name: "text rounded up", messages: []*ai.Message{ai.NewUserTextMessage("A LEGO set")}, expected: 3},
+		{name: "characters rather than bytes", messages: []*ai.Message{ai.NewUserTextMessage("Грузовик")}, expected: 2},
+		{name: "tool requests and responses as JSON", messages: giftHistory[2:], expected: 23},
+		{name: "media left out", messages: []*ai.Message{ai.NewUserMessage(ai.NewMediaPart("image/png", "data:image/png;base64,iVBORw0KGgo="), ai.NewTextPart("Like this?"))}, expected: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, TokenCount{Tokens: tt.expected, Estimated: true}, estimateTokens(tt.messages))
+		})
+	}
+}
+
+func TestTrimHistory(t *testing.T) {
+	hobbies := ai.NewModelTextMessage("Any hobbies?")
+	lego := ai.NewUserTextMessage("LEGO")
+	history := append(slices.Clone(giftHistory), hobbies, lego)
+	next := ai.NewUserTextMessage("Under $50.")
+
+	tests := []struct {
+		name     string
+		counts   []int
+		expected []*ai.Message
+		warning  string
+	}{
+		{name: "within budget", counts: []int{50}, expected: history},
+		{name: "tool response dropped with its request", counts: []int{90, 40}, expected: []*ai.Message{giftHistory[0], giftHistory[1], hobbies, lego}},
+		{name: "over budget when trimmed", counts: []int{90, 80, 70}, expected: []*ai.Message{giftHistory[0], giftHistory[1], lego}, warning: "the history takes 70 tokens even when trimmed, over the budget of 50"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureLog(t)
+			generator := NewMockGenerator(nil, nil)
+			for _, tokens := range tt.counts {
+				generator.tokenCounts = append(generator.tokenCounts, TokenCount{Tokens: tokens})
+			}
+
+			trimmed, err := trimHistory(context.Background(), generator, history, next, 50)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, trimmed)
+			require.Len(t, generator.countedMessages, len(tt.counts))
+			assert.Equal(t, append(slices.Clone(tt.expected), next), generator.countedMessages[len(tt.counts)-1], "the next message is counted with the history")
+			if tt.warning == "" {
+				assert.Empty(t, out.String())
+				return
+			}
+			assert.Contains(t, out.String(), tt.warning)
+		})
+	}
+}
+
+func TestGenkitGenerator_CountTokensWithGemini(t *testing.T) {
+	var path string
+	var request struct {
+		Contents []*genai.Content `json:"contents"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"totalTokens": 42}`))
+	}))
+	defer server.Close()
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		Backend:     genai.BackendGeminiAPI,
+		APIKey:      "key",
+		HTTPOptions: genai.HTTPOptions{BaseURL: server.URL},
+	})
+	require.NoError(t, err)
+	generator := &GenkitGenerator{Model: "googleai/gemini-2.5-flash", countTokens: geminiTokenCounter(client)}
+
+	count, err := generator.CountTokens(context.Background(), giftHistory)
+
+	require.NoError(t, err)
+	assert.Equal(t, TokenCount{Tokens: 42}, count)
+	assert.Contains(t, path, "models/gemini-2.5-flash:countTokens")
+	require.Len(t, request.Contents, 4)
+	assert.Equal(t, []string{"user", "user", "model", "user"}, []string{request.Contents[0].Role, request.Contents[1].Role, request.Contents[2].Role, request.Contents[3].Role})
+	assert.Equal(t, "You help find gifts.", request.Contents[0].Parts[0].Text)
+	assert.Equal(t, "askQuestion", request.Contents[2].Parts[0].FunctionCall.Name)
+	assert.Equal(t, map[string]any{"output": "8"}, request.Contents[3].Parts[0].FunctionResponse.Response)
+}
+
+func TestGenkitGenerator_CountTokensError(t *testing.T) {
+	generator := &GenkitGenerator{Model: "googleai/gemini-2.5-flash", countTokens: func(context.Context, string, []*ai.Message) (int, error) {
+		return 0, assert.AnError
+	}}
+
+	_, err := generator.CountTokens(context.Background(), giftHistory)
+
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestGenkitGenerator_CountTokensEstimated(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator *GenkitGenerator
+	}{
+		{name: "provider without token counting", generator: &GenkitGenerator{Model: "openai/gpt-4o-mini"}},
+		{name: "default model", generator: &GenkitGenerator{countTokens: func(context.Context, string, []*ai.Message) (int, error) {
+			t.Fatal("the default model isn't known to count its tokens")
+			return 0, nil
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := tt.generator.CountTokens(context.Background(), []*ai.Message{ai.NewUserTextMessage("A LEGO set")})
+
+			require.NoError(t, err)
+			assert.Equal(t, TokenCount{Tokens: 3, Estimated: true}, count)
+		})
+	}
+}
+
+func TestGeminiContents_Media(t *testing.T) {
+	contents, err := geminiContents([]*ai.Message{ai.NewUserMessage(
+		ai.NewMediaPart("image/png", "data:image/png;base64,iVBORw0KGgo="),
+		ai.NewMediaPart("image/jpeg", "https://example.com/bike.jpg"),
+	)})
+
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	assert.Equal(t, &genai.Blob{MIMEType: "image/png", Data: []byte("\x89PNG\r\n\x1a\n")}, contents[0].Parts[0].InlineData)
+	assert.Equal(t, &genai.FileData{MIMEType: "image/jpeg", FileURI: "https://example.com/bike.jpg"}, contents[0].Parts[1].FileData)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// maxParseAttempts is how many answers a tool handler asks for before giving up on a question whose
+// answers can't be parsed.
+const maxParseAttempts = 3
+
+// ToolResult is what a tool handler responds to the interrupting tool with.
+type ToolResult struct {
+	// Output is the tool output the model receives, such as a bool for the confirm tool.
+	Output any
+	// Metadata is attached to the tool response.
+	Metadata map[string]any
+}
+
+// ToolHandler answers the interrupts of one tool, asking the user through the interactor. input is the
+// raw tool input. Errors are treated like the errors of Interactor.Ask: ErrQuestionSkipped and the
+// other steering errors are passed on to the model, ErrAnswerPending suspends the run and the rest stop it.
+type ToolHandler func(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error)
+
+// RegisterToolHandler answers the interrupts of the named tool with the handler instead of asking
+// them as QuestionInput questions.
+func (ih *InterruptionHandler) RegisterToolHandler(name string, handler ToolHandler) {
+	if ih.toolHandlers == nil {
+		ih.toolHandlers = make(map[string]ToolHandler)
+	}
+	ih.toolHandlers[name] = handler
+}
+
+// AnswerParser checks an answer and converts it into the output of a tool. Its error tells the user
+// what is wrong with the answer.
+type AnswerParser func(answer string) (any, error)
+
+type answerParserKey struct{}
+
+// WithAnswerParser returns a context telling the interaction how answers to the question are parsed,
+// so that it can reject invalid answers and ask again by itself.
+func WithAnswerParser(ctx context.Context, parse AnswerParser) context.Context {
+	return context.WithValue(ctx, answerParserKey{}, parse)
+}
+
+// AnswerParserFromContext returns the parser of answers to the question being asked.
+func AnswerParserFromContext(ctx context.Context) (AnswerParser, bool) {
+	parse, ok := ctx.Value(answerParserKey{}).(AnswerParser)
+	return parse, ok
+}
+
+// askParsed asks the question until the parser accepts the answer and returns the parsed value.
+// Interactors that know about the parser re-prompt by themselves; for the others a rejected answer is
+// reported with Notify and the question is asked again, up to maxParseAttempts times.
+func askParsed(ctx context.Context, interactor Interactor, input QuestionInput, parse AnswerParser) (any, error) {
+	return askParsedAttempts(ctx, interactor, input, parse, maxParseAttempts)
+}
+
+// askParsedAttempts is askParsed asking up to attempts times.
+func askParsedAttempts(ctx context.Context, interactor Interactor, input QuestionInput, parse AnswerParser, attempts int) (any, error) {
+	ctx = WithAnswerParser(ctx, parse)
+	var err error
+	for range attempts {
+		var answer Answer
+		answer, err = interactor.Ask(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		if answer.Skipped {
+			return nil, ErrQuestionSkipped
+		}
+		var value any
+		if value, err = parse(answer.Value); err == nil {
+			return value, nil
+		}
+		if notifyErr := interactor.Notify(ctx, invalidAnswerMessage(err)); notifyErr != nil {
+			return nil, notifyErr
+		}
+	}
+	return nil, fmt.Errorf("no valid answer after %d attempts: %w", attempts, err)
+}
+
+// invalidAnswerMessage tells the user why the answer was rejected.
+func invalidAnswerMessage(err error) string {
+	return "Invalid answer: " + err.Error()
+}
+
+// decodeToolInput converts the raw input of a tool call into the tool's input struct.
+func decodeToolInput(input map[string]any, out any) error {
+	jsonBytes, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("failed to marshal input: %w", err)
+	}
+	if err := json.Unmarshal(jsonBytes, out); err != nil {
+		return &ErrInvalidInput{Err: fmt.Errorf("failed to unmarshal input: %w", err)}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createInterruptPart creates an interrupting tool request with the raw input.
+func createInterruptPart(name string, input map[string]any) *ai.Part {
+	return &ai.Part{
+		Kind:        ai.PartToolRequest,
+		ToolRequest: &ai.ToolRequest{Name: name, Input: input},
+		Metadata:    map[string]any{"interrupt": "interruptTest"},
+	}
+}
+
+// sequenceInteractor is an Interactor returning the answers in order and recording the notifications.
+type sequenceInteractor struct {
+	answers       []Answer
+	asked         int
+	notifications []string
+}
+
+func (s *sequenceInteractor) Ask(context.Context, QuestionInput) (Answer, error) {
+	if s.asked >= len(s.answers) {
+		return Answer{}, errors.New("unexpected question")
+	}
+	s.asked++
+	return s.answers[s.asked-1], nil
+}
+
+func (s *sequenceInteractor) Notify(_ context.Context, message string) error {
+	s.notifications = append(s.notifications, message)
+	return nil
+}
+
+func (s *sequenceInteractor) Close() error {
+	return nil
+}
+
+func parseDigit(answer string) (any, error) {
+	n, err := strconv.Atoi(answer)
+	if err != nil {
+		return nil, errors.New("expected a number")
+	}
+	return n, nil
+}
+
+func TestAskParsed(t *testing.T) {
+	tests := []struct {
+		name          string
+		answers       []Answer
+		expected      any
+		err           error
+		notifications int
+	}{
+		{name: "valid answer", answers: []Answer{{Value: "4"}}, expected: 4},
+		{name: "asks again", answers: []Answer{{Value: "four"}, {Value: "4"}}, expected: 4, notifications: 1},
+		{name: "skipped", answers: []Answer{{Skipped: true}}, err: ErrQuestionSkipped},
+		{name: "gives up", answers: []Answer{{Value: "a"}, {Value: "b"}, {Value: "c"}}, notifications: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interactor := &sequenceInteractor{answers: tt.answers}
+
+			value, err := askParsed(context.Background(), interactor, QuestionInput{Question: "How many?"}, parseDigit)
+
+			assert.Len(t, interactor.notifications, tt.notifications)
+			switch {
+			case tt.err != nil:
+				assert.ErrorIs(t, err, tt.err)
+			case tt.expected == nil:
+				assert.ErrorContains(t, err, "no valid answer after 3 attempts: expected a number")
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestInterruptionHandler_ToolHandlerSuspendAndResume(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createInterruptPart("confirm", map[string]any{"statement": "Stay under $50?"}),
+				createToolRequestPart("askQuestion", "What ages?", nil),
+			),
+			createTextResponse("Gifts under $50 for ages 8 and 11...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "confirm": createMockTool("confirm")},
+	)
+	confirmations := 0
+	handler := &InterruptionHandler{
+		generator: mockGen,
+		UserInteraction: func(ctx context.Context, input QuestionInput) (string, error) {
+			if id, ok := CorrelationIDFromContext(ctx); ok {
+				return "8 and 11 (" + id + ")", nil
+			}
+			return "", &ErrAnswerPending{ID: "ages"}
+		},
+	}
+	handler.RegisterToolHandler("confirm", func(context.Context, Interactor, map[string]any) (ToolResult, error) {
+		confirmations++
+		return ToolResult{Output: true, Metadata: map[string]any{"source": "test"}}, nil
+	})
+
+	_, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+	var suspended *ErrRunSuspended
+	require.True(t, errors.As(err, &suspended), "unexpected error: %v", err)
+	assert.Equal(t, map[int]string{1: "ages"}, suspended.Pending)
+	assert.Equal(t, map[int]ToolResult{0: {Output: true, Metadata: map[string]any{"source": "test"}}}, suspended.Results)
+
+	response, err := handler.Resume(context.Background(), suspended)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Gifts under $50 for ages 8 and 11...", response.Text())
+	assert.Equal(t, 1, confirmations, "an answered interrupt isn't asked again")
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 2)
+	assert.Equal(t, true, parts[0].ToolResponse.Output)
+	assert.Equal(t, map[string]any{"interruptResponse": map[string]any{"source": "test"}}, parts[0].Metadata)
+	assert.Equal(t, "8 and 11 (ages)", parts[1].ToolResponse.Output)
+}
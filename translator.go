@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// modelLanguage is the language the model is asked and answered in.
+const modelLanguage = "en"
+
+// Translator translates texts between the model's language and the user's.
+type Translator interface {
+	// Translate returns the text in the language with the code targetLang, such as "de" or "en".
+	Translate(ctx context.Context, text, targetLang string) (string, error)
+}
+
+// NoopTranslator is a Translator returning the texts unchanged.
+type NoopTranslator struct{}
+
+// Translate returns the text unchanged.
+func (NoopTranslator) Translate(_ context.Context, text, _ string) (string, error) {
+	return text, nil
+}
+
+// GeneratorTranslator is a Translator asking the model for the translations.
+type GeneratorTranslator struct {
+	generator Generator
+}
+
+// NewGeneratorTranslator returns a Translator translating with the generator's model.
+func NewGeneratorTranslator(generator Generator) *GeneratorTranslator {
+	return &GeneratorTranslator{generator: generator}
+}
+
+// Translate asks the model for the translation of the text. Blank texts are returned unchanged.
+func (t *GeneratorTranslator) Translate(ctx context.Context, text, targetLang string) (string, error) {
+	if strings.TrimSpace(text) == "" {
+		return text, nil
+	}
+	prompt := fmt.Sprintf("Translate the following text into the language with the code %q. Reply with the translation only, keeping numbers, names and formatting as they are.\n\n%s", targetLang, text)
+	resp, err := t.generator.Generate(ctx, ai.WithPrompt(prompt))
+	if err != nil {
+		return "", err
+	}
+	translation := strings.TrimSpace(resp.Text())
+	if translation == "" {
+		return "", errors.New("the translation is empty")
+	}
+	return translation, nil
+}
+
+// SetTranslation translates the questions of the inner handler, when it is an InterruptionHandler, to
+// the locale. Follow-up questions aren't translated.
+func (cv *ConversationLoopHandler) SetTranslation(locale string, translator Translator) {
+	if inner, ok := cv.inner.(*InterruptionHandler); ok {
+		inner.Locale = locale
+		inner.Translator = translator
+	}
+}
+
+// translates reports whether questions are translated to the user's locale.
+func (ih *InterruptionHandler) translates() bool {
+	return ih.Translator != nil && ih.Locale != "" && !strings.EqualFold(ih.Locale, modelLanguage)
+}
+
+// askTranslated asks the question in the user's locale and translates the answer back. An answer
+// picking a choice is answered with the original choice; free text is translated. The metadata holds
+// the locale and the question and answer as the user saw them.
+func (ih *InterruptionHandler) askTranslated(ctx context.Context, question QuestionInput) (ToolResult, error) {
+	shown, err := ih.translateQuestion(ctx, question)
+	if err != nil {
+		return ToolResult{}, fmt.Errorf("failed to translate the question: %w", err)
+	}
+	reply, err := askQuestion(ctx, ih.interactor(), shown)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	if reply.Skipped {
+		return ToolResult{Output: answerText(reply)}, nil
+	}
+
+	output := reply.Value
+	if i := slices.Index(shown.Choices, reply.Value); i >= 0 {
+		output = question.Choices[i]
+	} else if !slices.Contains(question.ChoiceValues, reply.Value) {
+		if output, err = ih.Translator.Translate(ctx, reply.Value, modelLanguage); err != nil {
+			return ToolResult{}, fmt.Errorf("failed to translate the answer: %w", err)
+		}
+	}
+	metadata := map[string]any{"locale": ih.Locale, "question": shown.Question, "answer": reply.Value}
+	return ToolResult{Output: output, Metadata: metadata}, nil
+}
+
+// translateQuestion returns the question with its texts translated to the user's locale. The choice
+// values are kept, so that the answer picking a choice is still replaced by its value.
+func (ih *InterruptionHandler) translateQuestion(ctx context.Context, question QuestionInput) (QuestionInput, error) {
+	translate := func(text string) (string, error) {
+		if text == "" {
+			return "", nil
+		}
+		return ih.Translator.Translate(ctx, text, ih.Locale)
+	}
+	translateAll := func(texts []string) ([]string, error) {
+		if texts == nil {
+			return nil, nil
+		}
+		translated := make([]string, len(texts))
+		for i, text := range texts {
+			var err error
+			if translated[i], err = translate(text); err != nil {
+				return nil, err
+			}
+		}
+		return translated, nil
+	}
+
+	shown := question
+	var err error
+	if shown.Question, err = translate(question.Question); err != nil {
+		return QuestionInput{}, err
+	}
+	if shown.Reason, err = translate(question.Reason); err != nil {
+		return QuestionInput{}, err
+	}
+	if shown.Choices, err = translateAll(question.Choices); err != nil {
+		return QuestionInput{}, err
+	}
+	if shown.ChoiceDescriptions, err = translateAll(question.ChoiceDescriptions); err != nil {
+		return QuestionInput{}, err
+	}
+	// a default picking a choice is shown as the translated choice
+	if i := slices.Index(question.Choices, question.Default); i >= 0 {
+		shown.Default = shown.Choices[i]
+	} else if shown.Default, err = translate(question.Default); err != nil {
+		return QuestionInput{}, err
+	}
+	return shown, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dictionaryTranslator translates from a dictionary keyed by the target language and the text.
+type dictionaryTranslator struct {
+	dictionary map[string]string
+	calls      []string
+}
+
+func (d *dictionaryTranslator) Translate(_ context.Context, text, targetLang string) (string, error) {
+	key := targetLang + ":" + text
+	d.calls = append(d.calls, key)
+	translation, ok := d.dictionary[key]
+	if !ok {
+		return "", fmt.Errorf("no translation for %q", key)
+	}
+	return translation, nil
+}
+
+func TestInterruptionHandler_Translation(t *testing.T) {
+	dictionary := map[string]string{
+		"de:What gender?":           "Welches Geschlecht?",
+		"de:Gifts differ by gender": "Geschenke unterscheiden sich",
+		"de:Boy":                    "Junge",
+		"de:Girl":                   "Mädchen",
+		"de:What budget?":           "Welches Budget?",
+		"de:Under $25":              "Unter 25 $",
+		"de:Over $25":               "Über 25 $",
+		"en:Zwillinge":              "Twins",
+	}
+
+	tests := []struct {
+		name     string
+		input    map[string]any
+		answer   string
+		shown    QuestionInput
+		output   string
+		metadata map[string]any
+	}{
+		{
+			name:   "free text answer translated back",
+			input:  map[string]any{"question": "What gender?", "reason": "Gifts differ by gender", "choices": []any{"Boy", "Girl"}},
+			answer: "Zwillinge",
+			shown: QuestionInput{
+				Question: "Welches Geschlecht?",
+				Reason:   "Geschenke unterscheiden sich",
+				Choices:  []string{"Junge", "Mädchen"},
+			},
+			output:   "Twins",
+			metadata: map[string]any{"locale": "de", "question": "Welches Geschlecht?", "answer": "Zwillinge"},
+		},
+		{
+			name:     "choice answered with the original",
+			input:    map[string]any{"question": "What gender?", "choices": []any{"Boy", "Girl"}, "default": "Girl"},
+			answer:   "Mädchen",
+			shown:    QuestionInput{Question: "Welches Geschlecht?", Choices: []string{"Junge", "Mädchen"}, Default: "Mädchen"},
+			output:   "Girl",
+			metadata: map[string]any{"locale": "de", "question": "Welches Geschlecht?", "answer": "Mädchen"},
+		},
+		{
+			name: "choice values kept",
+			input: map[string]any{"question": "What budget?", "choices": []any{
+				map[string]any{"value": "budget_lt_25", "label": "Under $25"},
+				map[string]any{"value": "budget_gte_25", "label": "Over $25"},
+			}},
+			answer: "über 25 $",
+			shown: QuestionInput{
+				Question:     "Welches Budget?",
+				Choices:      []string{"Unter 25 $", "Über 25 $"},
+				ChoiceValues: []string{"budget_lt_25", "budget_gte_25"},
+			},
+			output:   "budget_gte_25",
+			metadata: map[string]any{"locale": "de", "question": "Welches Budget?", "answer": "budget_gte_25"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGen := NewMockGenerator(
+				[]*ai.ModelResponse{
+					createInterruptedResponse(createInterruptPart("askQuestion", tt.input)),
+					createTextResponse("A LEGO set", "stop"),
+				},
+				map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+			)
+			var shown QuestionInput
+			handler := &InterruptionHandler{
+				generator:  mockGen,
+				Locale:     "de",
+				Translator: &dictionaryTranslator{dictionary: dictionary},
+				UserInteraction: func(_ context.Context, input QuestionInput) (string, error) {
+					shown = input
+					return tt.answer, nil
+				},
+			}
+
+			_, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.shown, shown)
+			parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+			require.Len(t, parts, 1)
+			assert.Equal(t, tt.output, parts[0].ToolResponse.Output)
+			assert.Equal(t, map[string]any{"interruptResponse": tt.metadata}, parts[0].Metadata)
+		})
+	}
+}
+
+func TestInterruptionHandler_TranslationFailure(t *testing.T) {
+	mockGen := NewMockGenerator(nil, map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")})
+	asked := false
+	handler := &InterruptionHandler{
+		generator:  mockGen,
+		Locale:     "de",
+		Translator: &dictionaryTranslator{},
+		UserInteraction: func(context.Context, QuestionInput) (string, error) {
+			asked = true
+			return "", nil
+		},
+	}
+
+	_, err := handler.handleResponse(context.Background(), createInterruptedResponse(createToolRequestPart("askQuestion", "What gender?", nil)))
+
+	assert.EqualError(t, err, `failed to translate the question: no translation for "de:What gender?"`)
+	assert.False(t, asked)
+}
+
+func TestInterruptionHandler_TranslatesOnlyOtherLanguages(t *testing.T) {
+	tests := []struct {
+		name       string
+		locale     string
+		translator Translator
+	}{
+		{name: "no locale", translator: &dictionaryTranslator{}},
+		{name: "english", locale: "EN", translator: &dictionaryTranslator{}},
+		{name: "no translator", locale: "de"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &InterruptionHandler{Locale: tt.locale, Translator: tt.translator}
+
+			assert.False(t, handler.translates())
+		})
+	}
+}
+
+func TestGeneratorTranslator(t *testing.T) {
+	mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse(" Welches Geschlecht?\n", "stop")}, nil)
+	translator := NewGeneratorTranslator(mockGen)
+
+	translation, err := translator.Translate(context.Background(), "What gender?", "de")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Welches Geschlecht?", translation)
+	require.Len(t, mockGen.capturedCalls, 1)
+
+	blank, err := translator.Translate(context.Background(), " ", "de")
+
+	require.NoError(t, err)
+	assert.Equal(t, " ", blank)
+	assert.Len(t, mockGen.capturedCalls, 1, "blank texts aren't sent to the model")
+}
+
+func TestGeneratorTranslator_Errors(t *testing.T) {
+	t.Run("empty translation", func(t *testing.T) {
+		mockGen := NewMockGenerator([]*ai.ModelResponse{createTextResponse("", "stop")}, nil)
+
+		_, err := NewGeneratorTranslator(mockGen).Translate(context.Background(), "What gender?", "de")
+
+		assert.EqualError(t, err, "the translation is empty")
+	})
+
+	t.Run("generation failure", func(t *testing.T) {
+		mockGen := NewMockGenerator(nil, nil)
+
+		_, err := NewGeneratorTranslator(mockGen).Translate(context.Background(), "What gender?", "de")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestNoopTranslator(t *testing.T) {
+	translation, err := NoopTranslator{}.Translate(context.Background(), "What gender?", "de")
+
+	require.NoError(t, err)
+	assert.Equal(t, "What gender?", translation)
+}
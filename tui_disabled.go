@@ -0,0 +1,10 @@
+//go:build !tui
+
+package main
+
+import "context"
+
+// newTUI reports that the TUI isn't available; it is compiled in with the tui build tag.
+func newTUI(context.Context, ...TerminalOption) (Interactor, bool) {
+	return nil, false
+}
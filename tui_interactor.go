@@ -0,0 +1,280 @@
+//go:build tui
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// tuiHint explains the keys below the input box.
+const tuiHint = "enter: answer · ↑/↓: pick a choice · esc: skip · ctrl+c: quit"
+
+// tuiRole tells who a transcript entry comes from.
+type tuiRole int
+
+const (
+	tuiQuestion tuiRole = iota
+	tuiAnswer
+	tuiNotice
+)
+
+// tuiEntry is a line of the transcript.
+type tuiEntry struct {
+	role tuiRole
+	text string
+}
+
+// tuiReply is the outcome of a question shown by the TUI.
+type tuiReply struct {
+	answer Answer
+	err    error
+}
+
+// tuiQuestionMsg shows a question; its answer is sent to reply.
+type tuiQuestionMsg struct {
+	input QuestionInput
+	reply chan<- tuiReply
+	// secret masks the typed answer and keeps it out of the transcript.
+	secret bool
+}
+
+// tuiCancelMsg withdraws the question answered through reply, because its asker is gone.
+type tuiCancelMsg struct {
+	reply chan<- tuiReply
+}
+
+// tuiNoticeMsg adds a message to the transcript.
+type tuiNoticeMsg string
+
+// tuiModel is the Bubble Tea model of the TUI: a scrolling transcript of the conversation,
+// the choices of the pending question and an input box.
+type tuiModel struct {
+	transcript []tuiEntry
+	pending    *tuiQuestionMsg
+	input      []rune
+	// selected is the index of the highlighted choice.
+	selected int
+	height   int
+}
+
+func (m tuiModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tuiQuestionMsg:
+		m.pending, m.input = &msg, nil
+		m.selected = max(slices.Index(msg.input.Choices, msg.input.Default), 0)
+		m.transcript = append(m.transcript, tuiEntry{role: tuiQuestion, text: msg.input.Question})
+		if msg.input.Reason != "" {
+			m.transcript = append(m.transcript, tuiEntry{role: tuiNotice, text: msg.input.Reason})
+		}
+	case tuiCancelMsg:
+		if m.pending != nil && m.pending.reply == msg.reply {
+			m.pending = nil
+			m.transcript = append(m.transcript, tuiEntry{role: tuiNotice, text: "(question withdrawn)"})
+		}
+	case tuiNoticeMsg:
+		m.transcript = append(m.transcript, tuiEntry{role: tuiNotice, text: string(msg)})
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case tea.KeyMsg:
+		return m.updateKey(msg)
+	}
+	return m, nil
+}
+
+// updateKey edits the answer and submits it.
+func (m tuiModel) updateKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.Type == tea.KeyCtrlC {
+		m.reply(tuiReply{err: ErrConversationAborted})
+		return m, tea.Quit
+	}
+	if m.pending == nil {
+		return m, nil
+	}
+	choices := m.pending.input.Choices
+
+	switch msg.Type {
+	case tea.KeyUp:
+		if len(choices) > 0 {
+			m.selected = (m.selected + len(choices) - 1) % len(choices)
+		}
+	case tea.KeyDown:
+		if len(choices) > 0 {
+			m.selected = (m.selected + 1) % len(choices)
+		}
+	case tea.KeyEsc:
+		m.transcript = append(m.transcript, tuiEntry{role: tuiAnswer, text: "(skipped)"})
+		m.reply(tuiReply{answer: Answer{Skipped: true}})
+	case tea.KeyBackspace:
+		if len(m.input) > 0 {
+			m.input = m.input[:len(m.input)-1]
+		}
+	case tea.KeySpace:
+		m.input = append(m.input, ' ')
+	case tea.KeyRunes:
+		m.input = append(m.input, msg.Runes...)
+	case tea.KeyEnter:
+		m = m.submit()
+	}
+	return m, nil
+}
+
+// submit answers the pending question with the typed text, or with the highlighted choice
+// or the default when nothing was typed.
+func (m tuiModel) submit() tuiModel {
+	input := m.pending.input
+	text := strings.TrimSpace(string(m.input))
+	switch {
+	case text != "":
+	case len(input.Choices) > 0:
+		text = input.Choices[m.selected]
+	case input.Default != "":
+		text = input.Default
+	default:
+		return m
+	}
+
+	answer, ok := resolveChoice(input.Choices, text)
+	if !ok {
+		m.transcript = append(m.transcript, tuiEntry{role: tuiNotice, text: fmt.Sprintf("Pick a number from 1 to %d.", len(input.Choices))})
+		m.input = nil
+		return m
+	}
+	shown := answer
+	if m.pending.secret {
+		shown = RedactedAnswer
+	}
+	m.transcript = append(m.transcript, tuiEntry{role: tuiAnswer, text: shown})
+	m.reply(tuiReply{answer: Answer{Value: answer}})
+	return m
+}
+
+// reply resolves the pending question. The reply channel is buffered, so this never blocks.
+func (m *tuiModel) reply(reply tuiReply) {
+	if m.pending == nil {
+		return
+	}
+	m.pending.reply <- reply
+	m.pending, m.input = nil, nil
+}
+
+func (m tuiModel) View() string {
+	var footer []string
+	if m.pending == nil {
+		footer = append(footer, "waiting for the model…")
+	} else {
+		for i, choice := range m.pending.input.Choices {
+			marker := " "
+			if i == m.selected {
+				marker = "›"
+			}
+			footer = append(footer, fmt.Sprintf("%s %d) %s", marker, i+1, choice))
+		}
+		typed := string(m.input)
+		if m.pending.secret {
+			typed = strings.Repeat("•", len(m.input))
+		}
+		footer = append(footer, "> "+typed+"█", tuiHint)
+	}
+
+	var lines []string
+	for _, entry := range m.transcript {
+		switch entry.role {
+		case tuiQuestion:
+			lines = append(lines, "? "+entry.text)
+		case tuiAnswer:
+			lines = append(lines, "  "+entry.text)
+		case tuiNotice:
+			lines = append(lines, "· "+entry.text)
+		}
+	}
+	// the transcript scrolls so that the footer always fits on the screen
+	if m.height > 0 {
+		if room := max(m.height-len(footer)-1, 0); len(lines) > room {
+			lines = lines[len(lines)-room:]
+		}
+	}
+	return strings.Join(lines, "\n") + "\n\n" + strings.Join(footer, "\n")
+}
+
+// TUIInteractor asks questions in a full-screen terminal UI showing the conversation as a chat-style
+// transcript. It is only available in builds with the tui tag.
+type TUIInteractor struct {
+	program *tea.Program
+	// done is closed once the program exits.
+	done chan struct{}
+}
+
+// NewTUIInteractor starts the TUI on the terminal behind in and out.
+// Use NewTUIOrTerminal to fall back to TerminalReader when they aren't a capable terminal.
+func NewTUIInteractor(in io.Reader, out io.Writer) *TUIInteractor {
+	t := &TUIInteractor{
+		program: tea.NewProgram(tuiModel{}, tea.WithInput(in), tea.WithOutput(out), tea.WithAltScreen()),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(t.done)
+		_, _ = t.program.Run()
+	}()
+	return t
+}
+
+// NewTUIOrTerminal returns a TUIInteractor on stdin and stdout when they are a terminal
+// that supports it, and a TerminalReader configured with opts otherwise.
+func NewTUIOrTerminal(ctx context.Context, opts ...TerminalOption) Interactor {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) || os.Getenv("TERM") == "dumb" {
+		return NewTerminalReader(ctx, os.Stdin, opts...)
+	}
+	return NewTUIInteractor(os.Stdin, os.Stdout)
+}
+
+// newTUI is the --tui implementation of builds with the tui tag.
+func newTUI(ctx context.Context, opts ...TerminalOption) (Interactor, bool) {
+	return NewTUIOrTerminal(ctx, opts...), true
+}
+
+// Ask shows the question and waits for the answer. It fails with ErrConversationAborted
+// once the user quits the TUI.
+func (t *TUIInteractor) Ask(ctx context.Context, input QuestionInput) (Answer, error) {
+	// buffered so that the model never waits for Ask
+	reply := make(chan tuiReply, 1)
+	select {
+	case <-t.done:
+		return Answer{}, ErrConversationAborted
+	default:
+	}
+	t.program.Send(tuiQuestionMsg{input: input, reply: reply, secret: IsSecretInput(ctx)})
+
+	select {
+	case r := <-reply:
+		return r.answer, r.err
+	case <-ctx.Done():
+		t.program.Send(tuiCancelMsg{reply: reply})
+		return Answer{}, ctx.Err()
+	case <-t.done:
+		return Answer{}, ErrConversationAborted
+	}
+}
+
+// Notify adds the message to the transcript.
+func (t *TUIInteractor) Notify(_ context.Context, message string) error {
+	t.program.Send(tuiNoticeMsg(message))
+	return nil
+}
+
+// Close stops the TUI and restores the terminal.
+func (t *TUIInteractor) Close() error {
+	t.program.Quit()
+	<-t.done
+	return nil
+}
@@ -0,0 +1,166 @@
+//go:build tui
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// updateTUI feeds the messages to the model in order.
+func updateTUI(t *testing.T, m tuiModel, msgs ...tea.Msg) tuiModel {
+	t.Helper()
+	for _, msg := range msgs {
+		next, _ := m.Update(msg)
+		m = next.(tuiModel)
+	}
+	return m
+}
+
+// typeKeys turns the text into key messages.
+func typeKeys(text string) []tea.Msg {
+	var msgs []tea.Msg
+	for _, r := range text {
+		if r == ' ' {
+			msgs = append(msgs, tea.KeyMsg{Type: tea.KeySpace})
+			continue
+		}
+		msgs = append(msgs, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	return msgs
+}
+
+func TestTUIModel_Answer(t *testing.T) {
+	choices := []string{"Boy", "Girl", "Both"}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	tests := []struct {
+		name     string
+		input    QuestionInput
+		keys     []tea.Msg
+		expected tuiReply
+	}{
+		{
+			name:     "highlighted choice",
+			input:    QuestionInput{Question: "What gender?", Choices: choices},
+			keys:     []tea.Msg{tea.KeyMsg{Type: tea.KeyDown}, tea.KeyMsg{Type: tea.KeyDown}, enter},
+			expected: tuiReply{answer: Answer{Value: "Both"}},
+		},
+		{
+			name:     "up wraps around",
+			input:    QuestionInput{Question: "What gender?", Choices: choices},
+			keys:     []tea.Msg{tea.KeyMsg{Type: tea.KeyUp}, enter},
+			expected: tuiReply{answer: Answer{Value: "Both"}},
+		},
+		{
+			name:     "default choice is highlighted",
+			input:    QuestionInput{Question: "What gender?", Choices: choices, Default: "Girl"},
+			keys:     []tea.Msg{enter},
+			expected: tuiReply{answer: Answer{Value: "Girl"}},
+		},
+		{
+			name:     "typed number",
+			input:    QuestionInput{Question: "What gender?", Choices: choices},
+			keys:     append(typeKeys("7"), tea.KeyMsg{Type: tea.KeyBackspace}, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("2")}, enter),
+			expected: tuiReply{answer: Answer{Value: "Girl"}},
+		},
+		{
+			name:     "free text",
+			input:    QuestionInput{Question: "What ages?"},
+			keys:     append(typeKeys("8 and 11"), enter),
+			expected: tuiReply{answer: Answer{Value: "8 and 11"}},
+		},
+		{
+			name:     "skip",
+			input:    QuestionInput{Question: "What ages?"},
+			keys:     []tea.Msg{tea.KeyMsg{Type: tea.KeyEsc}},
+			expected: tuiReply{answer: Answer{Skipped: true}},
+		},
+		{
+			name:     "quit",
+			input:    QuestionInput{Question: "What ages?"},
+			keys:     []tea.Msg{tea.KeyMsg{Type: tea.KeyCtrlC}},
+			expected: tuiReply{err: ErrConversationAborted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := make(chan tuiReply, 1)
+			m := updateTUI(t, tuiModel{}, tuiQuestionMsg{input: tt.input, reply: reply})
+
+			m = updateTUI(t, m, tt.keys...)
+
+			require.Len(t, reply, 1)
+			assert.Equal(t, tt.expected, <-reply)
+			assert.Nil(t, m.pending)
+		})
+	}
+}
+
+func TestTUIModel_InvalidNumber(t *testing.T) {
+	reply := make(chan tuiReply, 1)
+	m := updateTUI(t, tuiModel{}, tuiQuestionMsg{input: QuestionInput{Question: "What gender?", Choices: []string{"Boy", "Girl"}}, reply: reply})
+
+	m = updateTUI(t, m, append(typeKeys("5"), tea.KeyMsg{Type: tea.KeyEnter})...)
+
+	assert.Empty(t, reply, "the question stays pending")
+	assert.Contains(t, m.View(), "· Pick a number from 1 to 2.")
+}
+
+func TestTUIModel_Secret(t *testing.T) {
+	reply := make(chan tuiReply, 1)
+	m := updateTUI(t, tuiModel{}, tuiQuestionMsg{input: QuestionInput{Question: "Card number?"}, reply: reply, secret: true})
+
+	m = updateTUI(t, m, typeKeys("1234")...)
+	assert.Contains(t, m.View(), "> ••••█")
+	assert.NotContains(t, m.View(), "1234")
+
+	m = updateTUI(t, m, tea.KeyMsg{Type: tea.KeyEnter})
+	require.Len(t, reply, 1)
+	assert.Equal(t, tuiReply{answer: Answer{Value: "1234"}}, <-reply)
+	assert.Contains(t, m.View(), "  "+RedactedAnswer)
+	assert.NotContains(t, m.View(), "1234")
+}
+
+func TestTUIModel_View(t *testing.T) {
+	reply := make(chan tuiReply, 1)
+	m := updateTUI(t, tuiModel{},
+		tuiNoticeMsg("Looking for gifts"),
+		tuiQuestionMsg{input: QuestionInput{Question: "What gender?", Reason: "Gifts differ", Choices: []string{"Boy", "Girl"}}, reply: reply},
+		tea.KeyMsg{Type: tea.KeyDown},
+	)
+	m = updateTUI(t, m, typeKeys("Bo")...)
+
+	assert.Equal(t, strings.Join([]string{
+		"· Looking for gifts",
+		"? What gender?",
+		"· Gifts differ",
+		"",
+		"  1) Boy",
+		"› 2) Girl",
+		"> Bo█",
+		tuiHint,
+	}, "\n"), m.View())
+
+	// on a short screen the transcript scrolls to keep the footer visible
+	m = updateTUI(t, m, tea.WindowSizeMsg{Width: 80, Height: 6})
+	assert.True(t, strings.HasPrefix(m.View(), "· Gifts differ\n\n"), m.View())
+
+	m = updateTUI(t, m, tuiCancelMsg{reply: reply})
+	assert.True(t, strings.HasSuffix(m.View(), "· (question withdrawn)\n\nwaiting for the model…"), m.View())
+}
+
+func TestTUIInteractor_Closed(t *testing.T) {
+	tui := &TUIInteractor{done: make(chan struct{})}
+	close(tui.done)
+
+	_, err := tui.Ask(context.Background(), QuestionInput{Question: "What gender?"})
+
+	assert.ErrorIs(t, err, ErrConversationAborted)
+}
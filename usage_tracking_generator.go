@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/firebase/genkit/go/ai"
+)
+
+// TokenUsage counts the model calls and their tokens.
+type TokenUsage struct {
+	Calls        int64 `json:"calls"`
+	InputTokens  int64 `json:"inputTokens"`
+	OutputTokens int64 `json:"outputTokens"`
+	TotalTokens  int64 `json:"totalTokens"`
+}
+
+// usageCounter adds up usage from concurrent calls.
+type usageCounter struct {
+	calls, input, output, total atomic.Int64
+}
+
+// add counts a call with its usage, which is nil when the model doesn't report it. A total left out by
+// the model is the sum of the input and output tokens.
+func (c *usageCounter) add(usage *ai.GenerationUsage) {
+	c.calls.Add(1)
+	if usage == nil {
+		return
+	}
+	c.input.Add(int64(usage.InputTokens))
+	c.output.Add(int64(usage.OutputTokens))
+	total := usage.TotalTokens
+	if total == 0 {
+		total = usage.InputTokens + usage.OutputTokens
+	}
+	c.total.Add(int64(total))
+}
+
+// record counts the usage of a call, whatever the model.
+func (c *usageCounter) record(_ string, usage *ai.GenerationUsage) {
+	c.add(usage)
+}
+
+func (c *usageCounter) snapshot() TokenUsage {
+	return TokenUsage{
+		Calls:        c.calls.Load(),
+		InputTokens:  c.input.Load(),
+		OutputTokens: c.output.Load(),
+		TotalTokens:  c.total.Load(),
+	}
+}
+
+// UsageScope counts the usage of the model calls made with a context returned by WithUsageScope,
+// such as the calls of a single run, when they go through a UsageTrackingGenerator.
+type UsageScope struct {
+	counter usageCounter
+	// parent is the scope of the context the scope was made from, which counts the calls too.
+	parent *UsageScope
+}
+
+type usageScopeKey struct{}
+
+// WithUsageScope returns a context whose model calls are counted by the returned scope, and by the
+// scopes of ctx.
+func WithUsageScope(ctx context.Context) (context.Context, *UsageScope) {
+	parent, _ := ctx.Value(usageScopeKey{}).(*UsageScope)
+	scope := &UsageScope{parent: parent}
+	return context.WithValue(ctx, usageScopeKey{}, scope), scope
+}
+
+// Snapshot returns the usage counted so far.
+func (s *UsageScope) Snapshot() TokenUsage {
+	return s.counter.snapshot()
+}
+
+// usageRecorder is called with the usage of a model call and the name of the model called, which is
+// empty when it isn't known.
+type usageRecorder func(model string, usage *ai.GenerationUsage)
+
+type usageRecorderKey struct{}
+
+// withUsageRecorder returns a context asking generators that make model calls on their own, such as
+// GenkitGenerator.GenerateJSON, to report the usage of each call to record, and to the recorders of ctx.
+func withUsageRecorder(ctx context.Context, record usageRecorder) context.Context {
+	if parent, ok := ctx.Value(usageRecorderKey{}).(usageRecorder); ok {
+		own := record
+		record = func(model string, usage *ai.GenerationUsage) {
+			own(model, usage)
+			parent(model, usage)
+		}
+	}
+	return context.WithValue(ctx, usageRecorderKey{}, record)
+}
+
+// recordUsage reports the usage of a call to the model to the recorders of ctx, if any.
+func recordUsage(ctx context.Context, model string, usage *ai.GenerationUsage) {
+	if record, ok := ctx.Value(usageRecorderKey{}).(usageRecorder); ok {
+		record(model, usage)
+	}
+}
+
+// UsageTrackingGenerator is a Generator counting the tokens of the model calls of another one, in
+// total and by UsageScope. A single UsageTrackingGenerator is safe to share between concurrent sessions.
+type UsageTrackingGenerator struct {
+	inner   Generator
+	counter usageCounter
+}
+
+// NewUsageTrackingGenerator returns a Generator calling inner and counting the usage the model reports.
+// The calls of GenerateBool and GenerateJSON are counted when inner reports them, as GenkitGenerator
+// does.
+func NewUsageTrackingGenerator(inner Generator) *UsageTrackingGenerator {
+	return &UsageTrackingGenerator{inner: inner}
+}
+
+// Generate calls the inner Generate and counts the usage of the response.
+func (u *UsageTrackingGenerator) Generate(ctx context.Context, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	resp, err := u.inner.Generate(ctx, opts...)
+	if resp != nil {
+		u.record(ctx, resp.Usage)
+	}
+	return resp, err
+}
+
+// GenerateStream calls the inner GenerateStream and counts the usage of the response.
+func (u *UsageTrackingGenerator) GenerateStream(ctx context.Context, cb func(chunk *ai.ModelResponseChunk) error, opts ...ai.GenerateOption) (*ai.ModelResponse, error) {
+	resp, err := u.inner.GenerateStream(ctx, cb, opts...)
+	if resp != nil {
+		u.record(ctx, resp.Usage)
+	}
+	return resp, err
+}
+
+// GenerateBool calls the inner GenerateBool, counting the usage it reports.
+func (u *UsageTrackingGenerator) GenerateBool(ctx context.Context, prompt string, history []*ai.Message) (bool, error) {
+	return u.inner.GenerateBool(u.recording(ctx), prompt, history)
+}
+
+// GenerateChecklist calls the inner GenerateChecklist, counting the usage it reports.
+func (u *UsageTrackingGenerator) GenerateChecklist(ctx context.Context, questions []string, history []*ai.Message) (map[string]bool, error) {
+	return u.inner.GenerateChecklist(u.recording(ctx), questions, history)
+}
+
+// GenerateJSON calls the inner GenerateJSON, counting the usage it reports.
+func (u *UsageTrackingGenerator) GenerateJSON(ctx context.Context, system string, history []*ai.Message, out any) error {
+	return u.inner.GenerateJSON(u.recording(ctx), system, history, out)
+}
+
+// LookupTool looks up the tool with the inner generator.
+func (u *UsageTrackingGenerator) LookupTool(name string) ai.Tool {
+	return u.inner.LookupTool(name)
+}
+
+// CountTokens counts the tokens with the inner generator; counting tokens isn't a model call adding
+// to the usage.
+func (u *UsageTrackingGenerator) CountTokens(ctx context.Context, messages []*ai.Message) (TokenCount, error) {
+	return u.inner.CountTokens(ctx, messages)
+}
+
+// Embed embeds the text with the inner generator; an embedding isn't a model call adding to the usage.
+func (u *UsageTrackingGenerator) Embed(ctx context.Context, text string) ([]float32, error) {
+	return u.inner.Embed(ctx, text)
+}
+
+// Ping pings the inner generator; a ping isn't a model call adding to the usage.
+func (u *UsageTrackingGenerator) Ping(ctx context.Context) error {
+	return ping(ctx, u.inner)
+}
+
+// Snapshot returns the usage of all the calls counted so far.
+func (u *UsageTrackingGenerator) Snapshot() TokenUsage {
+	return u.counter.snapshot()
+}
+
+// recording returns ctx with a recorder counting the usage reported by the inner generator.
+func (u *UsageTrackingGenerator) recording(ctx context.Context) context.Context {
+	return withUsageRecorder(ctx, func(_ string, usage *ai.GenerationUsage) {
+		u.record(ctx, usage)
+	})
+}
+
+// record counts the usage in total and in the scopes of ctx.
+func (u *UsageTrackingGenerator) record(ctx context.Context, usage *ai.GenerationUsage) {
+	u.counter.add(usage)
+	scope, _ := ctx.Value(usageScopeKey{}).(*UsageScope)
+	for ; scope != nil; scope = scope.parent {
+		scope.counter.add(usage)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withUsage returns the response carrying the usage.
+func withUsage(resp *ai.ModelResponse, input, output, total int) *ai.ModelResponse {
+	resp.Usage = &ai.GenerationUsage{InputTokens: input, OutputTokens: output, TotalTokens: total}
+	return resp
+}
+
+// constantGenerator returns a GenkitGenerator answering every call with a copy of the response; it is
+// safe for concurrent calls.
+func constantGenerator(text string, input, output int) *GenkitGenerator {
+	return &GenkitGenerator{generate: func(context.Context, ...ai.GenerateOption) (*ai.ModelResponse, error) {
+		return withUsage(createTextResponse(text, "stop"), input, output, input+output), nil
+	}}
+}
+
+func TestUsageTrackingGenerator_Runs(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			withUsage(createTextResponse("A LEGO set", "stop"), 100, 20, 120),
+			withUsage(createTextResponse("A kite", "stop"), 80, 10, 0),
+			createTextResponse("A puzzle", "stop"),
+		},
+		nil,
+	)
+	tracker := NewUsageTrackingGenerator(mockGen)
+
+	var first, second TokenUsage
+	_, err := RunAgent(context.Background(), &Options{generator: tracker, userPrompt: "Suggest a gift.", usage: func(usage TokenUsage) { first = usage }})
+	require.NoError(t, err)
+	_, err = RunAgent(context.Background(), &Options{generator: tracker, userPrompt: "Suggest another gift.", usage: func(usage TokenUsage) { second = usage }})
+	require.NoError(t, err)
+	_, err = tracker.Generate(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, TokenUsage{Calls: 1, InputTokens: 100, OutputTokens: 20, TotalTokens: 120}, first)
+	assert.Equal(t, TokenUsage{Calls: 1, InputTokens: 80, OutputTokens: 10, TotalTokens: 90}, second, "a missing total is the sum of input and output")
+	assert.Equal(t, TokenUsage{Calls: 3, InputTokens: 180, OutputTokens: 30, TotalTokens: 210}, tracker.Snapshot(), "calls without usage are counted too")
+}
+
+func TestUsageTrackingGenerator_OtherCalls(t *testing.T) {
+	tracker := NewUsageTrackingGenerator(constantGenerator(trueAnswer, 50, 1))
+	ctx, scope := WithUsageScope(context.Background())
+
+	finished, err := tracker.GenerateBool(ctx, "Is the conversation finished?", nil)
+	require.NoError(t, err)
+	assert.True(t, finished)
+	var done scoredBool
+	require.NoError(t, tracker.GenerateJSON(ctx, "Is it done?", nil, &done))
+	_, err = tracker.GenerateStream(ctx, func(*ai.ModelResponseChunk) error { return nil })
+	require.NoError(t, err)
+
+	assert.Equal(t, TokenUsage{Calls: 3, InputTokens: 150, OutputTokens: 3, TotalTokens: 153}, scope.Snapshot())
+	assert.Equal(t, scope.Snapshot(), tracker.Snapshot())
+}
+
+func TestUsageTrackingGenerator_MismatchCounted(t *testing.T) {
+	tracker := NewUsageTrackingGenerator(constantGenerator("maybe", 50, 1))
+
+	_, err := tracker.GenerateBool(context.Background(), "Is the conversation finished?", nil)
+
+	require.Error(t, err)
+	assert.Equal(t, TokenUsage{Calls: 2, InputTokens: 100, OutputTokens: 2, TotalTokens: 102}, tracker.Snapshot(), "both attempts are counted")
+}
+
+func TestUsageTrackingGenerator_NestedScopes(t *testing.T) {
+	tracker := NewUsageTrackingGenerator(constantGenerator("A kite", 10, 5))
+	sessionCtx, session := WithUsageScope(context.Background())
+	runCtx, run := WithUsageScope(sessionCtx)
+
+	_, err := tracker.Generate(runCtx)
+	require.NoError(t, err)
+	_, err = tracker.Generate(sessionCtx)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), run.Snapshot().Calls)
+	assert.Equal(t, int64(2), session.Snapshot().Calls)
+}
+
+func TestUsageTrackingGenerator_Concurrent(t *testing.T) {
+	tracker := NewUsageTrackingGenerator(constantGenerator("A kite", 10, 5))
+	scopes := make([]*UsageScope, 20)
+
+	var wg sync.WaitGroup
+	for i := range scopes {
+		ctx, scope := WithUsageScope(context.Background())
+		scopes[i] = scope
+		wg.Go(func() {
+			for range 3 {
+				_, err := tracker.Generate(ctx)
+				assert.NoError(t, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	for _, scope := range scopes {
+		assert.Equal(t, TokenUsage{Calls: 3, InputTokens: 30, OutputTokens: 15, TotalTokens: 45}, scope.Snapshot())
+	}
+	assert.Equal(t, TokenUsage{Calls: 60, InputTokens: 600, OutputTokens: 300, TotalTokens: 900}, tracker.Snapshot())
+}
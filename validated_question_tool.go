@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+)
+
+// Defaults of the tool defined by DefineValidatedQuestionTool and its handler.
+const (
+	defaultValidatedQuestionToolName        = "askValidatedQuestion"
+	defaultValidatedQuestionToolDescription = "use this to ask the user a question whose answer must have a format, such as an email address or a postcode; the answer is checked against the pattern before you get it"
+	defaultValidationAttempts               = 3
+)
+
+// ValidatedQuestionInput contains a free-text question whose answer must match a pattern.
+type ValidatedQuestionInput struct {
+	Question   string `json:"question" jsonschema:"description=a clarifying question"`
+	Reason     string `json:"reason,omitempty" jsonschema:"description=a short explanation of why the question is asked"`
+	Pattern    string `json:"pattern,omitempty" jsonschema:"description=a regular expression (RE2 syntax) the whole answer must match"`
+	FormatHint string `json:"formatHint,omitempty" jsonschema:"description=the expected format in words shown to the user, such as 'a number between 1 and 10'"`
+}
+
+// DefineValidatedQuestionTool defines the validated question tool, named "askValidatedQuestion" by
+// default, in the Genkit instance and returns it. Its interrupts are answered by the handler returned
+// by NewValidatedQuestionHandler, which must be registered for the tool name with RegisterToolHandler.
+func DefineValidatedQuestionTool(g *genkit.Genkit, opts ...ToolOption) ai.Tool {
+	config := newToolConfig(defaultValidatedQuestionToolName, defaultValidatedQuestionToolDescription, opts)
+
+	return DefineInterruptTool[ValidatedQuestionInput, string](g, config.name, config.description, WithInterruptMetadataKey("question"))
+}
+
+// validatedQuestionHandler configures the handler returned by NewValidatedQuestionHandler.
+type validatedQuestionHandler struct {
+	attempts int
+}
+
+// ValidatedQuestionOption configures the handler of the validated question tool.
+type ValidatedQuestionOption func(*validatedQuestionHandler)
+
+// WithValidationAttempts sets how many answers are checked against the pattern before the last one is
+// accepted as it is. It defaults to 3.
+func WithValidationAttempts(attempts int) ValidatedQuestionOption {
+	return func(h *validatedQuestionHandler) {
+		h.attempts = max(attempts, 1)
+	}
+}
+
+// NewValidatedQuestionHandler returns the ToolHandler of the validated question tool. Answers that
+// don't match the pattern are rejected with the format hint and asked again; once the attempts are used
+// up the last answer is accepted. The tool response carries a "validated" metadata flag telling whether
+// the answer matched. A pattern that doesn't compile is ignored with a warning and reported in the
+// "patternError" metadata, so that the model can fix it.
+func NewValidatedQuestionHandler(opts ...ValidatedQuestionOption) ToolHandler {
+	h := &validatedQuestionHandler{attempts: defaultValidationAttempts}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h.handle
+}
+
+// validatedAnswer is an answer together with whether it matched the pattern.
+type validatedAnswer struct {
+	text  string
+	valid bool
+}
+
+func (h *validatedQuestionHandler) handle(ctx context.Context, interactor Interactor, input map[string]any) (ToolResult, error) {
+	var question ValidatedQuestionInput
+	if err := decodeToolInput(input, &question); err != nil {
+		return ToolResult{}, err
+	}
+	if question.Question == "" {
+		return ToolResult{}, errors.New("validated question tool called without a question")
+	}
+	ask := QuestionInput{Question: question.Question, Reason: question.Reason}
+	if question.FormatHint != "" {
+		ask.Question += " (" + question.FormatHint + ")"
+	}
+
+	var pattern *regexp.Regexp
+	metadata := map[string]any{"validated": false}
+	if question.Pattern != "" {
+		var err error
+		// the whole answer must match, not just a part of it
+		if pattern, err = regexp.Compile(`^(?:` + question.Pattern + `)$`); err != nil {
+			log.Printf("validated question: ignoring invalid pattern %q: %v", question.Pattern, err)
+			metadata["patternError"] = err.Error()
+		}
+	}
+	if pattern == nil {
+		reply, err := interactor.Ask(ctx, ask)
+		if err != nil {
+			return ToolResult{}, err
+		}
+		return ToolResult{Output: answerText(reply), Metadata: metadata}, nil
+	}
+
+	// the parser runs in the interaction and again here, so it keeps accepting once the attempts are used up
+	failures := 0
+	parse := func(answer string) (any, error) {
+		answer = strings.TrimSpace(answer)
+		if pattern.MatchString(answer) {
+			return validatedAnswer{text: answer, valid: true}, nil
+		}
+		failures++
+		if failures >= h.attempts {
+			return validatedAnswer{text: answer}, nil
+		}
+		if question.FormatHint != "" {
+			return nil, errors.New("expected " + question.FormatHint)
+		}
+		return nil, errors.New("expected an answer matching " + question.Pattern)
+	}
+	value, err := askParsedAttempts(ctx, interactor, ask, parse, h.attempts)
+	if err != nil {
+		return ToolResult{}, err
+	}
+	answer := value.(validatedAnswer)
+	metadata["validated"] = answer.valid
+	return ToolResult{Output: answer.text, Metadata: metadata}, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/firebase/genkit/go/genkit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatedQuestionHandler(t *testing.T) {
+	postcode := map[string]any{"question": "What is your postcode?", "pattern": `[0-9]{5}`, "formatHint": "five digits"}
+
+	tests := []struct {
+		name          string
+		input         map[string]any
+		answers       []Answer
+		opts          []ValidatedQuestionOption
+		expected      string
+		metadata      map[string]any
+		notifications []string
+	}{
+		{
+			name:     "match",
+			input:    postcode,
+			answers:  []Answer{{Value: " 10115 "}},
+			expected: "10115",
+			metadata: map[string]any{"validated": true},
+		},
+		{
+			name:          "retry then match",
+			input:         postcode,
+			answers:       []Answer{{Value: "10115 Berlin"}, {Value: "10115"}},
+			expected:      "10115",
+			metadata:      map[string]any{"validated": true},
+			notifications: []string{"Invalid answer: expected five digits"},
+		},
+		{
+			name:          "accepted as is after the attempts",
+			input:         postcode,
+			answers:       []Answer{{Value: "Berlin"}, {Value: "Mitte"}},
+			opts:          []ValidatedQuestionOption{WithValidationAttempts(2)},
+			expected:      "Mitte",
+			metadata:      map[string]any{"validated": false},
+			notifications: []string{"Invalid answer: expected five digits"},
+		},
+		{
+			name:          "pattern without a hint",
+			input:         map[string]any{"question": "How many?", "pattern": `[1-9]|10`},
+			answers:       []Answer{{Value: "11"}, {Value: "7"}},
+			expected:      "7",
+			metadata:      map[string]any{"validated": true},
+			notifications: []string{"Invalid answer: expected an answer matching [1-9]|10"},
+		},
+		{
+			name:     "bad pattern is ignored",
+			input:    map[string]any{"question": "What is your email?", "pattern": `[a-z+@`},
+			answers:  []Answer{{Value: "pat@example.com"}},
+			expected: "pat@example.com",
+			metadata: map[string]any{"validated": false, "patternError": "error parsing regexp: missing closing ]: `[a-z+@)$`"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interactor := &sequenceInteractor{answers: tt.answers}
+
+			result, err := NewValidatedQuestionHandler(tt.opts...)(context.Background(), interactor, tt.input)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Output)
+			assert.Equal(t, tt.metadata, result.Metadata)
+			assert.Equal(t, tt.notifications, interactor.notifications)
+		})
+	}
+}
+
+func TestValidatedQuestionHandler_Terminal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createInterruptPart("askValidatedQuestion", map[string]any{
+				"question":   "What is your email?",
+				"pattern":    `[^@\s]+@[^@\s]+\.[a-z]+`,
+				"formatHint": "an email address",
+			})),
+			createTextResponse("I'll email the list to you.", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion"), "askValidatedQuestion": createMockTool("askValidatedQuestion")},
+	)
+	var out bytes.Buffer
+	terminal := NewTerminalReader(ctx, strings.NewReader("pat at example\npat@example.com\n"), WithAnswerTimeout(time.Second), WithOutput(&out))
+	handler := &InterruptionHandler{generator: mockGen, Interactor: terminal}
+	handler.RegisterToolHandler("askValidatedQuestion", NewValidatedQuestionHandler())
+
+	_, err := RunAgent(ctx, &Options{generator: mockGen, responseHandler: handler})
+
+	require.NoError(t, err)
+	parts := capturedToolResponses(mockGen.capturedCalls[1].Options)
+	require.Len(t, parts, 1)
+	assert.Equal(t, "pat@example.com", parts[0].ToolResponse.Output)
+	assert.Equal(t, map[string]any{"interruptResponse": map[string]any{"validated": true}}, parts[0].Metadata)
+	assert.Contains(t, out.String(), "What is your email? (an email address)\n")
+	assert.Contains(t, out.String(), "Invalid answer: expected an email address\n")
+}
+
+func TestDefineValidatedQuestionTool(t *testing.T) {
+	g := genkit.Init(context.Background())
+
+	tool := DefineValidatedQuestionTool(g)
+	_, err := tool.RunRaw(context.Background(), map[string]any{"question": "Postcode?", "pattern": "[0-9]{5}"})
+
+	assert.Equal(t, "askValidatedQuestion", tool.Name())
+	interrupted, metadata := ai.IsToolInterruptError(err)
+	require.True(t, interrupted, "unexpected error: %v", err)
+	assert.Equal(t, ValidatedQuestionInput{Question: "Postcode?", Pattern: "[0-9]{5}"}, metadata["question"])
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//go:embed web/index.html
+var webUIPage []byte
+
+// RunState is the stage a run served by a WebUI is in.
+type RunState string
+
+// Run states reported by the WebUI status endpoint.
+const (
+	RunStateWaitingForModel RunState = "waiting-for-model"
+	RunStateWaitingForUser  RunState = "waiting-for-user"
+	RunStateFinished        RunState = "finished"
+	RunStateFailed          RunState = "failed"
+)
+
+// WebStatus is the run status served by GET /status.
+type WebStatus struct {
+	State         RunState `json:"state"`
+	FinalResponse string   `json:"finalResponse,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// WebUI serves a page for answering the agent's questions from a browser, for demos.
+// The page shows the pending question with its choices as buttons and the final answer once the run
+// is done. It is backed by an HTTPInteractor, so every open tab sees the same question and the first
+// answer wins.
+type WebUI struct {
+	interactor *HTTPInteractor
+
+	mu     sync.Mutex
+	status WebStatus
+	// asking counts the questions waiting for an answer.
+	asking int
+}
+
+// NewWebUI creates a WebUI whose questions are configured by the HTTPInteractor options.
+func NewWebUI(opts ...HTTPOption) *WebUI {
+	return &WebUI{
+		interactor: NewHTTPInteractor(opts...),
+		status:     WebStatus{State: RunStateWaitingForModel},
+	}
+}
+
+// Handler returns the HTTP handler serving the page, GET /status and the HTTPInteractor endpoints.
+func (w *WebUI) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", w.servePage)
+	mux.HandleFunc("GET /status", w.serveStatus)
+	mux.Handle("/questions/", w.interactor.Handler())
+	return mux
+}
+
+// ListenAndServe serves the Handler on the address until the context is done.
+func (w *WebUI) ListenAndServe(ctx context.Context, addr string) error {
+	server := &http.Server{Addr: addr, Handler: w.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Interact shows the question on the page and waits for a browser to answer it.
+// It implements UserInteractionFunc.
+func (w *WebUI) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	w.mu.Lock()
+	w.asking++
+	w.status.State = RunStateWaitingForUser
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.asking--
+		if w.asking == 0 {
+			w.status.State = RunStateWaitingForModel
+		}
+		w.mu.Unlock()
+	}()
+
+	return w.interactor.Interact(ctx, input)
+}
+
+// Finish records the outcome of the run so that the page can show it.
+func (w *WebUI) Finish(finalResponse string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err != nil {
+		w.status = WebStatus{State: RunStateFailed, Error: err.Error()}
+		return
+	}
+	w.status = WebStatus{State: RunStateFinished, FinalResponse: finalResponse}
+}
+
+// Status returns the current run status.
+func (w *WebUI) Status() WebStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *WebUI) servePage(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = rw.Write(webUIPage)
+}
+
+func (w *WebUI) serveStatus(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(w.Status())
+}
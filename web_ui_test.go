@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fetchStatus returns the run status served by the web UI.
+func fetchStatus(t *testing.T, server *httptest.Server) WebStatus {
+	t.Helper()
+	resp, err := http.Get(server.URL + "/status")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	var status WebStatus
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	return status
+}
+
+func TestWebUI_RunAgent(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+			),
+			createTextResponse("Based on both genders...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	webUI := NewWebUI()
+	server := httptest.NewServer(webUI.Handler())
+	defer server.Close()
+	assert.Equal(t, RunStateWaitingForModel, fetchStatus(t, server).State)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result, err := RunAgent(context.Background(), &Options{
+			generator: mockGen,
+			responseHandler: &InterruptionHandler{
+				generator:       mockGen,
+				UserInteraction: webUI.Interact,
+			},
+		})
+		webUI.Finish(result, err)
+	}()
+
+	// two tabs see the same question and the first answer wins
+	first, second := fetchQuestion(t, server), fetchQuestion(t, server)
+	assert.Equal(t, first, second)
+	assert.Equal(t, []string{"Boy", "Girl", "Both"}, first.Choices)
+	assert.Equal(t, RunStateWaitingForUser, fetchStatus(t, server).State)
+	assert.Equal(t, http.StatusNoContent, postAnswer(t, server, first.ID, "Both"))
+	assert.Equal(t, http.StatusNotFound, postAnswer(t, server, second.ID, "Boy"))
+	<-done
+
+	assert.Equal(t, WebStatus{State: RunStateFinished, FinalResponse: "Based on both genders..."}, fetchStatus(t, server))
+}
+
+func TestWebUI_Page(t *testing.T) {
+	server := httptest.NewServer(NewWebUI().Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/")
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), "questions/current")
+	assert.Contains(t, string(body), "question.media", "the images of the question are shown")
+
+	resp, err = http.Get(server.URL + "/missing")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestWebUI_Finish(t *testing.T) {
+	webUI := NewWebUI()
+
+	webUI.Finish("", errors.New("model unavailable"))
+
+	assert.Equal(t, WebStatus{State: RunStateFailed, Error: "model unavailable"}, webUI.Status())
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// webhookSignatureHeader carries the HMAC-SHA256 of the body, hex-encoded with a "sha256=" prefix.
+const webhookSignatureHeader = "X-Webhook-Signature"
+
+// Defaults for the delivery of questions.
+const (
+	defaultWebhookAttempts = 3
+	defaultWebhookBackoff  = 500 * time.Millisecond
+)
+
+// WebhookQuestion is the body of the outbound webhook announcing a question.
+type WebhookQuestion struct {
+	// Token is the one-time token the answer callback must carry.
+	Token string `json:"token"`
+	// CallbackURL is where the answer is posted, when configured.
+	CallbackURL string `json:"callbackUrl,omitempty"`
+	QuestionInput
+}
+
+// UnmarshalJSON decodes the question with its token and callback URL.
+func (q *WebhookQuestion) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		Token       string `json:"token"`
+		CallbackURL string `json:"callbackUrl"`
+	}
+	if err := unmarshalEmbeddedQuestion(data, &q.QuestionInput, &fields); err != nil {
+		return err
+	}
+	q.Token, q.CallbackURL = fields.Token, fields.CallbackURL
+	return nil
+}
+
+// WebhookAnswer is the body of the answer callback.
+type WebhookAnswer struct {
+	Token  string `json:"token"`
+	Answer string `json:"answer"`
+}
+
+// WebhookInteractor hands questions to a workflow engine: every question is POSTed to a URL together with
+// a one-time token, and the engine answers it later by POSTing the token and the answer to CallbackHandler.
+// Both requests are signed with an HMAC-SHA256 of the body in the X-Webhook-Signature header. Interact
+// blocks until the callback arrives, or with WithWebhookStore suspends the run until it does.
+type WebhookInteractor struct {
+	url         string
+	secret      string
+	callbackURL string
+	attempts    int
+	backoff     time.Duration
+	timeout     time.Duration
+	httpClient  *http.Client
+	// async is set by WithWebhookStore; answers then go to its store instead of pending.
+	async *AsyncInteractor
+
+	// pending holds the questions waiting for their callbacks by their tokens.
+	pending pendingAnswers[string]
+}
+
+// WebhookOption configures a WebhookInteractor.
+type WebhookOption func(*WebhookInteractor)
+
+// WithWebhookCallbackURL sets the callback URL sent along with every question.
+func WithWebhookCallbackURL(callbackURL string) WebhookOption {
+	return func(w *WebhookInteractor) {
+		w.callbackURL = callbackURL
+	}
+}
+
+// WithWebhookRetries sets how many times a question is delivered before giving up and the delay
+// before the first retry, which doubles with every further retry.
+func WithWebhookRetries(attempts int, backoff time.Duration) WebhookOption {
+	return func(w *WebhookInteractor) {
+		w.attempts = max(attempts, 1)
+		w.backoff = backoff
+	}
+}
+
+// WithWebhookAnswerTimeout sets how long Interact waits for the callback.
+// Zero, the default, waits until the context is done. It doesn't apply with WithWebhookStore.
+func WithWebhookAnswerTimeout(timeout time.Duration) WebhookOption {
+	return func(w *WebhookInteractor) {
+		w.timeout = timeout
+	}
+}
+
+// WithWebhookHTTPClient sets the client delivering the questions instead of http.DefaultClient.
+func WithWebhookHTTPClient(client *http.Client) WebhookOption {
+	return func(w *WebhookInteractor) {
+		w.httpClient = client
+	}
+}
+
+// WithWebhookStore keeps the questions in the store and makes Interact fail with ErrAnswerPending instead
+// of blocking, so that the run is suspended until the callback arrives, possibly hours later.
+// The run is continued with InterruptionHandler.Resume as with an AsyncInteractor.
+func WithWebhookStore(store AnswerStore) WebhookOption {
+	return func(w *WebhookInteractor) {
+		w.async = NewAsyncInteractor(store)
+	}
+}
+
+// NewWebhookInteractor creates a WebhookInteractor posting questions to url and signing with secret.
+// Serve its CallbackHandler at the callback URL.
+func NewWebhookInteractor(url, secret string, opts ...WebhookOption) *WebhookInteractor {
+	w := &WebhookInteractor{
+		url:        url,
+		secret:     secret,
+		attempts:   defaultWebhookAttempts,
+		backoff:    defaultWebhookBackoff,
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Interact delivers the question and blocks until its callback arrives, the timeout expires or the context
+// is done. With WithWebhookStore it returns ErrAnswerPending instead, and the answer once the run is resumed.
+// It implements UserInteractionFunc.
+func (w *WebhookInteractor) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	if w.async != nil {
+		return w.interactAsync(ctx, input)
+	}
+
+	token, err := newCorrelationID()
+	if err != nil {
+		return "", err
+	}
+	pending := w.pending.add(token, input)
+	defer w.pending.remove(token)
+
+	if err := w.deliver(ctx, token, input); err != nil {
+		return "", err
+	}
+	return pending.wait(ctx, w.timeout)
+}
+
+// interactAsync stores and delivers a new question, or looks up the answer to one asked before the run was suspended.
+func (w *WebhookInteractor) interactAsync(ctx context.Context, input QuestionInput) (string, error) {
+	if _, ok := CorrelationIDFromContext(ctx); ok {
+		return w.async.Ask(ctx, input)
+	}
+
+	_, err := w.async.Ask(ctx, input)
+	var pending *ErrAnswerPending
+	if !errors.As(err, &pending) {
+		return "", err
+	}
+	// the correlation ID of the stored question doubles as the callback token
+	if err := w.deliver(ctx, pending.ID, input); err != nil {
+		return "", err
+	}
+	return "", pending
+}
+
+// deliver posts the question, retrying with exponential backoff on network errors and on 5xx and 429
+// responses. Other 4xx responses are permanent failures.
+func (w *WebhookInteractor) deliver(ctx context.Context, token string, input QuestionInput) error {
+	body, err := json.Marshal(WebhookQuestion{Token: token, CallbackURL: w.callbackURL, QuestionInput: input})
+	if err != nil {
+		return fmt.Errorf("failed to marshal the question: %w", err)
+	}
+
+	backoff := w.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.attempts {
+			return fmt.Errorf("failed to deliver the question after %d attempt(s): %w", attempt, err)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// post sends the signed body once and reports whether a failure is worth retrying.
+func (w *WebhookInteractor) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookSignatureHeader, signWebhook(w.secret, body))
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxRequestBodySize))
+
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook responded with HTTP %d", resp.StatusCode)
+}
+
+// CallbackHandler returns the HTTP handler receiving the answer callbacks. It responds with 401 for
+// an invalid signature, 404 for a token without a pending question, including a token that was already
+// used, and 204 once the answer is accepted. An empty answer selects the question's default.
+func (w *WebhookInteractor) CallbackHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
+		if err != nil {
+			http.Error(rw, "failed to read the body", http.StatusBadRequest)
+			return
+		}
+		if !hmac.Equal([]byte(signWebhook(w.secret, body)), []byte(r.Header.Get(webhookSignatureHeader))) {
+			http.Error(rw, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		var answer WebhookAnswer
+		if err := json.Unmarshal(body, &answer); err != nil {
+			http.Error(rw, "invalid answer: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if w.async != nil {
+			err = w.async.SubmitAnswer(r.Context(), answer.Token, answer.Answer)
+		} else {
+			err = w.resolve(answer)
+		}
+		switch {
+		case errors.Is(err, ErrUnknownQuestion), errors.Is(err, ErrAlreadyAnswered):
+			http.Error(rw, "no pending question for the token", http.StatusNotFound)
+		case err != nil:
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+		default:
+			rw.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+// resolve delivers the answer to the blocked Interact call waiting for its token.
+func (w *WebhookInteractor) resolve(answer WebhookAnswer) error {
+	// the token is used up right away so that a second callback with it is rejected
+	found := false
+	answered := w.pending.answer(answer.Token, func(input QuestionInput) (string, bool) {
+		found = true
+		value := cmp.Or(strings.TrimSpace(answer.Answer), input.Default)
+		return value, value != ""
+	})
+	switch {
+	case !found:
+		return ErrUnknownQuestion
+	case !answered:
+		return errors.New("answer is empty")
+	}
+	return nil
+}
+
+// signWebhook returns the signature header value for the body.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
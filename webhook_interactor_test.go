@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWorkflowEngine receives question webhooks, failing the first deliveries with the given statuses.
+type fakeWorkflowEngine struct {
+	t        *testing.T
+	secret   string
+	failures []int
+
+	mu        sync.Mutex
+	attempts  int
+	questions chan WebhookQuestion
+}
+
+func newFakeWorkflowEngine(t *testing.T, secret string, failures ...int) *fakeWorkflowEngine {
+	return &fakeWorkflowEngine{t: t, secret: secret, failures: failures, questions: make(chan WebhookQuestion, 10)}
+}
+
+func (f *fakeWorkflowEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.attempts++
+	attempt := f.attempts
+	f.mu.Unlock()
+	if attempt <= len(f.failures) {
+		w.WriteHeader(f.failures[attempt-1])
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	assert.NoError(f.t, err)
+	assert.Equal(f.t, signWebhook(f.secret, body), r.Header.Get(webhookSignatureHeader))
+	var question WebhookQuestion
+	assert.NoError(f.t, json.Unmarshal(body, &question))
+	f.questions <- question
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// postCallback posts the answer to the callback signed with the secret and returns the response status.
+func postCallback(t *testing.T, callback *httptest.Server, secret, token, answer string) int {
+	t.Helper()
+	body, err := json.Marshal(WebhookAnswer{Token: token, Answer: answer})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, callback.URL, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set(webhookSignatureHeader, signWebhook(secret, body))
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestWebhookInteractor_RunAgent(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+			),
+			createTextResponse("Based on both genders...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	engine := newFakeWorkflowEngine(t, "s3cret", http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	engineServer := httptest.NewServer(engine)
+	defer engineServer.Close()
+	interactor := NewWebhookInteractor(engineServer.URL, "s3cret",
+		WithWebhookCallbackURL("https://agent.example.com/answers"),
+		WithWebhookRetries(3, time.Millisecond),
+		WithWebhookAnswerTimeout(time.Second),
+	)
+	callback := httptest.NewServer(interactor.CallbackHandler())
+	defer callback.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		question := <-engine.questions
+		assert.Equal(t, "https://agent.example.com/answers", question.CallbackURL)
+		assert.Equal(t, []string{"Boy", "Girl", "Both"}, question.Choices)
+		assert.Equal(t, http.StatusUnauthorized, postCallback(t, callback, "wrong", question.Token, "Boy"))
+		assert.Equal(t, http.StatusNotFound, postCallback(t, callback, "s3cret", "forged", "Boy"))
+		assert.Equal(t, http.StatusNoContent, postCallback(t, callback, "s3cret", question.Token, "Both"))
+		assert.Equal(t, http.StatusNotFound, postCallback(t, callback, "s3cret", question.Token, "Boy"), "the token is one-time")
+	}()
+
+	result, err := RunAgent(context.Background(), &Options{
+		generator: mockGen,
+		responseHandler: &InterruptionHandler{
+			generator:       mockGen,
+			UserInteraction: interactor.Interact,
+		},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Based on both genders...", result)
+	<-done
+	assert.Equal(t, 3, engine.attempts, "the question is delivered on the third attempt")
+}
+
+func TestWebhookInteractor_Delivery(t *testing.T) {
+	tests := []struct {
+		name             string
+		failures         []int
+		expectedAttempts int
+	}{
+		{name: "retries run out", failures: []int{500, 502, 503}, expectedAttempts: 3},
+		{name: "client error is permanent", failures: []int{400}, expectedAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newFakeWorkflowEngine(t, "s3cret", tt.failures...)
+			server := httptest.NewServer(engine)
+			defer server.Close()
+			interactor := NewWebhookInteractor(server.URL, "s3cret", WithWebhookRetries(3, time.Millisecond))
+
+			_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?"})
+
+			assert.ErrorContains(t, err, "failed to deliver the question")
+			assert.Equal(t, tt.expectedAttempts, engine.attempts)
+			assert.Zero(t, interactor.pending.len())
+		})
+	}
+}
+
+func TestWebhookInteractor_Callback(t *testing.T) {
+	interactor := NewWebhookInteractor("http://unused", "s3cret")
+	callback := httptest.NewServer(interactor.CallbackHandler())
+	defer callback.Close()
+
+	resp, err := http.Get(callback.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodPost, callback.URL, bytes.NewReader([]byte("{")))
+	require.NoError(t, err)
+	req.Header.Set(webhookSignatureHeader, signWebhook("s3cret", []byte("{")))
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestWebhookInteractor_SuspendAndResume(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(createToolRequestPart("askQuestion", "Approve the budget?", nil)),
+			createTextResponse("Based on the approved budget...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	engine := newFakeWorkflowEngine(t, "s3cret")
+	engineServer := httptest.NewServer(engine)
+	defer engineServer.Close()
+	interactor := NewWebhookInteractor(engineServer.URL, "s3cret", WithWebhookStore(NewMemoryAnswerStore()))
+	callback := httptest.NewServer(interactor.CallbackHandler())
+	defer callback.Close()
+	handler := &InterruptionHandler{generator: mockGen, UserInteraction: interactor.Interact}
+
+	_, err := RunAgent(context.Background(), &Options{generator: mockGen, responseHandler: handler})
+	var suspended *ErrRunSuspended
+	require.True(t, errors.As(err, &suspended), "unexpected error: %v", err)
+
+	question := <-engine.questions
+	assert.Equal(t, suspended.Pending[0], question.Token)
+	assert.Equal(t, http.StatusNoContent, postCallback(t, callback, "s3cret", question.Token, "yes"))
+	assert.Equal(t, http.StatusNotFound, postCallback(t, callback, "s3cret", question.Token, "no"), "the token is one-time")
+
+	response, err := handler.Resume(context.Background(), suspended)
+	require.NoError(t, err)
+	assert.Equal(t, "Based on the approved budget...", response.Text())
+	assert.Equal(t, 1, engine.attempts, "a resumed question isn't delivered again")
+}
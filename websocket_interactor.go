@@ -0,0 +1,229 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Types of the frames exchanged with WebSocket clients.
+const (
+	// WSQuestion is sent to clients with a question to answer.
+	WSQuestion = "question"
+	// WSAnswer is sent by a client with the answer to the question with the frame's ID.
+	WSAnswer = "answer"
+	// WSError is sent to clients when a frame was rejected, or with an ID when the question
+	// was cancelled or timed out and can no longer be answered.
+	WSError = "error"
+	// WSNotify is sent to clients with information that needs no reply, such as a question
+	// having been answered by another client.
+	WSNotify = "notify"
+)
+
+// WSMessage is a JSON frame exchanged with WebSocket clients.
+type WSMessage struct {
+	Type     string         `json:"type"`
+	ID       string         `json:"id,omitempty"`
+	Question *QuestionInput `json:"question,omitempty"`
+	Answer   string         `json:"answer,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Message  string         `json:"message,omitempty"`
+}
+
+// wsClient is a connected client. Writes are serialized because a connection supports one writer at a time.
+type wsClient struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (c *wsClient) send(msg WSMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(msg)
+}
+
+// WebSocketInteractor pushes questions to browser clients over WebSocket and resolves each
+// with the first answer frame received for its ID. Pending questions are sent again to clients
+// that connect later, so a client that lost its connection picks up where it left off.
+type WebSocketInteractor struct {
+	upgrader websocket.Upgrader
+	timeout  time.Duration
+
+	// pending holds the questions by their IDs.
+	pending pendingAnswers[string]
+
+	// mu guards the IDs and the clients. It is taken before the lock of pending.
+	mu      sync.Mutex
+	nextID  uint64
+	clients map[*wsClient]struct{}
+}
+
+// WebSocketOption configures a WebSocketInteractor.
+type WebSocketOption func(*WebSocketInteractor)
+
+// WithWebSocketAnswerTimeout sets how long Interact waits for an answer.
+// Zero, the default, waits until the context is done.
+func WithWebSocketAnswerTimeout(timeout time.Duration) WebSocketOption {
+	return func(w *WebSocketInteractor) {
+		w.timeout = timeout
+	}
+}
+
+// WithCheckOrigin sets the function that accepts or rejects the origin of connecting clients.
+// By default only same-origin browser connections are accepted.
+func WithCheckOrigin(check func(r *http.Request) bool) WebSocketOption {
+	return func(w *WebSocketInteractor) {
+		w.upgrader.CheckOrigin = check
+	}
+}
+
+// NewWebSocketInteractor creates a WebSocketInteractor. Serve its Handler to let clients connect.
+func NewWebSocketInteractor(opts ...WebSocketOption) *WebSocketInteractor {
+	w := &WebSocketInteractor{
+		clients: make(map[*wsClient]struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Handler returns the HTTP handler that upgrades requests to WebSocket connections.
+func (w *WebSocketInteractor) Handler() http.Handler {
+	return http.HandlerFunc(w.serveWS)
+}
+
+// Interact sends the question to every connected client and blocks until one of them answers,
+// the timeout expires or the context is done. It implements UserInteractionFunc.
+// Clients are told with an error frame when the question can no longer be answered.
+func (w *WebSocketInteractor) Interact(ctx context.Context, input QuestionInput) (string, error) {
+	id, pending, clients := w.add(input)
+	w.sendAll(clients, WSMessage{Type: WSQuestion, ID: id, Question: &input})
+
+	answer, err := pending.wait(ctx, w.timeout)
+	if err == nil {
+		w.broadcast(WSMessage{Type: WSNotify, ID: id, Message: "answered"})
+		return answer, nil
+	}
+
+	// an answer may have arrived together with the cancellation; it is dropped like a late answer
+	w.pending.remove(id)
+	w.broadcast(WSMessage{Type: WSError, ID: id, Error: err.Error()})
+	return "", err
+}
+
+// add registers a new pending question under a fresh ID. It returns the clients connected
+// at that moment; clients that connect later receive the question when they connect.
+func (w *WebSocketInteractor) add(input QuestionInput) (string, *outstandingQuestion, []*wsClient) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.nextID++
+	id := strconv.FormatUint(w.nextID, 10)
+	return id, w.pending.add(id, input), w.clientsLocked()
+}
+
+func (w *WebSocketInteractor) clientsLocked() []*wsClient {
+	clients := make([]*wsClient, 0, len(w.clients))
+	for client := range w.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// broadcast sends the frame to every connected client.
+func (w *WebSocketInteractor) broadcast(msg WSMessage) {
+	w.mu.Lock()
+	clients := w.clientsLocked()
+	w.mu.Unlock()
+	w.sendAll(clients, msg)
+}
+
+// sendAll sends the frame to the clients. Clients that fail are disconnected.
+func (w *WebSocketInteractor) sendAll(clients []*wsClient, msg WSMessage) {
+	for _, client := range clients {
+		if err := client.send(msg); err != nil {
+			_ = client.conn.Close()
+		}
+	}
+}
+
+// serveWS upgrades the connection, sends the pending questions and reads answers until the client disconnects.
+func (w *WebSocketInteractor) serveWS(rw http.ResponseWriter, r *http.Request) {
+	conn, err := w.upgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		// the upgrader has already replied with an error
+		return
+	}
+	client := &wsClient{conn: conn}
+	defer func() {
+		w.mu.Lock()
+		delete(w.clients, client)
+		w.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	// the client is registered and given the pending questions under the same lock,
+	// so a question asked meanwhile is neither missed nor sent twice
+	w.mu.Lock()
+	w.clients[client] = struct{}{}
+	var sendErr error
+	w.pending.each(func(id string, input QuestionInput) bool {
+		sendErr = client.send(WSMessage{Type: WSQuestion, ID: id, Question: &input})
+		return sendErr == nil
+	})
+	w.mu.Unlock()
+	if sendErr != nil {
+		return
+	}
+
+	for {
+		var msg WSMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			// a frame that isn't valid JSON is rejected, any other error means the connection is gone
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) {
+				return
+			}
+			if err := client.send(WSMessage{Type: WSError, Error: "invalid frame: " + err.Error()}); err != nil {
+				return
+			}
+			continue
+		}
+		if reply, ok := w.handleFrame(msg); ok {
+			if err := client.send(reply); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleFrame processes a frame received from a client. It returns a reply for the client when the frame is rejected.
+func (w *WebSocketInteractor) handleFrame(msg WSMessage) (WSMessage, bool) {
+	if msg.Type != WSAnswer {
+		return WSMessage{Type: WSError, ID: msg.ID, Error: "unexpected frame type " + strconv.Quote(msg.Type)}, true
+	}
+
+	found := false
+	answered := w.pending.answer(msg.ID, func(input QuestionInput) (string, bool) {
+		found = true
+		answer := cmp.Or(strings.TrimSpace(msg.Answer), input.Default)
+		return answer, answer != ""
+	})
+	switch {
+	case !found:
+		return WSMessage{Type: WSError, ID: msg.ID, Error: "no pending question with id " + msg.ID}, true
+	case !answered:
+		return WSMessage{Type: WSError, ID: msg.ID, Error: "answer is empty"}, true
+	}
+	return WSMessage{}, false
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firebase/genkit/go/ai"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialWS connects a scripted client to the interactor served by server.
+func dialWS(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// readFrame reads the next frame, failing the test when none arrives in time.
+func readFrame(t *testing.T, conn *websocket.Conn) WSMessage {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	var msg WSMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+// waitForClients waits until the interactor has registered n clients.
+func waitForClients(t *testing.T, interactor *WebSocketInteractor, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		interactor.mu.Lock()
+		defer interactor.mu.Unlock()
+		return len(interactor.clients) == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestWebSocketInteractor_RunAgent(t *testing.T) {
+	mockGen := NewMockGenerator(
+		[]*ai.ModelResponse{
+			createInterruptedResponse(
+				createToolRequestPart("askQuestion", "What gender are the children?", []string{"Boy", "Girl", "Both"}),
+				createToolRequestPart("askQuestion", "What are their ages?", nil),
+			),
+			createTextResponse("Based on both genders and ages...", "stop"),
+		},
+		map[string]ai.Tool{"askQuestion": createMockTool("askQuestion")},
+	)
+	interactor := NewWebSocketInteractor(WithWebSocketAnswerTimeout(time.Second))
+	server := httptest.NewServer(interactor.Handler())
+	defer server.Close()
+
+	conn := dialWS(t, server)
+	waitForClients(t, interactor, 1)
+
+	answers := map[string]string{
+		"What gender are the children?": "Both",
+		"What are their ages?":          "8 and 11",
+	}
+	go func() {
+		for {
+			var msg WSMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Type == WSQuestion {
+				_ = conn.WriteJSON(WSMessage{Type: WSAnswer, ID: msg.ID, Answer: answers[msg.Question.Question]})
+			}
+		}
+	}()
+
+	result, err := RunAgent(context.Background(), &Options{
+		generator: mockGen,
+		responseHandler: &InterruptionHandler{
+			generator:       mockGen,
+			UserInteraction: interactor.Interact,
+		},
+	})
+
+	require.NoError(t, err)
+	assert.Contains(t, result, "Based on")
+}
+
+func TestWebSocketInteractor_Reconnect(t *testing.T) {
+	interactor := NewWebSocketInteractor(WithWebSocketAnswerTimeout(time.Second))
+	server := httptest.NewServer(interactor.Handler())
+	defer server.Close()
+
+	first := dialWS(t, server)
+	waitForClients(t, interactor, 1)
+
+	type result struct {
+		answer string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		answer, err := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?"})
+		done <- result{answer, err}
+	}()
+
+	question := readFrame(t, first)
+	require.Equal(t, WSQuestion, question.Type)
+	require.NoError(t, first.Close())
+	waitForClients(t, interactor, 0)
+
+	// the pending question is sent again to the reconnected client
+	second := dialWS(t, server)
+	resent := readFrame(t, second)
+	assert.Equal(t, question, resent)
+
+	require.NoError(t, second.WriteJSON(WSMessage{Type: WSAnswer, ID: resent.ID, Answer: "Boy"}))
+	res := <-done
+	require.NoError(t, res.err)
+	assert.Equal(t, "Boy", res.answer)
+	assert.Equal(t, WSMessage{Type: WSNotify, ID: resent.ID, Message: "answered"}, readFrame(t, second))
+}
+
+func TestWebSocketInteractor_Cancel(t *testing.T) {
+	interactor := NewWebSocketInteractor()
+	server := httptest.NewServer(interactor.Handler())
+	defer server.Close()
+
+	conn := dialWS(t, server)
+	waitForClients(t, interactor, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := interactor.Interact(ctx, QuestionInput{Question: "What gender?"})
+		done <- err
+	}()
+
+	question := readFrame(t, conn)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Equal(t, WSMessage{Type: WSError, ID: question.ID, Error: context.Canceled.Error()}, readFrame(t, conn))
+
+	// answering the cancelled question is rejected
+	require.NoError(t, conn.WriteJSON(WSMessage{Type: WSAnswer, ID: question.ID, Answer: "Boy"}))
+	rejected := readFrame(t, conn)
+	assert.Equal(t, WSError, rejected.Type)
+	assert.Contains(t, rejected.Error, "no pending question")
+}
+
+func TestWebSocketInteractor_RejectedFrames(t *testing.T) {
+	interactor := NewWebSocketInteractor(WithWebSocketAnswerTimeout(500 * time.Millisecond))
+	server := httptest.NewServer(interactor.Handler())
+	defer server.Close()
+
+	conn := dialWS(t, server)
+	waitForClients(t, interactor, 1)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := interactor.Interact(context.Background(), QuestionInput{Question: "What gender?"})
+		done <- err
+	}()
+	question := readFrame(t, conn)
+
+	tests := []struct {
+		name  string
+		frame string
+		error string
+	}{
+		{name: "not JSON", frame: "Boy", error: "invalid frame"},
+		{name: "unknown type", frame: `{"type":"question"}`, error: `unexpected frame type "question"`},
+		{name: "empty answer", frame: `{"type":"answer","id":"` + question.ID + `","answer":" "}`, error: "answer is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(tt.frame)))
+			reply := readFrame(t, conn)
+			assert.Equal(t, WSError, reply.Type)
+			assert.Contains(t, reply.Error, tt.error)
+		})
+	}
+
+	var timeoutErr *ErrAnswerTimeout
+	assert.True(t, errors.As(<-done, &timeoutErr), "rejected frames don't answer the question")
+}